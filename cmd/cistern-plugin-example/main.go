@@ -0,0 +1,140 @@
+// This is a reference implementation of a cistern plugin. It serves
+// pipelines described in a JSON file using the format of the generic
+// provider and is meant as a starting point for writing plugins for
+// CI systems not supported by cistern.
+//
+// Usage in the configuration file of cistern:
+//
+//	[[providers.plugin]]
+//	name = "example"
+//	command = ["cistern-plugin-example", "ci.example.com", "/path/to/pipelines.json"]
+//
+// For each request, cistern starts the plugin, writes a JSON request
+// on its standard input and reads a JSON response on its standard
+// output. See the manual page of cistern for a description of the
+// protocol.
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+)
+
+// Version of the protocol implemented by this plugin
+const protocolVersion = 1
+
+type request struct {
+	Protocol int    `json:"protocol"`
+	Method   string `json:"method"`
+	URL      string `json:"url"`
+	Sha      string `json:"sha"`
+	Key      string `json:"key"`
+}
+
+type response struct {
+	Protocol int             `json:"protocol"`
+	Error    string          `json:"error,omitempty"`
+	Host     string          `json:"host,omitempty"`
+	Methods  []string        `json:"methods,omitempty"`
+	Pipeline json.RawMessage `json:"pipeline,omitempty"`
+	URLs     []string        `json:"urls,omitempty"`
+	Log      string          `json:"log,omitempty"`
+}
+
+// Pipelines are passed to cistern as is, only the fields needed for
+// answering requests are decoded
+type pipeline struct {
+	URL string `json:"url"`
+	Sha string `json:"sha"`
+}
+
+func handle(host string, path string, req request) (response, error) {
+	resp := response{Protocol: protocolVersion}
+	if req.Protocol != protocolVersion {
+		return resp, fmt.Errorf("unsupported protocol version %d (expected %d)", req.Protocol, protocolVersion)
+	}
+
+	if req.Method == "handshake" {
+		resp.Host = host
+		resp.Methods = []string{"pipeline", "pipelines", "log"}
+		return resp, nil
+	}
+
+	bs, err := ioutil.ReadFile(path)
+	if err != nil {
+		return resp, err
+	}
+	var feed struct {
+		Pipelines []json.RawMessage `json:"pipelines"`
+	}
+	if err := json.Unmarshal(bs, &feed); err != nil {
+		return resp, err
+	}
+
+	switch req.Method {
+	case "pipeline":
+		for _, raw := range feed.Pipelines {
+			var p pipeline
+			if err := json.Unmarshal(raw, &p); err != nil {
+				return resp, err
+			}
+			if p.URL == req.URL {
+				resp.Pipeline = raw
+				break
+			}
+		}
+		// Leaving out the pipeline tells cistern that the URL is unknown
+
+	case "pipelines":
+		resp.URLs = make([]string, 0)
+		for _, raw := range feed.Pipelines {
+			var p pipeline
+			if err := json.Unmarshal(raw, &p); err != nil {
+				return resp, err
+			}
+			if p.Sha != "" && strings.HasPrefix(req.Sha, p.Sha) {
+				resp.URLs = append(resp.URLs, p.URL)
+			}
+		}
+
+	case "log":
+		// Steps of the file may set "log_key" to the path of their log file
+		bs, err := ioutil.ReadFile(req.Key)
+		if err != nil {
+			return resp, err
+		}
+		resp.Log = string(bs)
+
+	default:
+		return resp, fmt.Errorf("unknown method %q", req.Method)
+	}
+
+	return resp, nil
+}
+
+func main() {
+	if len(os.Args) != 3 {
+		fmt.Fprintln(os.Stderr, "usage: cistern-plugin-example HOST FILE")
+		os.Exit(2)
+	}
+
+	var req request
+	if err := json.NewDecoder(os.Stdin).Decode(&req); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	resp, err := handle(os.Args[1], os.Args[2], req)
+	if err != nil {
+		// Errors are reported to cistern as part of the response
+		resp.Error = err.Error()
+	}
+
+	if err := json.NewEncoder(os.Stdout).Encode(resp); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
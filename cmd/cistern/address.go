@@ -0,0 +1,79 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/nbedos/cistern/providers"
+)
+
+// Return the remotes of the repository 'repo' and the reference of 'commit' to monitor. 'repo'
+// is either the path of a local repository or the URL of an online repository.
+func monitoredReference(repo string, commit string) (map[string][]string, providers.Ref, error) {
+	if abs, err := filepath.Abs(repo); err == nil {
+		if _, err := os.Stat(abs); err == nil {
+			repo = abs
+		}
+	}
+
+	ref := providers.Ref{Name: commit}
+	remotes, err := providers.Remotes(repo)
+	switch err {
+	case nil:
+		if ref.Commit, err = providers.ResolveCommit(repo, ref.Name); err != nil {
+			return nil, ref, err
+		}
+	case providers.ErrUnknownRepositoryURL:
+		remotes = map[string][]string{"": {repo}}
+	default:
+		return nil, ref, err
+	}
+
+	return remotes, ref, nil
+}
+
+// Monitor the pipelines of 'ref' until one of them matches the address 'a' and return the key
+// of the pipeline and the path of the step designated by the address
+func resolveAddress(ctx context.Context, cache providers.Cache, remotes map[string][]string, ref providers.Ref, a providers.Address) (providers.PipelineKey, []string, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	events := cache.Subscribe(ctx)
+	errc := make(chan error, 1)
+	go func() {
+		errc <- cache.MonitorPipelines(ctx, remotes, ref, true)
+	}()
+
+	var lastWarning error
+	for {
+		select {
+		case e := <-events:
+			if e.Type == providers.ProviderFailed {
+				lastWarning = e.Err
+				continue
+			}
+			if e.Type != providers.PipelineAdded && e.Type != providers.CommitSaved {
+				continue
+			}
+			key, stepIDs, err := a.Resolve(cache.Pipelines(ref.Name))
+			if err != providers.ErrAddressNotFound {
+				return key, stepIDs, err
+			}
+
+		case err := <-errc:
+			if err != nil {
+				return providers.PipelineKey{}, nil, err
+			}
+			key, stepIDs, err := a.Resolve(cache.Pipelines(ref.Name))
+			if err == providers.ErrAddressNotFound && lastWarning != nil {
+				err = fmt.Errorf("%w (%v)", err, lastWarning)
+			}
+			return key, stepIDs, err
+
+		case <-ctx.Done():
+			return providers.PipelineKey{}, nil, ctx.Err()
+		}
+	}
+}
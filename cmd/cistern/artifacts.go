@@ -0,0 +1,33 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/nbedos/cistern/providers"
+)
+
+// Download the artifacts of the job at the cursor to the artifacts directory
+func (c *Controller) downloadArtifacts(ctx context.Context) {
+	defer c.draw()
+	key, ids, exists := c.activeStepPath()
+	if !exists || len(ids) == 0 {
+		c.writeStatus("No job at cursor")
+		return
+	}
+	c.writeStatus("Downloading artifacts...")
+	c.draw()
+
+	dir, artifacts, err := c.cache.DownloadArtifacts(ctx, key, ids, c.conf.ArtifactsDir)
+	switch {
+	case err == providers.ErrNoArtifacts:
+		c.writeStatus("The provider of this job does not expose its artifacts")
+	case err != nil:
+		c.writeStatus("")
+		c.reportError(err)
+	case len(artifacts) == 0:
+		c.writeStatus("This job has no artifact")
+	default:
+		c.writeStatus(fmt.Sprintf("Downloaded %d artifact(s) to %s", len(artifacts), dir))
+	}
+}
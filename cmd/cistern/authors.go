@@ -0,0 +1,50 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/nbedos/cistern/providers"
+)
+
+// Pipelines of the user are recognized by the username of the user who triggered them or,
+// when the provider does not tell, by the author or the committer of their commit
+type authorFilter struct {
+	Usernames []string
+	// Only show pipelines of the user when the application starts
+	OnlyMine bool
+}
+
+func (a authorFilter) matches(pipeline providers.Pipeline, commit providers.Commit) bool {
+	candidates := []string{pipeline.Author}
+	if pipeline.Author == "" {
+		candidates = []string{commit.Author, commit.Committer}
+	}
+	for _, username := range a.Usernames {
+		if username == "" {
+			continue
+		}
+		for _, candidate := range candidates {
+			if strings.Contains(strings.ToLower(candidate), strings.ToLower(username)) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// Toggle between showing all pipelines and only pipelines of the user
+func (c *Controller) toggleOnlyMine() {
+	if len(c.conf.Authors.Usernames) == 0 {
+		c.writeStatus("No username configured (see the \"authors\" section of the configuration file)")
+		return
+	}
+	c.onlyMine = !c.onlyMine
+	c.refresh()
+	if c.onlyMine {
+		c.writeStatus(fmt.Sprintf("Showing only pipelines of %s", strings.Join(c.conf.Authors.Usernames, ", ")))
+	} else {
+		c.writeStatus("Showing all pipelines")
+	}
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/nbedos/cistern/providers"
+)
+
+func TestAuthorFilter_matches(t *testing.T) {
+	filter := authorFilter{Usernames: []string{"nbedos"}}
+	commit := providers.Commit{
+		Author:    "Jane Doe <jane@example.com>",
+		Committer: "Nicolas Bedos <nbedos@example.com>",
+	}
+
+	testCases := []struct {
+		name     string
+		filter   authorFilter
+		pipeline providers.Pipeline
+		commit   providers.Commit
+		expected bool
+	}{
+		{
+			name:     "pipeline triggered by user",
+			filter:   filter,
+			pipeline: providers.Pipeline{Author: "NBedos"},
+			expected: true,
+		},
+		{
+			name:     "pipeline triggered by someone else",
+			filter:   filter,
+			pipeline: providers.Pipeline{Author: "jdoe"},
+			commit:   commit,
+			expected: false,
+		},
+		{
+			name:     "unknown trigger but commit of user",
+			filter:   filter,
+			commit:   commit,
+			expected: true,
+		},
+		{
+			name:     "unknown trigger and commit of someone else",
+			filter:   filter,
+			commit:   providers.Commit{Author: "Jane Doe <jane@example.com>"},
+			expected: false,
+		},
+		{
+			name:     "no username",
+			pipeline: providers.Pipeline{Author: "nbedos"},
+			expected: false,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			if m := testCase.filter.matches(testCase.pipeline, testCase.commit); m != testCase.expected {
+				t.Fatalf("expected %v but got %v", testCase.expected, m)
+			}
+		})
+	}
+}
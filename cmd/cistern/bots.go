@@ -0,0 +1,56 @@
+package main
+
+import (
+	"strings"
+
+	"github.com/nbedos/cistern/providers"
+	"github.com/nbedos/cistern/tui"
+)
+
+// Name of the row gathering the pipelines of bots
+const botGroupName = "bot updates"
+
+// Pipelines run for bots, typically automated dependency updates, are recognized either by
+// the prefix of their git reference or by the author of their commit
+type botDetection struct {
+	RefPrefixes []string
+	Authors     []string
+}
+
+func (b botDetection) matches(ref string, author string) bool {
+	for _, prefix := range b.RefPrefixes {
+		if prefix != "" && strings.HasPrefix(ref, prefix) {
+			return true
+		}
+	}
+	author = strings.ToLower(author)
+	for _, name := range b.Authors {
+		if name != "" && strings.Contains(author, strings.ToLower(name)) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Return the nodes of the table: pipelines of bots are gathered under a single row
+// so that they do not clutter the table
+func (b botDetection) tableNodes(pipelines []providers.Pipeline, author string) []tui.TableNode {
+	nodes := make([]tui.TableNode, 0, len(pipelines))
+	bots := make(providers.Pipelines, 0)
+	for _, pipeline := range pipelines {
+		if b.matches(pipeline.Ref, author) {
+			bots = append(bots, pipeline)
+		} else {
+			nodes = append(nodes, pipeline)
+		}
+	}
+	if len(bots) > 0 {
+		nodes = append(nodes, providers.PipelineGroup{
+			Name:      botGroupName,
+			Pipelines: bots,
+		})
+	}
+
+	return nodes
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/nbedos/cistern/providers"
+)
+
+func TestBotDetection_tableNodes(t *testing.T) {
+	bots := botDetection{
+		RefPrefixes: []string{"dependabot/"},
+		Authors:     []string{"renovate[bot]"},
+	}
+	pipelines := []providers.Pipeline{
+		{Ref: "master", Step: providers.Step{ID: "1"}},
+		{Ref: "dependabot/npm_and_yarn/lodash", Step: providers.Step{ID: "2"}},
+		{Ref: "feature", Step: providers.Step{ID: "3"}},
+	}
+
+	t.Run("grouping by reference", func(t *testing.T) {
+		nodes := bots.tableNodes(pipelines, "John Doe <john@example.com>")
+		if len(nodes) != 3 {
+			t.Fatalf("expected 3 nodes but got %d", len(nodes))
+		}
+		group, ok := nodes[2].(providers.PipelineGroup)
+		if !ok {
+			t.Fatalf("expected last node to be a group but got %T", nodes[2])
+		}
+		if len(group.Pipelines) != 1 || group.Pipelines[0].ID != "2" {
+			t.Fatalf("unexpected pipelines in group: %+v", group.Pipelines)
+		}
+	})
+
+	t.Run("grouping by author", func(t *testing.T) {
+		nodes := bots.tableNodes(pipelines, "Renovate[bot] <bot@renovateapp.com>")
+		if len(nodes) != 1 {
+			t.Fatalf("expected 1 node but got %d", len(nodes))
+		}
+	})
+
+	t.Run("no bot", func(t *testing.T) {
+		nodes := botDetection{}.tableNodes(pipelines, "dependabot[bot]")
+		for _, node := range nodes {
+			if _, ok := node.(providers.PipelineGroup); ok {
+				t.Fatal("expected no group")
+			}
+		}
+	})
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"encoding/base64"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+)
+
+// Return the OSC 52 escape sequence setting the clipboard of the terminal to 'text'. Inside
+// tmux the sequence is wrapped so that tmux passes it through to the terminal.
+func osc52(text string, tmux bool) string {
+	seq := fmt.Sprintf("\x1b]52;c;%s\a", base64.StdEncoding.EncodeToString([]byte(text)))
+	if tmux {
+		seq = fmt.Sprintf("\x1bPtmux;%s\x1b\\", strings.Replace(seq, "\x1b", "\x1b\x1b", -1))
+	}
+	return seq
+}
+
+// Return the command line of the first clipboard utility available: wl-copy on Wayland,
+// xclip or xsel on X11, pbcopy on macOS and clip.exe on Windows and WSL. Return nil if
+// there is none.
+func clipboardCommand(getenv func(string) string, goos string, lookPath func(string) (string, error)) []string {
+	candidates := make([][]string, 0)
+	if getenv("WAYLAND_DISPLAY") != "" {
+		candidates = append(candidates, []string{"wl-copy"})
+	}
+	if getenv("DISPLAY") != "" {
+		candidates = append(candidates, []string{"xclip", "-selection", "clipboard"})
+		candidates = append(candidates, []string{"xsel", "--clipboard", "--input"})
+	}
+	if goos == "darwin" {
+		candidates = append(candidates, []string{"pbcopy"})
+	}
+	candidates = append(candidates, []string{"clip.exe"})
+
+	for _, candidate := range candidates {
+		if _, err := lookPath(candidate[0]); err == nil {
+			return candidate
+		}
+	}
+
+	return nil
+}
+
+// Run the clipboard utility of the command line 'args' with 'text' as input. The output of
+// the utility is discarded instead of being captured: xclip, xsel and wl-copy leave a process
+// running in the background to own the selection, and this process would keep the pipes open
+// until another application takes the clipboard.
+func runClipboardCommand(args []string, text string) error {
+	cmd := exec.Command(args[0], args[1:]...)
+	cmd.Stdin = strings.NewReader(text)
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("%s failed: %w", args[0], err)
+	}
+
+	return nil
+}
+
+// Copy 'text' to the system clipboard with the first clipboard utility available. Return the
+// name of the utility used, or an empty string if none was found.
+func copyToClipboard(text string) (string, error) {
+	args := clipboardCommand(os.Getenv, runtime.GOOS, exec.LookPath)
+	if args == nil {
+		return "", nil
+	}
+
+	return args[0], runClipboardCommand(args, text)
+}
+
+// Copy the URL of the web page of the step at the cursor to the clipboard. Failing to copy
+// the URL is not fatal and is reported in the error console.
+func (c *Controller) copyActiveRowURL() {
+	key, ids, exists := c.activeStepPath()
+	if !exists {
+		c.writeStatus("No URL for this row")
+		return
+	}
+	step, exists := c.cache.Step(key, ids)
+	if !exists || !step.WebURL.Valid {
+		c.writeStatus("No URL for this row")
+		return
+	}
+
+	// The sequence also sets the clipboard over SSH with terminals supporting it
+	if err := c.tui.WriteSequence(osc52(step.WebURL.String, os.Getenv("TMUX") != "")); err != nil {
+		c.reportError(fmt.Errorf("failed to copy URL to the clipboard: %w", err))
+		return
+	}
+	utility, err := copyToClipboard(step.WebURL.String)
+	if err != nil {
+		c.reportError(fmt.Errorf("failed to copy URL to the clipboard: %w", err))
+		return
+	}
+	if utility == "" {
+		c.writeStatus(fmt.Sprintf("Copied %s to the clipboard of the terminal", step.WebURL.String))
+	} else {
+		c.writeStatus(fmt.Sprintf("Copied %s to the clipboard", step.WebURL.String))
+	}
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"errors"
+	"os/exec"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestOsc52(t *testing.T) {
+	if s := osc52("https://example.com", false); s != "\x1b]52;c;aHR0cHM6Ly9leGFtcGxlLmNvbQ==\a" {
+		t.Fatalf("unexpected sequence %q", s)
+	}
+
+	s := osc52("https://example.com", true)
+	if !strings.HasPrefix(s, "\x1bPtmux;\x1b\x1b]52;c;") || !strings.HasSuffix(s, "\a\x1b\\") {
+		t.Fatalf("unexpected sequence %q", s)
+	}
+}
+
+func TestClipboardCommand(t *testing.T) {
+	testCases := []struct {
+		name      string
+		env       map[string]string
+		goos      string
+		installed []string
+		expected  []string
+	}{
+		{
+			name:      "wayland",
+			env:       map[string]string{"WAYLAND_DISPLAY": "wayland-0", "DISPLAY": ":0"},
+			goos:      "linux",
+			installed: []string{"wl-copy", "xclip"},
+			expected:  []string{"wl-copy"},
+		},
+		{
+			name:      "X11 without xclip",
+			env:       map[string]string{"DISPLAY": ":0"},
+			goos:      "linux",
+			installed: []string{"wl-copy", "xsel"},
+			expected:  []string{"xsel", "--clipboard", "--input"},
+		},
+		{
+			name:      "macOS",
+			goos:      "darwin",
+			installed: []string{"pbcopy"},
+			expected:  []string{"pbcopy"},
+		},
+		{
+			name:      "WSL",
+			goos:      "linux",
+			installed: []string{"clip.exe"},
+			expected:  []string{"clip.exe"},
+		},
+		{
+			name:      "no utility",
+			env:       map[string]string{"DISPLAY": ":0"},
+			goos:      "linux",
+			installed: []string{"pbcopy"},
+			expected:  nil,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			getenv := func(key string) string { return testCase.env[key] }
+			lookPath := func(name string) (string, error) {
+				for _, installed := range testCase.installed {
+					if name == installed {
+						return "/usr/bin/" + name, nil
+					}
+				}
+				return "", errors.New("not found")
+			}
+			if diff := cmp.Diff(testCase.expected, clipboardCommand(getenv, testCase.goos, lookPath)); diff != "" {
+				t.Fatal(diff)
+			}
+		})
+	}
+}
+
+func TestRunClipboardCommand(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("sh is not available")
+	}
+
+	// Like xclip, leave a process running in the background after reading the input
+	args := []string{"sh", "-c", "cat > /dev/null; sleep 10 &"}
+	errc := make(chan error)
+	go func() {
+		errc <- runClipboardCommand(args, "https://example.com")
+	}()
+
+	select {
+	case err := <-errc:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("runClipboardCommand must return without waiting for background processes")
+	}
+}
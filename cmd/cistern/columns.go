@@ -0,0 +1,43 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// Open the prompt listing the columns of the table in order
+func (c *Controller) openColumnsPrompt() {
+	c.focus = focusColumns
+	c.columnscmd.Focus()
+	columns := c.table.Columns()
+	names := make([]string, 0, len(columns))
+	for _, id := range columns.IDs() {
+		names = append(names, strings.ToLower(columns[id].Header))
+	}
+	c.columnscmd.SetInput(strings.Join(names, " "))
+}
+
+// Show the columns whose names are listed in 'input', separated by spaces or commas, in the
+// order of the list
+func (c *Controller) setColumns(input string) {
+	names := strings.FieldsFunc(input, func(r rune) bool {
+		return r == ',' || unicode.IsSpace(r)
+	})
+	if len(names) == 0 {
+		c.writeStatus("error: at least one column must be shown")
+		return
+	}
+	columns, err := selectColumns(names, c.conf.Columns)
+	if err != nil {
+		available := make([]string, 0, len(c.conf.Columns))
+		for _, id := range c.conf.Columns.IDs() {
+			available = append(available, strings.ToLower(c.conf.Columns[id].Header))
+		}
+		sort.Strings(available)
+		c.writeStatus(fmt.Sprintf("error: %v (available columns: %s)", err, strings.Join(available, ", ")))
+		return
+	}
+	c.table.SetColumns(columns)
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestController_setColumns(t *testing.T) {
+	controller, teardown, err := setup()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer teardown()
+	controller.conf.Columns = defaultTableColumns
+	controller.resize(80, 30)
+
+	// Return the names of the columns of the table in order
+	names := func() string {
+		controller.openColumnsPrompt()
+		controller.focus = focusTable
+		return controller.columnscmd.Input()
+	}
+
+	controller.setColumns("name, State  ref")
+	if s := names(); s != "name state ref" {
+		t.Fatalf("expected %q but got %q", "name state ref", s)
+	}
+
+	for _, input := range []string{"name size", " , "} {
+		controller.setColumns(input)
+		if s := controller.status.Content[0].String(); !strings.HasPrefix(s, "error: ") {
+			t.Fatalf("%q: expected an error but got %q", input, s)
+		}
+		if s := names(); s != "name state ref" {
+			t.Fatalf("%q: expected %q but got %q", input, "name state ref", s)
+		}
+	}
+}
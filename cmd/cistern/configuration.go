@@ -1,16 +1,22 @@
 package main
 
 import (
+	"crypto/sha256"
 	"errors"
 	"fmt"
 	"io/ioutil"
 	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/gdamore/tcell"
 	"github.com/nbedos/cistern/providers"
 	"github.com/nbedos/cistern/tui"
+	"github.com/nbedos/cistern/utils"
 	"github.com/pelletier/go-toml"
 )
 
@@ -21,23 +27,68 @@ const ConfFilename = "cistern.toml"
 // Warning: go-toml ignores default values on fields of nested structs
 // See https://github.com/pelletier/go-toml/issues/274
 type Configuration struct {
-	Providers    providers.Configuration `toml:"providers"`
-	Location     string                  `toml:"location" default:"Local"`
-	Columns      []string                `toml:"columns"`
-	Sort         string                  `toml:"sort"`
-	Depth        int                     `toml:"depth" default:"2"`
-	AutoCollapse struct {
+	Providers providers.Configuration `toml:"providers"`
+	Location  string                  `toml:"location" default:"Local"`
+	Columns   []string                `toml:"columns"`
+	// Maximum width of columns by name of the column
+	ColumnWidths      map[string]int `toml:"column-widths"`
+	Sort              string         `toml:"sort"`
+	Depth             int            `toml:"depth" default:"2"`
+	MergeProviders    bool           `toml:"merge-providers"`
+	GroupPullRequests bool           `toml:"group-pull-requests"`
+	Mouse             bool           `toml:"mouse" default:"true"`
+	AutoCollapse      struct {
 		Job      bool `toml:"job"`
 		Stage    bool `toml:"stage"`
 		Pipeline bool `toml:"pipeline"`
+		Finished bool `toml:"finished"`
 	} `toml:"autocollapse"`
+	Bots struct {
+		Refs    []string `toml:"refs"`
+		Authors []string `toml:"authors"`
+	} `toml:"bots"`
+	Authors struct {
+		Usernames []string `toml:"usernames"`
+		OnlyMine  bool     `toml:"only-mine"`
+	} `toml:"authors"`
+	Mute struct {
+		Duration int `toml:"duration"`
+	} `toml:"mute"`
+	Logs struct {
+		Directory string `toml:"directory"`
+		MaxAge    int    `toml:"max-age"`
+		Viewer    string `toml:"viewer"`
+	} `toml:"logs"`
+	Artifacts struct {
+		Directory string `toml:"directory"`
+	} `toml:"artifacts"`
+	Cache struct {
+		Enabled   bool   `toml:"enabled"`
+		Directory string `toml:"directory"`
+		MaxAge    int    `toml:"max-age"`
+		Memory    struct {
+			MaxPipelines int `toml:"max-pipelines"`
+			MaxAge       int `toml:"max-age"`
+		} `toml:"memory"`
+	} `toml:"cache"`
+	Costs struct {
+		Currency string `toml:"currency"`
+		Rates    []struct {
+			Provider  string   `toml:"provider"`
+			Jobs      []string `toml:"jobs"`
+			PerMinute float64  `toml:"per-minute"`
+		} `toml:"rates"`
+	} `toml:"costs"`
 	Style struct {
 		Theme   string                        `toml:"theme"`
+		Icons   string                        `toml:"icons"`
 		Default *tui.StyleTransformDefinition `toml:"default"`
 		Table   struct {
 			Separator  string                        `toml:"separator"`
 			Ascending  string                        `toml:"ascending"`
 			Descending string                        `toml:"descending"`
+			ColorRows  bool                          `toml:"color-rows"`
+			Ellipsis   *string                       `toml:"ellipsis"`
 			Header     *tui.StyleTransformDefinition `toml:"header"`
 			Cursor     *tui.StyleTransformDefinition `toml:"cursor"`
 			Provider   *tui.StyleTransformDefinition `toml:"provider"`
@@ -57,66 +108,358 @@ type Configuration struct {
 			Tag    *tui.StyleTransformDefinition `toml:"tag"`
 			Head   *tui.StyleTransformDefinition `toml:"head"`
 		} `toml:"git"`
+		// Themes defined by the user by name of the theme
+		Palettes map[string]paletteDefinition `toml:"palettes"`
 	} `toml:"style"`
-	Man string
-}
-
-var monochromeTableConfiguration = tui.TableConfiguration{
-	Cursor: func(s tcell.Style) tcell.Style { return s.Reverse(true) },
-	Header: func(s tcell.Style) tcell.Style { return s.Reverse(true).Bold(true) },
-	NodeStyle: providers.StepStyle{
-		GitStyle: providers.GitStyle{
-			SHA:    nil,
-			Head:   nil,
-			Branch: nil,
-			Tag:    nil,
-		},
-		Provider: func(s tcell.Style) tcell.Style { return s.Bold(true) },
-		Status: struct {
-			Failed   tui.StyleTransform
-			Canceled tui.StyleTransform
-			Passed   tui.StyleTransform
-			Running  tui.StyleTransform
-			Pending  tui.StyleTransform
-			Skipped  tui.StyleTransform
-			Manual   tui.StyleTransform
-		}{},
-	},
+	// Number of colors of the terminal, 0 if unknown. Themes using more colors than the
+	// terminal supports are replaced by a theme the terminal can show.
+	Colors int `toml:"-"`
+	// Keys bound to each action of the user interface by name of the action
+	Keys map[string][]string `toml:"keys"`
+	Man  string
+	// Snapshot of the cache written when the user interface exits, set on the command line
+	Export struct {
+		Path string
+		Logs bool
+	} `toml:"-"`
+	// Path of the snapshot shown instead of the pipelines of CI providers, set on the
+	// command line
+	Replay string `toml:"-"`
 }
 
-var defaultTableConfiguration = tui.TableConfiguration{
-	Cursor: func(s tcell.Style) tcell.Style { return s.Background(tcell.ColorSilver).Foreground(tcell.ColorBlack).Bold(false).Underline(false).Blink(false) },
-	Header: func(s tcell.Style) tcell.Style { return s.Bold(true).Reverse(true) },
-	NodeStyle: providers.StepStyle{
-		GitStyle: providers.GitStyle{
-			SHA:    func(s tcell.Style) tcell.Style { return s.Foreground(tcell.ColorOlive) },
-			Head:   func(s tcell.Style) tcell.Style { return s.Foreground(tcell.ColorAqua) },
-			Branch: func(s tcell.Style) tcell.Style { return s.Foreground(tcell.ColorTeal).Bold(false) },
-			Tag:    func(s tcell.Style) tcell.Style { return s.Foreground(tcell.ColorYellow).Bold(false) },
-		},
+// Colors of a theme. Elements whose color is tcell.ColorDefault keep the style of the
+// terminal, so the zero value of a palette must not be used: start from terminalPalette.
+type palette struct {
+	HeaderForeground tcell.Color
+	HeaderBackground tcell.Color
+	CursorForeground tcell.Color
+	CursorBackground tcell.Color
+	Passed           tcell.Color
+	Failed           tcell.Color
+	Running          tcell.Color
+	// Color of pending, skipped, canceled and manual steps
+	Inactive tcell.Color
+	SHA      tcell.Color
+	Head     tcell.Color
+	Branch   tcell.Color
+	Tag      tcell.Color
+	// Color of the occurrences of the text searched in the table and the log viewer
+	Match tcell.Color
+}
+
+// Palette of the "monochrome" theme: only bold and reverse video are used
+var terminalPalette = palette{
+	HeaderForeground: tcell.ColorDefault,
+	HeaderBackground: tcell.ColorDefault,
+	CursorForeground: tcell.ColorDefault,
+	CursorBackground: tcell.ColorDefault,
+	Passed:           tcell.ColorDefault,
+	Failed:           tcell.ColorDefault,
+	Running:          tcell.ColorDefault,
+	Inactive:         tcell.ColorDefault,
+	SHA:              tcell.ColorDefault,
+	Head:             tcell.ColorDefault,
+	Branch:           tcell.ColorDefault,
+	Tag:              tcell.ColorDefault,
+	Match:            tcell.ColorDefault,
+}
+
+// Palette of the "default" theme made of the 16 colors of the terminal
+var defaultPalette = palette{
+	HeaderForeground: tcell.ColorDefault,
+	HeaderBackground: tcell.ColorDefault,
+	CursorForeground: tcell.ColorBlack,
+	CursorBackground: tcell.ColorSilver,
+	Passed:           tcell.ColorGreen,
+	Failed:           tcell.ColorMaroon,
+	Running:          tcell.ColorOlive,
+	Inactive:         tcell.ColorGray,
+	SHA:              tcell.ColorOlive,
+	Head:             tcell.ColorAqua,
+	Branch:           tcell.ColorTeal,
+	Tag:              tcell.ColorYellow,
+	Match:            tcell.ColorOlive,
+}
+
+// Colors of the other built-in palettes are given in RGB. tcell approximates them with the
+// closest color of the palette on terminals that do not support truecolor.
+func hex(n int32) tcell.Color {
+	return tcell.NewHexColor(n)
+}
+
+var lightPalette = palette{
+	HeaderForeground: tcell.ColorDefault,
+	HeaderBackground: tcell.ColorDefault,
+	CursorForeground: hex(0x000000),
+	CursorBackground: hex(0xd0d0d0),
+	Passed:           hex(0x2e7d32),
+	Failed:           hex(0xc62828),
+	Running:          hex(0x9e6a00),
+	Inactive:         hex(0x757575),
+	SHA:              hex(0x8a6d00),
+	Head:             hex(0x00838f),
+	Branch:           hex(0x00695c),
+	Tag:              hex(0xa05a00),
+	Match:            hex(0x9e6a00),
+}
+
+var darkPalette = palette{
+	HeaderForeground: tcell.ColorDefault,
+	HeaderBackground: tcell.ColorDefault,
+	CursorForeground: hex(0xffffff),
+	CursorBackground: hex(0x444444),
+	Passed:           hex(0x87d75f),
+	Failed:           hex(0xff5f5f),
+	Running:          hex(0xffd75f),
+	Inactive:         hex(0x8a8a8a),
+	SHA:              hex(0xd7af5f),
+	Head:             hex(0x5fd7ff),
+	Branch:           hex(0x5fafaf),
+	Tag:              hex(0xffd700),
+	Match:            hex(0xffd75f),
+}
+
+// Solarized palettes, see https://ethanschoonover.com/solarized/
+var solarizedDarkPalette = palette{
+	HeaderForeground: hex(0x002b36),
+	HeaderBackground: hex(0x839496),
+	CursorForeground: hex(0x93a1a1),
+	CursorBackground: hex(0x073642),
+	Passed:           hex(0x859900),
+	Failed:           hex(0xdc322f),
+	Running:          hex(0xb58900),
+	Inactive:         hex(0x586e75),
+	SHA:              hex(0xb58900),
+	Head:             hex(0x268bd2),
+	Branch:           hex(0x2aa198),
+	Tag:              hex(0xcb4b16),
+	Match:            hex(0xb58900),
+}
+
+var solarizedLightPalette = palette{
+	HeaderForeground: hex(0xfdf6e3),
+	HeaderBackground: hex(0x657b83),
+	CursorForeground: hex(0x586e75),
+	CursorBackground: hex(0xeee8d5),
+	Passed:           hex(0x859900),
+	Failed:           hex(0xdc322f),
+	Running:          hex(0xb58900),
+	Inactive:         hex(0x93a1a1),
+	SHA:              hex(0xb58900),
+	Head:             hex(0x268bd2),
+	Branch:           hex(0x2aa198),
+	Tag:              hex(0xcb4b16),
+	Match:            hex(0xb58900),
+}
+
+// Return the built-in palette of the theme 'name'. Themes whose name ends with a variant
+// such as "-dark" are also available without it, in which case the variant is chosen
+// according to the background of the terminal.
+func builtinPalette(name string) (palette, bool) {
+	light, ok := lightBackground(os.Getenv("COLORFGBG"))
+	switch name {
+	case "", "auto":
+		switch {
+		case !ok:
+			return defaultPalette, true
+		case light:
+			return lightPalette, true
+		default:
+			return darkPalette, true
+		}
+	case "default":
+		return defaultPalette, true
+	case "monochrome":
+		return terminalPalette, true
+	case "light":
+		return lightPalette, true
+	case "dark":
+		return darkPalette, true
+	case "solarized":
+		if ok && light {
+			return solarizedLightPalette, true
+		}
+		return solarizedDarkPalette, true
+	case "solarized-dark":
+		return solarizedDarkPalette, true
+	case "solarized-light":
+		return solarizedLightPalette, true
+	}
+
+	return palette{}, false
+}
+
+// Return true if the palette can be shown as it is by a terminal with the given number of
+// colors. Colors other than the 16 colors of the terminal require 256 colors, tcell then
+// approximates RGB colors if the terminal does not support truecolor.
+func (p palette) fits(colors int) bool {
+	for _, c := range []tcell.Color{p.HeaderForeground, p.HeaderBackground, p.CursorForeground,
+		p.CursorBackground, p.Passed, p.Failed, p.Running, p.Inactive, p.SHA, p.Head, p.Branch,
+		p.Tag, p.Match} {
+		switch {
+		case c == tcell.ColorDefault:
+			continue
+		case colors < 8:
+			return false
+		case colors < 256 && (c&tcell.ColorIsRGB != 0 || c >= 16):
+			return false
+		}
+	}
+	return true
+}
+
+// Return the style transformation setting the foreground color of text to 'c', or nil if
+// 'c' is the default color of the terminal
+func foreground(c tcell.Color) tui.StyleTransform {
+	if c == tcell.ColorDefault {
+		return nil
+	}
+	return func(s tcell.Style) tcell.Style { return s.Foreground(c).Bold(false) }
+}
+
+// Return the configuration of the table showing the colors of the palette
+func (p palette) tableConfiguration() tui.TableConfiguration {
+	tconf := tui.TableConfiguration{
+		Cursor: func(s tcell.Style) tcell.Style { return s.Reverse(true) },
+		Header: func(s tcell.Style) tcell.Style { return s.Bold(true).Reverse(true) },
+	}
+	if p.CursorForeground != tcell.ColorDefault || p.CursorBackground != tcell.ColorDefault {
+		tconf.Cursor = func(s tcell.Style) tcell.Style {
+			return s.Background(p.CursorBackground).Foreground(p.CursorForeground).Bold(false).Underline(false).Blink(false)
+		}
+	}
+	if p.HeaderForeground != tcell.ColorDefault || p.HeaderBackground != tcell.ColorDefault {
+		tconf.Header = func(s tcell.Style) tcell.Style {
+			return s.Background(p.HeaderBackground).Foreground(p.HeaderForeground).Bold(true)
+		}
+	}
+
+	stepStyle := providers.StepStyle{
 		Provider: func(s tcell.Style) tcell.Style { return s.Bold(true) },
-		Status: struct {
-			Failed   tui.StyleTransform
-			Canceled tui.StyleTransform
-			Passed   tui.StyleTransform
-			Running  tui.StyleTransform
-			Pending  tui.StyleTransform
-			Skipped  tui.StyleTransform
-			Manual   tui.StyleTransform
-		}{
-			Failed:   func(s tcell.Style) tcell.Style { return s.Foreground(tcell.ColorMaroon).Bold(false) },
-			Canceled: func(s tcell.Style) tcell.Style { return s.Foreground(tcell.ColorMaroon).Bold(false) },
-			Passed:   func(s tcell.Style) tcell.Style { return s.Foreground(tcell.ColorGreen).Bold(false) },
-			Running:  func(s tcell.Style) tcell.Style { return s.Foreground(tcell.ColorOlive).Bold(false) },
-			Pending:  func(s tcell.Style) tcell.Style { return s.Foreground(tcell.ColorGray).Bold(false) },
-			Skipped:  func(s tcell.Style) tcell.Style { return s.Foreground(tcell.ColorGray).Bold(false) },
-			Manual:   func(s tcell.Style) tcell.Style { return s.Foreground(tcell.ColorGray).Bold(false) },
-		},
-	},
+	}
+	stepStyle.GitStyle.Branch = foreground(p.Branch)
+	stepStyle.GitStyle.Tag = foreground(p.Tag)
+	if p.SHA != tcell.ColorDefault {
+		stepStyle.GitStyle.SHA = func(s tcell.Style) tcell.Style { return s.Foreground(p.SHA) }
+	}
+	if p.Head != tcell.ColorDefault {
+		stepStyle.GitStyle.Head = func(s tcell.Style) tcell.Style { return s.Foreground(p.Head) }
+	}
+	stepStyle.Status.Passed = foreground(p.Passed)
+	stepStyle.Status.Failed = foreground(p.Failed)
+	stepStyle.Status.Canceled = foreground(p.Inactive)
+	stepStyle.Status.Running = foreground(p.Running)
+	stepStyle.Status.Pending = foreground(p.Inactive)
+	stepStyle.Status.Skipped = foreground(p.Inactive)
+	stepStyle.Status.Manual = foreground(p.Inactive)
+	tconf.NodeStyle = stepStyle
+	tconf.Match = p.match()
+
+	return tconf
+}
+
+// Return the style of the occurrences of the text searched in the table and in the log viewer
+func (p palette) match() tui.StyleTransform {
+	if p.Match == tcell.ColorDefault {
+		return func(s tcell.Style) tcell.Style { return s.Reverse(true) }
+	}
+	return func(s tcell.Style) tcell.Style { return s.Foreground(p.Match).Reverse(true) }
+}
+
+// Palette defined in the configuration file. Colors left out are those of the built-in
+// theme 'Base', or of the default theme if 'Base' is empty.
+type paletteDefinition struct {
+	Base             string `toml:"base"`
+	HeaderForeground string `toml:"header-foreground"`
+	HeaderBackground string `toml:"header-background"`
+	CursorForeground string `toml:"cursor-foreground"`
+	CursorBackground string `toml:"cursor-background"`
+	Passed           string `toml:"passed"`
+	Failed           string `toml:"failed"`
+	Running          string `toml:"running"`
+	Inactive         string `toml:"inactive"`
+	SHA              string `toml:"sha"`
+	Head             string `toml:"head"`
+	Branch           string `toml:"branch"`
+	Tag              string `toml:"tag"`
+	Match            string `toml:"match"`
+}
+
+func (d paletteDefinition) parse() (palette, error) {
+	base := d.Base
+	if base == "" {
+		base = "default"
+	}
+	p, exists := builtinPalette(base)
+	if !exists {
+		return p, fmt.Errorf("invalid base theme: %q", d.Base)
+	}
+
+	colors := []struct {
+		name   string
+		target *tcell.Color
+	}{
+		{d.HeaderForeground, &p.HeaderForeground},
+		{d.HeaderBackground, &p.HeaderBackground},
+		{d.CursorForeground, &p.CursorForeground},
+		{d.CursorBackground, &p.CursorBackground},
+		{d.Passed, &p.Passed},
+		{d.Failed, &p.Failed},
+		{d.Running, &p.Running},
+		{d.Inactive, &p.Inactive},
+		{d.SHA, &p.SHA},
+		{d.Head, &p.Head},
+		{d.Branch, &p.Branch},
+		{d.Tag, &p.Tag},
+		{d.Match, &p.Match},
+	}
+	for _, color := range colors {
+		switch color.name {
+		case "":
+			// Keep the color of the base theme
+		case "default":
+			*color.target = tcell.ColorDefault
+		default:
+			c, err := tui.ParseColor(color.name)
+			if err != nil {
+				return p, err
+			}
+			*color.target = c
+		}
+	}
+
+	return p, nil
+}
+
+// Guess whether the background of the terminal is light from the value of the environment
+// variable COLORFGBG ("foreground;background" or "foreground;default;background"), which is set
+// by some terminal emulators (e.g. rxvt, Konsole, iTerm2). The second return value is false if
+// the background cannot be determined.
+func lightBackground(colorfgbg string) (bool, bool) {
+	fields := strings.Split(colorfgbg, ";")
+	if len(fields) < 2 {
+		return false, false
+	}
+	bg, err := strconv.Atoi(fields[len(fields)-1])
+	if err != nil || bg < 0 || bg > 15 {
+		return false, false
+	}
+
+	// White (7) and bright colors other than bright black (8) are light backgrounds
+	return bg == 7 || bg > 8, true
 }
 
 const maxWidth = 999
 
+// Number of days after which log files are removed from the log directory
+const defaultLogMaxAge = 7
+
+const defaultCacheMaxAge = 30
+
+// Limits on the pipelines kept in memory (number of pipelines and hours since last use)
+const defaultMemoryMaxPipelines = 1000
+const defaultMemoryMaxAge = 24
+
 var defaultTableColumns = map[tui.ColumnID]tui.Column{
 	providers.ColumnRef: {
 		Header:    "REF",
@@ -185,6 +528,50 @@ var defaultTableColumns = map[tui.ColumnID]tui.Column{
 		MaxWidth:  maxWidth,
 		Alignment: tui.Left,
 	},
+	providers.ColumnCost: {
+		Position:  12,
+		Header:    "COST",
+		MaxWidth:  maxWidth,
+		Alignment: tui.Right,
+	},
+	providers.ColumnFlaky: {
+		Position:  13,
+		Header:    "FLAKY",
+		MaxWidth:  maxWidth,
+		Alignment: tui.Left,
+	},
+	providers.ColumnCoverage: {
+		Position:  14,
+		Header:    "COVERAGE",
+		MaxWidth:  maxWidth,
+		Alignment: tui.Right,
+	},
+	providers.ColumnRunner: {
+		Position:  15,
+		Header:    "RUNNER",
+		MaxWidth:  maxWidth,
+		Alignment: tui.Left,
+	},
+}
+
+// Columns shown on the detail line of each row
+var defaultDetailColumns = map[tui.ColumnID]tui.Column{
+	providers.ColumnCommit: {
+		Header:   "commit",
+		Position: 0,
+	},
+	providers.ColumnRef: {
+		Header:   "ref",
+		Position: 1,
+	},
+	providers.ColumnDuration: {
+		Header:   "duration",
+		Position: 2,
+	},
+	providers.ColumnVariables: {
+		Header:   "variables",
+		Position: 3,
+	},
 }
 
 func (c Configuration) ControllerConfig(allColumns map[tui.ColumnID]tui.Column) (ApplicationConfiguration, error) {
@@ -193,33 +580,300 @@ func (c Configuration) ControllerConfig(allColumns map[tui.ColumnID]tui.Column)
 		return ApplicationConfiguration{}, err
 	}
 
+	// Columns chosen while the application runs are limited to the same widths
+	if allColumns, err = c.limitWidths(allColumns); err != nil {
+		return ApplicationConfiguration{}, err
+	}
+
+	keys, err := newKeyMap(c.Keys)
+	if err != nil {
+		return ApplicationConfiguration{}, err
+	}
+
+	logPager, err := c.LogPager()
+	if err != nil {
+		return ApplicationConfiguration{}, err
+	}
+
 	return ApplicationConfiguration{
 		TableConfiguration: tableConfig,
 		controllerConfiguration: controllerConfiguration{
-			GitStyle:     tableConfig.NodeStyle.(providers.StepStyle).GitStyle,
-			AutoCollapse: c.AutoCollapse,
+			GitStyle:          tableConfig.NodeStyle.(providers.StepStyle).GitStyle,
+			StepStyle:         tableConfig.NodeStyle.(providers.StepStyle),
+			AutoCollapse:      c.AutoCollapse,
+			LogDir:            c.LogDirectory(),
+			LogPager:          logPager,
+			ArtifactsDir:      c.ArtifactsDirectory(),
+			RefreshOnPush:     c.Providers.Polling.RefreshOnPush,
+			MuteDuration:      c.MuteDuration(),
+			StatePath:         utils.XDGStateLocation(path.Join(ConfDir, StateFilename)),
+			Bots:              c.BotDetection(),
+			MergeProviders:    c.MergeProviders,
+			GroupPullRequests: c.GroupPullRequests,
+			ExportPath:        c.Export.Path,
+			ExportLogs:        c.Export.Logs,
+			Replay:            c.Replay != "",
+			Keys:              keys,
+			Columns:           allColumns,
+			Authors: authorFilter{
+				Usernames: c.Authors.Usernames,
+				OnlyMine:  c.Authors.OnlyMine,
+			},
 		},
 	}, nil
 }
 
-func (c Configuration) TableConfig(allColumns map[tui.ColumnID]tui.Column) (tui.TableConfiguration, error) {
-	var tconf tui.TableConfiguration
+var defaultBotRefPrefixes = []string{"dependabot/", "renovate/"}
+var defaultBotAuthors = []string{"dependabot[bot]", "renovate[bot]"}
 
-	switch c.Style.Theme {
-	case "", "default":
-		tconf = defaultTableConfiguration
-	case "monochrome":
-		tconf = monochromeTableConfiguration
+// Return the rules identifying pipelines of bots. Settings missing from the configuration
+// file take default values while empty lists disable detection.
+func (c Configuration) BotDetection() botDetection {
+	b := botDetection{
+		RefPrefixes: c.Bots.Refs,
+		Authors:     c.Bots.Authors,
+	}
+	if b.RefPrefixes == nil {
+		b.RefPrefixes = defaultBotRefPrefixes
+	}
+	if b.Authors == nil {
+		b.Authors = defaultBotAuthors
+	}
+
+	return b
+}
+
+// Return for how long pipelines stay hidden after being muted
+func (c Configuration) MuteDuration() time.Duration {
+	if c.Mute.Duration <= 0 {
+		return defaultMuteDuration
+	}
+	return time.Duration(c.Mute.Duration) * time.Hour
+}
+
+// Return the directory where log files are written. Unless set in the configuration file,
+// this is a subdirectory of the XDG cache directory rather than a temporary directory since
+// /tmp may be small or mounted noexec on some systems.
+func (c Configuration) LogDirectory() string {
+	if c.Logs.Directory != "" {
+		return c.Logs.Directory
+	}
+	return utils.XDGCacheLocation(path.Join(ConfDir, "logs"))
+}
+
+// Return true if logs are opened in $PAGER rather than in the built-in log viewer
+func (c Configuration) LogPager() (bool, error) {
+	switch c.Logs.Viewer {
+	case "", "builtin":
+		return false, nil
+	case "pager":
+		return true, nil
 	default:
-		return tconf, fmt.Errorf("invalid theme: %q (expected \"default\" or \"monochrome\")", c.Style.Theme)
+		return false, fmt.Errorf("invalid log viewer: %q (expected \"builtin\" or \"pager\")", c.Logs.Viewer)
+	}
+}
+
+// Return the directory where the artifacts of jobs are downloaded
+func (c Configuration) ArtifactsDirectory() string {
+	if c.Artifacts.Directory != "" {
+		return c.Artifacts.Directory
+	}
+	return utils.XDGCacheLocation(path.Join(ConfDir, "artifacts"))
+}
+
+// Return the directory where pipelines are saved if persistence of the cache is enabled
+func (c Configuration) CacheDirectory() string {
+	if c.Cache.Directory != "" {
+		return c.Cache.Directory
 	}
+	return utils.XDGCacheLocation(path.Join(ConfDir, "pipelines"))
+}
+
+// Name of the subdirectory of the cache directory where pipelines are saved when several
+// repositories are monitored at once
+const repositoriesCacheDir = "repositories"
+
+// Return the directory where the pipelines of 'repository' are saved when several repositories
+// are monitored at once. Each repository gets a directory of its own since commits are saved
+// by the name of their git reference, which repositories may share.
+func (c Configuration) RepositoryCacheDirectory(repository string) string {
+	if abs, err := filepath.Abs(repository); err == nil {
+		if _, err := os.Stat(abs); err == nil {
+			repository = abs
+		}
+	}
+	sum := sha256.Sum256([]byte(repository))
+	return filepath.Join(c.CacheDirectory(), repositoriesCacheDir, fmt.Sprintf("%x", sum[:8]))
+}
+
+// Return the directories where pipelines are saved: the cache directory and the directories
+// of the repositories monitored along with other ones
+func (c Configuration) CacheDirectories() ([]string, error) {
+	dirs, err := filepath.Glob(filepath.Join(c.CacheDirectory(), repositoriesCacheDir, "*"))
+	if err != nil {
+		return nil, err
+	}
+
+	return append([]string{c.CacheDirectory()}, dirs...), nil
+}
+
+// Return the maximal age of pipelines saved on disk. Zero means pipelines are never removed.
+func (c Configuration) CacheMaxAge() time.Duration {
+	days := c.Cache.MaxAge
+	switch {
+	case days == 0:
+		days = defaultCacheMaxAge
+	case days < 0:
+		days = 0
+	}
+	return time.Duration(days) * 24 * time.Hour
+}
+
+// Return the model used to estimate the cost of pipelines from the "costs" section
+func (c Configuration) CostModel() providers.CostModel {
+	model := providers.CostModel{
+		Currency: c.Costs.Currency,
+	}
+	for _, rate := range c.Costs.Rates {
+		model.Rates = append(model.Rates, providers.CostRate{
+			Provider:  rate.Provider,
+			Jobs:      rate.Jobs,
+			PerMinute: rate.PerMinute,
+		})
+	}
+
+	return model
+}
+
+// Return the limits on the pipelines kept in memory. Zero values mean no limit.
+func (c Configuration) EvictionPolicy() providers.EvictionPolicy {
+	n := c.Cache.Memory.MaxPipelines
+	switch {
+	case n == 0:
+		n = defaultMemoryMaxPipelines
+	case n < 0:
+		n = 0
+	}
+	hours := c.Cache.Memory.MaxAge
+	switch {
+	case hours == 0:
+		hours = defaultMemoryMaxAge
+	case hours < 0:
+		hours = 0
+	}
+
+	return providers.EvictionPolicy{
+		MaxPipelines: n,
+		MaxAge:       time.Duration(hours) * time.Hour,
+	}
+}
+
+// Return the maximal age of log files. Zero stands for the default age, a negative value means
+// log files are never removed.
+func (c Configuration) LogMaxAge() time.Duration {
+	days := c.Logs.MaxAge
+	if days == 0 {
+		days = defaultLogMaxAge
+	}
+	return time.Duration(days) * 24 * time.Hour
+}
+
+// Return the palette of the theme selected in the configuration file, falling back to a
+// theme with fewer colors if the terminal cannot show it. Setting the environment variable
+// NO_COLOR selects the monochrome theme (see https://no-color.org).
+func (c Configuration) themePalette() (palette, error) {
+	if os.Getenv("NO_COLOR") != "" {
+		return terminalPalette, nil
+	}
+
+	p, exists := builtinPalette(c.Style.Theme)
+	if definition, isUserDefined := c.Style.Palettes[c.Style.Theme]; isUserDefined && c.Style.Theme != "" {
+		var err error
+		if p, err = definition.parse(); err != nil {
+			return p, fmt.Errorf("theme %q: %w", c.Style.Theme, err)
+		}
+	} else if !exists {
+		return p, fmt.Errorf("invalid theme: %q (expected \"auto\", \"default\", \"light\", \"dark\", \"solarized\", \"solarized-light\", \"solarized-dark\", \"monochrome\" or the name of a palette of the [style.palettes] section)", c.Style.Theme)
+	}
+
+	switch {
+	case c.Colors == 0 || p.fits(c.Colors):
+		return p, nil
+	case defaultPalette.fits(c.Colors):
+		return defaultPalette, nil
+	default:
+		return terminalPalette, nil
+	}
+}
+
+// Return the columns of 'allColumns' whose headers are listed in 'names', in the order of
+// the list. Names are case insensitive.
+func selectColumns(names []string, allColumns tui.ColumnConfiguration) (tui.ColumnConfiguration, error) {
+	columns := make(tui.ColumnConfiguration)
+loop:
+	for position, name := range names {
+		for id, column := range allColumns {
+			if strings.ToLower(column.Header) == strings.ToLower(name) {
+				column.Position = position
+				columns[id] = column
+				continue loop
+			}
+		}
+		return nil, fmt.Errorf("invalid column name: %q", name)
+	}
+
+	return columns, nil
+}
+
+// Return a copy of 'allColumns' where the maximum width of each column listed in the
+// column-widths table of the configuration is the width set by the user
+func (c Configuration) limitWidths(allColumns tui.ColumnConfiguration) (tui.ColumnConfiguration, error) {
+	columns := make(tui.ColumnConfiguration, len(allColumns))
+	for id, column := range allColumns {
+		columns[id] = column
+	}
+
+	names := make([]string, 0, len(c.ColumnWidths))
+	for name := range c.ColumnWidths {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+loop:
+	for _, name := range names {
+		width := c.ColumnWidths[name]
+		if width <= 0 {
+			return nil, fmt.Errorf("invalid width for column %q: %d (expected a positive number)", name, width)
+		}
+		for id, column := range columns {
+			if strings.ToLower(column.Header) == strings.ToLower(name) {
+				column.MaxWidth = width
+				columns[id] = column
+				continue loop
+			}
+		}
+		return nil, fmt.Errorf("invalid column name in column-widths: %q", name)
+	}
+
+	return columns, nil
+}
+
+func (c Configuration) TableConfig(allColumns map[tui.ColumnID]tui.Column) (tui.TableConfiguration, error) {
+	p, err := c.themePalette()
+	if err != nil {
+		return tui.TableConfiguration{}, err
+	}
+	tconf := p.tableConfiguration()
 
 	tconf.Sep = c.Style.Table.Separator
 	if tconf.Sep == "" {
 		tconf.Sep = "  "
 	}
 
-	var err error
+	tconf.Ellipsis = "…"
+	if c.Style.Table.Ellipsis != nil {
+		tconf.Ellipsis = *c.Style.Table.Ellipsis
+	}
+
 	if c.Style.Table.Cursor != nil {
 		tconf.Cursor, err = c.Style.Table.Cursor.Parse()
 		if err != nil {
@@ -264,22 +918,31 @@ func (c Configuration) TableConfig(allColumns map[tui.ColumnID]tui.Column) (tui.
 		}
 	}
 
+	switch c.Style.Icons {
+	case "", "text":
+		// Show states as text
+	case "unicode":
+		stepStyle.StateIcons = providers.UnicodeStateIcons
+	case "nerd-font":
+		stepStyle.StateIcons = providers.NerdFontStateIcons
+		stepStyle.ProviderIcons = providers.NerdFontProviderIcons
+	default:
+		return tconf, fmt.Errorf("invalid icon set: %q (expected \"text\", \"unicode\" or \"nerd-font\")", c.Style.Icons)
+	}
+
+	stepStyle.Costs = c.CostModel()
+	stepStyle.ColorRows = c.Style.Table.ColorRows
+
 	tconf.NodeStyle = stepStyle
 
 	if len(c.Columns) == 0 {
 		c.Columns = []string{"ref", "pipeline", "type", "state", "started", "duration", "name", "url"}
 	}
-	tconf.Columns = make(map[tui.ColumnID]tui.Column)
-loop:
-	for position, name := range c.Columns {
-		for id, column := range allColumns {
-			if strings.ToLower(column.Header) == strings.ToLower(name) {
-				column.Position = position
-				tconf.Columns[id] = column
-				continue loop
-			}
-		}
-		return tconf, fmt.Errorf("invalid column name: %q", name)
+	if allColumns, err = c.limitWidths(allColumns); err != nil {
+		return tconf, err
+	}
+	if tconf.Columns, err = selectColumns(c.Columns, allColumns); err != nil {
+		return tconf, err
 	}
 
 	sort := c.Sort
@@ -310,6 +973,7 @@ loop:
 	}
 
 	tconf.DefaultDepth = c.Depth
+	tconf.Details = defaultDetailColumns
 
 	tconf.HeaderSuffixAscending = c.Style.Table.Ascending
 	if tconf.HeaderSuffixAscending == "" {
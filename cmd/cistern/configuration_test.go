@@ -1,7 +1,11 @@
 package main
 
 import (
+	"os"
 	"testing"
+
+	"github.com/gdamore/tcell"
+	"github.com/pelletier/go-toml"
 )
 
 func TestConfiguration_CisternToml(t *testing.T) {
@@ -15,3 +19,203 @@ func TestConfiguration_CisternToml(t *testing.T) {
 		t.Fatal(err)
 	}
 }
+
+func TestConfiguration_TableConfigIcons(t *testing.T) {
+	for _, icons := range []string{"", "text", "unicode", "nerd-font"} {
+		c := Configuration{Location: "UTC"}
+		c.Style.Icons = icons
+		if _, err := c.TableConfig(defaultTableColumns); err != nil {
+			t.Fatalf("icons %q: %v", icons, err)
+		}
+	}
+
+	c := Configuration{Location: "UTC"}
+	c.Style.Icons = "emoji"
+	if _, err := c.TableConfig(defaultTableColumns); err == nil {
+		t.Fatal("expected error but got nil")
+	}
+}
+
+func TestConfiguration_ColumnWidths(t *testing.T) {
+	var c Configuration
+	if err := toml.Unmarshal([]byte(`
+location = "UTC"
+column-widths = { Name = 30 }
+
+[style.table]
+ellipsis = "..."
+`), &c); err != nil {
+		t.Fatal(err)
+	}
+
+	tconf, err := c.TableConfig(defaultTableColumns)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tconf.Ellipsis != "..." {
+		t.Errorf("expected %q but got %q", "...", tconf.Ellipsis)
+	}
+	for id, column := range tconf.Columns {
+		expected := defaultTableColumns[id].MaxWidth
+		if column.Header == "NAME" {
+			expected = 30
+		}
+		if column.MaxWidth != expected {
+			t.Errorf("column %q: expected a width of %d but got %d", column.Header, expected, column.MaxWidth)
+		}
+	}
+
+	for _, widths := range []map[string]int{{"name": 0}, {"title": 30}} {
+		c := Configuration{Location: "UTC", ColumnWidths: widths}
+		if _, err := c.TableConfig(defaultTableColumns); err == nil {
+			t.Errorf("%v: expected an error", widths)
+		}
+	}
+}
+
+func TestConfiguration_LogPager(t *testing.T) {
+	for viewer, expected := range map[string]bool{"": false, "builtin": false, "pager": true} {
+		c := Configuration{}
+		c.Logs.Viewer = viewer
+		pager, err := c.LogPager()
+		if err != nil {
+			t.Fatalf("viewer %q: %v", viewer, err)
+		}
+		if pager != expected {
+			t.Fatalf("viewer %q: expected %v but got %v", viewer, expected, pager)
+		}
+	}
+
+	c := Configuration{}
+	c.Logs.Viewer = "less"
+	if _, err := c.LogPager(); err == nil {
+		t.Fatal("expected error but got nil")
+	}
+}
+
+func TestConfiguration_themePalette(t *testing.T) {
+	t.Run("built-in themes", func(t *testing.T) {
+		for _, theme := range []string{"", "auto", "default", "light", "dark", "solarized", "solarized-light", "solarized-dark", "monochrome"} {
+			c := Configuration{Location: "UTC"}
+			c.Style.Theme = theme
+			if _, err := c.TableConfig(defaultTableColumns); err != nil {
+				t.Fatalf("theme %q: %v", theme, err)
+			}
+		}
+
+		c := Configuration{Location: "UTC"}
+		c.Style.Theme = "neon"
+		if _, err := c.TableConfig(defaultTableColumns); err == nil {
+			t.Fatal("expected error but got nil")
+		}
+	})
+
+	t.Run("palettes of the configuration file", func(t *testing.T) {
+		tree, err := toml.Load(`
+			[style]
+			theme = "mine"
+			[style.palettes.mine]
+			base = "dark"
+			passed = "#00ff00"
+			failed = "red"
+			cursor-background = "default"
+		`)
+		if err != nil {
+			t.Fatal(err)
+		}
+		c := Configuration{}
+		if err := tree.Unmarshal(&c); err != nil {
+			t.Fatal(err)
+		}
+		p, err := c.themePalette()
+		if err != nil {
+			t.Fatal(err)
+		}
+		expected := darkPalette
+		expected.Passed = tcell.NewHexColor(0x00ff00)
+		expected.Failed = tcell.ColorRed
+		expected.CursorBackground = tcell.ColorDefault
+		if p != expected {
+			t.Fatalf("expected %+v but got %+v", expected, p)
+		}
+
+		for _, definition := range []paletteDefinition{{Base: "mine"}, {Tag: "ultraviolet"}} {
+			c.Style.Palettes["mine"] = definition
+			if _, err := c.themePalette(); err == nil {
+				t.Fatalf("%+v: expected error but got nil", definition)
+			}
+		}
+	})
+
+	t.Run("themes fall back to fewer colors", func(t *testing.T) {
+		testCases := []struct {
+			theme    string
+			colors   int
+			expected palette
+		}{
+			{"dark", 0, darkPalette},
+			{"dark", 1 << 24, darkPalette},
+			{"dark", 256, darkPalette},
+			{"dark", 16, defaultPalette},
+			{"default", 8, defaultPalette},
+			{"dark", 2, terminalPalette},
+			{"default", 1, terminalPalette},
+		}
+		for _, testCase := range testCases {
+			c := Configuration{Colors: testCase.colors}
+			c.Style.Theme = testCase.theme
+			p, err := c.themePalette()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if p != testCase.expected {
+				t.Errorf("theme %q with %d colors: expected %+v but got %+v", testCase.theme, testCase.colors, testCase.expected, p)
+			}
+		}
+	})
+}
+
+func TestConfiguration_NoColor(t *testing.T) {
+	value, exists := os.LookupEnv("NO_COLOR")
+	defer func() {
+		if exists {
+			os.Setenv("NO_COLOR", value)
+		} else {
+			os.Unsetenv("NO_COLOR")
+		}
+	}()
+	os.Setenv("NO_COLOR", "1")
+
+	c := Configuration{}
+	c.Style.Theme = "solarized"
+	p, err := c.themePalette()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p != terminalPalette {
+		t.Fatalf("expected %+v but got %+v", terminalPalette, p)
+	}
+}
+
+func TestLightBackground(t *testing.T) {
+	testCases := []struct {
+		colorfgbg string
+		light     bool
+		ok        bool
+	}{
+		{"", false, false},
+		{"15;0", false, true},
+		{"0;15", true, true},
+		{"0;default;15", true, true},
+		{"7;8", false, true},
+		{"0;7", true, true},
+		{"0;default", false, false},
+	}
+
+	for _, testCase := range testCases {
+		light, ok := lightBackground(testCase.colorfgbg)
+		if light != testCase.light || ok != testCase.ok {
+			t.Errorf("%q: expected (%v, %v) but got (%v, %v)", testCase.colorfgbg, testCase.light, testCase.ok, light, ok)
+		}
+	}
+}
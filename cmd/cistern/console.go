@@ -0,0 +1,74 @@
+package main
+
+import (
+	"errors"
+	"time"
+
+	"github.com/nbedos/cistern/providers"
+	"github.com/nbedos/cistern/tui"
+)
+
+// Maximum number of errors kept by the error console, older errors are discarded first
+const maxConsoleEntries = 500
+
+// Non-fatal error shown in the error console
+type consoleEntry struct {
+	at       time.Time
+	provider string
+	message  string
+}
+
+// The error console gathers errors that do not prevent cistern from running (provider
+// hiccups, failed log fetches...) so that they can be reviewed later
+type errorConsole struct {
+	entries []consoleEntry
+}
+
+// Record err. Errors of providers are attributed to the provider concerned.
+func (c *errorConsole) add(at time.Time, err error) consoleEntry {
+	entry := consoleEntry{
+		at:      at,
+		message: err.Error(),
+	}
+	if e, ok := err.(providers.ProviderError); ok {
+		entry.provider = e.ProviderID
+		entry.message = e.Message()
+	} else if errors.As(err, &e) {
+		// Keep the context added around the error of the provider
+		entry.provider = e.ProviderID
+	}
+
+	c.entries = append(c.entries, entry)
+	if len(c.entries) > maxConsoleEntries {
+		c.entries = c.entries[len(c.entries)-maxConsoleEntries:]
+	}
+
+	return entry
+}
+
+// Return the content of the error console, most recent errors first
+func (c errorConsole) lines(emphasis tui.StyleTransform) []tui.StyledString {
+	ss := []tui.StyledString{
+		tui.NewStyledString("ERRORS", emphasis),
+		{},
+	}
+	if len(c.entries) == 0 {
+		ss = append(ss, tui.NewStyledString("   No error"))
+	}
+
+	for i := len(c.entries) - 1; i >= 0; i-- {
+		entry := c.entries[i]
+		provider := entry.provider
+		if provider == "" {
+			provider = "-"
+		}
+		line := tui.NewStyledString("   ")
+		line.Append(entry.at.Format("2006-01-02 15:04:05"), emphasis)
+		line.Append("  ")
+		line.Append(provider, emphasis)
+		line.Append("  " + entry.message)
+		ss = append(ss, line)
+	}
+
+	return ss
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/nbedos/cistern/providers"
+)
+
+func TestErrorConsole_lines(t *testing.T) {
+	now := time.Date(2020, 1, 30, 12, 0, 0, 0, time.UTC)
+	console := errorConsole{}
+	console.add(now, errors.New("failed to watch local repository"))
+	console.add(now.Add(time.Minute), providers.ProviderError{
+		ProviderID: "gitlab-0",
+		Operation:  "fetch pipeline",
+		URL:        "https://gitlab.com/owner/repo/pipelines/1",
+		Err:        errors.New("502 Bad Gateway"),
+	})
+	console.add(now.Add(2*time.Minute), fmt.Errorf("no pipeline found (%w)", providers.ProviderError{
+		ProviderID: "travis-0",
+		Err:        errors.New("401 Unauthorized"),
+	}))
+
+	lines := make([]string, 0)
+	for _, line := range console.lines(nil) {
+		lines = append(lines, line.String())
+	}
+	expected := []string{
+		"ERRORS",
+		"",
+		"   2020-01-30 12:02:00  travis-0  no pipeline found (provider travis-0: 401 Unauthorized)",
+		"   2020-01-30 12:01:00  gitlab-0  fetch pipeline: 502 Bad Gateway (https://gitlab.com/owner/repo/pipelines/1)",
+		"   2020-01-30 12:00:00  -  failed to watch local repository",
+	}
+	if diff := cmp.Diff(expected, lines); len(diff) > 0 {
+		t.Fatal(diff)
+	}
+}
+
+func TestErrorConsole_add(t *testing.T) {
+	now := time.Date(2020, 1, 30, 12, 0, 0, 0, time.UTC)
+	console := errorConsole{}
+	for i := 0; i < maxConsoleEntries+10; i++ {
+		console.add(now.Add(time.Duration(i)*time.Second), errors.New("error"))
+	}
+
+	if len(console.entries) != maxConsoleEntries {
+		t.Fatalf("expected %d entries but got %d", maxConsoleEntries, len(console.entries))
+	}
+	// Oldest entries are discarded first
+	if first := now.Add(10 * time.Second); !console.entries[0].at.Equal(first) {
+		t.Fatalf("expected first entry at %v but got %v", first, console.entries[0].at)
+	}
+}
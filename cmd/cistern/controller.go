@@ -1,7 +1,6 @@
 package main
 
 import (
-	"bytes"
 	"context"
 	"errors"
 	"fmt"
@@ -9,8 +8,11 @@ import (
 	"log"
 	"os"
 	"os/exec"
-	"regexp"
+	"os/signal"
+	"path/filepath"
+	"sort"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/gdamore/tcell"
@@ -27,95 +29,213 @@ const (
 	focusSearch
 	focusRef
 	focusHelp
+	focusMute
+	focusRerun
+	focusColumns
+	focusErrors
+	focusTimeline
+	focusTests
+	focusStats
+	focusLog
+	focusLogSearch
 )
 
 type keyBinding struct {
+	// Name of the action in the [keys] section of the configuration file. The keys of
+	// bindings without a name cannot be changed.
+	name   string
 	keys   []string
 	action string
 }
 
 var tableKeyBindings = []keyBinding{
 	{
+		name:   "up",
 		keys:   []string{"Up", "k", "Ctrl-p"},
 		action: "Move cursor up by one line",
 	},
 	{
+		name:   "down",
 		keys:   []string{"Down", "j", "Ctrl-n"},
 		action: "Move cursor down by one line",
 	},
 	{
+		name:   "scroll-right",
 		keys:   []string{"Right", "l"},
 		action: "Scroll right",
 	},
 	{
+		name:   "scroll-left",
 		keys:   []string{"Left", "h"},
 		action: "Scroll left",
 	},
 	{
+		name:   "half-page-up",
 		keys:   []string{"Ctrl-u"},
-		action: "Move cursor up by half a page",
+		action: "Scroll up by half a page",
 	},
 	{
+		name:   "page-up",
 		keys:   []string{"Page Up", "Ctrl-B"},
-		action: "Move cursor up by one page",
+		action: "Scroll up by one page",
 	},
 	{
+		name:   "half-page-down",
 		keys:   []string{"Ctrl-d"},
-		action: "Move cursor down by half a page",
+		action: "Scroll down by half a page",
 	},
 	{
-		keys:   []string{"Page Down", "Ctrl-F"},
-		action: "Move cursor down by one page",
+		name:   "page-down",
+		keys:   []string{"Page Down", "Ctrl-F", "Space"},
+		action: "Scroll down by one page",
 	},
 	{
+		name:   "top",
 		keys:   []string{"Home"},
-		action: "Move cursor to the first line",
+		action: "Move cursor to the first line, or to line N if preceded by N",
 	},
 	{
-		keys:   []string{"End"},
-		action: "Move cursor to the last line",
+		name:   "first-line",
+		keys:   []string{"g"},
+		action: "Move cursor to the first line when typed twice, or to line N if preceded by N",
+	},
+	{
+		name:   "bottom",
+		keys:   []string{"End", "G"},
+		action: "Move cursor to the last line, or to line N if preceded by N",
+	},
+	{
+		name:   "parent",
+		keys:   []string{"p"},
+		action: "Move cursor to the parent of the current row",
+	},
+	{
+		name:   "next-sibling",
+		keys:   []string{"J"},
+		action: "Move cursor to the next row at the same depth",
+	},
+	{
+		name:   "previous-sibling",
+		keys:   []string{"K"},
+		action: "Move cursor to the previous row at the same depth",
+	},
+	{
+		name:   "next-pipeline",
+		keys:   []string{"}"},
+		action: "Move cursor to the next top-level pipeline",
 	},
 	{
+		name:   "previous-pipeline",
+		keys:   []string{"{"},
+		action: "Move cursor to the previous top-level pipeline",
+	},
+	{
+		name:   "sort-left",
 		keys:   []string{"<"},
 		action: "Move sort column left",
 	},
 	{
+		name:   "sort-right",
 		keys:   []string{">"},
 		action: "Move sort column right",
 	},
 	{
+		name:   "reverse-sort",
 		keys:   []string{"!"},
 		action: "Reverse sort order",
 	},
 	{
-		keys:   []string{"o", "+"},
+		name:   "open-fold",
+		keys:   []string{"o"},
 		action: "Open the fold at the cursor",
 	},
 	{
-		keys:   []string{"O"},
+		name:   "open-all-folds",
+		keys:   []string{"O", "+"},
 		action: "Open the fold at the cursor and all sub-folds",
 	},
 	{
-		keys:   []string{"c", "-"},
+		name:   "close-fold",
+		keys:   []string{"c"},
 		action: "Close the fold at the cursor",
 	},
 	{
+		name:   "toggle-fold",
 		keys:   []string{"Tab"},
 		action: "Toggle fold open/closed",
 	},
 	{
-		keys:   []string{"C"},
+		name:   "close-all-folds",
+		keys:   []string{"C", "-"},
 		action: "Close the fold at the cursor and all sub-folds",
 	},
 	{
+		name:   "open-every-fold",
+		keys:   []string{"E"},
+		action: "Open all the folds of the table",
+	},
+	{
+		name:   "close-every-fold",
+		keys:   []string{"Z"},
+		action: "Close all the folds of the table",
+	},
+	{
+		name:   "details",
+		keys:   []string{"d"},
+		action: "Show/hide details below each row",
+	},
+	{
+		name:   "columns",
+		keys:   []string{"|"},
+		action: "Choose the columns of the table and their order",
+	},
+	{
+		name:   "browser",
 		keys:   []string{"b"},
 		action: "Open associated web page in $BROWSER",
 	},
 	{
+		name:   "copy-url",
+		keys:   []string{"Y"},
+		action: "Copy the URL of the web page of the row to the clipboard",
+	},
+	{
+		name:   "logs",
 		keys:   []string{"v"},
 		action: "View the log of the job at the cursor",
 	},
 	{
+		name:   "split",
+		keys:   []string{"V"},
+		action: "Show/hide the log of the job at the cursor below the table",
+	},
+	{
+		name:   "definition",
+		keys:   []string{"D"},
+		action: "Open the definition of the job at the cursor in $EDITOR",
+	},
+	{
+		name:   "artifacts",
+		keys:   []string{"a"},
+		action: "Download the artifacts of the job at the cursor",
+	},
+	{
+		name:   "tests",
+		keys:   []string{"F"},
+		action: "Show the failed tests of the job at the cursor",
+	},
+	{
+		name:   "statistics",
+		keys:   []string{"S"},
+		action: "Show request and polling statistics of each provider",
+	},
+	{
+		name:   "export",
+		keys:   []string{"X"},
+		action: "Export the pipelines to a JSON file in the log directory",
+	},
+	{
+		name:   "search",
 		keys:   []string{"/"},
 		action: "Open search prompt",
 	},
@@ -124,30 +244,132 @@ var tableKeyBindings = []keyBinding{
 		action: "Close search prompt",
 	},
 	{
+		name:   "next-match",
 		keys:   []string{"Enter", "n"},
 		action: "Move to the next match",
 	},
 	{
+		name:   "previous-match",
 		keys:   []string{"N"},
 		action: "Move to the previous match",
 	},
 	{
+		name:   "filter",
+		keys:   []string{"&"},
+		action: "Show only the rows matching the search or show all rows",
+	},
+	{
+		name:   "follow",
 		keys:   []string{"f"},
 		action: "Follow the current git reference",
 	},
 	{
-		keys:   []string{"g"},
+		name:   "ref",
+		keys:   []string{"@"},
 		action: "Open git reference selection prompt",
 	},
 	{
+		name:   "refresh",
 		keys:   []string{"r", "F5"},
 		action: "Refresh pipeline data",
 	},
 	{
+		name:   "fewer-pipelines",
+		keys:   []string{"["},
+		action: "Show one pipeline less",
+	},
+	{
+		name:   "more-pipelines",
+		keys:   []string{"]"},
+		action: "Show one pipeline more",
+	},
+	{
+		name:   "next-tab",
+		keys:   []string{")"},
+		action: "Show the next repository",
+	},
+	{
+		name:   "previous-tab",
+		keys:   []string{"("},
+		action: "Show the previous repository",
+	},
+	{
+		name:   "mute",
+		keys:   []string{"m"},
+		action: "Hide pipelines of git references matching a pattern for a while",
+	},
+	{
+		name:   "unmute",
+		keys:   []string{"M"},
+		action: "Show pipelines hidden in this repository",
+	},
+	{
+		name:   "mark",
+		keys:   []string{"B"},
+		action: "Mark the row at the cursor with the letter typed next",
+	},
+	{
+		name:   "jump-to-mark",
+		keys:   []string{"'"},
+		action: "Move cursor to the row marked with the letter typed next, or back with '",
+	},
+	{
+		name:   "follow-newest",
+		keys:   []string{"L"},
+		action: "Move the cursor to the latest pipeline after each update, or stop",
+	},
+	{
+		name:   "only-failed",
+		keys:   []string{"x"},
+		action: "Show only failed and running pipelines / all pipelines",
+	},
+	{
+		name:   "tags",
+		keys:   []string{"T"},
+		action: "Show pipelines of all tags / of the commit",
+	},
+	{
+		name:   "only-mine",
+		keys:   []string{"u"},
+		action: "Show only my pipelines / all pipelines",
+	},
+	{
+		name:   "pull-requests",
+		keys:   []string{"P"},
+		action: "Group pipelines by pull request / do not group",
+	},
+	{
+		name:   "timeline",
+		keys:   []string{"t"},
+		action: "Show the timeline of the pipeline at the cursor",
+	},
+	{
+		name:   "configuration",
+		keys:   []string{"y"},
+		action: "View the configuration executed by the pipeline at the cursor",
+	},
+	{
+		name:   "rerun",
+		keys:   []string{"R"},
+		action: "Run the pipeline at the cursor again with edited variables",
+	},
+	{
+		name:   "errors",
+		keys:   []string{"e"},
+		action: "Show error console",
+	},
+	{
+		name:   "help",
 		keys:   []string{"?", "F1"},
 		action: "Show help screen",
 	},
 	{
+		name:   "suspend",
+		keys:   []string{"Ctrl-z"},
+		action: "Suspend cistern, resume it with the fg command of the shell",
+	},
+	{
+		name:   "quit",
 		keys:   []string{"q"},
 		action: "Quit",
 	},
@@ -155,34 +377,42 @@ var tableKeyBindings = []keyBinding{
 
 var shortTableKeyBindings = []keyBinding{
 	{
+		name:   "down",
 		keys:   []string{"j"},
 		action: "Down",
 	},
 	{
+		name:   "up",
 		keys:   []string{"k"},
 		action: "Up",
 	},
 	{
+		name:   "toggle-fold",
 		keys:   []string{"Tab"},
 		action: "Open/Close",
 	},
 	{
+		name:   "search",
 		keys:   []string{"/"},
 		action: "Search",
 	},
 	{
+		name:   "logs",
 		keys:   []string{"v"},
 		action: "Logs",
 	},
 	{
+		name:   "browser",
 		keys:   []string{"b"},
 		action: "Browser",
 	},
 	{
+		name:   "help",
 		keys:   []string{"?"},
 		action: "Help",
 	},
 	{
+		name:   "quit",
 		keys:   []string{"q"},
 		action: "Quit",
 	},
@@ -193,13 +423,37 @@ var searchKeyBindings = []keyBinding{
 		keys:   []string{"Enter"},
 		action: "Search",
 	},
+	{
+		keys:   []string{"Left", "Right"},
+		action: "Move the cursor by one character",
+	},
+	{
+		keys:   []string{"Home", "Ctrl-A"},
+		action: "Move the cursor to the beginning of the line",
+	},
+	{
+		keys:   []string{"End", "Ctrl-E"},
+		action: "Move the cursor to the end of the line",
+	},
 	{
 		keys:   []string{"Backspace"},
-		action: "Delete last character",
+		action: "Delete the character before the cursor",
+	},
+	{
+		keys:   []string{"Delete", "Ctrl-D"},
+		action: "Delete the character under the cursor",
+	},
+	{
+		keys:   []string{"Ctrl-W"},
+		action: "Delete the word before the cursor",
 	},
 	{
 		keys:   []string{"Ctrl-U"},
-		action: "Delete whole line",
+		action: "Delete from the beginning of the line to the cursor",
+	},
+	{
+		keys:   []string{"Ctrl-K"},
+		action: "Delete from the cursor to the end of the line",
 	},
 	{
 		keys:   []string{"Escape"},
@@ -227,13 +481,37 @@ var refKeyBindings = []keyBinding{
 		keys:   []string{"Enter"},
 		action: "Validate",
 	},
+	{
+		keys:   []string{"Left", "Right"},
+		action: "Move the cursor by one character",
+	},
+	{
+		keys:   []string{"Home", "Ctrl-A"},
+		action: "Move the cursor to the beginning of the line",
+	},
+	{
+		keys:   []string{"End", "Ctrl-E"},
+		action: "Move the cursor to the end of the line",
+	},
 	{
 		keys:   []string{"Backspace"},
-		action: "Delete last character",
+		action: "Delete the character before the cursor",
+	},
+	{
+		keys:   []string{"Delete", "Ctrl-D"},
+		action: "Delete the character under the cursor",
+	},
+	{
+		keys:   []string{"Ctrl-W"},
+		action: "Delete the word before the cursor",
 	},
 	{
 		keys:   []string{"Ctrl-U"},
-		action: "Delete whole line",
+		action: "Delete from the beginning of the line to the cursor",
+	},
+	{
+		keys:   []string{"Ctrl-K"},
+		action: "Delete from the cursor to the end of the line",
 	},
 	{
 		keys:   []string{"Tab", "Shift-Tab"},
@@ -280,24 +558,74 @@ var shortRefKeyBindings = []keyBinding{
 	},
 }
 
+var shortMuteKeyBindings = []keyBinding{
+	{
+		keys:   []string{"Enter"},
+		action: "Mute",
+	},
+	{
+		keys:   []string{"Backspace"},
+		action: "Erase",
+	},
+	{
+		keys:   []string{"Escape"},
+		action: "Abort",
+	},
+}
+
+var shortColumnsKeyBindings = []keyBinding{
+	{
+		keys:   []string{"Enter"},
+		action: "Show columns",
+	},
+	{
+		keys:   []string{"Backspace"},
+		action: "Erase",
+	},
+	{
+		keys:   []string{"Escape"},
+		action: "Abort",
+	},
+}
+
+var shortRerunKeyBindings = []keyBinding{
+	{
+		keys:   []string{"Enter"},
+		action: "Rerun",
+	},
+	{
+		keys:   []string{"Backspace"},
+		action: "Erase",
+	},
+	{
+		keys:   []string{"Escape"},
+		action: "Abort",
+	},
+}
+
 var shortHelpKeyBindings = []keyBinding{
 	{
+		name:   "down",
 		keys:   []string{"j"},
-		action: "Up",
+		action: "Down",
 	},
 	{
+		name:   "up",
 		keys:   []string{"k"},
-		action: "Down",
+		action: "Up",
 	},
 	{
+		name:   "page-up",
 		keys:   []string{"Ctrl-B"},
 		action: "Page up",
 	},
 	{
+		name:   "page-down",
 		keys:   []string{"Ctrl-F"},
 		action: "Page down",
 	},
 	{
+		name:   "quit",
 		keys:   []string{"q"},
 		action: "Quit",
 	},
@@ -305,39 +633,356 @@ var shortHelpKeyBindings = []keyBinding{
 
 var helpKeyBindings = []keyBinding{
 	{
+		name:   "down",
 		keys:   []string{"j", "Down", "Ctrl-N"},
 		action: "Scroll down by one line",
 	},
 	{
+		name:   "up",
 		keys:   []string{"k", "Up", "Ctrl-P"},
 		action: "Scroll up by one line",
 	},
 	{
+		name:   "page-up",
 		keys:   []string{"Page up", "Ctrl-B"},
 		action: "Scroll up by one page",
 	},
 	{
+		name:   "page-down",
 		keys:   []string{"Page down", "Ctrl-F"},
 		action: "Scroll down by one page",
 	},
 	{
+		name:   "half-page-up",
 		keys:   []string{"Ctrl-U"},
 		action: "Scroll up by half a page",
 	},
 	{
+		name:   "half-page-down",
 		keys:   []string{"Ctrl-D"},
 		action: "Scroll down by half a page",
 	},
 	{
-		keys:   []string{"q"},
+		name:   "quit",
+		keys:   []string{"q", "Escape"},
 		action: "Exit help screen",
 	},
 }
 
-func helpScreen(emphasis tui.StyleTransform) []tui.StyledString {
+var shortErrorsKeyBindings = []keyBinding{
+	{
+		name:   "down",
+		keys:   []string{"j"},
+		action: "Down",
+	},
+	{
+		name:   "up",
+		keys:   []string{"k"},
+		action: "Up",
+	},
+	{
+		name:   "page-up",
+		keys:   []string{"Ctrl-B"},
+		action: "Page up",
+	},
+	{
+		name:   "page-down",
+		keys:   []string{"Ctrl-F"},
+		action: "Page down",
+	},
+	{
+		name:   "quit",
+		keys:   []string{"q"},
+		action: "Quit",
+	},
+}
+
+var errorsKeyBindings = []keyBinding{
+	{
+		name:   "down",
+		keys:   []string{"j", "Down", "Ctrl-N"},
+		action: "Scroll down by one line",
+	},
+	{
+		name:   "up",
+		keys:   []string{"k", "Up", "Ctrl-P"},
+		action: "Scroll up by one line",
+	},
+	{
+		name:   "page-up",
+		keys:   []string{"Page up", "Ctrl-B"},
+		action: "Scroll up by one page",
+	},
+	{
+		name:   "page-down",
+		keys:   []string{"Page down", "Ctrl-F"},
+		action: "Scroll down by one page",
+	},
+	{
+		name:   "quit",
+		keys:   []string{"q", "Escape"},
+		action: "Exit error console",
+	},
+}
+
+var shortTimelineKeyBindings = []keyBinding{
+	{
+		name:   "down",
+		keys:   []string{"j"},
+		action: "Down",
+	},
+	{
+		name:   "up",
+		keys:   []string{"k"},
+		action: "Up",
+	},
+	{
+		name:   "page-up",
+		keys:   []string{"Ctrl-B"},
+		action: "Page up",
+	},
+	{
+		name:   "page-down",
+		keys:   []string{"Ctrl-F"},
+		action: "Page down",
+	},
+	{
+		name:   "quit",
+		keys:   []string{"q"},
+		action: "Quit",
+	},
+}
+
+var shortTestsKeyBindings = []keyBinding{
+	{
+		name:   "down",
+		keys:   []string{"j"},
+		action: "Down",
+	},
+	{
+		name:   "up",
+		keys:   []string{"k"},
+		action: "Up",
+	},
+	{
+		name:   "page-up",
+		keys:   []string{"Ctrl-B"},
+		action: "Page up",
+	},
+	{
+		name:   "page-down",
+		keys:   []string{"Ctrl-F"},
+		action: "Page down",
+	},
+	{
+		name:   "quit",
+		keys:   []string{"q"},
+		action: "Quit",
+	},
+}
+
+var testsKeyBindings = []keyBinding{
+	{
+		name:   "down",
+		keys:   []string{"j", "Down", "Ctrl-N"},
+		action: "Scroll down by one line",
+	},
+	{
+		name:   "up",
+		keys:   []string{"k", "Up", "Ctrl-P"},
+		action: "Scroll up by one line",
+	},
+	{
+		name:   "page-up",
+		keys:   []string{"Page up", "Ctrl-B"},
+		action: "Scroll up by one page",
+	},
+	{
+		name:   "page-down",
+		keys:   []string{"Page down", "Ctrl-F"},
+		action: "Scroll down by one page",
+	},
+	{
+		name:   "quit",
+		keys:   []string{"q", "Escape"},
+		action: "Exit test report",
+	},
+}
+
+var shortStatsKeyBindings = []keyBinding{
+	{
+		name:   "down",
+		keys:   []string{"j"},
+		action: "Down",
+	},
+	{
+		name:   "up",
+		keys:   []string{"k"},
+		action: "Up",
+	},
+	{
+		name:   "quit",
+		keys:   []string{"q"},
+		action: "Quit",
+	},
+}
+
+var statsKeyBindings = []keyBinding{
+	{
+		name:   "down",
+		keys:   []string{"j", "Down", "Ctrl-N"},
+		action: "Scroll down by one line",
+	},
+	{
+		name:   "up",
+		keys:   []string{"k", "Up", "Ctrl-P"},
+		action: "Scroll up by one line",
+	},
+	{
+		name:   "page-up",
+		keys:   []string{"Page up", "Ctrl-B"},
+		action: "Scroll up by one page",
+	},
+	{
+		name:   "page-down",
+		keys:   []string{"Page down", "Ctrl-F"},
+		action: "Scroll down by one page",
+	},
+	{
+		name:   "quit",
+		keys:   []string{"q", "Escape"},
+		action: "Exit statistics",
+	},
+}
+
+var shortLogKeyBindings = []keyBinding{
+	{
+		name:   "down",
+		keys:   []string{"j"},
+		action: "Down",
+	},
+	{
+		name:   "up",
+		keys:   []string{"k"},
+		action: "Up",
+	},
+	{
+		name:   "search",
+		keys:   []string{"/"},
+		action: "Search",
+	},
+	{
+		name:   "follow",
+		keys:   []string{"f"},
+		action: "Follow",
+	},
+	{
+		name:   "quit",
+		keys:   []string{"q"},
+		action: "Quit",
+	},
+}
+
+var logKeyBindings = []keyBinding{
+	{
+		name:   "down",
+		keys:   []string{"j", "Down", "Ctrl-N"},
+		action: "Scroll down by one line",
+	},
+	{
+		name:   "up",
+		keys:   []string{"k", "Up", "Ctrl-P"},
+		action: "Scroll up by one line",
+	},
+	{
+		name:   "half-page-up",
+		keys:   []string{"Ctrl-u"},
+		action: "Scroll up by half a page",
+	},
+	{
+		name:   "page-up",
+		keys:   []string{"Page up", "Ctrl-B"},
+		action: "Scroll up by one page",
+	},
+	{
+		name:   "half-page-down",
+		keys:   []string{"Ctrl-d"},
+		action: "Scroll down by half a page",
+	},
+	{
+		name:   "page-down",
+		keys:   []string{"Page down", "Ctrl-F"},
+		action: "Scroll down by one page",
+	},
+	{
+		name:   "top",
+		keys:   []string{"Home"},
+		action: "Scroll to the beginning of the log",
+	},
+	{
+		name:   "bottom",
+		keys:   []string{"End"},
+		action: "Scroll to the end of the log",
+	},
+	{
+		name:   "search",
+		keys:   []string{"/"},
+		action: "Open search prompt",
+	},
+	{
+		name:   "next-match",
+		keys:   []string{"Enter", "n"},
+		action: "Move to the next match",
+	},
+	{
+		name:   "previous-match",
+		keys:   []string{"N"},
+		action: "Move to the previous match",
+	},
+	{
+		name:   "follow",
+		keys:   []string{"f"},
+		action: "Follow the end of the log of a running job",
+	},
+	{
+		name:   "quit",
+		keys:   []string{"q", "Escape"},
+		action: "Exit log viewer",
+	},
+}
+
+var timelineKeyBindings = []keyBinding{
+	{
+		name:   "down",
+		keys:   []string{"j", "Down", "Ctrl-N"},
+		action: "Scroll down by one line",
+	},
+	{
+		name:   "up",
+		keys:   []string{"k", "Up", "Ctrl-P"},
+		action: "Scroll up by one line",
+	},
+	{
+		name:   "page-up",
+		keys:   []string{"Page up", "Ctrl-B"},
+		action: "Scroll up by one page",
+	},
+	{
+		name:   "page-down",
+		keys:   []string{"Page down", "Ctrl-F"},
+		action: "Scroll down by one page",
+	},
+	{
+		name:   "quit",
+		keys:   []string{"q", "Escape"},
+		action: "Exit timeline",
+	},
+}
+
+func helpScreen(emphasis tui.StyleTransform, keys keyMap) []tui.StyledString {
 	draw := func(bindings []keyBinding) []tui.StyledString {
 		lines := make([]tui.StyledString, 0)
-		for _, b := range bindings {
+		for _, b := range keys.bindings(bindings) {
 			keys := make([]tui.StyledString, 0)
 			for _, k := range b.keys {
 				keys = append(keys, tui.NewStyledString(k, emphasis))
@@ -377,6 +1022,31 @@ func helpScreen(emphasis tui.StyleTransform) []tui.StyledString {
 	ss = append(ss, draw(helpKeyBindings)...)
 	ss = append(ss, tui.StyledString{}, tui.StyledString{})
 
+	ss = append(ss, tui.NewStyledString("Error console", emphasis))
+	ss = append(ss, tui.StyledString{})
+	ss = append(ss, draw(errorsKeyBindings)...)
+	ss = append(ss, tui.StyledString{}, tui.StyledString{})
+
+	ss = append(ss, tui.NewStyledString("Timeline", emphasis))
+	ss = append(ss, tui.StyledString{})
+	ss = append(ss, draw(timelineKeyBindings)...)
+	ss = append(ss, tui.StyledString{}, tui.StyledString{})
+
+	ss = append(ss, tui.NewStyledString("Test report", emphasis))
+	ss = append(ss, tui.StyledString{})
+	ss = append(ss, draw(testsKeyBindings)...)
+	ss = append(ss, tui.StyledString{}, tui.StyledString{})
+
+	ss = append(ss, tui.NewStyledString("Statistics", emphasis))
+	ss = append(ss, tui.StyledString{})
+	ss = append(ss, draw(statsKeyBindings)...)
+	ss = append(ss, tui.StyledString{}, tui.StyledString{})
+
+	ss = append(ss, tui.NewStyledString("Log viewer", emphasis))
+	ss = append(ss, tui.StyledString{})
+	ss = append(ss, draw(logKeyBindings)...)
+	ss = append(ss, tui.StyledString{}, tui.StyledString{})
+
 	return ss
 }
 
@@ -389,12 +1059,30 @@ func (c *Controller) shortKeyBindings() tui.StyledString {
 		bindings = shortSearchKeyBindings
 	case focusRef:
 		bindings = shortRefKeyBindings
+	case focusMute:
+		bindings = shortMuteKeyBindings
+	case focusRerun:
+		bindings = shortRerunKeyBindings
+	case focusColumns:
+		bindings = shortColumnsKeyBindings
 	case focusHelp:
 		bindings = shortHelpKeyBindings
+	case focusErrors:
+		bindings = shortErrorsKeyBindings
+	case focusTimeline:
+		bindings = shortTimelineKeyBindings
+	case focusTests:
+		bindings = shortTestsKeyBindings
+	case focusStats:
+		bindings = shortStatsKeyBindings
+	case focusLog:
+		bindings = shortLogKeyBindings
+	case focusLogSearch:
+		bindings = shortSearchKeyBindings
 	}
 
 	s := tui.StyledString{}
-	for i, b := range bindings {
+	for i, b := range c.conf.Keys.shortBindings(bindings) {
 		if i > 0 {
 			s.Append("  ")
 		}
@@ -420,7 +1108,33 @@ type controllerConfiguration struct {
 		Job      bool `toml:"job"`
 		Stage    bool `toml:"stage"`
 		Pipeline bool `toml:"pipeline"`
+		Finished bool `toml:"finished"`
 	} `toml:"autocollapse"`
+	LogDir string
+	// True if logs are opened in $PAGER instead of the built-in log viewer
+	LogPager      bool
+	ArtifactsDir  string
+	RefreshOnPush bool
+	MuteDuration  time.Duration
+	StatePath     string
+	Bots          botDetection
+	Authors       authorFilter
+	// Gather the pipelines of the commit under a single row when several providers report
+	// pipelines for it
+	MergeProviders bool
+	// Gather the pipelines of each pull request under a row when the application starts
+	GroupPullRequests bool
+	// File the snapshot of the cache is written to when the application exits, if not empty
+	ExportPath string
+	// True if the snapshot includes the logs of jobs
+	ExportLogs bool
+	// True if the pipelines come from a snapshot, in which case providers are never polled
+	Replay bool
+	// Keys bound to the actions of the user interface
+	Keys keyMap
+	// Columns that can be shown by the table
+	Columns   tui.ColumnConfiguration
+	StepStyle providers.StepStyle
 	providers.GitStyle
 }
 
@@ -430,28 +1144,116 @@ type ApplicationConfiguration struct {
 }
 
 type Controller struct {
-	tui         *tui.TUI
-	cache       providers.Cache
+	tui   *tui.TUI
+	cache providers.Cache
+	// Source of the time shown to the user, shared with the cache
+	clock       utils.Clock
 	ref         providers.Ref
 	width       int
 	height      int
 	header      *tui.TextArea
 	table       *tui.HierarchicalTable
-	tableSearch string
+	tableSearch tui.Pattern
 	status      *tui.TextArea
+	statusBar   *tui.TextArea
 	refcmd      *tui.Command
 	completec   chan time.Time
 	searchcmd   *tui.Command
+	mutecmd     *tui.Command
+	reruncmd    *tui.Command
+	columnscmd  *tui.Command
 	keyhints    *tui.TextArea
 	focus       focus
 	help        *tui.TextArea
+	console     errorConsole
+	errorView   *tui.TextArea
+	timeline    *tui.TextArea
+	testReport  *tui.TextArea
+	stats       *tui.TextArea
+	logViewer
+	logPane
+	// Logs fetched in the background, one at a time
+	logc        chan logUpdate
+	fetchingLog bool
+	// Errors of background tasks that do not prevent the user from going on
+	backgroundErrc chan error
+	// Tokens of the logs of failed jobs written in the background, see linkFailedLog()
+	failedLogWrites chan struct{}
+	// Mouse buttons held down when the last mouse event was received
+	mouseButtons tcell.ButtonMask
+	// Pipeline shown by the timeline
+	timelineKey providers.PipelineKey
 	layout      map[tui.Widget]windowDimensions
 	conf        controllerConfiguration
+	repository  string
+	// Path of the local repository, empty if the repository was specified by a URL
+	repositoryPath string
+	state          State
+	// True if the status bar shows the breadcrumb of the row at the cursor rather than a message
+	breadcrumb bool
+	// True if only failed and running pipelines are shown
+	onlyRed bool
+	// True if only pipelines of the user are shown
+	onlyMine bool
+	// True if the cursor moves to the most recently updated pipeline after each refresh
+	followNewest bool
+	// True if a pipeline shown is running, in which case the durations shown change every
+	// second
+	running bool
+	tagView
+	// Pipeline run again by the rerun prompt and variables entered in the prompt, waiting for
+	// the confirmation of the user
+	rerunKey       providers.PipelineKey
+	rerunVariables []providers.Variable
+	pipelineGrouping
+	// Path of the row marked with each letter. The mark ' is the row where the cursor was
+	// before the last jump to a mark.
+	marks map[rune][]interface{}
+	// Action waiting for the next key: "mark" or "jump-to-mark" wait for the letter of a mark,
+	// "first-line" for its own key typed a second time and "rerun" for the confirmation of the
+	// user. Empty if there is none.
+	pendingAction string
+	// Number typed before the key of an action moving the cursor to repeat the action, zero
+	// if there is none
+	count int
+	// Events of the terminal processed by the controller
+	eventc <-chan tcell.Event
+	// Tabs of the repositories monitored when there are several of them, nil otherwise. The
+	// controller only draws on the screen while its tab, 'tab', is active.
+	tabs   *tabs
+	tab    int
+	tabBar *tui.TextArea
 }
 
 var ErrExit = errors.New("exit")
 
+// Maximum time spent waiting for provider goroutines to return when exiting
+const shutdownTimeout = 3 * time.Second
+
+// Time between two updates of the log followed by the log viewer
+const logRefreshInterval = 5 * time.Second
+
+// Maximum number of logs of failed jobs written at the same time in the background
+const maxFailedLogWrites = 4
+
+func bold(s tcell.Style) tcell.Style { return s.Bold(true) }
+
 func NewController(ui *tui.TUI, conf ApplicationConfiguration, c providers.Cache) (Controller, error) {
+	if conf.Keys.actions == nil {
+		keys, err := newKeyMap(nil)
+		if err != nil {
+			return Controller{}, err
+		}
+		conf.Keys = keys
+	}
+
+	// Durations of running steps are computed from the time of the cache
+	if style, ok := conf.TableConfiguration.NodeStyle.(providers.StepStyle); ok {
+		style.Now = c.Clock().Now
+		conf.TableConfiguration.NodeStyle = style
+		conf.StepStyle = style
+	}
+
 	// Arbitrary values, the correct size will be set when the first RESIZE event is received
 	width, height := ui.Size()
 	header, err := tui.NewTextArea(width, height)
@@ -463,12 +1265,21 @@ func NewController(ui *tui.TUI, conf ApplicationConfiguration, c providers.Cache
 	if err != nil {
 		return Controller{}, err
 	}
+	// Expand and collapse pipelines as they were when the application last exited
+	for key, state := range c.FoldState() {
+		table.RestoreFoldState(key, state)
+	}
 
 	status, err := tui.NewTextArea(width, height)
 	if err != nil {
 		return Controller{}, err
 	}
 
+	statusBar, err := tui.NewTextArea(width, height)
+	if err != nil {
+		return Controller{}, err
+	}
+
 	keyhints, err := tui.NewTextArea(width, height)
 	if err != nil {
 		return Controller{}, err
@@ -476,28 +1287,85 @@ func NewController(ui *tui.TUI, conf ApplicationConfiguration, c providers.Cache
 
 	search := tui.NewCommand(width, height, "Search: ")
 	command := tui.NewCommand(width, height, "Ref: ")
+	mute := tui.NewCommand(width, height, "Mute refs matching: ")
+	rerun := tui.NewCommand(width, height, "Rerun with variables: ")
+	columns := tui.NewCommand(width, height, "Columns: ")
 
 	help, err := tui.NewTextArea(width, height)
 	if err != nil {
 		return Controller{}, err
 	}
-	bold := func(s tcell.Style) tcell.Style { return s.Bold(true) }
-	help.WriteContent(helpScreen(bold)...)
+	help.WriteContent(helpScreen(bold, conf.Keys)...)
+
+	errorView, err := tui.NewTextArea(width, height)
+	if err != nil {
+		return Controller{}, err
+	}
+	errorView.WriteContent(errorConsole{}.lines(bold)...)
+
+	timeline, err := tui.NewTextArea(width, height)
+	if err != nil {
+		return Controller{}, err
+	}
+
+	testReport, err := tui.NewTextArea(width, height)
+	if err != nil {
+		return Controller{}, err
+	}
+
+	stats, err := tui.NewTextArea(width, height)
+	if err != nil {
+		return Controller{}, err
+	}
+
+	logViewer, err := newLogViewer(width, height, conf.Match)
+	if err != nil {
+		return Controller{}, err
+	}
+
+	logPane, err := newLogPane(width, height)
+	if err != nil {
+		return Controller{}, err
+	}
+
+	tabBar, err := tui.NewTextArea(width, height)
+	if err != nil {
+		return Controller{}, err
+	}
 
 	return Controller{
-		tui:       ui,
-		cache:     c,
-		width:     width,
-		height:    height,
-		header:    &header,
-		table:     &table,
-		status:    &status,
-		searchcmd: &search,
-		refcmd:    &command,
-		keyhints:  &keyhints,
-		help:      &help,
-		conf:      conf.controllerConfiguration,
-		layout:    make(map[tui.Widget]windowDimensions),
+		tui:              ui,
+		cache:            c,
+		clock:            c.Clock(),
+		width:            width,
+		height:           height,
+		header:           &header,
+		table:            &table,
+		status:           &status,
+		statusBar:        &statusBar,
+		searchcmd:        &search,
+		refcmd:           &command,
+		mutecmd:          &mute,
+		reruncmd:         &rerun,
+		columnscmd:       &columns,
+		keyhints:         &keyhints,
+		help:             &help,
+		errorView:        &errorView,
+		timeline:         &timeline,
+		testReport:       &testReport,
+		stats:            &stats,
+		logViewer:        logViewer,
+		logPane:          logPane,
+		logc:             make(chan logUpdate),
+		backgroundErrc:   make(chan error),
+		failedLogWrites:  make(chan struct{}, maxFailedLogWrites),
+		conf:             conf.controllerConfiguration,
+		layout:           make(map[tui.Widget]windowDimensions),
+		onlyMine:         conf.Authors.OnlyMine,
+		pipelineGrouping: newPipelineGrouping(conf.GroupPullRequests),
+		marks:            make(map[rune][]interface{}),
+		eventc:           ui.Eventc,
+		tabBar:           &tabBar,
 	}, nil
 }
 
@@ -507,19 +1375,39 @@ func (c *Controller) setRef(ref providers.Ref) {
 
 func (c *Controller) Run(ctx context.Context, repositoryPath string, ref string) error {
 	remotes, err := providers.Remotes(repositoryPath)
-	switch err {
-	case providers.ErrUnknownRepositoryURL:
+	switch {
+	case c.conf.Replay:
+		// The commits of the snapshot may not exist in the local repository so it is
+		// handled as if it were remote
 		remotes = map[string][]string{"": {repositoryPath}}
 		err = nil
-	case nil:
+	case err == providers.ErrUnknownRepositoryURL:
+		remotes = map[string][]string{"": {repositoryPath}}
+		err = nil
+	case err == nil:
 		c.completec = make(chan time.Time)
 	default:
 		return err
 	}
 
 	isLocalRepository := c.completec != nil
+	c.repository = repositoryName(repositoryPath, remotes)
+	if isLocalRepository {
+		c.repositoryPath = repositoryPath
+	}
+	if c.tabs != nil && c.repository != "" {
+		c.tabs.rename(c.tab, c.repository)
+	}
+	if c.state, err = LoadState(c.conf.StatePath); err != nil {
+		return err
+	}
 
 	c.writeStatus("")
+	if c.conf.Replay {
+		c.writeStatus(fmt.Sprintf("Replay of a snapshot taken on %s", c.clock.Now().In(c.conf.StepStyle.Location).Format("Jan 2 15:04")))
+	} else if ids := c.cache.AnonymousProviders(); len(ids) > 0 {
+		c.writeStatus(fmt.Sprintf("Read-only mode for %s: no API token, public repositories only and lower rate limits", strings.Join(ids, ", ")))
+	}
 	c.refresh()
 	c.draw()
 
@@ -554,14 +1442,63 @@ func (c *Controller) Run(ctx context.Context, repositoryPath string, ref string)
 
 	ctx, cancel := context.WithCancel(ctx)
 	defer cancel()
+
+	// Follow changes of references so that switching branches or committing in the local
+	// repository updates the commit being monitored, and pushing it triggers a refresh
+	refc := make(chan string)
+	watchErrc := make(chan error)
+	if isLocalRepository {
+		go func() {
+			if err := providers.WatchReferences(ctx, repositoryPath, refc); err != nil && err != context.Canceled {
+				select {
+				case watchErrc <- err:
+				case <-ctx.Done():
+				}
+			}
+		}()
+	}
+
+	signalc := make(chan os.Signal, 1)
+	signal.Notify(signalc, handledSignals()...)
+	defer signal.Stop(signalc)
+
 	pollCtx, pollCancel := context.WithCancel(ctx)
-	updates := make(chan providers.PipelineChanges)
+	events := c.cache.SubscribeBatches(ctx)
+	polling := sync.WaitGroup{}
+	restartPolling := func(ref providers.Ref) {
+		if c.conf.Replay {
+			// The pipelines of the snapshot never change so there is nothing to poll, but
+			// the table must show the pipelines of the new reference
+			c.refresh()
+			c.draw()
+			return
+		}
+		pollCancel()
+		pollCtx, pollCancel = context.WithCancel(ctx)
+		polling.Add(1)
+		go func(ctx context.Context, ref providers.Ref) {
+			defer polling.Done()
+			err := c.cache.MonitorPipelines(ctx, remotes, ref, true)
+			select {
+			case errc <- err:
+			case <-ctx.Done():
+			}
+		}(pollCtx, ref)
+	}
+
+	// The logs followed by the log viewer and the log pane are fetched again periodically
+	logTicker := time.NewTicker(logRefreshInterval)
+	defer logTicker.Stop()
+	// Redraw the screen periodically so that the elapsed times of the status bar and the
+	// durations of running steps stay current
+	statusTicker := time.NewTicker(statusBarRefreshInterval)
+	defer statusTicker.Stop()
 	for err == nil {
 		select {
-		case event := <-c.tui.Eventc:
+		case event := <-c.eventc:
 			var gitRef providers.Ref
-			var restartPolling bool
-			gitRef, restartPolling, err = c.process(ctx, event)
+			var restart bool
+			gitRef, restart, err = c.process(ctx, event)
 			if err != nil {
 				break
 			}
@@ -583,22 +1520,103 @@ func (c *Controller) Run(ctx context.Context, repositoryPath string, ref string)
 			}
 
 			refChanged := gitRef.Name != c.ref.Name || gitRef.Sha != c.ref.Sha
-			if restartPolling || refChanged {
+			if restart || refChanged {
 				if refChanged {
 					c.setRef(gitRef)
 				}
-				pollCancel()
-				pollCtx, pollCancel = context.WithCancel(ctx)
-				go func(ctx context.Context, ref providers.Ref) {
-					errc <- c.cache.MonitorPipelines(ctx, remotes, ref, updates)
-				}(pollCtx, gitRef)
+				restartPolling(gitRef)
 			}
 
-		case u := <-updates:
-			c.refresh()
-			c.autoCollapse(u)
+		case name := <-refc:
+			if branch := providers.RemoteBranch(name); branch != "" {
+				// A remote branch now pointing to the commit being monitored means the
+				// commit was just pushed so CI pipelines are probably starting
+				if !c.conf.RefreshOnPush {
+					break
+				}
+				commit, err := providers.ResolveCommit(repositoryPath, branch)
+				if err == nil && commit.Sha == c.ref.Sha {
+					c.writeStatus(fmt.Sprintf("Push to %s detected, refreshing pipelines...", branch))
+					c.draw()
+					restartPolling(c.ref)
+				}
+				break
+			}
+
+			if c.ref.Name != "HEAD" {
+				break
+			}
+			commit, err := providers.ResolveCommit(repositoryPath, c.ref.Name)
+			if err != nil {
+				// HEAD may be invalid for a short time while git is writing references
+				break
+			}
+			if commit.Sha != c.ref.Sha || commit.Head != c.ref.Head {
+				c.setRef(providers.Ref{Name: c.ref.Name, Commit: commit})
+				restartPolling(c.ref)
+				c.refresh()
+				c.draw()
+			}
+
+		case <-logTicker.C:
+			switch {
+			case c.fetchingLog:
+				// Wait for the log being fetched
+			case c.followingLog():
+				c.fetchLog(ctx, c.logStep)
+			case c.followingPane():
+				c.fetchLog(ctx, c.paneStep)
+			}
+
+		case <-statusTicker.C:
+			if c.running {
+				c.table.Refresh()
+			}
+			c.draw()
+
+		case u := <-c.logc:
+			c.fetchingLog = false
+			c.updateLog(u)
+			c.syncPane(ctx)
+			c.draw()
+
+		case e := <-watchErrc:
+			// Not fatal, the user can still refresh manually
+			c.reportError(fmt.Errorf("failed to watch local repository: %w", e))
 			c.draw()
 
+		case e := <-c.backgroundErrc:
+			c.reportError(e)
+			c.draw()
+
+		case batch := <-events:
+			// A single change of the cache publishes an event per step so the table is
+			// rebuilt once for all the events received together
+			changes := make([]providers.Event, 0, len(batch))
+			failed := false
+			for _, e := range batch {
+				switch e.Type {
+				case providers.LogAppended:
+					// Logs are not shown by the table
+				case providers.ProviderFailed:
+					c.reportError(e.Err)
+					failed = true
+				default:
+					c.linkFailedLog(ctx, e)
+					changes = append(changes, e)
+				}
+			}
+			if len(changes) > 0 {
+				c.refresh()
+				for _, e := range changes {
+					c.autoCollapse(e)
+				}
+				c.syncPane(ctx)
+			}
+			if failed || len(changes) > 0 {
+				c.draw()
+			}
+
 		case e := <-errc:
 			switch e {
 			case context.Canceled:
@@ -610,65 +1628,292 @@ func (c *Controller) Run(ctx context.Context, repositoryPath string, ref string)
 				err = e
 			}
 
+		case s := <-signalc:
+			switch {
+			case isTerminationSignal(s):
+				err = ErrExit
+			case c.tabs != nil && !c.tabs.isActive(c.tab):
+				// The terminal is handled by the controller of the active tab
+			case isSuspensionSignal(s):
+				err = c.suspend()
+			default:
+				// The terminal may have been modified while the process was stopped
+				c.tui.Sync()
+				c.draw()
+			}
+
 		case <-ctx.Done():
 			err = ctx.Err()
 		}
 	}
 
+	// Stop provider goroutines and give them some time to return so that no write to the
+	// cache store is interrupted, then save the cache for the next execution
+	cancel()
+	done := make(chan struct{})
+	go func() {
+		polling.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(shutdownTimeout):
+	}
+	for anchor, state := range c.table.FoldState() {
+		if key, ok := anchor.(providers.PipelineKey); ok {
+			c.cache.SaveFoldState(key, state)
+		}
+	}
+	if e := c.cache.Flush(); e != nil && (err == nil || err == ErrExit) {
+		err = fmt.Errorf("failed to save cache: %w", e)
+	}
+	if c.conf.ExportPath != "" {
+		// The context of the application is canceled by now but logs may still have to
+		// be fetched
+		e := writeSnapshot(context.Background(), c.cache, c.conf.ExportPath, c.conf.ExportLogs)
+		if e != nil && (err == nil || err == ErrExit) {
+			err = fmt.Errorf("failed to export snapshot: %w", e)
+		}
+	}
+
 	if err == ErrExit {
 		return nil
 	}
 	return err
 }
 
-func (c *Controller) autoCollapse(u providers.PipelineChanges) {
-	if !u.Valid {
+// Collapse the row of the step concerned by the event if it just passed or finished and
+// automatic collapsing is enabled for its type
+func (c *Controller) autoCollapse(e providers.Event) {
+	if e.Type != providers.StateChanged {
 		return
 	}
 
-	for stepType, changes := range u.Changes {
-		if (stepType == providers.StepPipeline && c.conf.AutoCollapse.Pipeline) ||
-			(stepType == providers.StepStage && c.conf.AutoCollapse.Stage) ||
-			(stepType == providers.StepJob && c.conf.AutoCollapse.Job) {
-			for _, path := range changes.Passed {
-				ipath := []interface{}{u.PipelineKey}
-				for i, v := range path {
-					if i >= 1 {
-						ipath = append(ipath, v)
-					}
-				}
-				c.table.Collapse(ipath...)
-			}
-		}
+	collapse := false
+	if e.Passed() {
+		collapse = (e.StepType == providers.StepPipeline && c.conf.AutoCollapse.Pipeline) ||
+			(e.StepType == providers.StepStage && c.conf.AutoCollapse.Stage) ||
+			(e.StepType == providers.StepJob && c.conf.AutoCollapse.Job)
+	}
+	// Failed pipelines are left expanded so that the failing jobs remain visible
+	if e.StepType == providers.StepPipeline && c.conf.AutoCollapse.Finished && e.Finished() {
+		collapse = true
+	}
+	if !collapse {
+		return
+	}
+
+	path := []interface{}{e.PipelineKey}
+	if group, exists := c.groupOf[e.PipelineKey]; exists {
+		path = []interface{}{group, e.PipelineKey}
+	}
+	for _, id := range e.StepIDs {
+		path = append(path, id)
 	}
+	c.table.Collapse(path...)
 }
 
 func (c *Controller) SetHeader(lines []tui.StyledString) {
 	c.header.WriteContent(lines...)
 }
 
+// Record a non-fatal error in the error console and mention it in the status bar
+func (c *Controller) reportError(err error) {
+	entry := c.console.add(c.clock.Now(), err)
+	c.errorView.WriteContent(c.console.lines(bold)...)
+	msg := entry.message
+	if entry.provider != "" {
+		msg = fmt.Sprintf("%s: %s", entry.provider, msg)
+	}
+	if k := c.conf.Keys.key("errors"); k != "" {
+		msg = fmt.Sprintf("%s (press %s to show all errors)", msg, k)
+	}
+	c.writeStatus("error: " + msg)
+}
+
 func (c *Controller) writeStatus(s string) {
 	msg := tui.NewStyledString(s)
 	msg.Fit(tui.Left, c.width)
 	c.status.WriteContent(msg)
+	c.breadcrumb = false
+}
+
+// Return the names of the pipeline and steps leading to the row at the cursor,
+// e.g. "gitlab #1234 › tests › go test"
+func (c *Controller) activeRowBreadcrumb() string {
+	key, ids, exists := c.activeStepPath()
+	if !exists {
+		return ""
+	}
+	pipeline, exists := c.cache.Pipeline(key)
+	if !exists {
+		return ""
+	}
+
+	names := []string{pipelineTitle(pipeline)}
+	for i := range ids {
+		step, exists := c.cache.Step(key, ids[:i+1])
+		if !exists {
+			break
+		}
+		names = append(names, step.Name)
+	}
+
+	return strings.Join(names, " › ")
+}
+
+// Show the breadcrumb of the row at the cursor in the status bar unless a message is shown
+func (c *Controller) writeBreadcrumb() {
+	if len(c.status.Content) > 0 && c.status.Content[0].Length() > 0 && !c.breadcrumb {
+		return
+	}
+	c.writeStatus(c.activeRowBreadcrumb())
+	c.breadcrumb = true
+}
+
+// Pipelines kept in the table when only failed and running pipelines are shown
+func isRed(pipeline providers.Pipeline) bool {
+	return pipeline.State == providers.Failed || pipeline.State.IsActive()
 }
 
 func (c *Controller) refresh() {
 	commit, _ := c.cache.Commit(c.ref.Name)
 	c.header.WriteContent(commit.StyledStrings(c.conf.GitStyle)...)
-	pipelines := make([]tui.TableNode, 0)
-	for _, pipeline := range c.cache.Pipelines(c.ref.Name) {
+	pipelines := make([]providers.Pipeline, 0)
+	candidates := c.cache.Pipelines(c.ref.Name)
+	if c.onlyTags {
+		candidates = c.cache.TagPipelines()
+	}
+	now := c.clock.Now()
+	for _, pipeline := range candidates {
+		if c.state.isMuted(c.repository, pipeline.Ref, now) {
+			continue
+		}
+		if c.onlyRed && !isRed(pipeline) {
+			continue
+		}
+		if c.onlyMine && !c.conf.Authors.matches(pipeline, commit) {
+			continue
+		}
 		pipelines = append(pipelines, pipeline)
 	}
-	c.table.Replace(pipelines)
+	nodes := c.conf.Bots.tableNodes(pipelines, commit.Author)
+	if c.groupPullRequests {
+		nodes = groupPullRequests(nodes)
+	}
+	// Pipelines of tags are not necessarily run for the commit of the header
+	if c.conf.MergeProviders && !c.onlyTags {
+		nodes, _ = mergeProviders(nodes, commit.Sha)
+	}
+	c.running = false
+	for _, pipeline := range pipelines {
+		c.running = c.running || pipeline.State.IsActive()
+	}
+	c.groupOf = pipelineGroups(nodes)
+	c.table.Replace(nodes)
 	c.resize(c.width, c.height)
+	if c.followNewest {
+		if pipeline, exists := newestPipeline(pipelines); exists {
+			c.table.ScrollToNode(pipeline.NodeID())
+		}
+	}
+}
+
+// Return the pipeline updated last, or created last if the provider does not report when
+// pipelines are updated
+func newestPipeline(pipelines []providers.Pipeline) (providers.Pipeline, bool) {
+	var newest providers.Pipeline
+	var newestTime utils.NullTime
+	for _, pipeline := range pipelines {
+		t := utils.MaxNullTime(pipeline.UpdatedAt, pipeline.CreatedAt)
+		if !newestTime.Valid || (t.Valid && t.Time.After(newestTime.Time)) {
+			newest, newestTime = pipeline, t
+		}
+	}
+
+	return newest, len(pipelines) > 0
+}
+
+// Increase or decrease by one the maximum number of pipelines shown
+func (c *Controller) changeMaxPipelines(increase bool) {
+	n := c.cache.GlobalMaxPipelines()
+	shown := len(c.cache.Pipelines(c.ref.Name))
+	switch {
+	case increase && shown < n:
+		// The limit is not reached so there is nothing more to show
+		n = 0
+	case increase && n > 0:
+		n++
+	case !increase && n == 0:
+		n = utils.MaxInt(shown-1, 1)
+	case !increase:
+		n = utils.MaxInt(n-1, 1)
+	}
+	c.cache.SetGlobalMaxPipelines(n)
+	c.refresh()
+
+	if n == 0 {
+		c.writeStatus("Showing all pipelines")
+	} else {
+		c.writeStatus(fmt.Sprintf("Showing at most %d pipelines", n))
+	}
+}
+
+// Toggle between showing all pipelines and only failed and running pipelines
+func (c *Controller) toggleOnlyRed() {
+	c.onlyRed = !c.onlyRed
+	c.refresh()
+	if c.onlyRed {
+		c.writeStatus("Showing only failed and running pipelines")
+	} else {
+		c.writeStatus("Showing all pipelines")
+	}
+}
+
+// Toggle between moving the cursor to the most recently updated pipeline after each refresh
+// and leaving the cursor where the user put it
+func (c *Controller) toggleFollowNewest() {
+	c.followNewest = !c.followNewest
+	if c.followNewest {
+		c.refresh()
+		c.writeStatus("Following the most recently updated pipeline")
+	} else {
+		c.writeStatus("Not following the most recently updated pipeline")
+	}
+}
+
+// Toggle between showing all rows and only the rows matching the search and their ancestors
+func (c *Controller) toggleFilter() {
+	filter := !c.table.Filtered()
+	c.table.SetFilter(filter)
+	switch {
+	case !filter:
+		c.writeStatus("Showing all rows")
+	case c.tableSearch.Empty():
+		c.writeStatus("Showing only rows matching the next search")
+	default:
+		c.writeStatus(fmt.Sprintf("Showing only rows matching %#v", c.tableSearch.String()))
+	}
+}
+
+// Search the table for the pattern typed by the user, emphasize its occurrences and move the
+// cursor to the next match. Searching an empty pattern removes the emphasis.
+func (c *Controller) search(input string) {
+	p, err := tui.NewPattern(input)
+	if err != nil {
+		c.writeStatus(fmt.Sprintf("error: %s", err))
+		return
+	}
+	c.tableSearch = p
+	c.table.SetSearch(p)
+	c.nextMatch(true)
 }
 
 func (c *Controller) nextMatch(ascending bool) {
-	if c.tableSearch != "" {
+	if !c.tableSearch.Empty() {
 		found := c.table.ScrollToNextMatch(c.tableSearch, ascending)
 		if !found {
-			c.writeStatus(fmt.Sprintf("No match found for %#v", c.tableSearch))
+			c.writeStatus(fmt.Sprintf("No match found for %#v", c.tableSearch.String()))
 		}
 	}
 }
@@ -677,24 +1922,74 @@ func (c *Controller) resize(width int, height int) {
 	c.width = utils.MaxInt(width, 0)
 	c.height = utils.MaxInt(height, 0)
 
+	// The tab bar takes the first line of the screen and the other widgets are laid out below
+	top := 0
+	if c.tabs != nil {
+		top = utils.MinInt(1, c.height)
+	}
+	height = c.height - top
+
 	c.layout[c.help] = windowDimensions{
 		width:  c.width,
-		height: utils.MaxInt(0, c.height-1),
+		height: utils.MaxInt(0, height-1),
+	}
+
+	c.layout[c.errorView] = c.layout[c.help]
+	c.layout[c.timeline] = c.layout[c.help]
+	c.layout[c.testReport] = c.layout[c.help]
+	c.layout[c.stats] = c.layout[c.help]
+
+	c.layout[c.logView] = windowDimensions{
+		width:  c.width,
+		height: utils.MaxInt(0, height-2),
+	}
+	c.layout[c.logStatus] = windowDimensions{
+		y:      c.layout[c.logView].height,
+		width:  c.width,
+		height: 1,
 	}
+	c.layout[c.logcmd] = c.layout[c.logStatus]
 
 	c.layout[c.header] = windowDimensions{
 		width:  c.width,
-		height: utils.MinInt(utils.MinInt(len(c.header.Content)+2, 9), c.height),
+		height: utils.MinInt(utils.MinInt(len(c.header.Content)+2, 9), height),
 	}
 	y := c.layout[c.header].height
 
 	c.layout[c.table] = windowDimensions{
 		y:      y,
 		width:  c.width,
-		height: utils.MaxInt(0, c.height-c.layout[c.header].height-2),
+		height: utils.MaxInt(0, height-c.layout[c.header].height-3),
+	}
+	if c.split {
+		// The log pane takes the lower half of the space of the table, title included
+		paneHeight := c.layout[c.table].height / 2
+		c.layout[c.table] = windowDimensions{
+			y:      y,
+			width:  c.width,
+			height: c.layout[c.table].height - paneHeight,
+		}
+		c.layout[c.paneTitle] = windowDimensions{
+			y:      y + c.layout[c.table].height,
+			width:  c.width,
+			height: utils.MinInt(1, paneHeight),
+		}
+		c.layout[c.pane] = windowDimensions{
+			y:      c.layout[c.paneTitle].y + 1,
+			width:  c.width,
+			height: utils.MaxInt(0, paneHeight-1),
+		}
+		y += paneHeight
 	}
 	y += c.layout[c.table].height
 
+	c.layout[c.statusBar] = windowDimensions{
+		y:      y,
+		width:  c.width,
+		height: 1,
+	}
+	y += 1
+
 	c.layout[c.status] = windowDimensions{
 		y:      y,
 		width:  c.width,
@@ -707,6 +2002,24 @@ func (c *Controller) resize(width int, height int) {
 		height: 1,
 	}
 
+	c.layout[c.mutecmd] = windowDimensions{
+		y:      y,
+		width:  c.width,
+		height: 1,
+	}
+
+	c.layout[c.reruncmd] = windowDimensions{
+		y:      y,
+		width:  c.width,
+		height: 1,
+	}
+
+	c.layout[c.columnscmd] = windowDimensions{
+		y:      y,
+		width:  c.width,
+		height: 1,
+	}
+
 	c.layout[c.refcmd] = windowDimensions{
 		y:      y - utils.MinInt(14, y) + 1,
 		width:  c.width,
@@ -720,58 +2033,129 @@ func (c *Controller) resize(width int, height int) {
 		height: 1,
 	}
 
+	if c.tabs != nil {
+		for w, dim := range c.layout {
+			dim.y += top
+			c.layout[w] = dim
+		}
+		c.layout[c.tabBar] = windowDimensions{
+			width:  c.width,
+			height: top,
+		}
+	}
+
 	for w, dim := range c.layout {
 		w.Resize(dim.width, dim.height)
 	}
 
 	if len(c.status.Content) > 0 {
 		// Call writeStatus since the result depends on the c.width
+		breadcrumb := c.breadcrumb
 		c.writeStatus(c.status.Content[0].String())
+		c.breadcrumb = breadcrumb
 	}
 }
 
-// Turn `aaa\rbbb\rccc\r\n` into `ccc\r\n`
-// This is mostly for Travis logs that contain metadata hidden by carriage returns
-var deleteUntilCarriageReturn = regexp.MustCompile(`.*\r([^\r\n])`)
+// Write the log of the step identified by key and stepIDs to the log directory, make it the
+// latest log of its branch and return its content
+func (c *Controller) readLog(ctx context.Context, key providers.PipelineKey, stepIDs []string) (string, string, error) {
+	logPath, err := c.cache.WriteToDirectory(ctx, key, stepIDs, c.conf.LogDir)
+	if err != nil {
+		return "", "", err
+	}
 
-// https://stackoverflow.com/questions/14693701/how-can-i-remove-the-ansi-escape-sequences-from-a-string-in-python
-var deleteANSIEscapeSequence = regexp.MustCompile(`\x1b[@-_][0-?]*[ -/]*[@-~]`)
+	if pipeline, exists := c.cache.Pipeline(key); exists && c.repository != "" {
+		if _, err := providers.LinkLatestLog(c.conf.LogDir, c.repository, pipeline.Ref, logPath); err != nil {
+			// Not fatal, the log can still be shown
+			c.reportBackgroundError(ctx, fmt.Errorf("failed to link latest log: %w", err))
+		}
+	}
 
-func (c *Controller) viewLog(ctx context.Context) error {
-	c.writeStatus("Fetching logs...")
-	c.draw()
-	defer func() {
-		c.draw()
-	}()
-	key, ids, exists := c.activeStepPath()
+	content, err := ioutil.ReadFile(logPath)
+	if err != nil {
+		return "", "", err
+	}
+
+	return logPath, string(content), nil
+}
+
+// Write the log of the job or task whose failure is signaled by 'e' to the log directory in the
+// background and make it the latest failure log of its branch. At most maxFailedLogWrites logs
+// are fetched at the same time so that a pipeline with many failed jobs does not flood the
+// provider with requests.
+func (c *Controller) linkFailedLog(ctx context.Context, e providers.Event) {
+	if e.Type != providers.StateChanged || e.State != providers.Failed || e.AllowFailure || c.repository == "" {
+		return
+	}
+	if e.StepType != providers.StepJob && e.StepType != providers.StepTask {
+		return
+	}
+	pipeline, exists := c.cache.Pipeline(e.PipelineKey)
 	if !exists {
-		return providers.ErrNoLogHere
+		return
 	}
 
-	log, err := c.cache.Log(ctx, key, ids)
-	if err != nil {
-		if err == providers.ErrNoLogHere {
-			return nil
+	go func() {
+		select {
+		case c.failedLogWrites <- struct{}{}:
+			defer func() { <-c.failedLogWrites }()
+		case <-ctx.Done():
+			return
 		}
-		return err
-	}
+		logPath, err := c.cache.WriteToDirectory(ctx, e.PipelineKey, e.StepIDs, c.conf.LogDir)
+		if err == nil {
+			_, err = providers.LinkLatestFailedLog(c.conf.LogDir, c.repository, pipeline.Ref, logPath)
+		}
+		if err != nil && err != providers.ErrNoLogHere && err != context.Canceled {
+			c.reportBackgroundError(ctx, fmt.Errorf("failed to link latest failure log: %w", err))
+		}
+	}()
+}
+
+// Send 'err' to the console from a goroutine other than the one running the event loop, or
+// from the event loop itself without blocking it
+func (c *Controller) reportBackgroundError(ctx context.Context, err error) {
+	go func() {
+		select {
+		case c.backgroundErrc <- err:
+		case <-ctx.Done():
+		}
+	}()
+}
 
-	stdin := bytes.Buffer{}
-	log = deleteANSIEscapeSequence.ReplaceAllString(log, "")
-	log = deleteUntilCarriageReturn.ReplaceAllString(log, "$1")
-	stdin.WriteString(log)
+// Return a name identifying the repository, preferably based on the URL of the "origin" remote
+func repositoryName(repositoryPath string, remotes map[string][]string) string {
+	names := make([]string, 0, len(remotes))
+	for name := range remotes {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		if names[i] == "origin" || names[j] == "origin" {
+			return names[i] == "origin" && names[j] != "origin"
+		}
+		return names[i] < names[j]
+	})
 
-	// FIXME Do not make this choice here, move this to the configuration
-	pager := os.Getenv("PAGER")
-	if pager == "" {
-		pager = "less"
+	for _, name := range names {
+		for _, u := range remotes[name] {
+			if host, slug, err := utils.RepositoryHostAndSlug(u); err == nil {
+				return host + "/" + slug
+			}
+		}
 	}
 
-	return c.tui.Exec(ctx, pager, nil, &stdin)
+	return filepath.Base(repositoryPath)
 }
 
 func (c Controller) activeStepPath() (providers.PipelineKey, []string, bool) {
-	if stepPath := c.table.ActiveNodePath(); len(stepPath) > 0 {
+	stepPath := c.table.ActiveNodePath()
+	// Rows gathering several pipelines are not part of the path of a step
+	if len(stepPath) > 0 {
+		if _, ok := stepPath[0].(providers.PipelineGroupID); ok {
+			stepPath = stepPath[1:]
+		}
+	}
+	if len(stepPath) > 0 {
 		key, ok := stepPath[0].(providers.PipelineKey)
 		if !ok {
 			return providers.PipelineKey{}, nil, false
@@ -810,18 +2194,59 @@ func (c Controller) openActiveRowInBrowser() error {
 }
 
 func (c *Controller) draw() {
+	if c.tabs != nil {
+		// Hold the lock until the screen is shown so that switching tabs does not let the
+		// previous tab draw over the new one
+		c.tabs.mutex.Lock()
+		defer c.tabs.mutex.Unlock()
+		if c.tabs.active != c.tab {
+			return
+		}
+	}
 	c.tui.Clear()
 	widgets := make([]tui.Widget, 0)
-	if c.focus == focusHelp {
+	if c.tabs != nil {
+		c.writeTabBar()
+		widgets = append(widgets, c.tabBar)
+	}
+	switch c.focus {
+	case focusHelp:
 		widgets = append(widgets, c.help)
-	} else {
-		widgets = append(widgets, c.header, c.table)
+	case focusErrors:
+		widgets = append(widgets, c.errorView)
+	case focusTimeline:
+		c.writeTimeline()
+		widgets = append(widgets, c.timeline)
+	case focusTests:
+		widgets = append(widgets, c.testReport)
+	case focusStats:
+		c.writeStats()
+		widgets = append(widgets, c.stats)
+	case focusLog:
+		c.writeLogStatus()
+		widgets = append(widgets, c.logView, c.logStatus)
+	case focusLogSearch:
+		widgets = append(widgets, c.logView, c.logcmd)
+	default:
+		c.writeStatusBar()
+		widgets = append(widgets, c.header, c.table, c.statusBar)
+		if c.split {
+			c.writePaneTitle()
+			widgets = append(widgets, c.paneTitle, c.pane)
+		}
 		switch c.focus {
 		case focusRef:
 			widgets = append(widgets, c.refcmd)
 		case focusSearch:
 			widgets = append(widgets, c.searchcmd)
+		case focusMute:
+			widgets = append(widgets, c.mutecmd)
+		case focusRerun:
+			widgets = append(widgets, c.reruncmd)
+		case focusColumns:
+			widgets = append(widgets, c.columnscmd)
 		default:
+			c.writeBreadcrumb()
 			widgets = append(widgets, c.status)
 		}
 	}
@@ -848,13 +2273,39 @@ func (c *Controller) process(ctx context.Context, event tcell.Event) (providers.
 	case *tcell.EventResize:
 		sx, sy := ev.Size()
 		c.resize(sx, sy)
+	case *tcell.EventMouse:
+		c.processMouse(ev)
 	case *tcell.EventKey:
+		action := c.conf.Keys.action(ev)
+		// Characters typed in a prompt are never taken for a command
+		if action == "suspend" && !(ev.Key() == tcell.KeyRune && c.promptFocused()) {
+			if err := c.suspend(); err != nil {
+				return gitRef, restartPolling, err
+			}
+			break
+		}
 		switch c.focus {
 		case focusHelp:
-			if ev.Key() == tcell.KeyRune && ev.Rune() == 'q' {
-				c.focus = focusTable
+			c.processView(c.help, ev, action)
+		case focusErrors:
+			c.processView(c.errorView, ev, action)
+		case focusTimeline:
+			c.processView(c.timeline, ev, action)
+		case focusTests:
+			c.processView(c.testReport, ev, action)
+		case focusStats:
+			c.processView(c.stats, ev, action)
+		case focusLog:
+			c.processLogView(ev, action)
+		case focusLogSearch:
+			if ev.Key() == tcell.KeyEnter {
+				c.searchLog(c.logcmd.Input())
+				c.focus = focusLog
 			} else {
-				c.help.Process(ev)
+				c.logcmd.Process(ev)
+				if ev.Key() == tcell.KeyEsc {
+					c.focus = focusLog
+				}
 			}
 		case focusRef:
 			if ev.Key() == tcell.KeyEnter {
@@ -871,8 +2322,7 @@ func (c *Controller) process(ctx context.Context, event tcell.Event) (providers.
 
 		case focusSearch:
 			if ev.Key() == tcell.KeyEnter {
-				c.tableSearch = c.searchcmd.Input()
-				c.nextMatch(true)
+				c.search(c.searchcmd.Input())
 				c.focus = focusTable
 			} else {
 				c.searchcmd.Process(ev)
@@ -881,77 +2331,245 @@ func (c *Controller) process(ctx context.Context, event tcell.Event) (providers.
 				}
 			}
 
-		case focusTable:
-			switch ev.Key() {
-			case tcell.KeyRune:
-				switch keyRune := ev.Rune(); keyRune {
-				case 'b':
-					if err := c.openActiveRowInBrowser(); err != nil {
-						return gitRef, restartPolling, err
-					}
-				case 'g':
-					c.focus = focusRef
-					c.refcmd.Focus()
-					if c.completec != nil {
-						// Trigger update of completion suggestions
-						go func() {
-							select {
-							case <-ctx.Done():
-							case c.completec <- time.Now():
-							}
-						}()
-					}
-				case 'n', 'N':
-					c.nextMatch(ev.Rune() == 'n')
-				case 'q':
-					return gitRef, restartPolling, ErrExit
-				case '/':
-					c.focus = focusSearch
-					c.searchcmd.Focus()
-				case 'r':
-					restartPolling = true
-				case 'f':
-					restartPolling = true
-					gitRef = providers.Ref{Name: c.ref.Name}
-				case '?':
-					c.focus = focusHelp
-				case 'v':
-					if err := c.viewLog(ctx); err != nil {
+		case focusMute:
+			if ev.Key() == tcell.KeyEnter {
+				c.focus = focusTable
+				if pattern := c.mutecmd.Input(); pattern != "" {
+					if err := c.mute(pattern); err != nil {
 						return gitRef, restartPolling, err
 					}
-				default:
-					c.table.Process(ev)
 				}
-			case tcell.KeyEnter:
-				c.nextMatch(true)
-			case tcell.KeyF1:
-				c.focus = focusHelp
-			case tcell.KeyF5:
+			} else {
+				c.mutecmd.Process(ev)
+				if ev.Key() == tcell.KeyEsc {
+					c.focus = focusTable
+				}
+			}
+
+		case focusRerun:
+			if ev.Key() == tcell.KeyEnter {
+				c.focus = focusTable
+				c.confirmRerun(c.reruncmd.Input())
+			} else {
+				c.reruncmd.Process(ev)
+				if ev.Key() == tcell.KeyEsc {
+					c.focus = focusTable
+				}
+			}
+
+		case focusColumns:
+			if ev.Key() == tcell.KeyEnter {
+				c.focus = focusTable
+				c.setColumns(c.columnscmd.Input())
+			} else {
+				c.columnscmd.Process(ev)
+				if ev.Key() == tcell.KeyEsc {
+					c.focus = focusTable
+				}
+			}
+
+		case focusTable:
+			if c.pendingAction != "" {
+				done, restart := c.completePendingAction(ctx, action, ev)
+				restartPolling = restartPolling || restart
+				if done {
+					break
+				}
+			}
+			if c.addCountDigit(action, ev) {
+				break
+			}
+			count := c.count
+			c.count = 0
+			switch action {
+			case "top", "bottom":
+				c.moveToLine(action, count)
+			case "browser":
+				if err := c.openActiveRowInBrowser(); err != nil {
+					return gitRef, restartPolling, err
+				}
+			case "ref":
+				c.focus = focusRef
+				c.refcmd.Focus()
+				if c.completec != nil {
+					// Trigger update of completion suggestions
+					go func() {
+						select {
+						case <-ctx.Done():
+						case c.completec <- time.Now():
+						}
+					}()
+				}
+			case "next-match", "previous-match":
+				for i := 0; i < utils.MaxInt(count, 1); i++ {
+					c.nextMatch(action == "next-match")
+				}
+			case "quit":
+				return gitRef, restartPolling, ErrExit
+			case "search":
+				c.focus = focusSearch
+				c.searchcmd.Focus()
+			case "refresh":
 				restartPolling = true
+			case "fewer-pipelines", "more-pipelines":
+				c.changeMaxPipelines(action == "more-pipelines")
+			case "next-tab", "previous-tab":
+				c.switchTab(action == "next-tab")
+			case "mute":
+				c.openMutePrompt()
+			case "columns":
+				c.openColumnsPrompt()
+			case "unmute":
+				if err := c.unmute(); err != nil {
+					return gitRef, restartPolling, err
+				}
+			case "only-failed":
+				c.toggleOnlyRed()
+			case "filter":
+				c.toggleFilter()
+			case "follow-newest":
+				c.toggleFollowNewest()
+			case "tags":
+				c.toggleOnlyTags()
+			case "pull-requests":
+				c.toggleGroupPullRequests()
+			case "only-mine":
+				c.toggleOnlyMine()
+			case "follow":
+				restartPolling = true
+				gitRef = providers.Ref{Name: c.ref.Name}
+			case "help":
+				c.focus = focusHelp
+			case "errors":
+				c.focus = focusErrors
+			case "rerun":
+				c.openRerunPrompt()
+			case "configuration":
+				if err := c.viewConfiguration(ctx); err != nil {
+					return gitRef, restartPolling, err
+				}
+			case "timeline":
+				if key, _, exists := c.activeStepPath(); exists {
+					c.timelineKey = key
+					c.focus = focusTimeline
+				}
+			case "logs":
+				if err := c.viewLog(ctx); err != nil {
+					return gitRef, restartPolling, err
+				}
+			case "copy-url":
+				c.copyActiveRowURL()
+			case "first-line":
+				c.waitForFirstLine(count)
+			case "mark", "jump-to-mark":
+				c.waitForMark(action)
+			case "definition":
+				if err := c.openDefinition(ctx); err != nil {
+					return gitRef, restartPolling, err
+				}
+			case "artifacts":
+				c.downloadArtifacts(ctx)
+			case "tests":
+				c.viewTestReport(ctx)
+			case "statistics":
+				c.focus = focusStats
+			case "export":
+				c.exportSnapshot(ctx)
+			case "split":
+				c.toggleSplit(ctx)
 			default:
-				c.table.Process(ev)
+				c.repeatTableAction(action, count)
 			}
 		}
 	}
+	// Moving the cursor or refreshing the table changes the step of the log pane
+	c.syncPane(ctx)
 
 	c.draw()
 	return gitRef, restartPolling, nil
 }
 
-func RunApplication(ctx context.Context, newScreen func() (tcell.Screen, error), repo string, ref string, conf Configuration) error {
+// Pass the key typed to the action waiting for it, if any. Return true as first value if the
+// key was consumed by the action, and as second value if polling must restart.
+func (c *Controller) completePendingAction(ctx context.Context, action string, ev *tcell.EventKey) (bool, bool) {
+	pending := c.pendingAction
+	c.pendingAction = ""
+	switch pending {
+	case "rerun":
+		c.count = 0
+		return true, c.answerRerun(ctx, ev)
+	case "mark", "jump-to-mark":
+		c.count = 0
+		c.completeMark(pending, ev)
+		return true, false
+	case "first-line":
+		return c.completeFirstLine(action), false
+	}
+	return false, false
+}
+
+// Return true if one of the prompts has focus
+func (c *Controller) promptFocused() bool {
+	switch c.focus {
+	case focusSearch, focusRef, focusMute, focusRerun, focusColumns, focusLogSearch:
+		return true
+	}
+	return false
+}
+
+// Scroll the text shown by a view with the keys moving the cursor of the table and close the
+// view with the key of "quit" or Escape
+func (c *Controller) processView(view *tui.TextArea, ev *tcell.EventKey, action string) {
+	if action == "quit" || ev.Key() == tcell.KeyEsc {
+		c.focus = focusTable
+		return
+	}
+	view.Do(tableActions[action])
+}
+
+func RunApplication(ctx context.Context, newScreen func() (tcell.Screen, error), repos []string, ref string, conf Configuration) error {
 	// FIXME Discard log until the status bar is implemented in order to hide the "Unsolicited response received on
 	//  idle HTTP channel" from GitLab's HTTP client
 	log.SetOutput(ioutil.Discard)
 
+	if len(repos) > 1 && (conf.Replay != "" || conf.Export.Path != "") {
+		return errors.New("--replay and --export cannot be used with more than one repository")
+	}
+
+	conf.Colors = tui.TerminalColors()
 	controllerConf, err := conf.ControllerConfig(defaultTableColumns)
 	if err != nil {
 		return err
 	}
 
-	// Keep this before NewTUI since it may use stdin/stderr for password prompt
-	cacheDB, err := conf.Providers.ToCache(ctx)
-	if err != nil {
-		return err
+	if maxAge := conf.LogMaxAge(); maxAge >= 0 {
+		if err := providers.RemoveOldLogs(controllerConf.LogDir, maxAge); err != nil {
+			return err
+		}
+	}
+
+	// Each repository gets a cache of its own since pipelines are looked up by the name of
+	// their git reference
+	caches := make([]providers.Cache, 0, len(repos))
+	if conf.Replay != "" {
+		snapshot, err := readSnapshotFile(conf.Replay)
+		if err != nil {
+			return err
+		}
+		caches = append(caches, providers.NewCacheFromSnapshot(snapshot))
+		if _, exists := snapshot.Commits[ref]; !exists {
+			ref = snapshot.LatestRef()
+		}
+	} else {
+		// Keep this before NewTUI since it may use stdin/stderr for password prompt
+		cacheDB, err := conf.Providers.ToCache(ctx)
+		if err != nil {
+			return err
+		}
+		caches, err = repositoryCaches(conf, repos, cacheDB)
+		if err != nil {
+			return err
+		}
 	}
 
 	encoding.Register()
@@ -967,6 +2585,9 @@ func RunApplication(ctx context.Context, newScreen func() (tcell.Screen, error),
 	if err != nil {
 		return err
 	}
+	if conf.Mouse {
+		ui.EnableMouse()
+	}
 	defer func() {
 		// If another goroutine panicked this wouldn't run so we'd be left with a garbled screen.
 		// The alternative would be to defer a call to recover for every goroutine that we launch
@@ -974,10 +2595,14 @@ func RunApplication(ctx context.Context, newScreen func() (tcell.Screen, error),
 		ui.Finish()
 	}()
 
-	controller, err := NewController(&ui, controllerConf, cacheDB)
+	if len(repos) > 1 {
+		return runTabs(ctx, &ui, controllerConf, caches, repos, ref)
+	}
+
+	controller, err := NewController(&ui, controllerConf, caches[0])
 	if err != nil {
 		return err
 	}
 
-	return controller.Run(ctx, repo, ref)
+	return controller.Run(ctx, repos[0], ref)
 }
@@ -2,11 +2,17 @@ package main
 
 import (
 	"context"
+	"io/ioutil"
 	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/gdamore/tcell"
+	"github.com/google/go-cmp/cmp"
 	"github.com/nbedos/cistern/providers"
 	"github.com/nbedos/cistern/tui"
 	"github.com/nbedos/cistern/utils"
@@ -50,6 +56,74 @@ func TestController_resize(t *testing.T) {
 		controller.refresh()
 		controller.draw()
 	})
+
+	t.Run("log pane takes half of the space of the table", func(t *testing.T) {
+		controller, teardown, err := setup()
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer teardown()
+
+		controller.resize(80, 30)
+		height := controller.layout[controller.table].height
+		controller.toggleSplit(context.Background())
+		table, title, pane := controller.layout[controller.table], controller.layout[controller.paneTitle], controller.layout[controller.pane]
+		if table.height+title.height+pane.height != height || title.height != 1 || pane.height != height/2-1 {
+			t.Fatalf("unexpected heights: table %d, title %d, pane %d (expected %d in total)", table.height, title.height, pane.height, height)
+		}
+		if title.y != table.y+table.height || pane.y != title.y+1 {
+			t.Fatalf("unexpected positions: table %d, title %d, pane %d", table.y, title.y, pane.y)
+		}
+		controller.draw()
+
+		for _, size := range [][2]int{{0, 0}, {80, 4}} {
+			// Must not panic
+			controller.resize(size[0], size[1])
+			controller.draw()
+		}
+	})
+
+	t.Run("status bar is between the table and the status line", func(t *testing.T) {
+		controller, teardown, err := setup()
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer teardown()
+
+		controller.resize(80, 30)
+		table, bar, status := controller.layout[controller.table], controller.layout[controller.statusBar], controller.layout[controller.status]
+		if bar.y != table.y+table.height || bar.height != 1 || status.y != bar.y+1 {
+			t.Fatalf("unexpected positions: table %d (height %d), status bar %d, status %d", table.y, table.height, bar.y, status.y)
+		}
+	})
+
+	t.Run("tab bar takes the first line of the screen", func(t *testing.T) {
+		controller, teardown, err := setup()
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer teardown()
+
+		controller.resize(80, 30)
+		header, keyhints := controller.layout[controller.header], controller.layout[controller.keyhints]
+		controller.tabs = newTabs([]string{"a", "b"})
+		controller.resize(80, 30)
+		tabBar := controller.layout[controller.tabBar]
+		if tabBar.y != 0 || tabBar.height != 1 {
+			t.Fatalf("unexpected tab bar position: %d (height %d)", tabBar.y, tabBar.height)
+		}
+		if y := controller.layout[controller.header].y; y != header.y+1 {
+			t.Fatalf("expected header at %d but got %d", header.y+1, y)
+		}
+		if y := controller.layout[controller.keyhints].y; y != keyhints.y {
+			t.Fatalf("expected key hints at %d but got %d", keyhints.y, y)
+		}
+		controller.draw()
+
+		// Must not panic
+		controller.resize(0, 0)
+		controller.draw()
+	})
 }
 
 func TestRunApplication(t *testing.T) {
@@ -63,9 +137,428 @@ func TestRunApplication(t *testing.T) {
 		if err != nil {
 			t.Fatal(err)
 		}
-		err = RunApplication(ctx, newScreen, pwd, "HEAD", Configuration{})
+		err = RunApplication(ctx, newScreen, []string{pwd}, "HEAD", Configuration{})
 		if err != providers.ErrNoProvider {
 			t.Fatalf("expected %v but got %v", providers.ErrNoProvider, err)
 		}
 	})
+
+	t.Run("snapshots are replayed without providers", func(t *testing.T) {
+		dir, err := ioutil.TempDir("", "cistern")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer os.RemoveAll(dir)
+
+		cache := providers.NewCache(nil, nil, utils.PollingStrategy{})
+		cache.SaveCommit("master", providers.Commit{Sha: "sha"})
+		pipeline := providers.Pipeline{
+			Number:       "42",
+			ProviderHost: "gitlab.com",
+			ProviderName: "gitlab",
+			Ref:          "master",
+			Step:         providers.Step{ID: "42", Type: providers.StepPipeline, State: providers.Passed},
+		}
+		if _, err := cache.SavePipeline("sha", pipeline); err != nil {
+			t.Fatal(err)
+		}
+		conf := Configuration{Replay: filepath.Join(dir, "snapshot.json")}
+		conf.Logs.Directory = dir
+		if err := writeSnapshot(context.Background(), cache, conf.Replay, false); err != nil {
+			t.Fatal(err)
+		}
+
+		newScreen := func() (tcell.Screen, error) {
+			return quittingScreen{tcell.NewSimulationScreen("")}, nil
+		}
+		// Without a snapshot, running the application with no provider is an error.
+		// "HEAD" is not part of the snapshot so the commit of "master" is shown instead.
+		if err := RunApplication(context.Background(), newScreen, []string{dir}, "HEAD", conf); err != nil {
+			t.Fatal(err)
+		}
+	})
+}
+
+// Simulation screen that asks the application to quit as soon as it starts
+type quittingScreen struct {
+	tcell.SimulationScreen
+}
+
+func (s quittingScreen) Init() error {
+	if err := s.SimulationScreen.Init(); err != nil {
+		return err
+	}
+	s.InjectKey(tcell.KeyRune, 'q', tcell.ModNone)
+	return nil
+}
+
+func TestController_activeRowBreadcrumb(t *testing.T) {
+	controller, teardown, err := setup()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer teardown()
+
+	conf := tui.TableConfiguration{
+		NodeStyle: providers.StepStyle{
+			GitStyle: providers.GitStyle{
+				Location: time.UTC,
+			},
+		},
+		DefaultDepth: 2,
+	}
+	table, err := tui.NewHierarchicalTable(conf, nil, 80, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	controller.table = &table
+
+	controller.cache.SaveCommit("master", providers.Commit{Sha: "sha"})
+	pipeline := providers.Pipeline{
+		Number:       "42",
+		ProviderHost: "gitlab.com",
+		ProviderName: "gitlab",
+		Step: providers.Step{
+			ID:   "42",
+			Type: providers.StepPipeline,
+			Children: []providers.Step{
+				{
+					ID:   "1",
+					Name: "tests",
+					Type: providers.StepStage,
+					Children: []providers.Step{
+						{
+							ID:   "2",
+							Name: "go test",
+							Type: providers.StepJob,
+						},
+					},
+				},
+			},
+		},
+	}
+	if _, err := controller.cache.SavePipeline("sha", pipeline); err != nil {
+		t.Fatal(err)
+	}
+	controller.setRef(providers.Ref{Name: "master"})
+	controller.refresh()
+
+	expected := []string{
+		"gitlab #42",
+		"gitlab #42 › tests",
+		"gitlab #42 › tests › go test",
+	}
+	for _, e := range expected {
+		if breadcrumb := controller.activeRowBreadcrumb(); breadcrumb != e {
+			t.Fatalf("expected %q but got %q", e, breadcrumb)
+		}
+		controller.table.Process(tcell.NewEventKey(tcell.KeyDown, 0, tcell.ModNone))
+	}
+}
+
+func TestController_readLog(t *testing.T) {
+	controller, teardown, err := setup()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer teardown()
+
+	dir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	controller.conf.LogDir = dir
+	controller.repository = "github.com/nbedos/cistern"
+
+	pipeline := providers.Pipeline{
+		ProviderHost: "gitlab.com",
+		Ref:          "master",
+		Step: providers.Step{
+			ID:    "42",
+			Type:  providers.StepPipeline,
+			State: providers.Failed,
+			Children: []providers.Step{
+				{
+					ID:    "1",
+					Type:  providers.StepJob,
+					State: providers.Failed,
+					Log:   providers.Log{Content: utils.NullString{Valid: true, String: "FAIL\n"}},
+				},
+			},
+		},
+	}
+	if _, err := controller.cache.SavePipeline("sha", pipeline); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("log of a failed job is linked as the latest failure log", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		controller.linkFailedLog(ctx, providers.Event{
+			Type:        providers.StateChanged,
+			PipelineKey: pipeline.Key(),
+			StepIDs:     []string{"1"},
+			StepType:    providers.StepJob,
+			State:       providers.Failed,
+		})
+
+		linkPath := filepath.Join(dir, "latest", "github.com_nbedos_cistern", "master.failed.log")
+		for start := time.Now(); time.Since(start) < 5*time.Second; time.Sleep(10 * time.Millisecond) {
+			if bs, err := ioutil.ReadFile(linkPath); err == nil && string(bs) == "FAIL\n" {
+				return
+			}
+		}
+		t.Fatalf("expected %q to hold the log of the failed job", linkPath)
+	})
+
+	t.Run("failure to link the log is reported without hiding the log", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		// Prevent the creation of the directory holding the links
+		if err := os.RemoveAll(filepath.Join(dir, "latest")); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(filepath.Join(dir, "latest"), nil, 0600); err != nil {
+			t.Fatal(err)
+		}
+
+		_, log, err := controller.readLog(ctx, pipeline.Key(), []string{"1"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if log != "FAIL\n" {
+			t.Fatalf("expected %q but got %q", "FAIL\n", log)
+		}
+		select {
+		case <-controller.backgroundErrc:
+		case <-time.After(5 * time.Second):
+			t.Fatal("expected the error to be reported")
+		}
+	})
+}
+
+func TestController_toggleOnlyRed(t *testing.T) {
+	controller, teardown, err := setup()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer teardown()
+
+	conf := tui.TableConfiguration{
+		NodeStyle: providers.StepStyle{
+			GitStyle: providers.GitStyle{
+				Location: time.UTC,
+			},
+		},
+	}
+	table, err := tui.NewHierarchicalTable(conf, nil, 80, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	controller.table = &table
+
+	controller.cache.SaveCommit("master", providers.Commit{Sha: "sha"})
+	for i, state := range []providers.State{providers.Passed, providers.Failed, providers.Running, providers.Canceled} {
+		pipeline := providers.Pipeline{
+			Number:       strconv.Itoa(i),
+			ProviderHost: "gitlab.com",
+			ProviderName: "gitlab",
+			Step: providers.Step{
+				ID:    strconv.Itoa(i),
+				Type:  providers.StepPipeline,
+				State: state,
+			},
+		}
+		if _, err := controller.cache.SavePipeline("sha", pipeline); err != nil {
+			t.Fatal(err)
+		}
+	}
+	controller.setRef(providers.Ref{Name: "master"})
+	controller.refresh()
+
+	// Return the IDs of the pipelines shown in the table
+	shown := func() []string {
+		ids := make([]string, 0)
+		seen := make(map[interface{}]bool)
+		controller.table.Process(tcell.NewEventKey(tcell.KeyHome, 0, tcell.ModNone))
+		for {
+			path := controller.table.ActiveNodePath()
+			if len(path) == 0 || seen[path[0]] {
+				break
+			}
+			seen[path[0]] = true
+			ids = append(ids, path[0].(providers.PipelineKey).ID)
+			controller.table.Process(tcell.NewEventKey(tcell.KeyDown, 0, tcell.ModNone))
+		}
+		sort.Strings(ids)
+		return ids
+	}
+
+	if ids := shown(); len(ids) != 4 {
+		t.Fatalf("expected 4 pipelines but got %v", ids)
+	}
+
+	controller.toggleOnlyRed()
+	if diff := cmp.Diff([]string{"1", "2"}, shown()); len(diff) > 0 {
+		t.Fatal(diff)
+	}
+
+	controller.toggleOnlyRed()
+	if ids := shown(); len(ids) != 4 {
+		t.Fatalf("expected 4 pipelines but got %v", ids)
+	}
+}
+
+func TestController_toggleFollowNewest(t *testing.T) {
+	controller, teardown, err := setup()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer teardown()
+
+	conf := tui.TableConfiguration{
+		NodeStyle: providers.StepStyle{
+			GitStyle: providers.GitStyle{
+				Location: time.UTC,
+			},
+		},
+	}
+	table, err := tui.NewHierarchicalTable(conf, nil, 80, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	controller.table = &table
+
+	updatedAt := time.Date(2020, 1, 1, 12, 0, 0, 0, time.UTC)
+	save := func(id string, d time.Duration) {
+		pipeline := providers.Pipeline{
+			Number:       id,
+			ProviderHost: "gitlab.com",
+			ProviderName: "gitlab",
+			Step: providers.Step{
+				ID:        id,
+				Type:      providers.StepPipeline,
+				UpdatedAt: utils.NullTime{Valid: true, Time: updatedAt.Add(d)},
+			},
+		}
+		if _, err := controller.cache.SavePipeline("sha", pipeline); err != nil {
+			t.Fatal(err)
+		}
+		controller.refresh()
+	}
+	cursor := func() string {
+		return controller.table.ActiveNodePath()[0].(providers.PipelineKey).ID
+	}
+
+	controller.cache.SaveCommit("master", providers.Commit{Sha: "sha"})
+	controller.setRef(providers.Ref{Name: "master"})
+	for i := 0; i < 3; i++ {
+		save(strconv.Itoa(i), time.Duration(i)*time.Minute)
+	}
+	controller.toggleFollowNewest()
+	if id := cursor(); id != "2" {
+		t.Fatalf("expected cursor on pipeline %q but got %q", "2", id)
+	}
+	save("0", time.Hour)
+	if id := cursor(); id != "0" {
+		t.Fatalf("expected cursor on pipeline %q but got %q", "0", id)
+	}
+
+	controller.toggleFollowNewest()
+	controller.table.Process(tcell.NewEventKey(tcell.KeyHome, 0, tcell.ModNone))
+	top := cursor()
+	other := "1"
+	if top == other {
+		other = "2"
+	}
+	save(other, 2*time.Hour)
+	if id := cursor(); id != top {
+		t.Fatalf("expected cursor to stay on pipeline %q but got %q", top, id)
+	}
+}
+
+func TestController_search(t *testing.T) {
+	controller, teardown, err := setup()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer teardown()
+	controller.resize(80, 30)
+
+	controller.search("re:test-(unit")
+	if s := controller.status.Content[0].String(); !strings.HasPrefix(s, "error: invalid regular expression") {
+		t.Fatalf("expected an error but got %q", s)
+	}
+	if !controller.tableSearch.Empty() {
+		t.Fatalf("invalid patterns must not replace the pattern searched")
+	}
+
+	controller.search("re:test-(unit|integration)")
+	if s := controller.tableSearch.String(); s != "re:test-(unit|integration)" {
+		t.Fatalf("expected %q but got %q", "re:test-(unit|integration)", s)
+	}
+}
+
+// Run with -race: the table is refreshed and drawn while pipelines are being saved
+func TestController_refreshConcurrentUpdates(t *testing.T) {
+	controller, teardown, err := setup()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer teardown()
+
+	conf := tui.TableConfiguration{
+		NodeStyle: providers.StepStyle{
+			GitStyle: providers.GitStyle{
+				Location: time.UTC,
+			},
+		},
+	}
+	table, err := tui.NewHierarchicalTable(conf, nil, 80, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	controller.table = &table
+
+	controller.cache.SaveCommit("master", providers.Commit{Sha: "sha"})
+	controller.setRef(providers.Ref{Name: "master"})
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 200; i++ {
+			pipeline := providers.Pipeline{
+				Number:       strconv.Itoa(i % 5),
+				ProviderHost: "gitlab.com",
+				ProviderName: "gitlab",
+				Step: providers.Step{
+					ID:    strconv.Itoa(i % 5),
+					Type:  providers.StepPipeline,
+					State: providers.Running,
+					UpdatedAt: utils.NullTime{
+						Valid: true,
+						Time:  time.Unix(int64(i), 0),
+					},
+					Children: []providers.Step{
+						{ID: "job", Type: providers.StepJob, State: providers.Running},
+					},
+				},
+			}
+			if _, err := controller.cache.SavePipeline("sha", pipeline); err != nil {
+				t.Error(err)
+				return
+			}
+		}
+	}()
+
+	for {
+		select {
+		case <-done:
+			return
+		default:
+			controller.refresh()
+			controller.draw()
+		}
+	}
 }
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/gdamore/tcell"
+	"github.com/nbedos/cistern/utils"
+)
+
+// Maximum number of times an action can be repeated by typing a count before its key
+const maxCount = 9999
+
+// Table actions moving the cursor, repeated when a count is typed before their key
+var repeatableActions = map[string]bool{
+	"up":                true,
+	"down":              true,
+	"scroll-left":       true,
+	"scroll-right":      true,
+	"half-page-up":      true,
+	"half-page-down":    true,
+	"page-up":           true,
+	"page-down":         true,
+	"parent":            true,
+	"next-sibling":      true,
+	"previous-sibling":  true,
+	"next-pipeline":     true,
+	"previous-pipeline": true,
+}
+
+// Add the digit typed to the count repeating the next action. Like in vi, digits typed before
+// the key of an action moving the cursor repeat the action unless they are bound to actions of
+// their own, and 0 only counts after another digit. Return true if the key was a digit.
+func (c *Controller) addCountDigit(action string, ev *tcell.EventKey) bool {
+	r := ev.Rune()
+	if action != "" || ev.Key() != tcell.KeyRune || r < '0' || r > '9' || (r == '0' && c.count == 0) {
+		return false
+	}
+	c.count = utils.MinInt(c.count*10+int(r-'0'), maxCount)
+	c.writeStatus(fmt.Sprintf("Count: %d", c.count))
+	return true
+}
+
+// Move the cursor to line 'count' if it is set, otherwise to the first or last line depending
+// on 'action'
+func (c *Controller) moveToLine(action string, count int) {
+	if count > 0 {
+		c.table.ScrollToRow(count - 1)
+	} else {
+		c.table.Do(tableActions[action])
+	}
+}
+
+// Wait for the key of "first-line" to be typed a second time. The count applies to the
+// sequence as a whole.
+func (c *Controller) waitForFirstLine(count int) {
+	c.count = count
+	c.pendingAction = "first-line"
+}
+
+// Move the cursor to the first line, or to the line of the count, if 'action' completes the
+// sequence started by "first-line". Any other action cancels the sequence and is left to the
+// caller. Return true if the sequence was completed.
+func (c *Controller) completeFirstLine(action string) bool {
+	count := c.count
+	c.count = 0
+	if action != "first-line" {
+		return false
+	}
+	c.moveToLine("top", count)
+	return true
+}
+
+// Perform the table action 'count' times if it moves the cursor, once otherwise
+func (c *Controller) repeatTableAction(action string, count int) {
+	if !repeatableActions[action] {
+		count = 1
+	}
+	for i := 0; i < utils.MaxInt(count, 1); i++ {
+		c.table.Do(tableActions[action])
+	}
+}
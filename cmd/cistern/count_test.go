@@ -0,0 +1,90 @@
+package main
+
+import (
+	"context"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/gdamore/tcell"
+	"github.com/nbedos/cistern/providers"
+	"github.com/nbedos/cistern/tui"
+)
+
+func TestController_count(t *testing.T) {
+	controller, teardown, err := setup()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer teardown()
+
+	conf := tui.TableConfiguration{
+		NodeStyle: providers.StepStyle{
+			GitStyle: providers.GitStyle{
+				Location: time.UTC,
+			},
+		},
+	}
+	table, err := tui.NewHierarchicalTable(conf, nil, 80, 20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	controller.table = &table
+
+	controller.cache.SaveCommit("master", providers.Commit{Sha: "sha"})
+	controller.setRef(providers.Ref{Name: "master"})
+	for i := 0; i < 6; i++ {
+		pipeline := providers.Pipeline{
+			Number:       strconv.Itoa(i),
+			ProviderHost: "gitlab.com",
+			ProviderName: "gitlab",
+			Step: providers.Step{
+				ID:   strconv.Itoa(i),
+				Type: providers.StepPipeline,
+			},
+		}
+		if _, err := controller.cache.SavePipeline("sha", pipeline); err != nil {
+			t.Fatal(err)
+		}
+	}
+	controller.refresh()
+
+	cursor := func() string {
+		return controller.table.ActiveNodePath()[0].(providers.PipelineKey).ID
+	}
+	rows := make([]string, 0)
+	controller.table.Do(tui.ActionTop)
+	for i := 0; i < 6; i++ {
+		rows = append(rows, cursor())
+		controller.table.Do(tui.ActionCursorDown)
+	}
+
+	press := func(keys string) {
+		for _, r := range keys {
+			ev := tcell.NewEventKey(tcell.KeyRune, r, tcell.ModNone)
+			if _, _, err := controller.process(context.Background(), ev); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	for _, testCase := range []struct {
+		keys     string
+		expected int
+	}{
+		{keys: "G", expected: 5},
+		{keys: "2G", expected: 1},
+		{keys: "3j", expected: 4},
+		{keys: "10k", expected: 0},
+		{keys: "4j2k", expected: 2},
+		{keys: "0j", expected: 3},
+		{keys: "gg", expected: 0},
+		{keys: "3gg", expected: 2},
+		{keys: "4gj", expected: 3},
+		{keys: "G2gg", expected: 1},
+	} {
+		press(testCase.keys)
+		if id := cursor(); id != rows[testCase.expected] {
+			t.Fatalf("%q: expected cursor on row %d (pipeline %q) but got pipeline %q", testCase.keys, testCase.expected, rows[testCase.expected], id)
+		}
+	}
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/nbedos/cistern/providers"
+)
+
+// Open the file of the local repository defining the job at the cursor in $EDITOR
+func (c *Controller) openDefinition(ctx context.Context) error {
+	defer c.draw()
+	if c.repositoryPath == "" {
+		c.writeStatus("Job definitions are only available for local repositories")
+		return nil
+	}
+	key, ids, exists := c.activeStepPath()
+	if !exists || len(ids) == 0 {
+		c.writeStatus("No job at cursor")
+		return nil
+	}
+	step, exists := c.cache.Step(key, ids)
+	if !exists {
+		return nil
+	}
+
+	root, err := providers.RepositoryRoot(c.repositoryPath)
+	if err != nil {
+		c.reportError(err)
+		return nil
+	}
+	definition, err := providers.JobDefinition(root, step.Name)
+	if err != nil {
+		if err == providers.ErrNoDefinition {
+			c.writeStatus(fmt.Sprintf("No definition found for %q", step.Name))
+		} else {
+			c.reportError(err)
+		}
+		return nil
+	}
+
+	// $EDITOR may include arguments, e.g. "code --wait"
+	editor := strings.Fields(os.Getenv("EDITOR"))
+	if len(editor) == 0 {
+		editor = []string{"vi"}
+	}
+	args := append(editor[1:], fmt.Sprintf("+%d", definition.Line), definition.Path)
+
+	return c.tui.Exec(ctx, editor[0], args, os.Stdin)
+}
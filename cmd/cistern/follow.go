@@ -0,0 +1,162 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+	"time"
+
+	"github.com/nbedos/cistern/providers"
+)
+
+const followUsage = `usage: cistern follow [-r REPOSITORY | --repository REPOSITORY] [COMMIT]
+
+Print a line to the standard output each time the state of a pipeline
+associated to COMMIT changes, until interrupted. Errors of CI providers are
+written to the standard error. This is meant for logging to files or feeding
+notification scripts, without the terminal user interface.
+
+Example of output:
+  [12:03:11] gitlab pipeline #456 (master) running → failed: job 'test-linux'
+
+Positional arguments:
+  COMMIT        SHA, tag or branch of the commit to follow. Default: HEAD
+
+Options:
+  -r REPOSITORY, --repository REPOSITORY
+                Path or URL of the repository. Default: current directory`
+
+// Return the names of the jobs of the step that failed without being allowed to
+func failedJobs(step providers.Step) []string {
+	names := make([]string, 0)
+	if step.Type == providers.StepJob && step.State == providers.Failed && !step.AllowFailure {
+		names = append(names, step.Name)
+	}
+	for _, child := range step.Children {
+		names = append(names, failedJobs(child)...)
+	}
+	return names
+}
+
+// Return the line describing the change of the state of the pipeline from 'previous' to 'state'
+func formatTransition(at time.Time, pipeline providers.Pipeline, previous providers.State, state providers.State) string {
+	number := pipeline.Number
+	if number == "" {
+		number = pipeline.ID
+	}
+	transition := string(state)
+	if previous != providers.Unknown {
+		transition = fmt.Sprintf("%s → %s", previous, state)
+	}
+	s := fmt.Sprintf("[%s] %s pipeline #%s (%s) %s", at.Format("15:04:05"), pipeline.ProviderName, number, pipeline.Ref, transition)
+
+	if state == providers.Failed {
+		if names := failedJobs(pipeline.Step); len(names) > 0 {
+			noun := "job"
+			if len(names) > 1 {
+				noun = "jobs"
+			}
+			s += fmt.Sprintf(": %s '%s'", noun, strings.Join(names, "', '"))
+		}
+	}
+
+	return s
+}
+
+// Run the "follow" subcommand with the arguments 'args'. State changes of pipelines are
+// written to 'w' and errors of CI providers to 'errw'.
+func Follow(ctx context.Context, w io.Writer, errw io.Writer, args []string, conf Configuration) error {
+	f := flag.NewFlagSet("follow", flag.ContinueOnError)
+	f.SetOutput(bytes.NewBuffer(nil))
+	repoFlag := f.String("repository", ".", "")
+	repoFlagShort := f.String("r", ".", "")
+	helpFlag := f.Bool("help", false, "")
+	helpFlagShort := f.Bool("h", false, "")
+	if err := f.Parse(args); err != nil {
+		return fmt.Errorf("%s\n%s", err.Error(), followUsage)
+	}
+	if *helpFlag || *helpFlagShort {
+		_, err := fmt.Fprintln(w, followUsage)
+		return err
+	}
+	commit := "HEAD"
+	switch f.NArg() {
+	case 0:
+	case 1:
+		commit = f.Arg(0)
+	default:
+		return fmt.Errorf("at most one commit can be specified\n%s", followUsage)
+	}
+
+	repo := *repoFlag
+	if repo == "." {
+		repo = *repoFlagShort
+	}
+	remotes, ref, err := monitoredReference(repo, commit)
+	if err != nil {
+		return err
+	}
+
+	// New pipelines may be started at any time so polling never stops
+	conf.Providers.Polling.Forever = true
+	cache, err := conf.Providers.ToCache(ctx)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	signalc := make(chan os.Signal, 1)
+	signal.Notify(signalc, append([]os.Signal{os.Interrupt}, terminationSignals...)...)
+	defer signal.Stop(signalc)
+
+	events := cache.Subscribe(ctx)
+	errc := make(chan error, 1)
+	go func() {
+		errc <- cache.MonitorPipelines(ctx, remotes, ref, true)
+	}()
+
+	return printTransitions(ctx, w, errw, cache, events, errc, signalc)
+}
+
+// Write a line to 'w' for each change of the state of a pipeline received on 'events', and
+// errors of CI providers to 'errw', until an error is received on 'errc', a signal on
+// 'signalc' or ctx is done. Lines are timestamped with the clock of the cache.
+func printTransitions(ctx context.Context, w io.Writer, errw io.Writer, cache providers.Cache, events <-chan providers.Event, errc <-chan error, signalc <-chan os.Signal) error {
+	clock := cache.Clock()
+	for {
+		select {
+		case e := <-events:
+			if e.Type == providers.ProviderFailed {
+				if _, err := fmt.Fprintf(errw, "[%s] %v\n", clock.Now().Format("15:04:05"), e.Err); err != nil {
+					return err
+				}
+				break
+			}
+			if e.Type != providers.StateChanged || len(e.StepIDs) > 0 {
+				break
+			}
+			pipeline, exists := cache.Pipeline(e.PipelineKey)
+			if !exists {
+				break
+			}
+			if _, err := fmt.Fprintln(w, formatTransition(clock.Now(), pipeline, e.Previous, e.State)); err != nil {
+				return err
+			}
+
+		case err := <-errc:
+			return err
+
+		case <-signalc:
+			return nil
+
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
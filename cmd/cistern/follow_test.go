@@ -0,0 +1,121 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nbedos/cistern/providers"
+	"github.com/nbedos/cistern/utils"
+)
+
+func TestFormatTransition(t *testing.T) {
+	at := time.Date(2020, 1, 1, 12, 3, 11, 0, time.UTC)
+	pipeline := providers.Pipeline{
+		Number:       "456",
+		ProviderName: "gitlab",
+		Ref:          "master",
+		Step: providers.Step{
+			ID:    "1",
+			State: providers.Failed,
+			Children: []providers.Step{
+				{
+					ID:   "2",
+					Type: providers.StepStage,
+					Children: []providers.Step{
+						{ID: "3", Name: "test-linux", Type: providers.StepJob, State: providers.Failed},
+						{ID: "4", Name: "test-windows", Type: providers.StepJob, State: providers.Failed, AllowFailure: true},
+						{ID: "5", Name: "build", Type: providers.StepJob, State: providers.Passed},
+					},
+				},
+			},
+		},
+	}
+
+	testCases := []struct {
+		name     string
+		previous providers.State
+		state    providers.State
+		expected string
+	}{
+		{
+			name:     "new pipeline",
+			state:    providers.Running,
+			expected: "[12:03:11] gitlab pipeline #456 (master) running",
+		},
+		{
+			name:     "failed pipeline",
+			previous: providers.Running,
+			state:    providers.Failed,
+			expected: "[12:03:11] gitlab pipeline #456 (master) running → failed: job 'test-linux'",
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			if s := formatTransition(at, pipeline, testCase.previous, testCase.state); s != testCase.expected {
+				t.Fatalf("expected %q but got %q", testCase.expected, s)
+			}
+		})
+	}
+}
+
+func TestFollow(t *testing.T) {
+	t.Run("help", func(t *testing.T) {
+		w := &bytes.Buffer{}
+		if err := Follow(context.Background(), w, w, []string{"--help"}, Configuration{}); err != nil {
+			t.Fatal(err)
+		}
+		if !strings.HasPrefix(w.String(), "usage: cistern follow") {
+			t.Fatalf("unexpected output: %q", w.String())
+		}
+	})
+
+	t.Run("too many commits", func(t *testing.T) {
+		w := &bytes.Buffer{}
+		if err := Follow(context.Background(), w, w, []string{"a", "b"}, Configuration{}); err == nil {
+			t.Fatal("expected an error but got nil")
+		}
+	})
+}
+
+func TestPrintTransitions(t *testing.T) {
+	cache := providers.NewCache(nil, nil, utils.PollingStrategy{})
+	cache.SetClock(utils.NewVirtualClock(time.Date(2020, 1, 1, 12, 3, 11, 0, time.UTC)))
+	pipeline := providers.Pipeline{
+		ProviderHost: "gitlab.com",
+		ProviderName: "gitlab",
+		Ref:          "master",
+		Step: providers.Step{
+			ID:    "456",
+			Type:  providers.StepPipeline,
+			State: providers.Running,
+		},
+	}
+	if _, err := cache.SavePipeline("sha", pipeline); err != nil {
+		t.Fatal(err)
+	}
+
+	events := make(chan providers.Event)
+	signalc := make(chan os.Signal)
+	go func() {
+		events <- providers.Event{Type: providers.StateChanged, PipelineKey: pipeline.Key(), State: providers.Running}
+		events <- providers.Event{Type: providers.ProviderFailed, Err: providers.ProviderError{ProviderID: "gitlab", Err: errors.New("timeout")}}
+		signalc <- os.Interrupt
+	}()
+
+	w, errw := &bytes.Buffer{}, &bytes.Buffer{}
+	if err := printTransitions(context.Background(), w, errw, cache, events, nil, signalc); err != nil {
+		t.Fatal(err)
+	}
+	if expected := "[12:03:11] gitlab pipeline #456 (master) running\n"; w.String() != expected {
+		t.Fatalf("expected %q but got %q", expected, w.String())
+	}
+	if expected := "[12:03:11] provider gitlab: timeout\n"; errw.String() != expected {
+		t.Fatalf("expected %q but got %q", expected, errw.String())
+	}
+}
@@ -0,0 +1,230 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/gdamore/tcell"
+	"github.com/nbedos/cistern/tui"
+)
+
+// Key of the keyboard. The rune is only set for printable characters.
+type key struct {
+	code tcell.Key
+	r    rune
+}
+
+func keyOf(ev *tcell.EventKey) key {
+	if ev.Key() == tcell.KeyRune {
+		return key{code: tcell.KeyRune, r: ev.Rune()}
+	}
+	return key{code: ev.Key()}
+}
+
+// Names of keys accepted in the configuration file in addition to those of tcell.KeyNames
+var keyAliases = map[string]key{
+	"space":     {code: tcell.KeyRune, r: ' '},
+	"escape":    {code: tcell.KeyEsc},
+	"page up":   {code: tcell.KeyPgUp},
+	"page down": {code: tcell.KeyPgDn},
+	"shift-tab": {code: tcell.KeyBacktab},
+}
+
+// Parse the name of a key as written in the configuration file: either a single character
+// such as "j" or "?", or the name of a special key such as "Enter", "Page Up", "F5" or
+// "Ctrl-p". Names of special keys are case insensitive.
+func parseKey(name string) (key, error) {
+	if utf8.RuneCountInString(name) == 1 {
+		r, _ := utf8.DecodeRuneInString(name)
+		return key{code: tcell.KeyRune, r: r}, nil
+	}
+	if k, exists := keyAliases[strings.ToLower(name)]; exists {
+		return k, nil
+	}
+	for code, keyName := range tcell.KeyNames {
+		if strings.EqualFold(name, keyName) {
+			return key{code: code}, nil
+		}
+	}
+
+	return key{}, fmt.Errorf("invalid key name: %q", name)
+}
+
+// Table actions performed by the table widget itself, by name
+var tableActions = map[string]tui.Action{
+	"up":                tui.ActionCursorUp,
+	"down":              tui.ActionCursorDown,
+	"scroll-left":       tui.ActionScrollLeft,
+	"scroll-right":      tui.ActionScrollRight,
+	"half-page-up":      tui.ActionHalfPageUp,
+	"half-page-down":    tui.ActionHalfPageDown,
+	"page-up":           tui.ActionPageUp,
+	"page-down":         tui.ActionPageDown,
+	"top":               tui.ActionTop,
+	"bottom":            tui.ActionBottom,
+	"parent":            tui.ActionParent,
+	"next-sibling":      tui.ActionNextSibling,
+	"previous-sibling":  tui.ActionPreviousSibling,
+	"next-pipeline":     tui.ActionNextTopLevel,
+	"previous-pipeline": tui.ActionPreviousTopLevel,
+	"sort-left":         tui.ActionSortLeft,
+	"sort-right":        tui.ActionSortRight,
+	"reverse-sort":      tui.ActionReverseSort,
+	"open-fold":         tui.ActionOpenFold,
+	"open-all-folds":    tui.ActionOpenAllFolds,
+	"close-fold":        tui.ActionCloseFold,
+	"close-all-folds":   tui.ActionCloseAllFolds,
+	"toggle-fold":       tui.ActionToggleFold,
+	"open-every-fold":   tui.ActionOpenEveryFold,
+	"close-every-fold":  tui.ActionCloseEveryFold,
+	"details":           tui.ActionToggleDetails,
+}
+
+// Keys bound to the actions of the tabular view. Views showing text such as the help screen
+// use the bindings of the actions moving the cursor for scrolling and the binding of "quit"
+// to close the view.
+type keyMap struct {
+	// Names of the keys bound to each action, as written in the configuration file
+	keys map[string][]string
+	// Name of the action bound to each key
+	actions map[key]string
+}
+
+// Return the key bindings of the tabular view where the keys of the actions found in 'custom'
+// replace the default keys. Every action must be the name of a binding of tableKeyBindings,
+// every key must be valid and no key may be bound to more than one action. All conflicts are
+// listed in the error so that they can be fixed at once.
+func newKeyMap(custom map[string][]string) (keyMap, error) {
+	m := keyMap{
+		keys:    make(map[string][]string),
+		actions: make(map[key]string),
+	}
+	for _, b := range tableKeyBindings {
+		if b.name != "" {
+			m.keys[b.name] = b.keys
+		}
+	}
+
+	names := make([]string, 0, len(custom))
+	for name := range custom {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if _, exists := m.keys[name]; !exists {
+			return keyMap{}, fmt.Errorf("invalid action name in [keys] section: %q", name)
+		}
+		m.keys[name] = custom[name]
+	}
+
+	actionsByKey := make(map[key][]string)
+	namesByKey := make(map[key]string)
+	for _, b := range tableKeyBindings {
+		if b.name == "" {
+			continue
+		}
+		for _, keyName := range m.keys[b.name] {
+			k, err := parseKey(keyName)
+			if err != nil {
+				return keyMap{}, fmt.Errorf("action %q: %w", b.name, err)
+			}
+			if actions := actionsByKey[k]; len(actions) > 0 && actions[len(actions)-1] == b.name {
+				continue
+			}
+			if _, exists := namesByKey[k]; !exists {
+				namesByKey[k] = keyName
+			}
+			actionsByKey[k] = append(actionsByKey[k], b.name)
+			m.actions[k] = b.name
+		}
+	}
+
+	conflicts := make([]string, 0)
+	for k, actions := range actionsByKey {
+		if len(actions) > 1 {
+			conflicts = append(conflicts, fmt.Sprintf("%q is bound to %s", namesByKey[k], strings.Join(actions, ", ")))
+		}
+	}
+	if len(conflicts) > 0 {
+		sort.Strings(conflicts)
+		return keyMap{}, fmt.Errorf("conflicting key bindings: %s", strings.Join(conflicts, "; "))
+	}
+
+	return m, nil
+}
+
+// Return the name of the action bound to the key of ev, or an empty string if there is none
+func (m keyMap) action(ev *tcell.EventKey) string {
+	return m.actions[keyOf(ev)]
+}
+
+// Return the name of the first key bound to the action 'name', or an empty string if there is
+// none
+func (m keyMap) key(name string) string {
+	if keys := m.keys[name]; len(keys) > 0 {
+		return keys[0]
+	}
+	return ""
+}
+
+// Return a copy of 'bindings' showing the keys of this key map. Keys listed in a binding that
+// are not keys of its action by default, such as "Escape" for closing a view, are kept.
+func (m keyMap) bindings(bindings []keyBinding) []keyBinding {
+	result := make([]keyBinding, 0, len(bindings))
+	for _, b := range bindings {
+		if keys, exists := m.keys[b.name]; exists {
+			extra := make([]string, 0)
+			for _, k := range b.keys {
+				if !isDefaultKey(b.name, k) {
+					extra = append(extra, k)
+				}
+			}
+			b.keys = append(keys[:len(keys):len(keys)], extra...)
+		}
+		result = append(result, b)
+	}
+
+	return result
+}
+
+// Return a copy of the short key bindings 'bindings' showing the keys of this key map. Each
+// binding shows a single key: the one shown by default if it is still bound to the action,
+// the first key of the action otherwise. Bindings of actions without keys are left out.
+func (m keyMap) shortBindings(bindings []keyBinding) []keyBinding {
+	result := make([]keyBinding, 0, len(bindings))
+	for _, b := range bindings {
+		if keys, exists := m.keys[b.name]; exists && len(b.keys) > 0 {
+			if k, err := parseKey(b.keys[0]); err != nil || m.actions[k] != b.name {
+				if len(keys) == 0 {
+					continue
+				}
+				b.keys = keys[:1]
+			}
+		}
+		result = append(result, b)
+	}
+
+	return result
+}
+
+// Return true if the key named 'keyName' is bound to the action 'name' by default
+func isDefaultKey(name string, keyName string) bool {
+	k, err := parseKey(keyName)
+	if err != nil {
+		return false
+	}
+	for _, b := range tableKeyBindings {
+		if b.name != name {
+			continue
+		}
+		for _, defaultName := range b.keys {
+			if defaultKey, err := parseKey(defaultName); err == nil && defaultKey == k {
+				return true
+			}
+		}
+	}
+
+	return false
+}
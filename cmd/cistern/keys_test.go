@@ -0,0 +1,131 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/gdamore/tcell"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestParseKey(t *testing.T) {
+	for name, expected := range map[string]key{
+		"j":         {code: tcell.KeyRune, r: 'j'},
+		"?":         {code: tcell.KeyRune, r: '?'},
+		"Space":     {code: tcell.KeyRune, r: ' '},
+		"Enter":     {code: tcell.KeyEnter},
+		"escape":    {code: tcell.KeyEsc},
+		"Page Up":   {code: tcell.KeyPgUp},
+		"PgDn":      {code: tcell.KeyPgDn},
+		"Ctrl-p":    {code: tcell.KeyCtrlP},
+		"Ctrl-B":    {code: tcell.KeyCtrlB},
+		"F5":        {code: tcell.KeyF5},
+		"Shift-Tab": {code: tcell.KeyBacktab},
+	} {
+		k, err := parseKey(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if k != expected {
+			t.Errorf("expected %+v for %q but got %+v", expected, name, k)
+		}
+	}
+
+	if _, err := parseKey("Hyper-x"); err == nil {
+		t.Fatal("expected an error")
+	}
+}
+
+func TestNewKeyMap(t *testing.T) {
+	t.Run("default key bindings do not conflict", func(t *testing.T) {
+		m, err := newKeyMap(nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if action := m.action(tcell.NewEventKey(tcell.KeyCtrlN, 0, tcell.ModCtrl)); action != "down" {
+			t.Fatalf("expected %q but got %q", "down", action)
+		}
+		for name := range tableActions {
+			if len(m.keys[name]) == 0 {
+				t.Errorf("no key bound to %q", name)
+			}
+		}
+	})
+
+	t.Run("custom keys replace default keys", func(t *testing.T) {
+		m, err := newKeyMap(map[string][]string{"quit": {"Ctrl-q"}, "down": {"W"}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		for r, expected := range map[rune]string{'q': "", 'j': "", 'W': "down", 'k': "up"} {
+			if action := m.action(tcell.NewEventKey(tcell.KeyRune, r, tcell.ModNone)); action != expected {
+				t.Errorf("expected %q for %q but got %q", expected, r, action)
+			}
+		}
+		if action := m.action(tcell.NewEventKey(tcell.KeyCtrlQ, 0, tcell.ModCtrl)); action != "quit" {
+			t.Fatalf("expected %q but got %q", "quit", action)
+		}
+	})
+
+	t.Run("reference prompt can be bound to g again", func(t *testing.T) {
+		m, err := newKeyMap(map[string][]string{"ref": {"g"}, "first-line": {}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if action := m.action(tcell.NewEventKey(tcell.KeyRune, 'g', tcell.ModNone)); action != "ref" {
+			t.Fatalf("expected %q but got %q", "ref", action)
+		}
+	})
+
+	t.Run("all conflicts are reported", func(t *testing.T) {
+		_, err := newKeyMap(map[string][]string{"logs": {"j", "Up"}, "quit": {"?"}})
+		if err == nil {
+			t.Fatal("expected an error")
+		}
+		for _, conflict := range []string{`"j" is bound to down, logs`, `"Up" is bound to up, logs`, `"?" is bound to help, quit`} {
+			if !strings.Contains(err.Error(), conflict) {
+				t.Errorf("expected error to mention %q: %v", conflict, err)
+			}
+		}
+	})
+
+	t.Run("unknown actions and keys are rejected", func(t *testing.T) {
+		if _, err := newKeyMap(map[string][]string{"fly": {"f"}}); err == nil {
+			t.Fatal("expected an error")
+		}
+		if _, err := newKeyMap(map[string][]string{"quit": {"Hyper-q"}}); err == nil {
+			t.Fatal("expected an error")
+		}
+	})
+}
+
+func TestKeyMap_bindings(t *testing.T) {
+	m, err := newKeyMap(map[string][]string{"quit": {"Q"}, "help": {"F1"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	bindings := []keyBinding{
+		{name: "quit", keys: []string{"q", "Escape"}, action: "Exit"},
+		{keys: []string{"Enter"}, action: "Search"},
+	}
+	expected := []keyBinding{
+		{name: "quit", keys: []string{"Q", "Escape"}, action: "Exit"},
+		{keys: []string{"Enter"}, action: "Search"},
+	}
+	if diff := cmp.Diff(expected, m.bindings(bindings), cmp.AllowUnexported(keyBinding{})); diff != "" {
+		t.Fatal(diff)
+	}
+
+	short := []keyBinding{
+		{name: "help", keys: []string{"?"}, action: "Help"},
+		{name: "down", keys: []string{"j"}, action: "Down"},
+	}
+	expected = []keyBinding{
+		{name: "help", keys: []string{"F1"}, action: "Help"},
+		{name: "down", keys: []string{"j"}, action: "Down"},
+	}
+	if diff := cmp.Diff(expected, m.shortBindings(short), cmp.AllowUnexported(keyBinding{})); diff != "" {
+		t.Fatal(diff)
+	}
+}
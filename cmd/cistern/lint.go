@@ -0,0 +1,99 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"path/filepath"
+
+	"github.com/nbedos/cistern/providers"
+)
+
+const lintUsage = `usage: cistern lint [-r REPOSITORY | --repository REPOSITORY]
+
+Validate the CI configuration files of a local repository with the API of the
+CI providers of the configuration file (GitLab: .gitlab-ci.yml, CircleCI:
+.circleci/config.yml) so that mistakes are caught before pushing
+
+Options:
+  -r REPOSITORY, --repository REPOSITORY
+                Path of the local repository. Default: current directory`
+
+// Write the results of the validation of configuration files to w. The result is the number
+// of invalid files.
+func writeLintResults(w io.Writer, results []providers.LintResult) (int, error) {
+	invalid := 0
+	for _, result := range results {
+		if result.Valid() {
+			if _, err := fmt.Fprintf(w, "%s: valid (%s)\n", result.Path, result.ProviderID); err != nil {
+				return invalid, err
+			}
+			continue
+		}
+		invalid++
+		for _, message := range result.Errors {
+			if _, err := fmt.Fprintf(w, "%s: %s (%s)\n", result.Path, message, result.ProviderID); err != nil {
+				return invalid, err
+			}
+		}
+	}
+
+	return invalid, nil
+}
+
+// Run the "lint" subcommand with the arguments 'args' and write the result to 'w'
+func Lint(ctx context.Context, w io.Writer, args []string, conf Configuration) error {
+	f := flag.NewFlagSet("lint", flag.ContinueOnError)
+	f.SetOutput(bytes.NewBuffer(nil))
+	repoFlag := f.String("repository", ".", "")
+	repoFlagShort := f.String("r", ".", "")
+	helpFlag := f.Bool("help", false, "")
+	helpFlagShort := f.Bool("h", false, "")
+	if err := f.Parse(args); err != nil {
+		return fmt.Errorf("%s\n%s", err.Error(), lintUsage)
+	}
+	if *helpFlag || *helpFlagShort {
+		_, err := fmt.Fprintln(w, lintUsage)
+		return err
+	}
+	if f.NArg() > 0 {
+		return fmt.Errorf("unexpected argument: %q\n%s", f.Arg(0), lintUsage)
+	}
+
+	repo := *repoFlag
+	if repo == "." {
+		repo = *repoFlagShort
+	}
+	repo, err := filepath.Abs(repo)
+	if err != nil {
+		return err
+	}
+	root, err := providers.RepositoryRoot(repo)
+	if err != nil {
+		return err
+	}
+
+	cache, err := conf.Providers.ToCache(ctx)
+	if err != nil {
+		return err
+	}
+	results, err := cache.LintConfiguration(ctx, root)
+	if err != nil {
+		return err
+	}
+	if len(results) == 0 {
+		return fmt.Errorf("no configuration file found in %s that a configured provider can validate", root)
+	}
+
+	invalid, err := writeLintResults(w, results)
+	if err != nil {
+		return err
+	}
+	if invalid > 0 {
+		return fmt.Errorf("%d invalid configuration file(s)", invalid)
+	}
+
+	return nil
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/nbedos/cistern/providers"
+)
+
+func TestWriteLintResults(t *testing.T) {
+	results := []providers.LintResult{
+		{
+			Path:       ".circleci/config.yml",
+			ProviderID: "circleci",
+		},
+		{
+			Path:       ".gitlab-ci.yml",
+			ProviderID: "gitlab",
+			Errors:     []string{"jobs:test script config should be a string", "stages is not a list"},
+		},
+	}
+
+	buf := bytes.Buffer{}
+	invalid, err := writeLintResults(&buf, results)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if invalid != 1 {
+		t.Fatalf("expected 1 invalid file but got %d", invalid)
+	}
+	expected := `.circleci/config.yml: valid (circleci)
+.gitlab-ci.yml: jobs:test script config should be a string (gitlab)
+.gitlab-ci.yml: stages is not a list (gitlab)
+`
+	if buf.String() != expected {
+		t.Fatalf("expected %q but got %q", expected, buf.String())
+	}
+}
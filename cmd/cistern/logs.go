@@ -0,0 +1,88 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"fmt"
+	"io"
+
+	"github.com/nbedos/cistern/providers"
+)
+
+const addressHelp = `ADDRESS designates a pipeline or a job with the syntax
+[PROVIDER/][#]PIPELINE[.JOB] where PROVIDER is the name of the provider,
+PIPELINE the number or the ID of the pipeline and JOB the name or the ID of
+the job, for example "gitlab/12345" or "#456.test-linux".`
+
+const logsUsage = `usage: cistern logs [-r REPOSITORY | --repository REPOSITORY] [-c COMMIT | --commit COMMIT] ADDRESS
+
+Write the log of the job designated by ADDRESS among the pipelines of COMMIT
+to the standard output.
+
+` + addressHelp + `
+
+Options:
+  -r REPOSITORY, --repository REPOSITORY
+                Path or URL of the repository. Default: current directory
+
+  -c COMMIT, --commit COMMIT
+                SHA, tag or branch of the commit. Default: HEAD`
+
+// Run the "logs" subcommand with the arguments 'args' and write the log to 'w'
+func Logs(ctx context.Context, w io.Writer, args []string, conf Configuration) error {
+	f := flag.NewFlagSet("logs", flag.ContinueOnError)
+	f.SetOutput(bytes.NewBuffer(nil))
+	repoFlag := f.String("repository", ".", "")
+	repoFlagShort := f.String("r", ".", "")
+	commitFlag := f.String("commit", "HEAD", "")
+	commitFlagShort := f.String("c", "HEAD", "")
+	helpFlag := f.Bool("help", false, "")
+	helpFlagShort := f.Bool("h", false, "")
+	if err := f.Parse(args); err != nil {
+		return fmt.Errorf("%s\n%s", err.Error(), logsUsage)
+	}
+	if *helpFlag || *helpFlagShort {
+		_, err := fmt.Fprintln(w, logsUsage)
+		return err
+	}
+	if f.NArg() != 1 {
+		return fmt.Errorf("expected exactly one address\n%s", logsUsage)
+	}
+	address, err := providers.ParseAddress(f.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	repo := *repoFlag
+	if repo == "." {
+		repo = *repoFlagShort
+	}
+	commit := *commitFlag
+	if commit == "HEAD" {
+		commit = *commitFlagShort
+	}
+	remotes, ref, err := monitoredReference(repo, commit)
+	if err != nil {
+		return err
+	}
+
+	cache, err := conf.Providers.ToCache(ctx)
+	if err != nil {
+		return err
+	}
+	key, stepIDs, err := resolveAddress(ctx, cache, remotes, ref, address)
+	if err != nil {
+		return err
+	}
+	if len(stepIDs) == 0 {
+		return fmt.Errorf("address %q designates a pipeline, not a job", address)
+	}
+	log, err := cache.Log(ctx, key, stepIDs)
+	if err != nil {
+		return err
+	}
+
+	_, err = io.WriteString(w, log)
+	return err
+}
@@ -0,0 +1,28 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestLogs(t *testing.T) {
+	t.Run("help", func(t *testing.T) {
+		w := &bytes.Buffer{}
+		if err := Logs(context.Background(), w, []string{"-h"}, Configuration{}); err != nil {
+			t.Fatal(err)
+		}
+		if !strings.HasPrefix(w.String(), "usage: cistern logs") {
+			t.Fatalf("unexpected output: %q", w.String())
+		}
+	})
+
+	for _, args := range [][]string{{}, {"#1.a", "#2.b"}, {"gitlab/"}} {
+		t.Run(strings.Join(args, " "), func(t *testing.T) {
+			if err := Logs(context.Background(), &bytes.Buffer{}, args, Configuration{}); err == nil {
+				t.Fatal("expected an error but got nil")
+			}
+		})
+	}
+}
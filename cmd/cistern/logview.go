@@ -0,0 +1,233 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/gdamore/tcell"
+	"github.com/nbedos/cistern/providers"
+	"github.com/nbedos/cistern/tui"
+	"github.com/nbedos/cistern/utils"
+)
+
+// State of the log viewer
+type logViewer struct {
+	logView   *tui.LogView
+	logStatus *tui.TextArea
+	logcmd    *tui.Command
+	// Step whose log is shown by the log viewer
+	logStep stepPath
+	// Names of the pipeline and steps leading to the step whose log is shown
+	logTitle string
+	// Pattern searched in the log
+	logSearch tui.Pattern
+	// Message shown in place of the status of the log viewer until the next key press
+	logMessage string
+}
+
+// Return a log viewer highlighting the lines matching the text searched with 'match', or by
+// reversing their colors if 'match' is nil
+func newLogViewer(width int, height int, match tui.StyleTransform) (logViewer, error) {
+	if match == nil {
+		match = func(s tcell.Style) tcell.Style { return s.Reverse(true) }
+	}
+	logView, err := tui.NewLogView(width, height, match)
+	if err != nil {
+		return logViewer{}, err
+	}
+
+	logStatus, err := tui.NewTextArea(width, height)
+	if err != nil {
+		return logViewer{}, err
+	}
+	logSearch := tui.NewCommand(width, height, "Search log: ")
+
+	return logViewer{
+		logView:   &logView,
+		logStatus: &logStatus,
+		logcmd:    &logSearch,
+	}, nil
+}
+
+// Show the log of the job at the cursor in the log viewer, or in $PAGER if the configuration
+// says so
+func (c *Controller) viewLog(ctx context.Context) error {
+	c.writeStatus("Fetching logs...")
+	c.draw()
+	defer func() {
+		c.draw()
+	}()
+	key, ids, exists := c.activeStepPath()
+	if !exists {
+		return providers.ErrNoLogHere
+	}
+
+	logPath, log, err := c.readLog(ctx, key, ids)
+	if err != nil {
+		if err != providers.ErrNoLogHere {
+			c.reportError(err)
+		}
+		return nil
+	}
+
+	if !c.conf.LogPager {
+		c.writeStatus("")
+		c.logView.Clear()
+		c.logView.SetLog(log)
+		// The log of a running job is followed until the user scrolls up
+		if step, exists := c.cache.Step(key, ids); exists && step.State.IsActive() {
+			c.logView.Follow(true)
+		}
+		c.logStep = stepPath{key: key, ids: ids}
+		c.logTitle = c.activeRowBreadcrumb()
+		c.logSearch = tui.Pattern{}
+		c.logMessage = ""
+		c.focus = focusLog
+		return nil
+	}
+
+	// FIXME Do not make this choice here, move this to the configuration
+	pager := os.Getenv("PAGER")
+	if pager == "" {
+		pager = "less"
+	}
+
+	return c.tui.Exec(ctx, pager, []string{logPath}, nil)
+}
+
+// Path of a step in the cache
+type stepPath struct {
+	key providers.PipelineKey
+	ids []string
+}
+
+func (p stepPath) equal(other stepPath) bool {
+	if p.key != other.key || len(p.ids) != len(other.ids) {
+		return false
+	}
+	for i := range p.ids {
+		if p.ids[i] != other.ids[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// Log of a step fetched in the background for the log viewer or the log pane
+type logUpdate struct {
+	step stepPath
+	log  string
+	err  error
+}
+
+// Fetch the log of 'step' in the background. The log is sent to c.logc.
+func (c *Controller) fetchLog(ctx context.Context, step stepPath) {
+	c.fetchingLog = true
+	go func() {
+		u := logUpdate{step: step}
+		_, u.log, u.err = c.readLog(ctx, step.key, step.ids)
+		select {
+		case c.logc <- u:
+		case <-ctx.Done():
+		}
+	}()
+}
+
+// Return true if the log viewer is open and follows the end of the log
+func (c *Controller) followingLog() bool {
+	return (c.focus == focusLog || c.focus == focusLogSearch) && c.logView.Following()
+}
+
+// Show a log fetched in the background in the log viewer and in the log pane if they still
+// show the same step. The log viewer stops following the log once the step is finished or if
+// the log cannot be fetched.
+func (c *Controller) updateLog(u logUpdate) {
+	if u.step.equal(c.paneStep) {
+		switch u.err {
+		case nil:
+			c.paneMessage = ""
+			c.pane.SetLog(u.log)
+		case providers.ErrNoLogHere:
+			c.paneMessage = "No log for this row"
+		default:
+			c.paneMessage = fmt.Sprintf("error: %s", u.err)
+		}
+	}
+
+	if !u.step.equal(c.logStep) {
+		return
+	}
+	if u.err != nil {
+		c.logView.Follow(false)
+		if u.err != providers.ErrNoLogHere {
+			c.reportError(u.err)
+			c.logMessage = fmt.Sprintf("error: %s", u.err)
+		}
+		return
+	}
+
+	c.logView.SetLog(u.log)
+	if step, exists := c.cache.Step(u.step.key, u.step.ids); !exists || !step.State.IsActive() {
+		c.logView.Follow(false)
+	}
+}
+
+// Search the log for the pattern typed by the user and move to the next matching line
+func (c *Controller) searchLog(input string) {
+	p, err := tui.NewPattern(input)
+	if err != nil {
+		c.logMessage = fmt.Sprintf("error: %s", err)
+		return
+	}
+	c.logSearch = p
+	c.nextLogMatch(true)
+}
+
+// Move the log viewer to the next line matching the text searched
+func (c *Controller) nextLogMatch(ascending bool) {
+	if !c.logSearch.Empty() && !c.logView.Search(c.logSearch, ascending) {
+		c.logMessage = fmt.Sprintf("No match found for %#v", c.logSearch.String())
+	}
+}
+
+// Show the step whose log is shown, whether the log is followed and the position in the log,
+// or the last message of the log viewer
+func (c *Controller) writeLogStatus() {
+	if c.logMessage != "" {
+		msg := tui.NewStyledString(c.logMessage)
+		msg.Fit(tui.Left, c.width)
+		c.logStatus.WriteContent(msg)
+		return
+	}
+
+	first, total := c.logView.Position()
+	position := fmt.Sprintf("line %d/%d", utils.MinInt(first+1, total), total)
+	if c.logView.Following() {
+		position = "following, " + position
+	}
+	s := tui.NewStyledString(c.logTitle, bold)
+	s.Fit(tui.Left, utils.MaxInt(0, c.width-len(position)-1))
+	s.Append(" " + position)
+	s.Fit(tui.Left, c.width)
+	c.logStatus.WriteContent(s)
+}
+
+// Scroll the log viewer with the keys moving the cursor of the table. The keys searching the
+// table and following a git reference search the log and follow its end.
+func (c *Controller) processLogView(ev *tcell.EventKey, action string) {
+	c.logMessage = ""
+	switch {
+	case action == "quit" || ev.Key() == tcell.KeyEsc:
+		c.focus = focusTable
+	case action == "search":
+		c.focus = focusLogSearch
+		c.logcmd.Focus()
+	case action == "next-match" || action == "previous-match":
+		c.nextLogMatch(action == "next-match")
+	case action == "follow":
+		c.logView.Follow(!c.logView.Following())
+	default:
+		c.logView.Do(tableActions[action])
+	}
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/nbedos/cistern/providers"
+)
+
+func TestController_updateLog(t *testing.T) {
+	controller, teardown, err := setup()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer teardown()
+
+	pipeline := providers.Pipeline{
+		ProviderHost: "gitlab.com",
+		Step: providers.Step{
+			ID:       "42",
+			Type:     providers.StepPipeline,
+			State:    providers.Running,
+			Children: []providers.Step{{ID: "1", Type: providers.StepJob, State: providers.Running}},
+		},
+	}
+	if _, err := controller.cache.SavePipeline("sha", pipeline); err != nil {
+		t.Fatal(err)
+	}
+	key := pipeline.Key()
+	controller.logStep = stepPath{key: key, ids: []string{"1"}}
+	controller.focus = focusLog
+	controller.logView.Follow(true)
+
+	t.Run("logs of other steps are ignored", func(t *testing.T) {
+		controller.updateLog(logUpdate{step: stepPath{key: key, ids: []string{"2"}}, log: "a\nb\n"})
+		if _, total := controller.logView.Position(); total != 0 {
+			t.Fatalf("expected empty log but got %d lines", total)
+		}
+	})
+
+	t.Run("log of a running step is followed", func(t *testing.T) {
+		controller.updateLog(logUpdate{step: stepPath{key: key, ids: []string{"1"}}, log: "a\nb\n"})
+		if _, total := controller.logView.Position(); total != 2 {
+			t.Fatalf("expected 2 lines but got %d", total)
+		}
+		if !controller.followingLog() {
+			t.Fatal("expected log to be followed")
+		}
+	})
+
+	t.Run("following stops once the step is finished", func(t *testing.T) {
+		pipeline.State = providers.Passed
+		pipeline.Children[0].State = providers.Passed
+		if _, err := controller.cache.SavePipeline("sha", pipeline); err != nil {
+			t.Fatal(err)
+		}
+		controller.updateLog(logUpdate{step: stepPath{key: key, ids: []string{"1"}}, log: "a\nb\nc\n"})
+		if _, total := controller.logView.Position(); total != 3 {
+			t.Fatalf("expected 3 lines but got %d", total)
+		}
+		if controller.followingLog() {
+			t.Fatal("expected log not to be followed")
+		}
+	})
+	t.Run("log pane shows the log of its step", func(t *testing.T) {
+		controller.split = true
+		controller.paneStep = stepPath{key: key, ids: []string{"1"}}
+		controller.paneMessage = "Fetching log..."
+		controller.updateLog(logUpdate{step: stepPath{key: key, ids: []string{"1"}}, log: "a\n"})
+		if _, total := controller.pane.Position(); total != 1 || controller.paneMessage != "" {
+			t.Fatalf("expected 1 line without message but got %d lines and message %q", total, controller.paneMessage)
+		}
+		controller.updateLog(logUpdate{step: stepPath{key: key, ids: []string{"1"}}, err: providers.ErrNoLogHere})
+		if controller.paneMessage != "No log for this row" {
+			t.Fatalf("unexpected message %q", controller.paneMessage)
+		}
+	})
+}
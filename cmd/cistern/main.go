@@ -9,6 +9,7 @@ import (
 	"math/rand"
 	"os"
 	"path"
+	"strings"
 	"time"
 
 	"github.com/gdamore/tcell"
@@ -17,7 +18,14 @@ import (
 
 var Version = "undefined"
 
-const usage = `usage: cistern [-r REPOSITORY | --repository REPOSITORY] [COMMIT]
+const usage = `usage: cistern [-r REPOSITORY | --repository REPOSITORY] [--log-dir DIRECTORY]
+                [--export FILE [--export-logs]] [--replay FILE] [COMMIT]
+       cistern stats [--job NAME] [--since DURATION] [--output csv|summary]
+       cistern lint [-r REPOSITORY | --repository REPOSITORY]
+       cistern diagnose [-r REPOSITORY | --repository REPOSITORY]
+       cistern follow [-r REPOSITORY | --repository REPOSITORY] [COMMIT]
+       cistern logs [-r REPOSITORY] [-c COMMIT] ADDRESS
+       cistern rerun [-r REPOSITORY] [-c COMMIT] ADDRESS [NAME=value...]
        cistern -h | --help
        cistern --version
 
@@ -27,7 +35,25 @@ Positional arguments:
   COMMIT        Specify the commit to monitor. COMMIT is expected to be
                 the SHA identifier of a commit, or the name of a tag or
                 a branch. If this option is missing cistern will monitor
-                the commit referenced by HEAD.
+                the commit referenced by HEAD. Use "cistern -- stats"
+                to monitor a branch named "stats" (same for the other
+                subcommands).
+
+Subcommands:
+  stats         Export the duration and the outcome of the jobs saved in
+                the cache directory. Run "cistern stats --help" for details.
+  lint          Validate the CI configuration files of a local repository
+                with the API of CI providers. Run "cistern lint --help" for
+                details.
+  diagnose      Write a report to attach to bug reports, secrets redacted.
+                Run "cistern diagnose --help" for details.
+  follow        Print changes of the state of pipelines to the standard
+                output instead of starting the user interface. Run
+                "cistern follow --help" for details.
+  logs          Write the log of a job to the standard output. Run
+                "cistern logs --help" for details.
+  rerun         Run a pipeline again, optionally with different variables.
+                Run "cistern rerun --help" for details.
 
 Options:
   -r REPOSITORY, --repository REPOSITORY
@@ -39,6 +65,26 @@ Options:
                 had been set to the path of the current directory.
                 Note that cistern will only monitor repositories hosted
                 on GitLab or GitHub.
+                This option may be given several times to monitor
+                several repositories, each one in a tab of its own.
+
+  --log-dir DIRECTORY
+                Specify the directory where log files are written before
+                being opened in the pager. This overrides the "directory"
+                key of the [logs] section of the configuration file.
+                Default: $XDG_CACHE_HOME/cistern/logs
+
+  --export FILE Write a JSON snapshot of the commits and pipelines shown
+                by cistern to FILE when exiting. Values of secret
+                variables are masked.
+
+  --export-logs Include the logs of all jobs in the snapshot written to
+                the file set by --export
+
+  --replay FILE Show the pipelines of a snapshot written by --export
+                instead of querying CI providers. If COMMIT is missing
+                from the snapshot, the most recent commit of the
+                snapshot is shown.
 
   -h, --help    Show usage
 
@@ -48,14 +94,51 @@ const warningNoConfigFileFormat = `warning: No configuration file found at %s, u
 Please note that:
     - cistern will likely reach the rate limit of the GitHub API for unauthenticated clients in a few minutes
     - cistern will not be able to access pipeline jobs on GitLab without an API access token
+    - only public repositories are visible and pipelines cannot be run again
 	
 To lift these restrictions, create a configuration file containing your credentials at the aforementioned location.
 `
 
+// Repositories set by the repeatable --repository option
+type repositoriesFlag []string
+
+func (f *repositoriesFlag) String() string {
+	return strings.Join(*f, ", ")
+}
+
+func (f *repositoriesFlag) Set(repository string) error {
+	*f = append(*f, repository)
+	return nil
+}
+
 func Main(w io.Writer) error {
 	SetupSignalHandlers()
 	rand.Seed(time.Now().UnixNano())
 
+	if len(os.Args) > 1 && os.Args[1] == "diagnose" {
+		paths := utils.XDGConfigLocations(path.Join(ConfDir, ConfFilename))
+		return Diagnose(context.Background(), os.Stdout, os.Args[2:], paths)
+	}
+
+	if len(os.Args) > 1 && (os.Args[1] == "stats" || os.Args[1] == "lint" || os.Args[1] == "follow" || os.Args[1] == "logs" || os.Args[1] == "rerun") {
+		paths := utils.XDGConfigLocations(path.Join(ConfDir, ConfFilename))
+		config, err := ConfigFromPaths(paths...)
+		if err != nil && err != ErrMissingConf {
+			return err
+		}
+		switch os.Args[1] {
+		case "lint":
+			return Lint(context.Background(), os.Stdout, os.Args[2:], config)
+		case "follow":
+			return Follow(context.Background(), os.Stdout, os.Stderr, os.Args[2:], config)
+		case "logs":
+			return Logs(context.Background(), os.Stdout, os.Args[2:], config)
+		case "rerun":
+			return Rerun(context.Background(), os.Stdout, os.Args[2:], config)
+		}
+		return Stats(os.Stdout, os.Args[2:], config, time.Now())
+	}
+
 	f := flag.NewFlagSet("cistern", flag.ContinueOnError)
 	null := bytes.NewBuffer(nil)
 	f.SetOutput(null)
@@ -68,8 +151,13 @@ func Main(w io.Writer) error {
 	versionFlag := f.Bool("version", false, "")
 	helpFlagShort := f.Bool("h", false, "")
 	helpFlag := f.Bool("help", false, "")
-	repoFlag := f.String("repository", defaultRepository, "")
-	repoFlagShort := f.String("r", defaultRepository, "")
+	repos := make(repositoriesFlag, 0)
+	f.Var(&repos, "repository", "")
+	f.Var(&repos, "r", "")
+	logDirFlag := f.String("log-dir", "", "")
+	exportFlag := f.String("export", "", "")
+	exportLogsFlag := f.Bool("export-logs", false, "")
+	replayFlag := f.String("replay", "", "")
 
 	if err := f.Parse(os.Args[1:]); err != nil {
 		return fmt.Errorf("%s\n%s", err.Error(), usage)
@@ -92,9 +180,8 @@ func Main(w io.Writer) error {
 		return fmt.Errorf("at most one commit can be specified\n%s", usage)
 	}
 
-	repo := *repoFlag
-	if repo == defaultRepository {
-		repo = *repoFlagShort
+	if len(repos) == 0 {
+		repos = append(repos, defaultRepository)
 	}
 
 	paths := utils.XDGConfigLocations(path.Join(ConfDir, ConfFilename))
@@ -116,7 +203,17 @@ func Main(w io.Writer) error {
 		return err
 	}
 
-	return RunApplication(context.Background(), tcell.NewScreen, repo, sha, config)
+	if *logDirFlag != "" {
+		config.Logs.Directory = *logDirFlag
+	}
+	if *exportLogsFlag && *exportFlag == "" {
+		return fmt.Errorf("--export-logs requires --export\n%s", usage)
+	}
+	config.Export.Path = *exportFlag
+	config.Export.Logs = *exportLogsFlag
+	config.Replay = *replayFlag
+
+	return RunApplication(context.Background(), tcell.NewScreen, repos, sha, config)
 }
 
 func main() {
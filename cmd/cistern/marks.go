@@ -0,0 +1,56 @@
+package main
+
+import (
+	"fmt"
+	"unicode"
+
+	"github.com/gdamore/tcell"
+)
+
+// Wait for the letter of the mark that 'action', "mark" or "jump-to-mark", applies to
+func (c *Controller) waitForMark(action string) {
+	c.pendingAction = action
+	switch action {
+	case "mark":
+		c.writeStatus("Type a letter to mark the row at the cursor")
+	case "jump-to-mark":
+		c.writeStatus("Type the letter of the mark to jump to")
+	}
+}
+
+// Mark the row at the cursor with the letter typed, or move the cursor to the row marked with
+// it, depending on the action waiting for the letter. Marks are kept by path so that they
+// survive refreshes of the table.
+func (c *Controller) completeMark(action string, ev *tcell.EventKey) {
+	if ev.Key() == tcell.KeyEsc {
+		return
+	}
+	r := ev.Rune()
+	if ev.Key() != tcell.KeyRune || !(unicode.IsLetter(r) || (r == '\'' && action == "jump-to-mark")) {
+		c.writeStatus("error: marks are letters")
+		return
+	}
+
+	switch action {
+	case "mark":
+		path := c.table.ActiveNodePath()
+		if path == nil {
+			c.writeStatus("error: no row to mark")
+			return
+		}
+		c.marks[r] = path
+		c.writeStatus(fmt.Sprintf("Row marked with '%c'", r))
+	case "jump-to-mark":
+		path, exists := c.marks[r]
+		if !exists {
+			c.writeStatus(fmt.Sprintf("error: no row is marked with '%c'", r))
+			return
+		}
+		previous := c.table.ActiveNodePath()
+		if !c.table.ScrollToPath(path) {
+			c.writeStatus(fmt.Sprintf("error: the row marked with '%c' is not shown anymore", r))
+			return
+		}
+		c.marks['\''] = previous
+	}
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gdamore/tcell"
+	"github.com/nbedos/cistern/providers"
+	"github.com/nbedos/cistern/tui"
+)
+
+func TestController_marks(t *testing.T) {
+	controller, teardown, err := setup()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer teardown()
+
+	conf := tui.TableConfiguration{
+		NodeStyle: providers.StepStyle{
+			GitStyle: providers.GitStyle{
+				Location: time.UTC,
+			},
+		},
+	}
+	table, err := tui.NewHierarchicalTable(conf, nil, 80, 20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	controller.table = &table
+
+	save := func(id string) {
+		pipeline := providers.Pipeline{
+			Number:       id,
+			ProviderHost: "gitlab.com",
+			ProviderName: "gitlab",
+			Step: providers.Step{
+				ID:   id,
+				Type: providers.StepPipeline,
+			},
+		}
+		if _, err := controller.cache.SavePipeline("sha", pipeline); err != nil {
+			t.Fatal(err)
+		}
+		controller.refresh()
+	}
+	controller.cache.SaveCommit("master", providers.Commit{Sha: "sha"})
+	controller.setRef(providers.Ref{Name: "master"})
+	for i := 0; i < 4; i++ {
+		save(strconv.Itoa(i))
+	}
+
+	cursor := func() string {
+		return controller.table.ActiveNodePath()[0].(providers.PipelineKey).ID
+	}
+	press := func(keys string) {
+		for _, r := range keys {
+			ev := tcell.NewEventKey(tcell.KeyRune, r, tcell.ModNone)
+			if _, _, err := controller.process(context.Background(), ev); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	status := func() string {
+		return controller.status.Content[0].String()
+	}
+
+	press("jBa")
+	marked := cursor()
+	press("GBb")
+	last := cursor()
+
+	// Marks survive refreshes of the table
+	save("4")
+	press("'a")
+	if id := cursor(); id != marked {
+		t.Fatalf("expected cursor on pipeline %q but got %q", marked, id)
+	}
+	press("''")
+	if id := cursor(); id != last {
+		t.Fatalf("expected cursor back on pipeline %q but got %q", last, id)
+	}
+
+	press("'z")
+	if id := cursor(); id != last || !strings.HasPrefix(status(), "error: no row is marked") {
+		t.Fatalf("expected cursor to stay on pipeline %q with an error but got %q and status %q", last, id, status())
+	}
+	press("B1")
+	if !strings.HasPrefix(status(), "error: marks are letters") {
+		t.Fatalf("expected an error but got status %q", status())
+	}
+	// The key following an invalid mark is processed as usual
+	press("'a")
+	if id := cursor(); id != marked {
+		t.Fatalf("expected cursor on pipeline %q but got %q", marked, id)
+	}
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"github.com/nbedos/cistern/providers"
+	"github.com/nbedos/cistern/tui"
+)
+
+// Return the name of the row gathering the pipelines of the commit
+func commitGroupName(sha string) string {
+	if len(sha) > 7 {
+		sha = sha[:7]
+	}
+	return "commit " + sha
+}
+
+// Gather the pipelines found in 'nodes' under a single row for the commit 'sha' if they were
+// reported by more than one provider, so that the table reads commit, pipelines of each
+// provider, jobs. Groups found in 'nodes' are kept as they are. The second result is the ID of
+// the row of the commit, empty if pipelines were not gathered.
+func mergeProviders(nodes []tui.TableNode, sha string) ([]tui.TableNode, providers.PipelineGroupID) {
+	pipelines := make(providers.Pipelines, 0, len(nodes))
+	others := make([]tui.TableNode, 0)
+	hosts := make(map[string]struct{})
+	for _, node := range nodes {
+		if pipeline, ok := node.(providers.Pipeline); ok {
+			pipelines = append(pipelines, pipeline)
+			hosts[pipeline.ProviderHost] = struct{}{}
+		} else {
+			others = append(others, node)
+		}
+	}
+	if len(hosts) < 2 {
+		return nodes, ""
+	}
+
+	group := providers.PipelineGroup{
+		Name:      commitGroupName(sha),
+		Pipelines: pipelines,
+	}
+	return append([]tui.TableNode{group}, others...), group.NodeID().(providers.PipelineGroupID)
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/nbedos/cistern/providers"
+	"github.com/nbedos/cistern/tui"
+)
+
+func TestMergeProviders(t *testing.T) {
+	bots := providers.PipelineGroup{Name: botGroupName}
+
+	t.Run("pipelines of a single provider are not merged", func(t *testing.T) {
+		nodes := []tui.TableNode{
+			providers.Pipeline{ProviderHost: "travis-ci.org", Step: providers.Step{ID: "1"}},
+			providers.Pipeline{ProviderHost: "travis-ci.org", Step: providers.Step{ID: "2"}},
+		}
+		merged, id := mergeProviders(nodes, "0123456789abcdef")
+		if id != "" || len(merged) != 2 {
+			t.Fatalf("expected nodes to be left as they are but got %v (%+v)", id, merged)
+		}
+	})
+
+	t.Run("pipelines of several providers are merged", func(t *testing.T) {
+		nodes := []tui.TableNode{
+			providers.Pipeline{ProviderHost: "travis-ci.org", Step: providers.Step{ID: "1"}},
+			bots,
+			providers.Pipeline{ProviderHost: "ci.appveyor.com", Step: providers.Step{ID: "2"}},
+		}
+		merged, id := mergeProviders(nodes, "0123456789abcdef")
+		if id != providers.PipelineGroupID("commit 0123456") {
+			t.Fatalf("unexpected group ID %q", id)
+		}
+		if len(merged) != 2 {
+			t.Fatalf("expected 2 nodes but got %d", len(merged))
+		}
+		group, ok := merged[0].(providers.PipelineGroup)
+		if !ok || len(group.Pipelines) != 2 {
+			t.Fatalf("expected first node to gather both pipelines but got %+v", merged[0])
+		}
+		if other, ok := merged[1].(providers.PipelineGroup); !ok || other.Name != botGroupName {
+			t.Fatalf("expected the group of bots to be kept but got %+v", merged[1])
+		}
+	})
+}
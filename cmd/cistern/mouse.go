@@ -0,0 +1,46 @@
+package main
+
+import (
+	"github.com/gdamore/tcell"
+	"github.com/nbedos/cistern/tui"
+)
+
+// Scroll with the mouse wheel and click on the table to move the cursor, open and close folds
+// or sort rows. A click is handled when the left button is pressed, not while it is held down.
+func (c *Controller) processMouse(ev *tcell.EventMouse) {
+	buttons := ev.Buttons()
+	click := buttons&tcell.Button1 != 0 && c.mouseButtons&tcell.Button1 == 0
+	c.mouseButtons = buttons
+
+	action := tui.ActionNone
+	switch {
+	case buttons&tcell.WheelUp != 0:
+		action = tui.ActionCursorUp
+	case buttons&tcell.WheelDown != 0:
+		action = tui.ActionCursorDown
+	}
+
+	switch c.focus {
+	case focusHelp:
+		c.help.Do(action)
+	case focusErrors:
+		c.errorView.Do(action)
+	case focusTimeline:
+		c.timeline.Do(action)
+	case focusTests:
+		c.testReport.Do(action)
+	case focusStats:
+		c.stats.Do(action)
+	case focusLog:
+		c.logView.Do(action)
+	case focusTable:
+		x, y := ev.Position()
+		dim := c.layout[c.table]
+		switch {
+		case action != tui.ActionNone:
+			c.table.Do(action)
+		case click && x >= dim.x && x < dim.x+dim.width && y >= dim.y && y < dim.y+dim.height:
+			c.table.Click(x-dim.x, y-dim.y)
+		}
+	}
+}
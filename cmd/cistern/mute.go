@@ -0,0 +1,62 @@
+package main
+
+import "fmt"
+
+// Open the mute prompt, suggesting the git reference of the pipeline at the cursor as pattern
+func (c *Controller) openMutePrompt() {
+	c.focus = focusMute
+	c.mutecmd.Focus()
+	if key, _, exists := c.activeStepPath(); exists {
+		if pipeline, exists := c.cache.Pipeline(key); exists {
+			c.mutecmd.SetInput(pipeline.Ref)
+		}
+	}
+}
+
+// Hide pipelines of the git references matching pattern for the duration set in the
+// configuration and save the mute rule to the state file
+func (c *Controller) mute(pattern string) error {
+	now := c.clock.Now()
+	until := now.Add(c.conf.MuteDuration)
+	if err := c.reloadState(); err != nil {
+		return err
+	}
+	c.state.mute(c.repository, pattern, until, now)
+	if err := c.state.Save(c.conf.StatePath); err != nil {
+		return err
+	}
+	c.refresh()
+	c.writeStatus(fmt.Sprintf("Hiding pipelines of refs matching %q until %s", pattern, until.Format("Jan 2 15:04")))
+
+	return nil
+}
+
+// Load the state file again when several repositories are monitored since the controllers of
+// other tabs may have saved rules of their own since it was loaded
+func (c *Controller) reloadState() error {
+	if c.tabs == nil {
+		return nil
+	}
+	state, err := LoadState(c.conf.StatePath)
+	if err != nil {
+		return err
+	}
+	c.state = state
+
+	return nil
+}
+
+// Remove all mute rules applying to the current repository
+func (c *Controller) unmute() error {
+	if err := c.reloadState(); err != nil {
+		return err
+	}
+	n := c.state.unmute(c.repository, c.clock.Now())
+	if err := c.state.Save(c.conf.StatePath); err != nil {
+		return err
+	}
+	c.refresh()
+	c.writeStatus(fmt.Sprintf("Removed %d mute rule(s)", n))
+
+	return nil
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"context"
+
+	"github.com/gdamore/tcell"
+	"github.com/nbedos/cistern/tui"
+	"github.com/nbedos/cistern/utils"
+)
+
+// State of the log pane shown below the table
+type logPane struct {
+	// True if the lower half of the screen shows the end of the log of the step at the cursor
+	split     bool
+	pane      *tui.LogView
+	paneTitle *tui.TextArea
+	// Step whose log is shown by the log pane
+	paneStep stepPath
+	// Message shown by the title of the log pane while there is no log to show, for example
+	// because it is being fetched
+	paneMessage string
+	// True if the log of paneStep has yet to be fetched
+	panePending bool
+}
+
+// Return a hidden log pane
+func newLogPane(width int, height int) (logPane, error) {
+	pane, err := tui.NewLogView(width, height, nil)
+	if err != nil {
+		return logPane{}, err
+	}
+	pane.Follow(true)
+
+	paneTitle, err := tui.NewTextArea(width, height)
+	if err != nil {
+		return logPane{}, err
+	}
+
+	return logPane{
+		pane:      &pane,
+		paneTitle: &paneTitle,
+	}, nil
+}
+
+// Return true if the log pane is shown and its log must be fetched again because the step is
+// still running
+func (c *Controller) followingPane() bool {
+	if !c.split || c.focus == focusLog || c.focus == focusLogSearch || c.paneMessage != "" {
+		return false
+	}
+	step, exists := c.cache.Step(c.paneStep.key, c.paneStep.ids)
+	return exists && step.State.IsActive()
+}
+
+// Make the log pane show the log of the step at the cursor. The log is fetched in the
+// background once no other log is being fetched.
+func (c *Controller) syncPane(ctx context.Context) {
+	if !c.split {
+		return
+	}
+	key, ids, exists := c.activeStepPath()
+	if step := (stepPath{key: key, ids: ids}); !step.equal(c.paneStep) {
+		c.paneStep = step
+		c.pane.Clear()
+		c.pane.Follow(true)
+		c.paneMessage = "Fetching log..."
+		c.panePending = exists
+		if !exists {
+			c.paneMessage = "No log for this row"
+		}
+	}
+	if c.panePending && !c.fetchingLog {
+		c.panePending = false
+		c.fetchLog(ctx, c.paneStep)
+	}
+}
+
+// Show or hide the log pane
+func (c *Controller) toggleSplit(ctx context.Context) {
+	c.split = !c.split
+	c.paneStep = stepPath{}
+	c.pane.Clear()
+	c.pane.Follow(true)
+	c.paneMessage = "No log for this row"
+	c.panePending = false
+	c.resize(c.width, c.height)
+	c.syncPane(ctx)
+}
+
+// Show the names of the steps leading to the step of the log pane and the reason why no log is
+// shown, if any
+func (c *Controller) writePaneTitle() {
+	title := tui.NewStyledString(" Log: " + c.activeRowBreadcrumb())
+	if c.paneMessage != "" {
+		title.Fit(tui.Left, utils.MaxInt(0, c.width-len(c.paneMessage)-1))
+		title.Append(c.paneMessage + " ")
+	}
+	title.Fit(tui.Left, c.width)
+	title.Apply(func(s tcell.Style) tcell.Style {
+		return s.Reverse(true)
+	})
+	c.paneTitle.WriteContent(title)
+}
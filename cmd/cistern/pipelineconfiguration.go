@@ -0,0 +1,38 @@
+package main
+
+import (
+	"context"
+	"os"
+	"strings"
+
+	"github.com/nbedos/cistern/providers"
+)
+
+// Show the configuration executed by the pipeline at the cursor in $PAGER
+func (c *Controller) viewConfiguration(ctx context.Context) error {
+	defer c.draw()
+	key, _, exists := c.activeStepPath()
+	if !exists {
+		return nil
+	}
+	c.writeStatus("Fetching configuration...")
+	c.draw()
+
+	configuration, err := c.cache.Configuration(ctx, key)
+	if err != nil {
+		if err == providers.ErrNoConfiguration {
+			c.writeStatus("The provider of this pipeline does not expose the configuration it executed")
+		} else {
+			c.reportError(err)
+		}
+		return nil
+	}
+	c.writeStatus("")
+
+	pager := os.Getenv("PAGER")
+	if pager == "" {
+		pager = "less"
+	}
+
+	return c.tui.Exec(ctx, pager, nil, strings.NewReader(configuration))
+}
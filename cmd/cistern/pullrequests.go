@@ -0,0 +1,81 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/nbedos/cistern/providers"
+	"github.com/nbedos/cistern/tui"
+)
+
+// Rows of the table gathering pipelines: pull requests, commits and bots
+type pipelineGrouping struct {
+	// True if pipelines of pull requests are gathered under a row for each pull request
+	groupPullRequests bool
+	// Row of the table gathering each pipeline that is part of a group
+	groupOf map[providers.PipelineKey]providers.PipelineGroupID
+}
+
+func newPipelineGrouping(pullRequests bool) pipelineGrouping {
+	return pipelineGrouping{
+		groupPullRequests: pullRequests,
+		groupOf:           make(map[providers.PipelineKey]providers.PipelineGroupID),
+	}
+}
+
+// Gather the pipelines found in 'nodes' that were run for a pull request under a row for each
+// pull request. Other nodes are kept as they are.
+func groupPullRequests(nodes []tui.TableNode) []tui.TableNode {
+	result := make([]tui.TableNode, 0, len(nodes))
+	groups := make([]*providers.PipelineGroup, 0)
+	groupByNumber := make(map[string]*providers.PipelineGroup)
+	for _, node := range nodes {
+		pipeline, ok := node.(providers.Pipeline)
+		if !ok || !pipeline.PullRequest.Valid() {
+			result = append(result, node)
+			continue
+		}
+		group, exists := groupByNumber[pipeline.PullRequest.Number]
+		if !exists {
+			group = &providers.PipelineGroup{
+				Name: fmt.Sprintf("pull request #%s", pipeline.PullRequest.Number),
+			}
+			groupByNumber[pipeline.PullRequest.Number] = group
+			groups = append(groups, group)
+		}
+		if group.Title == "" {
+			group.Title = pipeline.PullRequest.Title
+		}
+		group.Pipelines = append(group.Pipelines, pipeline)
+	}
+	for _, group := range groups {
+		result = append(result, *group)
+	}
+
+	return result
+}
+
+// Return the row gathering each pipeline of 'nodes' that is part of a group
+func pipelineGroups(nodes []tui.TableNode) map[providers.PipelineKey]providers.PipelineGroupID {
+	groups := make(map[providers.PipelineKey]providers.PipelineGroupID)
+	for _, node := range nodes {
+		if group, ok := node.(providers.PipelineGroup); ok {
+			for _, pipeline := range group.Pipelines {
+				groups[pipeline.Key()] = group.NodeID().(providers.PipelineGroupID)
+			}
+		}
+	}
+
+	return groups
+}
+
+// Toggle between showing pipelines of pull requests under a row for each pull request and
+// showing them like other pipelines
+func (c *Controller) toggleGroupPullRequests() {
+	c.groupPullRequests = !c.groupPullRequests
+	c.refresh()
+	if c.groupPullRequests {
+		c.writeStatus("Grouping pipelines by pull request")
+	} else {
+		c.writeStatus("Not grouping pipelines by pull request")
+	}
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/nbedos/cistern/providers"
+	"github.com/nbedos/cistern/tui"
+)
+
+func TestGroupPullRequests(t *testing.T) {
+	nodes := []tui.TableNode{
+		providers.Pipeline{Step: providers.Step{ID: "1"}, PullRequest: providers.PullRequest{Number: "12", Title: "Fix typo"}},
+		providers.Pipeline{Step: providers.Step{ID: "2"}},
+		providers.PipelineGroup{Name: botGroupName},
+		providers.Pipeline{Step: providers.Step{ID: "3"}, PullRequest: providers.PullRequest{Number: "12"}},
+		providers.Pipeline{Step: providers.Step{ID: "4"}, PullRequest: providers.PullRequest{Number: "13"}},
+	}
+	grouped := groupPullRequests(nodes)
+	if len(grouped) != 4 {
+		t.Fatalf("expected 4 nodes but got %d: %+v", len(grouped), grouped)
+	}
+
+	expected := []providers.PipelineGroup{
+		{Name: "pull request #12", Title: "Fix typo", Pipelines: providers.Pipelines{nodes[0].(providers.Pipeline), nodes[3].(providers.Pipeline)}},
+		{Name: "pull request #13", Pipelines: providers.Pipelines{nodes[4].(providers.Pipeline)}},
+	}
+	for i, e := range expected {
+		group, ok := grouped[2+i].(providers.PipelineGroup)
+		if !ok {
+			t.Fatalf("expected node %d to be a group but got %+v", 2+i, grouped[2+i])
+		}
+		if group.Name != e.Name || group.Title != e.Title || len(group.Pipelines) != len(e.Pipelines) {
+			t.Fatalf("expected %+v but got %+v", e, group)
+		}
+		for j := range e.Pipelines {
+			if group.Pipelines[j].Key() != e.Pipelines[j].Key() {
+				t.Fatalf("expected pipeline %v but got %v", e.Pipelines[j].Key(), group.Pipelines[j].Key())
+			}
+		}
+	}
+
+	groups := pipelineGroups(grouped)
+	if groups[nodes[3].(providers.Pipeline).Key()] != providers.PipelineGroupID("pull request #12") {
+		t.Fatalf("unexpected groups %v", groups)
+	}
+	if _, exists := groups[nodes[1].(providers.Pipeline).Key()]; exists {
+		t.Fatalf("pipeline outside of a pull request must not be part of a group: %v", groups)
+	}
+}
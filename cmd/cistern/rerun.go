@@ -0,0 +1,246 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"strings"
+	"unicode"
+
+	"github.com/gdamore/tcell"
+	"github.com/nbedos/cistern/providers"
+)
+
+const rerunUsage = `usage: cistern rerun [-r REPOSITORY | --repository REPOSITORY] [-c COMMIT | --commit COMMIT] ADDRESS [NAME=value...]
+
+Run the pipeline designated by ADDRESS among the pipelines of COMMIT again
+and print the URL of the new pipeline. The variables of the new pipeline are
+those of the original pipeline, replaced or completed by the variables given
+as arguments.
+
+` + addressHelp + ` If ADDRESS designates a job, the pipeline of the job
+is run again.
+
+Options:
+  -r REPOSITORY, --repository REPOSITORY
+                Path or URL of the repository. Default: current directory
+
+  -c COMMIT, --commit COMMIT
+                SHA, tag or branch of the commit. Default: HEAD`
+
+// Return 'variables' with the value of variables named like one of 'overrides' replaced by
+// the value of the override. Overrides matching no variable are appended.
+func overrideVariables(variables []providers.Variable, overrides []providers.Variable) []providers.Variable {
+	result := append([]providers.Variable(nil), variables...)
+	for _, override := range overrides {
+		replaced := false
+		for i := range result {
+			if result[i].Name == override.Name {
+				result[i].Value = override.Value
+				replaced = true
+			}
+		}
+		if !replaced {
+			result = append(result, override)
+		}
+	}
+
+	return result
+}
+
+// Run the "rerun" subcommand with the arguments 'args' and write the URL of the new pipeline
+// to 'w'
+func Rerun(ctx context.Context, w io.Writer, args []string, conf Configuration) error {
+	f := flag.NewFlagSet("rerun", flag.ContinueOnError)
+	f.SetOutput(bytes.NewBuffer(nil))
+	repoFlag := f.String("repository", ".", "")
+	repoFlagShort := f.String("r", ".", "")
+	commitFlag := f.String("commit", "HEAD", "")
+	commitFlagShort := f.String("c", "HEAD", "")
+	helpFlag := f.Bool("help", false, "")
+	helpFlagShort := f.Bool("h", false, "")
+	if err := f.Parse(args); err != nil {
+		return fmt.Errorf("%s\n%s", err.Error(), rerunUsage)
+	}
+	if *helpFlag || *helpFlagShort {
+		_, err := fmt.Fprintln(w, rerunUsage)
+		return err
+	}
+	if f.NArg() < 1 {
+		return fmt.Errorf("missing address\n%s", rerunUsage)
+	}
+	address, err := providers.ParseAddress(f.Arg(0))
+	if err != nil {
+		return err
+	}
+	overrides := make([]providers.Variable, 0)
+	for _, arg := range f.Args()[1:] {
+		i := strings.Index(arg, "=")
+		if i <= 0 {
+			return fmt.Errorf("invalid variable %q (expected NAME=value)", arg)
+		}
+		overrides = append(overrides, providers.Variable{Name: arg[:i], Value: arg[i+1:]})
+	}
+
+	repo := *repoFlag
+	if repo == "." {
+		repo = *repoFlagShort
+	}
+	commit := *commitFlag
+	if commit == "HEAD" {
+		commit = *commitFlagShort
+	}
+	remotes, ref, err := monitoredReference(repo, commit)
+	if err != nil {
+		return err
+	}
+
+	cache, err := conf.Providers.ToCache(ctx)
+	if err != nil {
+		return err
+	}
+	key, _, err := resolveAddress(ctx, cache, remotes, ref, address)
+	if err != nil {
+		return err
+	}
+	pipeline, exists := cache.Pipeline(key)
+	if !exists {
+		return providers.ErrAddressNotFound
+	}
+	u, err := cache.Rerun(ctx, key, overrideVariables(pipeline.Variables, overrides))
+	if err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprintln(w, u)
+	return err
+}
+
+// Return the variables as a space separated list of NAME=value, quoting values that contain
+// spaces or quotes. The value of secret variables is masked.
+func formatVariables(variables []providers.Variable) string {
+	words := make([]string, 0, len(variables))
+	for _, v := range variables {
+		value := v.Value
+		if v.IsSecret() {
+			value = providers.MaskedValue
+		}
+		if value == "" || strings.ContainsAny(value, " \t\"\\") {
+			value = `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(value) + `"`
+		}
+		words = append(words, v.Name+"="+value)
+	}
+
+	return strings.Join(words, " ")
+}
+
+// Parse a space separated list of NAME=value. Values may be enclosed in double quotes in which
+// case backslash escapes a double quote or a backslash.
+func parseVariables(s string) ([]providers.Variable, error) {
+	variables := make([]providers.Variable, 0)
+	words := make([]string, 0)
+	var word strings.Builder
+	inWord, quoted, escaped := false, false, false
+	for _, r := range s {
+		switch {
+		case escaped:
+			word.WriteRune(r)
+			escaped = false
+		case quoted && r == '\\':
+			escaped = true
+		case r == '"':
+			quoted = !quoted
+			inWord = true
+		case !quoted && unicode.IsSpace(r):
+			if inWord {
+				words = append(words, word.String())
+				word.Reset()
+				inWord = false
+			}
+		default:
+			word.WriteRune(r)
+			inWord = true
+		}
+	}
+	if quoted {
+		return nil, errors.New("missing closing double quote")
+	}
+	if inWord {
+		words = append(words, word.String())
+	}
+
+	for _, w := range words {
+		i := strings.Index(w, "=")
+		if i <= 0 {
+			return nil, fmt.Errorf("invalid variable %q (expected NAME=value)", w)
+		}
+		variables = append(variables, providers.Variable{
+			Name:  w[:i],
+			Value: w[i+1:],
+		})
+	}
+
+	return variables, nil
+}
+
+// Open the rerun prompt filled with the variables of the pipeline at the cursor
+func (c *Controller) openRerunPrompt() {
+	key, _, exists := c.activeStepPath()
+	if !exists {
+		return
+	}
+	pipeline, exists := c.cache.Pipeline(key)
+	if !exists {
+		return
+	}
+	c.rerunKey = key
+	c.focus = focusRerun
+	c.reruncmd.Focus()
+	c.reruncmd.SetInput(formatVariables(pipeline.Variables))
+}
+
+// Ask the user to confirm that the pipeline selected when the rerun prompt was opened must
+// run again with the variables entered in the prompt. The answer is the next key typed.
+func (c *Controller) confirmRerun(input string) {
+	variables, err := parseVariables(input)
+	if err != nil {
+		c.writeStatus(err.Error())
+		return
+	}
+	c.rerunVariables = variables
+	c.pendingAction = "rerun"
+	c.writeStatus("Run the pipeline again with these variables? (y/n)")
+}
+
+// Run the pipeline again if the key typed in answer to the confirmation request is 'y'. Return
+// true if a new pipeline was started.
+func (c *Controller) answerRerun(ctx context.Context, ev *tcell.EventKey) bool {
+	if r := ev.Rune(); ev.Key() == tcell.KeyRune && (r == 'y' || r == 'Y') {
+		return c.rerun(ctx)
+	}
+	c.writeStatus("Rerun canceled")
+	return false
+}
+
+// Run the pipeline selected when the rerun prompt was opened again with the variables
+// confirmed by the user. Return true if a new pipeline was started.
+func (c *Controller) rerun(ctx context.Context) bool {
+	u, err := c.cache.Rerun(ctx, c.rerunKey, c.rerunVariables)
+	switch {
+	case err == providers.ErrRerunNotSupported:
+		c.writeStatus("The provider of this pipeline cannot run it again with different variables")
+		return false
+	case err == providers.ErrReadOnly:
+		c.writeStatus("Running a pipeline again requires an API token for its provider")
+		return false
+	case err != nil:
+		c.reportError(err)
+		return false
+	}
+	c.writeStatus(fmt.Sprintf("Started new pipeline: %s", u))
+
+	return true
+}
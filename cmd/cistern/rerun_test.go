@@ -0,0 +1,221 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gdamore/tcell"
+	"github.com/google/go-cmp/cmp"
+	"github.com/nbedos/cistern/providers"
+	"github.com/nbedos/cistern/tui"
+	"github.com/nbedos/cistern/utils"
+)
+
+func TestFormatVariables(t *testing.T) {
+	variables := []providers.Variable{
+		{Name: "DEPLOY", Value: "true"},
+		{Name: "MESSAGE", Value: `say "hello world"`},
+		{Name: "EMPTY", Value: ""},
+		{Name: "API_TOKEN", Value: "secret"},
+	}
+
+	expected := `DEPLOY=true MESSAGE="say \"hello world\"" EMPTY="" API_TOKEN=****`
+	if s := formatVariables(variables); s != expected {
+		t.Fatalf("expected %q but got %q", expected, s)
+	}
+}
+
+func TestParseVariables(t *testing.T) {
+	testCases := []struct {
+		name      string
+		input     string
+		variables []providers.Variable
+		fails     bool
+	}{
+		{
+			name:      "empty input",
+			input:     "  ",
+			variables: []providers.Variable{},
+		},
+		{
+			name:  "unquoted values",
+			input: "DEPLOY=true  TARGET=production URL=https://example.com/?a=b",
+			variables: []providers.Variable{
+				{Name: "DEPLOY", Value: "true"},
+				{Name: "TARGET", Value: "production"},
+				{Name: "URL", Value: "https://example.com/?a=b"},
+			},
+		},
+		{
+			name:  "quoted values",
+			input: `MESSAGE="say \"hello world\"" EMPTY="" PATH="C:\\Program Files"`,
+			variables: []providers.Variable{
+				{Name: "MESSAGE", Value: `say "hello world"`},
+				{Name: "EMPTY", Value: ""},
+				{Name: "PATH", Value: `C:\Program Files`},
+			},
+		},
+		{
+			name:  "missing equal sign",
+			input: "DEPLOY",
+			fails: true,
+		},
+		{
+			name:  "missing name",
+			input: "=true",
+			fails: true,
+		},
+		{
+			name:  "missing closing quote",
+			input: `MESSAGE="hello`,
+			fails: true,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			variables, err := parseVariables(testCase.input)
+			if testCase.fails {
+				if err == nil {
+					t.Fatal("expected an error but got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(testCase.variables, variables); len(diff) > 0 {
+				t.Fatal(diff)
+			}
+		})
+	}
+
+	t.Run("parsing formatted variables is lossless", func(t *testing.T) {
+		expected := []providers.Variable{
+			{Name: "MESSAGE", Value: `a "quoted" \ value`},
+			{Name: "EMPTY", Value: ""},
+		}
+		variables, err := parseVariables(formatVariables(expected))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if diff := cmp.Diff(expected, variables); len(diff) > 0 {
+			t.Fatal(diff)
+		}
+	})
+}
+
+func TestOverrideVariables(t *testing.T) {
+	variables := []providers.Variable{
+		{Name: "DEPLOY", Value: "false"},
+		{Name: "TARGET", Value: "staging"},
+	}
+	overrides := []providers.Variable{
+		{Name: "DEPLOY", Value: "true"},
+		{Name: "DEBUG", Value: "1"},
+	}
+
+	expected := []providers.Variable{
+		{Name: "DEPLOY", Value: "true"},
+		{Name: "TARGET", Value: "staging"},
+		{Name: "DEBUG", Value: "1"},
+	}
+	if diff := cmp.Diff(expected, overrideVariables(variables, overrides)); len(diff) > 0 {
+		t.Fatal(diff)
+	}
+	if variables[0].Value != "false" {
+		t.Fatal("the variables of the caller must not be modified")
+	}
+}
+
+type rerunProvider struct {
+	savedPipelinesProvider
+	variables [][]providers.Variable
+}
+
+func (p *rerunProvider) Rerun(ctx context.Context, pipeline providers.Pipeline, variables []providers.Variable) (string, error) {
+	p.variables = append(p.variables, variables)
+	return "https://example.com/pipelines/43", nil
+}
+
+func TestController_rerun(t *testing.T) {
+	controller, teardown, err := setup()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer teardown()
+
+	provider := &rerunProvider{}
+	controller.cache = providers.NewCache([]providers.CIProvider{provider}, nil, utils.PollingStrategy{})
+	conf := tui.TableConfiguration{
+		NodeStyle: providers.StepStyle{
+			GitStyle: providers.GitStyle{
+				Location: time.UTC,
+			},
+		},
+	}
+	table, err := tui.NewHierarchicalTable(conf, nil, 80, 20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	controller.table = &table
+
+	controller.cache.SaveCommit("master", providers.Commit{Sha: "sha"})
+	controller.setRef(providers.Ref{Name: "master"})
+	pipeline := providers.Pipeline{
+		Number:       "42",
+		ProviderHost: "example.com",
+		Step: providers.Step{
+			ID:        "42",
+			Type:      providers.StepPipeline,
+			Variables: []providers.Variable{{Name: "DEPLOY", Value: "true"}},
+		},
+	}
+	if _, err := controller.cache.SavePipeline("sha", pipeline); err != nil {
+		t.Fatal(err)
+	}
+	controller.refresh()
+
+	press := func(evs ...*tcell.EventKey) {
+		for _, ev := range evs {
+			if _, _, err := controller.process(context.Background(), ev); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	rerun := tcell.NewEventKey(tcell.KeyRune, 'R', tcell.ModNone)
+	enter := tcell.NewEventKey(tcell.KeyEnter, 0, tcell.ModNone)
+	status := func() string {
+		return controller.status.Content[0].String()
+	}
+
+	t.Run("the pipeline must not run again before the user confirms", func(t *testing.T) {
+		press(rerun, enter)
+		if len(provider.variables) > 0 {
+			t.Fatal("expected no call to the provider")
+		}
+		if s := status(); !strings.Contains(s, "(y/n)") {
+			t.Fatalf("expected confirmation request but got %q", s)
+		}
+	})
+
+	t.Run("any key other than y must cancel the rerun", func(t *testing.T) {
+		press(tcell.NewEventKey(tcell.KeyRune, 'n', tcell.ModNone))
+		if len(provider.variables) > 0 {
+			t.Fatal("expected no call to the provider")
+		}
+		if s := strings.TrimSpace(status()); s != "Rerun canceled" {
+			t.Fatalf("expected %q but got %q", "Rerun canceled", s)
+		}
+	})
+
+	t.Run("y must run the pipeline again", func(t *testing.T) {
+		press(rerun, enter, tcell.NewEventKey(tcell.KeyRune, 'y', tcell.ModNone))
+		expected := [][]providers.Variable{{{Name: "DEPLOY", Value: "true"}}}
+		if diff := cmp.Diff(expected, provider.variables); len(diff) > 0 {
+			t.Fatal(diff)
+		}
+	})
+}
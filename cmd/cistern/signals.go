@@ -0,0 +1,40 @@
+package main
+
+import "os"
+
+func containsSignal(signals []os.Signal, s os.Signal) bool {
+	for _, other := range signals {
+		if other == s {
+			return true
+		}
+	}
+	return false
+}
+
+// Signals the controller reacts to while the user interface is running
+func handledSignals() []os.Signal {
+	signals := make([]os.Signal, 0)
+	signals = append(signals, terminationSignals...)
+	signals = append(signals, suspensionSignals...)
+	signals = append(signals, resumptionSignals...)
+	return signals
+}
+
+func isTerminationSignal(s os.Signal) bool { return containsSignal(terminationSignals, s) }
+
+func isSuspensionSignal(s os.Signal) bool { return containsSignal(suspensionSignals, s) }
+
+// Restore the terminal and stop the process until it is resumed, for example by the "fg"
+// command of the shell, then redraw the screen
+func (c *Controller) suspend() error {
+	if stopProcess == nil {
+		c.writeStatus("Suspending cistern is not supported on this platform")
+		return nil
+	}
+	if err := c.tui.Suspend(stopProcess); err != nil {
+		return err
+	}
+	c.draw()
+
+	return nil
+}
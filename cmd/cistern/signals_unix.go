@@ -2,11 +2,20 @@
 
 package main
 
+import "os"
 import "os/signal"
 import "syscall"
 
 func SetupSignalHandlers() {
 	signal.Ignore(syscall.SIGINT)
-	// FIXME Do not ignore SIGTSTP/SIGCONT
-	signal.Ignore(syscall.SIGTSTP)
+}
+
+var terminationSignals = []os.Signal{syscall.SIGTERM}
+var suspensionSignals = []os.Signal{syscall.SIGTSTP}
+var resumptionSignals = []os.Signal{syscall.SIGCONT}
+
+// Stop the process until it receives SIGCONT. SIGSTOP is used instead of SIGTSTP since
+// SIGTSTP is caught by cistern.
+var stopProcess = func() error {
+	return syscall.Kill(syscall.Getpid(), syscall.SIGSTOP)
 }
@@ -2,9 +2,17 @@
 
 package main
 
+import "os"
 import "os/signal"
 import "syscall"
 
 func SetupSignalHandlers() {
 	signal.Ignore(syscall.SIGINT)
 }
+
+var terminationSignals = []os.Signal{syscall.SIGTERM}
+var suspensionSignals = []os.Signal{}
+var resumptionSignals = []os.Signal{}
+
+// Processes cannot be suspended on Windows
+var stopProcess func() error
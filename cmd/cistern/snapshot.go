@@ -0,0 +1,53 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/nbedos/cistern/providers"
+)
+
+// Write a snapshot of the cache, logs excluded, to a new file of the log directory
+func (c *Controller) exportSnapshot(ctx context.Context) {
+	defer c.draw()
+	if err := os.MkdirAll(c.conf.LogDir, 0700); err != nil {
+		c.reportError(err)
+		return
+	}
+	name := fmt.Sprintf("snapshot-%s.json", c.clock.Now().Format("20060102-150405"))
+	path := filepath.Join(c.conf.LogDir, name)
+	if err := writeSnapshot(ctx, c.cache, path, false); err != nil {
+		c.reportError(fmt.Errorf("failed to export snapshot: %w", err))
+		return
+	}
+	c.writeStatus(fmt.Sprintf("Snapshot written to %s", path))
+}
+
+// Read the snapshot written by Cache.Export to the file at 'path'
+func readSnapshotFile(path string) (providers.Snapshot, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return providers.Snapshot{}, err
+	}
+	defer f.Close()
+	snapshot, err := providers.ReadSnapshot(f)
+	if err != nil {
+		return providers.Snapshot{}, fmt.Errorf("%s: %w", path, err)
+	}
+	return snapshot, nil
+}
+
+// Write a snapshot of the cache to the file at 'path', replacing it if it exists
+func writeSnapshot(ctx context.Context, cache providers.Cache, path string, logs bool) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := cache.Export(ctx, f, logs); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+)
+
+const StateFilename = "state.json"
+
+const defaultMuteDuration = 8 * time.Hour
+
+// Pipelines of repositories and git references matching the patterns of a mute rule are hidden
+// until the rule expires. Patterns may contain the wildcard "*" which matches any sequence of
+// characters, including "/".
+type muteRule struct {
+	Repository string    `json:"repository"`
+	Ref        string    `json:"ref"`
+	Until      time.Time `json:"until"`
+}
+
+func globMatch(pattern string, s string) bool {
+	expr := strings.Replace(regexp.QuoteMeta(pattern), `\*`, ".*", -1)
+	matched, err := regexp.MatchString("^"+expr+"$", s)
+	return err == nil && matched
+}
+
+func (r muteRule) matches(repository string, ref string, now time.Time) bool {
+	return now.Before(r.Until) && globMatch(r.Repository, repository) && globMatch(r.Ref, ref)
+}
+
+// State saved across executions of cistern
+type State struct {
+	Mutes []muteRule `json:"mutes"`
+}
+
+// Load state from the file at the location designated by path. A missing file results in an
+// empty state.
+func LoadState(path string) (State, error) {
+	var state State
+
+	bs, err := ioutil.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			err = nil
+		}
+		return state, err
+	}
+
+	err = json.Unmarshal(bs, &state)
+	return state, err
+}
+
+func (s State) Save(path string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+
+	bs, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(path, bs, 0600)
+}
+
+func (s State) isMuted(repository string, ref string, now time.Time) bool {
+	for _, rule := range s.Mutes {
+		if rule.matches(repository, ref, now) {
+			return true
+		}
+	}
+	return false
+}
+
+// Hide pipelines of references matching pattern in repository until the time designated by
+// until. Expired rules are discarded.
+func (s *State) mute(repository string, pattern string, until time.Time, now time.Time) {
+	mutes := make([]muteRule, 0, len(s.Mutes)+1)
+	for _, rule := range s.Mutes {
+		if now.Before(rule.Until) && (rule.Repository != repository || rule.Ref != pattern) {
+			mutes = append(mutes, rule)
+		}
+	}
+	s.Mutes = append(mutes, muteRule{
+		Repository: repository,
+		Ref:        pattern,
+		Until:      until,
+	})
+}
+
+// Remove all mute rules applying to repository and return the number of rules removed
+func (s *State) unmute(repository string, now time.Time) int {
+	removed := 0
+	mutes := make([]muteRule, 0, len(s.Mutes))
+	for _, rule := range s.Mutes {
+		switch {
+		case !now.Before(rule.Until):
+			// Discard expired rule
+		case globMatch(rule.Repository, repository):
+			removed++
+		default:
+			mutes = append(mutes, rule)
+		}
+	}
+	s.Mutes = mutes
+
+	return removed
+}
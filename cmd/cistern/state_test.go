@@ -0,0 +1,119 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestGlobMatch(t *testing.T) {
+	testCases := []struct {
+		pattern string
+		s       string
+		match   bool
+	}{
+		{"master", "master", true},
+		{"master", "master2", false},
+		{"dependabot/*", "dependabot/npm_and_yarn/lodash-4.17.19", true},
+		{"dependabot/*", "feature/dependabot", false},
+		{"*", "", true},
+		{"v1.*", "v1.0", true},
+		{"v1.*", "v100", false},
+	}
+
+	for _, testCase := range testCases {
+		if match := globMatch(testCase.pattern, testCase.s); match != testCase.match {
+			t.Errorf("globMatch(%q, %q): expected %v but got %v", testCase.pattern, testCase.s, testCase.match, match)
+		}
+	}
+}
+
+func TestState_mute(t *testing.T) {
+	now := time.Date(2020, 1, 30, 12, 0, 0, 0, time.UTC)
+	state := State{}
+
+	state.mute("github.com/owner/repo", "dependabot/*", now.Add(8*time.Hour), now)
+	state.mute("github.com/owner/other", "*", now.Add(time.Hour), now)
+
+	testCases := []struct {
+		name       string
+		repository string
+		ref        string
+		at         time.Time
+		muted      bool
+	}{
+		{"matching ref", "github.com/owner/repo", "dependabot/go_modules/x", now, true},
+		{"other ref", "github.com/owner/repo", "master", now, false},
+		{"other repository", "github.com/owner/fork", "dependabot/go_modules/x", now, false},
+		{"expired rule", "github.com/owner/other", "master", now.Add(2 * time.Hour), false},
+		{"active rule", "github.com/owner/other", "master", now.Add(30 * time.Minute), true},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			if muted := state.isMuted(testCase.repository, testCase.ref, testCase.at); muted != testCase.muted {
+				t.Fatalf("expected %v but got %v", testCase.muted, muted)
+			}
+		})
+	}
+
+	t.Run("muting again replaces the existing rule", func(t *testing.T) {
+		state := State{}
+		state.mute("repo", "master", now.Add(time.Hour), now)
+		state.mute("repo", "master", now.Add(2*time.Hour), now)
+		if len(state.Mutes) != 1 || !state.Mutes[0].Until.Equal(now.Add(2*time.Hour)) {
+			t.Fatalf("unexpected mute rules: %+v", state.Mutes)
+		}
+	})
+
+	t.Run("unmute", func(t *testing.T) {
+		later := now.Add(2 * time.Hour)
+		if n := state.unmute("github.com/owner/repo", later); n != 1 {
+			t.Fatalf("expected 1 rule removed but got %d", n)
+		}
+		// The rule of the other repository expired and must have been discarded too
+		if len(state.Mutes) != 0 {
+			t.Fatalf("expected no mute rule but got %+v", state.Mutes)
+		}
+	})
+}
+
+func TestState_Save(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cistern")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	filePath := path.Join(dir, "cistern", StateFilename)
+
+	t.Run("missing file", func(t *testing.T) {
+		state, err := LoadState(filePath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if diff := cmp.Diff(State{}, state); len(diff) > 0 {
+			t.Fatal(diff)
+		}
+	})
+
+	t.Run("round trip", func(t *testing.T) {
+		now := time.Date(2020, 1, 30, 12, 0, 0, 0, time.UTC)
+		state := State{}
+		state.mute("github.com/owner/repo", "dependabot/*", now.Add(8*time.Hour), now)
+		if err := state.Save(filePath); err != nil {
+			t.Fatal(err)
+		}
+
+		loaded, err := LoadState(filePath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if diff := cmp.Diff(state, loaded); len(diff) > 0 {
+			t.Fatal(diff)
+		}
+	})
+}
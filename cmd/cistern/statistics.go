@@ -0,0 +1,87 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/nbedos/cistern/providers"
+	"github.com/nbedos/cistern/tui"
+)
+
+// Return a size in bytes as shown to the user, e.g. "1.5 MB"
+func byteSize(n int) string {
+	const unit = 1000
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	size, exp := float64(n)/unit, 0
+	for ; size >= unit && exp < 3; exp++ {
+		size /= unit
+	}
+	return fmt.Sprintf("%.1f %cB", size, "kMGT"[exp])
+}
+
+// Return the lines of the view showing the activity of the cache and of each provider so
+// that users can tell why refreshes are slow or hitting rate limits
+func statisticsLines(stats providers.CacheStats, style providers.StepStyle, now time.Time, emphasis tui.StyleTransform) []tui.StyledString {
+	lines := []tui.StyledString{
+		tui.NewStyledString("STATISTICS", emphasis),
+		tui.NewStyledString(fmt.Sprintf("%d commits, %d pipelines and %s of logs in cache",
+			stats.Commits, stats.Pipelines, byteSize(stats.LogBytes))),
+	}
+
+	row := func(name string, value string) tui.StyledString {
+		line := tui.NewStyledString("   " + name)
+		line.Fit(tui.Left, 25)
+		line.Append(value)
+		return line
+	}
+
+	for _, p := range stats.Providers {
+		name := p.Name
+		if name != p.ID {
+			name = fmt.Sprintf("%s (%s)", p.Name, p.ID)
+		}
+		lines = append(lines, tui.StyledString{}, tui.NewStyledString(name, emphasis))
+
+		requests, rateLimit := "not counted", "unknown"
+		if p.Instrumented {
+			requests = fmt.Sprintf("%d (%d failed", p.Requests.Requests, p.Requests.Errors)
+			if p.Requests.Requests > 0 {
+				average := p.Requests.Duration / time.Duration(p.Requests.Requests)
+				requests += fmt.Sprintf(", %s on average", average.Round(time.Millisecond))
+			}
+			requests += ")"
+		}
+		if limit := p.Requests.RateLimit; limit.Valid {
+			rateLimit = fmt.Sprintf("%d", limit.Remaining)
+			if limit.Limit > 0 {
+				rateLimit += fmt.Sprintf("/%d", limit.Limit)
+			}
+			rateLimit += " requests left"
+			if limit.Reset.Valid {
+				rateLimit += fmt.Sprintf(", reset at %s", limit.Reset.Time.In(style.Location).Format("15:04:05"))
+			}
+		}
+		lines = append(lines, row("API requests", requests), row("Rate limit", rateLimit))
+
+		polls := fmt.Sprintf("%d (%d failed)", p.Polls, p.PollErrors)
+		lastPoll := "never"
+		if p.LastPoll.Valid {
+			ago := now.Sub(p.LastPoll.Time).Truncate(time.Second)
+			lastPoll = fmt.Sprintf("took %s, %s ago", p.LastPollDuration.Duration.Round(time.Millisecond), ago)
+		}
+		lines = append(lines,
+			row("Pipeline polls", polls),
+			row("Last poll", lastPoll),
+			row("Pipelines in cache", fmt.Sprintf("%d", p.Pipelines)),
+			row("Logs in memory", byteSize(p.LogBytes)))
+	}
+
+	return lines
+}
+
+// Write the statistics of the cache and its providers, rewritten each time they are drawn
+func (c *Controller) writeStats() {
+	c.stats.WriteContent(statisticsLines(c.cache.Stats(), c.conf.StepStyle, c.clock.Now(), bold)...)
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gdamore/tcell"
+	"github.com/nbedos/cistern/providers"
+	"github.com/nbedos/cistern/utils"
+)
+
+func TestByteSize(t *testing.T) {
+	for n, expected := range map[int]string{
+		0:          "0 B",
+		999:        "999 B",
+		1500:       "1.5 kB",
+		2300000:    "2.3 MB",
+		4000000000: "4.0 GB",
+	} {
+		if s := byteSize(n); s != expected {
+			t.Errorf("expected %q for %d but got %q", expected, n, s)
+		}
+	}
+}
+
+func TestStatisticsLines(t *testing.T) {
+	identity := func(s tcell.Style) tcell.Style { return s }
+	now := time.Date(2020, 1, 30, 12, 0, 0, 0, time.UTC)
+	stats := providers.CacheStats{
+		Providers: []providers.ProviderStats{
+			{
+				ID:           "gitlab-com",
+				Name:         "gitlab",
+				Instrumented: true,
+				Requests: providers.RequestStats{
+					Requests: 4,
+					Errors:   1,
+					Duration: 2 * time.Second,
+					RateLimit: providers.RateLimit{
+						Valid:     true,
+						Limit:     600,
+						Remaining: 596,
+						Reset:     utils.NullTime{Valid: true, Time: now.Add(time.Minute)},
+					},
+				},
+				Polls:            3,
+				LastPoll:         utils.NullTime{Valid: true, Time: now.Add(-10 * time.Second)},
+				LastPollDuration: utils.NullDuration{Valid: true, Duration: 1200 * time.Millisecond},
+				Pipelines:        2,
+				LogBytes:         1500,
+			},
+			{
+				ID:   "local",
+				Name: "local",
+			},
+		},
+		Commits:   1,
+		Pipelines: 2,
+		LogBytes:  1500,
+	}
+
+	lines := statisticsLines(stats, providers.StepStyle{GitStyle: providers.GitStyle{Location: time.UTC}}, now, identity)
+	content := make([]string, 0)
+	for _, line := range lines {
+		content = append(content, strings.TrimRight(line.String(), " "))
+	}
+
+	expected := []string{
+		"STATISTICS",
+		"1 commits, 2 pipelines and 1.5 kB of logs in cache",
+		"",
+		"gitlab (gitlab-com)",
+		"   API requests          4 (1 failed, 500ms on average)",
+		"   Rate limit            596/600 requests left, reset at 12:01:00",
+		"   Pipeline polls        3 (0 failed)",
+		"   Last poll             took 1.2s, 10s ago",
+		"   Pipelines in cache    2",
+		"   Logs in memory        1.5 kB",
+		"",
+		"local",
+		"   API requests          not counted",
+		"   Rate limit            unknown",
+		"   Pipeline polls        0 (0 failed)",
+		"   Last poll             never",
+		"   Pipelines in cache    0",
+		"   Logs in memory        0 B",
+	}
+	if strings.Join(content, "\n") != strings.Join(expected, "\n") {
+		t.Fatalf("expected:\n%s\nbut got:\n%s", strings.Join(expected, "\n"), strings.Join(content, "\n"))
+	}
+}
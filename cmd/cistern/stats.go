@@ -0,0 +1,278 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/nbedos/cistern/providers"
+)
+
+const statsUsage = `usage: cistern stats [--job NAME] [--since DURATION] [--output csv|summary]
+
+Export the duration and the outcome of the jobs of the pipelines saved in the
+cache directory (see the "cache" section of the configuration file)
+
+Options:
+  --job NAME    Only export jobs named NAME. By default all jobs are exported.
+
+  --since DURATION
+                Only export jobs started less than DURATION ago, for example
+                "30d", "12h" or "90m". By default all jobs are exported.
+
+  --output FORMAT
+                Output format: "csv" for one line per job run, "summary"
+                for one line per job name followed by the total of all
+                jobs. The estimated cost of jobs is computed according to
+                the "costs" section of the configuration file.
+                Default: csv`
+
+var statsHeader = []string{"started", "provider", "pipeline", "ref", "sha", "job", "state", "duration", "cost"}
+
+var summaryHeader = []string{"job", "runs", "failures", "duration", "mean_duration", "cost"}
+
+type jobRecord struct {
+	startedAt time.Time
+	provider  string
+	pipeline  string
+	ref       string
+	sha       string
+	job       string
+	state     providers.State
+	duration  time.Duration
+	cost      float64
+	costValid bool
+}
+
+func formatCost(cost float64, valid bool) string {
+	if !valid {
+		return ""
+	}
+	return strconv.FormatFloat(cost, 'f', 2, 64)
+}
+
+func formatSeconds(d time.Duration) string {
+	return strconv.FormatFloat(d.Seconds(), 'f', -1, 64)
+}
+
+func (r jobRecord) csv() []string {
+	return []string{
+		r.startedAt.UTC().Format(time.RFC3339),
+		r.provider,
+		r.pipeline,
+		r.ref,
+		r.sha,
+		r.job,
+		string(r.state),
+		formatSeconds(r.duration),
+		formatCost(r.cost, r.costValid),
+	}
+}
+
+// Parse a duration such as "30d", "12h" or "90m"
+func parseSince(s string) (time.Duration, error) {
+	if strings.HasSuffix(s, "d") {
+		days, err := strconv.Atoi(strings.TrimSuffix(s, "d"))
+		if err != nil || days < 0 {
+			return 0, fmt.Errorf("invalid duration: %q", s)
+		}
+		return time.Duration(days) * 24 * time.Hour, nil
+	}
+
+	d, err := time.ParseDuration(s)
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("invalid duration: %q", s)
+	}
+	return d, nil
+}
+
+// Return the finished jobs of the pipelines named 'job' (or all jobs if 'job' is empty) started
+// after 'since', in chronological order
+func jobRecords(pipelines []providers.Pipeline, job string, since time.Time, costs providers.CostModel) []jobRecord {
+	records := make([]jobRecord, 0)
+	for _, pipeline := range pipelines {
+		var traverse func(step providers.Step)
+		traverse = func(step providers.Step) {
+			for _, child := range step.Children {
+				traverse(child)
+			}
+			if step.Type != providers.StepJob || (job != "" && step.Name != job) {
+				return
+			}
+			if step.State.IsActive() || !step.StartedAt.Valid || step.StartedAt.Time.Before(since) {
+				return
+			}
+			var duration time.Duration
+			switch {
+			case step.Duration.Valid:
+				duration = step.Duration.Duration
+			case step.FinishedAt.Valid:
+				duration = step.FinishedAt.Time.Sub(step.StartedAt.Time)
+			default:
+				return
+			}
+			cost, costValid := costs.JobCost(pipeline, step)
+			records = append(records, jobRecord{
+				startedAt: step.StartedAt.Time,
+				provider:  pipeline.ProviderName,
+				pipeline:  pipelineTitle(pipeline),
+				ref:       pipeline.Ref,
+				sha:       pipeline.Sha,
+				job:       step.Name,
+				state:     step.State,
+				duration:  duration,
+				cost:      cost,
+				costValid: costValid,
+			})
+		}
+		traverse(pipeline.Step)
+	}
+
+	sort.SliceStable(records, func(i, j int) bool {
+		return records[i].startedAt.Before(records[j].startedAt)
+	})
+
+	return records
+}
+
+// Run the "stats" subcommand with the arguments 'args' and write the result to 'w'
+func Stats(w io.Writer, args []string, conf Configuration, now time.Time) error {
+	f := flag.NewFlagSet("stats", flag.ContinueOnError)
+	f.SetOutput(bytes.NewBuffer(nil))
+	jobFlag := f.String("job", "", "")
+	sinceFlag := f.String("since", "", "")
+	outputFlag := f.String("output", "csv", "")
+	helpFlag := f.Bool("help", false, "")
+	helpFlagShort := f.Bool("h", false, "")
+	if err := f.Parse(args); err != nil {
+		return fmt.Errorf("%s\n%s", err.Error(), statsUsage)
+	}
+
+	if *helpFlag || *helpFlagShort {
+		_, err := fmt.Fprintln(w, statsUsage)
+		return err
+	}
+	if f.NArg() > 0 {
+		return fmt.Errorf("unexpected argument: %q\n%s", f.Arg(0), statsUsage)
+	}
+	if *outputFlag != "csv" && *outputFlag != "summary" {
+		return fmt.Errorf("unsupported output format: %q\n%s", *outputFlag, statsUsage)
+	}
+
+	var since time.Time
+	if *sinceFlag != "" {
+		d, err := parseSince(*sinceFlag)
+		if err != nil {
+			return err
+		}
+		since = now.Add(-d)
+	}
+
+	if !conf.Cache.Enabled {
+		return errors.New("the cache is disabled so no pipeline has been saved (see the \"cache\" section of the configuration file)")
+	}
+	dirs, err := conf.CacheDirectories()
+	if err != nil {
+		return err
+	}
+	pipelines := make([]providers.Pipeline, 0)
+	saved := make(map[providers.PipelineKey]struct{})
+	for _, dir := range dirs {
+		stored, err := providers.StoredPipelines(dir)
+		if err != nil {
+			return err
+		}
+		// A pipeline may be saved in the directories of several repositories
+		for _, p := range stored {
+			if _, exists := saved[p.Key()]; !exists {
+				saved[p.Key()] = struct{}{}
+				pipelines = append(pipelines, p)
+			}
+		}
+	}
+
+	records := jobRecords(pipelines, *jobFlag, since, conf.CostModel())
+	lines := [][]string{statsHeader}
+	if *outputFlag == "summary" {
+		lines = summary(records)
+	} else {
+		for _, record := range records {
+			lines = append(lines, record.csv())
+		}
+	}
+
+	writer := csv.NewWriter(w)
+	if err := writer.WriteAll(lines); err != nil {
+		return err
+	}
+
+	return writer.Error()
+}
+
+type jobSummary struct {
+	name      string
+	runs      int
+	failures  int
+	duration  time.Duration
+	cost      float64
+	costValid bool
+}
+
+func (s *jobSummary) add(r jobRecord) {
+	s.runs++
+	if r.state == providers.Failed {
+		s.failures++
+	}
+	s.duration += r.duration
+	if r.costValid {
+		s.cost += r.cost
+		s.costValid = true
+	}
+}
+
+func (s jobSummary) csv() []string {
+	mean := time.Duration(0)
+	if s.runs > 0 {
+		mean = s.duration / time.Duration(s.runs)
+	}
+	return []string{
+		s.name,
+		strconv.Itoa(s.runs),
+		strconv.Itoa(s.failures),
+		formatSeconds(s.duration),
+		formatSeconds(mean),
+		formatCost(s.cost, s.costValid),
+	}
+}
+
+// Aggregate records by job name, in alphabetical order, followed by the total of all jobs
+func summary(records []jobRecord) [][]string {
+	byName := make(map[string]*jobSummary)
+	names := make([]string, 0)
+	total := jobSummary{name: "total"}
+	for _, record := range records {
+		s, exists := byName[record.job]
+		if !exists {
+			s = &jobSummary{name: record.job}
+			byName[record.job] = s
+			names = append(names, record.job)
+		}
+		s.add(record)
+		total.add(record)
+	}
+	sort.Strings(names)
+
+	lines := [][]string{summaryHeader}
+	for _, name := range names {
+		lines = append(lines, byName[name].csv())
+	}
+
+	return append(lines, total.csv())
+}
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/nbedos/cistern/providers"
+	"github.com/nbedos/cistern/utils"
+)
+
+func TestParseSince(t *testing.T) {
+	testCases := []struct {
+		input    string
+		duration time.Duration
+		valid    bool
+	}{
+		{"30d", 30 * 24 * time.Hour, true},
+		{"12h", 12 * time.Hour, true},
+		{"90m", 90 * time.Minute, true},
+		{"-1d", 0, false},
+		{"d", 0, false},
+		{"30", 0, false},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.input, func(t *testing.T) {
+			d, err := parseSince(testCase.input)
+			if (err == nil) != testCase.valid {
+				t.Fatalf("unexpected error value: %v", err)
+			}
+			if d != testCase.duration {
+				t.Fatalf("expected %v but got %v", testCase.duration, d)
+			}
+		})
+	}
+}
+
+func statsJob(name string, state providers.State, start time.Time, d time.Duration) providers.Step {
+	return providers.Step{
+		ID:         name,
+		Name:       name,
+		Type:       providers.StepJob,
+		State:      state,
+		StartedAt:  utils.NullTime{Valid: true, Time: start},
+		FinishedAt: utils.NullTime{Valid: true, Time: start.Add(d)},
+	}
+}
+
+func TestJobRecords(t *testing.T) {
+	t0 := time.Date(2020, 1, 30, 12, 0, 0, 0, time.UTC)
+	pipelines := []providers.Pipeline{
+		{
+			Number:       "2",
+			ProviderName: "gitlab",
+			Ref:          "master",
+			Sha:          "sha2",
+			Step: providers.Step{
+				ID: "2",
+				Children: []providers.Step{
+					statsJob("build", providers.Failed, t0.Add(24*time.Hour), time.Minute),
+					statsJob("test", providers.Passed, t0.Add(24*time.Hour), 2*time.Minute),
+					{ID: "3", Name: "build", Type: providers.StepJob, State: providers.Running},
+				},
+			},
+		},
+		{
+			Number:       "1",
+			ProviderName: "gitlab",
+			Ref:          "master",
+			Sha:          "sha1",
+			Step: providers.Step{
+				ID: "1",
+				Children: []providers.Step{
+					statsJob("build", providers.Passed, t0, 90*time.Second),
+				},
+			},
+		},
+	}
+
+	records := make([][]string, 0)
+	for _, record := range jobRecords(pipelines, "build", time.Time{}, providers.CostModel{}) {
+		records = append(records, record.csv())
+	}
+	expected := [][]string{
+		{"2020-01-30T12:00:00Z", "gitlab", "gitlab #1", "master", "sha1", "build", "passed", "90", ""},
+		{"2020-01-31T12:00:00Z", "gitlab", "gitlab #2", "master", "sha2", "build", "failed", "60", ""},
+	}
+	if diff := cmp.Diff(expected, records); len(diff) > 0 {
+		t.Fatal(diff)
+	}
+
+	if records := jobRecords(pipelines, "", t0.Add(time.Hour), providers.CostModel{}); len(records) != 2 {
+		t.Fatalf("expected 2 records but got %d", len(records))
+	}
+}
+
+func TestSummary(t *testing.T) {
+	records := []jobRecord{
+		{job: "test", state: providers.Passed, duration: time.Minute, cost: 0.5, costValid: true},
+		{job: "build", state: providers.Failed, duration: 2 * time.Minute},
+		{job: "test", state: providers.Failed, duration: 3 * time.Minute, cost: 1.5, costValid: true},
+	}
+
+	expected := [][]string{
+		summaryHeader,
+		{"build", "1", "1", "120", "120", ""},
+		{"test", "2", "1", "240", "120", "2.00"},
+		{"total", "3", "2", "360", "120", "2.00"},
+	}
+	if diff := cmp.Diff(expected, summary(records)); len(diff) > 0 {
+		t.Fatal(diff)
+	}
+}
+
+func TestStats(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cistern")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	conf := Configuration{}
+	conf.Cache.Enabled = true
+	conf.Cache.Directory = dir
+
+	t.Run("empty cache", func(t *testing.T) {
+		buf := bytes.Buffer{}
+		if err := Stats(&buf, []string{"--job", "build", "--since", "30d"}, conf, time.Now()); err != nil {
+			t.Fatal(err)
+		}
+		if expected := strings.Join(statsHeader, ",") + "\n"; buf.String() != expected {
+			t.Fatalf("expected %q but got %q", expected, buf.String())
+		}
+	})
+
+	t.Run("unsupported output format", func(t *testing.T) {
+		if err := Stats(ioutil.Discard, []string{"--output", "json"}, conf, time.Now()); err == nil {
+			t.Fatal("expected error but got nil")
+		}
+	})
+
+	t.Run("disabled cache", func(t *testing.T) {
+		if err := Stats(ioutil.Discard, nil, Configuration{}, time.Now()); err == nil {
+			t.Fatal("expected error but got nil")
+		}
+	})
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/gdamore/tcell"
+	"github.com/nbedos/cistern/providers"
+	"github.com/nbedos/cistern/tui"
+	"github.com/nbedos/cistern/utils"
+)
+
+// Interval between two refreshes of the elapsed times shown on screen
+const statusBarRefreshInterval = time.Second
+
+// Return the content of the status bar: the repository monitored, the time elapsed since
+// the last successful poll of a pipeline, the providers still polling pipelines and the
+// number of errors reported so far, so that users can tell stale data from a quiet
+// repository
+func statusBar(repository string, stats providers.CacheStats, errors int, errorsKey string, now time.Time) string {
+	fields := make([]string, 0, 4)
+	if repository != "" {
+		fields = append(fields, repository)
+	}
+
+	successes := make([]utils.NullTime, 0, len(stats.Providers))
+	polling := make([]string, 0)
+	for _, p := range stats.Providers {
+		successes = append(successes, p.LastSuccess)
+		if p.Polling {
+			polling = append(polling, p.Name)
+		}
+	}
+	if lastSuccess := utils.MaxNullTime(successes...); lastSuccess.Valid {
+		ago := now.Sub(lastSuccess.Time).Truncate(time.Second)
+		if ago < 0 {
+			ago = 0
+		}
+		fields = append(fields, fmt.Sprintf("updated %s ago", ago))
+	} else {
+		fields = append(fields, "no update yet")
+	}
+	if len(polling) > 0 {
+		fields = append(fields, "fetching from "+strings.Join(polling, ", "))
+	}
+
+	if errors > 0 {
+		s := fmt.Sprintf("%d errors", errors)
+		if errors == 1 {
+			s = "1 error"
+		}
+		if errorsKey != "" {
+			s += fmt.Sprintf(" (press %s)", errorsKey)
+		}
+		fields = append(fields, s)
+	}
+
+	return strings.Join(fields, " | ")
+}
+
+// Return the position of the cursor among the 'total' rows matching the search, 'index'
+// being 0 if the cursor is not on a matching row
+func searchStatus(index int, total int) string {
+	switch {
+	case total == 0:
+		return "no match"
+	case index == 0 && total == 1:
+		return "1 match"
+	case index == 0:
+		return fmt.Sprintf("%d matches", total)
+	default:
+		return fmt.Sprintf("match %d of %d", index, total)
+	}
+}
+
+// Write the persistent status bar shown below the table
+func (c *Controller) writeStatusBar() {
+	var s string
+	if c.conf.Replay {
+		s = fmt.Sprintf("%s | replay of a snapshot", c.repository)
+	} else {
+		s = statusBar(c.repository, c.cache.Stats(), len(c.console.entries), c.conf.Keys.key("errors"), c.clock.Now())
+	}
+	if !c.tableSearch.Empty() {
+		s += " | " + searchStatus(c.table.MatchPosition())
+	}
+	bar := tui.NewStyledString(s)
+	bar.Fit(tui.Left, c.width)
+	bar.Apply(func(s tcell.Style) tcell.Style {
+		return s.Reverse(true)
+	})
+	c.statusBar.WriteContent(bar)
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/nbedos/cistern/providers"
+	"github.com/nbedos/cistern/utils"
+)
+
+func TestStatusBar(t *testing.T) {
+	now := time.Date(2020, 1, 30, 12, 0, 0, 0, time.UTC)
+	at := func(d time.Duration) utils.NullTime {
+		return utils.NullTime{Valid: true, Time: now.Add(d)}
+	}
+
+	testCases := []struct {
+		name     string
+		stats    providers.CacheStats
+		errors   int
+		expected string
+	}{
+		{
+			name:     "no poll",
+			stats:    providers.CacheStats{Providers: []providers.ProviderStats{{Name: "gitlab"}}},
+			expected: "nbedos/cistern | no update yet",
+		},
+		{
+			name: "providers polling",
+			stats: providers.CacheStats{
+				Providers: []providers.ProviderStats{
+					{Name: "gitlab", LastSuccess: at(-time.Minute), Polling: true},
+					{Name: "travis", LastSuccess: at(-12*time.Second - 300*time.Millisecond)},
+					{Name: "azure", Polling: true},
+				},
+			},
+			expected: "nbedos/cistern | updated 12s ago | fetching from gitlab, azure",
+		},
+		{
+			name:     "errors",
+			stats:    providers.CacheStats{Providers: []providers.ProviderStats{{Name: "gitlab", LastSuccess: at(-2 * time.Hour)}}},
+			errors:   3,
+			expected: "nbedos/cistern | updated 2h0m0s ago | 3 errors (press E)",
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			s := statusBar("nbedos/cistern", testCase.stats, testCase.errors, "E", now)
+			if s != testCase.expected {
+				t.Fatalf("expected %q but got %q", testCase.expected, s)
+			}
+		})
+	}
+}
+
+func TestSearchStatus(t *testing.T) {
+	for expected, position := range map[string][2]int{
+		"no match":     {0, 0},
+		"1 match":      {0, 1},
+		"3 matches":    {0, 3},
+		"match 2 of 3": {2, 3},
+	} {
+		if s := searchStatus(position[0], position[1]); s != expected {
+			t.Errorf("expected %q but got %q", expected, s)
+		}
+	}
+}
@@ -0,0 +1,187 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/gdamore/tcell"
+	"github.com/nbedos/cistern/providers"
+	"github.com/nbedos/cistern/tui"
+)
+
+// Repositories monitored at the same time, each one by its own controller shown in a tab.
+// Events of the terminal are only sent to the controller of the active tab, which is also the
+// only one drawing on the screen.
+type tabs struct {
+	mutex sync.Mutex
+	// Name of the repository of each tab
+	names  []string
+	active int
+	// Events of the terminal sent to the controller of each tab
+	eventcs []chan tcell.Event
+	// Signaled when the active tab changes
+	switchc chan struct{}
+}
+
+func newTabs(names []string) *tabs {
+	t := tabs{
+		names:   make([]string, len(names)),
+		eventcs: make([]chan tcell.Event, len(names)),
+		switchc: make(chan struct{}, 1),
+	}
+	copy(t.names, names)
+	for i := range t.eventcs {
+		t.eventcs[i] = make(chan tcell.Event)
+	}
+
+	return &t
+}
+
+// Name the tab 'i' after the repository it shows
+func (t *tabs) rename(i int, name string) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	t.names[i] = name
+}
+
+func (t *tabs) isActive(i int) bool {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	return t.active == i
+}
+
+// Activate the tab 'n' positions after the active one, or before it if 'n' is negative,
+// cycling back to the first tab after the last one
+func (t *tabs) cycle(n int) {
+	t.mutex.Lock()
+	t.active = ((t.active+n)%len(t.names) + len(t.names)) % len(t.names)
+	t.mutex.Unlock()
+
+	select {
+	case t.switchc <- struct{}{}:
+	default:
+		// A switch is already pending
+	}
+}
+
+// Send the events received on 'eventc' to the controller of the active tab until the context
+// is canceled. The controller of a tab that becomes active receives a resize event since it
+// must lay out its widgets and draw the screen again.
+func (t *tabs) dispatch(ctx context.Context, eventc <-chan tcell.Event, size func() (int, int)) {
+	for {
+		var event tcell.Event
+		select {
+		case event = <-eventc:
+		case <-t.switchc:
+			event = tcell.NewEventResize(size())
+		case <-ctx.Done():
+			return
+		}
+
+		t.mutex.Lock()
+		active := t.eventcs[t.active]
+		t.mutex.Unlock()
+		select {
+		case active <- event:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// Show the repository of the next tab, or of the previous one, cycling through tabs
+func (c *Controller) switchTab(next bool) {
+	if c.tabs == nil {
+		c.writeStatus("Only one repository is monitored (use --repository several times to monitor more)")
+		return
+	}
+	if next {
+		c.tabs.cycle(1)
+	} else {
+		c.tabs.cycle(-1)
+	}
+}
+
+// Write the name of the repository of each tab to the tab bar, the active tab in bold and the
+// others in reverse video. The caller must hold the lock of the tabs.
+func (c *Controller) writeTabBar() {
+	reverse := func(s tcell.Style) tcell.Style { return s.Reverse(true) }
+	bar := tui.NewStyledString("")
+	for i, name := range c.tabs.names {
+		label := fmt.Sprintf(" %d %s ", i+1, name)
+		if i == c.tabs.active {
+			bar.Append(label, bold)
+		} else {
+			bar.Append(label, reverse)
+		}
+	}
+	bar.Fit(tui.Left, c.width)
+	c.tabBar.WriteContent(bar)
+}
+
+// Return a cache for each repository of 'repos': 'base' for the first one and empty copies of
+// it for the others so that the providers of 'base' are set up only once. If persistence is
+// enabled, the cache of a repository monitored along with other ones is saved to a directory
+// of its own so that the caches of tabs neither overwrite nor evict the files of each other.
+func repositoryCaches(conf Configuration, repos []string, base providers.Cache) ([]providers.Cache, error) {
+	base.SetEvictionPolicy(conf.EvictionPolicy())
+	caches := make([]providers.Cache, 0, len(repos))
+	for i, repo := range repos {
+		cacheDB := base
+		if i > 0 {
+			cacheDB = base.EmptyCopy()
+		}
+		if conf.Cache.Enabled {
+			dir := conf.CacheDirectory()
+			if len(repos) > 1 {
+				dir = conf.RepositoryCacheDirectory(repo)
+			}
+			if err := cacheDB.Persist(dir, conf.CacheMaxAge()); err != nil {
+				return nil, err
+			}
+		}
+		caches = append(caches, cacheDB)
+	}
+
+	return caches, nil
+}
+
+// Monitor each repository of 'repos' with its own controller and cache, shown in a tab of
+// its own. The first controller to return, for example because the user asked to quit, stops
+// the others.
+func runTabs(ctx context.Context, ui *tui.TUI, conf ApplicationConfiguration, caches []providers.Cache, repos []string, ref string) error {
+	t := newTabs(repos)
+	controllers := make([]Controller, 0, len(repos))
+	for i := range repos {
+		controller, err := NewController(ui, conf, caches[i])
+		if err != nil {
+			return err
+		}
+		controller.tabs = t
+		controller.tab = i
+		controller.eventc = t.eventcs[i]
+		controllers = append(controllers, controller)
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go t.dispatch(ctx, ui.Eventc, func() (int, int) { return ui.Size() })
+
+	errc := make(chan error)
+	for i := range controllers {
+		go func(c *Controller, repo string) {
+			errc <- c.Run(ctx, repo, ref)
+		}(&controllers[i], repos[i])
+	}
+
+	var err error
+	for range controllers {
+		if e := <-errc; e != nil && e != context.Canceled && err == nil {
+			err = e
+		}
+		cancel()
+	}
+
+	return err
+}
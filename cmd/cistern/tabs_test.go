@@ -0,0 +1,139 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/gdamore/tcell"
+	"github.com/nbedos/cistern/providers"
+	"github.com/nbedos/cistern/utils"
+)
+
+func TestTabs_cycle(t *testing.T) {
+	tabs := newTabs([]string{"a", "b", "c"})
+	for _, step := range []struct {
+		n      int
+		active int
+	}{
+		{n: 1, active: 1},
+		{n: 1, active: 2},
+		{n: 1, active: 0},
+		{n: -1, active: 2},
+		{n: -4, active: 1},
+	} {
+		tabs.cycle(step.n)
+		if !tabs.isActive(step.active) {
+			t.Fatalf("expected tab %d to be active after cycling by %d but got tab %d", step.active, step.n, tabs.active)
+		}
+	}
+}
+
+func TestTabs_dispatch(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	tabs := newTabs([]string{"a", "b"})
+	eventc := make(chan tcell.Event)
+	go tabs.dispatch(ctx, eventc, func() (int, int) { return 80, 24 })
+
+	key := tcell.NewEventKey(tcell.KeyRune, 'j', tcell.ModNone)
+	eventc <- key
+	if event := <-tabs.eventcs[0]; event != key {
+		t.Fatalf("expected %v to be sent to the first tab but got %v", key, event)
+	}
+
+	tabs.cycle(1)
+	event, ok := (<-tabs.eventcs[1]).(*tcell.EventResize)
+	if !ok {
+		t.Fatalf("expected a resize event to be sent to the tab becoming active but got %v", event)
+	}
+	if width, height := event.Size(); width != 80 || height != 24 {
+		t.Fatalf("expected size (80, 24) but got (%d, %d)", width, height)
+	}
+
+	eventc <- key
+	if event := <-tabs.eventcs[1]; event != key {
+		t.Fatalf("expected %v to be sent to the second tab but got %v", key, event)
+	}
+}
+
+// CI provider of the pipelines saved by tests. Pipelines saved by tests have no provider ID
+// since it is set by the cache when fetching pipelines, hence the empty ID.
+type savedPipelinesProvider struct{}
+
+func (savedPipelinesProvider) ID() string   { return "" }
+func (savedPipelinesProvider) Host() string { return "example.com" }
+func (savedPipelinesProvider) Name() string { return "example" }
+func (savedPipelinesProvider) Log(ctx context.Context, step providers.Step) (string, error) {
+	return "", nil
+}
+func (savedPipelinesProvider) BuildFromURL(ctx context.Context, u string) (providers.Pipeline, error) {
+	return providers.Pipeline{}, providers.ErrUnknownPipelineURL
+}
+
+func TestRepositoryCaches(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cistern")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	conf := Configuration{}
+	conf.Cache.Enabled = true
+	conf.Cache.Directory = dir
+	repos := []string{"github.com/owner/first", "github.com/owner/second"}
+	newCache := func() providers.Cache {
+		ps := []providers.CIProvider{savedPipelinesProvider{}}
+		return providers.NewCache(ps, nil, utils.PollingStrategy{})
+	}
+
+	caches, err := repositoryCaches(conf, repos, newCache())
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Both repositories have a branch named master
+	for i, c := range caches {
+		sha := repos[i]
+		c.SaveCommit("master", providers.Commit{Sha: sha})
+		pipeline := providers.Pipeline{
+			Number:       repos[i],
+			ProviderHost: "example.com",
+			Step:         providers.Step{ID: repos[i], Type: providers.StepPipeline},
+		}
+		if _, err := c.SavePipeline(sha, pipeline); err != nil {
+			t.Fatal(err)
+		}
+		if err := c.Flush(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Pipelines saved by tests have no provider ID so they are not restored by Persist. Check
+	// instead that the pipeline of each repository was written to the directory of its tab.
+	for i, repo := range repos {
+		paths, err := filepath.Glob(filepath.Join(conf.RepositoryCacheDirectory(repo), "cistern-pipeline-*.json"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(paths) != 1 {
+			t.Fatalf("expected a single pipeline file for tab %d but got %v", i, paths)
+		}
+		bs, err := ioutil.ReadFile(paths[0])
+		if err != nil {
+			t.Fatal(err)
+		}
+		var stored struct {
+			Pipeline providers.Pipeline `json:"pipeline"`
+		}
+		if err := json.Unmarshal(bs, &stored); err != nil {
+			t.Fatal(err)
+		}
+		if stored.Pipeline.ID != repo {
+			t.Fatalf("expected pipeline %q to be saved for tab %d but got %q", repo, i, stored.Pipeline.ID)
+		}
+	}
+}
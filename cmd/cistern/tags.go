@@ -0,0 +1,32 @@
+package main
+
+import (
+	"github.com/nbedos/cistern/providers"
+	"github.com/nbedos/cistern/tui"
+)
+
+// State of the view of the pipelines of tags
+type tagView struct {
+	// True if only pipelines of tags are shown, whatever their commit, sorted by tag
+	onlyTags bool
+	// Order of the table to restore when leaving the view of tags
+	orderBeforeTags tui.Order
+}
+
+// Toggle between showing the pipelines of the commit and the pipelines of all tags found in
+// the cache, latest tag first
+func (c *Controller) toggleOnlyTags() {
+	c.onlyTags = !c.onlyTags
+	if c.onlyTags {
+		c.orderBeforeTags = c.table.Order()
+		c.table.SetOrder(tui.Order{Valid: true, ID: providers.ColumnRef, Ascending: false})
+	} else {
+		c.table.SetOrder(c.orderBeforeTags)
+	}
+	c.refresh()
+	if c.onlyTags {
+		c.writeStatus("Showing only pipelines of tags")
+	} else {
+		c.writeStatus("Showing pipelines of the commit")
+	}
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/gdamore/tcell"
+	"github.com/google/go-cmp/cmp"
+	"github.com/nbedos/cistern/providers"
+	"github.com/nbedos/cistern/tui"
+)
+
+func TestController_toggleOnlyTags(t *testing.T) {
+	controller, teardown, err := setup()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer teardown()
+
+	conf := tui.TableConfiguration{
+		NodeStyle: providers.StepStyle{
+			GitStyle: providers.GitStyle{
+				Location: time.UTC,
+			},
+		},
+	}
+	table, err := tui.NewHierarchicalTable(conf, nil, 80, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	controller.table = &table
+
+	controller.cache.SaveCommit("master", providers.Commit{Sha: "sha"})
+	saved := []struct {
+		sha   string
+		ref   string
+		isTag bool
+	}{
+		{sha: "sha", ref: "master"},
+		{sha: "previous", ref: "v1.9.0", isTag: true},
+		{sha: "sha", ref: "v1.10.0", isTag: true},
+	}
+	for i, s := range saved {
+		pipeline := providers.Pipeline{
+			Number:       strconv.Itoa(i),
+			ProviderHost: "gitlab.com",
+			ProviderName: "gitlab",
+			Ref:          s.ref,
+			IsTag:        s.isTag,
+			Step: providers.Step{
+				ID:   strconv.Itoa(i),
+				Type: providers.StepPipeline,
+			},
+		}
+		if _, err := controller.cache.SavePipeline(s.sha, pipeline); err != nil {
+			t.Fatal(err)
+		}
+	}
+	controller.setRef(providers.Ref{Name: "master"})
+	controller.refresh()
+
+	// Return the IDs of the pipelines shown in the table, in order
+	shown := func() []string {
+		ids := make([]string, 0)
+		seen := make(map[interface{}]bool)
+		controller.table.Process(tcell.NewEventKey(tcell.KeyHome, 0, tcell.ModNone))
+		for {
+			path := controller.table.ActiveNodePath()
+			if len(path) == 0 || seen[path[0]] {
+				break
+			}
+			seen[path[0]] = true
+			ids = append(ids, path[0].(providers.PipelineKey).ID)
+			controller.table.Process(tcell.NewEventKey(tcell.KeyDown, 0, tcell.ModNone))
+		}
+		return ids
+	}
+
+	if diff := cmp.Diff([]string{"0", "2"}, shown()); len(diff) > 0 {
+		t.Fatal(diff)
+	}
+
+	controller.toggleOnlyTags()
+	if diff := cmp.Diff([]string{"2", "1"}, shown()); len(diff) > 0 {
+		t.Fatal(diff)
+	}
+
+	controller.toggleOnlyTags()
+	if diff := cmp.Diff([]string{"0", "2"}, shown()); len(diff) > 0 {
+		t.Fatal(diff)
+	}
+	if order := controller.table.Order(); order.Valid {
+		t.Fatalf("expected the order of the table to be restored but got %+v", order)
+	}
+}
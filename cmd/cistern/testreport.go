@@ -0,0 +1,94 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/nbedos/cistern/providers"
+	"github.com/nbedos/cistern/tui"
+)
+
+// Maximum number of lines of the failure message shown for each test case
+const maxTestMessageLines = 10
+
+// Return the lines of the view listing the test cases of report that failed or errored,
+// grouped by test suite. 'title' designates the job that ran the tests.
+func testReportLines(title string, report providers.TestReport, style providers.StepStyle, emphasis tui.StyleTransform) []tui.StyledString {
+	lines := []tui.StyledString{
+		tui.NewStyledString(fmt.Sprintf("FAILED TESTS OF %s", title), emphasis),
+		tui.NewStyledString(fmt.Sprintf("%d passed, %d failed, %d errored, %d skipped",
+			report.Count(providers.TestPassed),
+			report.Count(providers.TestFailed),
+			report.Count(providers.TestErrored),
+			report.Count(providers.TestSkipped))),
+		{},
+	}
+
+	failures := report.Failures()
+	if len(failures) == 0 {
+		return append(lines, tui.NewStyledString("   No test failed"))
+	}
+
+	for i, suite := range failures {
+		if i > 0 {
+			lines = append(lines, tui.StyledString{})
+		}
+		name := suite.Name
+		if name == "" {
+			name = "(unnamed test suite)"
+		}
+		lines = append(lines, tui.NewStyledString(name, emphasis))
+		for _, testCase := range suite.Cases {
+			line := tui.NewStyledString("   ")
+			line.Append(string(testCase.Status), style.Status.Failed)
+			line.Append("  ")
+			if testCase.ClassName != "" {
+				line.Append(testCase.ClassName + " › ")
+			}
+			line.Append(testCase.Name)
+			if testCase.Duration.Valid {
+				line.Append(fmt.Sprintf(" (%s)", testCase.Duration.String()))
+			}
+			lines = append(lines, line)
+
+			message := strings.Split(strings.TrimSpace(testCase.Message), "\n")
+			if len(message) > maxTestMessageLines {
+				omitted := len(message) - maxTestMessageLines
+				message = append(message[:maxTestMessageLines], fmt.Sprintf("[%d more lines]", omitted))
+			}
+			for _, messageLine := range message {
+				if messageLine = strings.TrimRight(messageLine, "\r \t"); messageLine != "" {
+					lines = append(lines, tui.NewStyledString("         "+strings.Replace(messageLine, "\t", "    ", -1)))
+				}
+			}
+		}
+	}
+
+	return lines
+}
+
+// Show the test cases of the job at the cursor that failed
+func (c *Controller) viewTestReport(ctx context.Context) {
+	defer c.draw()
+	key, ids, exists := c.activeStepPath()
+	if !exists || len(ids) == 0 {
+		c.writeStatus("No job at cursor")
+		return
+	}
+	c.writeStatus("Fetching test report...")
+	c.draw()
+
+	report, err := c.cache.TestReport(ctx, key, ids)
+	switch {
+	case err == providers.ErrNoTestReport:
+		c.writeStatus("No test report found for this job")
+	case err != nil:
+		c.writeStatus("")
+		c.reportError(err)
+	default:
+		c.writeStatus("")
+		c.testReport.WriteContent(testReportLines(c.activeRowBreadcrumb(), report, c.conf.StepStyle, bold)...)
+		c.focus = focusTests
+	}
+}
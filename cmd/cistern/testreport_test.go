@@ -0,0 +1,72 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gdamore/tcell"
+	"github.com/nbedos/cistern/providers"
+	"github.com/nbedos/cistern/utils"
+)
+
+func TestTestReportLines(t *testing.T) {
+	identity := func(s tcell.Style) tcell.Style { return s }
+	message := make([]string, 0)
+	for i := 1; i <= maxTestMessageLines+2; i++ {
+		message = append(message, fmt.Sprintf("line %d", i))
+	}
+
+	t.Run("failed test cases are listed by suite", func(t *testing.T) {
+		report := providers.TestReport{
+			Suites: []providers.TestSuite{
+				{
+					Name: "unit",
+					Cases: []providers.TestCase{
+						{Name: "TestA", Status: providers.TestPassed},
+						{
+							Name:      "TestB",
+							ClassName: "providers",
+							Status:    providers.TestFailed,
+							Duration:  utils.NullDuration{Valid: true, Duration: 2 * time.Second},
+							Message:   strings.Join(message, "\n"),
+						},
+					},
+				},
+				{
+					Name:  "lint",
+					Cases: []providers.TestCase{{Name: "vet", Status: providers.TestSkipped}},
+				},
+			},
+		}
+
+		lines := testReportLines("gitlab #42 › test › go test", report, providers.StepStyle{}, identity)
+		content := make([]string, 0)
+		for _, line := range lines {
+			content = append(content, line.String())
+		}
+
+		expected := []string{
+			"FAILED TESTS OF gitlab #42 › test › go test",
+			"1 passed, 1 failed, 0 errored, 1 skipped",
+			"",
+			"unit",
+			"   failed  providers › TestB (2s)",
+		}
+		for _, line := range message[:maxTestMessageLines] {
+			expected = append(expected, "         "+line)
+		}
+		expected = append(expected, "         [2 more lines]")
+		if strings.Join(content, "\n") != strings.Join(expected, "\n") {
+			t.Fatalf("expected:\n%s\nbut got:\n%s", strings.Join(expected, "\n"), strings.Join(content, "\n"))
+		}
+	})
+
+	t.Run("reports without failure", func(t *testing.T) {
+		lines := testReportLines("job", providers.TestReport{}, providers.StepStyle{}, identity)
+		if last := lines[len(lines)-1].String(); last != "   No test failed" {
+			t.Fatalf("expected %q but got %q", "   No test failed", last)
+		}
+	})
+}
@@ -0,0 +1,262 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/nbedos/cistern/providers"
+	"github.com/nbedos/cistern/tui"
+	"github.com/nbedos/cistern/utils"
+)
+
+// Blocks filling the left part of a cell by increments of one eighth
+var partialBlocks = []string{"▏", "▎", "▍", "▌", "▋", "▊", "▉"}
+
+const fullBlock = "█"
+
+// Return the name of a pipeline as shown to the user, e.g. "gitlab #1234"
+func pipelineTitle(pipeline providers.Pipeline) string {
+	number := pipeline.Number
+	if number == "" {
+		number = pipeline.ID
+	}
+	if _, err := strconv.Atoi(number); err == nil {
+		number = "#" + number
+	}
+	return strings.TrimSpace(fmt.Sprintf("%s %s", pipeline.ProviderName, number))
+}
+
+// Return the time interval during which step ran. Steps still running end at 'now'. The
+// result is false if the step never started.
+func stepInterval(step providers.Step, now time.Time) (time.Time, time.Time, bool) {
+	if !step.StartedAt.Valid {
+		return time.Time{}, time.Time{}, false
+	}
+	start := step.StartedAt.Time
+	switch {
+	case step.FinishedAt.Valid:
+		return start, step.FinishedAt.Time, true
+	case step.Duration.Valid:
+		return start, start.Add(step.Duration.Duration), true
+	case step.State.IsActive():
+		return start, now, true
+	default:
+		return start, start, true
+	}
+}
+
+// Draw a bar of 'width' cells representing the interval [start, end] on a time axis
+// beginning at t0 and lasting 'total'. Bars end with a partial block so that their length
+// is accurate to one eighth of a cell.
+func timelineBar(start time.Time, end time.Time, t0 time.Time, total time.Duration, width int) string {
+	if width <= 0 {
+		return ""
+	}
+	if total <= 0 {
+		total = time.Second
+	}
+	eighths := func(t time.Time) int {
+		e := int(float64(t.Sub(t0))/float64(total)*float64(width*8) + 0.5)
+		return utils.Bounded(e, 0, width*8)
+	}
+	e0, e1 := eighths(start), eighths(end)
+	if e1 <= e0 {
+		// Make sure even the shortest steps are visible
+		e0 = utils.MinInt(e0, width*8-1)
+		e1 = e0 + 1
+	}
+
+	s := strings.Repeat(" ", e0/8) + strings.Repeat(fullBlock, e1/8-e0/8)
+	if e1%8 > 0 {
+		s += partialBlocks[e1%8-1]
+	}
+	if padding := width - len([]rune(s)); padding > 0 {
+		s += strings.Repeat(" ", padding)
+	}
+
+	return s
+}
+
+type timelineRow struct {
+	step  providers.Step
+	depth int
+	start time.Time
+	end   time.Time
+	valid bool
+}
+
+func timelineRows(step providers.Step, depth int, now time.Time) []timelineRow {
+	start, end, valid := stepInterval(step, now)
+	rows := []timelineRow{{
+		step:  step,
+		depth: depth,
+		start: start,
+		end:   end,
+		valid: valid,
+	}}
+	for _, child := range step.Children {
+		rows = append(rows, timelineRows(child, depth+1, now)...)
+	}
+
+	return rows
+}
+
+// Return the indexes of the rows on the critical path of the pipeline, that is the chain
+// of steps without children that ran one after the other and ended last, in chronological
+// order. Steps of the chain start when the previous one ends, within 'slack'. Shortening any
+// other step would not make the pipeline finish earlier.
+func criticalPath(rows []timelineRow, slack time.Duration) []int {
+	leaves := make([]int, 0)
+	for i, row := range rows {
+		if row.valid && len(row.step.Children) == 0 {
+			leaves = append(leaves, i)
+		}
+	}
+
+	path := make([]int, 0)
+	onPath := make(map[int]bool)
+	current := -1
+	for _, i := range leaves {
+		if current < 0 || rows[i].end.After(rows[current].end) {
+			current = i
+		}
+	}
+	for current >= 0 {
+		path = append([]int{current}, path...)
+		onPath[current] = true
+		next := -1
+		for _, i := range leaves {
+			if onPath[i] || rows[i].end.After(rows[current].start.Add(slack)) {
+				continue
+			}
+			if next < 0 || rows[i].end.After(rows[next].end) {
+				next = i
+			}
+		}
+		current = next
+	}
+
+	return path
+}
+
+// Return the lines of the timeline of pipeline: each step is shown as a bar positioned by
+// its start and finish time so that parallel steps and the critical path stand out
+func timeline(pipeline providers.Pipeline, style providers.StepStyle, width int, now time.Time, emphasis tui.StyleTransform) []tui.StyledString {
+	title := fmt.Sprintf("TIMELINE OF %s", pipelineTitle(pipeline))
+	if pipeline.Ref != "" {
+		title = fmt.Sprintf("%s (%s)", title, pipeline.Ref)
+	}
+	lines := []tui.StyledString{
+		tui.NewStyledString(title, emphasis),
+	}
+
+	rows := timelineRows(pipeline.Step, 0, now)
+	var t0, t1 time.Time
+	nameWidth := 0
+	for i, row := range rows {
+		if i > 0 {
+			nameWidth = utils.MaxInt(nameWidth, 2*(row.depth-1)+len([]rune(row.step.Name)))
+		}
+		if !row.valid {
+			continue
+		}
+		if t0.IsZero() || row.start.Before(t0) {
+			t0 = row.start
+		}
+		if t1.IsZero() || row.end.After(t1) {
+			t1 = row.end
+		}
+	}
+	if t0.IsZero() {
+		return append(lines, tui.StyledString{}, tui.NewStyledString("   No step has started yet"))
+	}
+	total := t1.Sub(t0)
+
+	path := criticalPath(rows, time.Second)
+	critical := make(map[int]bool, len(path))
+	names := make([]string, 0, len(path))
+	for _, i := range path {
+		critical[i] = true
+		names = append(names, rows[i].step.Name)
+	}
+	summary := tui.NewStyledString("Critical path (marked with *): ")
+	if len(path) > 0 {
+		d := utils.NullDuration{Valid: true, Duration: rows[path[len(path)-1]].end.Sub(rows[path[0]].start)}
+		summary.Append(strings.Join(names, " › "), emphasis)
+		summary.Append(fmt.Sprintf(", %s out of %s", d.String(), utils.NullDuration{Valid: true, Duration: total}.String()))
+	} else {
+		summary.Append("-")
+	}
+	lines = append(lines, summary, tui.StyledString{})
+
+	const durationWidth = 10
+	nameWidth = utils.Bounded(nameWidth, 4, utils.MaxInt(4, width/3))
+	barWidth := utils.MaxInt(0, width-2-nameWidth-1-durationWidth-1)
+
+	axis := tui.NewStyledString(strings.Repeat(" ", 2+nameWidth+1))
+	start := tui.NewStyledString(t0.In(style.Location).Format("15:04:05"))
+	start.Fit(tui.Left, barWidth/2)
+	end := tui.NewStyledString(t1.In(style.Location).Format("15:04:05"))
+	end.Fit(tui.Right, barWidth-barWidth/2)
+	axis.AppendString(start)
+	axis.AppendString(end)
+	lines = append(lines, axis)
+
+	for i, row := range rows {
+		if i == 0 {
+			// The bar of the pipeline spans the whole axis, no need to show it
+			continue
+		}
+		marker := "  "
+		if critical[i] {
+			marker = "* "
+		}
+		line := tui.NewStyledString(marker)
+
+		name := tui.NewStyledString(strings.Repeat(" ", 2*(row.depth-1)) + row.step.Name)
+		if critical[i] {
+			name.Apply(emphasis)
+		}
+		name.Fit(tui.Left, nameWidth)
+		line.AppendString(name)
+		line.Append(" ")
+
+		bar := tui.NewStyledString(strings.Repeat(" ", barWidth))
+		if row.valid {
+			bar = tui.NewStyledString(timelineBar(row.start, row.end, t0, total, barWidth))
+			if transform := style.StateStyle(row.step.State); transform != nil {
+				bar.Apply(transform)
+			}
+			if critical[i] {
+				bar.Apply(emphasis)
+			}
+		}
+		line.AppendString(bar)
+		line.Append(" ")
+
+		duration := utils.NullDuration{}
+		if row.valid {
+			duration = utils.NullDuration{Valid: true, Duration: row.end.Sub(row.start)}
+		}
+		d := tui.NewStyledString(duration.String())
+		d.Fit(tui.Right, durationWidth)
+		line.AppendString(d)
+
+		lines = append(lines, line)
+	}
+
+	return lines
+}
+
+// Write the timeline of the pipeline selected by the user. The timeline is rewritten each
+// time it is drawn so that it follows updates of the pipeline.
+func (c *Controller) writeTimeline() {
+	pipeline, exists := c.cache.Pipeline(c.timelineKey)
+	if !exists {
+		c.timeline.WriteContent(tui.NewStyledString("Pipeline not found"))
+		return
+	}
+	c.timeline.WriteContent(timeline(pipeline, c.conf.StepStyle, c.width, c.clock.Now(), bold)...)
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gdamore/tcell"
+	"github.com/nbedos/cistern/providers"
+	"github.com/nbedos/cistern/utils"
+)
+
+func TestTimelineBar(t *testing.T) {
+	t0 := time.Date(2020, 1, 30, 12, 0, 0, 0, time.UTC)
+	testCases := []struct {
+		name   string
+		start  time.Duration
+		end    time.Duration
+		output string
+	}{
+		{"whole axis", 0, 10 * time.Second, "██████████"},
+		{"first half", 0, 5 * time.Second, "█████     "},
+		{"second half", 5 * time.Second, 10 * time.Second, "     █████"},
+		{"partial block", 2 * time.Second, 5500 * time.Millisecond, "  ███▌    "},
+		{"zero duration step", 3 * time.Second, 3 * time.Second, "   ▏      "},
+		{"zero duration step at the end", 10 * time.Second, 10 * time.Second, "         █"},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			bar := timelineBar(t0.Add(testCase.start), t0.Add(testCase.end), t0, 10*time.Second, 10)
+			if bar != testCase.output {
+				t.Fatalf("expected %q but got %q", testCase.output, bar)
+			}
+		})
+	}
+}
+
+func timelineStep(id string, start time.Time, d time.Duration, children ...providers.Step) providers.Step {
+	return providers.Step{
+		ID:         id,
+		Name:       id,
+		State:      providers.Passed,
+		StartedAt:  utils.NullTime{Valid: true, Time: start},
+		FinishedAt: utils.NullTime{Valid: true, Time: start.Add(d)},
+		Children:   children,
+	}
+}
+
+func TestCriticalPath(t *testing.T) {
+	t0 := time.Date(2020, 1, 30, 12, 0, 0, 0, time.UTC)
+	pipeline := timelineStep("pipeline", t0, 10*time.Minute,
+		timelineStep("build", t0, 3*time.Minute,
+			timelineStep("compile", t0, 3*time.Minute),
+			timelineStep("lint", t0, time.Minute),
+		),
+		timelineStep("test", t0.Add(3*time.Minute), 7*time.Minute,
+			timelineStep("unit", t0.Add(3*time.Minute), 2*time.Minute),
+			timelineStep("integration", t0.Add(3*time.Minute), 7*time.Minute),
+		),
+	)
+
+	rows := timelineRows(pipeline, 0, t0)
+	names := make([]string, 0)
+	for _, i := range criticalPath(rows, time.Second) {
+		names = append(names, rows[i].step.Name)
+	}
+
+	if expected := "compile,integration"; strings.Join(names, ",") != expected {
+		t.Fatalf("expected critical path %q but got %q", expected, strings.Join(names, ","))
+	}
+}
+
+func TestTimeline(t *testing.T) {
+	t0 := time.Date(2020, 1, 30, 12, 0, 0, 0, time.UTC)
+	pipeline := providers.Pipeline{
+		Number:       "42",
+		ProviderName: "gitlab",
+		Ref:          "master",
+		Step: timelineStep("pipeline", t0, 10*time.Minute,
+			timelineStep("build", t0, 5*time.Minute),
+			providers.Step{ID: "deploy", Name: "deploy", State: providers.Pending},
+		),
+	}
+	style := providers.StepStyle{}
+	style.Location = time.UTC
+	identity := func(s tcell.Style) tcell.Style { return s }
+
+	lines := timeline(pipeline, style, 40, t0, identity)
+	content := make([]string, 0)
+	for _, line := range lines {
+		content = append(content, strings.TrimRight(line.String(), " "))
+	}
+
+	expected := []string{
+		"TIMELINE OF gitlab #42 (master)",
+		"Critical path (marked with *): build, 5m00s out of 10m00s",
+		"",
+		"         12:00:00    12:10:00",
+		"* build  ██████████                5m00s",
+		"  deploy                               -",
+	}
+	if strings.Join(content, "\n") != strings.Join(expected, "\n") {
+		t.Fatalf("expected:\n%s\nbut got:\n%s", strings.Join(expected, "\n"), strings.Join(content, "\n"))
+	}
+}
@@ -31,7 +31,7 @@ import (
 const usage = `Usage:
 
     Test:
-        make test        # Run unit tests
+        make test        # Run unit tests with the race detector
 
     Compile and release:
         make cistern     # Build executable and manual pages for host machine
@@ -345,7 +345,7 @@ func main() {
 	case "clean":
 		err = os.RemoveAll(buildDirectory)
 	case "test", "tests":
-		cmd := exec.Command(goBin, "test", "-v", "./...")
+		cmd := exec.Command(goBin, "test", "-race", "-v", "./...")
 		cmd.Stdout = os.Stdout
 		cmd.Stderr = os.Stderr
 		cmd.Env = append(os.Environ(), env...)
@@ -0,0 +1,172 @@
+package providers
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+var ErrAddressNotFound = errors.New("no pipeline matches the address")
+
+// Address designates a pipeline or a step of a pipeline from the command line with the syntax
+// [PROVIDER/][#]PIPELINE[.STEP], for example "gitlab/12345" or "#456.test-linux". Provider
+// names, pipeline numbers and step names may all contain dots or slashes, so the address is
+// only split into its components when resolved against actual pipelines (see Resolve).
+type Address struct {
+	text string
+}
+
+func ParseAddress(s string) (Address, error) {
+	a := Address{text: strings.TrimSpace(s)}
+	switch {
+	case a.text == "":
+		return Address{}, fmt.Errorf("invalid address %q: missing pipeline number", s)
+	case strings.HasPrefix(a.text, "/"):
+		return Address{}, fmt.Errorf("invalid address %q: missing provider before '/'", s)
+	case strings.HasSuffix(a.text, "/"), strings.HasSuffix(a.text, "#"):
+		return Address{}, fmt.Errorf("invalid address %q: missing pipeline number", s)
+	case strings.HasSuffix(a.text, "."):
+		return Address{}, fmt.Errorf("invalid address %q: missing step after '.'", s)
+	}
+
+	return a, nil
+}
+
+func (a Address) String() string {
+	return a.text
+}
+
+// One way of splitting an address into its components
+type addressParts struct {
+	// Name or ID of the provider. Empty to match all providers.
+	Provider string
+	// Number or ID of the pipeline
+	Pipeline string
+	// Name or ID of a step of the pipeline. Empty to designate the pipeline itself.
+	Step string
+}
+
+func (a addressParts) String() string {
+	s := "#" + a.Pipeline
+	if a.Provider != "" {
+		s = a.Provider + "/" + s
+	}
+	if a.Step != "" {
+		s += "." + a.Step
+	}
+	return s
+}
+
+// Return every way of splitting the address into a provider and the rest of the address,
+// longest provider first and without provider last
+func (a Address) providerSplits() [][2]string {
+	splits := make([][2]string, 0)
+	for i := strings.LastIndex(a.text, "/"); i > 0; i = strings.LastIndex(a.text[:i], "/") {
+		splits = append(splits, [2]string{a.text[:i], a.text[i+1:]})
+	}
+	return append(splits, [2]string{"", a.text})
+}
+
+// Return every way of splitting 'rest' into a pipeline number and a step, longest pipeline
+// number first
+func pipelineSplits(rest string) [][2]string {
+	rest = strings.TrimPrefix(rest, "#")
+	splits := make([][2]string, 0)
+	if rest != "" {
+		splits = append(splits, [2]string{rest, ""})
+	}
+	for i := strings.LastIndex(rest, "."); i > 0; i = strings.LastIndex(rest[:i], ".") {
+		if i < len(rest)-1 {
+			splits = append(splits, [2]string{rest[:i], rest[i+1:]})
+		}
+	}
+	return splits
+}
+
+func (a addressParts) matchesPipeline(p Pipeline) bool {
+	if a.Provider != "" {
+		if !strings.EqualFold(a.Provider, p.ProviderName) && !strings.EqualFold(a.Provider, p.providerID) {
+			return false
+		}
+	}
+	return a.Pipeline == p.Number || a.Pipeline == p.ID
+}
+
+// Return the paths of the steps of 's' matching 'name'. Steps are matched by name and, if none
+// has this name, by ID.
+func matchingSteps(s Step, name string) [][]string {
+	var walk func(s Step, path []string, byName bool) [][]string
+	walk = func(s Step, path []string, byName bool) [][]string {
+		paths := make([][]string, 0)
+		for _, child := range s.Children {
+			childPath := append(append([]string(nil), path...), child.ID)
+			if (byName && child.Name == name) || (!byName && child.ID == name) {
+				paths = append(paths, childPath)
+			}
+			paths = append(paths, walk(child, childPath, byName)...)
+		}
+		return paths
+	}
+
+	if paths := walk(s, nil, true); len(paths) > 0 {
+		return paths
+	}
+	return walk(s, nil, false)
+}
+
+// Return the parts of the address and the pipelines they designate among 'pipelines'. Splits
+// naming a provider are preferred and, for a given provider, the longest pipeline number
+// matching a pipeline wins, the remainder of the address designating a step.
+func (a Address) match(pipelines []Pipeline) (addressParts, []Pipeline) {
+	for _, providerSplit := range a.providerSplits() {
+		for _, pipelineSplit := range pipelineSplits(providerSplit[1]) {
+			parts := addressParts{
+				Provider: providerSplit[0],
+				Pipeline: pipelineSplit[0],
+				Step:     pipelineSplit[1],
+			}
+			matches := make([]Pipeline, 0)
+			for _, p := range pipelines {
+				if parts.matchesPipeline(p) {
+					matches = append(matches, p)
+				}
+			}
+			if len(matches) > 0 {
+				return parts, matches
+			}
+		}
+	}
+
+	return addressParts{}, nil
+}
+
+// Return the key of the pipeline and the path of the step designated by the address among
+// 'pipelines'. The path is empty if the address designates a pipeline. ErrAddressNotFound is
+// returned if nothing matches the address.
+func (a Address) Resolve(pipelines []Pipeline) (PipelineKey, []string, error) {
+	parts, matches := a.match(pipelines)
+	switch {
+	case len(matches) == 0:
+		return PipelineKey{}, nil, ErrAddressNotFound
+	case len(matches) > 1:
+		names := make([]string, 0, len(matches))
+		for _, p := range matches {
+			names = append(names, addressParts{Provider: p.ProviderName, Pipeline: parts.Pipeline}.String())
+		}
+		return PipelineKey{}, nil, fmt.Errorf("address %q matches several pipelines (%s), prefix it with the name of the provider", a, strings.Join(names, ", "))
+	}
+
+	pipeline := matches[0]
+	if parts.Step == "" {
+		return pipeline.Key(), nil, nil
+	}
+	paths := matchingSteps(pipeline.Step, parts.Step)
+	switch {
+	case len(paths) == 0:
+		return PipelineKey{}, nil, fmt.Errorf("pipeline %s has no step named %q", addressParts{Provider: parts.Provider, Pipeline: parts.Pipeline}, parts.Step)
+	case len(paths) > 1:
+		return PipelineKey{}, nil, fmt.Errorf("address %q matches %d steps of the pipeline", a, len(paths))
+	}
+
+	return pipeline.Key(), paths[0], nil
+}
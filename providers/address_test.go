@@ -0,0 +1,171 @@
+package providers
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestParseAddress(t *testing.T) {
+	testCases := []struct {
+		input string
+		fails bool
+	}{
+		{input: "gitlab/12345"},
+		{input: " #456.test-linux "},
+		{input: "azure/#20191204.3"},
+		{input: "gitlab.example.com/123"},
+		{input: "", fails: true},
+		{input: "gitlab/", fails: true},
+		{input: "gitlab/#", fails: true},
+		{input: "/42", fails: true},
+		{input: "#42.", fails: true},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.input, func(t *testing.T) {
+			address, err := ParseAddress(testCase.input)
+			if testCase.fails {
+				if err == nil {
+					t.Fatal("expected an error but got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if address.String() != strings.TrimSpace(testCase.input) {
+				t.Fatalf("expected %q but got %q", strings.TrimSpace(testCase.input), address)
+			}
+		})
+	}
+}
+
+func TestAddress_Resolve(t *testing.T) {
+	pipelines := []Pipeline{
+		{
+			Number:       "456",
+			providerID:   "gitlab-0",
+			ProviderName: "gitlab",
+			ProviderHost: "gitlab.com",
+			Step: Step{
+				ID: "1001",
+				Children: []Step{
+					{
+						ID:   "test",
+						Name: "test",
+						Children: []Step{
+							{ID: "2001", Name: "test-linux"},
+							{ID: "2002", Name: "test-windows"},
+						},
+					},
+				},
+			},
+		},
+		{
+			Number:       "456",
+			providerID:   "travis-0",
+			ProviderName: "travis",
+			ProviderHost: "travis-ci.org",
+			Step:         Step{ID: "9876"},
+		},
+		{
+			Number:       "457",
+			providerID:   "gitlab-0",
+			ProviderName: "gitlab",
+			ProviderHost: "gitlab.com",
+			Step: Step{
+				ID: "1002",
+				Children: []Step{
+					{ID: "2003", Name: "deploy"},
+					{ID: "2004", Name: "deploy"},
+				},
+			},
+		},
+		{
+			Number:       "20191204.3",
+			providerID:   "azure-0",
+			ProviderName: "azure",
+			ProviderHost: "dev.azure.com",
+			Step: Step{
+				ID: "3001",
+				Children: []Step{
+					{ID: "4001", Name: "tests.unit"},
+				},
+			},
+		},
+		{
+			Number:       "20191204",
+			providerID:   "azure-1",
+			ProviderName: "azure-other",
+			ProviderHost: "azure.example.com",
+			Step: Step{
+				ID: "3002",
+				Children: []Step{
+					{ID: "4002", Name: "3"},
+				},
+			},
+		},
+		{
+			Number:       "123",
+			providerID:   "gitlab-1",
+			ProviderName: "gitlab.example.com",
+			ProviderHost: "gitlab.example.com",
+			Step: Step{
+				ID: "5001",
+				Children: []Step{
+					{ID: "6001", Name: "build/linux"},
+				},
+			},
+		},
+	}
+
+	testCases := []struct {
+		input   string
+		key     PipelineKey
+		stepIDs []string
+		fails   bool
+	}{
+		{input: "gitlab/456", key: pipelines[0].Key()},
+		{input: "travis/#456", key: pipelines[1].Key()},
+		{input: "gitlab-0/1001", key: pipelines[0].Key()},
+		{input: "gitlab/#456.test-linux", key: pipelines[0].Key(), stepIDs: []string{"test", "2001"}},
+		{input: "#457.2004", key: pipelines[2].Key(), stepIDs: []string{"2004"}},
+		{input: "#456", fails: true},
+		{input: "#457.deploy", fails: true},
+		{input: "#458", fails: true},
+		{input: "gitlab/456.build", fails: true},
+		{input: "azure/#20191204.3", key: pipelines[3].Key()},
+		{input: "#20191204.3.tests.unit", key: pipelines[3].Key(), stepIDs: []string{"4001"}},
+		{input: "azure-other/20191204.3", key: pipelines[4].Key(), stepIDs: []string{"4002"}},
+		{input: "gitlab.example.com/123", key: pipelines[5].Key()},
+		{input: "gitlab.example.com/#123.build/linux", key: pipelines[5].Key(), stepIDs: []string{"6001"}},
+		{input: "#123.build/linux", key: pipelines[5].Key(), stepIDs: []string{"6001"}},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.input, func(t *testing.T) {
+			address, err := ParseAddress(testCase.input)
+			if err != nil {
+				t.Fatal(err)
+			}
+			key, stepIDs, err := address.Resolve(pipelines)
+			if testCase.fails {
+				if err == nil {
+					t.Fatal("expected an error but got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if key != testCase.key {
+				t.Fatalf("expected %v but got %v", testCase.key, key)
+			}
+			if diff := cmp.Diff(testCase.stepIDs, stepIDs); len(diff) > 0 {
+				t.Fatal(diff)
+			}
+		})
+	}
+}
@@ -22,6 +22,8 @@ type AppVeyorClient struct {
 	rateLimiter <-chan time.Time
 	token       string
 	provider    Provider
+	// Requests sent through client
+	requests *requestCounter
 }
 
 var appVeyorURL = url.URL{
@@ -37,9 +39,12 @@ func NewAppVeyorClient(id string, name string, token string, requestsPerSecond f
 		rateLimit = time.Second / time.Duration(requestsPerSecond)
 	}
 
+	httpClient, requests := countedClient(nil)
+
 	return AppVeyorClient{
 		url:         appVeyorURL,
-		client:      &http.Client{Timeout: 10 * time.Second},
+		client:      httpClient,
+		requests:    requests,
 		rateLimiter: time.Tick(rateLimit),
 		token:       token,
 		provider: Provider{
@@ -49,6 +54,10 @@ func NewAppVeyorClient(id string, name string, token string, requestsPerSecond f
 	}
 }
 
+func (c AppVeyorClient) RequestStats() RequestStats {
+	return c.requests.snapshot()
+}
+
 func (c AppVeyorClient) ID() string {
 	return c.provider.ID
 }
@@ -138,10 +147,11 @@ func (c AppVeyorClient) get(ctx context.Context, u url.URL) (io.ReadCloser, erro
 		}
 		resp.Body.Close()
 		return nil, HTTPError{
-			Method:  req.Method,
-			URL:     u.String(),
-			Status:  resp.StatusCode,
-			Message: string(message),
+			Method:     req.Method,
+			URL:        u.String(),
+			Status:     resp.StatusCode,
+			Message:    string(message),
+			RetryAfter: resp.Header.Get("Retry-After"),
 		}
 	}
 
@@ -257,6 +267,9 @@ type appVeyorBuild struct {
 	StartedAt   string        `json:"started"`
 	FinishedAt  string        `json:"finished"`
 	UpdatedAt   string        `json:"updated"`
+	// Set for builds of pull requests only
+	PullRequestID    string `json:"pullRequestId"`
+	PullRequestTitle string `json:"pullRequestName"`
 }
 
 func (b appVeyorBuild) toCachePipeline(owner string, repository string) (Pipeline, error) {
@@ -269,6 +282,11 @@ func (b appVeyorBuild) toCachePipeline(owner string, repository string) (Pipelin
 		Number: strconv.Itoa(b.Number),
 		Ref:    ref,
 		IsTag:  b.IsTag,
+		Author: b.Author,
+		PullRequest: PullRequest{
+			Number: b.PullRequestID,
+			Title:  b.PullRequestTitle,
+		},
 		Step: Step{
 			ID:    strconv.Itoa(b.ID),
 			Type:  StepPipeline,
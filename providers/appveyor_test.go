@@ -97,6 +97,7 @@ func TestAppVeyorBuild_ToCacheBuild(t *testing.T) {
 		Number: "42",
 		Ref:    "feature/appveyor",
 		IsTag:  false,
+		Author: "nbedos",
 		Step: Step{
 			ID:    "42",
 			State: "failed",
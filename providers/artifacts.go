@@ -0,0 +1,133 @@
+package providers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+)
+
+var ErrNoArtifacts = errors.New("the provider of this job does not expose its artifacts")
+
+// File produced by a job and kept by the CI provider once the job finished
+type Artifact struct {
+	// Path of the file relative to the artifacts of the job
+	Path string
+	// Size of the file in bytes, zero if the provider does not tell
+	Size int64
+	// Location of the file for the provider
+	URL string
+}
+
+// CI providers implementing ArtifactProvider give access to the artifacts of jobs. Artifacts are
+// listed on demand rather than with each pipeline so that polling does not cost more requests.
+type ArtifactProvider interface {
+	// Return the artifacts of the job 'step'
+	Artifacts(ctx context.Context, step Step) ([]Artifact, error)
+	// Write the content of the artifact 'a' of the job 'step' to w
+	DownloadArtifact(ctx context.Context, step Step, a Artifact, w io.Writer) error
+}
+
+// Return the provider of the pipeline identified by key along with the pipeline and its step
+// designated by stepIDs
+func (c *Cache) artifactProvider(key PipelineKey, stepIDs []string) (ArtifactProvider, string, Pipeline, Step, error) {
+	pipeline, exists := c.Pipeline(key)
+	if !exists {
+		return nil, "", Pipeline{}, Step{}, fmt.Errorf("no matching pipeline for %v", key)
+	}
+	step, exists := pipeline.getStep(stepIDs)
+	if !exists {
+		return nil, "", Pipeline{}, Step{}, fmt.Errorf("no matching step for %v %v", key, stepIDs)
+	}
+	provider, exists := c.ciProvidersByID[pipeline.providerID]
+	if !exists {
+		return nil, "", Pipeline{}, Step{}, fmt.Errorf("no matching Provider found in cache for account ID %q", pipeline.providerID)
+	}
+	artifactProvider, ok := provider.(ArtifactProvider)
+	if !ok || step.Type != StepJob {
+		return nil, "", Pipeline{}, Step{}, ErrNoArtifacts
+	}
+
+	return artifactProvider, provider.ID(), pipeline, step, nil
+}
+
+// Return the artifacts of the job identified by key and stepIDs
+func (c *Cache) Artifacts(ctx context.Context, key PipelineKey, stepIDs []string) ([]Artifact, error) {
+	provider, providerID, pipeline, step, err := c.artifactProvider(key, stepIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := requestContext(ctx, c.timeout())
+	defer cancel()
+	artifacts, err := provider.Artifacts(ctx, step)
+	if err != nil {
+		return nil, ProviderError{
+			ProviderID: providerID,
+			Operation:  fmt.Sprintf("list artifacts of job '%s' of %s", step.Name, pipelineName(pipeline)),
+			URL:        step.WebURL.String,
+			Err:        err,
+		}
+	}
+
+	return artifacts, nil
+}
+
+// Download the artifacts of the job identified by key and stepIDs to a subdirectory of 'dir'.
+// Return the path of the subdirectory and the artifacts written to it. Downloads are not
+// subject to the request timeout since artifacts may be large.
+func (c *Cache) DownloadArtifacts(ctx context.Context, key PipelineKey, stepIDs []string, dir string) (string, []Artifact, error) {
+	artifacts, err := c.Artifacts(ctx, key, stepIDs)
+	if err != nil {
+		return "", nil, err
+	}
+	provider, providerID, pipeline, step, err := c.artifactProvider(key, stepIDs)
+	if err != nil {
+		return "", nil, err
+	}
+
+	jobDir := filepath.Join(dir, stepFilename(key, stepIDs))
+	for _, artifact := range artifacts {
+		// Artifact paths come from the provider: never write outside of the job directory
+		artifactPath := filepath.Join(jobDir, filepath.FromSlash(path.Clean("/"+artifact.Path)))
+		if err := downloadArtifact(ctx, provider, step, artifact, artifactPath); err != nil {
+			if err != context.Canceled {
+				err = ProviderError{
+					ProviderID: providerID,
+					Operation:  fmt.Sprintf("download artifact %q of job '%s' of %s", artifact.Path, step.Name, pipelineName(pipeline)),
+					URL:        artifact.URL,
+					Err:        err,
+				}
+			}
+			return "", nil, err
+		}
+	}
+
+	return jobDir, artifacts, nil
+}
+
+func downloadArtifact(ctx context.Context, provider ArtifactProvider, step Step, artifact Artifact, artifactPath string) error {
+	if err := os.MkdirAll(filepath.Dir(artifactPath), 0700); err != nil {
+		return err
+	}
+	// Write to a temporary file first so that an interrupted download never leaves a
+	// truncated artifact
+	tmpPath := artifactPath + ".tmp"
+	f, err := os.OpenFile(tmpPath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	err = provider.DownloadArtifact(ctx, step, artifact, f)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+
+	return os.Rename(tmpPath, artifactPath)
+}
@@ -0,0 +1,148 @@
+package providers
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/nbedos/cistern/utils"
+)
+
+type artifactProvider struct {
+	testProvider
+	artifacts []Artifact
+	// Error returned when downloading the artifact with this path
+	failures map[string]error
+}
+
+func (p *artifactProvider) Artifacts(ctx context.Context, step Step) ([]Artifact, error) {
+	return p.artifacts, nil
+}
+
+func (p *artifactProvider) DownloadArtifact(ctx context.Context, step Step, a Artifact, w io.Writer) error {
+	if _, err := io.WriteString(w, "content of "+a.Path); err != nil {
+		return err
+	}
+	return p.failures[a.Path]
+}
+
+func TestCache_DownloadArtifacts(t *testing.T) {
+	pipeline := Pipeline{
+		providerID:   "ci",
+		ProviderHost: "ci.example.com",
+		Step: Step{
+			ID:   "42",
+			Type: StepPipeline,
+			Children: []Step{
+				{ID: "1", Name: "test", Type: StepJob},
+				{ID: "2", Name: "build", Type: StepStage},
+			},
+		},
+	}
+	newCache := func(provider CIProvider) Cache {
+		c := NewCache([]CIProvider{provider}, nil, utils.PollingStrategy{})
+		if _, err := c.SavePipeline("sha", pipeline); err != nil {
+			t.Fatal(err)
+		}
+		return c
+	}
+
+	t.Run("artifacts are written to a directory specific to the job", func(t *testing.T) {
+		dir, err := ioutil.TempDir("", "cistern")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer os.RemoveAll(dir)
+
+		provider := &artifactProvider{
+			testProvider: testProvider{id: "ci", url: "ci.example.com"},
+			artifacts: []Artifact{
+				{Path: "test-results/junit.xml"},
+				{Path: "../../outside"},
+			},
+		}
+		c := newCache(provider)
+
+		jobDir, artifacts, err := c.DownloadArtifacts(context.Background(), pipeline.Key(), []string{"1"}, dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if expected := filepath.Join(dir, "ci.example.com-42-1"); jobDir != expected {
+			t.Fatalf("expected %q but got %q", expected, jobDir)
+		}
+		if diff := cmp.Diff(provider.artifacts, artifacts); len(diff) > 0 {
+			t.Fatal(diff)
+		}
+
+		files := make(map[string]string)
+		err = filepath.Walk(dir, func(p string, info os.FileInfo, err error) error {
+			if err != nil || info.IsDir() {
+				return err
+			}
+			bs, err := ioutil.ReadFile(p)
+			if err != nil {
+				return err
+			}
+			rel, err := filepath.Rel(dir, p)
+			files[filepath.ToSlash(rel)] = string(bs)
+			return err
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		expected := map[string]string{
+			"ci.example.com-42-1/test-results/junit.xml": "content of test-results/junit.xml",
+			"ci.example.com-42-1/outside":                "content of ../../outside",
+		}
+		if diff := cmp.Diff(expected, files); len(diff) > 0 {
+			t.Fatal(diff)
+		}
+	})
+
+	t.Run("failed downloads leave no file behind", func(t *testing.T) {
+		dir, err := ioutil.TempDir("", "cistern")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer os.RemoveAll(dir)
+
+		providerErr := errors.New("connection reset")
+		provider := &artifactProvider{
+			testProvider: testProvider{id: "ci", url: "ci.example.com"},
+			artifacts:    []Artifact{{Path: "binary"}},
+			failures:     map[string]error{"binary": providerErr},
+		}
+		c := newCache(provider)
+
+		_, _, err = c.DownloadArtifacts(context.Background(), pipeline.Key(), []string{"1"}, dir)
+		if !errors.Is(err, providerErr) {
+			t.Fatalf("expected %v but got %v", providerErr, err)
+		}
+		paths, err := filepath.Glob(filepath.Join(dir, "*", "*"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(paths) > 0 {
+			t.Fatalf("expected no file but found %v", paths)
+		}
+	})
+
+	t.Run("only jobs have artifacts", func(t *testing.T) {
+		c := newCache(&artifactProvider{testProvider: testProvider{id: "ci", url: "ci.example.com"}})
+		if _, err := c.Artifacts(context.Background(), pipeline.Key(), []string{"2"}); err != ErrNoArtifacts {
+			t.Fatalf("expected %v but got %v", ErrNoArtifacts, err)
+		}
+	})
+
+	t.Run("providers without support for artifacts", func(t *testing.T) {
+		c := newCache(&testProvider{id: "ci", url: "ci.example.com"})
+		if _, err := c.Artifacts(context.Background(), pipeline.Key(), []string{"1"}); err != ErrNoArtifacts {
+			t.Fatalf("expected %v but got %v", ErrNoArtifacts, err)
+		}
+	})
+}
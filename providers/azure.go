@@ -26,6 +26,8 @@ type AzurePipelinesClient struct {
 	provider    Provider
 	version     string
 	mux         *sync.Mutex
+	// Requests sent through httpClient
+	requests *requestCounter
 }
 
 var azureURL = url.URL{
@@ -39,9 +41,12 @@ func NewAzurePipelinesClient(id string, name string, token string, requestsPerSe
 		rateLimit = time.Second / time.Duration(requestsPerSecond)
 	}
 
+	httpClient, requests := countedClient(nil)
+
 	return AzurePipelinesClient{
 		baseURL:     azureURL,
-		httpClient:  &http.Client{Timeout: 10 * time.Second},
+		httpClient:  httpClient,
+		requests:    requests,
 		rateLimiter: time.Tick(rateLimit),
 		token:       token,
 		provider: Provider{
@@ -53,6 +58,10 @@ func NewAzurePipelinesClient(id string, name string, token string, requestsPerSe
 	}
 }
 
+func (c AzurePipelinesClient) RequestStats() RequestStats {
+	return c.requests.snapshot()
+}
+
 func (c AzurePipelinesClient) ID() string {
 	return c.provider.ID
 }
@@ -126,6 +135,94 @@ func (c AzurePipelinesClient) Log(ctx context.Context, step Step) (string, error
 	return string(log), nil
 }
 
+type azureTestRun struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+}
+
+type azureTestResult struct {
+	TestCaseTitle        string  `json:"testCaseTitle"`
+	AutomatedTestStorage string  `json:"automatedTestStorage"`
+	Outcome              string  `json:"outcome"`
+	DurationInMs         float64 `json:"durationInMs"`
+	ErrorMessage         string  `json:"errorMessage"`
+	StackTrace           string  `json:"stackTrace"`
+}
+
+func fromAzureTestOutcome(outcome string) TestStatus {
+	switch strings.ToLower(outcome) {
+	case "passed":
+		return TestPassed
+	case "failed", "timeout", "aborted":
+		return TestFailed
+	case "error":
+		return TestErrored
+	default:
+		return TestSkipped
+	}
+}
+
+// Azure attaches test runs to builds, not to the jobs that published them, so the test
+// report of a job holds the test runs of its whole build, one suite per run.
+func (c AzurePipelinesClient) TestReport(ctx context.Context, step Step) (TestReport, error) {
+	if !step.WebURL.Valid {
+		return TestReport{}, ErrNoTestReport
+	}
+	owner, repo, buildID, err := c.parseAzureWebURL(step.WebURL.String)
+	if err != nil {
+		return TestReport{}, err
+	}
+
+	u := c.baseURL
+	u.Path += fmt.Sprintf("/%s/%s/_apis/test/runs", owner, repo)
+	params := u.Query()
+	params.Add("buildUri", "vstfs:///Build/Build/"+buildID)
+	u.RawQuery = params.Encode()
+	runs := struct {
+		Value []azureTestRun `json:"value"`
+	}{}
+	if err := c.getJSON(ctx, u, &runs); err != nil {
+		return TestReport{}, err
+	}
+	if len(runs.Value) == 0 {
+		return TestReport{}, ErrNoTestReport
+	}
+
+	report := TestReport{
+		Suites: make([]TestSuite, 0, len(runs.Value)),
+	}
+	for _, run := range runs.Value {
+		u := c.baseURL
+		u.Path += fmt.Sprintf("/%s/%s/_apis/test/runs/%d/results", owner, repo, run.ID)
+		results := struct {
+			Value []azureTestResult `json:"value"`
+		}{}
+		if err := c.getJSON(ctx, u, &results); err != nil {
+			return TestReport{}, err
+		}
+
+		suite := TestSuite{
+			Name:  run.Name,
+			Cases: make([]TestCase, 0, len(results.Value)),
+		}
+		for _, result := range results.Value {
+			suite.Cases = append(suite.Cases, TestCase{
+				Name:      result.TestCaseTitle,
+				ClassName: result.AutomatedTestStorage,
+				Status:    fromAzureTestOutcome(result.Outcome),
+				Duration: utils.NullDuration{
+					Valid:    true,
+					Duration: time.Duration(result.DurationInMs * float64(time.Millisecond)),
+				},
+				Message: strings.TrimSpace(result.ErrorMessage + "\n" + result.StackTrace),
+			})
+		}
+		report.Suites = append(report.Suites, suite)
+	}
+
+	return report, nil
+}
+
 type azureBuild struct {
 	ID                int        `json:"id"`
 	Number            string     `json:"buildNumber"`
@@ -159,6 +256,47 @@ type azureBuild struct {
 	Repository struct {
 		ID string `json:"id"`
 	} `json:"repository"`
+	// Queue of the agent pool running the jobs of the build unless they set their own pool
+	Queue struct {
+		Name string `json:"name"`
+		Pool struct {
+			Name string `json:"name"`
+		} `json:"pool"`
+	} `json:"queue"`
+	// JSON object mapping the names of the variables set when queuing the build to their value
+	Parameters         string                 `json:"parameters"`
+	TemplateParameters map[string]interface{} `json:"templateParameters"`
+	// Details of the trigger of the build such as "pr.number" and "pr.title"
+	TriggerInfo map[string]string `json:"triggerInfo"`
+}
+
+// Return the variables and the template parameters the build was queued with, sorted by name
+func (b azureBuild) variables() ([]Variable, error) {
+	values := make(map[string]string)
+	if b.Parameters != "" {
+		if err := json.Unmarshal([]byte(b.Parameters), &values); err != nil {
+			return nil, err
+		}
+	}
+	for name, value := range b.TemplateParameters {
+		values[name] = fmt.Sprint(value)
+	}
+
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var variables []Variable
+	for _, name := range names {
+		variables = append(variables, Variable{
+			Name:  name,
+			Value: values[name],
+		})
+	}
+
+	return variables, nil
 }
 
 func (b azureBuild) toPipeline() (Pipeline, error) {
@@ -176,15 +314,24 @@ func (b azureBuild) toPipeline() (Pipeline, error) {
 		isTag = true
 	}
 
+	// Builds of pull requests run on refs/pull/<number>/merge
+	var pr PullRequest
+	if strings.HasPrefix(b.SourceBranch, "refs/pull/") {
+		pr.Number = strings.SplitN(strings.TrimPrefix(b.SourceBranch, "refs/pull/"), "/", 2)[0]
+		pr.Title = b.TriggerInfo["pr.title"]
+	}
+
 	pipeline := Pipeline{
-		Number: b.Number,
-		Ref:    ref,
-		IsTag:  isTag,
+		Number:      b.Number,
+		Ref:         ref,
+		IsTag:       isTag,
+		PullRequest: pr,
 		Step: Step{
-			ID:    strconv.Itoa(b.ID),
-			Name:  b.Definition.Name,
-			Type:  StepPipeline,
-			State: fromAzureState(b.Result, b.Status),
+			ID:     strconv.Itoa(b.ID),
+			Name:   b.Definition.Name,
+			Type:   StepPipeline,
+			State:  fromAzureState(b.Result, b.Status),
+			Runner: b.Queue.Pool.Name,
 			WebURL: utils.NullString{
 				Valid:  b.Links.Web.Href != "",
 				String: b.Links.Web.Href,
@@ -209,6 +356,9 @@ func (b azureBuild) toPipeline() (Pipeline, error) {
 		return Pipeline{}, err
 	}
 	pipeline.Duration = utils.NullSub(pipeline.FinishedAt, pipeline.StartedAt)
+	if pipeline.Variables, err = b.variables(); err != nil {
+		return Pipeline{}, err
+	}
 
 	return pipeline, nil
 }
@@ -262,13 +412,10 @@ func (c AzurePipelinesClient) fetchPipeline(ctx context.Context, owner string, r
 	}
 
 	for i := range stages {
-		stages[i].CreatedAt = pipeline.CreatedAt
-		for j := range stages[i].Children {
-			stages[i].Children[j].CreatedAt = pipeline.CreatedAt
-			for k := range stages[i].Children[j].Children {
-				stages[i].Children[j].Children[k].CreatedAt = pipeline.CreatedAt
-			}
-		}
+		stages[i] = stages[i].Map(func(s Step) Step {
+			s.CreatedAt = pipeline.CreatedAt
+			return s
+		})
 	}
 
 	// If there is a single stage named "__default" just remove it and populate pipeline.Children
@@ -304,9 +451,10 @@ func (c AzurePipelinesClient) fetchStages(ctx context.Context, u string, webURL
 		return nil, err
 	}
 
+	allRecordsByID := make(map[string]azureRecord)
 	recordsByID := make(map[string]*azureRecord)
-
 	for _, record := range timeline.Records {
+		allRecordsByID[record.ID] = record
 		switch strings.ToLower(record.Type) {
 		case "job", "task", "phase", "stage":
 			record := record // kill me now
@@ -317,11 +465,12 @@ func (c AzurePipelinesClient) fetchStages(ctx context.Context, u string, webURL
 	// Build tree structure from flat list and ID -> parentIDs links
 	topLevelRecords := make([]*azureRecord, 0)
 	for _, record := range recordsByID {
-		if record.ParentID != "" {
-			parent, exists := recordsByID[record.ParentID]
-			if !exists {
-				return nil, fmt.Errorf("ParentID not found: %q", record.ParentID)
-			}
+		parentID, err := visibleParentID(allRecordsByID, recordsByID, *record)
+		if err != nil {
+			return nil, err
+		}
+		if parentID != "" {
+			parent := recordsByID[parentID]
 			parent.children = append(parent.children, record)
 		} else {
 			topLevelRecords = append(topLevelRecords, record)
@@ -341,8 +490,8 @@ func (c AzurePipelinesClient) fetchStages(ctx context.Context, u string, webURL
 
 	// At this point we have a tree structure with the following hierarchy of record.type_ :
 	//    Stage -> Phase -> Job -> Task
-	// For now we ignore tasks. Phases that contain jobs are redundant with the jobs themselves
-	// so we ignore them too. Phase that have no child are turned into a providers.Job.
+	// Phases that contain jobs are redundant with the jobs themselves so we ignore them.
+	// Phase that have no child are turned into a providers.Job.
 	// (this is consistent with the way jobs are shown on the Azure website)
 	steps := make([]Step, 0, len(topLevelRecords))
 	for _, record := range topLevelRecords {
@@ -356,6 +505,30 @@ func (c AzurePipelinesClient) fetchStages(ctx context.Context, u string, webURL
 	return steps, nil
 }
 
+// Return the ID of the closest ancestor of 'record' that is part of 'visible'. Records of
+// multi-stage pipelines may be attached to records we do not display (e.g. checkpoints used
+// for approvals) in which case the record is attached to the parent of the hidden record.
+func visibleParentID(all map[string]azureRecord, visible map[string]*azureRecord, record azureRecord) (string, error) {
+	seen := make(map[string]struct{})
+	for parentID := record.ParentID; parentID != ""; {
+		if _, exists := visible[parentID]; exists {
+			return parentID, nil
+		}
+		if _, exists := seen[parentID]; exists {
+			return "", fmt.Errorf("cycle detected in parents of record %q", record.ID)
+		}
+		seen[parentID] = struct{}{}
+
+		parent, exists := all[parentID]
+		if !exists {
+			return "", fmt.Errorf("ParentID not found: %q", parentID)
+		}
+		parentID = parent.ParentID
+	}
+
+	return "", nil
+}
+
 type azureRecord struct {
 	ID           string `json:"id"`
 	ParentID     string `json:"parentId"`
@@ -367,7 +540,9 @@ type azureRecord struct {
 	Result       string `json:"result"`
 	LastModified string `json:"lastModified"`
 	Order        int    `json:"order"`
-	Log          struct {
+	// Name of the agent that ran the record
+	WorkerName string `json:"workerName"`
+	Log        struct {
 		URL string `json:"url"`
 	} `json:"log"`
 	children []*azureRecord
@@ -420,6 +595,7 @@ func (r azureRecord) toSteps(webURL url.URL) ([]Step, error) {
 
 	case "job":
 		step.Type = StepJob
+		step.Runner = r.WorkerName
 		query := webURL.Query()
 		query.Del("s")
 		query.Add("j", r.ID)
@@ -490,7 +666,7 @@ func (c AzurePipelinesClient) get(ctx context.Context, u url.URL) (io.ReadCloser
 	if err != nil {
 		return nil, err
 	}
-	req.WithContext(ctx)
+	req = req.WithContext(ctx)
 
 	if c.token != "" {
 		req.SetBasicAuth("", c.token)
@@ -519,10 +695,11 @@ func (c AzurePipelinesClient) get(ctx context.Context, u url.URL) (io.ReadCloser
 		}
 		resp.Body.Close()
 		return nil, HTTPError{
-			Method:  req.Method,
-			URL:     u.String(),
-			Status:  resp.StatusCode,
-			Message: string(message),
+			Method:     req.Method,
+			URL:        u.String(),
+			Status:     resp.StatusCode,
+			Message:    string(message),
+			RetryAfter: resp.Header.Get("Retry-After"),
 		}
 	}
 
@@ -25,8 +25,14 @@ func Setup() (AzurePipelinesClient, func(), error) {
 			filename = "azure_build_16.json"
 		case r.Method == "GET" && r.URL.Path == "/owner/repo/_apis/build/builds/16/Timeline":
 			filename = "azure_build_16_timeline.json"
+		case r.Method == "GET" && r.URL.Path == "/owner/repo/_apis/build/builds/17/Timeline":
+			filename = "azure_build_17_timeline.json"
 		case r.Method == "GET" && r.URL.Path == "/owner/repo/_apis/build/builds/16/logs/1234":
 			filename = "azure_build_16_job_log.txt"
+		case r.Method == "GET" && r.URL.Path == "/owner/repo/_apis/test/runs" && r.URL.Query().Get("buildUri") == "vstfs:///Build/Build/16":
+			filename = "azure_build_16_test_runs.json"
+		case r.Method == "GET" && r.URL.Path == "/owner/repo/_apis/test/runs/7/results":
+			filename = "azure_test_run_7_results.json"
 		default:
 			w.WriteHeader(404)
 			return
@@ -77,10 +83,11 @@ var expectedPipeline = Pipeline{
 	Ref:    "azure-pipelines",
 	IsTag:  false,
 	Step: Step{
-		ID:    "16",
-		Name:  "owner.repo (1)",
-		Type:  StepPipeline,
-		State: Failed,
+		ID:     "16",
+		Name:   "owner.repo (1)",
+		Type:   StepPipeline,
+		State:  Failed,
+		Runner: "Azure Pipelines",
 		CreatedAt: utils.NullTime{
 			Valid: true,
 			Time:  time.Date(2019, 12, 4, 13, 9, 34, 734161200, time.UTC),
@@ -105,6 +112,11 @@ var expectedPipeline = Pipeline{
 			String: "http://HOST/owner/repo/_build/results?buildId=16",
 			Valid:  true,
 		},
+		Variables: []Variable{
+			{Name: "DEPLOY_TOKEN", Value: "abcd"},
+			{Name: "environment", Value: "staging"},
+			{Name: "system.debug", Value: "true"},
+		},
 		Children: []Step{
 			{
 				ID:    "8bfbeaae-4c8e-5f12-f154-edd305817000",
@@ -185,10 +197,11 @@ var expectedPipeline = Pipeline{
 						},
 					},
 					{
-						ID:    "aa83c9de-d200-5148-7d44-5e08a0dd6659",
-						Type:  StepJob,
-						State: "failed",
-						Name:  "macoOS_10_14",
+						ID:     "aa83c9de-d200-5148-7d44-5e08a0dd6659",
+						Type:   StepJob,
+						State:  "failed",
+						Name:   "macoOS_10_14",
+						Runner: "Hosted Agent",
 						CreatedAt: utils.NullTime{
 							Valid: true,
 							Time:  time.Date(2019, 12, 4, 13, 9, 34, 734161200, time.UTC),
@@ -334,6 +347,107 @@ func TestAzurePipelinesClient_BuildFromURL(t *testing.T) {
 	}
 }
 
+func TestAzurePipelinesClient_fetchStages(t *testing.T) {
+	client, teardown, err := Setup()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer teardown()
+
+	baseURL := "http://" + client.baseURL.Host
+	webURL, err := url.Parse(baseURL + "/owner/repo/_build/results?buildId=17")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.Background()
+	stages, err := client.fetchStages(ctx, baseURL+"/owner/repo/_apis/build/builds/17/Timeline", *webURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	at := func(minutes int, seconds int) utils.NullTime {
+		return utils.NullTime{
+			Valid: true,
+			Time:  time.Date(2020, 1, 30, 13, minutes, seconds, 0, time.UTC),
+		}
+	}
+	duration := func(d time.Duration) utils.NullDuration {
+		return utils.NullDuration{
+			Valid:    true,
+			Duration: d,
+		}
+	}
+	stepURL := func(query string) utils.NullString {
+		return utils.NullString{
+			Valid:  true,
+			String: baseURL + "/owner/repo/_build/results?buildId=17" + query,
+		}
+	}
+
+	expectedStages := []Step{
+		{
+			ID:         "1c2d3e4f-4444-5c3d-c3c6-290a6d3ec2ef",
+			Name:       "build",
+			Type:       StepStage,
+			State:      Passed,
+			StartedAt:  at(0, 0),
+			FinishedAt: at(0, 50),
+			Duration:   duration(50 * time.Second),
+			WebURL:     stepURL("&s=1c2d3e4f-4444-5c3d-c3c6-290a6d3ec2ef&view=logs"),
+			Children: []Step{
+				{
+					ID:         "6f5c1d2a-2222-5c3d-c3c6-290a6d3ec2ef",
+					Name:       "Build",
+					Type:       StepJob,
+					State:      Passed,
+					StartedAt:  at(0, 5),
+					FinishedAt: at(0, 45),
+					Duration:   duration(40 * time.Second),
+					WebURL:     stepURL("&j=6f5c1d2a-2222-5c3d-c3c6-290a6d3ec2ef&view=logs"),
+					Log:        Log{Key: baseURL + "/owner/repo/_apis/build/builds/17/logs/5"},
+					Children: []Step{
+						{
+							ID:         "0b2f7a1e-1111-5c3d-c3c6-290a6d3ec2ef",
+							Name:       "go build",
+							Type:       StepTask,
+							State:      Passed,
+							StartedAt:  at(0, 10),
+							FinishedAt: at(0, 40),
+							Duration:   duration(30 * time.Second),
+							WebURL:     stepURL("&j=6f5c1d2a-2222-5c3d-c3c6-290a6d3ec2ef&t=0b2f7a1e-1111-5c3d-c3c6-290a6d3ec2ef&view=logs"),
+							Log:        Log{Key: baseURL + "/owner/repo/_apis/build/builds/17/logs/6"},
+						},
+					},
+				},
+			},
+		},
+		{
+			ID:        "5d6e7f80-5555-5c3d-c3c6-290a6d3ec2ef",
+			Name:      "deploy",
+			Type:      StepStage,
+			State:     Running,
+			StartedAt: at(0, 50),
+			Duration:  utils.NullSub(utils.NullTime{}, at(0, 50)),
+			WebURL:    stepURL("&s=5d6e7f80-5555-5c3d-c3c6-290a6d3ec2ef&view=logs"),
+			Children: []Step{
+				{
+					// This phase is attached to a checkpoint in the timeline
+					ID:     "c3d4e5f6-8888-5c3d-c3c6-290a6d3ec2ef",
+					Name:   "Deploy",
+					Type:   StepJob,
+					State:  Pending,
+					WebURL: stepURL("&j=c3d4e5f6-8888-5c3d-c3c6-290a6d3ec2ef&view=logs"),
+				},
+			},
+		},
+	}
+
+	if diff := cmp.Diff(expectedStages, stages, cmp.AllowUnexported(Step{})); len(diff) > 0 {
+		t.Fatal(diff)
+	}
+}
+
 func TestAzurePipelinesClient_Log(t *testing.T) {
 	client, teardown, err := Setup()
 	if err != nil {
@@ -364,3 +478,50 @@ func TestAzurePipelinesClient_Log(t *testing.T) {
 		t.Fatal(diff)
 	}
 }
+
+func TestAzurePipelinesClient_TestReport(t *testing.T) {
+	client, teardown, err := Setup()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer teardown()
+
+	webURL := client.baseURL
+	webURL.Path = "/owner/repo/_build/results"
+	webURL.RawQuery = "buildId=16&j=1234"
+	job := Step{
+		ID:     "1234",
+		Type:   StepJob,
+		WebURL: utils.NullString{Valid: true, String: webURL.String()},
+	}
+	report, err := client.TestReport(context.Background(), job)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := TestReport{
+		Suites: []TestSuite{
+			{
+				Name: "VSTest_TestResults_1",
+				Cases: []TestCase{
+					{
+						Name:      "TestAdd",
+						ClassName: "calculator.dll",
+						Status:    TestPassed,
+						Duration:  utils.NullDuration{Valid: true, Duration: 12 * time.Millisecond},
+					},
+					{
+						Name:      "TestDivide",
+						ClassName: "calculator.dll",
+						Status:    TestFailed,
+						Duration:  utils.NullDuration{Valid: true, Duration: 3 * time.Millisecond},
+						Message:   "Assert.AreEqual failed\nat Calculator.TestDivide()",
+					},
+				},
+			},
+		},
+	}
+	if diff := cmp.Diff(expected, report); len(diff) > 0 {
+		t.Fatal(diff)
+	}
+}
@@ -0,0 +1,333 @@
+package providers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/nbedos/cistern/utils"
+)
+
+type BuddyClient struct {
+	baseURL     url.URL
+	apiURL      url.URL
+	httpClient  *http.Client
+	rateLimiter <-chan time.Time
+	token       string
+	provider    Provider
+	// Requests sent through httpClient
+	requests *requestCounter
+}
+
+var BuddyURL = url.URL{Scheme: "https", Host: "app.buddy.works"}
+var BuddyAPIURL = url.URL{Scheme: "https", Host: "api.buddy.works"}
+
+func NewBuddyClient(id string, name string, token string, URL string, apiURL string, requestsPerSecond float64) (BuddyClient, error) {
+	rateLimit := time.Second / 10
+	if requestsPerSecond > 0 {
+		rateLimit = time.Second / time.Duration(requestsPerSecond)
+	}
+
+	u := BuddyURL
+	if URL != "" {
+		v, err := url.Parse(URL)
+		if err != nil {
+			return BuddyClient{}, err
+		}
+		u = *v
+	}
+	u.Path = strings.TrimSuffix(u.Path, "/")
+
+	a := BuddyAPIURL
+	if apiURL != "" {
+		v, err := url.Parse(apiURL)
+		if err != nil {
+			return BuddyClient{}, err
+		}
+		a = *v
+	}
+	a.Path = strings.TrimSuffix(a.Path, "/")
+
+	httpClient, requests := countedClient(nil)
+
+	return BuddyClient{
+		baseURL:     u,
+		apiURL:      a,
+		httpClient:  httpClient,
+		requests:    requests,
+		rateLimiter: time.Tick(rateLimit),
+		token:       token,
+		provider: Provider{
+			ID:   id,
+			Name: name,
+		},
+	}, nil
+}
+
+func (c BuddyClient) RequestStats() RequestStats {
+	return c.requests.snapshot()
+}
+
+func (c BuddyClient) ID() string {
+	return c.provider.ID
+}
+
+func (c BuddyClient) Host() string {
+	return c.baseURL.Host
+}
+
+func (c BuddyClient) Name() string {
+	return c.provider.Name
+}
+
+func (c BuddyClient) BuildFromURL(ctx context.Context, u string) (Pipeline, error) {
+	workspace, project, pipelineID, executionID, err := parseBuddyWebURL(&c.baseURL, u)
+	if err != nil {
+		return Pipeline{}, err
+	}
+
+	return c.fetchPipeline(ctx, workspace, project, pipelineID, executionID)
+}
+
+// Extract workspace, project, pipeline ID and execution ID from web url of execution
+func parseBuddyWebURL(baseURL *url.URL, u string) (string, string, int, int, error) {
+	v, err := url.Parse(u)
+	if err != nil {
+		return "", "", 0, 0, err
+	}
+
+	if v.Hostname() != baseURL.Hostname() {
+		return "", "", 0, 0, ErrUnknownPipelineURL
+	}
+
+	// url format: https://app.buddy.works/nbedos/cistern/pipelines/pipeline/42/execution/1337
+	cs := strings.Split(strings.TrimPrefix(v.Path, baseURL.Path), "/")
+	if len(cs) < 8 || cs[3] != "pipelines" || cs[4] != "pipeline" || cs[6] != "execution" {
+		return "", "", 0, 0, ErrUnknownPipelineURL
+	}
+
+	workspace, project := cs[1], cs[2]
+	pipelineID, err := strconv.Atoi(cs[5])
+	if err != nil {
+		return "", "", 0, 0, err
+	}
+	executionID, err := strconv.Atoi(cs[7])
+	if err != nil {
+		return "", "", 0, 0, err
+	}
+
+	return workspace, project, pipelineID, executionID, nil
+}
+
+func (c BuddyClient) endpoint(format string, a ...interface{}) url.URL {
+	endpoint := c.apiURL
+	endpoint.Path += fmt.Sprintf(format, a...)
+
+	return endpoint
+}
+
+func (c BuddyClient) fetchPipeline(ctx context.Context, workspace string, project string, pipelineID int, executionID int) (Pipeline, error) {
+	var execution buddyExecution
+	executionURL := c.endpoint("/workspaces/%s/projects/%s/pipelines/%d/executions/%d", workspace, project, pipelineID, executionID)
+	if err := c.getJSON(ctx, executionURL, &execution); err != nil {
+		return Pipeline{}, err
+	}
+
+	webURL := c.baseURL
+	webURL.Path += fmt.Sprintf("/%s/%s/pipelines/pipeline/%d/execution/%d", workspace, project, pipelineID, executionID)
+
+	return execution.toPipeline(webURL.String(), executionURL)
+}
+
+func (c BuddyClient) Log(ctx context.Context, step Step) (string, error) {
+	if step.Log.Key == "" {
+		return "", ErrNoLogHere
+	}
+
+	u, err := url.Parse(step.Log.Key)
+	if err != nil {
+		return "", err
+	}
+
+	var actionExecution struct {
+		Log []string `json:"log"`
+	}
+	if err := c.getJSON(ctx, *u, &actionExecution); err != nil {
+		return "", err
+	}
+
+	return strings.Join(actionExecution.Log, "\n"), nil
+}
+
+func (c BuddyClient) getJSON(ctx context.Context, u url.URL, v interface{}) error {
+	r, err := c.get(ctx, u)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if errClose := r.Close(); err == nil {
+			err = errClose
+		}
+	}()
+
+	err = json.NewDecoder(r).Decode(v)
+	return err
+}
+
+func (c BuddyClient) get(ctx context.Context, u url.URL) (io.ReadCloser, error) {
+	req, err := http.NewRequest("GET", u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Add("Accept", "application/json")
+	if c.token != "" {
+		req.Header.Add("Authorization", fmt.Sprintf("Bearer %s", c.token))
+	}
+	req = req.WithContext(ctx)
+
+	select {
+	case <-c.rateLimiter:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		message, err := ioutil.ReadAll(resp.Body)
+		if err != nil {
+			message = nil
+		}
+		resp.Body.Close()
+		return nil, HTTPError{
+			Method:     req.Method,
+			URL:        u.String(),
+			Status:     resp.StatusCode,
+			Message:    string(message),
+			RetryAfter: resp.Header.Get("Retry-After"),
+		}
+	}
+
+	return resp.Body, nil
+}
+
+func fromBuddyState(s string) State {
+	switch strings.ToUpper(s) {
+	case "INITIAL", "ENQUEUED", "WAITING_FOR_APPLY", "WAITING_FOR_VARIABLES", "WAITING_FOR_SETTABLE_VARIABLES":
+		return Pending
+	case "INPROGRESS", "TERMINATING":
+		return Running
+	case "SUCCESSFUL":
+		return Passed
+	case "FAILED":
+		return Failed
+	case "TERMINATED":
+		return Canceled
+	case "NOT_EXECUTED", "SKIPPED":
+		return Skipped
+	default:
+		return Unknown
+	}
+}
+
+type buddyExecution struct {
+	ID         int    `json:"id"`
+	Status     string `json:"status"`
+	StartDate  string `json:"start_date"`
+	FinishDate string `json:"finish_date"`
+	Branch     struct {
+		Name string `json:"name"`
+	} `json:"branch"`
+	Tag struct {
+		Name string `json:"name"`
+	} `json:"tag"`
+	ActionExecutions []buddyActionExecution `json:"action_executions"`
+}
+
+func (e buddyExecution) toPipeline(webURL string, executionURL url.URL) (Pipeline, error) {
+	pipeline := Pipeline{
+		Number: strconv.Itoa(e.ID),
+		Ref:    e.Branch.Name,
+		IsTag:  e.Tag.Name != "",
+		Step: Step{
+			ID:    strconv.Itoa(e.ID),
+			Type:  StepPipeline,
+			State: fromBuddyState(e.Status),
+			WebURL: utils.NullString{
+				Valid:  true,
+				String: webURL,
+			},
+		},
+	}
+	if pipeline.IsTag {
+		pipeline.Ref = e.Tag.Name
+	}
+
+	var err error
+	if pipeline.StartedAt, err = utils.NullTimeFromString(e.StartDate); err != nil {
+		return Pipeline{}, err
+	}
+	if pipeline.FinishedAt, err = utils.NullTimeFromString(e.FinishDate); err != nil {
+		return Pipeline{}, err
+	}
+	// Buddy does not tell when an execution was created
+	pipeline.CreatedAt = pipeline.StartedAt
+	pipeline.UpdatedAt = utils.MaxNullTime(pipeline.StartedAt, pipeline.FinishedAt)
+	pipeline.Duration = utils.NullSub(pipeline.FinishedAt, pipeline.StartedAt)
+
+	for _, actionExecution := range e.ActionExecutions {
+		logURL := executionURL
+		logURL.Path += fmt.Sprintf("/action_executions/%d", actionExecution.Action.ID)
+		job, err := actionExecution.toStep(pipeline, logURL.String())
+		if err != nil {
+			return Pipeline{}, err
+		}
+		pipeline.Children = append(pipeline.Children, job)
+	}
+
+	return pipeline, nil
+}
+
+type buddyActionExecution struct {
+	Status     string `json:"status"`
+	StartDate  string `json:"start_date"`
+	FinishDate string `json:"finish_date"`
+	Action     struct {
+		ID   int    `json:"id"`
+		Name string `json:"name"`
+	} `json:"action"`
+}
+
+func (a buddyActionExecution) toStep(pipeline Pipeline, logKey string) (Step, error) {
+	step := Step{
+		ID:        strconv.Itoa(a.Action.ID),
+		Name:      a.Action.Name,
+		Type:      StepJob,
+		State:     fromBuddyState(a.Status),
+		CreatedAt: pipeline.CreatedAt,
+		WebURL:    pipeline.WebURL,
+		Log: Log{
+			Key: logKey,
+		},
+	}
+
+	var err error
+	if step.StartedAt, err = utils.NullTimeFromString(a.StartDate); err != nil {
+		return Step{}, err
+	}
+	if step.FinishedAt, err = utils.NullTimeFromString(a.FinishDate); err != nil {
+		return Step{}, err
+	}
+	step.Duration = utils.NullSub(step.FinishedAt, step.StartedAt)
+
+	return step, nil
+}
@@ -0,0 +1,186 @@
+package providers
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/nbedos/cistern/utils"
+)
+
+func setupBuddyTestServer(t *testing.T) (BuddyClient, *url.URL, func()) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		filename := ""
+		switch r.URL.Path {
+		case "/workspaces/nbedos/projects/cistern/pipelines/42/executions/1337":
+			filename = "buddy_execution.json"
+		case "/workspaces/nbedos/projects/cistern/pipelines/42/executions/1337/action_executions/8":
+			filename = "buddy_action_execution.json"
+		default:
+			w.WriteHeader(404)
+			return
+		}
+
+		bs, err := ioutil.ReadFile(path.Join("test_data", "buddy", filename))
+		if err != nil {
+			w.WriteHeader(500)
+			fmt.Fprint(w, err.Error())
+			return
+		}
+		if _, err := fmt.Fprint(w, string(bs)); err != nil {
+			w.WriteHeader(500)
+			fmt.Fprint(w, err.Error())
+			return
+		}
+	}))
+
+	testURL, err := url.Parse(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	client := BuddyClient{
+		baseURL:     *testURL,
+		apiURL:      *testURL,
+		httpClient:  ts.Client(),
+		rateLimiter: time.Tick(time.Millisecond),
+	}
+
+	return client, testURL, func() { ts.Close() }
+}
+
+func TestParseBuddyWebURL(t *testing.T) {
+	baseURL := BuddyURL
+
+	t.Run("execution url", func(t *testing.T) {
+		u := "https://app.buddy.works/nbedos/cistern/pipelines/pipeline/42/execution/1337"
+		workspace, project, pipelineID, executionID, err := parseBuddyWebURL(&baseURL, u)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if workspace != "nbedos" || project != "cistern" || pipelineID != 42 || executionID != 1337 {
+			t.Fail()
+		}
+	})
+
+	t.Run("url of another host", func(t *testing.T) {
+		u := "https://example.com/nbedos/cistern/pipelines/pipeline/42/execution/1337"
+		_, _, _, _, err := parseBuddyWebURL(&baseURL, u)
+		if err != ErrUnknownPipelineURL {
+			t.Fatalf("expected %v but got %v", ErrUnknownPipelineURL, err)
+		}
+	})
+}
+
+func TestBuddyClient_BuildFromURL(t *testing.T) {
+	client, testURL, teardown := setupBuddyTestServer(t)
+	defer teardown()
+
+	pipelineURL := testURL.String() + "/nbedos/cistern/pipelines/pipeline/42/execution/1337"
+	pipeline, err := client.BuildFromURL(context.Background(), pipelineURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	at := func(minutes int, seconds int) utils.NullTime {
+		return utils.NullTime{
+			Valid: true,
+			Time:  time.Date(2020, 1, 30, 13, minutes, seconds, 0, time.UTC),
+		}
+	}
+	webURL := utils.NullString{
+		Valid:  true,
+		String: pipelineURL,
+	}
+	logKey := testURL.String() + "/workspaces/nbedos/projects/cistern/pipelines/42/executions/1337/action_executions/"
+
+	expectedPipeline := Pipeline{
+		Number: "1337",
+		Ref:    "master",
+		Step: Step{
+			ID:         "1337",
+			Type:       StepPipeline,
+			State:      Failed,
+			CreatedAt:  at(0, 5),
+			StartedAt:  at(0, 5),
+			FinishedAt: at(1, 30),
+			UpdatedAt:  at(1, 30),
+			Duration: utils.NullDuration{
+				Valid:    true,
+				Duration: 85 * time.Second,
+			},
+			WebURL: webURL,
+			Children: []Step{
+				{
+					ID:         "7",
+					Name:       "Build",
+					Type:       StepJob,
+					State:      Passed,
+					CreatedAt:  at(0, 5),
+					StartedAt:  at(0, 5),
+					FinishedAt: at(0, 30),
+					Duration: utils.NullDuration{
+						Valid:    true,
+						Duration: 25 * time.Second,
+					},
+					WebURL: webURL,
+					Log:    Log{Key: logKey + "7"},
+				},
+				{
+					ID:         "8",
+					Name:       "Test",
+					Type:       StepJob,
+					State:      Failed,
+					CreatedAt:  at(0, 5),
+					StartedAt:  at(0, 30),
+					FinishedAt: at(1, 30),
+					Duration: utils.NullDuration{
+						Valid:    true,
+						Duration: time.Minute,
+					},
+					WebURL: webURL,
+					Log:    Log{Key: logKey + "8"},
+				},
+				{
+					ID:        "9",
+					Name:      "Deploy",
+					Type:      StepJob,
+					State:     Skipped,
+					CreatedAt: at(0, 5),
+					WebURL:    webURL,
+					Log:       Log{Key: logKey + "9"},
+				},
+			},
+		},
+	}
+	if diff := expectedPipeline.Diff(pipeline); len(diff) > 0 {
+		t.Fatal(diff)
+	}
+}
+
+func TestBuddyClient_Log(t *testing.T) {
+	client, testURL, teardown := setupBuddyTestServer(t)
+	defer teardown()
+
+	step := Step{
+		Log: Log{
+			Key: testURL.String() + "/workspaces/nbedos/projects/cistern/pipelines/42/executions/1337/action_executions/8",
+		},
+	}
+
+	log, err := client.Log(context.Background(), step)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if diff := cmp.Diff("$ go test ./...\nFAIL", log); len(diff) > 0 {
+		t.Fatal(diff)
+	}
+}
@@ -4,8 +4,12 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"io/ioutil"
 	"os"
 	"os/exec"
+	"path"
+	"path/filepath"
+	"regexp"
 	"sort"
 	"strings"
 	"sync"
@@ -23,6 +27,18 @@ var ErrUnknownRepositoryURL = errors.New("unknown repository url")
 var ErrUnknownPipelineURL = errors.New("unknown pipeline url")
 var ErrUnknownGitReference = errors.New("unknown git reference")
 
+// Maximum duration of a request to a CI provider unless configured otherwise
+const defaultRequestTimeout = 10 * time.Second
+
+// Return a context for a single request to a provider. The context is canceled once 'timeout'
+// is elapsed, unless 'timeout' is zero.
+func requestContext(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, timeout)
+}
+
 var defaultPollingStrategy = utils.PollingStrategy{
 	InitialInterval: 10 * time.Second,
 	Multiplier:      1.5,
@@ -43,16 +59,57 @@ type CIProvider interface {
 	BuildFromURL(ctx context.Context, u string) (Pipeline, error)
 }
 
+// CI providers implementing PipelineLister are asked for the pipelines of each commit in
+// addition to the pipelines referenced by the statuses of the commit
+type PipelineLister interface {
+	// Return the web URL of the pipelines associated to the commit designated by sha
+	PipelineURLs(ctx context.Context, sha string) ([]string, error)
+}
+
+// CI providers implementing IncrementalBuilder are polled with the version of the source
+// returned by their previous successful poll so that they can avoid transferring pipelines
+// that did not change since then
+type IncrementalBuilder interface {
+	// Return the pipeline designated by the url u and the version of the source it was read
+	// from, or ErrNotModified if the source did not change since the version 'since'. A zero
+	// value for 'since' means the pipeline is always returned.
+	BuildFromURLSince(ctx context.Context, u string, since SourceVersion) (Pipeline, SourceVersion, error)
+}
+
+// Version of the source of a pipeline, as returned by an IncrementalBuilder
+type SourceVersion struct {
+	// Time of the poll, according to the clock of the cache. This is set by the cache and
+	// is only meaningful to compare with local modification times such as those of files.
+	PolledAt time.Time
+	// Validators of the provider, sent back verbatim so that the comparison is done with
+	// the clock of the server: for example the Last-Modified and ETag headers of an HTTP
+	// response, or the update time of the pipeline according to the server
+	LastModified string
+	ETag         string
+}
+
+// Return true if v designates no version at all
+func (v SourceVersion) IsZero() bool {
+	return v.PolledAt.IsZero() && v.LastModified == "" && v.ETag == ""
+}
+
+var ErrNotModified = errors.New("pipeline not modified")
+
 type SourceProvider interface {
 	// Unique identifier of the Provider instance among all other instances
 	ID() string
+	// Return true if the repository designated by url may be hosted by the Provider
+	HostsRepository(url string) bool
 	RefStatuses(ctx context.Context, url string, ref string, sha string) ([]string, error)
 	Commit(ctx context.Context, repo string, sha string) (Commit, error)
 }
 
-// Poll Provider at increasing interval for the url of statuses associated to "ref"
-func monitorRefStatuses(ctx context.Context, p SourceProvider, s utils.PollingStrategy, remoteName string, url string, ref string, commitc chan<- Commit) error {
-	commit, err := p.Commit(ctx, url, ref)
+// Poll Provider at increasing interval for the url of statuses associated to "ref". Each
+// request is canceled if it takes longer than 'timeout'.
+func monitorRefStatuses(ctx context.Context, p SourceProvider, s utils.PollingStrategy, clock utils.Clock, timeout time.Duration, remoteName string, url string, ref string, commitc chan<- Commit) error {
+	reqCtx, cancel := requestContext(ctx, timeout)
+	commit, err := p.Commit(reqCtx, url, ref)
+	cancel()
 	if err != nil {
 		return err
 	}
@@ -72,16 +129,23 @@ func monitorRefStatuses(ctx context.Context, p SourceProvider, s utils.PollingSt
 
 	for waitTime := time.Duration(0); s.Forever || waitTime < s.MaxInterval; waitTime = s.NextInterval(waitTime) {
 		select {
-		case <-time.After(waitTime):
+		case <-clock.After(waitTime):
 			// Do nothing
 		case <-ctx.Done():
 			return ctx.Err()
 		}
 
-		statuses, err := p.RefStatuses(ctx, url, ref, commit.Sha)
+		var statuses []string
+		err := withRetries(ctx, clock, func() error {
+			reqCtx, cancel := requestContext(ctx, timeout)
+			defer cancel()
+			var err error
+			statuses, err = p.RefStatuses(reqCtx, url, ref, commit.Sha)
+			return err
+		})
 		if err != nil {
 			if err != ErrUnknownRepositoryURL && err != context.Canceled {
-				err = fmt.Errorf("provider %s: %v (%s@%s)", p.ID(), err, ref, url)
+				err = fmt.Errorf("provider %s: fetch statuses of %s@%s: %w", p.ID(), ref, url, err)
 			}
 			return err
 		}
@@ -213,13 +277,14 @@ func ResolveCommit(path string, ref string) (Commit, error) {
 	}
 
 	c := Commit{
-		Sha:      commit.Hash.String(),
-		Author:   commit.Author.String(),
-		Date:     commit.Author.When,
-		Message:  commit.Message,
-		Branches: nil,
-		Tags:     nil,
-		Head:     head.Name().Short(),
+		Sha:       commit.Hash.String(),
+		Author:    commit.Author.String(),
+		Committer: commit.Committer.String(),
+		Date:      commit.Author.When,
+		Message:   commit.Message,
+		Branches:  nil,
+		Tags:      nil,
+		Head:      head.Name().Short(),
 	}
 
 	refs, err := r.References()
@@ -298,18 +363,53 @@ type Cache struct {
 	ciProvidersByID map[string]CIProvider
 	sourceProviders []SourceProvider
 	pollStrat       utils.PollingStrategy
-	mutex           *sync.Mutex
-	// All the following data structures must be accessed after acquiring mutex
+	mutex           *sync.RWMutex
+	// All the following data structures must be accessed after acquiring mutex, for writing
+	// if they are modified and for reading otherwise.
+	// Pipelines and commits are never modified in place: each change stores a new value whose
+	// slices are distinct from those of the previous value. Values returned by the cache can
+	// therefore be read by other goroutines without holding the mutex, as long as they do
+	// not modify them in place either.
+	limits        *pipelineLimits
+	refFilter     *refFilter
 	commitsByRef  map[string]Commit
 	pipelineByKey map[PipelineKey]*Pipeline
 	pipelineBySha map[string]map[PipelineKey]*Pipeline
+	// Last time each pipeline was saved or returned by Pipelines()
+	usedAt   *pipelineUsage
+	eviction *EvictionPolicy
+	// Time of the last successful poll of each pipeline URL, by provider ID
+	watermarks map[string]map[string]watermark
+	// Time of the next poll of the pipelines whose last poll failed with a transient error
+	retryAt map[PipelineKey]time.Time
+	// Copy of the cache on disk, nil unless persistence is enabled
+	store *diskStore
+	// Held while copying the commits and writing them to the store so that an older copy
+	// never replaces a newer one on disk
+	commitWrites *sync.Mutex
+	// Recent runs of each job, kept after their pipeline is evicted
+	jobRuns map[jobKey][]jobRun
+	// Receivers of the changes of the cache, see Subscribe()
+	subscriptions *subscriptions
+	// Maximum duration of each request to a provider, zero meaning no limit
+	requestTimeout *time.Duration
+	// Source of the time used for polling, eviction and retries
+	clock utils.Clock
+	// Polls of pipelines by provider ID, see Stats()
+	polls map[string]pollStats
+	// Traversable state of the steps of each pipeline in the user interface, see SaveFoldState()
+	folds map[PipelineKey]map[string]bool
 }
 
 type Configuration struct {
-	Polling struct {
+	MaxPipelines   int      `toml:"max-pipelines"`
+	Refs           []string `toml:"refs"`
+	RequestTimeout int      `toml:"request-timeout"`
+	Polling        struct {
 		InitialInterval int  `toml:"initial-interval"`
 		MaxInterval     int  `toml:"max-interval"`
 		Forever         bool `toml:"forever"`
+		RefreshOnPush   bool `toml:"refresh-on-push"`
 	}
 	GitLab []struct {
 		Name              string   `toml:"name" default:"gitlab"`
@@ -318,35 +418,106 @@ type Configuration struct {
 		Token             string   `toml:"token"`
 		TokenFromProcess  []string `toml:"token-from-process"`
 		RequestsPerSecond float64  `toml:"max-requests-per-second"`
+		MaxPipelines      int      `toml:"max-pipelines"`
 	}
 	GitHub []struct {
 		Token            string   `toml:"token"`
 		TokenFromProcess []string `toml:"token-from-process"`
+		CheckApps        []string `toml:"check-apps"`
+		StatusContexts   []string `toml:"status-contexts"`
+		MaxPipelines     int      `toml:"max-pipelines"`
+	}
+	Gitea []struct {
+		Name              string   `toml:"name" default:"gitea"`
+		URL               string   `toml:"url"`
+		SSHHost           string   `toml:"ssh-host"`
+		Token             string   `toml:"token"`
+		TokenFromProcess  []string `toml:"token-from-process"`
+		RequestsPerSecond float64  `toml:"max-requests-per-second"`
+		MaxPipelines      int      `toml:"max-pipelines"`
 	}
 	CircleCI []struct {
 		Name              string   `toml:"name" default:"circleci"`
 		Token             string   `toml:"token"`
 		TokenFromProcess  []string `toml:"token-from-process"`
 		RequestsPerSecond float64  `toml:"max-requests-per-second"`
+		MaxPipelines      int      `toml:"max-pipelines"`
 	}
 	Travis []struct {
 		Name              string   `toml:"name" default:"travis"`
 		URL               string   `toml:"url"`
+		WebURL            string   `toml:"web-url"`
 		Token             string   `toml:"token"`
 		TokenFromProcess  []string `toml:"token-from-process"`
 		RequestsPerSecond float64  `toml:"max-requests-per-second"`
+		MaxPipelines      int      `toml:"max-pipelines"`
 	}
 	AppVeyor []struct {
 		Name              string   `toml:"name" default:"appveyor"`
 		Token             string   `toml:"token"`
 		TokenFromProcess  []string `toml:"token-from-process"`
 		RequestsPerSecond float64  `toml:"max-requests-per-second"`
+		MaxPipelines      int      `toml:"max-pipelines"`
 	}
 	Azure []struct {
 		Name              string   `toml:"name" default:"azure"`
 		Token             string   `toml:"token"`
 		TokenFromProcess  []string `toml:"token-from-process"`
 		RequestsPerSecond float64  `toml:"max-requests-per-second"`
+		MaxPipelines      int      `toml:"max-pipelines"`
+	}
+	Woodpecker []struct {
+		Name              string   `toml:"name" default:"woodpecker"`
+		URL               string   `toml:"url"`
+		Token             string   `toml:"token"`
+		TokenFromProcess  []string `toml:"token-from-process"`
+		RequestsPerSecond float64  `toml:"max-requests-per-second"`
+		MaxPipelines      int      `toml:"max-pipelines"`
+	}
+	Codefresh []struct {
+		Name              string   `toml:"name" default:"codefresh"`
+		URL               string   `toml:"url"`
+		Token             string   `toml:"token"`
+		TokenFromProcess  []string `toml:"token-from-process"`
+		RequestsPerSecond float64  `toml:"max-requests-per-second"`
+		MaxPipelines      int      `toml:"max-pipelines"`
+	}
+	Buddy []struct {
+		Name              string   `toml:"name" default:"buddy"`
+		URL               string   `toml:"url"`
+		APIURL            string   `toml:"api-url"`
+		Token             string   `toml:"token"`
+		TokenFromProcess  []string `toml:"token-from-process"`
+		RequestsPerSecond float64  `toml:"max-requests-per-second"`
+		MaxPipelines      int      `toml:"max-pipelines"`
+	}
+	Generic []struct {
+		Name              string   `toml:"name" default:"generic"`
+		Source            string   `toml:"source"`
+		Token             string   `toml:"token"`
+		TokenFromProcess  []string `toml:"token-from-process"`
+		RequestsPerSecond float64  `toml:"max-requests-per-second"`
+		MaxPipelines      int      `toml:"max-pipelines"`
+	}
+	Plugin []struct {
+		Name         string   `toml:"name" default:"plugin"`
+		Command      []string `toml:"command"`
+		MaxPipelines int      `toml:"max-pipelines"`
+	}
+	Local []struct {
+		Name         string `toml:"name" default:"local"`
+		File         string `toml:"file"`
+		Runner       string `toml:"runner"`
+		MaxPipelines int    `toml:"max-pipelines"`
+	}
+	Screwdriver []struct {
+		Name              string   `toml:"name" default:"screwdriver"`
+		URL               string   `toml:"url"`
+		APIURL            string   `toml:"api-url"`
+		Token             string   `toml:"token"`
+		TokenFromProcess  []string `toml:"token-from-process"`
+		RequestsPerSecond float64  `toml:"max-requests-per-second"`
+		MaxPipelines      int      `toml:"max-pipelines"`
 	}
 }
 
@@ -369,6 +540,7 @@ func token(token string, process []string) (string, error) {
 func (c Configuration) ToCache(ctx context.Context) (Cache, error) {
 	source := make([]SourceProvider, 0)
 	ci := make([]CIProvider, 0)
+	maxPipelines := make(map[string]int)
 
 	for i, conf := range c.GitLab {
 		id := fmt.Sprintf("gitlab-%d", i)
@@ -382,6 +554,7 @@ func (c Configuration) ToCache(ctx context.Context) (Cache, error) {
 		}
 		source = append(source, client)
 		ci = append(ci, client)
+		maxPipelines[id] = conf.MaxPipelines
 	}
 
 	for i, conf := range c.GitHub {
@@ -390,8 +563,27 @@ func (c Configuration) ToCache(ctx context.Context) (Cache, error) {
 		if err != nil {
 			return Cache{}, err
 		}
-		client := NewGitHubClient(ctx, id, &token)
+		client := NewGitHubClient(ctx, id, &token, conf.CheckApps, conf.StatusContexts)
+		source = append(source, client)
+		if len(conf.CheckApps) > 0 || len(conf.StatusContexts) > 0 {
+			ci = append(ci, client)
+			maxPipelines[id] = conf.MaxPipelines
+		}
+	}
+
+	for i, conf := range c.Gitea {
+		id := fmt.Sprintf("gitea-%d", i)
+		token, err := token(conf.Token, conf.TokenFromProcess)
+		if err != nil {
+			return Cache{}, err
+		}
+		client, err := NewGiteaClient(id, conf.Name, token, conf.URL, conf.RequestsPerSecond, conf.SSHHost)
+		if err != nil {
+			return Cache{}, err
+		}
 		source = append(source, client)
+		ci = append(ci, client)
+		maxPipelines[id] = conf.MaxPipelines
 	}
 
 	for i, conf := range c.CircleCI {
@@ -402,6 +594,7 @@ func (c Configuration) ToCache(ctx context.Context) (Cache, error) {
 		}
 		client := NewCircleCIClient(id, conf.Name, token, conf.RequestsPerSecond)
 		ci = append(ci, client)
+		maxPipelines[id] = conf.MaxPipelines
 	}
 
 	for i, conf := range c.AppVeyor {
@@ -412,6 +605,7 @@ func (c Configuration) ToCache(ctx context.Context) (Cache, error) {
 		}
 		client := NewAppVeyorClient(id, conf.Name, token, conf.RequestsPerSecond)
 		ci = append(ci, client)
+		maxPipelines[id] = conf.MaxPipelines
 	}
 
 	for i, conf := range c.Travis {
@@ -420,11 +614,12 @@ func (c Configuration) ToCache(ctx context.Context) (Cache, error) {
 		if err != nil {
 			return Cache{}, err
 		}
-		client, err := NewTravisClient(id, conf.Name, token, conf.URL, conf.RequestsPerSecond)
+		client, err := NewTravisClient(id, conf.Name, token, conf.URL, conf.WebURL, conf.RequestsPerSecond)
 		if err != nil {
 			return Cache{}, err
 		}
 		ci = append(ci, client)
+		maxPipelines[id] = conf.MaxPipelines
 	}
 
 	for i, conf := range c.Azure {
@@ -435,6 +630,103 @@ func (c Configuration) ToCache(ctx context.Context) (Cache, error) {
 		}
 		client := NewAzurePipelinesClient(id, conf.Name, token, conf.RequestsPerSecond)
 		ci = append(ci, client)
+		maxPipelines[id] = conf.MaxPipelines
+	}
+
+	for i, conf := range c.Woodpecker {
+		id := fmt.Sprintf("woodpecker-%d", i)
+		token, err := token(conf.Token, conf.TokenFromProcess)
+		if err != nil {
+			return Cache{}, err
+		}
+		client, err := NewWoodpeckerClient(id, conf.Name, token, conf.URL, conf.RequestsPerSecond)
+		if err != nil {
+			return Cache{}, err
+		}
+		ci = append(ci, client)
+		maxPipelines[id] = conf.MaxPipelines
+	}
+
+	for i, conf := range c.Screwdriver {
+		id := fmt.Sprintf("screwdriver-%d", i)
+		token, err := token(conf.Token, conf.TokenFromProcess)
+		if err != nil {
+			return Cache{}, err
+		}
+		client, err := NewScrewdriverClient(id, conf.Name, token, conf.URL, conf.APIURL, conf.RequestsPerSecond)
+		if err != nil {
+			return Cache{}, err
+		}
+		ci = append(ci, client)
+		maxPipelines[id] = conf.MaxPipelines
+	}
+
+	for i, conf := range c.Generic {
+		id := fmt.Sprintf("generic-%d", i)
+		token, err := token(conf.Token, conf.TokenFromProcess)
+		if err != nil {
+			return Cache{}, err
+		}
+		client, err := NewGenericClient(id, conf.Name, token, conf.Source, conf.RequestsPerSecond)
+		if err != nil {
+			return Cache{}, err
+		}
+		ci = append(ci, client)
+		maxPipelines[id] = conf.MaxPipelines
+	}
+
+	for i, conf := range c.Plugin {
+		id := fmt.Sprintf("plugin-%d", i)
+		client, err := NewPluginClient(ctx, id, conf.Name, conf.Command)
+		if err != nil {
+			return Cache{}, err
+		}
+		ci = append(ci, client)
+		maxPipelines[id] = conf.MaxPipelines
+	}
+
+	for i, conf := range c.Local {
+		id := fmt.Sprintf("local-%d", i)
+		var client LocalClient
+		var err error
+		if conf.Runner == "" {
+			client, err = NewLocalClient(ctx, id, conf.Name, conf.File)
+		} else {
+			client, err = NewLocalCIClient(ctx, id, conf.Name, conf.File, conf.Runner)
+		}
+		if err != nil {
+			return Cache{}, err
+		}
+		ci = append(ci, client)
+		maxPipelines[id] = conf.MaxPipelines
+	}
+
+	for i, conf := range c.Codefresh {
+		id := fmt.Sprintf("codefresh-%d", i)
+		token, err := token(conf.Token, conf.TokenFromProcess)
+		if err != nil {
+			return Cache{}, err
+		}
+		client, err := NewCodefreshClient(id, conf.Name, token, conf.URL, conf.RequestsPerSecond)
+		if err != nil {
+			return Cache{}, err
+		}
+		ci = append(ci, client)
+		maxPipelines[id] = conf.MaxPipelines
+	}
+
+	for i, conf := range c.Buddy {
+		id := fmt.Sprintf("buddy-%d", i)
+		token, err := token(conf.Token, conf.TokenFromProcess)
+		if err != nil {
+			return Cache{}, err
+		}
+		client, err := NewBuddyClient(id, conf.Name, token, conf.URL, conf.APIURL, conf.RequestsPerSecond)
+		if err != nil {
+			return Cache{}, err
+		}
+		ci = append(ci, client)
+		maxPipelines[id] = conf.MaxPipelines
 	}
 
 	if len(ci) == 0 || len(source) == 0 {
@@ -446,7 +738,22 @@ func (c Configuration) ToCache(ctx context.Context) (Cache, error) {
 		return Cache{}, err
 	}
 
-	return NewCache(ci, source, s), nil
+	cache := NewCache(ci, source, s)
+	switch {
+	case c.RequestTimeout < 0:
+		return Cache{}, errors.New("request timeout must be greater than 0s")
+	case c.RequestTimeout > 0:
+		cache.SetRequestTimeout(time.Duration(c.RequestTimeout) * time.Second)
+	}
+	cache.SetGlobalMaxPipelines(c.MaxPipelines)
+	if err := cache.SetRefFilter(c.Refs); err != nil {
+		return Cache{}, err
+	}
+	for id, n := range maxPipelines {
+		cache.SetMaxPipelines(id, n)
+	}
+
+	return cache, nil
 }
 
 var ErrNoProvider = errors.New("list of providers must not be empty")
@@ -457,17 +764,57 @@ func NewCache(CIProviders []CIProvider, sourceProviders []SourceProvider, strate
 		providersByAccountID[provider.ID()] = provider
 	}
 
+	requestTimeout := defaultRequestTimeout
+
 	return Cache{
 		pollStrat:       strategy,
+		limits:          &pipelineLimits{byProviderID: make(map[string]int)},
+		refFilter:       &refFilter{},
 		commitsByRef:    make(map[string]Commit),
 		pipelineByKey:   make(map[PipelineKey]*Pipeline),
 		pipelineBySha:   make(map[string]map[PipelineKey]*Pipeline),
-		mutex:           &sync.Mutex{},
+		usedAt:          newPipelineUsage(),
+		watermarks:      make(map[string]map[string]watermark),
+		retryAt:         make(map[PipelineKey]time.Time),
+		polls:           make(map[string]pollStats),
+		folds:           make(map[PipelineKey]map[string]bool),
+		eviction:        &EvictionPolicy{},
+		jobRuns:         make(map[jobKey][]jobRun),
+		subscriptions:   newSubscriptions(),
+		requestTimeout:  &requestTimeout,
+		clock:           utils.SystemClock{},
+		mutex:           &sync.RWMutex{},
+		commitWrites:    &sync.Mutex{},
 		ciProvidersByID: providersByAccountID,
 		sourceProviders: sourceProviders,
 	}
 }
 
+// Return an empty cache using the providers and the settings of c: polling strategy, request
+// timeout, eviction policy, limits on the number of pipelines and reference filter. Setting up
+// providers may run commands asking the user for tokens, so caches of repositories monitored
+// at the same time share the providers instead of setting them up again.
+func (c *Cache) EmptyCopy() Cache {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+
+	ciProviders := make([]CIProvider, 0, len(c.ciProvidersByID))
+	for _, p := range c.ciProvidersByID {
+		ciProviders = append(ciProviders, p)
+	}
+	cache := NewCache(ciProviders, c.sourceProviders, c.pollStrat)
+	*cache.requestTimeout = *c.requestTimeout
+	*cache.eviction = *c.eviction
+	cache.limits.global = c.limits.global
+	for id, n := range c.limits.byProviderID {
+		cache.limits.byProviderID[id] = n
+	}
+	cache.refFilter.patterns = c.refFilter.patterns
+	cache.clock = c.clock
+
+	return cache
+}
+
 var ErrObsoleteBuild = errors.New("build to save is older than current build in cache")
 
 // Store build in  If a build from the same Provider and with the same ID is
@@ -475,48 +822,367 @@ var ErrObsoleteBuild = errors.New("build to save is older than current build in
 // than the build in  If the build to save is older than the build in cache,
 // SavePipeline will return ErrObsoleteBuild.
 func (c *Cache) SavePipeline(sha string, p Pipeline) (PipelineChanges, error) {
+	changes, err := c.savePipeline(sha, p)
+	if err == nil {
+		c.persistPipeline(p.Key())
+	}
+
+	return changes, err
+}
+
+// Copy the pipeline identified by key to the disk store if persistence is enabled. Failing
+// to write to the store does not prevent the cache from working so errors are ignored.
+func (c *Cache) persistPipeline(key PipelineKey) {
+	if c.store == nil {
+		return
+	}
+	c.mutex.RLock()
+	p, exists := c.pipelineByKey[key]
+	var pipeline Pipeline
+	var sha string
+	folds := c.folds[key]
+	if exists {
+		pipeline = *p
+		for s, pipelines := range c.pipelineBySha {
+			if _, ok := pipelines[key]; ok {
+				sha = s
+				break
+			}
+		}
+	}
+	c.mutex.RUnlock()
+
+	if exists {
+		_ = c.store.savePipeline(sha, pipeline, folds, c.clock.Now())
+	}
+}
+
+// Record the traversable state of the steps of the pipeline identified by key, as returned by
+// tui.HierarchicalTable.FoldState, so that it is saved with the pipeline if persistence is
+// enabled. The state is discarded if the pipeline is not in cache.
+func (c *Cache) SaveFoldState(key PipelineKey, state map[string]bool) {
 	c.mutex.Lock()
 	defer c.mutex.Unlock()
-	var changes PipelineChanges
-
-	if existingBuild, exists := c.pipelineByKey[p.Key()]; exists && existingBuild != nil {
-		changes = p.StatusDiff(*existingBuild)
-
-		// updatedAt refers to the last update of the build and does not reflect an eventual
-		// update of a job so default to always updating an active build
-		isNotAfter := p.UpdatedAt.Valid && existingBuild.UpdatedAt.Valid && !p.UpdatedAt.Time.After(existingBuild.UpdatedAt.Time)
-		if !p.State.IsActive() && isNotAfter {
-			// Point ref to existingBuild
-			if _, exists := c.pipelineBySha[sha]; !exists {
-				c.pipelineBySha[sha] = make(map[PipelineKey]*Pipeline)
-			}
-			if c.pipelineBySha[sha][p.Key()] == existingBuild {
-				return changes, ErrObsoleteBuild
+	if _, exists := c.pipelineByKey[key]; !exists {
+		return
+	}
+	folds := make(map[string]bool, len(state))
+	for k, v := range state {
+		folds[k] = v
+	}
+	c.folds[key] = folds
+}
+
+// Return the traversable state of the steps of each pipeline recorded by SaveFoldState,
+// including the state loaded from the disk store by Persist
+func (c *Cache) FoldState() map[PipelineKey]map[string]bool {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	states := make(map[PipelineKey]map[string]bool, len(c.folds))
+	for key, folds := range c.folds {
+		states[key] = folds
+	}
+	return states
+}
+
+// Enable persistence of the cache to the directory 'dir' and load the pipelines and commits
+// saved there by previous executions so that they can be shown immediately. Pipelines saved
+// more than maxAge ago are discarded. Pipelines of CI providers that are no longer configured
+// are ignored.
+func (c *Cache) Persist(dir string, maxAge time.Duration) error {
+	store := diskStore{dir: dir}
+	pipelines, commitsByRef, err := store.load(maxAge, c.clock.Now())
+	if err != nil {
+		return err
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	for _, stored := range pipelines {
+		if _, exists := c.ciProvidersByID[stored.ProviderID]; !exists {
+			continue
+		}
+		p := stored.Pipeline
+		if _, exists := c.pipelineByKey[p.Key()]; exists {
+			continue
+		}
+		c.pipelineByKey[p.Key()] = &p
+		if _, exists := c.pipelineBySha[stored.Sha]; !exists {
+			c.pipelineBySha[stored.Sha] = make(map[PipelineKey]*Pipeline)
+		}
+		c.pipelineBySha[stored.Sha][p.Key()] = &p
+		c.usedAt.touch(stored.SavedAt, p.Key())
+		c.recordJobRuns(stored.Sha, p)
+		if len(stored.Folds) > 0 {
+			c.folds[p.Key()] = stored.Folds
+		}
+	}
+	c.evict(c.clock.Now())
+	for ref, commit := range commitsByRef {
+		// Forget commits whose pipelines have all expired
+		if _, exists := c.pipelineBySha[commit.Sha]; !exists {
+			continue
+		}
+		if _, exists := c.commitsByRef[ref]; !exists {
+			c.commitsByRef[ref] = commit
+		}
+	}
+	c.store = &store
+
+	return nil
+}
+
+// Write all the pipelines and commits of the cache to the disk store if persistence is
+// enabled. This is meant to be called before exiting so that the latest state of the cache
+// is found by the next execution even if a write failed or was interrupted earlier.
+func (c *Cache) Flush() error {
+	if c.store == nil {
+		return nil
+	}
+
+	c.commitWrites.Lock()
+	defer c.commitWrites.Unlock()
+	c.mutex.RLock()
+	pipelines := make([]storedPipeline, 0, len(c.pipelineByKey))
+	for sha, pipelineByKey := range c.pipelineBySha {
+		for _, p := range pipelineByKey {
+			pipelines = append(pipelines, storedPipeline{Sha: sha, Pipeline: *p, Folds: c.folds[p.Key()]})
+		}
+	}
+	commitsByRef := make(map[string]Commit, len(c.commitsByRef))
+	for ref, commit := range c.commitsByRef {
+		commitsByRef[ref] = commit
+	}
+	c.mutex.RUnlock()
+
+	now := c.clock.Now()
+	for _, p := range pipelines {
+		if err := c.store.savePipeline(p.Sha, p.Pipeline, p.Folds, now); err != nil {
+			return err
+		}
+	}
+
+	return c.store.saveCommits(commitsByRef)
+}
+
+// Store p in cache unless the pipeline in cache is more recent. The comparison of both
+// pipelines can be slow for large pipelines so it is done while holding the read lock only,
+// the write lock being taken to store the result. If the pipeline in cache was replaced in
+// the meantime, the comparison is done again.
+func (c *Cache) savePipeline(sha string, p Pipeline) (PipelineChanges, error) {
+	key := p.Key()
+	normalized := p
+	normalized.Step = p.Step.withChildrenStates().withChildrenDurations()
+
+	for {
+		c.mutex.RLock()
+		existing := c.pipelineByKey[key]
+		c.mutex.RUnlock()
+
+		var changes PipelineChanges
+		var events []Event
+		obsolete := false
+		if existing != nil {
+			changes = p.StatusDiff(*existing)
+
+			// updatedAt refers to the last update of the build and does not reflect an eventual
+			// update of a job so default to always updating an active build
+			isNotAfter := p.UpdatedAt.Valid && existing.UpdatedAt.Valid && !p.UpdatedAt.Time.After(existing.UpdatedAt.Time)
+			obsolete = !p.State.IsActive() && isNotAfter
+			if !obsolete {
+				events = stateEvents(key, normalized.Step, existing.Step, nil)
+				if diff := existing.Diff(normalized); diff != "" {
+					events = append(events, Event{Type: PipelineUpdated, PipelineKey: key})
+				}
 			}
-			c.pipelineBySha[sha][p.Key()] = existingBuild
-			return changes, nil
+		} else {
+			changes = p.StatusDiff(Pipeline{})
+			events = append([]Event{{Type: PipelineAdded, PipelineKey: key}}, stateEvents(key, normalized.Step, Step{}, nil)...)
+		}
+
+		if stored, err := c.storePipeline(sha, normalized, existing, obsolete, events); stored {
+			return changes, err
 		}
-	} else {
-		changes = p.StatusDiff(Pipeline{})
 	}
+}
 
-	c.pipelineByKey[p.Key()] = &p
-	// Point ref to new build
+// Store p in cache and publish events if 'previous' is still the pipeline in cache with the
+// same key. Otherwise return false without changing anything. If 'obsolete' is true, p is
+// not stored and the commit designated by sha is linked to 'previous' instead.
+func (c *Cache) storePipeline(sha string, p Pipeline, previous *Pipeline, obsolete bool, events []Event) (bool, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	key := p.Key()
+	if c.pipelineByKey[key] != previous {
+		return false, nil
+	}
 	if _, exists := c.pipelineBySha[sha]; !exists {
 		c.pipelineBySha[sha] = make(map[PipelineKey]*Pipeline)
 	}
-	c.pipelineBySha[sha][p.Key()] = &p
+	now := c.clock.Now()
+
+	if obsolete {
+		// Point ref to the pipeline in cache
+		if c.pipelineBySha[sha][key] == previous {
+			return true, ErrObsoleteBuild
+		}
+		c.pipelineBySha[sha][key] = previous
+		c.usedAt.touch(now, key)
+		return true, nil
+	}
+
+	c.subscriptions.publish(events...)
+	c.pipelineByKey[key] = &p
+	c.recordJobRuns(sha, p)
+	// Point ref to new build
+	c.pipelineBySha[sha][key] = &p
+	c.usedAt.touch(now, key)
+	c.evict(now)
+
+	return true, nil
+}
+
+// Last time each pipeline was saved or returned by the cache. Pipelines are returned while
+// holding the read lock of the cache so this map has a mutex of its own, always acquired
+// after the mutex of the cache.
+type pipelineUsage struct {
+	mutex *sync.Mutex
+	at    map[PipelineKey]time.Time
+}
+
+func newPipelineUsage() *pipelineUsage {
+	return &pipelineUsage{
+		mutex: &sync.Mutex{},
+		at:    make(map[PipelineKey]time.Time),
+	}
+}
+
+func (u *pipelineUsage) touch(at time.Time, keys ...PipelineKey) {
+	u.mutex.Lock()
+	defer u.mutex.Unlock()
+	for _, key := range keys {
+		u.at[key] = at
+	}
+}
+
+func (u *pipelineUsage) get(key PipelineKey) time.Time {
+	u.mutex.Lock()
+	defer u.mutex.Unlock()
+	return u.at[key]
+}
+
+func (u *pipelineUsage) forget(key PipelineKey) {
+	u.mutex.Lock()
+	defer u.mutex.Unlock()
+	delete(u.at, key)
+}
+
+// Limits on the pipelines kept in memory by the cache. Pipelines evicted from the cache are
+// fetched again from their provider the next time the pipelines of their commit are
+// monitored.
+type EvictionPolicy struct {
+	// Maximum number of pipelines in cache. Zero means no limit.
+	MaxPipelines int
+	// Pipelines that were neither updated nor shown for longer than MaxAge are evicted. Zero
+	// means no limit.
+	MaxAge time.Duration
+}
+
+// Cancel requests to providers that take longer than 'timeout'. Zero means no limit.
+func (c *Cache) SetRequestTimeout(timeout time.Duration) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	*c.requestTimeout = timeout
+}
+
+func (c *Cache) timeout() time.Duration {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	return *c.requestTimeout
+}
+
+// Use 'clock' instead of the clock of the system. This must be called before the cache is
+// used or copied.
+func (c *Cache) SetClock(clock utils.Clock) {
+	c.clock = clock
+}
+
+// Return the clock used by the cache
+func (c Cache) Clock() utils.Clock {
+	return c.clock
+}
+
+func (c *Cache) SetEvictionPolicy(policy EvictionPolicy) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	*c.eviction = policy
+	c.evict(c.clock.Now())
+}
+
+// Remove pipelines from the cache according to the eviction policy, least recently used
+// first. Active pipelines are never evicted since they are still being monitored.
+// The caller must hold the write lock of c.mutex.
+func (c *Cache) evict(now time.Time) {
+	if c.eviction.MaxPipelines <= 0 && c.eviction.MaxAge <= 0 {
+		return
+	}
+
+	candidates := make([]PipelineKey, 0)
+	for key, p := range c.pipelineByKey {
+		if !p.State.IsActive() {
+			candidates = append(candidates, key)
+		}
+	}
+	sort.Slice(candidates, func(i, j int) bool {
+		return c.usedAt.get(candidates[i]).Before(c.usedAt.get(candidates[j]))
+	})
+
+	excess := 0
+	if c.eviction.MaxPipelines > 0 {
+		excess = len(c.pipelineByKey) - c.eviction.MaxPipelines
+	}
+	for _, key := range candidates {
+		expired := c.eviction.MaxAge > 0 && now.Sub(c.usedAt.get(key)) > c.eviction.MaxAge
+		if excess <= 0 && !expired {
+			break
+		}
+		delete(c.pipelineByKey, key)
+		c.usedAt.forget(key)
+		delete(c.retryAt, key)
+		delete(c.folds, key)
+		for sha, pipelines := range c.pipelineBySha {
+			delete(pipelines, key)
+			if len(pipelines) == 0 {
+				delete(c.pipelineBySha, sha)
+			}
+		}
+		excess--
+	}
 
-	return changes, nil
+	for _, urls := range c.watermarks {
+		for u, w := range urls {
+			if _, exists := c.pipelineByKey[w.key]; !exists {
+				delete(urls, u)
+			}
+		}
+	}
 }
 
 // Store commit in  If a commit with the same SHA exists, merge
 // both commits.
 func (c *Cache) SaveCommit(ref string, commit Commit) {
+	// The commits are copied under the lock and written to the disk store once it is released
+	// so that readers of the cache do not wait for the disk
+	c.commitWrites.Lock()
+	defer c.commitWrites.Unlock()
+	var commitsByRef map[string]Commit
 	c.mutex.Lock()
-	defer c.mutex.Unlock()
-
 	if previousCommit, exists := c.commitsByRef[ref]; exists && previousCommit.Sha == commit.Sha {
+		// The slices of the previous commit may be read by other goroutines so they are
+		// copied before being appended to
+		previousCommit.Branches = append([]string(nil), previousCommit.Branches...)
+		previousCommit.Tags = append([]string(nil), previousCommit.Tags...)
 		previousBranches := make(map[string]struct{})
 		for _, b := range previousCommit.Branches {
 			previousBranches[b] = struct{}{}
@@ -541,32 +1207,166 @@ func (c *Cache) SaveCommit(ref string, commit Commit) {
 	} else {
 		c.commitsByRef[ref] = commit
 	}
+	c.subscriptions.publish(Event{Type: CommitSaved, Ref: ref, Commit: c.commitsByRef[ref]})
+
+	if c.store != nil {
+		commitsByRef = make(map[string]Commit, len(c.commitsByRef))
+		for ref, commit := range c.commitsByRef {
+			commitsByRef[ref] = commit
+		}
+	}
+	c.mutex.Unlock()
+
+	if commitsByRef != nil {
+		// Best effort, see persistPipeline()
+		_ = c.store.saveCommits(commitsByRef)
+	}
 }
 
 func (c Cache) Commit(ref string) (Commit, bool) {
-	c.mutex.Lock()
-	defer c.mutex.Unlock()
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
 	commit, exists := c.commitsByRef[ref]
 	return commit, exists
 }
 
-func (c Cache) Pipelines(ref string) []Pipeline {
+// Maximum number of pipelines returned by Cache.Pipelines for a CI provider or for all
+// providers together. Zero means no limit.
+type pipelineLimits struct {
+	global       int
+	byProviderID map[string]int
+}
+
+// Limit the number of pipelines of the CI provider identified by 'providerID' returned by
+// Pipelines() to the 'n' most recent ones. If n is zero, all pipelines are returned.
+func (c *Cache) SetMaxPipelines(providerID string, n int) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.limits.byProviderID[providerID] = utils.MaxInt(n, 0)
+}
+
+// Limit the total number of pipelines returned by Pipelines() to the 'n' most recent ones.
+// If n is zero, all pipelines are returned.
+func (c *Cache) SetGlobalMaxPipelines(n int) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.limits.global = utils.MaxInt(n, 0)
+}
+
+// Only keep pipelines whose git reference (branch or tag) matches one of the patterns. Patterns
+// are either names of references or glob patterns such as "release/*". An empty list of patterns
+// disables the filter.
+func (c *Cache) SetRefFilter(patterns []string) error {
+	for _, pattern := range patterns {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("invalid reference pattern %q: %w", pattern, err)
+		}
+	}
 	c.mutex.Lock()
 	defer c.mutex.Unlock()
+	c.refFilter.patterns = patterns
+
+	return nil
+}
+
+type refFilter struct {
+	patterns []string
+}
+
+// Return true if the pipeline of the git reference 'ref' must be kept. Pipelines whose
+// reference is unknown are always kept.
+func (f refFilter) matches(ref string) bool {
+	if len(f.patterns) == 0 || ref == "" {
+		return true
+	}
+	for _, pattern := range f.patterns {
+		if matched, err := path.Match(pattern, ref); err == nil && matched {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *Cache) GlobalMaxPipelines() int {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	return c.limits.global
+}
+
+func (c Cache) Pipelines(ref string) []Pipeline {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
 
 	commit, exists := c.commitsByRef[ref]
 	if !exists {
 		return nil
 	}
 
-	pipelines := make([]Pipeline, 0, len(c.pipelineBySha[ref]))
-	for _, p := range c.pipelineBySha[commit.Sha] {
-		pipelines = append(pipelines, *p)
+	used := make([]PipelineKey, 0, len(c.pipelineBySha[commit.Sha]))
+	pipelinesByProviderID := make(map[string]Pipelines)
+	for key, p := range c.pipelineBySha[commit.Sha] {
+		if !c.refFilter.matches(p.Ref) {
+			continue
+		}
+		used = append(used, key)
+		pipeline := *p
+		pipeline.Sha = commit.Sha
+		pipeline.Step = c.withFlakyJobs(p.ProviderHost, pipeline.Step, nil)
+		pipeline.CoverageDelta = c.coverageDelta(pipeline)
+		if at, exists := c.retryAt[key]; exists {
+			pipeline.RetryAt = utils.NullTime{Valid: true, Time: at}
+		}
+		pipelinesByProviderID[p.providerID] = append(pipelinesByProviderID[p.providerID], pipeline)
+	}
+	c.usedAt.touch(c.clock.Now(), used...)
+
+	pipelines := make(Pipelines, 0, len(c.pipelineBySha[commit.Sha]))
+	for providerID, ps := range pipelinesByProviderID {
+		pipelines = append(pipelines, ps.MostRecent(c.limits.byProviderID[providerID])...)
+	}
+	pipelines = pipelines.MostRecent(c.limits.global)
+
+	sort.Slice(pipelines, func(i, j int) bool {
+		pi := pipelines[i]
+		pj := pipelines[j]
+		return pi.ProviderHost < pj.ProviderHost || (pi.ProviderHost == pj.ProviderHost && pi.ID < pj.ID)
+	})
+
+	return pipelines
+}
+
+// Return the pipelines of tags found in the cache whatever the commit they were run for,
+// sorted by tag
+func (c Cache) TagPipelines() []Pipeline {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+
+	used := make([]PipelineKey, 0)
+	pipelines := make(Pipelines, 0)
+	for sha, pipelineByKey := range c.pipelineBySha {
+		for key, p := range pipelineByKey {
+			if !p.IsTag || !c.refFilter.matches(p.Ref) {
+				continue
+			}
+			used = append(used, key)
+			pipeline := *p
+			pipeline.Sha = sha
+			pipeline.Step = c.withFlakyJobs(p.ProviderHost, pipeline.Step, nil)
+			pipeline.CoverageDelta = c.coverageDelta(pipeline)
+			if at, exists := c.retryAt[key]; exists {
+				pipeline.RetryAt = utils.NullTime{Valid: true, Time: at}
+			}
+			pipelines = append(pipelines, pipeline)
+		}
 	}
+	c.usedAt.touch(c.clock.Now(), used...)
 
 	sort.Slice(pipelines, func(i, j int) bool {
 		pi := pipelines[i]
 		pj := pipelines[j]
+		if c := compareRefs(pi.Ref, pj.Ref); c != 0 {
+			return c < 0
+		}
 		return pi.ProviderHost < pj.ProviderHost || (pi.ProviderHost == pj.ProviderHost && pi.ID < pj.ID)
 	})
 
@@ -574,23 +1374,50 @@ func (c Cache) Pipelines(ref string) []Pipeline {
 }
 
 // Poll Provider at increasing interval for information about the CI pipeline identified by the url
-// u. A message is sent on the channel 'updates' each time the cache is updated with new information
-// for this specific pipeline.
-func (c *Cache) monitorPipeline(ctx context.Context, sha string, pid string, u string, updates chan<- PipelineChanges) error {
+// u. Changes of the pipeline are published to the subscribers of the cache.
+func (c *Cache) monitorPipeline(ctx context.Context, sha string, pid string, u string) error {
 	p, exists := c.ciProvidersByID[pid]
 	if !exists {
 		return fmt.Errorf("cache does not contain any CI provider with ID %q", pid)
 	}
 
+	var retryAt utils.NullTime
+	var retries int
 	for waitTime, active := time.Duration(0), true; c.pollStrat.Forever || active; waitTime = c.pollStrat.NextInterval(waitTime) {
+		if retryAt.Valid {
+			// The provider told us when to ask again
+			waitTime = retryAt.Time.Sub(c.clock.Now())
+		}
 		select {
-		case <-time.After(waitTime):
+		case <-c.clock.After(waitTime):
 			// Do nothing
 		case <-ctx.Done():
 			return ctx.Err()
 		}
 
-		pipeline, err := p.BuildFromURL(ctx, u)
+		polledAt := c.clock.Now()
+		c.startPoll(p.ID())
+		pipeline, version, err := c.fetchPipeline(ctx, p, u)
+		version.PolledAt = polledAt
+		c.recordPoll(p.ID(), polledAt, c.clock.Now().Sub(polledAt), err)
+		if at, ok := retryTime(err, polledAt); ok && retries < maxRetries {
+			// The provider is overloaded or rate limiting us: keep showing the pipeline
+			// in cache and ask again when the provider says so
+			retries++
+			retryAt = utils.NullTime{Valid: true, Time: at}
+			c.setRetry(p.ID(), u, retryAt)
+			continue
+		}
+		retries = 0
+		retryAt = utils.NullTime{}
+		c.setRetry(p.ID(), u, retryAt)
+		if err == ErrNotModified {
+			// Nothing changed since the last poll: the pipeline in cache is up to date
+			c.setWatermark(p.ID(), u, watermark{version: version, key: pipeline.Key()})
+			c.linkPipeline(sha, pipeline.Key())
+			active = pipeline.State.IsActive()
+			continue
+		}
 		if err != nil {
 			return err
 		}
@@ -598,16 +1425,17 @@ func (c *Cache) monitorPipeline(ctx context.Context, sha string, pid string, u s
 		pipeline.ProviderHost = p.Host()
 		pipeline.ProviderName = p.Name()
 
-		switch changes, err := c.SavePipeline(sha, pipeline); err {
+		c.mutex.RLock()
+		filtered := !c.refFilter.matches(pipeline.Ref)
+		c.mutex.RUnlock()
+		if filtered {
+			// The pipeline is not shown so there is no point in polling it again
+			return nil
+		}
+		c.setWatermark(p.ID(), u, watermark{version: version, key: pipeline.Key()})
+
+		switch _, err := c.SavePipeline(sha, pipeline); err {
 		case nil:
-			if updates != nil {
-				go func() {
-					select {
-					case updates <- changes:
-					case <-ctx.Done():
-					}
-				}()
-			}
 			// If SavePipeline() does not return an error then the build object we just saved
 			// differs from the previous one. This most likely means the pipeline is
 			// currently running so reset the backoff object.
@@ -630,13 +1458,146 @@ func (c *Cache) monitorPipeline(ctx context.Context, sha string, pid string, u s
 	return nil
 }
 
-// Ask all providers to monitor the CI pipeline identified by the url u. A message is sent on the
-// channel 'updates' each time the cache is updated with new information for this specific pipeline.
-// If no Provider is able to handle the specified url, ErrUnknownPipelineURL is returned.
-func (c *Cache) broadcastMonitorPipeline(ctx context.Context, sha string, u string, updates chan<- PipelineChanges) error {
+// Version of the source of a pipeline at its last successful poll and key of the pipeline in
+// cache
+type watermark struct {
+	version SourceVersion
+	key     PipelineKey
+}
+
+// Associate the pipeline identified by key to the commit designated by sha
+func (c *Cache) linkPipeline(sha string, key PipelineKey) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	p, exists := c.pipelineByKey[key]
+	if !exists {
+		return
+	}
+	if _, exists := c.pipelineBySha[sha]; !exists {
+		c.pipelineBySha[sha] = make(map[PipelineKey]*Pipeline)
+	}
+	c.pipelineBySha[sha][key] = p
+	c.usedAt.touch(c.clock.Now(), key)
+}
+
+// Set the time of the next poll of the pipeline last fetched from the url u after a
+// transient error, or forget it if 'at' is not valid
+func (c *Cache) setRetry(providerID string, u string, at utils.NullTime) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	w, exists := c.watermarks[providerID][u]
+	if !exists {
+		// The pipeline was never fetched so it is not shown
+		return
+	}
+	previous, retrying := c.retryAt[w.key]
+	switch {
+	case at.Valid && !(retrying && previous.Equal(at.Time)):
+		c.retryAt[w.key] = at.Time
+	case !at.Valid && retrying:
+		delete(c.retryAt, w.key)
+	default:
+		return
+	}
+	c.subscriptions.publish(Event{Type: PipelineUpdated, PipelineKey: w.key})
+}
+
+func (c *Cache) setWatermark(providerID string, u string, w watermark) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if _, exists := c.watermarks[providerID]; !exists {
+		c.watermarks[providerID] = make(map[string]watermark)
+	}
+	c.watermarks[providerID][u] = w
+}
+
+// Fetch the pipeline designated by the url u from the provider p. If the provider supports
+// incremental polling and the pipeline is still in cache, only ask for a pipeline updated
+// since the last poll. In that case, ErrNotModified is returned along with the cached
+// pipeline if nothing changed. The version of the source is only returned by providers
+// supporting incremental polling.
+func (c *Cache) fetchPipeline(ctx context.Context, p CIProvider, u string) (Pipeline, SourceVersion, error) {
+	if incremental, ok := p.(IncrementalBuilder); ok {
+		c.mutex.RLock()
+		w, exists := c.watermarks[p.ID()][u]
+		var cached *Pipeline
+		if exists {
+			cached = c.pipelineByKey[w.key]
+		}
+		var previous Pipeline
+		if cached != nil {
+			previous = *cached
+		}
+		c.mutex.RUnlock()
+
+		// The pipeline may have been evicted from the cache in which case it must be fetched
+		// entirely
+		if cached != nil {
+			ctx, cancel := requestContext(ctx, c.timeout())
+			defer cancel()
+			pipeline, version, err := incremental.BuildFromURLSince(ctx, u, w.version)
+			if err == ErrNotModified {
+				return previous, version, err
+			}
+			return pipeline, version, err
+		}
+
+		ctx, cancel := requestContext(ctx, c.timeout())
+		defer cancel()
+		return incremental.BuildFromURLSince(ctx, u, SourceVersion{})
+	}
+
+	ctx, cancel := requestContext(ctx, c.timeout())
+	defer cancel()
+	pipeline, err := p.BuildFromURL(ctx, u)
+	return pipeline, SourceVersion{}, err
+}
+
+// Error of a provider that only affects the monitoring of a single pipeline or a single
+// request. Such errors do not stop the monitoring of other pipelines.
+type ProviderError struct {
+	ProviderID string
+	// What was asked to the provider, for example "fetch log of step 'test' of pipeline #12"
+	Operation string
+	URL       string
+	Err       error
+}
+
+func (e ProviderError) Error() string {
+	return fmt.Sprintf("provider %s: %s", e.ProviderID, e.Message())
+}
+
+// Return the description of the error without the ID of the provider
+func (e ProviderError) Message() string {
+	s := e.Err.Error()
+	if e.Operation != "" {
+		s = fmt.Sprintf("%s: %s", e.Operation, s)
+	}
+	if e.URL != "" {
+		s = fmt.Sprintf("%s (%s)", s, e.URL)
+	}
+	return s
+}
+
+func (e ProviderError) Unwrap() error {
+	return e.Err
+}
+
+// Return the name of the pipeline used in error messages, for example "pipeline #12"
+func pipelineName(p Pipeline) string {
+	if p.Number != "" {
+		return "pipeline #" + p.Number
+	}
+	return "pipeline #" + p.ID
+}
+
+// Ask all providers to monitor the CI pipeline identified by the url u. If no Provider is able to
+// handle the specified url, ErrUnknownPipelineURL is returned.
+func (c *Cache) broadcastMonitorPipeline(ctx context.Context, sha string, u string) error {
 	wg := sync.WaitGroup{}
 	errc := make(chan error)
 	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
 	for pid := range c.ciProvidersByID {
 		wg.Add(1)
 		go func(pid string) {
@@ -645,9 +1606,9 @@ func (c *Cache) broadcastMonitorPipeline(ctx context.Context, sha string, u stri
 			// meaning these providers can handle the url they've been given. These calls
 			// will run longer or possibly never return unless their context is canceled or
 			// they encounter an error.
-			if err := c.monitorPipeline(ctx, sha, pid, u, updates); err != nil {
+			if err := c.monitorPipeline(ctx, sha, pid, u); err != nil {
 				if err != ErrUnknownPipelineURL && err != context.Canceled {
-					err = fmt.Errorf("Provider %s: monitorPipeline failed with %v (%s)", pid, err, u)
+					err = ProviderError{ProviderID: pid, Operation: "fetch pipeline", URL: u, Err: err}
 				}
 				errc <- err
 			}
@@ -680,28 +1641,39 @@ func (c *Cache) broadcastMonitorPipeline(ctx context.Context, sha string, u stri
 	return err
 }
 
-// Ask all providers to monitor the statuses of 'ref'. The url of each status is written on the
-// channel urlc once. If no Provider is able to handle the specified url, ErrUnknownRepositoryURL
-// is returned.
+// Ask all providers hosting the repository to monitor the statuses of 'ref'. The url of each status
+// is written on the channel urlc once. If no Provider is able to handle the specified url,
+// ErrUnknownRepositoryURL is returned.
 func (c *Cache) broadcastMonitorRefStatus(ctx context.Context, repositoryURLs map[string][]string, ref string, commitc chan<- Commit) error {
 	errc := make(chan error)
 	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
 	wg := sync.WaitGroup{}
 	requestCount := 0
 	// FIXME Deduplicate repositoryURLs
 	for remoteName, remoteURLs := range repositoryURLs {
 		for _, u := range remoteURLs {
 			for _, p := range c.sourceProviders {
+				// Only query the instances hosting the repository (e.g. gitlab.com and a
+				// self-hosted GitLab instance may both be configured)
+				if !p.HostsRepository(u) {
+					continue
+				}
 				requestCount++
 				wg.Add(1)
-				go func(p SourceProvider, u string) {
+				go func(p SourceProvider, remoteName string, u string) {
 					defer wg.Done()
-					errc <- monitorRefStatuses(ctx, p, c.pollStrat, remoteName, u, ref, commitc)
-				}(p, u)
+					errc <- monitorRefStatuses(ctx, p, c.pollStrat, c.clock, c.timeout(), remoteName, u, ref, commitc)
+				}(p, remoteName, u)
 			}
 		}
 	}
 
+	if requestCount == 0 {
+		cancel()
+		return ErrUnknownRepositoryURL
+	}
+
 	go func() {
 		wg.Wait()
 		close(errc)
@@ -752,16 +1724,28 @@ func (c *Cache) broadcastMonitorRefStatus(ctx context.Context, repositoryURLs ma
 	return err
 }
 
-// Monitor CI pipelines associated to the git reference 'ref'. Every time the cache is
-// updated with new data, a message is sent on the 'updates' channel.
+// Monitor CI pipelines associated to the git reference 'ref'. Every change of the cache is
+// published to its subscribers, see Subscribe().
+// If 'continueOnError' is true, errors of CI providers are published as ProviderFailed events
+// and only stop the monitoring of the pipeline concerned. Otherwise these errors end the
+// monitoring of all pipelines.
 // This function may return ErrUnknownRepositoryURL if none of the source providers is
 // able to handle 'repositoryURL'.
-func (c *Cache) MonitorPipelines(ctx context.Context, repositoryURLs map[string][]string, ref Ref, updates chan<- PipelineChanges) error {
+func (c *Cache) MonitorPipelines(ctx context.Context, repositoryURLs map[string][]string, ref Ref, continueOnError bool) error {
 	commitc := make(chan Commit)
 	errc := make(chan error)
 	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
 	wg := sync.WaitGroup{}
 
+	report := func(err error) {
+		if e, ok := err.(ProviderError); ok && continueOnError {
+			c.subscriptions.publish(Event{Type: ProviderFailed, Err: e})
+			return
+		}
+		errc <- err
+	}
+
 	wg.Add(1)
 	go func() {
 		defer wg.Done()
@@ -790,29 +1774,34 @@ func (c *Cache) MonitorPipelines(ctx context.Context, repositoryURLs map[string]
 		// Ask for monitoring of each url
 		for commit := range commitc {
 			c.SaveCommit(ref.Name, commit)
-			if updates != nil {
-				wg.Add(1)
-				go func() {
-					defer wg.Done()
-					select {
-					case updates <- PipelineChanges{}:
-						// Do nothing
-					case <-ctx.Done():
-						// Do nothing
+			pipelineURLs := commit.Statuses
+			for _, p := range c.ciProvidersByID {
+				if lister, ok := p.(PipelineLister); ok {
+					reqCtx, cancel := requestContext(ctx, c.timeout())
+					us, err := lister.PipelineURLs(reqCtx, commit.Sha)
+					cancel()
+					if err != nil {
+						report(ProviderError{
+							ProviderID: p.ID(),
+							Operation:  fmt.Sprintf("list pipelines of commit %s", commit.Sha),
+							Err:        err,
+						})
+						continue
 					}
-				}()
+					pipelineURLs = append(pipelineURLs, us...)
+				}
 			}
-			for _, u := range commit.Statuses {
+			for _, u := range pipelineURLs {
 				if _, exists := urls[u]; !exists {
 					wg.Add(1)
 					go func(u string) {
 						defer wg.Done()
-						err := c.broadcastMonitorPipeline(ctx, commit.Sha, u, updates)
+						err := c.broadcastMonitorPipeline(ctx, commit.Sha, u)
 						// Ignore ErrUnknownPipelineURL. This error means that we don't integrate
 						// with the application that created that particular url. No need to report
 						// this up the chain, though it's nice to know our request couldn't be handled.
 						if err != ErrUnknownPipelineURL {
-							errc <- err
+							report(err)
 							return
 						}
 					}(u)
@@ -838,16 +1827,47 @@ func (c *Cache) MonitorPipelines(ctx context.Context, repositoryURLs map[string]
 	return err
 }
 
-func (c *Cache) Pipeline(key PipelineKey) (Pipeline, bool) {
+// Store the log of the step identified by key and stepIDs in the pipeline
+func (c *Cache) saveLog(key PipelineKey, stepIDs []string, log string) {
 	c.mutex.Lock()
 	defer c.mutex.Unlock()
 
+	p, exists := c.pipelineByKey[key]
+	if !exists || p == nil {
+		return
+	}
+	// Store a copy since the stored pipeline may be read concurrently without holding the mutex
+	updated := *p
+	updated.Step = p.Step.setLog(stepIDs, log)
+	c.pipelineByKey[key] = &updated
+	for _, pipelines := range c.pipelineBySha {
+		if pipelines[key] == p {
+			pipelines[key] = &updated
+		}
+	}
+	c.subscriptions.publish(Event{
+		Type:        LogAppended,
+		PipelineKey: key,
+		StepIDs:     append([]string(nil), stepIDs...),
+		Log:         log,
+	})
+}
+
+func (c *Cache) Pipeline(key PipelineKey) (Pipeline, bool) {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+
 	p, exists := c.pipelineByKey[key]
 	if !exists || p == nil {
 		return Pipeline{}, false
 	}
+	pipeline := *p
+	pipeline.CoverageDelta = c.coverageDelta(pipeline)
+	if at, exists := c.retryAt[key]; exists {
+		pipeline.RetryAt = utils.NullTime{Valid: true, Time: at}
+	}
 
-	return *p, true
+	return pipeline, true
 }
 
 func (c *Cache) Step(key PipelineKey, stepIDs []string) (Step, bool) {
@@ -873,23 +1893,35 @@ func (c *Cache) Log(ctx context.Context, key PipelineKey, stepIDs []string) (str
 	if !step.Log.Content.Valid {
 		pipeline, exists := c.Pipeline(key)
 		if !exists {
-
+			return "", fmt.Errorf("no matching pipeline for %v", key)
 		}
 		provider, exists := c.ciProvidersByID[pipeline.providerID]
 		if !exists {
 			return "", fmt.Errorf("no matching Provider found in cache for account ID %q", pipeline.providerID)
 		}
 
-		log, err = provider.Log(ctx, step)
+		reqCtx, cancel := requestContext(ctx, c.timeout())
+		log, err = provider.Log(reqCtx, step)
+		cancel()
 		if err != nil {
+			if err != ErrNoLogHere {
+				err = ProviderError{
+					ProviderID: provider.ID(),
+					Operation:  fmt.Sprintf("fetch log of step '%s' of %s", step.Name, pipelineName(pipeline)),
+					URL:        step.WebURL.String,
+					Err:        err,
+				}
+			}
 			return "", err
 		}
 
-		/*if !step.State.IsActive() {
-			if err = c.SaveStep(pKey, stepIDs,accountID, buildID, stageID, job); err != nil {
-				return err
-			}
-		}*/
+		// The log of a finished step will not change anymore so keep it with the pipeline.
+		// This avoids fetching it again and makes it available after a restart if the
+		// cache is persisted.
+		if !step.State.IsActive() && c.store != nil {
+			c.saveLog(key, stepIDs, log)
+			c.persistPipeline(key)
+		}
 	}
 
 	if !strings.HasSuffix(log, "\n") {
@@ -898,3 +1930,331 @@ func (c *Cache) Log(ctx context.Context, key PipelineKey, stepIDs []string) (str
 
 	return log, err
 }
+
+// Turn `aaa\rbbb\rccc\r\n` into `ccc\r\n`
+// This is mostly for Travis logs that contain metadata hidden by carriage returns
+var deleteUntilCarriageReturn = regexp.MustCompile(`.*\r([^\r\n])`)
+
+// https://stackoverflow.com/questions/14693701/how-can-i-remove-the-ansi-escape-sequences-from-a-string-in-python
+var deleteANSIEscapeSequence = regexp.MustCompile(`\x1b[@-_][0-?]*[ -/]*[@-~]`)
+
+// Remove escape sequences and text overwritten by carriage returns so that the log can be
+// shown as plain text
+func PlainLog(log string) string {
+	log = deleteANSIEscapeSequence.ReplaceAllString(log, "")
+	return deleteUntilCarriageReturn.ReplaceAllString(log, "$1")
+}
+
+var unsafePathCharacters = regexp.MustCompile(`[^a-zA-Z0-9._-]+`)
+
+// Return component with every byte other than ASCII letters, digits and dots replaced by '_'
+// followed by its hexadecimal value, e.g. "_2F" for '/'. The result is safe to use in a path,
+// never contains '-' and differs for different components.
+func escapeFilenameComponent(component string) string {
+	var b strings.Builder
+	for i := 0; i < len(component); i++ {
+		switch c := component[i]; {
+		case 'a' <= c && c <= 'z', 'A' <= c && c <= 'Z', '0' <= c && c <= '9', c == '.':
+			b.WriteByte(c)
+		default:
+			fmt.Fprintf(&b, "_%02X", c)
+		}
+	}
+	return b.String()
+}
+
+// Return a name identifying the step designated by key and stepIDs that is safe to use in a
+// path. Components are escaped before being joined with '-' so that different steps never
+// share a name.
+func stepFilename(key PipelineKey, stepIDs []string) string {
+	components := append([]string{key.ProviderHost, key.ID}, stepIDs...)
+	for i, component := range components {
+		components[i] = escapeFilenameComponent(component)
+	}
+	return strings.Join(components, "-")
+}
+
+// Prefix of the names of log files so that they can be told apart from other files of the
+// log directory
+const logFilenamePrefix = "cistern-"
+
+// Return the name of the log file of the step identified by key and stepIDs. Logs of steps
+// that are still running are incomplete and get a distinct name, whose suffix cannot be
+// produced by escapeFilenameComponent.
+func logFilename(key PipelineKey, stepIDs []string, complete bool) string {
+	filename := logFilenamePrefix + stepFilename(key, stepIDs)
+	if !complete {
+		filename += "_partial"
+	}
+
+	return filename + ".log"
+}
+
+// Logs are written by the user interface and by goroutines fetching logs in the background.
+// Writes of the log of a step are serialized so that a writer waiting for another one reuses
+// the log it wrote instead of requesting it again.
+var logWrites = &pathLocks{mutex: &sync.Mutex{}, locks: make(map[string]*pathLock)}
+
+type pathLocks struct {
+	mutex *sync.Mutex
+	locks map[string]*pathLock
+}
+
+type pathLock struct {
+	mutex sync.Mutex
+	// Number of goroutines holding or waiting for the lock
+	users int
+}
+
+// Acquire the lock of 'path' and return the function releasing it
+func (l *pathLocks) lock(path string) func() {
+	l.mutex.Lock()
+	pl, exists := l.locks[path]
+	if !exists {
+		pl = &pathLock{}
+		l.locks[path] = pl
+	}
+	pl.users++
+	l.mutex.Unlock()
+
+	pl.mutex.Lock()
+	return func() {
+		pl.mutex.Unlock()
+		l.mutex.Lock()
+		defer l.mutex.Unlock()
+		if pl.users--; pl.users == 0 {
+			delete(l.locks, path)
+		}
+	}
+}
+
+// Write the log of the step identified by key and stepIDs to a file located in the directory
+// 'dir' and return the path of the file. The directory is created if it does not exist.
+// The log of a finished step is written once: if the file was written after the step finished
+// it is reused without requesting the log from the provider again.
+func (c *Cache) WriteToDirectory(ctx context.Context, key PipelineKey, stepIDs []string, dir string) (string, error) {
+	unlock := logWrites.lock(filepath.Join(dir, stepFilename(key, stepIDs)))
+	defer unlock()
+
+	step, exists := c.Step(key, stepIDs)
+	if !exists {
+		return "", fmt.Errorf("no matching step for %v %v", key, stepIDs)
+	}
+	complete := !step.State.IsActive()
+	logPath := filepath.Join(dir, logFilename(key, stepIDs, complete))
+	if complete {
+		info, err := os.Stat(logPath)
+		if err == nil && info.Mode().IsRegular() && (!step.FinishedAt.Valid || info.ModTime().After(step.FinishedAt.Time)) {
+			return logPath, nil
+		}
+	}
+
+	log, err := c.Log(ctx, key, stepIDs)
+	if err != nil {
+		return "", err
+	}
+	log = PlainLog(log)
+
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return "", err
+	}
+	// Write to a temporary file first so that an interrupted write never leaves a truncated
+	// log that would later be mistaken for a complete one
+	f, err := ioutil.TempFile(dir, filepath.Base(logPath)+".*.tmp")
+	if err != nil {
+		return "", err
+	}
+	if _, err := f.WriteString(log); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return "", err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(f.Name())
+		return "", err
+	}
+	if err := os.Rename(f.Name(), logPath); err != nil {
+		os.Remove(f.Name())
+		return "", err
+	}
+	if complete {
+		partialPath := filepath.Join(dir, logFilename(key, stepIDs, false))
+		// Links to the partial log would be left dangling once it is removed
+		if err := relinkLatestLogs(dir, partialPath, logPath); err != nil {
+			return "", err
+		}
+		if err := os.Remove(partialPath); err != nil && !os.IsNotExist(err) {
+			return "", err
+		}
+	}
+
+	return logPath, nil
+}
+
+// Make 'dir/latest/<repository>/<ref>.log' a symbolic link to the log file at 'logPath' so
+// that the most recent log of a branch can always be found at the same location. If the
+// platform does not support symbolic links, the log file is copied instead.
+func LinkLatestLog(dir string, repository string, ref string, logPath string) (string, error) {
+	return linkLatestLog(dir, repository, ref+latestLogSuffix, logPath)
+}
+
+// Make 'dir/latest/<repository>/<ref>.failed.log' a symbolic link to the log file at 'logPath'
+// so that the log of the most recent failure of a branch can always be found at the same
+// location. If the platform does not support symbolic links, the log file is copied instead.
+func LinkLatestFailedLog(dir string, repository string, ref string, logPath string) (string, error) {
+	return linkLatestLog(dir, repository, ref+latestFailedLogSuffix, logPath)
+}
+
+const (
+	latestLogSuffix       = ".log"
+	latestFailedLogSuffix = ".failed.log"
+)
+
+// Links are written by goroutines fetching logs in the background and must not share their
+// temporary files
+var latestLogsMutex = &sync.Mutex{}
+
+func linkLatestLog(dir string, repository string, name string, logPath string) (string, error) {
+	latestLogsMutex.Lock()
+	defer latestLogsMutex.Unlock()
+
+	repository = unsafePathCharacters.ReplaceAllString(repository, "_")
+	name = unsafePathCharacters.ReplaceAllString(name, "_")
+	linkDir := filepath.Join(dir, "latest", repository)
+	if err := os.MkdirAll(linkDir, 0700); err != nil {
+		return "", err
+	}
+	target, err := filepath.Abs(logPath)
+	if err != nil {
+		return "", err
+	}
+
+	linkPath := filepath.Join(linkDir, name)
+	if err := replaceLink(linkPath, target); err != nil {
+		return "", err
+	}
+
+	return linkPath, nil
+}
+
+// Make 'linkPath' a symbolic link to 'target', or a copy of it if the platform does not
+// support symbolic links
+func replaceLink(linkPath string, target string) error {
+	// Create the new link next to the old one and rename it so that the replacement is atomic
+	tmpPath := linkPath + ".tmp"
+	if err := os.Remove(tmpPath); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if err := os.Symlink(target, tmpPath); err != nil {
+		bs, err := ioutil.ReadFile(target)
+		if err != nil {
+			return err
+		}
+		if err := ioutil.WriteFile(tmpPath, bs, 0600); err != nil {
+			return err
+		}
+	}
+	return os.Rename(tmpPath, linkPath)
+}
+
+// Make the links of 'dir/latest' pointing to the log file at 'oldPath' point to the log file
+// at 'newPath' instead
+func relinkLatestLogs(dir string, oldPath string, newPath string) error {
+	oldTarget, err := filepath.Abs(oldPath)
+	if err != nil {
+		return err
+	}
+	newTarget, err := filepath.Abs(newPath)
+	if err != nil {
+		return err
+	}
+
+	latestLogsMutex.Lock()
+	defer latestLogsMutex.Unlock()
+
+	return filepath.Walk(filepath.Join(dir, "latest"), func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		if info.Mode()&os.ModeSymlink == 0 {
+			return nil
+		}
+		if target, err := os.Readlink(p); err != nil || target != oldTarget {
+			return err
+		}
+		return replaceLink(p, newTarget)
+	})
+}
+
+// Names of the files written by WriteToDirectory: the log files named by logFilename after
+// the provider host, the pipeline ID and the step IDs, and the temporary files they are first
+// written to
+var logFilenameRegexp = regexp.MustCompile(`^cistern-[a-zA-Z0-9._-]+-[a-zA-Z0-9._-]+\.log((\.[0-9]+)?\.tmp)?$`)
+
+// Names of the links written by linkLatestLog and of the temporary files they are first
+// written to
+var latestLogFilenameRegexp = regexp.MustCompile(`^[a-zA-Z0-9._-]+\.log(\.tmp)?$`)
+
+// Age past which a temporary file is considered left over by an interrupted write
+const staleTemporaryFileAge = time.Minute
+
+// Return true if the file was last modified before the limit, or more than
+// staleTemporaryFileAge ago if it is a temporary file
+func isOldLogFile(info os.FileInfo, limit time.Time) bool {
+	if filepath.Ext(info.Name()) == ".tmp" {
+		if tmpLimit := time.Now().Add(-staleTemporaryFileAge); tmpLimit.After(limit) {
+			limit = tmpLimit
+		}
+	}
+	return info.ModTime().Before(limit)
+}
+
+// Remove log files written by WriteToDirectory to the directory 'dir' that were last modified
+// more than 'maxAge' ago, along with the temporary files left by interrupted writes. Other
+// files of the directory are left alone since it may be shared with other programs. A missing
+// directory is not considered an error.
+func RemoveOldLogs(dir string, maxAge time.Duration) error {
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+
+	limit := time.Now().Add(-maxAge)
+	for _, entry := range entries {
+		if entry.Mode().IsRegular() && logFilenameRegexp.MatchString(entry.Name()) && isOldLogFile(entry, limit) {
+			if err := os.Remove(filepath.Join(dir, entry.Name())); err != nil && !os.IsNotExist(err) {
+				return err
+			}
+		}
+	}
+
+	// Remove links to "latest" log files that no longer exist, and old copies of log files
+	// made in their place on platforms without symbolic links. Only the files located at
+	// 'dir/latest/<repository>/<ref>.log' are considered.
+	latestDir := filepath.Join(dir, "latest")
+	return filepath.Walk(latestDir, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		if filepath.Dir(filepath.Dir(p)) != latestDir || !latestLogFilenameRegexp.MatchString(info.Name()) {
+			return nil
+		}
+		if info.Mode()&os.ModeSymlink != 0 {
+			if _, err := os.Stat(p); os.IsNotExist(err) {
+				return os.Remove(p)
+			}
+		} else if info.Mode().IsRegular() && isOldLogFile(info, limit) {
+			return os.Remove(p)
+		}
+		return nil
+	})
+}
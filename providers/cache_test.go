@@ -2,14 +2,19 @@ package providers
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"math/rand"
 	"os"
 	"os/exec"
 	"path"
+	"runtime"
 	"sort"
+	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -176,6 +181,7 @@ func TestCache_Pipelines(t *testing.T) {
 	}
 
 	expected := []Pipeline{pipelines[0]}
+	expected[0].Sha = "sha1"
 	buildRef1 := c.Pipelines("ref1")
 	if diff := Pipelines(expected).Diff(buildRef1); len(diff) > 0 {
 		t.Fatal(diff)
@@ -184,6 +190,9 @@ func TestCache_Pipelines(t *testing.T) {
 	// Point "ref1" to "sha2"
 	c.SaveCommit("ref1", Commit{Sha: "sha2"})
 	expected = []Pipeline{pipelines[1], pipelines[2]}
+	for i := range expected {
+		expected[i].Sha = "sha2"
+	}
 	buildRef1 = c.Pipelines("ref2")
 	sortPipelines(buildRef1)
 	sortPipelines(expected)
@@ -192,12 +201,395 @@ func TestCache_Pipelines(t *testing.T) {
 	}
 }
 
+func TestCache_TagPipelines(t *testing.T) {
+	c := NewCache(nil, nil, utils.PollingStrategy{})
+	saved := map[string]Pipeline{
+		"sha1": {Ref: "v1.10.0", IsTag: true, Step: Step{ID: "1"}},
+		"sha2": {Ref: "master", Step: Step{ID: "2"}},
+		"sha3": {Ref: "v1.9.0", IsTag: true, Step: Step{ID: "3"}},
+	}
+	for sha, p := range saved {
+		if _, err := c.SavePipeline(sha, p); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	pipelines := c.TagPipelines()
+	if len(pipelines) != 2 {
+		t.Fatalf("expected 2 pipelines but got %d", len(pipelines))
+	}
+	for i, sha := range []string{"sha3", "sha1"} {
+		expected := saved[sha]
+		expected.Sha = sha
+		if diff := expected.Diff(pipelines[i]); len(diff) > 0 {
+			t.Fatal(diff)
+		}
+	}
+}
+
 func sortPipelines(pipelines []Pipeline) {
 	sort.Slice(pipelines, func(i, j int) bool {
 		return pipelines[i].ID < pipelines[j].ID
 	})
 }
 
+func TestCache_storePipeline(t *testing.T) {
+	c := NewCache(nil, nil, utils.PollingStrategy{})
+	p := Pipeline{Step: Step{ID: "1", State: Running}}
+	if _, err := c.SavePipeline("sha", p); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("a pipeline compared to a replaced pipeline is not stored", func(t *testing.T) {
+		stale := &Pipeline{Step: Step{ID: "1", State: Pending}}
+		updated := Pipeline{Step: Step{ID: "1", State: Passed}}
+		if stored, err := c.storePipeline("sha", updated, stale, false, nil); stored || err != nil {
+			t.Fatalf("expected (false, nil) but got (%v, %v)", stored, err)
+		}
+		if cached, _ := c.Pipeline(p.Key()); cached.State != Running {
+			t.Fatalf("expected state %q but got %q", Running, cached.State)
+		}
+	})
+
+	t.Run("concurrent saves of the same pipeline all succeed", func(t *testing.T) {
+		wg := sync.WaitGroup{}
+		for i := 0; i < 10; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				p := Pipeline{Step: Step{ID: "1", State: Running, Variables: []Variable{{Name: "N", Value: strconv.Itoa(i)}}}}
+				if _, err := c.SavePipeline("sha", p); err != nil {
+					t.Error(err)
+				}
+			}(i)
+		}
+		wg.Wait()
+		if n := c.Stats().Pipelines; n != 1 {
+			t.Fatalf("expected a single pipeline but got %d", n)
+		}
+	})
+}
+
+// Run with -race: pipelines are saved and their logs appended while other goroutines read them
+// the way the user interface does
+func TestCache_concurrentAccess(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	c := NewCache([]CIProvider{&testProvider{"ci", "ci.example.com", 0}}, nil, utils.PollingStrategy{})
+	events := c.Subscribe(ctx)
+	style := StepStyle{GitStyle: GitStyle{Location: time.UTC}}
+	c.SaveCommit("master", Commit{Sha: "sha", Branches: []string{"master"}})
+
+	const iterations = 200
+	pipeline := func(i int, id string) Pipeline {
+		return Pipeline{
+			providerID:   "ci",
+			ProviderHost: "ci.example.com",
+			Ref:          "master",
+			Step: Step{
+				ID:        id,
+				Type:      StepPipeline,
+				UpdatedAt: utils.NullTime{Valid: true, Time: time.Unix(int64(i), 0)},
+				Variables: []Variable{{Name: "N", Value: strconv.Itoa(i)}},
+				Children: []Step{
+					{
+						ID:   "stage",
+						Type: StepStage,
+						Children: []Step{
+							{ID: "job", Type: StepJob, State: Running},
+						},
+					},
+				},
+			},
+		}
+	}
+
+	wg := sync.WaitGroup{}
+	for _, id := range []string{"1", "2"} {
+		wg.Add(1)
+		go func(id string) {
+			defer wg.Done()
+			for i := 0; i < iterations; i++ {
+				p := pipeline(i, id)
+				if i%2 == 0 {
+					p.Children[0].Children[0].State = Passed
+				}
+				if _, err := c.SavePipeline("sha", p); err != nil && err != ErrObsoleteBuild {
+					t.Error(err)
+					return
+				}
+				c.saveLog(p.Key(), []string{"stage", "job"}, strconv.Itoa(i))
+				c.SaveCommit("master", Commit{Sha: "sha", Branches: []string{"branch" + strconv.Itoa(i)}})
+			}
+		}(id)
+	}
+
+	// Walk every step the way the table does when rendering rows
+	var walk func(s Step)
+	walk = func(s Step) {
+		_ = s.Values(style)
+		for _, child := range s.Children {
+			walk(child)
+		}
+	}
+	readers := sync.WaitGroup{}
+	done := make(chan struct{})
+	readers.Add(2)
+	go func() {
+		defer readers.Done()
+		for {
+			select {
+			case <-done:
+				return
+			default:
+			}
+			for _, p := range c.Pipelines("master") {
+				_ = p.Values(style)
+				walk(p.Step)
+				if q, exists := c.Pipeline(p.Key()); exists {
+					walk(q.Step)
+				}
+			}
+			if commit, exists := c.Commit("master"); exists {
+				_ = strings.Join(commit.Branches, ",")
+			}
+		}
+	}()
+	go func() {
+		defer readers.Done()
+		for {
+			select {
+			case e := <-events:
+				if e.Type == CommitSaved {
+					_ = strings.Join(e.Commit.Branches, ",")
+				}
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	wg.Wait()
+	close(done)
+	readers.Wait()
+
+	if n := len(c.Pipelines("master")); n != 2 {
+		t.Fatalf("expected 2 pipelines but got %d", n)
+	}
+}
+
+func TestCache_concurrentAccessSameKey(t *testing.T) {
+	c := NewCache([]CIProvider{&testProvider{"ci", "ci.example.com", 0}}, nil, utils.PollingStrategy{})
+	c.SaveCommit("master", Commit{Sha: "sha", Branches: []string{"master"}})
+
+	const iterations = 200
+	stepIDs := []string{"stage", "job"}
+	pipeline := func(i int) Pipeline {
+		return Pipeline{
+			providerID:   "ci",
+			ProviderHost: "ci.example.com",
+			Ref:          "master",
+			Step: Step{
+				ID:        "1",
+				Type:      StepPipeline,
+				UpdatedAt: utils.NullTime{Valid: true, Time: time.Unix(int64(i), 0)},
+				Children: []Step{
+					{
+						ID:   "stage",
+						Type: StepStage,
+						Children: []Step{
+							{ID: "job", Type: StepJob, State: Running},
+						},
+					},
+				},
+			},
+		}
+	}
+	key := pipeline(0).Key()
+
+	// Save the pipeline, attach logs to it and read it from several goroutines at once
+	wg := sync.WaitGroup{}
+	wg.Add(3)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < iterations; i++ {
+			if _, err := c.SavePipeline("sha", pipeline(i)); err != nil && err != ErrObsoleteBuild {
+				t.Error(err)
+				return
+			}
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		for i := 0; i < iterations; i++ {
+			c.saveLog(key, stepIDs, strconv.Itoa(i))
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		for i := 0; i < iterations; i++ {
+			if p, exists := c.Pipeline(key); exists {
+				_, _ = p.getStep(stepIDs)
+			}
+			_ = c.Pipelines("master")
+		}
+	}()
+	wg.Wait()
+
+	t.Run("log saved last must be attached to the pipeline of every index", func(t *testing.T) {
+		c.saveLog(key, stepIDs, "log")
+		step, exists := c.Step(key, stepIDs)
+		if !exists || step.Log.Content.String != "log" {
+			t.Fatalf("expected log %q but got %+v", "log", step.Log)
+		}
+		pipelines := c.Pipelines("master")
+		if len(pipelines) != 1 {
+			t.Fatalf("expected 1 pipeline but got %d", len(pipelines))
+		}
+		step, exists = pipelines[0].getStep(stepIDs)
+		if !exists || step.Log.Content.String != "log" {
+			t.Fatalf("expected log %q but got %+v", "log", step.Log)
+		}
+	})
+}
+
+func TestCache_SetRefFilter(t *testing.T) {
+	c := NewCache(nil, nil, utils.PollingStrategy{})
+	c.SaveCommit("master", Commit{Sha: "sha"})
+	for i, ref := range []string{"master", "release/1.0", "feature/a", ""} {
+		p := Pipeline{Ref: ref, Step: Step{ID: strconv.Itoa(i)}}
+		if _, err := c.SavePipeline("sha", p); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	testCases := []struct {
+		patterns []string
+		ids      []string
+	}{
+		{nil, []string{"0", "1", "2", "3"}},
+		{[]string{"master"}, []string{"0", "3"}},
+		{[]string{"master", "release/*"}, []string{"0", "1", "3"}},
+	}
+	for _, testCase := range testCases {
+		t.Run(strings.Join(testCase.patterns, ","), func(t *testing.T) {
+			if err := c.SetRefFilter(testCase.patterns); err != nil {
+				t.Fatal(err)
+			}
+			ids := make([]string, 0)
+			for _, p := range c.Pipelines("master") {
+				ids = append(ids, p.ID)
+			}
+			if diff := cmp.Diff(testCase.ids, ids); len(diff) > 0 {
+				t.Fatal(diff)
+			}
+		})
+	}
+
+	if err := c.SetRefFilter([]string{"["}); err == nil {
+		t.Fatal("expected error for invalid pattern")
+	}
+}
+
+func TestCache_EmptyCopy(t *testing.T) {
+	provider := &testProvider{"ci", "ci.example.com", 0}
+	c := NewCache([]CIProvider{provider}, nil, utils.PollingStrategy{})
+	c.SetRequestTimeout(time.Minute)
+	c.SetGlobalMaxPipelines(3)
+	c.SetMaxPipelines("ci", 2)
+	if err := c.SetRefFilter([]string{"master"}); err != nil {
+		t.Fatal(err)
+	}
+	c.SaveCommit("master", Commit{Sha: "sha"})
+	if _, err := c.SavePipeline("sha", Pipeline{providerID: "ci", Ref: "master", Step: Step{ID: "1"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	copied := c.EmptyCopy()
+	if p, exists := copied.ciProvidersByID["ci"]; !exists || p != CIProvider(provider) {
+		t.Fatalf("expected the provider to be shared but got %v", copied.ciProvidersByID)
+	}
+	if timeout := copied.timeout(); timeout != time.Minute {
+		t.Fatalf("expected timeout %v but got %v", time.Minute, timeout)
+	}
+	expectedLimits := pipelineLimits{global: 3, byProviderID: map[string]int{"ci": 2}}
+	if diff := cmp.Diff(expectedLimits, *copied.limits, cmp.AllowUnexported(pipelineLimits{})); len(diff) > 0 {
+		t.Fatal(diff)
+	}
+	if diff := cmp.Diff([]string{"master"}, copied.refFilter.patterns); len(diff) > 0 {
+		t.Fatal(diff)
+	}
+	if _, exists := copied.Commit("master"); exists {
+		t.Fatal("expected the copy to be empty")
+	}
+
+	// Settings of the copy are independent of those of the original cache
+	copied.SetMaxPipelines("ci", 5)
+	if n := c.limits.byProviderID["ci"]; n != 2 {
+		t.Fatalf("expected limit 2 but got %d", n)
+	}
+}
+
+func TestCache_evict(t *testing.T) {
+	now := time.Date(2020, 1, 30, 12, 0, 0, 0, time.UTC)
+	newCache := func() Cache {
+		c := NewCache(nil, nil, utils.PollingStrategy{})
+		c.SaveCommit("master", Commit{Sha: "sha"})
+		for i, state := range []State{Passed, Running, Failed, Passed} {
+			p := Pipeline{Step: Step{ID: strconv.Itoa(i), State: state}}
+			if _, err := c.SavePipeline("sha", p); err != nil {
+				t.Fatal(err)
+			}
+			c.usedAt.touch(now.Add(time.Duration(i)*time.Hour), p.Key())
+		}
+		return c
+	}
+
+	testCases := []struct {
+		name   string
+		policy EvictionPolicy
+		ids    []string
+	}{
+		{
+			name:   "no limit",
+			policy: EvictionPolicy{},
+			ids:    []string{"0", "1", "2", "3"},
+		},
+		{
+			name:   "least recently used pipelines are evicted first",
+			policy: EvictionPolicy{MaxPipelines: 2},
+			ids:    []string{"1", "3"},
+		},
+		{
+			name:   "active pipelines are never evicted",
+			policy: EvictionPolicy{MaxPipelines: 1},
+			ids:    []string{"1"},
+		},
+		{
+			name:   "pipelines unused for longer than MaxAge are evicted",
+			policy: EvictionPolicy{MaxAge: 90 * time.Minute},
+			ids:    []string{"1", "2", "3"},
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			c := newCache()
+			*c.eviction = testCase.policy
+			c.evict(now.Add(3 * time.Hour))
+
+			ids := make([]string, 0)
+			for _, p := range c.Pipelines("master") {
+				ids = append(ids, p.ID)
+			}
+			if diff := cmp.Diff(testCase.ids, ids); len(diff) > 0 {
+				t.Fatal(diff)
+			}
+		})
+	}
+}
+
 func createRepository(t *testing.T, remotes []config.RemoteConfig) (string, string) {
 	tmpDir, err := ioutil.TempDir("", "")
 	if err != nil {
@@ -336,13 +728,14 @@ func TestResolveCommit(t *testing.T) {
 		defer os.RemoveAll(repositoryPath)
 
 		expectedCommit := Commit{
-			Sha:      sha,
-			Author:   "Name <email>",
-			Date:     time.Date(2019, 19, 12, 21, 49, 0, 0, time.UTC),
-			Message:  "message",
-			Branches: []string{"master"},
-			Tags:     []string{"0.1.0"},
-			Head:     "master",
+			Sha:       sha,
+			Author:    "Name <email>",
+			Committer: "Name <email>",
+			Date:      time.Date(2019, 19, 12, 21, 49, 0, 0, time.UTC),
+			Message:   "message",
+			Branches:  []string{"master"},
+			Tags:      []string{"0.1.0"},
+			Head:      "master",
 		}
 
 		references := []string{
@@ -369,12 +762,18 @@ func TestResolveCommit(t *testing.T) {
 }
 
 type testProvider struct {
-	id         string
-	url        string
-	callNumber int
+	id  string
+	url string
+	// Number of calls to RefStatuses() and BuildFromURL(), accessed atomically since
+	// providers are called concurrently
+	callNumber int32
 }
 
-func (p testProvider) ID() string { return p.id }
+func (p *testProvider) ID() string { return p.id }
+
+func (p *testProvider) HostsRepository(url string) bool {
+	return strings.Contains(url, p.url)
+}
 
 func (p *testProvider) RefStatuses(ctx context.Context, url, ref, sha string) ([]string, error) {
 	if !strings.Contains(url, p.url) {
@@ -383,7 +782,7 @@ func (p *testProvider) RefStatuses(ctx context.Context, url, ref, sha string) ([
 	statusURL := func(status string) string {
 		return fmt.Sprintf("%s/%s/%s", url, ref, status)
 	}
-	switch p.callNumber++; p.callNumber {
+	switch atomic.AddInt32(&p.callNumber, 1) {
 	case 1:
 		return []string{statusURL("status0")}, nil
 	case 2:
@@ -393,32 +792,35 @@ func (p *testProvider) RefStatuses(ctx context.Context, url, ref, sha string) ([
 	}
 }
 
-func (p testProvider) Commit(ctx context.Context, repo, sha string) (Commit, error) {
+func (p *testProvider) Commit(ctx context.Context, repo, sha string) (Commit, error) {
 	if !strings.Contains(repo, p.url) {
 		return Commit{}, ErrUnknownRepositoryURL
 	}
 	return Commit{}, nil
 }
 
-func (p testProvider) Host() string {
+func (p *testProvider) Host() string {
 	return ""
 }
 
-func (p testProvider) Name() string {
+func (p *testProvider) Name() string {
 	return ""
 }
 
-func (p testProvider) Log(ctx context.Context, step Step) (string, error) {
+func (p *testProvider) Log(ctx context.Context, step Step) (string, error) {
 	return "", nil
 }
 
 func (p *testProvider) BuildFromURL(ctx context.Context, u string) (Pipeline, error) {
-	p.callNumber++
+	n := atomic.AddInt32(&p.callNumber, 1)
 	if !strings.Contains(u, p.url) {
 		return Pipeline{}, ErrUnknownPipelineURL
 	}
+	if strings.Contains(u, "error") {
+		return Pipeline{}, errors.New("internal server error")
+	}
 	if strings.Contains(u, "inactive") {
-		switch p.callNumber {
+		switch n {
 		case 1:
 			return Pipeline{Step: Step{State: Pending}}, nil
 		case 2:
@@ -446,7 +848,7 @@ func TestCache_monitorRefStatus(t *testing.T) {
 	}
 
 	go func() {
-		err := monitorRefStatuses(ctx, &p, s, "remoteName", "url", "ref", commitc)
+		err := monitorRefStatuses(ctx, &p, s, utils.SystemClock{}, 0, "remoteName", "url", "ref", commitc)
 		close(commitc)
 		errc <- err
 		close(errc)
@@ -522,6 +924,55 @@ func TestCache_broadcastMonitorRefStatus(t *testing.T) {
 	}
 }
 
+func TestCache_broadcastMonitorRefStatus_matchingHost(t *testing.T) {
+	rand.Seed(0)
+	gitlabCom := &testProvider{"gitlab-0", "gitlab.com", 0}
+	selfHosted := &testProvider{"gitlab-1", "gitlab.example.com", 0}
+	c := NewCache(nil, []SourceProvider{gitlabCom, selfHosted}, utils.PollingStrategy{
+		InitialInterval: time.Millisecond,
+		Multiplier:      1.5,
+		Randomizer:      0.25,
+		MaxInterval:     10 * time.Millisecond,
+	})
+
+	t.Run("only the instance hosting the repository must be queried", func(t *testing.T) {
+		commitc := make(chan Commit)
+		errc := make(chan error)
+		remotes := map[string][]string{
+			"origin": {"gitlab.example.com/owner/repo"},
+		}
+		go func() {
+			err := c.broadcastMonitorRefStatus(context.Background(), remotes, "sha", commitc)
+			close(commitc)
+			errc <- err
+			close(errc)
+		}()
+
+		for range commitc {
+		}
+		if err := <-errc; err != nil {
+			t.Fatal(err)
+		}
+
+		if n := atomic.LoadInt32(&gitlabCom.callNumber); n != 0 {
+			t.Fatalf("expected no request to gitlab.com but got %d", n)
+		}
+		if atomic.LoadInt32(&selfHosted.callNumber) == 0 {
+			t.Fatal("expected requests to the self-hosted instance")
+		}
+	})
+
+	t.Run("ErrUnknownRepositoryURL must be returned if no instance hosts the repository", func(t *testing.T) {
+		remotes := map[string][]string{
+			"origin": {"github.com/owner/repo"},
+		}
+		err := c.broadcastMonitorRefStatus(context.Background(), remotes, "sha", make(chan Commit))
+		if err != ErrUnknownRepositoryURL {
+			t.Fatalf("expected %v but got %v", ErrUnknownRepositoryURL, err)
+		}
+	})
+}
+
 func TestCache_monitorPipeline(t *testing.T) {
 	t.Run("monitorPipeline must save pipeline in cache and return once the pipeline becomes inactive", func(t *testing.T) {
 		rand.Seed(0)
@@ -535,7 +986,7 @@ func TestCache_monitorPipeline(t *testing.T) {
 			MaxInterval:     10 * time.Millisecond,
 		})
 
-		err := c.monitorPipeline(ctx, "sha", "ci", "ci.example.com/pipelines/inactive", nil)
+		err := c.monitorPipeline(ctx, "sha", "ci", "ci.example.com/pipelines/inactive")
 		if err != nil {
 			t.Fatal(err)
 		}
@@ -556,7 +1007,7 @@ func TestCache_monitorPipeline(t *testing.T) {
 			MaxInterval:     10 * time.Millisecond,
 		})
 
-		err := c.monitorPipeline(ctx, "sha", "ci", "bad.url.example.com", nil)
+		err := c.monitorPipeline(ctx, "sha", "ci", "bad.url.example.com")
 		if err != ErrUnknownPipelineURL {
 			t.Fatalf("expected %v but got %v", ErrUnknownPipelineURL, err)
 		}
@@ -580,7 +1031,7 @@ func TestCache_monitorPipeline(t *testing.T) {
 		}()
 
 		started := time.Now()
-		err := c.monitorPipeline(ctx, "sha", "ci", "ci.example.com/pipelines/active", nil)
+		err := c.monitorPipeline(ctx, "sha", "ci", "ci.example.com/pipelines/active")
 		if err != context.Canceled {
 			t.Fatalf("expected %v but got %v", context.Canceled, err)
 		}
@@ -591,6 +1042,157 @@ func TestCache_monitorPipeline(t *testing.T) {
 	})
 }
 
+// incrementalTestProvider counts calls to BuildFromURL and BuildFromURLSince
+type incrementalTestProvider struct {
+	testProvider
+	full        int
+	incremental int
+	since       SourceVersion
+}
+
+func (p *incrementalTestProvider) BuildFromURL(ctx context.Context, u string) (Pipeline, error) {
+	p.full++
+	return Pipeline{Step: Step{ID: "1", State: Passed}}, nil
+}
+
+func (p *incrementalTestProvider) BuildFromURLSince(ctx context.Context, u string, since SourceVersion) (Pipeline, SourceVersion, error) {
+	version := SourceVersion{ETag: `"1"`}
+	if since.IsZero() {
+		pipeline, err := p.BuildFromURL(ctx, u)
+		return pipeline, version, err
+	}
+	p.incremental++
+	p.since = since
+	return Pipeline{}, version, ErrNotModified
+}
+
+// Provider returning a running pipeline, then failing 'failures' times with a transient error
+// before returning the pipeline as passed
+type overloadedProvider struct {
+	testProvider
+	failures int32
+	// Called before each call to BuildFromURL returns
+	onCall func(n int32)
+}
+
+func (p *overloadedProvider) BuildFromURL(ctx context.Context, u string) (Pipeline, error) {
+	n := atomic.AddInt32(&p.callNumber, 1)
+	if p.onCall != nil {
+		p.onCall(n)
+	}
+	switch {
+	case n == 1:
+		return Pipeline{Step: Step{ID: "1", State: Running}}, nil
+	case n <= 1+p.failures:
+		return Pipeline{}, HTTPError{Status: 503, RetryAfter: "120"}
+	default:
+		return Pipeline{Step: Step{ID: "1", State: Passed}}, nil
+	}
+}
+
+func TestCache_monitorPipelineRetries(t *testing.T) {
+	strategy := utils.PollingStrategy{
+		InitialInterval: time.Millisecond,
+		Multiplier:      1.5,
+		MaxInterval:     10 * time.Millisecond,
+	}
+	key := PipelineKey{ID: "1"}
+
+	t.Run("the pipeline is marked as stale until the provider answers again", func(t *testing.T) {
+		p := &overloadedProvider{testProvider: testProvider{"ci", "ci.example.com", 0}, failures: 2}
+		c := NewCache([]CIProvider{p}, nil, strategy)
+		clock := utils.NewVirtualClock(time.Date(2020, 1, 30, 12, 0, 0, 0, time.UTC))
+		c.SetClock(clock)
+		// Time of the next attempt announced by the cache when the provider is called again
+		retryAt := make([]utils.NullTime, 0)
+		calledAt := make([]time.Time, 0)
+		p.onCall = func(n int32) {
+			if n > 1 {
+				pipeline, _ := c.Pipeline(key)
+				retryAt = append(retryAt, pipeline.RetryAt)
+				calledAt = append(calledAt, clock.Now())
+			}
+		}
+
+		if err := c.monitorPipeline(context.Background(), "sha", "ci", "ci.example.com/pipelines/1"); err != nil {
+			t.Fatal(err)
+		}
+		expected := []utils.NullTime{
+			{},
+			{Valid: true, Time: calledAt[0].Add(2 * time.Minute)},
+			{Valid: true, Time: calledAt[1].Add(2 * time.Minute)},
+		}
+		if diff := cmp.Diff(expected, retryAt); len(diff) > 0 {
+			t.Fatal(diff)
+		}
+		for i := 1; i < len(calledAt); i++ {
+			if !calledAt[i].Equal(retryAt[i].Time) {
+				t.Fatalf("expected call at %v but got %v", retryAt[i].Time, calledAt[i])
+			}
+		}
+		pipeline, exists := c.Pipeline(key)
+		if !exists || pipeline.State != Passed || pipeline.RetryAt.Valid {
+			t.Fatalf("expected up to date pipeline in cache but got %+v", pipeline)
+		}
+	})
+
+	t.Run("the error is returned once the provider failed too many times", func(t *testing.T) {
+		p := &overloadedProvider{testProvider: testProvider{"ci", "ci.example.com", 0}, failures: maxRetries + 1}
+		c := NewCache([]CIProvider{p}, nil, strategy)
+		c.SetClock(utils.NewVirtualClock(time.Time{}))
+
+		err := c.monitorPipeline(context.Background(), "sha", "ci", "ci.example.com/pipelines/1")
+		if e, ok := err.(HTTPError); !ok || e.Status != 503 {
+			t.Fatalf("expected HTTP error but got %v", err)
+		}
+	})
+}
+
+func TestCache_monitorPipelineIncremental(t *testing.T) {
+	provider := &incrementalTestProvider{testProvider: testProvider{id: "ci", url: "ci.example.com"}}
+	c := NewCache([]CIProvider{provider}, nil, utils.PollingStrategy{
+		InitialInterval: time.Millisecond,
+		MaxInterval:     10 * time.Millisecond,
+	})
+	u := "ci.example.com/pipelines/1"
+
+	// The first poll fetches the whole pipeline
+	started := time.Now()
+	if err := c.monitorPipeline(context.Background(), "sha1", "ci", u); err != nil {
+		t.Fatal(err)
+	}
+	if provider.full != 1 || provider.incremental != 0 {
+		t.Fatalf("expected 1 full fetch but got %d full and %d incremental", provider.full, provider.incremental)
+	}
+
+	// Following polls only ask for changes since the previous one
+	if err := c.monitorPipeline(context.Background(), "sha2", "ci", u); err != nil {
+		t.Fatal(err)
+	}
+	if provider.full != 1 || provider.incremental != 1 {
+		t.Fatalf("expected 1 incremental fetch but got %d full and %d incremental", provider.full, provider.incremental)
+	}
+	if provider.since.PolledAt.Before(started) || provider.since.ETag != `"1"` {
+		t.Fatalf("expected watermark after %v with the ETag of the first poll but got %+v", started, provider.since)
+	}
+	c.SaveCommit("ref", Commit{Sha: "sha2"})
+	if pipelines := c.Pipelines("ref"); len(pipelines) != 1 {
+		t.Fatalf("expected the cached pipeline to be associated to the new commit but got %+v", pipelines)
+	}
+
+	// Evicted pipelines are fetched again entirely
+	*c.eviction = EvictionPolicy{MaxPipelines: 1, MaxAge: time.Nanosecond}
+	c.mutex.Lock()
+	c.evict(time.Now().Add(time.Hour))
+	c.mutex.Unlock()
+	if err := c.monitorPipeline(context.Background(), "sha1", "ci", u); err != nil {
+		t.Fatal(err)
+	}
+	if provider.full != 2 {
+		t.Fatalf("expected 2 full fetches but got %d", provider.full)
+	}
+}
+
 func TestCache_broadcastMonitorPipeline(t *testing.T) {
 	t.Run("broadcastMonitorPipeline must save the pipeline in cache and return once the pipeline becomes inactive", func(t *testing.T) {
 		rand.Seed(0)
@@ -606,7 +1208,7 @@ func TestCache_broadcastMonitorPipeline(t *testing.T) {
 			MaxInterval:     10 * time.Millisecond,
 		})
 
-		err := c.broadcastMonitorPipeline(ctx, "sha", "ci1.example.com/pipelines/inactive", nil)
+		err := c.broadcastMonitorPipeline(ctx, "sha", "ci1.example.com/pipelines/inactive")
 		if err != nil {
 			t.Fatal(err)
 		}
@@ -629,7 +1231,7 @@ func TestCache_broadcastMonitorPipeline(t *testing.T) {
 			MaxInterval:     10 * time.Millisecond,
 		})
 
-		err := c.broadcastMonitorPipeline(ctx, "sha", "bad.url.example.com", nil)
+		err := c.broadcastMonitorPipeline(ctx, "sha", "bad.url.example.com")
 		if err != ErrUnknownPipelineURL {
 			t.Fatalf("expected %v but got %v", ErrUnknownPipelineURL, err)
 		}
@@ -655,7 +1257,7 @@ func TestCache_broadcastMonitorPipeline(t *testing.T) {
 		}()
 
 		started := time.Now()
-		err := c.broadcastMonitorPipeline(ctx, "sha", "ci1.example.com/pipelines/active", nil)
+		err := c.broadcastMonitorPipeline(ctx, "sha", "ci1.example.com/pipelines/active")
 		if err != context.Canceled {
 			t.Fatalf("expected %v but got %v", context.Canceled, err)
 		}
@@ -690,7 +1292,7 @@ func TestCache_MonitorPipelines(t *testing.T) {
 			Name:   "inactive",
 			Commit: Commit{},
 		}
-		err := c.MonitorPipelines(ctx, remotes, ref, nil)
+		err := c.MonitorPipelines(ctx, remotes, ref, false)
 		if err != nil {
 			t.Fatal(err)
 		}
@@ -729,7 +1331,7 @@ func TestCache_MonitorPipelines(t *testing.T) {
 		}()
 
 		started := time.Now()
-		err := c.MonitorPipelines(ctx, remotes, ref, nil)
+		err := c.MonitorPipelines(ctx, remotes, ref, false)
 		if err != context.Canceled {
 			t.Fatalf("expected %v but got %v", context.Canceled, err)
 		}
@@ -738,3 +1340,486 @@ func TestCache_MonitorPipelines(t *testing.T) {
 		}
 	})
 }
+
+func TestCache_MonitorPipelinesWarnings(t *testing.T) {
+	rand.Seed(0)
+	ctx := context.Background()
+	ciProviders := []CIProvider{
+		&testProvider{"provider", "provider.example.com", 0},
+	}
+	sourceProviders := []SourceProvider{
+		&testProvider{"provider", "provider.example.com", 0},
+	}
+	c := NewCache(ciProviders, sourceProviders, utils.PollingStrategy{
+		InitialInterval: time.Millisecond,
+		Multiplier:      1.5,
+		Randomizer:      0.25,
+		MaxInterval:     2 * time.Millisecond,
+	})
+
+	remotes := map[string][]string{
+		"provider": {"provider.example.com"},
+	}
+	ref := Ref{
+		Name:   "error",
+		Commit: Commit{},
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	events := c.Subscribe(ctx)
+	errc := make(chan error)
+	go func() {
+		errc <- c.MonitorPipelines(ctx, remotes, ref, true)
+	}()
+
+	n := 0
+	for done := false; !done || n == 0; {
+		select {
+		case e := <-events:
+			if e.Type != ProviderFailed {
+				break
+			}
+			if w := e.Err; w.ProviderID != "provider" || !strings.HasPrefix(w.URL, "provider.example.com/error/") {
+				t.Fatalf("unexpected warning: %v", w)
+			}
+			n++
+		case err := <-errc:
+			// Errors of CI providers must not stop the monitoring
+			if err != nil {
+				t.Fatal(err)
+			}
+			done = true
+		case <-time.After(5 * time.Second):
+			t.Fatal("expected at least one warning")
+		}
+	}
+}
+
+// Return a function failing the test if goroutines started since the call to checkLeaks
+// are still running
+func checkLeaks(t *testing.T) func() {
+	before := runtime.NumGoroutine()
+	return func() {
+		// Goroutines may need a moment to return after their context is canceled
+		for deadline := time.Now().Add(time.Second); runtime.NumGoroutine() > before; {
+			if time.Now().After(deadline) {
+				buf := make([]byte, 1<<20)
+				buf = buf[:runtime.Stack(buf, true)]
+				t.Fatalf("%d goroutine(s) leaked:\n%s", runtime.NumGoroutine()-before, buf)
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+}
+
+// Provider whose pipeline requests only return once their context is canceled
+type blockingProvider struct {
+	testProvider
+}
+
+func (p *blockingProvider) BuildFromURL(ctx context.Context, u string) (Pipeline, error) {
+	<-ctx.Done()
+	return Pipeline{}, ctx.Err()
+}
+
+func TestCache_MonitorPipelinesDeadline(t *testing.T) {
+	remotes := map[string][]string{
+		"origin": {"ci.example.com"},
+	}
+	newCache := func() Cache {
+		p := &blockingProvider{testProvider{"ci", "ci.example.com", 0}}
+		return NewCache([]CIProvider{p}, []SourceProvider{p}, utils.PollingStrategy{
+			InitialInterval: time.Millisecond,
+			Multiplier:      1.5,
+			MaxInterval:     10 * time.Millisecond,
+			Forever:         true,
+		})
+	}
+
+	t.Run("requests taking longer than the timeout are canceled", func(t *testing.T) {
+		defer checkLeaks(t)()
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		c := newCache()
+		c.SetRequestTimeout(10 * time.Millisecond)
+		events := c.Subscribe(ctx)
+
+		err := c.MonitorPipelines(ctx, remotes, Ref{Name: "master"}, false)
+		if e, ok := err.(ProviderError); !ok || e.Err != context.DeadlineExceeded {
+			t.Fatalf("expected deadline to be exceeded but got %v", err)
+		}
+		cancel()
+		for range events {
+		}
+	})
+
+	t.Run("no goroutine is left running once the monitoring is canceled", func(t *testing.T) {
+		defer checkLeaks(t)()
+		ctx, cancel := context.WithCancel(context.Background())
+
+		c := newCache()
+		c.SetRequestTimeout(0)
+		events := c.Subscribe(ctx)
+		errc := make(chan error)
+		go func() {
+			errc <- c.MonitorPipelines(ctx, remotes, Ref{Name: "master"}, true)
+		}()
+
+		// Wait for pipeline requests to be sent
+		for e := range events {
+			if e.Type == CommitSaved {
+				break
+			}
+		}
+		time.Sleep(20 * time.Millisecond)
+		cancel()
+
+		select {
+		case err := <-errc:
+			if err != nil && err != context.Canceled {
+				t.Fatal(err)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("MonitorPipelines did not return after its context was canceled")
+		}
+		for range events {
+		}
+	})
+}
+
+func TestProviderError(t *testing.T) {
+	err := ProviderError{
+		ProviderID: "gitlab-0",
+		Operation:  "fetch log of step 'test' of pipeline #12",
+		URL:        "https://gitlab.com/owner/repo/-/jobs/42",
+		Err:        fmt.Errorf("GET https://gitlab.com/api/v4/jobs/42/trace: %w", context.DeadlineExceeded),
+	}
+
+	expected := "provider gitlab-0: fetch log of step 'test' of pipeline #12: GET https://gitlab.com/api/v4/jobs/42/trace: context deadline exceeded (https://gitlab.com/owner/repo/-/jobs/42)"
+	if err.Error() != expected {
+		t.Fatalf("expected %q but got %q", expected, err.Error())
+	}
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatal("the error of the provider must be unwrapped")
+	}
+
+	var e ProviderError
+	if !errors.As(fmt.Errorf("monitoring failed: %w", err), &e) || e.ProviderID != "gitlab-0" {
+		t.Fatal("the provider error must be found in the chain of errors")
+	}
+}
+
+func TestLogFilename(t *testing.T) {
+	key := PipelineKey{ProviderHost: "host", ID: "42"}
+
+	t.Run("names are safe to use in a path", func(t *testing.T) {
+		if name, expected := logFilename(key, []string{"build/linux x64"}, true), "cistern-host-42-build_2Flinux_20x64.log"; name != expected {
+			t.Fatalf("expected %q but got %q", expected, name)
+		}
+	})
+
+	t.Run("different steps never share a name", func(t *testing.T) {
+		steps := []struct {
+			key      PipelineKey
+			stepIDs  []string
+			complete bool
+		}{
+			{key: key, stepIDs: []string{"a-b"}, complete: true},
+			{key: key, stepIDs: []string{"a", "b"}, complete: true},
+			{key: key, stepIDs: []string{"a_b"}, complete: true},
+			{key: key, stepIDs: []string{"a/b"}, complete: true},
+			{key: key, stepIDs: []string{"a_2Fb"}, complete: true},
+			{key: key, stepIDs: []string{"a"}, complete: false},
+			{key: key, stepIDs: []string{"a_partial"}, complete: true},
+			{key: key, stepIDs: []string{"a.partial"}, complete: true},
+			{key: PipelineKey{ProviderHost: "host-42", ID: "a"}, complete: true},
+			{key: PipelineKey{ProviderHost: "host", ID: "42-a"}, complete: true},
+		}
+		steps = append(steps, steps[0])
+		steps[len(steps)-1].complete = false
+
+		names := make(map[string]int)
+		for i, step := range steps {
+			name := logFilename(step.key, step.stepIDs, step.complete)
+			if !logFilenameRegexp.MatchString(name) {
+				t.Errorf("%q does not match the pattern of log filenames", name)
+			}
+			if j, exists := names[name]; exists {
+				t.Errorf("steps %+v and %+v are both named %q", steps[j], step, name)
+			}
+			names[name] = i
+		}
+	})
+}
+
+func TestCache_WriteToDirectory(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	c := NewCache(nil, nil, utils.PollingStrategy{})
+	p := Pipeline{
+		ProviderHost: "host",
+		Step: Step{
+			ID: "42",
+			Children: []Step{
+				{
+					ID: "1/2",
+					Log: Log{
+						Content: utils.NullString{
+							Valid:  true,
+							String: "\x1b[31mred\x1b[0m\nabc\rdef\n",
+						},
+					},
+				},
+			},
+		},
+	}
+	if _, err := c.SavePipeline("sha", p); err != nil {
+		t.Fatal(err)
+	}
+
+	logDir := path.Join(dir, "logs")
+	logPath, err := c.WriteToDirectory(context.Background(), p.Key(), []string{"1/2"}, logDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := path.Join(logDir, "cistern-host-42-1_2F2.log"); logPath != expected {
+		t.Fatalf("expected %q but got %q", expected, logPath)
+	}
+
+	bs, err := ioutil.ReadFile(logPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff("red\ndef\n", string(bs)); len(diff) > 0 {
+		t.Fatal(diff)
+	}
+}
+
+func TestCache_WriteToDirectoryConcurrent(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	c := NewCache(nil, nil, utils.PollingStrategy{})
+	p := Pipeline{
+		ProviderHost: "host",
+		Step: Step{
+			ID:    "42",
+			State: Running,
+			Children: []Step{
+				{
+					ID:    "1",
+					State: Running,
+					Log:   Log{Content: utils.NullString{Valid: true, String: "partial\n"}},
+				},
+			},
+		},
+	}
+	if _, err := c.SavePipeline("sha", p); err != nil {
+		t.Fatal(err)
+	}
+
+	// The log of a running step is written again by every writer
+	errc := make(chan error)
+	n := 20
+	for i := 0; i < n; i++ {
+		go func() {
+			_, err := c.WriteToDirectory(context.Background(), p.Key(), []string{"1"}, dir)
+			errc <- err
+		}()
+	}
+	for i := 0; i < n; i++ {
+		if err := <-errc; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || entries[0].Name() != "cistern-host-42-1_partial.log" {
+		t.Fatalf("expected a single log file but got %v", entries)
+	}
+}
+
+func TestCache_WriteToDirectoryReuse(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	log := func(s string) Log {
+		return Log{Content: utils.NullString{Valid: true, String: s}}
+	}
+	c := NewCache(nil, nil, utils.PollingStrategy{})
+	p := Pipeline{
+		ProviderHost: "host",
+		Step: Step{
+			ID:    "42",
+			State: Running,
+			Children: []Step{
+				{
+					ID:         "1",
+					State:      Passed,
+					FinishedAt: utils.NullTime{Valid: true, Time: time.Now().Add(-time.Hour)},
+					Log:        log("complete\n"),
+				},
+				{
+					ID:    "2",
+					State: Running,
+					Log:   log("partial\n"),
+				},
+			},
+		},
+	}
+	if _, err := c.SavePipeline("sha", p); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("log of finished step is reused", func(t *testing.T) {
+		logPath, err := c.WriteToDirectory(context.Background(), p.Key(), []string{"1"}, dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(logPath, []byte("from disk\n"), 0600); err != nil {
+			t.Fatal(err)
+		}
+		if logPath, err = c.WriteToDirectory(context.Background(), p.Key(), []string{"1"}, dir); err != nil {
+			t.Fatal(err)
+		}
+		bs, err := ioutil.ReadFile(logPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if diff := cmp.Diff("from disk\n", string(bs)); len(diff) > 0 {
+			t.Fatal(diff)
+		}
+	})
+
+	t.Run("log of running step is written to a partial log file", func(t *testing.T) {
+		logPath, err := c.WriteToDirectory(context.Background(), p.Key(), []string{"2"}, dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if expected := path.Join(dir, "cistern-host-42-2_partial.log"); logPath != expected {
+			t.Fatalf("expected %q but got %q", expected, logPath)
+		}
+	})
+}
+
+func TestRemoveOldLogs(t *testing.T) {
+	t.Run("missing directory must not cause an error", func(t *testing.T) {
+		if err := RemoveOldLogs("invalid path", time.Hour); err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	t.Run("only log files of cistern older than maxAge must be removed", func(t *testing.T) {
+		dir, err := ioutil.TempDir("", "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer os.RemoveAll(dir)
+
+		old := time.Now().Add(-48 * time.Hour)
+		files := map[string]time.Time{
+			"cistern-gitlab.com-42-1.log":          old,
+			"cistern-gitlab.com-42-2_partial.log":  old,
+			"cistern-gitlab.com-42-3.log.tmp":      time.Now().Add(-time.Hour),
+			"cistern-gitlab.com-42-4.log.tmp":      time.Now(),
+			"cistern-gitlab.com-42-5.log.1234.tmp": time.Now().Add(-time.Hour),
+			"cistern-gitlab.com-42-6.log.5678.tmp": time.Now(),
+			"cistern-gitlab.com-43-1.log":          time.Now(),
+			"build-output.log":                     old,
+			"old.log":                              old,
+			"old.txt":                              old,
+			"notes from yesterday.log":             old,
+		}
+		for name, modTime := range files {
+			p := path.Join(dir, name)
+			if err := ioutil.WriteFile(p, nil, 0600); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.Chtimes(p, modTime, modTime); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		if err := RemoveOldLogs(dir, 24*time.Hour); err != nil {
+			t.Fatal(err)
+		}
+
+		entries, err := ioutil.ReadDir(dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		names := make([]string, 0)
+		for _, entry := range entries {
+			names = append(names, entry.Name())
+		}
+		expected := []string{
+			"build-output.log",
+			"cistern-gitlab.com-42-4.log.tmp",
+			"cistern-gitlab.com-42-6.log.5678.tmp",
+			"cistern-gitlab.com-43-1.log",
+			"notes from yesterday.log",
+			"old.log",
+			"old.txt",
+		}
+		if diff := cmp.Diff(expected, names); len(diff) > 0 {
+			t.Fatal(diff)
+		}
+	})
+
+	t.Run("only old copies of latest logs must be removed from the latest directory", func(t *testing.T) {
+		dir, err := ioutil.TempDir("", "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer os.RemoveAll(dir)
+
+		old := time.Now().Add(-48 * time.Hour)
+		files := map[string]time.Time{
+			"latest/repository/master.log":        old,
+			"latest/repository/master.failed.log": old,
+			"latest/repository/feature.log":       time.Now(),
+			"latest/repository/notes.txt":         old,
+			"latest/repository/nested/old.log":    old,
+			"latest/old.log":                      old,
+		}
+		for name, modTime := range files {
+			p := path.Join(dir, name)
+			if err := os.MkdirAll(path.Dir(p), 0700); err != nil {
+				t.Fatal(err)
+			}
+			if err := ioutil.WriteFile(p, nil, 0600); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.Chtimes(p, modTime, modTime); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		if err := RemoveOldLogs(dir, 24*time.Hour); err != nil {
+			t.Fatal(err)
+		}
+
+		for name := range files {
+			_, err := os.Stat(path.Join(dir, name))
+			removed := os.IsNotExist(err)
+			if expected := name == "latest/repository/master.log" || name == "latest/repository/master.failed.log"; removed != expected {
+				t.Errorf("%s: expected removal to be %v but got %v", name, expected, removed)
+			}
+		}
+	})
+}
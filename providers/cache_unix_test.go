@@ -3,8 +3,14 @@
 package providers
 
 import (
+	"context"
+	"io/ioutil"
 	"os"
+	"path"
 	"testing"
+	"time"
+
+	"github.com/nbedos/cistern/utils"
 )
 
 func TestConfiguration_TokenFromProcess(t *testing.T) {
@@ -23,3 +29,136 @@ func TestConfiguration_TokenFromProcess(t *testing.T) {
 		}
 	})
 }
+
+func TestLinkLatestLog(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	for _, name := range []string{"first.log", "second.log"} {
+		logPath := path.Join(dir, name)
+		if err := ioutil.WriteFile(logPath, []byte(name), 0600); err != nil {
+			t.Fatal(err)
+		}
+
+		linkPath, err := LinkLatestLog(dir, "github.com/nbedos/cistern", "feature/logs", logPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if expected := path.Join(dir, "latest", "github.com_nbedos_cistern", "feature_logs.log"); linkPath != expected {
+			t.Fatalf("expected %q but got %q", expected, linkPath)
+		}
+
+		bs, err := ioutil.ReadFile(linkPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(bs) != name {
+			t.Fatalf("expected %q but got %q", name, string(bs))
+		}
+	}
+
+	t.Run("dangling links must be removed by RemoveOldLogs", func(t *testing.T) {
+		if err := os.Remove(path.Join(dir, "second.log")); err != nil {
+			t.Fatal(err)
+		}
+		if err := RemoveOldLogs(dir, time.Hour); err != nil {
+			t.Fatal(err)
+		}
+		linkPath := path.Join(dir, "latest", "github.com_nbedos_cistern", "feature_logs.log")
+		if _, err := os.Lstat(linkPath); !os.IsNotExist(err) {
+			t.Fatalf("expected link %q to be removed", linkPath)
+		}
+	})
+}
+
+func TestLinkLatestFailedLog(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	logPath := path.Join(dir, "failed.log")
+	if err := ioutil.WriteFile(logPath, []byte("FAIL\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	linkPath, err := LinkLatestFailedLog(dir, "github.com/nbedos/cistern", "master", logPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := path.Join(dir, "latest", "github.com_nbedos_cistern", "master.failed.log"); linkPath != expected {
+		t.Fatalf("expected %q but got %q", expected, linkPath)
+	}
+	bs, err := ioutil.ReadFile(linkPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(bs) != "FAIL\n" {
+		t.Fatalf("expected %q but got %q", "FAIL\n", string(bs))
+	}
+}
+
+func TestCache_WriteToDirectoryRelink(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	c := NewCache(nil, nil, utils.PollingStrategy{})
+	p := Pipeline{
+		ProviderHost: "host",
+		Ref:          "master",
+		Step: Step{
+			ID:    "42",
+			State: Running,
+			Children: []Step{
+				{
+					ID:    "1",
+					State: Running,
+					Log:   Log{Content: utils.NullString{Valid: true, String: "partial\n"}},
+				},
+			},
+		},
+	}
+	if _, err := c.SavePipeline("sha", p); err != nil {
+		t.Fatal(err)
+	}
+
+	partialPath, err := c.WriteToDirectory(context.Background(), p.Key(), []string{"1"}, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	linkPath, err := LinkLatestLog(dir, "repository", p.Ref, partialPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p.State = Failed
+	p.Children = []Step{
+		{
+			ID:    "1",
+			State: Failed,
+			Log:   Log{Content: utils.NullString{Valid: true, String: "partial\ncomplete\n"}},
+		},
+	}
+	if _, err := c.SavePipeline("sha", p); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.WriteToDirectory(context.Background(), p.Key(), []string{"1"}, dir); err != nil {
+		t.Fatal(err)
+	}
+
+	// The link must follow the log from the partial file to the complete one
+	bs, err := ioutil.ReadFile(linkPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(bs) != "partial\ncomplete\n" {
+		t.Fatalf("expected %q but got %q", "partial\ncomplete\n", string(bs))
+	}
+}
@@ -5,8 +5,10 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"io"
 	"net/http"
 	"net/url"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
@@ -16,10 +18,15 @@ import (
 
 type CircleCIClient struct {
 	baseURL     url.URL
+	apiV2URL    url.URL
+	appURL      url.URL
+	graphQLURL  url.URL
 	httpClient  *http.Client
 	rateLimiter <-chan time.Time
 	token       string
 	provider    Provider
+	// Requests sent through httpClient
+	requests *requestCounter
 }
 
 var CircleCIURL = url.URL{
@@ -29,15 +36,28 @@ var CircleCIURL = url.URL{
 	RawPath: "api/v1.1",
 }
 
+var CircleCIV2URL = url.URL{Scheme: "https", Host: "circleci.com", Path: "/api/v2"}
+
+var CircleCIAppURL = url.URL{Scheme: "https", Host: "app.circleci.com"}
+
+// Endpoint used by the CircleCI CLI to validate configuration files
+var CircleCIGraphQLURL = url.URL{Scheme: "https", Host: "circleci.com", Path: "/graphql-unstable"}
+
 func NewCircleCIClient(id string, name string, token string, requestsPerSecond float64) CircleCIClient {
 	rateLimit := time.Second / 10
 	if requestsPerSecond > 0 {
 		rateLimit = time.Second / time.Duration(requestsPerSecond)
 	}
 
+	httpClient, requests := countedClient(nil)
+
 	return CircleCIClient{
 		baseURL:     CircleCIURL,
-		httpClient:  &http.Client{Timeout: 10 * time.Second},
+		apiV2URL:    CircleCIV2URL,
+		appURL:      CircleCIAppURL,
+		graphQLURL:  CircleCIGraphQLURL,
+		httpClient:  httpClient,
+		requests:    requests,
 		rateLimiter: time.Tick(rateLimit),
 		token:       token,
 		provider: Provider{
@@ -47,6 +67,10 @@ func NewCircleCIClient(id string, name string, token string, requestsPerSecond f
 	}
 }
 
+func (c CircleCIClient) RequestStats() RequestStats {
+	return c.requests.snapshot()
+}
+
 func (c CircleCIClient) get(ctx context.Context, resourceURL url.URL) (*bytes.Buffer, error) {
 	if c.token != "" {
 		parameters := resourceURL.Query()
@@ -59,7 +83,7 @@ func (c CircleCIClient) get(ctx context.Context, resourceURL url.URL) (*bytes.Bu
 		return nil, err
 	}
 	req.Header.Add("Accept", "application/json")
-	req.WithContext(ctx)
+	req = req.WithContext(ctx)
 
 	select {
 	case <-c.rateLimiter:
@@ -94,10 +118,11 @@ func (c CircleCIClient) get(ctx context.Context, resourceURL url.URL) (*bytes.Bu
 		noTokenURL.RawQuery = parameters.Encode()
 
 		return nil, HTTPError{
-			Method:  req.Method,
-			URL:     noTokenURL.String(),
-			Status:  resp.StatusCode,
-			Message: message,
+			Method:     req.Method,
+			URL:        noTokenURL.String(),
+			Status:     resp.StatusCode,
+			Message:    message,
+			RetryAfter: resp.Header.Get("Retry-After"),
 		}
 	}
 
@@ -132,7 +157,17 @@ func (c CircleCIClient) BuildFromURL(ctx context.Context, u string) (Pipeline, e
 	}
 
 	endPoint := c.projectEndpoint(owner, repo)
-	return c.fetchPipeline(ctx, endPoint, id)
+	build, err := c.fetchBuild(ctx, endPoint, id)
+	if err != nil {
+		return Pipeline{}, err
+	}
+
+	// Builds of CircleCI 1.0 are not part of any workflow
+	if build.Workflows.ID == "" {
+		return build.toPipeline()
+	}
+
+	return c.fetchWorkflowPipeline(ctx, endPoint, build.Workflows.ID)
 }
 
 // Extract owner, repository and build ID from web url of build
@@ -185,10 +220,11 @@ func (c CircleCIClient) Log(ctx context.Context, step Step) (string, error) {
 
 	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
 		return "", HTTPError{
-			Method:  "GET",
-			URL:     step.Log.Key,
-			Status:  resp.StatusCode,
-			Message: "", // FIXME
+			Method:     "GET",
+			URL:        step.Log.Key,
+			Status:     resp.StatusCode,
+			Message:    "", // FIXME
+			RetryAfter: resp.Header.Get("Retry-After"),
 		}
 	}
 
@@ -207,29 +243,376 @@ func (c CircleCIClient) Log(ctx context.Context, step Step) (string, error) {
 	return builder.String(), err
 }
 
-func (c CircleCIClient) fetchPipeline(ctx context.Context, projectEndpoint url.URL, buildID int) (Pipeline, error) {
-	var err error
-	var pipeline Pipeline
+func (c CircleCIClient) Artifacts(ctx context.Context, step Step) ([]Artifact, error) {
+	if step.Type != StepJob || !step.WebURL.Valid {
+		return nil, ErrNoArtifacts
+	}
+	// Jobs that did not run have the URL of their workflow and no artifact
+	owner, repo, buildID, err := parseCircleCIWebURL(&c.baseURL, step.WebURL.String)
+	if err != nil {
+		return nil, ErrNoArtifacts
+	}
+
+	endpoint := c.projectEndpoint(owner, repo)
+	endpoint.Path += fmt.Sprintf("/%d/artifacts", buildID)
+	body, err := c.get(ctx, endpoint)
+	if err != nil {
+		return nil, err
+	}
+
+	var items []struct {
+		Path string `json:"path"`
+		URL  string `json:"url"`
+	}
+	if err := json.Unmarshal(body.Bytes(), &items); err != nil {
+		return nil, err
+	}
+
+	artifacts := make([]Artifact, 0, len(items))
+	for _, item := range items {
+		artifacts = append(artifacts, Artifact{
+			Path: item.Path,
+			URL:  item.URL,
+		})
+	}
+
+	return artifacts, nil
+}
+
+func (c CircleCIClient) DownloadArtifact(ctx context.Context, step Step, a Artifact, w io.Writer) error {
+	req, err := http.NewRequest("GET", a.URL, nil)
+	if err != nil {
+		return err
+	}
+	if c.token != "" {
+		req.Header.Add("Circle-Token", c.token)
+	}
+	req = req.WithContext(ctx)
+
+	select {
+	case <-c.rateLimiter:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return HTTPError{
+			Method:     req.Method,
+			URL:        a.URL,
+			Status:     resp.StatusCode,
+			RetryAfter: resp.Header.Get("Retry-After"),
+		}
+	}
+
+	_, err = io.Copy(w, resp.Body)
+	return err
+}
+
+func (c CircleCIClient) fetchBuild(ctx context.Context, projectEndpoint url.URL, buildID int) (circleCIBuild, error) {
+	var build circleCIBuild
 
 	projectEndpoint.Path += fmt.Sprintf("/%d", buildID)
 	body, err := c.get(ctx, projectEndpoint)
 	if err != nil {
-		return pipeline, err
+		return build, err
 	}
 
-	var circleCIBuild circleCIBuild
-	if err := json.Unmarshal(body.Bytes(), &circleCIBuild); err != nil {
-		return pipeline, err
+	err = json.Unmarshal(body.Bytes(), &build)
+	return build, err
+}
+
+func (c CircleCIClient) v2Endpoint(format string, a ...interface{}) url.URL {
+	endpoint := c.apiV2URL
+	endpoint.Path += fmt.Sprintf(format, a...)
+
+	return endpoint
+}
+
+// Request all the pages of a paginated resource of the v2 API and return the concatenation of
+// the items of each page
+func (c CircleCIClient) getV2Items(ctx context.Context, endpoint url.URL) ([]json.RawMessage, error) {
+	items := make([]json.RawMessage, 0)
+	pageToken := ""
+	for {
+		u := endpoint
+		if pageToken != "" {
+			parameters := u.Query()
+			parameters.Set("page-token", pageToken)
+			u.RawQuery = parameters.Encode()
+		}
+
+		body, err := c.get(ctx, u)
+		if err != nil {
+			return nil, err
+		}
+
+		var page struct {
+			Items         []json.RawMessage `json:"items"`
+			NextPageToken string            `json:"next_page_token"`
+		}
+		if err := json.Unmarshal(body.Bytes(), &page); err != nil {
+			return nil, err
+		}
+		items = append(items, page.Items...)
+
+		if pageToken = page.NextPageToken; pageToken == "" {
+			return items, nil
+		}
 	}
+}
 
-	pipeline, err = circleCIBuild.toPipeline()
+// Build a pipeline from the CircleCI pipeline the workflow identified by workflowID belongs to.
+// Each workflow of the pipeline is turned into a stage and each job of a workflow into a job.
+func (c CircleCIClient) fetchWorkflowPipeline(ctx context.Context, projectEndpoint url.URL, workflowID string) (Pipeline, error) {
+	var workflow circleCIWorkflow
+	body, err := c.get(ctx, c.v2Endpoint("/workflow/%s", url.PathEscape(workflowID)))
 	if err != nil {
-		return pipeline, err
+		return Pipeline{}, err
+	}
+	if err := json.Unmarshal(body.Bytes(), &workflow); err != nil {
+		return Pipeline{}, err
+	}
+
+	var circlePipeline circleCIPipeline
+	body, err = c.get(ctx, c.v2Endpoint("/pipeline/%s", url.PathEscape(workflow.PipelineID)))
+	if err != nil {
+		return Pipeline{}, err
+	}
+	if err := json.Unmarshal(body.Bytes(), &circlePipeline); err != nil {
+		return Pipeline{}, err
+	}
+
+	webURL := c.appURL
+	webURL.Path += fmt.Sprintf("/pipelines/%s/%d", circleCIAppSlug(circlePipeline.ProjectSlug), circlePipeline.Number)
+	pipeline, err := circlePipeline.toPipeline(webURL.String())
+	if err != nil {
+		return Pipeline{}, err
+	}
+
+	rawWorkflows, err := c.getV2Items(ctx, c.v2Endpoint("/pipeline/%s/workflow", url.PathEscape(circlePipeline.ID)))
+	if err != nil {
+		return Pipeline{}, err
+	}
+	workflows := make([]circleCIWorkflow, 0, len(rawWorkflows))
+	for _, raw := range rawWorkflows {
+		var workflow circleCIWorkflow
+		if err := json.Unmarshal(raw, &workflow); err != nil {
+			return Pipeline{}, err
+		}
+		workflows = append(workflows, workflow)
+	}
+	sort.SliceStable(workflows, func(i, j int) bool {
+		return workflows[i].CreatedAt < workflows[j].CreatedAt
+	})
+
+	// Workflows that were rerun are still shown but only the most recent run of a workflow
+	// determines the state of the pipeline
+	latestRuns := make([]Step, 0)
+	for i, workflow := range workflows {
+		workflowURL := webURL
+		workflowURL.Path += fmt.Sprintf("/workflows/%s", workflow.ID)
+		stage, err := workflow.toStep(workflowURL.String())
+		if err != nil {
+			return Pipeline{}, err
+		}
+
+		rawJobs, err := c.getV2Items(ctx, c.v2Endpoint("/workflow/%s/job", url.PathEscape(workflow.ID)))
+		if err != nil {
+			return Pipeline{}, err
+		}
+		for _, raw := range rawJobs {
+			var job circleCIWorkflowJob
+			if err := json.Unmarshal(raw, &job); err != nil {
+				return Pipeline{}, err
+			}
+
+			// Jobs that did not run (e.g. approval jobs) have no build number
+			var step Step
+			if job.Number > 0 {
+				build, err := c.fetchBuild(ctx, projectEndpoint, job.Number)
+				if err != nil {
+					return Pipeline{}, err
+				}
+				p, err := build.toPipeline()
+				if err != nil {
+					return Pipeline{}, err
+				}
+				step = p.Step
+				step.Name = job.Name
+			} else {
+				if step, err = job.toStep(stage.WebURL); err != nil {
+					return Pipeline{}, err
+				}
+			}
+			stage.Children = append(stage.Children, step)
+		}
+		aggregate := Aggregate(stage.Children)
+		stage.StartedAt = aggregate.StartedAt
+		stage.Duration = utils.NullSub(stage.FinishedAt, stage.StartedAt)
+		pipeline.Children = append(pipeline.Children, stage)
+
+		isLatestRun := true
+		for _, other := range workflows[i+1:] {
+			if other.Name == workflow.Name {
+				isLatestRun = false
+				break
+			}
+		}
+		if isLatestRun {
+			latestRuns = append(latestRuns, stage)
+		}
+	}
+
+	aggregate := Aggregate(latestRuns)
+	pipeline.State = aggregate.State
+	pipeline.StartedAt = aggregate.StartedAt
+	pipeline.FinishedAt = aggregate.FinishedAt
+	if pipeline.State.IsActive() {
+		pipeline.FinishedAt = utils.NullTime{}
 	}
+	pipeline.Duration = utils.NullSub(pipeline.FinishedAt, pipeline.StartedAt)
+	pipeline.UpdatedAt = utils.MaxNullTime(pipeline.UpdatedAt, pipeline.StartedAt, pipeline.FinishedAt)
 
 	return pipeline, nil
 }
 
+// Convert a project slug of the v2 API ("gh/owner/repo") to the format used by the
+// CircleCI web application ("github/owner/repo")
+func circleCIAppSlug(projectSlug string) string {
+	cs := strings.SplitN(projectSlug, "/", 2)
+	if len(cs) < 2 {
+		return projectSlug
+	}
+	switch cs[0] {
+	case "gh":
+		cs[0] = "github"
+	case "bb":
+		cs[0] = "bitbucket"
+	}
+
+	return strings.Join(cs, "/")
+}
+
+type circleCIPipeline struct {
+	ID          string `json:"id"`
+	Number      int    `json:"number"`
+	ProjectSlug string `json:"project_slug"`
+	CreatedAt   string `json:"created_at"`
+	UpdatedAt   string `json:"updated_at"`
+	VCS         struct {
+		Branch   string `json:"branch"`
+		Tag      string `json:"tag"`
+		Revision string `json:"revision"`
+	} `json:"vcs"`
+	Trigger struct {
+		Actor struct {
+			Login string `json:"login"`
+		} `json:"actor"`
+	} `json:"trigger"`
+}
+
+func (p circleCIPipeline) toPipeline(webURL string) (Pipeline, error) {
+	pipeline := Pipeline{
+		Number: strconv.Itoa(p.Number),
+		Ref:    p.VCS.Branch,
+		IsTag:  p.VCS.Tag != "",
+		Author: p.Trigger.Actor.Login,
+		Step: Step{
+			ID:   p.ID,
+			Type: StepPipeline,
+			WebURL: utils.NullString{
+				Valid:  true,
+				String: webURL,
+			},
+		},
+	}
+	if pipeline.IsTag {
+		pipeline.Ref = p.VCS.Tag
+	}
+
+	var err error
+	if pipeline.CreatedAt, err = utils.NullTimeFromString(p.CreatedAt); err != nil {
+		return Pipeline{}, err
+	}
+	if pipeline.UpdatedAt, err = utils.NullTimeFromString(p.UpdatedAt); err != nil {
+		return Pipeline{}, err
+	}
+
+	return pipeline, nil
+}
+
+type circleCIWorkflow struct {
+	ID         string `json:"id"`
+	Name       string `json:"name"`
+	Status     string `json:"status"`
+	PipelineID string `json:"pipeline_id"`
+	CreatedAt  string `json:"created_at"`
+	StoppedAt  string `json:"stopped_at"`
+}
+
+func (w circleCIWorkflow) toStep(webURL string) (Step, error) {
+	step := Step{
+		ID:    w.ID,
+		Name:  w.Name,
+		Type:  StepStage,
+		State: fromCircleCIStatus(w.Status),
+		WebURL: utils.NullString{
+			Valid:  true,
+			String: webURL,
+		},
+	}
+
+	var err error
+	if step.CreatedAt, err = utils.NullTimeFromString(w.CreatedAt); err != nil {
+		return Step{}, err
+	}
+	if step.FinishedAt, err = utils.NullTimeFromString(w.StoppedAt); err != nil {
+		return Step{}, err
+	}
+
+	return step, nil
+}
+
+type circleCIWorkflowJob struct {
+	ID        string `json:"id"`
+	Number    int    `json:"job_number"`
+	Name      string `json:"name"`
+	Type      string `json:"type"`
+	Status    string `json:"status"`
+	StartedAt string `json:"started_at"`
+	StoppedAt string `json:"stopped_at"`
+}
+
+func (j circleCIWorkflowJob) toStep(webURL utils.NullString) (Step, error) {
+	step := Step{
+		ID:     j.ID,
+		Name:   j.Name,
+		Type:   StepJob,
+		State:  fromCircleCIStatus(j.Status),
+		WebURL: webURL,
+	}
+	if j.Type == "approval" && step.State == Pending {
+		step.State = Manual
+	}
+
+	var err error
+	if step.StartedAt, err = utils.NullTimeFromString(j.StartedAt); err != nil {
+		return Step{}, err
+	}
+	if step.FinishedAt, err = utils.NullTimeFromString(j.StoppedAt); err != nil {
+		return Step{}, err
+	}
+	step.Duration = utils.NullSub(step.FinishedAt, step.StartedAt)
+
+	return step, nil
+}
+
 type circleCIAction struct {
 	Index                int    `json:"index"`
 	Name                 string `json:"name"`
@@ -360,12 +743,14 @@ func fromCircleCIStatus(status string) State {
 	switch status {
 	case "canceled", "cancelled":
 		return Canceled
-	case "infrastructure_fail", "timedout", "failed":
+	case "infrastructure_fail", "timedout", "failed", "error", "failing", "unauthorized":
 		return Failed
 	case "running":
 		return Running
-	case "queued", "scheduled":
+	case "queued", "scheduled", "blocked", "not_started":
 		return Pending
+	case "on_hold":
+		return Manual
 	case "not_running", "not_run":
 		return Skipped
 	case "success":
@@ -377,3 +762,95 @@ func fromCircleCIStatus(status string) State {
 		return Unknown
 	}
 }
+
+func (c CircleCIClient) ConfigurationFiles() []string {
+	return []string{".circleci/config.yml"}
+}
+
+const circleCIValidateQuery = `query ValidateConfig ($config: String!) {
+	buildConfig(configYaml: $config) {
+		valid,
+		errors { message }
+	}
+}`
+
+// Validate the content of a .circleci/config.yml file with the GraphQL API of CircleCI, as
+// done by "circleci config validate"
+func (c CircleCIClient) Lint(ctx context.Context, content string) ([]string, error) {
+	var request struct {
+		Query     string            `json:"query"`
+		Variables map[string]string `json:"variables"`
+	}
+	request.Query = circleCIValidateQuery
+	request.Variables = map[string]string{"config": content}
+	bs, err := json.Marshal(request)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequest("POST", c.graphQLURL.String(), bytes.NewReader(bs))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Add("Accept", "application/json")
+	req.Header.Add("Content-Type", "application/json")
+	if c.token != "" {
+		req.Header.Add("Authorization", c.token)
+	}
+	req = req.WithContext(ctx)
+
+	select {
+	case <-c.rateLimiter:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body := new(bytes.Buffer)
+	if _, err := body.ReadFrom(resp.Body); err != nil {
+		return nil, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, HTTPError{
+			Method:     req.Method,
+			URL:        req.URL.String(),
+			Status:     resp.StatusCode,
+			Message:    body.String(),
+			RetryAfter: resp.Header.Get("Retry-After"),
+		}
+	}
+
+	var response struct {
+		Data struct {
+			BuildConfig struct {
+				Valid  bool `json:"valid"`
+				Errors []struct {
+					Message string `json:"message"`
+				} `json:"errors"`
+			} `json:"buildConfig"`
+		} `json:"data"`
+		Errors []struct {
+			Message string `json:"message"`
+		} `json:"errors"`
+	}
+	if err := json.Unmarshal(body.Bytes(), &response); err != nil {
+		return nil, err
+	}
+	if len(response.Errors) > 0 {
+		return nil, fmt.Errorf("graphql: %s", response.Errors[0].Message)
+	}
+
+	errs := make([]string, 0)
+	for _, e := range response.Data.BuildConfig.Errors {
+		errs = append(errs, e.Message)
+	}
+	if !response.Data.BuildConfig.Valid && len(errs) == 0 {
+		errs = append(errs, "invalid configuration")
+	}
+
+	return errs, nil
+}
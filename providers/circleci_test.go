@@ -8,6 +8,7 @@ import (
 	"net/http/httptest"
 	"net/url"
 	"path"
+	"strings"
 	"testing"
 	"time"
 
@@ -23,6 +24,25 @@ func setupCircleCITestServer(t *testing.T) (*http.Client, *url.URL, func()) {
 			filename = "circle_build.json"
 		case "/cistern/log/36":
 			filename = "circle_log"
+		case "/project/gh/nbedos/cistern/36/artifacts":
+			fmt.Fprintf(w, `[{"path": "test-results/junit.xml", "url": "http://%s/artifacts/36/junit.xml"}]`, r.Host)
+			return
+		case "/artifacts/36/junit.xml":
+			fmt.Fprint(w, "<testsuites></testsuites>")
+			return
+		case "/api/v2/workflow/9b17c635-15bb-4b38-9b74-86f76aa66c0e":
+			filename = "circle_workflow.json"
+		case "/api/v2/pipeline/5034460f-c7c4-4c43-9457-de07e2029e7b":
+			filename = "circle_pipeline.json"
+		case "/api/v2/pipeline/5034460f-c7c4-4c43-9457-de07e2029e7b/workflow":
+			filename = "circle_pipeline_workflows_1.json"
+			if r.URL.Query().Get("page-token") == "page2" {
+				filename = "circle_pipeline_workflows_2.json"
+			}
+		case "/api/v2/workflow/9b17c635-15bb-4b38-9b74-86f76aa66c0e/job":
+			filename = "circle_workflow_jobs_build.json"
+		case "/api/v2/workflow/c3d5a1f0-7b52-4c7e-8e0a-2f6b0d3c9a41/job":
+			filename = "circle_workflow_jobs_deploy.json"
 		default:
 			w.WriteHeader(404)
 			return
@@ -70,8 +90,12 @@ func TestCircleCIClient_BuildFromURL(t *testing.T) {
 	httpClient, testURL, teardown := setupCircleCITestServer(t)
 	defer teardown()
 
+	apiV2URL := *testURL
+	apiV2URL.Path = "/api/v2"
 	client := CircleCIClient{
 		baseURL:     *testURL,
+		apiV2URL:    apiV2URL,
+		appURL:      *testURL,
 		httpClient:  httpClient,
 		rateLimiter: time.Tick(time.Millisecond),
 	}
@@ -82,68 +106,98 @@ func TestCircleCIClient_BuildFromURL(t *testing.T) {
 		t.Fatal(err)
 	}
 
+	at := func(minutes int, seconds int, milliseconds int) utils.NullTime {
+		return utils.NullTime{
+			Valid: true,
+			Time:  time.Date(2019, 11, 21, 14, minutes, seconds, milliseconds*int(time.Millisecond), time.UTC),
+		}
+	}
+	webURL := func(s string) utils.NullString {
+		return utils.NullString{
+			Valid:  true,
+			String: s,
+		}
+	}
+	pipelineWebURL := testURL.String() + "/pipelines/github/nbedos/cistern/12"
+
 	expectedPipeline := Pipeline{
-		Number: "",
+		Number: "12",
 		Ref:    "master",
 		IsTag:  false,
+		Author: "nbedos",
 		Step: Step{
-			ID:    "36",
-			Name:  "build",
-			Type:  StepJob,
-			State: Passed,
-			CreatedAt: utils.NullTime{
-				Valid: true,
-				Time:  time.Date(2019, 11, 21, 14, 40, 27, 911000000, time.UTC),
-			},
-			StartedAt: utils.NullTime{
-				Valid: true,
-				Time:  time.Date(2019, 11, 21, 14, 40, 32, 555000000, time.UTC),
-			},
-			FinishedAt: utils.NullTime{
-				Valid: true,
-				Time:  time.Date(2019, 11, 21, 14, 41, 6, 461000000, time.UTC),
-			},
-			UpdatedAt: utils.NullTime{
-				Valid: true,
-				Time:  time.Date(2019, 11, 21, 14, 41, 6, 461000000, time.UTC),
-			},
-			Duration: utils.NullDuration{
-				Valid:    true,
-				Duration: 33*time.Second + 906*time.Millisecond,
-			},
-			WebURL: utils.NullString{
-				Valid:  true,
-				String: "https://circleci.com/gh/nbedos/cistern/36",
-			},
-			Log: Log{},
+			ID:         "5034460f-c7c4-4c43-9457-de07e2029e7b",
+			Type:       StepPipeline,
+			State:      Passed,
+			CreatedAt:  at(40, 25, 0),
+			StartedAt:  at(40, 32, 555),
+			FinishedAt: at(41, 6, 0),
+			UpdatedAt:  at(41, 6, 0),
+			Duration:   utils.NullSub(at(41, 6, 0), at(40, 32, 555)),
+			WebURL:     webURL(pipelineWebURL),
 			Children: []Step{
 				{
-					ID:    "0.0",
-					Name:  "Spin up Environment",
-					Type:  3,
-					State: "passed",
-					CreatedAt: utils.NullTime{
-						Valid: true,
-						Time:  time.Date(2019, 11, 21, 14, 40, 27, 911000000, time.UTC),
-					},
-					StartedAt: utils.NullTime{
-						Valid: true,
-						Time:  time.Date(2019, 11, 21, 14, 40, 32, 620000000, time.UTC),
-					},
-					FinishedAt: utils.NullTime{
-						Valid: true,
-						Time:  time.Date(2019, 11, 21, 14, 40, 37, 893000000, time.UTC),
+					ID:         "9b17c635-15bb-4b38-9b74-86f76aa66c0e",
+					Name:       "build-workflow",
+					Type:       StepStage,
+					State:      Passed,
+					CreatedAt:  at(40, 27, 0),
+					StartedAt:  at(40, 32, 555),
+					FinishedAt: at(41, 6, 0),
+					Duration:   utils.NullSub(at(41, 6, 0), at(40, 32, 555)),
+					WebURL:     webURL(pipelineWebURL + "/workflows/9b17c635-15bb-4b38-9b74-86f76aa66c0e"),
+					Children: []Step{
+						{
+							ID:         "36",
+							Name:       "build",
+							Type:       StepJob,
+							State:      Passed,
+							CreatedAt:  at(40, 27, 911),
+							StartedAt:  at(40, 32, 555),
+							FinishedAt: at(41, 6, 461),
+							UpdatedAt:  at(41, 6, 461),
+							Duration: utils.NullDuration{
+								Valid:    true,
+								Duration: 33*time.Second + 906*time.Millisecond,
+							},
+							WebURL: webURL("https://circleci.com/gh/nbedos/cistern/36"),
+							Children: []Step{
+								{
+									ID:         "0.0",
+									Name:       "Spin up Environment",
+									Type:       StepTask,
+									State:      Passed,
+									CreatedAt:  at(40, 27, 911),
+									StartedAt:  at(40, 32, 620),
+									FinishedAt: at(40, 37, 893),
+									Duration: utils.NullDuration{
+										Valid:    true,
+										Duration: 5*time.Second + 273*time.Millisecond,
+									},
+									WebURL: webURL("https://circleci.com/gh/nbedos/cistern/36"),
+									Log: Log{
+										Key: "example.com/logurl",
+									},
+								},
+							},
+						},
 					},
-					Duration: utils.NullDuration{
-						Valid:    true,
-						Duration: 5*time.Second + 273*time.Millisecond,
-					},
-					WebURL: utils.NullString{
-						Valid:  true,
-						String: "https://circleci.com/gh/nbedos/cistern/36",
-					},
-					Log: Log{
-						Key: "example.com/logurl",
+				},
+				{
+					ID:        "c3d5a1f0-7b52-4c7e-8e0a-2f6b0d3c9a41",
+					Name:      "deploy-workflow",
+					Type:      StepStage,
+					State:     Manual,
+					CreatedAt: at(40, 27, 0),
+					WebURL:    webURL(pipelineWebURL + "/workflows/c3d5a1f0-7b52-4c7e-8e0a-2f6b0d3c9a41"),
+					Children: []Step{
+						{
+							ID:     "e7c1a9d2-3f4b-4b8e-9c6d-5a2f1b0e8d73",
+							Name:   "hold",
+							Type:   StepJob,
+							State:  Manual,
+							WebURL: webURL(pipelineWebURL + "/workflows/c3d5a1f0-7b52-4c7e-8e0a-2f6b0d3c9a41"),
+						},
 					},
 				},
 			},
@@ -181,6 +235,47 @@ func TestCircleCIClient_Log(t *testing.T) {
 	}
 }
 
+func TestCircleCIClient_Artifacts(t *testing.T) {
+	httpClient, testURL, teardown := setupCircleCITestServer(t)
+	defer teardown()
+
+	client := CircleCIClient{
+		baseURL:     *testURL,
+		httpClient:  httpClient,
+		rateLimiter: time.Tick(time.Millisecond),
+	}
+
+	step := Step{
+		Type: StepJob,
+		WebURL: utils.NullString{
+			Valid:  true,
+			String: testURL.String() + "/gh/nbedos/cistern/36",
+		},
+	}
+
+	artifacts, err := client.Artifacts(context.Background(), step)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []Artifact{
+		{
+			Path: "test-results/junit.xml",
+			URL:  testURL.String() + "/artifacts/36/junit.xml",
+		},
+	}
+	if diff := cmp.Diff(expected, artifacts); len(diff) > 0 {
+		t.Fatal(diff)
+	}
+
+	content := new(strings.Builder)
+	if err := client.DownloadArtifact(context.Background(), step, artifacts[0], content); err != nil {
+		t.Fatal(err)
+	}
+	if expected := "<testsuites></testsuites>"; content.String() != expected {
+		t.Fatalf("expected %q but got %q", expected, content.String())
+	}
+}
+
 func TestCircleCIBuild_NullCreationDate(t *testing.T) {
 	// Regression test for https://github.com/nbedos/cistern/issues/24
 	p, err := circleCIBuild{}.toPipeline()
@@ -191,3 +286,58 @@ func TestCircleCIBuild_NullCreationDate(t *testing.T) {
 		t.Fatal("expected p.CreatedAt.Valid to be false but got true")
 	}
 }
+
+func TestCircleCIClient_Lint(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "POST" || r.URL.Path != "/graphql-unstable" {
+			w.WriteHeader(404)
+			return
+		}
+		if r.Header.Get("Authorization") != "token" {
+			w.WriteHeader(401)
+			return
+		}
+		bs, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			w.WriteHeader(500)
+			return
+		}
+		if strings.Contains(string(bs), "invalid") {
+			fmt.Fprint(w, `{"data":{"buildConfig":{"valid":false,"errors":[{"message":"Unknown key: invalid"}]}}}`)
+			return
+		}
+		fmt.Fprint(w, `{"data":{"buildConfig":{"valid":true,"errors":[]}}}`)
+	}))
+	defer ts.Close()
+
+	graphQLURL, err := url.Parse(ts.URL + "/graphql-unstable")
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := CircleCIClient{
+		graphQLURL:  *graphQLURL,
+		httpClient:  ts.Client(),
+		rateLimiter: time.Tick(time.Millisecond),
+		token:       "token",
+	}
+
+	t.Run("valid configuration", func(t *testing.T) {
+		errs, err := client.Lint(context.Background(), "version: 2.1\n")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(errs) > 0 {
+			t.Fatalf("expected no error but got %v", errs)
+		}
+	})
+
+	t.Run("invalid configuration", func(t *testing.T) {
+		errs, err := client.Lint(context.Background(), "invalid: true\n")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if diff := cmp.Diff([]string{"Unknown key: invalid"}, errs); len(diff) > 0 {
+			t.Fatal(diff)
+		}
+	})
+}
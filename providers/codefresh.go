@@ -0,0 +1,329 @@
+package providers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/nbedos/cistern/utils"
+)
+
+type CodefreshClient struct {
+	baseURL     url.URL
+	httpClient  *http.Client
+	rateLimiter <-chan time.Time
+	token       string
+	provider    Provider
+	// Requests sent through httpClient
+	requests *requestCounter
+}
+
+var CodefreshURL = url.URL{Scheme: "https", Host: "g.codefresh.io"}
+
+func NewCodefreshClient(id string, name string, token string, URL string, requestsPerSecond float64) (CodefreshClient, error) {
+	rateLimit := time.Second / 10
+	if requestsPerSecond > 0 {
+		rateLimit = time.Second / time.Duration(requestsPerSecond)
+	}
+
+	u := CodefreshURL
+	if URL != "" {
+		v, err := url.Parse(URL)
+		if err != nil {
+			return CodefreshClient{}, err
+		}
+		u = *v
+	}
+	u.Path = strings.TrimSuffix(u.Path, "/")
+
+	httpClient, requests := countedClient(nil)
+
+	return CodefreshClient{
+		baseURL:     u,
+		httpClient:  httpClient,
+		requests:    requests,
+		rateLimiter: time.Tick(rateLimit),
+		token:       token,
+		provider: Provider{
+			ID:   id,
+			Name: name,
+		},
+	}, nil
+}
+
+func (c CodefreshClient) RequestStats() RequestStats {
+	return c.requests.snapshot()
+}
+
+func (c CodefreshClient) ID() string {
+	return c.provider.ID
+}
+
+func (c CodefreshClient) Host() string {
+	return c.baseURL.Host
+}
+
+func (c CodefreshClient) Name() string {
+	return c.provider.Name
+}
+
+func (c CodefreshClient) BuildFromURL(ctx context.Context, u string) (Pipeline, error) {
+	buildID, err := parseCodefreshWebURL(&c.baseURL, u)
+	if err != nil {
+		return Pipeline{}, err
+	}
+
+	return c.fetchPipeline(ctx, buildID)
+}
+
+// Extract build ID from web url of build
+func parseCodefreshWebURL(baseURL *url.URL, u string) (string, error) {
+	v, err := url.Parse(u)
+	if err != nil {
+		return "", err
+	}
+
+	if v.Hostname() != baseURL.Hostname() {
+		return "", ErrUnknownPipelineURL
+	}
+
+	// url format: https://g.codefresh.io/build/5e32d7ba9a1d7e36c1b9d3e4
+	cs := strings.Split(strings.TrimPrefix(v.Path, baseURL.Path), "/")
+	if len(cs) < 3 || cs[1] != "build" || cs[2] == "" {
+		return "", ErrUnknownPipelineURL
+	}
+
+	return cs[2], nil
+}
+
+func (c CodefreshClient) endpoint(format string, a ...interface{}) url.URL {
+	endpoint := c.baseURL
+	endpoint.Path += fmt.Sprintf(format, a...)
+
+	return endpoint
+}
+
+func (c CodefreshClient) fetchPipeline(ctx context.Context, buildID string) (Pipeline, error) {
+	var build codefreshBuild
+	if err := c.getJSON(ctx, c.endpoint("/api/workflow/%s", url.PathEscape(buildID)), &build); err != nil {
+		return Pipeline{}, err
+	}
+
+	webURL := c.endpoint("/build/%s", url.PathEscape(build.ID))
+	pipeline, err := build.toPipeline(webURL.String())
+	if err != nil {
+		return Pipeline{}, err
+	}
+
+	if build.Progress == "" {
+		return pipeline, nil
+	}
+
+	// Steps of the build are described by the associated progress object
+	var progress codefreshProgress
+	progressURL := c.endpoint("/api/progress/%s", url.PathEscape(build.Progress))
+	if err := c.getJSON(ctx, progressURL, &progress); err != nil {
+		return Pipeline{}, err
+	}
+	for i, s := range progress.Steps {
+		// The log of a step is designated by the url of the progress object and the index
+		// of the step in the progress
+		logURL := progressURL
+		logURL.Fragment = strconv.Itoa(i)
+		pipeline.Children = append(pipeline.Children, s.toStep(i, pipeline, logURL.String()))
+	}
+
+	return pipeline, nil
+}
+
+func (c CodefreshClient) Log(ctx context.Context, step Step) (string, error) {
+	if step.Log.Key == "" {
+		return "", ErrNoLogHere
+	}
+
+	u, err := url.Parse(step.Log.Key)
+	if err != nil {
+		return "", err
+	}
+	index, err := strconv.Atoi(u.Fragment)
+	if err != nil {
+		return "", err
+	}
+	u.Fragment = ""
+
+	var progress codefreshProgress
+	if err := c.getJSON(ctx, *u, &progress); err != nil {
+		return "", err
+	}
+	if index < 0 || index >= len(progress.Steps) {
+		return "", ErrNoLogHere
+	}
+
+	return strings.Join(progress.Steps[index].Logs, ""), nil
+}
+
+func (c CodefreshClient) getJSON(ctx context.Context, u url.URL, v interface{}) error {
+	r, err := c.get(ctx, u)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if errClose := r.Close(); err == nil {
+			err = errClose
+		}
+	}()
+
+	err = json.NewDecoder(r).Decode(v)
+	return err
+}
+
+func (c CodefreshClient) get(ctx context.Context, u url.URL) (io.ReadCloser, error) {
+	req, err := http.NewRequest("GET", u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Add("Accept", "application/json")
+	if c.token != "" {
+		// Codefresh API keys are passed as is, without any authentication scheme
+		req.Header.Add("Authorization", c.token)
+	}
+	req = req.WithContext(ctx)
+
+	select {
+	case <-c.rateLimiter:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		message, err := ioutil.ReadAll(resp.Body)
+		if err != nil {
+			message = nil
+		}
+		resp.Body.Close()
+		return nil, HTTPError{
+			Method:     req.Method,
+			URL:        u.String(),
+			Status:     resp.StatusCode,
+			Message:    string(message),
+			RetryAfter: resp.Header.Get("Retry-After"),
+		}
+	}
+
+	return resp.Body, nil
+}
+
+func fromCodefreshState(s string) State {
+	switch strings.ToLower(s) {
+	case "pending", "elected", "delayed", "pending-approval":
+		return Pending
+	case "running", "terminating":
+		return Running
+	case "success", "approved":
+		return Passed
+	case "error", "failure", "denied":
+		return Failed
+	case "terminated":
+		return Canceled
+	case "skipped":
+		return Skipped
+	default:
+		return Unknown
+	}
+}
+
+// Codefresh progress timestamps are UNIX timestamps where 0 stands for a missing value
+func codefreshTime(timestamp float64) utils.NullTime {
+	if timestamp <= 0 {
+		return utils.NullTime{}
+	}
+	return utils.NullTime{
+		Valid: true,
+		Time:  time.Unix(int64(timestamp), 0).UTC(),
+	}
+}
+
+type codefreshBuild struct {
+	ID         string `json:"id"`
+	Status     string `json:"status"`
+	Created    string `json:"created"`
+	Started    string `json:"started"`
+	Finished   string `json:"finished"`
+	BranchName string `json:"branchName"`
+	Revision   string `json:"revision"`
+	Progress   string `json:"progress"`
+}
+
+func (b codefreshBuild) toPipeline(webURL string) (Pipeline, error) {
+	pipeline := Pipeline{
+		Number: b.ID,
+		Ref:    b.BranchName,
+		Step: Step{
+			ID:    b.ID,
+			Type:  StepPipeline,
+			State: fromCodefreshState(b.Status),
+			WebURL: utils.NullString{
+				Valid:  true,
+				String: webURL,
+			},
+		},
+	}
+
+	var err error
+	if pipeline.CreatedAt, err = utils.NullTimeFromString(b.Created); err != nil {
+		return Pipeline{}, err
+	}
+	if pipeline.StartedAt, err = utils.NullTimeFromString(b.Started); err != nil {
+		return Pipeline{}, err
+	}
+	if pipeline.FinishedAt, err = utils.NullTimeFromString(b.Finished); err != nil {
+		return Pipeline{}, err
+	}
+	pipeline.UpdatedAt = utils.MaxNullTime(pipeline.CreatedAt, pipeline.StartedAt, pipeline.FinishedAt)
+	pipeline.Duration = utils.NullSub(pipeline.FinishedAt, pipeline.StartedAt)
+
+	return pipeline, nil
+}
+
+type codefreshProgress struct {
+	ID    string          `json:"id"`
+	Steps []codefreshStep `json:"steps"`
+}
+
+type codefreshStep struct {
+	Title             string   `json:"title"`
+	Status            string   `json:"status"`
+	CreationTimeStamp float64  `json:"creationTimeStamp"`
+	FinishTimeStamp   float64  `json:"finishTimeStamp"`
+	Logs              []string `json:"logs"`
+}
+
+func (s codefreshStep) toStep(index int, pipeline Pipeline, logKey string) Step {
+	step := Step{
+		ID:         strconv.Itoa(index),
+		Name:       s.Title,
+		Type:       StepJob,
+		State:      fromCodefreshState(s.Status),
+		CreatedAt:  codefreshTime(s.CreationTimeStamp),
+		StartedAt:  codefreshTime(s.CreationTimeStamp),
+		FinishedAt: codefreshTime(s.FinishTimeStamp),
+		WebURL:     pipeline.WebURL,
+		Log: Log{
+			Key: logKey,
+		},
+	}
+	step.Duration = utils.NullSub(step.FinishedAt, step.StartedAt)
+
+	return step
+}
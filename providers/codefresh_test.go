@@ -0,0 +1,180 @@
+package providers
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/nbedos/cistern/utils"
+)
+
+func setupCodefreshTestServer(t *testing.T) (CodefreshClient, *url.URL, func()) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "token" {
+			w.WriteHeader(401)
+			return
+		}
+
+		filename := ""
+		switch r.URL.Path {
+		case "/api/workflow/5e32d7ba9a1d7e36c1b9d3e4":
+			filename = "codefresh_workflow.json"
+		case "/api/progress/5e32d7ba9a1d7e36c1b9d3e5":
+			filename = "codefresh_progress.json"
+		default:
+			w.WriteHeader(404)
+			return
+		}
+
+		bs, err := ioutil.ReadFile(path.Join("test_data", "codefresh", filename))
+		if err != nil {
+			w.WriteHeader(500)
+			fmt.Fprint(w, err.Error())
+			return
+		}
+		if _, err := fmt.Fprint(w, string(bs)); err != nil {
+			w.WriteHeader(500)
+			fmt.Fprint(w, err.Error())
+			return
+		}
+	}))
+
+	testURL, err := url.Parse(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	client := CodefreshClient{
+		baseURL:     *testURL,
+		httpClient:  ts.Client(),
+		rateLimiter: time.Tick(time.Millisecond),
+		token:       "token",
+	}
+
+	return client, testURL, func() { ts.Close() }
+}
+
+func TestParseCodefreshWebURL(t *testing.T) {
+	baseURL := CodefreshURL
+
+	t.Run("build url", func(t *testing.T) {
+		id, err := parseCodefreshWebURL(&baseURL, "https://g.codefresh.io/build/5e32d7ba9a1d7e36c1b9d3e4")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if id != "5e32d7ba9a1d7e36c1b9d3e4" {
+			t.Fatalf("expected %q but got %q", "5e32d7ba9a1d7e36c1b9d3e4", id)
+		}
+	})
+
+	t.Run("url of another host", func(t *testing.T) {
+		_, err := parseCodefreshWebURL(&baseURL, "https://example.com/build/5e32d7ba9a1d7e36c1b9d3e4")
+		if err != ErrUnknownPipelineURL {
+			t.Fatalf("expected %v but got %v", ErrUnknownPipelineURL, err)
+		}
+	})
+}
+
+func TestCodefreshClient_BuildFromURL(t *testing.T) {
+	client, testURL, teardown := setupCodefreshTestServer(t)
+	defer teardown()
+
+	pipelineURL := testURL.String() + "/build/5e32d7ba9a1d7e36c1b9d3e4"
+	pipeline, err := client.BuildFromURL(context.Background(), pipelineURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	at := func(minutes int, seconds int) utils.NullTime {
+		return utils.NullTime{
+			Valid: true,
+			Time:  time.Date(2020, 1, 30, 13, minutes, seconds, 0, time.UTC),
+		}
+	}
+	webURL := utils.NullString{
+		Valid:  true,
+		String: pipelineURL,
+	}
+	logKey := testURL.String() + "/api/progress/5e32d7ba9a1d7e36c1b9d3e5#"
+
+	expectedPipeline := Pipeline{
+		Number: "5e32d7ba9a1d7e36c1b9d3e4",
+		Ref:    "master",
+		Step: Step{
+			ID:         "5e32d7ba9a1d7e36c1b9d3e4",
+			Type:       StepPipeline,
+			State:      Failed,
+			CreatedAt:  at(0, 0),
+			StartedAt:  at(0, 5),
+			FinishedAt: at(1, 30),
+			UpdatedAt:  at(1, 30),
+			Duration: utils.NullDuration{
+				Valid:    true,
+				Duration: 85 * time.Second,
+			},
+			WebURL: webURL,
+			Children: []Step{
+				{
+					ID:         "0",
+					Name:       "Cloning repository",
+					Type:       StepJob,
+					State:      Passed,
+					CreatedAt:  at(0, 5),
+					StartedAt:  at(0, 5),
+					FinishedAt: at(0, 30),
+					Duration: utils.NullDuration{
+						Valid:    true,
+						Duration: 25 * time.Second,
+					},
+					WebURL: webURL,
+					Log:    Log{Key: logKey + "0"},
+				},
+				{
+					ID:         "1",
+					Name:       "Running tests",
+					Type:       StepJob,
+					State:      Failed,
+					CreatedAt:  at(0, 30),
+					StartedAt:  at(0, 30),
+					FinishedAt: at(1, 30),
+					Duration: utils.NullDuration{
+						Valid:    true,
+						Duration: time.Minute,
+					},
+					WebURL: webURL,
+					Log:    Log{Key: logKey + "1"},
+				},
+			},
+		},
+	}
+	if diff := expectedPipeline.Diff(pipeline); len(diff) > 0 {
+		t.Fatal(diff)
+	}
+}
+
+func TestCodefreshClient_Log(t *testing.T) {
+	client, testURL, teardown := setupCodefreshTestServer(t)
+	defer teardown()
+
+	step := Step{
+		Log: Log{
+			Key: testURL.String() + "/api/progress/5e32d7ba9a1d7e36c1b9d3e5#1",
+		},
+	}
+
+	log, err := client.Log(context.Background(), step)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if diff := cmp.Diff("$ go test ./...\r\nFAIL\r\n", log); len(diff) > 0 {
+		t.Fatal(diff)
+	}
+}
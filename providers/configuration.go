@@ -0,0 +1,48 @@
+package providers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+var ErrNoConfiguration = errors.New("the provider of this pipeline does not expose the configuration it executed")
+
+// CI providers implementing ConfigurationProvider expose the configuration executed by a
+// pipeline, that is the configuration file once includes and templates have been expanded
+type ConfigurationProvider interface {
+	Configuration(ctx context.Context, p Pipeline) (string, error)
+}
+
+// Return the configuration executed by the pipeline identified by 'key'
+func (c *Cache) Configuration(ctx context.Context, key PipelineKey) (string, error) {
+	pipeline, exists := c.Pipeline(key)
+	if !exists {
+		return "", fmt.Errorf("no matching pipeline for %v", key)
+	}
+	provider, exists := c.ciProvidersByID[pipeline.providerID]
+	if !exists {
+		return "", fmt.Errorf("no matching Provider found in cache for account ID %q", pipeline.providerID)
+	}
+	configurationProvider, ok := provider.(ConfigurationProvider)
+	if !ok {
+		return "", ErrNoConfiguration
+	}
+
+	ctx, cancel := requestContext(ctx, c.timeout())
+	defer cancel()
+	configuration, err := configurationProvider.Configuration(ctx, pipeline)
+	if err != nil {
+		if err != ErrNoConfiguration {
+			err = ProviderError{
+				ProviderID: provider.ID(),
+				Operation:  "fetch configuration of " + pipelineName(pipeline),
+				URL:        pipeline.WebURL.String,
+				Err:        err,
+			}
+		}
+		return "", err
+	}
+
+	return configuration, nil
+}
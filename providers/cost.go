@@ -0,0 +1,109 @@
+package providers
+
+import (
+	"fmt"
+	"math"
+	"path"
+)
+
+// Price of one minute of a runner. A rate applies to the jobs run on a CI provider of type
+// Provider (e.g. "github", "gitlab") whose name matches one of the glob patterns of Jobs.
+// Empty fields match everything.
+type CostRate struct {
+	Provider  string
+	Jobs      []string
+	PerMinute float64
+}
+
+func (r CostRate) matches(providerType string, job string) bool {
+	if r.Provider != "" && r.Provider != providerType {
+		return false
+	}
+	if len(r.Jobs) == 0 {
+		return true
+	}
+	for _, pattern := range r.Jobs {
+		if matched, err := path.Match(pattern, job); err == nil && matched {
+			return true
+		}
+	}
+	return false
+}
+
+// CostModel estimates the cost of jobs from their duration. Like most CI providers, each job
+// is billed by the minute, rounded up.
+type CostModel struct {
+	// Symbol prepended to amounts, e.g. "$"
+	Currency string
+	// The first matching rate applies
+	Rates []CostRate
+}
+
+// Return the estimated cost of a job of the pipeline p. The result is false if no rate applies
+// to the job or if the duration of the job is unknown.
+func (m CostModel) JobCost(p Pipeline, job Step) (float64, bool) {
+	duration := job.Duration
+	if !duration.Valid && job.StartedAt.Valid && job.FinishedAt.Valid {
+		duration.Valid = true
+		duration.Duration = job.FinishedAt.Time.Sub(job.StartedAt.Time)
+	}
+	if !duration.Valid {
+		return 0, false
+	}
+	for _, rate := range m.Rates {
+		if rate.matches(providerType(p.providerID), job.Name) {
+			minutes := math.Ceil(duration.Duration.Minutes())
+			return minutes * rate.PerMinute, true
+		}
+	}
+	return 0, false
+}
+
+// Return the estimated cost of all the jobs of the pipeline p. The result is false if the cost
+// of none of the jobs could be estimated.
+func (m CostModel) PipelineCost(p Pipeline) (float64, bool) {
+	total, valid := 0.0, false
+	var traverse func(s Step)
+	traverse = func(s Step) {
+		if s.Type == StepJob {
+			if cost, ok := m.JobCost(p, s); ok {
+				total += cost
+				valid = true
+			}
+			return
+		}
+		for _, child := range s.Children {
+			traverse(child)
+		}
+	}
+	traverse(p.Step)
+
+	return total, valid
+}
+
+// Return the estimated cost of the pipelines of the group
+func (g PipelineGroup) cost(m CostModel) (float64, bool) {
+	total, valid := 0.0, false
+	for _, p := range g.Pipelines {
+		if cost, ok := m.PipelineCost(p); ok {
+			total += cost
+			valid = true
+		}
+	}
+	return total, valid
+}
+
+func compareFloats(lhs float64, rhs float64) int {
+	switch {
+	case lhs < rhs:
+		return -1
+	case lhs > rhs:
+		return 1
+	default:
+		return 0
+	}
+}
+
+func (m CostModel) Format(cost float64) string {
+	return fmt.Sprintf("%s%.2f", m.Currency, cost)
+}
@@ -0,0 +1,76 @@
+package providers
+
+import (
+	"testing"
+	"time"
+
+	"github.com/nbedos/cistern/utils"
+)
+
+func TestCostModel_PipelineCost(t *testing.T) {
+	job := func(name string, d time.Duration) Step {
+		return Step{
+			Name:     name,
+			Type:     StepJob,
+			Duration: utils.NullDuration{Valid: true, Duration: d},
+		}
+	}
+	pipeline := Pipeline{
+		providerID: "github-0",
+		Step: Step{
+			Children: []Step{
+				{
+					Type: StepStage,
+					Children: []Step{
+						job("test (macos-latest)", 90*time.Second),
+						job("test (ubuntu-latest)", 10*time.Minute),
+					},
+				},
+				{Name: "pending", Type: StepJob},
+			},
+		},
+	}
+
+	testCases := []struct {
+		name  string
+		model CostModel
+		cost  float64
+		valid bool
+	}{
+		{
+			name:  "no rate",
+			model: CostModel{},
+			valid: false,
+		},
+		{
+			name: "first matching rate applies and minutes are rounded up",
+			model: CostModel{
+				Rates: []CostRate{
+					{Provider: "github", Jobs: []string{"*macos*"}, PerMinute: 0.08},
+					{Provider: "github", PerMinute: 0.01},
+				},
+			},
+			cost:  2*0.08 + 10*0.01,
+			valid: true,
+		},
+		{
+			name: "rates of other providers are ignored",
+			model: CostModel{
+				Rates: []CostRate{{Provider: "gitlab", PerMinute: 1}},
+			},
+			valid: false,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			cost, valid := testCase.model.PipelineCost(pipeline)
+			if valid != testCase.valid {
+				t.Fatalf("expected valid=%v but got %v", testCase.valid, valid)
+			}
+			if diff := cost - testCase.cost; diff > 1e-9 || diff < -1e-9 {
+				t.Fatalf("expected cost %v but got %v", testCase.cost, cost)
+			}
+		})
+	}
+}
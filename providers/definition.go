@@ -0,0 +1,121 @@
+package providers
+
+import (
+	"bufio"
+	"errors"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+var ErrNoDefinition = errors.New("no definition found in the CI configuration files of the repository")
+
+// Paths of the CI configuration files relative to the root of the repository
+var ciConfigurationFiles = []string{
+	".gitlab-ci.yml",
+	".travis.yml",
+	".circleci/config.yml",
+	"appveyor.yml",
+	".appveyor.yml",
+	"azure-pipelines.yml",
+	"codefresh.yml",
+	"buddy.yml",
+	"screwdriver.yaml",
+	".woodpecker.yml",
+	".drone.yml",
+}
+
+// Location of the definition of a job in a CI configuration file
+type Definition struct {
+	Path string
+	// Line number starting at 1
+	Line int
+}
+
+// Return the CI configuration files of the repository whose root is 'dir'
+func ciConfigurationPaths(dir string) ([]string, error) {
+	paths := make([]string, 0)
+	for _, p := range ciConfigurationFiles {
+		paths = append(paths, filepath.Join(dir, filepath.FromSlash(p)))
+	}
+	// Workflows of GitHub Actions and Gitea Actions
+	for _, workflowDir := range []string{".github", ".gitea"} {
+		for _, pattern := range []string{"*.yml", "*.yaml"} {
+			workflows, err := filepath.Glob(filepath.Join(dir, workflowDir, "workflows", pattern))
+			if err != nil {
+				return nil, err
+			}
+			sort.Strings(workflows)
+			paths = append(paths, workflows...)
+		}
+	}
+
+	return paths, nil
+}
+
+// Return the names that may have been used to define the job: names of jobs of a build
+// matrix such as "build (1.13, ubuntu)" are derived from the name found in the configuration.
+func definitionNames(job string) []string {
+	names := []string{job}
+	if i := strings.Index(job, " ("); i > 0 && strings.HasSuffix(job, ")") {
+		names = append(names, job[:i])
+	}
+	return names
+}
+
+// Return the first line of the file at 'path' defining the job named 'name', either as a key
+// ("name:") or as the value of a key naming jobs ("name: NAME", "job: NAME"...)
+func findDefinition(path string, name string) (int, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	quoted := regexp.QuoteMeta(name)
+	key := regexp.MustCompile(`^\s*(-\s*)?["']?` + quoted + `["']?\s*:\s*(#.*)?$`)
+	value := regexp.MustCompile(`^\s*(-\s*)?(name|displayName|job|deployment)\s*:\s*["']?` + quoted + `["']?\s*(#.*)?$`)
+
+	scanner := bufio.NewScanner(f)
+	for n := 1; scanner.Scan(); n++ {
+		if line := scanner.Text(); key.MatchString(line) || value.MatchString(line) {
+			return n, nil
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return 0, err
+	}
+
+	return 0, ErrNoDefinition
+}
+
+// Return the location of the definition of the job named 'job' in the CI configuration files
+// of the repository whose root is 'dir'. Configuration files are scanned line by line instead
+// of being parsed so the result is the first line that looks like the definition of the job.
+func JobDefinition(dir string, job string) (Definition, error) {
+	if job == "" {
+		return Definition{}, ErrNoDefinition
+	}
+	paths, err := ciConfigurationPaths(dir)
+	if err != nil {
+		return Definition{}, err
+	}
+
+	for _, name := range definitionNames(job) {
+		for _, p := range paths {
+			line, err := findDefinition(p, name)
+			switch {
+			case err == nil:
+				return Definition{Path: p, Line: line}, nil
+			case err == ErrNoDefinition, os.IsNotExist(err):
+				continue
+			default:
+				return Definition{}, err
+			}
+		}
+	}
+
+	return Definition{}, ErrNoDefinition
+}
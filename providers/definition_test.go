@@ -0,0 +1,83 @@
+package providers
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestJobDefinition(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cistern")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	files := map[string]string{
+		".gitlab-ci.yml": `stages:
+  - test
+
+"go test":
+  stage: test
+  script: go test ./...
+`,
+		".github/workflows/ci.yml": `name: CI
+on: push
+jobs:
+  lint:
+    runs-on: ubuntu-latest
+  build:
+    name: Build
+    runs-on: ubuntu-latest
+`,
+	}
+	for name, content := range files {
+		p := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(p), 0700); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(p, []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	testCases := []struct {
+		job        string
+		definition Definition
+		err        error
+	}{
+		{
+			job:        "go test",
+			definition: Definition{Path: filepath.Join(dir, ".gitlab-ci.yml"), Line: 4},
+		},
+		{
+			job:        "lint",
+			definition: Definition{Path: filepath.Join(dir, ".github", "workflows", "ci.yml"), Line: 4},
+		},
+		{
+			job:        "Build (1.13, ubuntu-latest)",
+			definition: Definition{Path: filepath.Join(dir, ".github", "workflows", "ci.yml"), Line: 7},
+		},
+		{
+			job: "deploy",
+			err: ErrNoDefinition,
+		},
+		{
+			job: "",
+			err: ErrNoDefinition,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.job, func(t *testing.T) {
+			definition, err := JobDefinition(dir, testCase.job)
+			if err != testCase.err {
+				t.Fatalf("expected error %v but got %v", testCase.err, err)
+			}
+			if definition != testCase.definition {
+				t.Fatalf("expected %+v but got %+v", testCase.definition, definition)
+			}
+		})
+	}
+}
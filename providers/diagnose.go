@@ -0,0 +1,117 @@
+package providers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/nbedos/cistern/utils"
+)
+
+// Outcome of a request made to a source provider to check that it can be reached
+type ConnectivityResult struct {
+	ProviderID string
+	// URL of the repository requested
+	URL           string
+	Authenticated bool
+	// True if the token of the provider comes from a command that was not run
+	TokenFromProcess bool
+	Duration         time.Duration
+	Err              error
+}
+
+// Ask each source provider hosting one of the repositories 'urls' for the commit at HEAD.
+// Results are sorted by provider and URL.
+func (c *Cache) CheckConnectivity(ctx context.Context, urls []string) []ConnectivityResult {
+	results := make([]ConnectivityResult, 0)
+	mux := sync.Mutex{}
+	wg := sync.WaitGroup{}
+	for _, provider := range c.sourceProviders {
+		for _, u := range urls {
+			if !provider.HostsRepository(u) {
+				continue
+			}
+			wg.Add(1)
+			go func(provider SourceProvider, u string) {
+				defer wg.Done()
+				start := time.Now()
+				ctx, cancel := requestContext(ctx, c.timeout())
+				defer cancel()
+				_, err := provider.Commit(ctx, u, "HEAD")
+				result := ConnectivityResult{
+					ProviderID:    provider.ID(),
+					URL:           u,
+					Authenticated: authenticated(provider),
+					Duration:      time.Since(start),
+					Err:           err,
+				}
+				mux.Lock()
+				results = append(results, result)
+				mux.Unlock()
+			}(provider, u)
+		}
+	}
+	wg.Wait()
+
+	sort.Slice(results, func(i, j int) bool {
+		ri, rj := results[i], results[j]
+		return ri.ProviderID < rj.ProviderID || (ri.ProviderID == rj.ProviderID && ri.URL < rj.URL)
+	})
+
+	return results
+}
+
+// Ask each source provider of the configuration hosting one of the repositories 'urls' for the
+// commit at HEAD. Unlike ToCache, this does not run the commands of "token-from-process"
+// settings: the providers concerned make anonymous requests instead. CI providers, including
+// plugins, are not set up.
+func (c Configuration) CheckConnectivity(ctx context.Context, urls []string) ([]ConnectivityResult, error) {
+	source := make([]SourceProvider, 0)
+	fromProcess := make(map[string]bool)
+
+	for i, conf := range c.GitLab {
+		id := fmt.Sprintf("gitlab-%d", i)
+		client, err := NewGitLabClient(id, conf.Name, conf.URL, conf.Token, conf.RequestsPerSecond, conf.SSHHost)
+		if err != nil {
+			return nil, err
+		}
+		source = append(source, client)
+		fromProcess[id] = len(conf.TokenFromProcess) > 0
+	}
+
+	for i, conf := range c.GitHub {
+		id := fmt.Sprintf("github-%d", i)
+		token := conf.Token
+		client := NewGitHubClient(ctx, id, &token, nil, nil)
+		source = append(source, client)
+		fromProcess[id] = len(conf.TokenFromProcess) > 0
+	}
+
+	for i, conf := range c.Gitea {
+		id := fmt.Sprintf("gitea-%d", i)
+		client, err := NewGiteaClient(id, conf.Name, conf.Token, conf.URL, conf.RequestsPerSecond, conf.SSHHost)
+		if err != nil {
+			return nil, err
+		}
+		source = append(source, client)
+		fromProcess[id] = len(conf.TokenFromProcess) > 0
+	}
+
+	cache := NewCache(nil, source, utils.PollingStrategy{})
+	switch {
+	case c.RequestTimeout < 0:
+		return nil, errors.New("request timeout must be greater than 0s")
+	case c.RequestTimeout > 0:
+		cache.SetRequestTimeout(time.Duration(c.RequestTimeout) * time.Second)
+	}
+
+	results := cache.CheckConnectivity(ctx, urls)
+	for i := range results {
+		results[i].TokenFromProcess = fromProcess[results[i].ProviderID]
+	}
+
+	return results, nil
+}
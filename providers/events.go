@@ -0,0 +1,227 @@
+package providers
+
+import (
+	"context"
+	"sync"
+)
+
+type EventType int
+
+const (
+	// A pipeline was saved in the cache for the first time
+	PipelineAdded EventType = iota
+	// The state of a pipeline or of one of its steps changed
+	StateChanged
+	// The log of a step was saved in the cache
+	LogAppended
+	// A commit was saved in the cache
+	CommitSaved
+	// A new version of a pipeline already in the cache was saved, or the cache started or
+	// stopped retrying to fetch it. This is published after the StateChanged events caused by
+	// the new version.
+	PipelineUpdated
+	// A CI provider returned an error while the cache was monitoring pipelines
+	ProviderFailed
+)
+
+func (t EventType) String() string {
+	switch t {
+	case PipelineAdded:
+		return "pipeline added"
+	case StateChanged:
+		return "state changed"
+	case LogAppended:
+		return "log appended"
+	case CommitSaved:
+		return "commit saved"
+	case PipelineUpdated:
+		return "pipeline updated"
+	case ProviderFailed:
+		return "provider failed"
+	default:
+		return "unknown event"
+	}
+}
+
+// Change of the content of the cache
+type Event struct {
+	Type EventType
+	// Pipeline concerned by the event, unset for CommitSaved and ProviderFailed
+	PipelineKey PipelineKey
+	// Path of the step concerned by the event from the pipeline. Empty for the pipeline
+	// itself and for PipelineAdded, PipelineUpdated, CommitSaved and ProviderFailed.
+	StepIDs []string
+	// Set for StateChanged only
+	StepType     StepType
+	AllowFailure bool
+	Previous     State
+	State        State
+	// Set for CommitSaved only
+	Ref    string
+	Commit Commit
+	// Set for LogAppended only: content of the log saved
+	Log string
+	// Set for ProviderFailed only
+	Err ProviderError
+}
+
+// Return true if the step concerned by a StateChanged event reached a final state that is not a
+// failure: passed, skipped, waiting for a manual action or failed while being allowed to fail
+func (e Event) Finished() bool {
+	switch e.State {
+	case Passed, Skipped, Manual:
+		return true
+	case Failed, Canceled:
+		return e.AllowFailure
+	default:
+		return false
+	}
+}
+
+// Return true if the step concerned by a StateChanged event passed or failed while being
+// allowed to fail
+func (e Event) Passed() bool {
+	return e.State == Passed || (e.AllowFailure && (e.State == Failed || e.State == Canceled))
+}
+
+// Return a StateChanged event for each step of 'after' whose state differs from the state of
+// the step with the same path in 'before'
+func stateEvents(key PipelineKey, after Step, before Step, stepIDs []string) []Event {
+	events := make([]Event, 0)
+	if after.State != before.State {
+		events = append(events, Event{
+			Type:         StateChanged,
+			PipelineKey:  key,
+			StepIDs:      append([]string(nil), stepIDs...),
+			StepType:     after.Type,
+			AllowFailure: after.AllowFailure,
+			Previous:     before.State,
+			State:        after.State,
+		})
+	}
+
+	for _, child := range after.Children {
+		beforeChild, exists := before.getStep([]string{child.ID})
+		if !exists {
+			beforeChild = Step{}
+		}
+		childIDs := append(append([]string(nil), stepIDs...), child.ID)
+		events = append(events, stateEvents(key, child, beforeChild, childIDs)...)
+	}
+
+	return events
+}
+
+// Events published by the cache but not yet received by a subscriber
+type subscription struct {
+	mutex  *sync.Mutex
+	queue  []Event
+	notify chan struct{}
+}
+
+func (s *subscription) push(events []Event) {
+	s.mutex.Lock()
+	s.queue = append(s.queue, events...)
+	s.mutex.Unlock()
+
+	select {
+	case s.notify <- struct{}{}:
+	default:
+		// The subscriber has already been notified
+	}
+}
+
+func (s *subscription) pop() []Event {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	events := s.queue
+	s.queue = nil
+	return events
+}
+
+type subscriptions struct {
+	mutex *sync.Mutex
+	all   map[*subscription]struct{}
+}
+
+func newSubscriptions() *subscriptions {
+	return &subscriptions{
+		mutex: &sync.Mutex{},
+		all:   make(map[*subscription]struct{}),
+	}
+}
+
+// Send events to all subscribers. This never blocks so it may be called while holding the
+// mutex of the cache.
+func (s *subscriptions) publish(events ...Event) {
+	if len(events) == 0 {
+		return
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	for sub := range s.all {
+		sub.push(events)
+	}
+}
+
+// Return a channel receiving every change of the content of the cache in the order the
+// changes happened. The channel is closed once ctx is canceled. Events are queued until they
+// are received so a slow subscriber never blocks the cache or other subscribers.
+func (c *Cache) Subscribe(ctx context.Context) <-chan Event {
+	batches := c.SubscribeBatches(ctx)
+	events := make(chan Event)
+	go func() {
+		defer close(events)
+		for batch := range batches {
+			for _, e := range batch {
+				select {
+				case events <- e:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+
+	return events
+}
+
+// Same as Subscribe but all the events queued since the previous receive are sent at once,
+// so that subscribers can handle the many events published by a single change of the cache
+// in one go
+func (c *Cache) SubscribeBatches(ctx context.Context) <-chan []Event {
+	sub := &subscription{
+		mutex:  &sync.Mutex{},
+		notify: make(chan struct{}, 1),
+	}
+	c.subscriptions.mutex.Lock()
+	c.subscriptions.all[sub] = struct{}{}
+	c.subscriptions.mutex.Unlock()
+
+	batches := make(chan []Event)
+	go func() {
+		defer close(batches)
+		defer func() {
+			c.subscriptions.mutex.Lock()
+			delete(c.subscriptions.all, sub)
+			c.subscriptions.mutex.Unlock()
+		}()
+
+		for {
+			if batch := sub.pop(); len(batch) > 0 {
+				select {
+				case batches <- batch:
+				case <-ctx.Done():
+					return
+				}
+			}
+			select {
+			case <-sub.notify:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return batches
+}
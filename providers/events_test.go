@@ -0,0 +1,155 @@
+package providers
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/nbedos/cistern/utils"
+)
+
+func TestCache_Subscribe(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	c := NewCache([]CIProvider{&testProvider{"ci", "ci.example.com", 0}}, nil, utils.PollingStrategy{})
+	events := c.Subscribe(ctx)
+
+	pipeline := Pipeline{
+		providerID:   "ci",
+		ProviderHost: "ci.example.com",
+		Step: Step{
+			ID:    "1",
+			Type:  StepPipeline,
+			State: Running,
+			Children: []Step{
+				{ID: "2", Type: StepJob, State: Passed},
+				{ID: "3", Type: StepJob, State: Running},
+			},
+		},
+	}
+	key := pipeline.Key()
+
+	// The subscriber does not receive anything until all changes are done to make sure
+	// publishing never blocks the cache
+	c.SaveCommit("master", Commit{Sha: "sha"})
+	if _, err := c.SavePipeline("sha", pipeline); err != nil {
+		t.Fatal(err)
+	}
+	pipeline.State = Failed
+	pipeline.Children[1].State = Failed
+	if _, err := c.SavePipeline("sha", pipeline); err != nil {
+		t.Fatal(err)
+	}
+	c.saveLog(key, []string{"2"}, "log")
+
+	expected := []Event{
+		{Type: CommitSaved, Ref: "master", Commit: Commit{Sha: "sha"}},
+		{Type: PipelineAdded, PipelineKey: key},
+		{Type: StateChanged, PipelineKey: key, StepType: StepPipeline, State: Running},
+		{Type: StateChanged, PipelineKey: key, StepIDs: []string{"2"}, StepType: StepJob, State: Passed},
+		{Type: StateChanged, PipelineKey: key, StepIDs: []string{"3"}, StepType: StepJob, State: Running},
+		{Type: StateChanged, PipelineKey: key, StepType: StepPipeline, Previous: Running, State: Failed},
+		{Type: StateChanged, PipelineKey: key, StepIDs: []string{"3"}, StepType: StepJob, Previous: Running, State: Failed},
+		{Type: PipelineUpdated, PipelineKey: key},
+		{Type: LogAppended, PipelineKey: key, StepIDs: []string{"2"}, Log: "log"},
+	}
+	received := make([]Event, 0, len(expected))
+	for len(received) < len(expected) {
+		select {
+		case e := <-events:
+			received = append(received, e)
+		case <-time.After(time.Second):
+			t.Fatalf("timeout waiting for events, received %+v", received)
+		}
+	}
+	if diff := cmp.Diff(expected, received); len(diff) > 0 {
+		t.Fatal(diff)
+	}
+
+	cancel()
+	select {
+	case _, open := <-events:
+		if open {
+			t.Fatal("expected no more events")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected channel to be closed once the context is canceled")
+	}
+}
+
+func TestEvent_Finished(t *testing.T) {
+	testCases := []struct {
+		event    Event
+		passed   bool
+		finished bool
+	}{
+		{event: Event{State: Passed}, passed: true, finished: true},
+		{event: Event{State: Failed}, passed: false, finished: false},
+		{event: Event{State: Failed, AllowFailure: true}, passed: true, finished: true},
+		{event: Event{State: Manual}, passed: false, finished: true},
+		{event: Event{State: Running}, passed: false, finished: false},
+	}
+
+	for _, testCase := range testCases {
+		if passed := testCase.event.Passed(); passed != testCase.passed {
+			t.Errorf("%v: expected Passed() to be %v", testCase.event.State, testCase.passed)
+		}
+		if finished := testCase.event.Finished(); finished != testCase.finished {
+			t.Errorf("%v: expected Finished() to be %v", testCase.event.State, testCase.finished)
+		}
+	}
+}
+
+func TestCache_SubscribeBatches(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	c := NewCache([]CIProvider{&testProvider{"ci", "ci.example.com", 0}}, nil, utils.PollingStrategy{})
+	batches := c.SubscribeBatches(ctx)
+
+	pipeline := Pipeline{
+		providerID:   "ci",
+		ProviderHost: "ci.example.com",
+		Step: Step{
+			ID:    "1",
+			Type:  StepPipeline,
+			State: Running,
+			Children: []Step{
+				{ID: "2", Type: StepJob, State: Passed},
+				{ID: "3", Type: StepJob, State: Running},
+			},
+		},
+	}
+	key := pipeline.Key()
+	if _, err := c.SavePipeline("sha", pipeline); err != nil {
+		t.Fatal(err)
+	}
+
+	// All the events of a single save are received at once
+	expected := []Event{
+		{Type: PipelineAdded, PipelineKey: key},
+		{Type: StateChanged, PipelineKey: key, StepType: StepPipeline, State: Running},
+		{Type: StateChanged, PipelineKey: key, StepIDs: []string{"2"}, StepType: StepJob, State: Passed},
+		{Type: StateChanged, PipelineKey: key, StepIDs: []string{"3"}, StepType: StepJob, State: Running},
+	}
+	select {
+	case batch := <-batches:
+		if diff := cmp.Diff(expected, batch); len(diff) > 0 {
+			t.Fatal(diff)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("timeout waiting for events")
+	}
+
+	cancel()
+	select {
+	case _, open := <-batches:
+		if open {
+			t.Fatal("expected no more events")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected channel to be closed once the context is canceled")
+	}
+}
@@ -0,0 +1,399 @@
+package providers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nbedos/cistern/utils"
+)
+
+// GenericClient reads pipelines described in the JSON format documented in the manual page
+// from an HTTP endpoint, a file or the standard input. This allows integrating CI systems for
+// which cistern has no dedicated provider.
+type GenericClient struct {
+	// Either an HTTP(S) URL, the path of a file or "-" for the standard input
+	source      string
+	sourceURL   *url.URL
+	stdin       *genericStdin
+	httpClient  *http.Client
+	rateLimiter <-chan time.Time
+	token       string
+	provider    Provider
+	// Requests sent through httpClient
+	requests *requestCounter
+}
+
+// The standard input can only be read once so its content is kept for the lifetime of the
+// client
+type genericStdin struct {
+	once    *sync.Once
+	reader  io.Reader
+	content []byte
+	err     error
+}
+
+func NewGenericClient(id string, name string, token string, source string, requestsPerSecond float64) (GenericClient, error) {
+	rateLimit := time.Second / 10
+	if requestsPerSecond > 0 {
+		rateLimit = time.Second / time.Duration(requestsPerSecond)
+	}
+
+	if source == "" {
+		return GenericClient{}, errors.New("the source of a generic provider must not be empty")
+	}
+
+	var sourceURL *url.URL
+	if strings.HasPrefix(source, "http://") || strings.HasPrefix(source, "https://") {
+		u, err := url.Parse(source)
+		if err != nil {
+			return GenericClient{}, err
+		}
+		sourceURL = u
+	}
+
+	httpClient, requests := countedClient(nil)
+
+	return GenericClient{
+		source:    source,
+		sourceURL: sourceURL,
+		stdin: &genericStdin{
+			once:   &sync.Once{},
+			reader: os.Stdin,
+		},
+		httpClient:  httpClient,
+		requests:    requests,
+		rateLimiter: time.Tick(rateLimit),
+		token:       token,
+		provider: Provider{
+			ID:   id,
+			Name: name,
+		},
+	}, nil
+}
+
+func (c GenericClient) RequestStats() RequestStats {
+	return c.requests.snapshot()
+}
+
+func (c GenericClient) ID() string {
+	return c.provider.ID
+}
+
+// Host of the HTTP endpoint or, for a file or the standard input, the ID of the provider so that
+// keys of pipelines from different sources do not collide
+func (c GenericClient) Host() string {
+	if c.sourceURL != nil {
+		return c.sourceURL.Host
+	}
+	return c.provider.ID
+}
+
+func (c GenericClient) Name() string {
+	return c.provider.Name
+}
+
+func (c GenericClient) BuildFromURL(ctx context.Context, u string) (Pipeline, error) {
+	pipeline, _, err := c.BuildFromURLSince(ctx, u, SourceVersion{})
+	return pipeline, err
+}
+
+// Same as BuildFromURL but return ErrNotModified if the source was not modified since the
+// version 'since'. A file is compared to the time of the poll that returned 'since' whereas an
+// HTTP endpoint is asked to compare its content to the Last-Modified and ETag headers of the
+// response that returned 'since'.
+func (c GenericClient) BuildFromURLSince(ctx context.Context, u string, since SourceVersion) (Pipeline, SourceVersion, error) {
+	feed, version, err := c.feedSince(ctx, since)
+	if err != nil {
+		return Pipeline{}, version, err
+	}
+
+	for _, p := range feed.Pipelines {
+		if p.URL == u {
+			pipeline, err := p.toPipeline()
+			return pipeline, version, err
+		}
+	}
+
+	return Pipeline{}, version, ErrUnknownPipelineURL
+}
+
+// Return the web URL of every pipeline of the source associated to the commit designated by sha
+func (c GenericClient) PipelineURLs(ctx context.Context, sha string) ([]string, error) {
+	feed, err := c.feed(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	urls := make([]string, 0)
+	for _, p := range feed.Pipelines {
+		// Abbreviated commit hashes are accepted
+		if p.Sha != "" && p.URL != "" && strings.HasPrefix(sha, p.Sha) {
+			urls = append(urls, p.URL)
+		}
+	}
+
+	return urls, nil
+}
+
+func (c GenericClient) Log(ctx context.Context, step Step) (string, error) {
+	// Logs are included in the description of the pipeline and never fetched separately
+	return "", ErrNoLogHere
+}
+
+func (c GenericClient) feed(ctx context.Context) (genericFeed, error) {
+	feed, _, err := c.feedSince(ctx, SourceVersion{})
+	return feed, err
+}
+
+// Return the content of the source and its version, or ErrNotModified if it was not modified
+// since the version 'since'. A zero value for 'since' means the source is always read.
+func (c GenericClient) feedSince(ctx context.Context, since SourceVersion) (genericFeed, SourceVersion, error) {
+	var feed genericFeed
+	var version SourceVersion
+	var bs []byte
+	var err error
+
+	switch {
+	case c.sourceURL != nil:
+		bs, version, err = c.get(ctx, *c.sourceURL, since)
+	case c.source == "-":
+		if !since.IsZero() {
+			// The standard input is only read once so its content never changes
+			return feed, version, ErrNotModified
+		}
+		c.stdin.once.Do(func() {
+			c.stdin.content, c.stdin.err = ioutil.ReadAll(c.stdin.reader)
+		})
+		bs, err = c.stdin.content, c.stdin.err
+	default:
+		if !since.PolledAt.IsZero() {
+			if info, err := os.Stat(c.source); err == nil && info.ModTime().Before(since.PolledAt) {
+				return feed, version, ErrNotModified
+			}
+		}
+		bs, err = ioutil.ReadFile(c.source)
+	}
+	if err != nil {
+		return feed, version, err
+	}
+
+	if err := json.Unmarshal(bs, &feed); err != nil {
+		return feed, version, fmt.Errorf("invalid pipeline description in %q: %w", c.source, err)
+	}
+
+	return feed, version, nil
+}
+
+// Send a GET request to u and return the body and the validators of the response. Validators
+// of 'since' are sent along so that the server can answer "304 Not Modified", in which case
+// ErrNotModified is returned along with the validators of 'since'.
+func (c GenericClient) get(ctx context.Context, u url.URL, since SourceVersion) ([]byte, SourceVersion, error) {
+	req, err := http.NewRequest("GET", u.String(), nil)
+	if err != nil {
+		return nil, SourceVersion{}, err
+	}
+	req.Header.Add("Accept", "application/json")
+	if c.token != "" {
+		req.Header.Add("Authorization", fmt.Sprintf("Bearer %s", c.token))
+	}
+	if since.ETag != "" {
+		req.Header.Add("If-None-Match", since.ETag)
+	}
+	if since.LastModified != "" {
+		req.Header.Add("If-Modified-Since", since.LastModified)
+	}
+	req = req.WithContext(ctx)
+
+	select {
+	case <-c.rateLimiter:
+	case <-ctx.Done():
+		return nil, SourceVersion{}, ctx.Err()
+	}
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, SourceVersion{}, err
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, SourceVersion{}, err
+	}
+
+	version := SourceVersion{
+		LastModified: resp.Header.Get("Last-Modified"),
+		ETag:         resp.Header.Get("ETag"),
+	}
+	if resp.StatusCode == http.StatusNotModified {
+		// Servers may omit validators that did not change from a 304 response
+		if version.LastModified == "" {
+			version.LastModified = since.LastModified
+		}
+		if version.ETag == "" {
+			version.ETag = since.ETag
+		}
+		return nil, version, ErrNotModified
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, SourceVersion{}, HTTPError{
+			Method:     req.Method,
+			URL:        u.String(),
+			Status:     resp.StatusCode,
+			Message:    string(body),
+			RetryAfter: resp.Header.Get("Retry-After"),
+		}
+	}
+
+	return body, version, nil
+}
+
+type genericFeed struct {
+	Pipelines []genericPipeline `json:"pipelines"`
+}
+
+type genericStep struct {
+	ID           string        `json:"id"`
+	Name         string        `json:"name"`
+	Type         string        `json:"type"`
+	State        string        `json:"state"`
+	AllowFailure bool          `json:"allow_failure"`
+	CreatedAt    string        `json:"created_at"`
+	StartedAt    string        `json:"started_at"`
+	FinishedAt   string        `json:"finished_at"`
+	UpdatedAt    string        `json:"updated_at"`
+	URL          string        `json:"url"`
+	Log          *string       `json:"log"`
+	LogKey       string        `json:"log_key"`
+	Children     []genericStep `json:"children"`
+}
+
+type genericPipeline struct {
+	genericStep
+	Number string `json:"number"`
+	Sha    string `json:"sha"`
+	Ref    string `json:"ref"`
+	IsTag  bool   `json:"tag"`
+}
+
+func (p genericPipeline) toPipeline() (Pipeline, error) {
+	if p.URL == "" {
+		return Pipeline{}, errors.New("the url of a pipeline must not be empty")
+	}
+
+	step, err := p.genericStep.toStep(StepPipeline, utils.NullString{})
+	if err != nil {
+		return Pipeline{}, err
+	}
+	step.Type = StepPipeline
+	if step.ID == "" {
+		step.ID = p.Number
+	}
+	if step.ID == "" {
+		step.ID = p.URL
+	}
+
+	return Pipeline{
+		Number: p.Number,
+		Ref:    p.Ref,
+		IsTag:  p.IsTag,
+		Step:   step,
+	}, nil
+}
+
+func fromGenericType(s string, defaultType StepType) (StepType, error) {
+	switch s {
+	case "":
+		return defaultType, nil
+	case "stage":
+		return StepStage, nil
+	case "job":
+		return StepJob, nil
+	case "task":
+		return StepTask, nil
+	default:
+		return 0, fmt.Errorf("invalid step type: %q", s)
+	}
+}
+
+// States of the generic format are the ones displayed by cistern
+func fromGenericState(s string) State {
+	if _, exists := statePrecedence[State(s)]; exists {
+		return State(s)
+	}
+	return Unknown
+}
+
+func (s genericStep) toStep(defaultType StepType, parentURL utils.NullString) (Step, error) {
+	stepType, err := fromGenericType(s.Type, defaultType)
+	if err != nil {
+		return Step{}, err
+	}
+
+	step := Step{
+		ID:           s.ID,
+		Name:         s.Name,
+		Type:         stepType,
+		State:        fromGenericState(s.State),
+		AllowFailure: s.AllowFailure,
+		WebURL:       parentURL,
+	}
+	if s.URL != "" {
+		step.WebURL = utils.NullString{
+			Valid:  true,
+			String: s.URL,
+		}
+	}
+	step.Log.Key = s.LogKey
+	if s.Log != nil {
+		step.Log.Content = utils.NullString{
+			Valid:  true,
+			String: *s.Log,
+		}
+	}
+
+	if step.CreatedAt, err = utils.NullTimeFromString(s.CreatedAt); err != nil {
+		return Step{}, err
+	}
+	if step.StartedAt, err = utils.NullTimeFromString(s.StartedAt); err != nil {
+		return Step{}, err
+	}
+	if step.FinishedAt, err = utils.NullTimeFromString(s.FinishedAt); err != nil {
+		return Step{}, err
+	}
+	if step.UpdatedAt, err = utils.NullTimeFromString(s.UpdatedAt); err != nil {
+		return Step{}, err
+	}
+	if !step.UpdatedAt.Valid {
+		step.UpdatedAt = utils.MaxNullTime(step.CreatedAt, step.StartedAt, step.FinishedAt)
+	}
+	step.Duration = utils.NullSub(step.FinishedAt, step.StartedAt)
+
+	// Children of jobs are tasks and children of pipelines and stages default to jobs
+	var childType StepType = StepJob
+	if stepType == StepJob || stepType == StepTask {
+		childType = StepTask
+	}
+	for i, child := range s.Children {
+		childStep, err := child.toStep(childType, step.WebURL)
+		if err != nil {
+			return Step{}, err
+		}
+		if childStep.ID == "" {
+			childStep.ID = strconv.Itoa(i)
+		}
+		step.Children = append(step.Children, childStep)
+	}
+
+	return step, nil
+}
@@ -0,0 +1,269 @@
+package providers
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/nbedos/cistern/utils"
+)
+
+var genericTestFile = path.Join("test_data", "generic", "generic_pipelines.json")
+
+func setupGenericTestServer(t *testing.T) (GenericClient, func()) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer token" {
+			w.WriteHeader(401)
+			return
+		}
+		if r.URL.Path != "/pipelines" {
+			w.WriteHeader(404)
+			return
+		}
+
+		f, err := os.Open(genericTestFile)
+		if err != nil {
+			w.WriteHeader(500)
+			fmt.Fprint(w, err.Error())
+			return
+		}
+		defer f.Close()
+		info, err := f.Stat()
+		if err != nil {
+			w.WriteHeader(500)
+			fmt.Fprint(w, err.Error())
+			return
+		}
+		// ServeContent handles the If-Modified-Since header
+		http.ServeContent(w, r, "", info.ModTime(), f)
+	}))
+
+	sourceURL, err := url.Parse(ts.URL + "/pipelines")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	client := GenericClient{
+		source:      sourceURL.String(),
+		sourceURL:   sourceURL,
+		httpClient:  ts.Client(),
+		rateLimiter: time.Tick(time.Millisecond),
+		token:       "token",
+	}
+
+	return client, func() { ts.Close() }
+}
+
+func TestGenericClient_BuildFromURL(t *testing.T) {
+	client, teardown := setupGenericTestServer(t)
+	defer teardown()
+
+	pipeline, err := client.BuildFromURL(context.Background(), "https://ci.example.com/builds/42")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	at := func(minutes int, seconds int) utils.NullTime {
+		return utils.NullTime{
+			Valid: true,
+			Time:  time.Date(2020, 1, 30, 13, minutes, seconds, 0, time.UTC),
+		}
+	}
+	duration := func(d time.Duration) utils.NullDuration {
+		return utils.NullDuration{
+			Valid:    true,
+			Duration: d,
+		}
+	}
+	webURL := func(s string) utils.NullString {
+		return utils.NullString{
+			Valid:  true,
+			String: s,
+		}
+	}
+	content := func(s string) Log {
+		return Log{
+			Content: utils.NullString{
+				Valid:  true,
+				String: s,
+			},
+		}
+	}
+
+	expectedPipeline := Pipeline{
+		Number: "42",
+		Ref:    "master",
+		Step: Step{
+			ID:         "42",
+			Type:       StepPipeline,
+			State:      Failed,
+			CreatedAt:  at(0, 0),
+			StartedAt:  at(0, 5),
+			FinishedAt: at(1, 30),
+			UpdatedAt:  at(1, 30),
+			Duration:   duration(85 * time.Second),
+			WebURL:     webURL("https://ci.example.com/builds/42"),
+			Children: []Step{
+				{
+					ID:         "0",
+					Name:       "build",
+					Type:       StepJob,
+					State:      Passed,
+					StartedAt:  at(0, 5),
+					FinishedAt: at(0, 30),
+					UpdatedAt:  at(0, 30),
+					Duration:   duration(25 * time.Second),
+					WebURL:     webURL("https://ci.example.com/builds/42"),
+					Log:        content("$ make\nok\n"),
+				},
+				{
+					ID:       "test",
+					Name:     "test",
+					Type:     StepStage,
+					State:    Failed,
+					Duration: utils.NullSub(utils.NullTime{}, utils.NullTime{}),
+					WebURL:   webURL("https://ci.example.com/builds/42"),
+					Children: []Step{
+						{
+							ID:         "unit",
+							Name:       "unit tests",
+							Type:       StepJob,
+							State:      Failed,
+							StartedAt:  at(0, 30),
+							FinishedAt: at(1, 30),
+							UpdatedAt:  at(1, 30),
+							Duration:   duration(time.Minute),
+							WebURL:     webURL("https://ci.example.com/builds/42/unit"),
+							Children: []Step{
+								{
+									ID:       "0",
+									Name:     "go test",
+									Type:     StepTask,
+									State:    Failed,
+									Duration: utils.NullSub(utils.NullTime{}, utils.NullTime{}),
+									WebURL:   webURL("https://ci.example.com/builds/42/unit"),
+									Log:      content("$ go test ./...\nFAIL\n"),
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+	if diff := expectedPipeline.Diff(pipeline); len(diff) > 0 {
+		t.Fatal(diff)
+	}
+
+	t.Run("unknown url", func(t *testing.T) {
+		_, err := client.BuildFromURL(context.Background(), "https://ci.example.com/builds/44")
+		if err != ErrUnknownPipelineURL {
+			t.Fatalf("expected %v but got %v", ErrUnknownPipelineURL, err)
+		}
+	})
+}
+
+func TestGenericClient_PipelineURLs(t *testing.T) {
+	bs, err := ioutil.ReadFile(genericTestFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	clients := map[string]GenericClient{
+		"file": {
+			source: genericTestFile,
+		},
+		"stdin": {
+			source: "-",
+			stdin: &genericStdin{
+				once:   &sync.Once{},
+				reader: strings.NewReader(string(bs)),
+			},
+		},
+	}
+
+	for name, client := range clients {
+		t.Run(name, func(t *testing.T) {
+			// Reading twice makes sure the standard input is not consumed by the first call
+			for i := 0; i < 2; i++ {
+				urls, err := client.PipelineURLs(context.Background(), "a24840cfe1f6d9b4e1a8c5b2f0d3e7a6c9b8d4f1")
+				if err != nil {
+					t.Fatal(err)
+				}
+				if diff := cmp.Diff([]string{"https://ci.example.com/builds/42"}, urls); len(diff) > 0 {
+					t.Fatal(diff)
+				}
+			}
+		})
+	}
+}
+
+func TestGenericClient_BuildFromURLSince(t *testing.T) {
+	info, err := os.Stat(genericTestFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	u := "https://ci.example.com/builds/42"
+
+	t.Run("file", func(t *testing.T) {
+		client := GenericClient{source: genericTestFile}
+		since := SourceVersion{PolledAt: info.ModTime().Add(time.Hour)}
+		if _, _, err := client.BuildFromURLSince(context.Background(), u, since); err != ErrNotModified {
+			t.Fatalf("expected %v but got %v", ErrNotModified, err)
+		}
+
+		since = SourceVersion{PolledAt: info.ModTime().Add(-time.Hour)}
+		pipeline, _, err := client.BuildFromURLSince(context.Background(), u, since)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if pipeline.ID != "42" {
+			t.Fatalf("expected pipeline 42 but got %q", pipeline.ID)
+		}
+	})
+
+	t.Run("http", func(t *testing.T) {
+		client, teardown := setupGenericTestServer(t)
+		defer teardown()
+
+		pipeline, version, err := client.BuildFromURLSince(context.Background(), u, SourceVersion{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if pipeline.ID != "42" {
+			t.Fatalf("expected pipeline 42 but got %q", pipeline.ID)
+		}
+		// The validator of the server is sent back as is, whatever the time of the poll
+		expected := info.ModTime().UTC().Format(http.TimeFormat)
+		if version.LastModified != expected {
+			t.Fatalf("expected Last-Modified %q but got %q", expected, version.LastModified)
+		}
+
+		version.PolledAt = info.ModTime().Add(-time.Hour)
+		_, notModified, err := client.BuildFromURLSince(context.Background(), u, version)
+		if err != ErrNotModified {
+			t.Fatalf("expected %v but got %v", ErrNotModified, err)
+		}
+		if notModified.LastModified != expected {
+			t.Fatalf("expected Last-Modified %q but got %q", expected, notModified.LastModified)
+		}
+
+		older := SourceVersion{
+			PolledAt:     info.ModTime().Add(time.Hour),
+			LastModified: info.ModTime().Add(-time.Hour).UTC().Format(http.TimeFormat),
+		}
+		if _, _, err := client.BuildFromURLSince(context.Background(), u, older); err != nil {
+			t.Fatal(err)
+		}
+	})
+}
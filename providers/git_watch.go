@@ -0,0 +1,122 @@
+package providers
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Return the path of the git directory of the repository containing 'path'. The git directory
+// is usually the ".git" directory at the root of the repository, except for worktrees and
+// submodules where ".git" is a file referencing the actual git directory.
+func gitDirectory(path string) (string, error) {
+	dir, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+
+	for {
+		dotGit := filepath.Join(dir, ".git")
+		info, err := os.Stat(dotGit)
+		switch {
+		case err == nil && info.IsDir():
+			return dotGit, nil
+		case err == nil:
+			bs, err := ioutil.ReadFile(dotGit)
+			if err != nil {
+				return "", err
+			}
+			gitDir := strings.TrimSpace(strings.TrimPrefix(string(bs), "gitdir:"))
+			if !filepath.IsAbs(gitDir) {
+				gitDir = filepath.Join(dir, gitDir)
+			}
+			return gitDir, nil
+		case !os.IsNotExist(err):
+			return "", err
+		}
+
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", ErrUnknownRepositoryURL
+		}
+		dir = parent
+	}
+}
+
+// Return the path of the directory holding the references shared by all the worktrees of the
+// repository whose git directory is 'gitDir'. For linked worktrees this is the git directory
+// of the main worktree, referenced by the "commondir" file of 'gitDir'. Otherwise it is
+// 'gitDir' itself.
+func commonDirectory(gitDir string) (string, error) {
+	bs, err := ioutil.ReadFile(filepath.Join(gitDir, "commondir"))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return gitDir, nil
+		}
+		return "", err
+	}
+
+	commonDir := strings.TrimSpace(string(bs))
+	if !filepath.IsAbs(commonDir) {
+		commonDir = filepath.Join(gitDir, commonDir)
+	}
+	return filepath.Clean(commonDir), nil
+}
+
+// Return true if the file 'name', a path relative to 'base', must be watched. HEAD is specific
+// to each worktree and read from the git directory 'gitDir' whereas branches are shared by all
+// worktrees and read from 'commonDir'.
+func isWatchedFile(gitDir string, commonDir string, base string, name string) bool {
+	if filepath.ToSlash(name) == "HEAD" {
+		return base == gitDir
+	}
+	return base == commonDir && isWatchedReference(name)
+}
+
+// Return true if 'name', a path relative to the git directory, is a file whose modification
+// may change the commit referenced by HEAD or a remote branch
+func isWatchedReference(name string) bool {
+	name = filepath.ToSlash(name)
+	if strings.HasSuffix(name, ".lock") {
+		return false
+	}
+	return name == "HEAD" ||
+		name == "packed-refs" ||
+		strings.HasPrefix(name, "refs/heads/") ||
+		strings.HasPrefix(name, "refs/remotes/")
+}
+
+// Send the name of the reference every time HEAD, a local branch or a remote branch is
+// modified in the repository containing 'path', until ctx is canceled. Names are relative
+// to the git directory (e.g. "HEAD" or "refs/remotes/origin/master").
+func WatchReferences(ctx context.Context, path string, changes chan<- string) error {
+	gitDir, err := gitDirectory(path)
+	if err != nil {
+		return err
+	}
+	commonDir, err := commonDirectory(gitDir)
+	if err != nil {
+		return err
+	}
+
+	return watchGitDirectory(ctx, gitDir, commonDir, changes)
+}
+
+// Return the name of a remote branch given the name of the file storing it in the git
+// directory, or the empty string if the file does not hold a remote branch
+func RemoteBranch(name string) string {
+	name = filepath.ToSlash(name)
+	if !strings.HasPrefix(name, "refs/remotes/") || strings.HasSuffix(name, "/HEAD") {
+		return ""
+	}
+	return strings.TrimPrefix(name, "refs/remotes/")
+}
+
+func notify(ctx context.Context, changes chan<- string, name string) {
+	select {
+	case changes <- filepath.ToSlash(name):
+	case <-ctx.Done():
+	}
+}
@@ -0,0 +1,123 @@
+// +build linux
+
+package providers
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"sort"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+const inotifyMask = unix.IN_CREATE | unix.IN_MODIFY | unix.IN_MOVED_TO | unix.IN_DELETE | unix.IN_DELETE_SELF
+
+// Watch HEAD in the git directory and the branches of the common directory with inotify
+func watchGitDirectory(ctx context.Context, gitDir string, commonDir string, changes chan<- string) error {
+	fd, err := unix.InotifyInit1(unix.IN_CLOEXEC | unix.IN_NONBLOCK)
+	if err != nil {
+		return err
+	}
+	// The file descriptor is non-blocking so the file is handled by the runtime poller and
+	// closing it interrupts pending reads
+	f := os.NewFile(uintptr(fd), "inotify")
+	defer f.Close()
+
+	// Directory watched and directory the names of its files are relative to, either gitDir
+	// or commonDir
+	type watchedDir struct {
+		path string
+		base string
+	}
+	dirByWatch := make(map[int]watchedDir)
+	addWatch := func(dir string, base string) error {
+		wd, err := unix.InotifyAddWatch(fd, dir, inotifyMask)
+		if err != nil {
+			return err
+		}
+		dirByWatch[wd] = watchedDir{path: dir, base: base}
+		return nil
+	}
+
+	// Branch names containing a slash are stored in sub-directories of "refs/heads" and
+	// "refs/remotes/<remote>" so the creation of directories must be watched too
+	if err := addWatch(gitDir, gitDir); err != nil {
+		return err
+	}
+	for _, dir := range []string{commonDir, filepath.Join(commonDir, "refs")} {
+		if err := addWatch(dir, commonDir); err != nil {
+			return err
+		}
+	}
+	for _, dir := range []string{"heads", "remotes"} {
+		err = filepath.Walk(filepath.Join(commonDir, "refs", dir), func(p string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			if info.IsDir() {
+				return addWatch(p, commonDir)
+			}
+			return nil
+		})
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+
+	go func() {
+		<-ctx.Done()
+		f.Close()
+	}()
+
+	buf := make([]byte, 64*(unix.SizeofInotifyEvent+unix.NAME_MAX+1))
+	for {
+		n, err := f.Read(buf)
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			return err
+		}
+
+		changed := make([]string, 0)
+		for offset := 0; offset+unix.SizeofInotifyEvent <= n; {
+			event := (*unix.InotifyEvent)(unsafe.Pointer(&buf[offset]))
+			nameBytes := buf[offset+unix.SizeofInotifyEvent : offset+unix.SizeofInotifyEvent+int(event.Len)]
+			offset += unix.SizeofInotifyEvent + int(event.Len)
+
+			dir, exists := dirByWatch[int(event.Wd)]
+			if !exists {
+				continue
+			}
+			if event.Mask&unix.IN_IGNORED != 0 {
+				delete(dirByWatch, int(event.Wd))
+				continue
+			}
+			p := filepath.Join(dir.path, string(bytes.TrimRight(nameBytes, "\x00")))
+			name, err := filepath.Rel(dir.base, p)
+			if err != nil {
+				continue
+			}
+			isDir := event.Mask&unix.IN_ISDIR != 0
+			if isDir && event.Mask&(unix.IN_CREATE|unix.IN_MOVED_TO) != 0 && dir.base == commonDir && isWatchedReference(name+"/") {
+				if err := addWatch(p, commonDir); err != nil && !os.IsNotExist(err) {
+					return err
+				}
+			}
+			if !isDir && isWatchedFile(gitDir, commonDir, dir.base, name) {
+				changed = append(changed, name)
+			}
+		}
+
+		// Git usually writes the same reference several times in a row
+		sort.Strings(changed)
+		for i, name := range changed {
+			if i == 0 || changed[i-1] != name {
+				notify(ctx, changes, name)
+			}
+		}
+	}
+}
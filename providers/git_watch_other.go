@@ -0,0 +1,68 @@
+// +build !linux
+
+package providers
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+const gitPollingInterval = 2 * time.Second
+
+// Poll the modification time of HEAD in the git directory and of the branches of the common
+// directory on platforms where inotify is not available
+func watchGitDirectory(ctx context.Context, gitDir string, commonDir string, changes chan<- string) error {
+	modificationTimes := func() map[string]time.Time {
+		times := make(map[string]time.Time)
+		if info, err := os.Stat(filepath.Join(gitDir, "HEAD")); err == nil {
+			times["HEAD"] = info.ModTime()
+		}
+		if info, err := os.Stat(filepath.Join(commonDir, "packed-refs")); err == nil {
+			times["packed-refs"] = info.ModTime()
+		}
+		for _, dir := range []string{"heads", "remotes"} {
+			// Errors are ignored since references may be deleted while walking the directory
+			_ = filepath.Walk(filepath.Join(commonDir, "refs", dir), func(p string, info os.FileInfo, err error) error {
+				if err != nil || info.IsDir() {
+					return nil
+				}
+				if name, err := filepath.Rel(commonDir, p); err == nil && isWatchedReference(name) {
+					times[name] = info.ModTime()
+				}
+				return nil
+			})
+		}
+		return times
+	}
+
+	ticker := time.NewTicker(gitPollingInterval)
+	defer ticker.Stop()
+	previous := modificationTimes()
+	for {
+		select {
+		case <-ticker.C:
+			current := modificationTimes()
+			changed := make([]string, 0)
+			for name, t := range current {
+				if p, exists := previous[name]; !exists || !p.Equal(t) {
+					changed = append(changed, name)
+				}
+			}
+			for name := range previous {
+				if _, exists := current[name]; !exists {
+					changed = append(changed, name)
+				}
+			}
+			sort.Strings(changed)
+			for _, name := range changed {
+				notify(ctx, changes, name)
+			}
+			previous = current
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
@@ -0,0 +1,214 @@
+package providers
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+	"time"
+)
+
+func TestGitDirectory(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	gitDir := path.Join(dir, "repository", ".git")
+	subDir := path.Join(dir, "repository", "a", "b")
+	worktree := path.Join(dir, "worktree")
+	for _, d := range []string{gitDir, subDir, worktree} {
+		if err := os.MkdirAll(d, 0700); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := ioutil.WriteFile(path.Join(worktree, ".git"), []byte("gitdir: ../repository/.git\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, p := range []string{subDir, worktree} {
+		t.Run(p, func(t *testing.T) {
+			d, err := gitDirectory(p)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if d != gitDir {
+				t.Fatalf("expected %q but got %q", gitDir, d)
+			}
+		})
+	}
+}
+
+func TestIsWatchedReference(t *testing.T) {
+	testCases := map[string]bool{
+		"HEAD":                    true,
+		"HEAD.lock":               false,
+		"packed-refs":             true,
+		"refs/heads/master":       true,
+		"refs/heads/feature/x":    true,
+		"refs/heads/master.lock":  false,
+		"refs/tags/0.1.0":         false,
+		"refs/remotes/origin/dev": true,
+		"index":                   false,
+	}
+
+	for name, expected := range testCases {
+		if isWatchedReference(name) != expected {
+			t.Errorf("expected isWatchedReference(%q) to be %v", name, expected)
+		}
+	}
+}
+
+func TestRemoteBranch(t *testing.T) {
+	testCases := map[string]string{
+		"refs/remotes/origin/feature/x": "origin/feature/x",
+		"refs/remotes/origin/HEAD":      "",
+		"refs/heads/master":             "",
+		"HEAD":                          "",
+	}
+
+	for name, expected := range testCases {
+		if branch := RemoteBranch(name); branch != expected {
+			t.Errorf("expected RemoteBranch(%q) to be %q but got %q", name, expected, branch)
+		}
+	}
+}
+
+func TestCommonDirectory(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	gitDir := path.Join(dir, ".git")
+	worktreeGitDir := path.Join(gitDir, "worktrees", "feature")
+	if err := os.MkdirAll(worktreeGitDir, 0700); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(path.Join(worktreeGitDir, "commondir"), []byte("../..\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	for p, expected := range map[string]string{gitDir: gitDir, worktreeGitDir: gitDir} {
+		t.Run(p, func(t *testing.T) {
+			d, err := commonDirectory(p)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if d != expected {
+				t.Fatalf("expected %q but got %q", expected, d)
+			}
+		})
+	}
+}
+
+// Write the file 'p' until WatchReferences reports a change of the reference 'expected'.
+// Changes of other references are ignored since they may have been caused by the writes of a
+// previous call.
+func waitForChange(t *testing.T, p string, expected string, changes <-chan string, errc <-chan error) {
+	// Writing files until a change is detected avoids racing with the setup of the watcher
+	ticker := time.NewTicker(100 * time.Millisecond)
+	defer ticker.Stop()
+	timeout := time.After(10 * time.Second)
+	for i := 0; ; i++ {
+		select {
+		case <-ticker.C:
+			// Modification times of the polling implementation may have a resolution of one second
+			modTime := time.Now().Add(time.Duration(i) * time.Second)
+			if err := ioutil.WriteFile(p, []byte("0123456789abcdef\n"), 0600); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.Chtimes(p, modTime, modTime); err != nil {
+				t.Fatal(err)
+			}
+		case name := <-changes:
+			if name == expected {
+				return
+			}
+		case err := <-errc:
+			t.Fatal(err)
+		case <-timeout:
+			t.Fatal("no change detected")
+		}
+	}
+}
+
+func TestWatchReferences(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	gitDir := path.Join(dir, ".git")
+	if err := os.MkdirAll(path.Join(gitDir, "refs", "heads"), 0700); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(path.Join(gitDir, "HEAD"), []byte("ref: refs/heads/master\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	changes := make(chan string)
+	errc := make(chan error)
+	go func() {
+		errc <- WatchReferences(ctx, dir, changes)
+	}()
+
+	waitForChange(t, path.Join(gitDir, "refs", "heads", "master"), "refs/heads/master", changes, errc)
+
+	cancel()
+	if err := <-errc; err != context.Canceled {
+		t.Fatalf("expected %v but got %v", context.Canceled, err)
+	}
+}
+
+func TestWatchReferences_worktree(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// Linked worktrees have a git directory of their own holding HEAD while branches are
+	// stored in the git directory of the main worktree
+	gitDir := path.Join(dir, "repository", ".git")
+	worktreeGitDir := path.Join(gitDir, "worktrees", "feature")
+	worktree := path.Join(dir, "feature")
+	for _, d := range []string{path.Join(gitDir, "refs", "heads"), worktreeGitDir, worktree} {
+		if err := os.MkdirAll(d, 0700); err != nil {
+			t.Fatal(err)
+		}
+	}
+	files := map[string]string{
+		path.Join(gitDir, "HEAD"):              "ref: refs/heads/master\n",
+		path.Join(worktreeGitDir, "HEAD"):      "ref: refs/heads/feature\n",
+		path.Join(worktreeGitDir, "commondir"): "../..\n",
+		path.Join(worktree, ".git"):            "gitdir: " + worktreeGitDir + "\n",
+	}
+	for p, content := range files {
+		if err := ioutil.WriteFile(p, []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	changes := make(chan string)
+	errc := make(chan error)
+	go func() {
+		errc <- WatchReferences(ctx, worktree, changes)
+	}()
+
+	waitForChange(t, path.Join(gitDir, "refs", "heads", "feature"), "refs/heads/feature", changes, errc)
+	waitForChange(t, path.Join(worktreeGitDir, "HEAD"), "HEAD", changes, errc)
+
+	cancel()
+	if err := <-errc; err != context.Canceled {
+		t.Fatalf("expected %v but got %v", context.Canceled, err)
+	}
+}
@@ -0,0 +1,543 @@
+package providers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/nbedos/cistern/utils"
+)
+
+// Number of items requested per page of a paginated endpoint of the Gitea API
+const giteaPageSize = 50
+
+// Maximum number of pages of workflow runs read while looking for a run by its number
+const giteaMaxRunPages = 10
+
+// GiteaClient is both a source provider, reading commits and commit statuses of repositories
+// hosted on a Gitea instance, and a CI provider for Gitea Actions, the CI service built into
+// Gitea.
+type GiteaClient struct {
+	baseURL     url.URL
+	sshHostname string
+	httpClient  *http.Client
+	rateLimiter <-chan time.Time
+	token       string
+	provider    Provider
+	// Requests sent through httpClient
+	requests *requestCounter
+}
+
+func NewGiteaClient(id string, name string, token string, URL string, requestsPerSecond float64, sshHostname string) (GiteaClient, error) {
+	if URL == "" {
+		return GiteaClient{}, errors.New("the url of a gitea instance must not be empty")
+	}
+	u, err := url.Parse(URL)
+	if err != nil {
+		return GiteaClient{}, err
+	}
+	u.Path = strings.TrimSuffix(u.Path, "/")
+
+	rateLimit := time.Second / 10
+	if requestsPerSecond > 0 {
+		rateLimit = time.Second / time.Duration(requestsPerSecond)
+	}
+
+	httpClient, requests := countedClient(nil)
+
+	return GiteaClient{
+		baseURL:     *u,
+		sshHostname: sshHostname,
+		httpClient:  httpClient,
+		requests:    requests,
+		rateLimiter: time.Tick(rateLimit),
+		token:       token,
+		provider: Provider{
+			ID:   id,
+			Name: name,
+		},
+	}, nil
+}
+
+func (c GiteaClient) RequestStats() RequestStats {
+	return c.requests.snapshot()
+}
+
+func (c GiteaClient) Authenticated() bool {
+	return c.token != ""
+}
+
+func (c GiteaClient) ID() string {
+	return c.provider.ID
+}
+
+func (c GiteaClient) Host() string {
+	return c.baseURL.Host
+}
+
+func (c GiteaClient) Name() string {
+	return c.provider.Name
+}
+
+func (c GiteaClient) HostsRepository(u string) bool {
+	_, _, err := c.parseRepositoryURL(u)
+	return err == nil
+}
+
+func (c GiteaClient) parseRepositoryURL(u string) (string, string, error) {
+	hostname, slug, err := utils.RepositoryHostAndSlug(u)
+	if err != nil || (hostname != c.baseURL.Hostname() && hostname != c.sshHostname) {
+		return "", "", ErrUnknownRepositoryURL
+	}
+
+	// Gitea may be served from a subpath of its host
+	slug = strings.TrimPrefix(slug, strings.TrimPrefix(c.baseURL.Path, "/")+"/")
+	components := strings.FieldsFunc(slug, func(c rune) bool { return c == '/' })
+	if len(components) != 2 {
+		return "", "", fmt.Errorf("invalid repository path: %q (expected two components)", slug)
+	}
+
+	return components[0], components[1], nil
+}
+
+func (c GiteaClient) endpoint(format string, a ...interface{}) url.URL {
+	endpoint := c.baseURL
+	endpoint.Path += fmt.Sprintf(format, a...)
+
+	return endpoint
+}
+
+// Return the endpoint of the page 'page' of the paginated resource 'u'
+func giteaPage(u url.URL, page int) url.URL {
+	params := u.Query()
+	params.Set("page", strconv.Itoa(page))
+	params.Set("limit", strconv.Itoa(giteaPageSize))
+	u.RawQuery = params.Encode()
+
+	return u
+}
+
+type giteaUser struct {
+	Name  string    `json:"name"`
+	Email string    `json:"email"`
+	Date  time.Time `json:"date"`
+}
+
+type giteaCommit struct {
+	Sha    string `json:"sha"`
+	Commit struct {
+		Message   string    `json:"message"`
+		Author    giteaUser `json:"author"`
+		Committer giteaUser `json:"committer"`
+	} `json:"commit"`
+}
+
+func (c GiteaClient) Commit(ctx context.Context, repo string, ref string) (Commit, error) {
+	owner, repo, err := c.parseRepositoryURL(repo)
+	if err != nil {
+		return Commit{}, ErrUnknownRepositoryURL
+	}
+
+	var giteaCommit giteaCommit
+	endpoint := c.endpoint("/api/v1/repos/%s/%s/git/commits/%s", owner, repo, url.PathEscape(ref))
+	if err := c.getJSON(ctx, endpoint, &giteaCommit); err != nil {
+		if e, ok := err.(HTTPError); ok && (e.Status == 404 || e.Status == 422) {
+			return Commit{}, ErrUnknownGitReference
+		}
+		return Commit{}, err
+	}
+
+	author := giteaCommit.Commit.Author
+	committer := giteaCommit.Commit.Committer
+	commit := Commit{
+		Sha:       giteaCommit.Sha,
+		Author:    fmt.Sprintf("%s <%s>", author.Name, author.Email),
+		Committer: fmt.Sprintf("%s <%s>", committer.Name, committer.Email),
+		Date:      author.Date,
+		Message:   giteaCommit.Commit.Message,
+	}
+
+	for page := 1; ; page++ {
+		var branches []struct {
+			Name   string `json:"name"`
+			Commit struct {
+				ID string `json:"id"`
+			} `json:"commit"`
+		}
+		endpoint := giteaPage(c.endpoint("/api/v1/repos/%s/%s/branches", owner, repo), page)
+		if err := c.getJSON(ctx, endpoint, &branches); err != nil {
+			return Commit{}, err
+		}
+		for _, branch := range branches {
+			if branch.Commit.ID == commit.Sha {
+				commit.Branches = append(commit.Branches, branch.Name)
+			}
+		}
+		if len(branches) < giteaPageSize {
+			break
+		}
+	}
+
+	for page := 1; ; page++ {
+		var tags []struct {
+			Name   string `json:"name"`
+			Commit struct {
+				Sha string `json:"sha"`
+			} `json:"commit"`
+		}
+		endpoint := giteaPage(c.endpoint("/api/v1/repos/%s/%s/tags", owner, repo), page)
+		if err := c.getJSON(ctx, endpoint, &tags); err != nil {
+			return Commit{}, err
+		}
+		for _, tag := range tags {
+			if tag.Commit.Sha == commit.Sha {
+				commit.Tags = append(commit.Tags, tag.Name)
+			}
+		}
+		if len(tags) < giteaPageSize {
+			break
+		}
+	}
+
+	return commit, nil
+}
+
+// Return the target URLs of the statuses of the commit designated by sha, or by ref if sha is
+// empty. Statuses are set by Gitea Actions as well as by external CI servers such as
+// Woodpecker CI.
+func (c GiteaClient) RefStatuses(ctx context.Context, u string, ref string, sha string) ([]string, error) {
+	owner, repo, err := c.parseRepositoryURL(u)
+	if err != nil {
+		return nil, err
+	}
+	if sha != "" {
+		ref = sha
+	}
+
+	urls := make([]string, 0)
+	seen := make(map[string]struct{})
+	for page := 1; ; page++ {
+		var statuses []struct {
+			TargetURL string `json:"target_url"`
+		}
+		endpoint := giteaPage(c.endpoint("/api/v1/repos/%s/%s/commits/%s/statuses", owner, repo, url.PathEscape(ref)), page)
+		if err := c.getJSON(ctx, endpoint, &statuses); err != nil {
+			if e, ok := err.(HTTPError); ok && e.Status == 404 {
+				return nil, ErrUnknownRepositoryURL
+			}
+			return nil, err
+		}
+		for _, status := range statuses {
+			if _, exists := seen[status.TargetURL]; status.TargetURL != "" && !exists {
+				seen[status.TargetURL] = struct{}{}
+				urls = append(urls, status.TargetURL)
+			}
+		}
+		if len(statuses) < giteaPageSize {
+			break
+		}
+	}
+
+	return urls, nil
+}
+
+func (c GiteaClient) BuildFromURL(ctx context.Context, u string) (Pipeline, error) {
+	owner, repo, number, err := parseGiteaActionsURL(&c.baseURL, u)
+	if err != nil {
+		return Pipeline{}, err
+	}
+
+	return c.fetchPipeline(ctx, owner, repo, number)
+}
+
+// Extract owner, repository and run number from the web URL of a Gitea Actions run or job
+func parseGiteaActionsURL(baseURL *url.URL, u string) (string, string, int, error) {
+	v, err := url.Parse(u)
+	if err != nil {
+		return "", "", 0, err
+	}
+
+	if v.Hostname() != baseURL.Hostname() {
+		return "", "", 0, ErrUnknownPipelineURL
+	}
+
+	// url format: https://gitea.example.com/nbedos/cistern/actions/runs/42
+	//         OR  https://gitea.example.com/nbedos/cistern/actions/runs/42/jobs/0
+	cs := strings.Split(strings.TrimPrefix(v.Path, baseURL.Path), "/")
+	if len(cs) < 6 || cs[3] != "actions" || cs[4] != "runs" {
+		return "", "", 0, ErrUnknownPipelineURL
+	}
+
+	owner, repo := cs[1], cs[2]
+	number, err := strconv.Atoi(cs[5])
+	if err != nil {
+		return "", "", 0, err
+	}
+
+	return owner, repo, number, nil
+}
+
+type giteaRun struct {
+	ID          int       `json:"id"`
+	RunNumber   int       `json:"run_number"`
+	Event       string    `json:"event"`
+	Status      string    `json:"status"`
+	Conclusion  string    `json:"conclusion"`
+	HeadBranch  string    `json:"head_branch"`
+	HTMLURL     string    `json:"html_url"`
+	StartedAt   time.Time `json:"started_at"`
+	CompletedAt time.Time `json:"completed_at"`
+}
+
+type giteaJob struct {
+	ID          int             `json:"id"`
+	Name        string          `json:"name"`
+	Status      string          `json:"status"`
+	Conclusion  string          `json:"conclusion"`
+	HTMLURL     string          `json:"html_url"`
+	CreatedAt   time.Time       `json:"created_at"`
+	StartedAt   time.Time       `json:"started_at"`
+	CompletedAt time.Time       `json:"completed_at"`
+	Steps       []giteaStepInfo `json:"steps"`
+}
+
+type giteaStepInfo struct {
+	Name        string    `json:"name"`
+	Number      int       `json:"number"`
+	Status      string    `json:"status"`
+	Conclusion  string    `json:"conclusion"`
+	StartedAt   time.Time `json:"started_at"`
+	CompletedAt time.Time `json:"completed_at"`
+}
+
+// Web URLs of Gitea Actions designate runs by their number but the API designates them by
+// their ID, so the run is looked up among the most recent runs of the repository.
+func (c GiteaClient) findRun(ctx context.Context, owner string, repo string, number int) (giteaRun, error) {
+	for page := 1; page <= giteaMaxRunPages; page++ {
+		var runs struct {
+			WorkflowRuns []giteaRun `json:"workflow_runs"`
+		}
+		endpoint := giteaPage(c.endpoint("/api/v1/repos/%s/%s/actions/runs", owner, repo), page)
+		if err := c.getJSON(ctx, endpoint, &runs); err != nil {
+			return giteaRun{}, err
+		}
+		for _, run := range runs.WorkflowRuns {
+			if run.RunNumber == number {
+				return run, nil
+			}
+		}
+		if len(runs.WorkflowRuns) < giteaPageSize {
+			break
+		}
+	}
+
+	return giteaRun{}, fmt.Errorf("no run numbered %d among the recent runs of %s/%s", number, owner, repo)
+}
+
+func (c GiteaClient) fetchPipeline(ctx context.Context, owner string, repo string, number int) (Pipeline, error) {
+	run, err := c.findRun(ctx, owner, repo, number)
+	if err != nil {
+		return Pipeline{}, err
+	}
+
+	var jobs struct {
+		Jobs []giteaJob `json:"jobs"`
+	}
+	endpoint := c.endpoint("/api/v1/repos/%s/%s/actions/runs/%d/jobs", owner, repo, run.ID)
+	if err := c.getJSON(ctx, endpoint, &jobs); err != nil {
+		return Pipeline{}, err
+	}
+
+	webURL := run.HTMLURL
+	if webURL == "" {
+		u := c.endpoint("/%s/%s/actions/runs/%d", owner, repo, number)
+		webURL = u.String()
+	}
+	pipeline := Pipeline{
+		Number: strconv.Itoa(run.RunNumber),
+		Ref:    run.HeadBranch,
+		Step: Step{
+			ID:         strconv.Itoa(run.ID),
+			Type:       StepPipeline,
+			State:      fromGiteaState(run.Status, run.Conclusion),
+			StartedAt:  giteaTime(run.StartedAt),
+			FinishedAt: giteaTime(run.CompletedAt),
+			WebURL: utils.NullString{
+				Valid:  true,
+				String: webURL,
+			},
+		},
+	}
+	pipeline.CreatedAt = pipeline.StartedAt
+
+	for _, job := range jobs.Jobs {
+		logURL := c.endpoint("/api/v1/repos/%s/%s/actions/jobs/%d/logs", owner, repo, job.ID)
+		pipeline.Children = append(pipeline.Children, job.toStep(pipeline, logURL))
+		pipeline.CreatedAt = utils.MinNullTime(pipeline.CreatedAt, giteaTime(job.CreatedAt))
+	}
+	pipeline.UpdatedAt = utils.MaxNullTime(pipeline.CreatedAt, pipeline.StartedAt, pipeline.FinishedAt)
+	for _, job := range pipeline.Children {
+		pipeline.UpdatedAt = utils.MaxNullTime(pipeline.UpdatedAt, job.StartedAt, job.FinishedAt)
+	}
+	pipeline.Duration = utils.NullSub(pipeline.FinishedAt, pipeline.StartedAt)
+
+	return pipeline, nil
+}
+
+func (j giteaJob) toStep(pipeline Pipeline, logURL url.URL) Step {
+	webURL := pipeline.WebURL
+	if j.HTMLURL != "" {
+		webURL = utils.NullString{Valid: true, String: j.HTMLURL}
+	}
+	step := Step{
+		ID:         strconv.Itoa(j.ID),
+		Name:       j.Name,
+		Type:       StepJob,
+		State:      fromGiteaState(j.Status, j.Conclusion),
+		CreatedAt:  giteaTime(j.CreatedAt),
+		StartedAt:  giteaTime(j.StartedAt),
+		FinishedAt: giteaTime(j.CompletedAt),
+		WebURL:     webURL,
+		Log: Log{
+			Key: logURL.String(),
+		},
+	}
+	step.Duration = utils.NullSub(step.FinishedAt, step.StartedAt)
+
+	for _, s := range j.Steps {
+		task := Step{
+			ID:         strconv.Itoa(s.Number),
+			Name:       s.Name,
+			Type:       StepTask,
+			State:      fromGiteaState(s.Status, s.Conclusion),
+			CreatedAt:  step.CreatedAt,
+			StartedAt:  giteaTime(s.StartedAt),
+			FinishedAt: giteaTime(s.CompletedAt),
+			WebURL:     webURL,
+		}
+		task.Duration = utils.NullSub(task.FinishedAt, task.StartedAt)
+		step.Children = append(step.Children, task)
+	}
+
+	return step
+}
+
+func (c GiteaClient) Log(ctx context.Context, step Step) (string, error) {
+	if step.Log.Key == "" {
+		return "", ErrNoLogHere
+	}
+
+	u, err := url.Parse(step.Log.Key)
+	if err != nil {
+		return "", err
+	}
+	r, err := c.get(ctx, *u, "text/plain")
+	if err != nil {
+		return "", err
+	}
+	defer r.Close()
+
+	bs, err := ioutil.ReadAll(r)
+	if err != nil {
+		return "", err
+	}
+
+	return string(bs), nil
+}
+
+func (c GiteaClient) getJSON(ctx context.Context, u url.URL, v interface{}) error {
+	r, err := c.get(ctx, u, "application/json")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if errClose := r.Close(); err == nil {
+			err = errClose
+		}
+	}()
+
+	err = json.NewDecoder(r).Decode(v)
+	return err
+}
+
+func (c GiteaClient) get(ctx context.Context, u url.URL, accept string) (io.ReadCloser, error) {
+	req, err := http.NewRequest("GET", u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Add("Accept", accept)
+	if c.token != "" {
+		req.Header.Add("Authorization", fmt.Sprintf("token %s", c.token))
+	}
+	req = req.WithContext(ctx)
+
+	select {
+	case <-c.rateLimiter:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		message, err := ioutil.ReadAll(resp.Body)
+		if err != nil {
+			message = nil
+		}
+		resp.Body.Close()
+		return nil, HTTPError{
+			Method:     req.Method,
+			URL:        u.String(),
+			Status:     resp.StatusCode,
+			Message:    string(message),
+			RetryAfter: resp.Header.Get("Retry-After"),
+		}
+	}
+
+	return resp.Body, nil
+}
+
+// Gitea reports the state of runs, jobs and steps either with its own status names or, in
+// the endpoints compatible with GitHub, with a status completed by a conclusion
+func fromGiteaState(status string, conclusion string) State {
+	if strings.ToLower(status) == "completed" {
+		status = conclusion
+	}
+	switch strings.ToLower(status) {
+	case "queued", "waiting", "pending", "blocked":
+		return Pending
+	case "in_progress", "running":
+		return Running
+	case "success":
+		return Passed
+	case "failure":
+		return Failed
+	case "cancelled":
+		return Canceled
+	case "skipped":
+		return Skipped
+	default:
+		return Unknown
+	}
+}
+
+// Gitea dates are RFC 3339 timestamps where the zero time stands for a missing value
+func giteaTime(t time.Time) utils.NullTime {
+	if t.IsZero() {
+		return utils.NullTime{}
+	}
+	return utils.NullTime{
+		Valid: true,
+		Time:  t.UTC(),
+	}
+}
@@ -0,0 +1,285 @@
+package providers
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/nbedos/cistern/utils"
+)
+
+const giteaTestSha = "a1b2c3d4e5f60718293a4b5c6d7e8f9012345678"
+
+func setupGiteaTestServer(t *testing.T) (GiteaClient, *url.URL, func()) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		filename := ""
+		switch r.URL.Path {
+		case "/api/v1/repos/nbedos/cistern/git/commits/master":
+			filename = "gitea_commit.json"
+		case "/api/v1/repos/nbedos/cistern/branches":
+			filename = "gitea_branches.json"
+		case "/api/v1/repos/nbedos/cistern/tags":
+			filename = "gitea_tags.json"
+		case "/api/v1/repos/nbedos/cistern/commits/" + giteaTestSha + "/statuses":
+			filename = "gitea_statuses.json"
+		case "/api/v1/repos/nbedos/cistern/actions/runs":
+			filename = "gitea_runs.json"
+		case "/api/v1/repos/nbedos/cistern/actions/runs/1042/jobs":
+			filename = "gitea_jobs.json"
+		case "/api/v1/repos/nbedos/cistern/actions/jobs/7/logs":
+			filename = "gitea_log.txt"
+		default:
+			w.WriteHeader(404)
+			return
+		}
+
+		bs, err := ioutil.ReadFile(path.Join("test_data", "gitea", filename))
+		if err != nil {
+			w.WriteHeader(500)
+			fmt.Fprint(w, err.Error())
+			return
+		}
+		if _, err := fmt.Fprint(w, string(bs)); err != nil {
+			w.WriteHeader(500)
+			fmt.Fprint(w, err.Error())
+			return
+		}
+	}))
+
+	testURL, err := url.Parse(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	client := GiteaClient{
+		baseURL:     *testURL,
+		sshHostname: "ssh.gitea.example.com",
+		httpClient:  ts.Client(),
+		rateLimiter: time.Tick(time.Millisecond),
+	}
+
+	return client, testURL, func() { ts.Close() }
+}
+
+func TestGiteaClient_HostsRepository(t *testing.T) {
+	client := GiteaClient{
+		baseURL:     url.URL{Scheme: "https", Host: "gitea.example.com"},
+		sshHostname: "ssh.gitea.example.com",
+	}
+
+	for u, expected := range map[string]bool{
+		"https://gitea.example.com/nbedos/cistern.git": true,
+		"git@gitea.example.com:nbedos/cistern.git":     true,
+		"git@ssh.gitea.example.com:nbedos/cistern.git": true,
+		"https://github.com/nbedos/cistern.git":        false,
+		"https://gitea.example.com/nbedos":             false,
+	} {
+		t.Run(u, func(t *testing.T) {
+			if hosted := client.HostsRepository(u); hosted != expected {
+				t.Fatalf("expected %v but got %v", expected, hosted)
+			}
+		})
+	}
+}
+
+func TestGiteaClient_Commit(t *testing.T) {
+	client, testURL, teardown := setupGiteaTestServer(t)
+	defer teardown()
+
+	commit, err := client.Commit(context.Background(), testURL.String()+"/nbedos/cistern.git", "master")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := Commit{
+		Sha:       giteaTestSha,
+		Author:    "nbedos <nbedos@example.com>",
+		Committer: "nbedos <nbedos@example.com>",
+		Date:      time.Date(2020, 1, 30, 13, 0, 0, 0, time.UTC),
+		Message:   "Add Gitea provider\n",
+		Branches:  []string{"master"},
+		Tags:      []string{"v0.1.0"},
+	}
+	if diff := cmp.Diff(expected, commit); len(diff) > 0 {
+		t.Fatal(diff)
+	}
+
+	t.Run("unknown reference", func(t *testing.T) {
+		_, err := client.Commit(context.Background(), testURL.String()+"/nbedos/cistern.git", "unknown")
+		if err != ErrUnknownGitReference {
+			t.Fatalf("expected %v but got %v", ErrUnknownGitReference, err)
+		}
+	})
+}
+
+func TestGiteaClient_RefStatuses(t *testing.T) {
+	client, testURL, teardown := setupGiteaTestServer(t)
+	defer teardown()
+
+	urls, err := client.RefStatuses(context.Background(), testURL.String()+"/nbedos/cistern.git", "master", giteaTestSha)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{
+		"https://woodpecker.example.com/nbedos/cistern/pipeline/42",
+		"https://gitea.example.com/nbedos/cistern/actions/runs/12/jobs/0",
+	}
+	if diff := cmp.Diff(expected, urls); len(diff) > 0 {
+		t.Fatal(diff)
+	}
+}
+
+func TestParseGiteaActionsURL(t *testing.T) {
+	baseURL := url.URL{
+		Scheme: "https",
+		Host:   "gitea.example.com",
+	}
+
+	for _, u := range []string{
+		"https://gitea.example.com/nbedos/cistern/actions/runs/12",
+		"https://gitea.example.com/nbedos/cistern/actions/runs/12/jobs/0",
+	} {
+		t.Run(u, func(t *testing.T) {
+			owner, repo, number, err := parseGiteaActionsURL(&baseURL, u)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if owner != "nbedos" || repo != "cistern" || number != 12 {
+				t.Fail()
+			}
+		})
+	}
+
+	for _, u := range []string{
+		"https://example.com/nbedos/cistern/actions/runs/12",
+		"https://gitea.example.com/nbedos/cistern/commit/12",
+	} {
+		t.Run(u, func(t *testing.T) {
+			_, _, _, err := parseGiteaActionsURL(&baseURL, u)
+			if err != ErrUnknownPipelineURL {
+				t.Fatalf("expected %v but got %v", ErrUnknownPipelineURL, err)
+			}
+		})
+	}
+}
+
+func TestGiteaClient_BuildFromURL(t *testing.T) {
+	client, testURL, teardown := setupGiteaTestServer(t)
+	defer teardown()
+
+	pipeline, err := client.BuildFromURL(context.Background(), testURL.String()+"/nbedos/cistern/actions/runs/12/jobs/0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	at := func(minutes int, seconds int) utils.NullTime {
+		return utils.NullTime{
+			Valid: true,
+			Time:  time.Date(2020, 1, 30, 13, minutes, seconds, 0, time.UTC),
+		}
+	}
+	webURL := utils.NullString{
+		Valid:  true,
+		String: testURL.String() + "/nbedos/cistern/actions/runs/12",
+	}
+
+	expectedPipeline := Pipeline{
+		Number: "12",
+		Ref:    "master",
+		Step: Step{
+			ID:         "1042",
+			Type:       StepPipeline,
+			State:      Failed,
+			CreatedAt:  at(0, 0),
+			StartedAt:  at(0, 5),
+			FinishedAt: at(1, 30),
+			UpdatedAt:  at(1, 30),
+			Duration: utils.NullDuration{
+				Valid:    true,
+				Duration: 85 * time.Second,
+			},
+			WebURL: webURL,
+			Children: []Step{
+				{
+					ID:         "7",
+					Name:       "unit",
+					Type:       StepJob,
+					State:      Failed,
+					CreatedAt:  at(0, 0),
+					StartedAt:  at(0, 5),
+					FinishedAt: at(1, 30),
+					Duration: utils.NullDuration{
+						Valid:    true,
+						Duration: 85 * time.Second,
+					},
+					WebURL: webURL,
+					Log: Log{
+						Key: testURL.String() + "/api/v1/repos/nbedos/cistern/actions/jobs/7/logs",
+					},
+					Children: []Step{
+						{
+							ID:         "1",
+							Name:       "Checkout",
+							Type:       StepTask,
+							State:      Passed,
+							CreatedAt:  at(0, 0),
+							StartedAt:  at(0, 5),
+							FinishedAt: at(0, 10),
+							Duration: utils.NullDuration{
+								Valid:    true,
+								Duration: 5 * time.Second,
+							},
+							WebURL: webURL,
+						},
+						{
+							ID:         "2",
+							Name:       "go test ./...",
+							Type:       StepTask,
+							State:      Failed,
+							CreatedAt:  at(0, 0),
+							StartedAt:  at(0, 10),
+							FinishedAt: at(1, 30),
+							Duration: utils.NullDuration{
+								Valid:    true,
+								Duration: 80 * time.Second,
+							},
+							WebURL: webURL,
+						},
+					},
+				},
+			},
+		},
+	}
+	if diff := expectedPipeline.Diff(pipeline); len(diff) > 0 {
+		t.Fatal(diff)
+	}
+}
+
+func TestGiteaClient_Log(t *testing.T) {
+	client, testURL, teardown := setupGiteaTestServer(t)
+	defer teardown()
+
+	step := Step{
+		Log: Log{
+			Key: testURL.String() + "/api/v1/repos/nbedos/cistern/actions/jobs/7/logs",
+		},
+	}
+
+	log, err := client.Log(context.Background(), step)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if diff := cmp.Diff("go test ./...\nFAIL\n", log); len(diff) > 0 {
+		t.Fatal(diff)
+	}
+}
@@ -5,8 +5,11 @@ import (
 	"fmt"
 	"net/http"
 	"net/url"
+	"regexp"
+	"strconv"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/google/go-github/v29/github"
 	"github.com/nbedos/cistern/utils"
@@ -16,28 +19,71 @@ import (
 type GitHubClient struct {
 	id     string
 	client *github.Client
+	// Slugs of the GitHub Apps whose check suites are shown as pipelines
+	checkApps []string
+	// Contexts of the commit statuses shown as jobs of a single pipeline
+	statusContexts []string
+	// False if the client was created without an API token
+	authenticated bool
+	// Requests sent through client
+	requests *requestCounter
 }
 
-func NewGitHubClient(ctx context.Context, id string, token *string) GitHubClient {
-	var httpClient *http.Client
-
-	if token != nil && *token != "" {
+func NewGitHubClient(ctx context.Context, id string, token *string, checkApps []string, statusContexts []string) GitHubClient {
+	var transport http.RoundTripper
+	authenticated := token != nil && *token != ""
+	if authenticated {
 		ts := oauth2.StaticTokenSource(
 			&oauth2.Token{AccessToken: *token},
 		)
-		httpClient = oauth2.NewClient(ctx, ts)
+		transport = oauth2.NewClient(ctx, ts).Transport
 	}
+	httpClient, requests := countedClient(transport)
 
 	return GitHubClient{
-		id:     id,
-		client: github.NewClient(httpClient),
+		id:             id,
+		client:         github.NewClient(httpClient),
+		checkApps:      checkApps,
+		statusContexts: statusContexts,
+		authenticated:  authenticated,
+		requests:       requests,
+	}
+}
+
+func (c GitHubClient) RequestStats() RequestStats {
+	return c.requests.snapshot()
+}
+
+func (c GitHubClient) Authenticated() bool {
+	return c.authenticated
+}
+
+// Explain rate limit errors of clients without credentials, the limit being much lower for
+// unauthenticated requests
+func (c GitHubClient) explain(err error) error {
+	if _, ok := err.(*github.RateLimitError); ok && !c.authenticated {
+		return fmt.Errorf("%w (unauthenticated requests are limited to 60 per hour, set \"token\" in the [[providers.github]] section of the configuration file to raise this limit)", err)
 	}
+	return err
 }
 
 func (c GitHubClient) ID() string {
 	return c.id
 }
 
+func (c GitHubClient) Host() string {
+	return strings.TrimPrefix(c.client.BaseURL.Host, "api.")
+}
+
+func (c GitHubClient) Name() string {
+	return "github"
+}
+
+func (c GitHubClient) HostsRepository(url string) bool {
+	_, _, err := c.parseRepositoryURL(url)
+	return err == nil
+}
+
 func (c GitHubClient) parseRepositoryURL(url string) (string, string, error) {
 	host, slug, err := utils.RepositoryHostAndSlug(url)
 	expectedHost := strings.TrimPrefix(c.client.BaseURL.Hostname(), "api.")
@@ -55,7 +101,8 @@ func (c GitHubClient) parseRepositoryURL(url string) (string, string, error) {
 	return owner, repo, nil
 }
 
-func (c GitHubClient) Commit(ctx context.Context, repo string, ref string) (Commit, error) {
+func (c GitHubClient) Commit(ctx context.Context, repo string, ref string) (_ Commit, err error) {
+	defer func() { err = c.explain(err) }()
 	owner, repo, err := c.parseRepositoryURL(repo)
 	if err != nil {
 		return Commit{}, ErrUnknownRepositoryURL
@@ -80,10 +127,11 @@ func (c GitHubClient) Commit(ctx context.Context, repo string, ref string) (Comm
 
 	githubCommit := repoCommit.Commit
 	commit := Commit{
-		Sha:     repoCommit.GetSHA(),
-		Author:  fmt.Sprintf("%s <%s>", githubCommit.GetAuthor().GetName(), githubCommit.GetAuthor().GetEmail()),
-		Date:    githubCommit.GetAuthor().GetDate(),
-		Message: githubCommit.GetMessage(),
+		Sha:       repoCommit.GetSHA(),
+		Author:    fmt.Sprintf("%s <%s>", githubCommit.GetAuthor().GetName(), githubCommit.GetAuthor().GetEmail()),
+		Committer: fmt.Sprintf("%s <%s>", githubCommit.GetCommitter().GetName(), githubCommit.GetCommitter().GetEmail()),
+		Date:      githubCommit.GetAuthor().GetDate(),
+		Message:   githubCommit.GetMessage(),
 	}
 
 	branches, _, err := c.client.Repositories.ListBranchesHeadCommit(ctx, owner, repo, commit.Sha)
@@ -115,7 +163,8 @@ func (c GitHubClient) Commit(ctx context.Context, repo string, ref string) (Comm
 	return commit, nil
 }
 
-func (c GitHubClient) RefStatuses(ctx context.Context, u string, ref string, sha string) ([]string, error) {
+func (c GitHubClient) RefStatuses(ctx context.Context, u string, ref string, sha string) (_ []string, err error) {
+	defer func() { err = c.explain(err) }()
 	owner, repo, err := c.parseRepositoryURL(u)
 	if err != nil {
 		return nil, err
@@ -142,11 +191,14 @@ func (c GitHubClient) RefStatuses(ctx context.Context, u string, ref string, sha
 				return
 			}
 			for _, status := range statuses {
-				if status.TargetURL == nil || *status.TargetURL == "" {
+				if status == nil || status.TargetURL == nil || *status.TargetURL == "" {
 					continue
 				}
 				mux.Lock()
 				previousURLs[*status.TargetURL] = struct{}{}
+				if sha != "" && matchesAny(c.statusContexts, status.GetContext()) {
+					previousURLs[c.commitStatusesURL(owner, repo, sha)] = struct{}{}
+				}
 				mux.Unlock()
 			}
 
@@ -184,7 +236,37 @@ func (c GitHubClient) RefStatuses(ctx context.Context, u string, ref string, sha
 		errc <- nil
 	}()
 
-	for i := 0; i < 2; i++ {
+	go func() {
+		if len(c.checkApps) == 0 {
+			errc <- nil
+			return
+		}
+		opt := github.ListCheckSuiteOptions{}
+		for {
+			suites, resp, err := c.client.Checks.ListCheckSuitesForRef(ctx, owner, repo, ref, &opt)
+			if err != nil {
+				errc <- err
+				return
+			}
+
+			for _, suite := range suites.CheckSuites {
+				if suite == nil || !matchesAny(c.checkApps, suite.GetApp().GetSlug()) {
+					continue
+				}
+				mux.Lock()
+				previousURLs[c.checkSuiteURL(owner, repo, suite.GetHeadSHA(), suite.GetID())] = struct{}{}
+				mux.Unlock()
+			}
+
+			if resp.NextPage == 0 {
+				break
+			}
+			opt.Page = resp.NextPage
+		}
+		errc <- nil
+	}()
+
+	for i := 0; i < 3; i++ {
 		if e := <-errc; err == nil {
 			switch errResp := e.(type) {
 			case *github.ErrorResponse:
@@ -210,3 +292,393 @@ func (c GitHubClient) RefStatuses(ctx context.Context, u string, ref string, sha
 
 	return urls, err
 }
+
+// Return true if s matches one of the patterns. A pattern ending with "*" matches all strings
+// starting with the rest of the pattern.
+func matchesAny(patterns []string, s string) bool {
+	for _, pattern := range patterns {
+		if prefix := strings.TrimSuffix(pattern, "*"); prefix != pattern {
+			if strings.HasPrefix(s, prefix) {
+				return true
+			}
+		} else if pattern == s {
+			return true
+		}
+	}
+	return false
+}
+
+// Return the URL of the web page of a commit, which is also the URL of the pipeline built
+// from the statuses of the commit
+func (c GitHubClient) commitStatusesURL(owner string, repo string, sha string) string {
+	return fmt.Sprintf("https://%s/%s/%s/commit/%s", c.Host(), owner, repo, sha)
+}
+
+// Return the URL of the web page of a check suite, which is also the URL of the pipeline
+// built from the check suite
+func (c GitHubClient) checkSuiteURL(owner string, repo string, sha string, id int64) string {
+	return fmt.Sprintf("https://%s/%s/%s/commit/%s/checks?check_suite_id=%d", c.Host(), owner, repo, sha, id)
+}
+
+// Pipelines of GitHubClient are either check suites, whose jobs are check runs, or sets of
+// commit statuses. This shows results of CI systems that have no dedicated provider.
+// Check suites are designated by the URL of their web page
+// (https://github.com/<owner>/<repo>/commit/<sha>/checks?check_suite_id=<id>) and commit
+// statuses by the URL of the web page of the commit (https://github.com/<owner>/<repo>/commit/<sha>).
+func (c GitHubClient) BuildFromURL(ctx context.Context, u string) (Pipeline, error) {
+	pipeline, _, err := c.BuildFromURLSince(ctx, u, SourceVersion{})
+	return pipeline, err
+}
+
+// Same as BuildFromURL but return ErrNotModified if the pipeline did not change since the
+// version 'since'. The first page of check runs or commit statuses, and the check suite
+// itself, are requested with the ETags of the previous responses so that GitHub answers
+// "304 Not Modified" if nothing changed. Such responses do not count against the rate limit.
+// Pipelines whose jobs span several pages are always fetched entirely.
+func (c GitHubClient) BuildFromURLSince(ctx context.Context, u string, since SourceVersion) (_ Pipeline, _ SourceVersion, err error) {
+	defer func() { err = c.explain(err) }()
+	v, err := url.Parse(u)
+	if err != nil {
+		return Pipeline{}, SourceVersion{}, err
+	}
+	if v.Host != c.Host() {
+		return Pipeline{}, SourceVersion{}, ErrUnknownPipelineURL
+	}
+
+	var pipeline Pipeline
+	var etags []string
+	previous := splitETags(since.ETag)
+	cs := strings.Split(strings.Trim(v.Path, "/"), "/")
+	switch {
+	case len(c.checkApps) > 0 && len(cs) == 5 && cs[2] == "commit" && cs[4] == "checks":
+		id, err := strconv.ParseInt(v.Query().Get("check_suite_id"), 10, 64)
+		if err != nil {
+			return Pipeline{}, SourceVersion{}, ErrUnknownPipelineURL
+		}
+		pipeline, etags, err = c.checkSuitePipeline(ctx, cs[0], cs[1], id, u, previous)
+		if err != nil {
+			return Pipeline{}, since, err
+		}
+
+	case len(c.statusContexts) > 0 && len(cs) == 4 && cs[2] == "commit" && v.RawQuery == "":
+		pipeline, etags, err = c.commitStatusesPipeline(ctx, cs[0], cs[1], cs[3], u, previous)
+		if err != nil {
+			return Pipeline{}, since, err
+		}
+
+	default:
+		return Pipeline{}, SourceVersion{}, ErrUnknownPipelineURL
+	}
+
+	return pipeline, SourceVersion{ETag: strings.Join(etags, "\n")}, nil
+}
+
+// ETags of the several resources making up a pipeline are joined by newlines, which ETags
+// cannot contain
+func splitETags(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return strings.Split(s, "\n")
+}
+
+// Send a GET request for the resource at the path 'u' of the API and decode the response in v.
+// If etag is not empty, the request is conditional and ErrNotModified is returned if the
+// resource did not change. The ETag of the response is returned along with the response.
+func (c GitHubClient) getConditional(ctx context.Context, u string, accept string, etag string, v interface{}) (*github.Response, string, error) {
+	req, err := c.client.NewRequest("GET", u, nil)
+	if err != nil {
+		return nil, "", err
+	}
+	if accept != "" {
+		req.Header.Set("Accept", accept)
+	}
+	if etag != "" {
+		req.Header.Set("If-None-Match", etag)
+	}
+	resp, err := c.client.Do(ctx, req, v)
+	if resp != nil && resp.StatusCode == http.StatusNotModified {
+		return resp, etag, ErrNotModified
+	}
+	if err != nil {
+		return resp, "", err
+	}
+	return resp, resp.Header.Get("ETag"), nil
+}
+
+// Return the commit statuses of sha as a pipeline along with the ETag of the first page of
+// statuses. ErrNotModified is returned if 'etags' holds the ETag of a single page of
+// statuses that did not change. No ETag is returned if the statuses span several pages.
+func (c GitHubClient) commitStatusesPipeline(ctx context.Context, owner string, repo string, sha string, u string, etags []string) (Pipeline, []string, error) {
+	pipeline := Pipeline{
+		Number: sha,
+		Step: Step{
+			ID:     sha,
+			Name:   "commit statuses",
+			Type:   StepPipeline,
+			WebURL: utils.NullString{Valid: true, String: u},
+		},
+	}
+	if len(pipeline.Number) > 7 {
+		pipeline.Number = pipeline.Number[:7]
+	}
+
+	var previous string
+	if len(etags) == 1 {
+		previous = etags[0]
+	}
+	var etag string
+	page := 1
+	for {
+		path := fmt.Sprintf("repos/%s/%s/commits/%s/status?per_page=100&page=%d", owner, repo, url.QueryEscape(sha), page)
+		combined := new(github.CombinedStatus)
+		resp, pageETag, err := c.getConditional(ctx, path, "", previous, combined)
+		if err != nil {
+			return Pipeline{}, nil, err
+		}
+		if page == 1 && resp.NextPage == 0 {
+			etag = pageETag
+		}
+		previous = ""
+		for _, status := range combined.Statuses {
+			if matchesAny(c.statusContexts, status.GetContext()) {
+				step := fromGitHubStatus(status)
+				if status.GetContext() == "codecov/project" {
+					// Coverage of the whole project, as opposed to "codecov/patch"
+					pipeline.Coverage = step.Coverage
+				}
+				pipeline.Children = append(pipeline.Children, step)
+			}
+		}
+		if resp.NextPage == 0 {
+			break
+		}
+		page = resp.NextPage
+	}
+
+	aggregate := Aggregate(pipeline.Children)
+	pipeline.State = aggregate.State
+	pipeline.CreatedAt = aggregate.CreatedAt
+	pipeline.StartedAt = aggregate.StartedAt
+	pipeline.UpdatedAt = aggregate.UpdatedAt
+	if !pipeline.State.IsActive() {
+		pipeline.FinishedAt = aggregate.FinishedAt
+	}
+	pipeline.Duration = utils.NullSub(pipeline.FinishedAt, pipeline.StartedAt)
+
+	if etag == "" {
+		return pipeline, nil, nil
+	}
+	return pipeline, []string{etag}, nil
+}
+
+// Commit statuses only carry the time of their creation and of their last update, so a status
+// is considered to have started when created and finished when last updated.
+func fromGitHubStatus(status github.RepoStatus) Step {
+	nullTime := func(t *time.Time) utils.NullTime {
+		if t == nil {
+			return utils.NullTime{}
+		}
+		return utils.NullTime{Valid: true, Time: *t}
+	}
+
+	step := Step{
+		ID:        status.GetContext(),
+		Name:      status.GetContext(),
+		Type:      StepJob,
+		CreatedAt: nullTime(status.CreatedAt),
+		StartedAt: nullTime(status.CreatedAt),
+		UpdatedAt: nullTime(status.UpdatedAt),
+		Log: Log{
+			Content: utils.NullString{Valid: true, String: status.GetDescription()},
+		},
+	}
+	switch status.GetState() {
+	case "pending":
+		step.State = Pending
+	case "success":
+		step.State = Passed
+	case "failure", "error":
+		step.State = Failed
+	default:
+		step.State = Unknown
+	}
+	if !step.State.IsActive() {
+		step.FinishedAt = step.UpdatedAt
+	}
+	step.Duration = utils.NullSub(step.FinishedAt, step.StartedAt)
+	if targetURL := status.GetTargetURL(); targetURL != "" {
+		step.WebURL = utils.NullString{Valid: true, String: targetURL}
+	}
+	if strings.HasPrefix(status.GetContext(), "codecov/") {
+		step.Coverage = codecovCoverage(status.GetDescription())
+	}
+
+	return step
+}
+
+// Codecov reports coverage in the description of its commit statuses, e.g.
+// "87.50% (+0.25%) compared to 1a2b3c4"
+var codecovDescription = regexp.MustCompile(`^\s*(\d+(?:\.\d+)?)%`)
+
+func codecovCoverage(description string) utils.NullFloat64 {
+	match := codecovDescription.FindStringSubmatch(description)
+	if match == nil {
+		return utils.NullFloat64{}
+	}
+	coverage, err := strconv.ParseFloat(match[1], 64)
+	if err != nil {
+		return utils.NullFloat64{}
+	}
+	return utils.NullFloat64{Valid: true, Float64: coverage}
+}
+
+// Media type of the check runs API
+const gitHubChecksMediaType = "application/vnd.github.antiope-preview+json"
+
+// Return the check suite designated by id as a pipeline whose jobs are the check runs of the
+// suite, along with the ETags of the suite and of the first page of check runs. ErrNotModified
+// is returned if 'etags' holds ETags of the suite and of a single page of check runs that
+// did not change. No ETag is returned if the check runs span several pages.
+func (c GitHubClient) checkSuitePipeline(ctx context.Context, owner string, repo string, id int64, u string, etags []string) (Pipeline, []string, error) {
+	suitePath := fmt.Sprintf("repos/%s/%s/check-suites/%d", owner, repo, id)
+	runsPath := func(page int) string {
+		return fmt.Sprintf("%s/check-runs?per_page=100&page=%d", suitePath, page)
+	}
+
+	var previousSuite, previousRuns string
+	if len(etags) == 2 {
+		previousSuite, previousRuns = etags[0], etags[1]
+	}
+	suite := new(github.CheckSuite)
+	_, suiteETag, suiteErr := c.getConditional(ctx, suitePath, gitHubChecksMediaType, previousSuite, suite)
+	runs := new(github.ListCheckRunsResults)
+	resp, runsETag, runsErr := c.getConditional(ctx, runsPath(1), gitHubChecksMediaType, previousRuns, runs)
+	switch {
+	case suiteErr == ErrNotModified && runsErr == ErrNotModified:
+		return Pipeline{}, nil, ErrNotModified
+	case suiteErr == ErrNotModified:
+		// Only the check runs changed but the suite is needed to build the pipeline
+		_, suiteETag, suiteErr = c.getConditional(ctx, suitePath, gitHubChecksMediaType, "", suite)
+	case runsErr == ErrNotModified:
+		resp, runsETag, runsErr = c.getConditional(ctx, runsPath(1), gitHubChecksMediaType, "", runs)
+	}
+	if suiteErr != nil {
+		return Pipeline{}, nil, suiteErr
+	}
+	if runsErr != nil {
+		return Pipeline{}, nil, runsErr
+	}
+
+	pipeline := Pipeline{
+		Number: strconv.FormatInt(id, 10),
+		Ref:    suite.GetHeadBranch(),
+		Step: Step{
+			ID:     strconv.FormatInt(id, 10),
+			Name:   suite.GetApp().GetName(),
+			Type:   StepPipeline,
+			State:  fromGitHubCheckState(suite.GetStatus(), suite.GetConclusion()),
+			WebURL: utils.NullString{Valid: true, String: u},
+		},
+	}
+
+	var validators []string
+	if resp.NextPage == 0 && suiteETag != "" && runsETag != "" {
+		validators = []string{suiteETag, runsETag}
+	}
+	for {
+		for _, run := range runs.CheckRuns {
+			if run != nil {
+				pipeline.Children = append(pipeline.Children, fromGitHubCheckRun(*run))
+			}
+		}
+		if resp.NextPage == 0 {
+			break
+		}
+		runs = new(github.ListCheckRunsResults)
+		var err error
+		if resp, _, err = c.getConditional(ctx, runsPath(resp.NextPage), gitHubChecksMediaType, "", runs); err != nil {
+			return Pipeline{}, nil, err
+		}
+	}
+
+	for _, job := range pipeline.Children {
+		pipeline.StartedAt = utils.MinNullTime(pipeline.StartedAt, job.StartedAt)
+		pipeline.FinishedAt = utils.MaxNullTime(pipeline.FinishedAt, job.FinishedAt)
+		pipeline.UpdatedAt = utils.MaxNullTime(pipeline.UpdatedAt, job.UpdatedAt)
+	}
+	if pipeline.State.IsActive() {
+		pipeline.FinishedAt = utils.NullTime{}
+	}
+	pipeline.CreatedAt = pipeline.StartedAt
+	pipeline.Duration = utils.NullSub(pipeline.FinishedAt, pipeline.StartedAt)
+
+	return pipeline, validators, nil
+}
+
+// The output of check runs is included in the pipeline returned by BuildFromURL
+func (c GitHubClient) Log(ctx context.Context, step Step) (string, error) {
+	return "", ErrNoLogHere
+}
+
+func fromGitHubCheckRun(run github.CheckRun) Step {
+	nullTime := func(t *github.Timestamp) utils.NullTime {
+		if t == nil {
+			return utils.NullTime{}
+		}
+		return utils.NullTime{Valid: true, Time: t.Time}
+	}
+
+	step := Step{
+		ID:         strconv.FormatInt(run.GetID(), 10),
+		Name:       run.GetName(),
+		Type:       StepJob,
+		State:      fromGitHubCheckState(run.GetStatus(), run.GetConclusion()),
+		StartedAt:  nullTime(run.StartedAt),
+		FinishedAt: nullTime(run.CompletedAt),
+	}
+	step.UpdatedAt = utils.MaxNullTime(step.StartedAt, step.FinishedAt)
+	step.Duration = utils.NullSub(step.FinishedAt, step.StartedAt)
+	if webURL := run.GetHTMLURL(); webURL != "" {
+		step.WebURL = utils.NullString{Valid: true, String: webURL}
+	}
+
+	output := make([]string, 0)
+	for _, s := range []string{run.GetOutput().GetTitle(), run.GetOutput().GetSummary(), run.GetOutput().GetText()} {
+		if s != "" {
+			output = append(output, s)
+		}
+	}
+	step.Log = Log{
+		Content: utils.NullString{
+			Valid:  true,
+			String: strings.Join(output, "\n\n"),
+		},
+	}
+
+	return step
+}
+
+func fromGitHubCheckState(status string, conclusion string) State {
+	switch status {
+	case "queued":
+		return Pending
+	case "in_progress":
+		return Running
+	case "completed":
+		switch conclusion {
+		case "success", "neutral":
+			return Passed
+		case "failure", "timed_out", "startup_failure":
+			return Failed
+		case "cancelled":
+			return Canceled
+		case "skipped", "stale":
+			return Skipped
+		case "action_required":
+			return Manual
+		}
+	}
+
+	return Unknown
+}
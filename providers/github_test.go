@@ -8,11 +8,13 @@ import (
 	"net/http/httptest"
 	"path"
 	"sort"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/google/go-cmp/cmp"
 	"github.com/google/go-github/v29/github"
+	"github.com/nbedos/cistern/utils"
 )
 
 func setupGitHubTestServer() (*http.Client, string, func()) {
@@ -29,11 +31,27 @@ func setupGitHubTestServer() (*http.Client, string, func()) {
 			filename = "github_branches.json"
 		case "/api/v3/repos/nbedos/termtosvg/tags":
 			filename = "github_tags.json"
+		case "/api/v3/repos/nbedos/termtosvg/commits/d58600a58bf1738c6529ce3489a546bfa2178e07/check-suites":
+			filename = "github_check_suites.json"
+		case "/api/v3/repos/nbedos/termtosvg/commits/d58600a58bf1738c6529ce3489a546bfa2178e07/status":
+			filename = "github_combined_status.json"
+		case "/api/v3/repos/nbedos/termtosvg/check-suites/42":
+			filename = "github_check_suite.json"
+		case "/api/v3/repos/nbedos/termtosvg/check-suites/42/check-runs":
+			filename = "github_check_suite_runs.json"
 		default:
 			w.WriteHeader(404)
 			return
 		}
 
+		// Resources never change so their ETag only depends on their content
+		etag := fmt.Sprintf("%q", filename)
+		if r.Header.Get("If-None-Match") == etag {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", etag)
+
 		bs, err := ioutil.ReadFile(path.Join("test_data", "github", filename))
 		if err != nil {
 			w.WriteHeader(500)
@@ -102,15 +120,283 @@ func TestCommit(t *testing.T) {
 	}
 
 	expectedCommit := Commit{
-		Sha:      "d58600a58bf1738c6529ce3489a546bfa2178e07",
-		Author:   "nbedos <nicolas.bedos@gmail.com>",
-		Date:     time.Date(2019, 11, 16, 14, 59, 32, 0, time.UTC),
-		Message:  "Bump version to 1.0.0",
-		Branches: []string{"master"},
-		Tags:     []string{"1.0.0"},
+		Sha:       "d58600a58bf1738c6529ce3489a546bfa2178e07",
+		Author:    "nbedos <nicolas.bedos@gmail.com>",
+		Committer: "nbedos <nicolas.bedos@gmail.com>",
+		Date:      time.Date(2019, 11, 16, 14, 59, 32, 0, time.UTC),
+		Message:   "Bump version to 1.0.0",
+		Branches:  []string{"master"},
+		Tags:      []string{"1.0.0"},
 	}
 
 	if diff := cmp.Diff(expectedCommit, commit); len(diff) > 0 {
 		t.Fatal(diff)
 	}
 }
+
+func TestGitHubClient_BuildFromURL(t *testing.T) {
+	httpClient, serverURL, teardown := setupGitHubTestServer()
+	defer teardown()
+
+	c, err := github.NewEnterpriseClient(serverURL, serverURL, httpClient)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := GitHubClient{
+		client:    c,
+		checkApps: []string{"github-actions"},
+	}
+
+	sha := "d58600a58bf1738c6529ce3489a546bfa2178e07"
+	urls, err := client.RefStatuses(context.Background(), serverURL+"/nbedos/termtosvg", "", sha)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Only the check suite of GitHub Actions is listed, not the one of Travis CI
+	suiteURL := client.checkSuiteURL("nbedos", "termtosvg", sha, 42)
+	found := false
+	for _, u := range urls {
+		found = found || u == suiteURL
+		if u == client.checkSuiteURL("nbedos", "termtosvg", sha, 43) {
+			t.Fatalf("unexpected url: %q", u)
+		}
+	}
+	if !found {
+		t.Fatalf("expected %q in %v", suiteURL, urls)
+	}
+
+	pipeline, err := client.BuildFromURL(context.Background(), suiteURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	at := func(minutes int, seconds int) utils.NullTime {
+		return utils.NullTime{
+			Valid: true,
+			Time:  time.Date(2019, 11, 16, 15, minutes, seconds, 0, time.UTC),
+		}
+	}
+	expectedPipeline := Pipeline{
+		Number: "42",
+		Ref:    "master",
+		Step: Step{
+			ID:         "42",
+			Name:       "GitHub Actions",
+			Type:       StepPipeline,
+			State:      Failed,
+			CreatedAt:  at(0, 0),
+			StartedAt:  at(0, 0),
+			FinishedAt: at(3, 10),
+			UpdatedAt:  at(3, 10),
+			Duration:   utils.NullDuration{Valid: true, Duration: 3*time.Minute + 10*time.Second},
+			WebURL:     utils.NullString{Valid: true, String: suiteURL},
+			Children: []Step{
+				{
+					ID:         "1001",
+					Name:       "lint",
+					Type:       StepJob,
+					State:      Passed,
+					StartedAt:  at(0, 0),
+					FinishedAt: at(1, 0),
+					UpdatedAt:  at(1, 0),
+					Duration:   utils.NullDuration{Valid: true, Duration: time.Minute},
+					WebURL:     utils.NullString{Valid: true, String: "https://github.com/nbedos/termtosvg/runs/1001"},
+					Log:        Log{Content: utils.NullString{Valid: true, String: "Lint\n\nNo issue found"}},
+				},
+				{
+					ID:         "1002",
+					Name:       "test",
+					Type:       StepJob,
+					State:      Failed,
+					StartedAt:  at(0, 10),
+					FinishedAt: at(3, 10),
+					UpdatedAt:  at(3, 10),
+					Duration:   utils.NullDuration{Valid: true, Duration: 3 * time.Minute},
+					WebURL:     utils.NullString{Valid: true, String: "https://github.com/nbedos/termtosvg/runs/1002"},
+					Log:        Log{Content: utils.NullString{Valid: true, String: "Tests failed\n\n1 test failed\n\nFAIL: TestCommit"}},
+				},
+			},
+		},
+	}
+	if diff := expectedPipeline.Diff(pipeline); len(diff) > 0 {
+		t.Fatal(diff)
+	}
+
+	t.Run("url of a check run", func(t *testing.T) {
+		_, err := client.BuildFromURL(context.Background(), "https://github.com/nbedos/termtosvg/runs/1001")
+		if err != ErrUnknownPipelineURL {
+			t.Fatalf("expected %v but got %v", ErrUnknownPipelineURL, err)
+		}
+	})
+}
+
+func TestGitHubClient_BuildFromURLStatuses(t *testing.T) {
+	httpClient, serverURL, teardown := setupGitHubTestServer()
+	defer teardown()
+
+	c, err := github.NewEnterpriseClient(serverURL, serverURL, httpClient)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := GitHubClient{
+		client:         c,
+		statusContexts: []string{"continuous-integration/jenkins*", "continuous-integration/appveyor*"},
+	}
+
+	sha := "d58600a58bf1738c6529ce3489a546bfa2178e07"
+	urls, err := client.RefStatuses(context.Background(), serverURL+"/nbedos/termtosvg", "", sha)
+	if err != nil {
+		t.Fatal(err)
+	}
+	statusesURL := client.commitStatusesURL("nbedos", "termtosvg", sha)
+	found := false
+	for _, u := range urls {
+		found = found || u == statusesURL
+	}
+	if !found {
+		t.Fatalf("expected %q in %v", statusesURL, urls)
+	}
+
+	pipeline, err := client.BuildFromURL(context.Background(), statusesURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	at := func(minutes int, seconds int) utils.NullTime {
+		return utils.NullTime{
+			Valid: true,
+			Time:  time.Date(2019, 11, 16, 15, minutes, seconds, 0, time.UTC),
+		}
+	}
+	expectedPipeline := Pipeline{
+		Number: "d58600a",
+		Step: Step{
+			ID:        sha,
+			Name:      "commit statuses",
+			Type:      StepPipeline,
+			State:     Pending,
+			CreatedAt: at(0, 0),
+			StartedAt: at(0, 0),
+			UpdatedAt: at(0, 30),
+			Duration:  utils.NullSub(utils.NullTime{}, at(0, 0)),
+			WebURL:    utils.NullString{Valid: true, String: statusesURL},
+			Children: []Step{
+				{
+					ID:        "continuous-integration/jenkins/branch",
+					Name:      "continuous-integration/jenkins/branch",
+					Type:      StepJob,
+					State:     Pending,
+					CreatedAt: at(0, 0),
+					StartedAt: at(0, 0),
+					UpdatedAt: at(0, 30),
+					Duration:  utils.NullSub(utils.NullTime{}, at(0, 0)),
+					WebURL:    utils.NullString{Valid: true, String: "https://jenkins.example.com/job/termtosvg/12/"},
+					Log:       Log{Content: utils.NullString{Valid: true, String: "Build #12 started"}},
+				},
+			},
+		},
+	}
+	if diff := expectedPipeline.Diff(pipeline); len(diff) > 0 {
+		t.Fatal(diff)
+	}
+}
+
+func TestGitHubClient_BuildFromURLSince(t *testing.T) {
+	httpClient, serverURL, teardown := setupGitHubTestServer()
+	defer teardown()
+
+	c, err := github.NewEnterpriseClient(serverURL, serverURL, httpClient)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := GitHubClient{
+		client:         c,
+		checkApps:      []string{"github-actions"},
+		statusContexts: []string{"continuous-integration/jenkins*"},
+	}
+
+	sha := "d58600a58bf1738c6529ce3489a546bfa2178e07"
+	urls := map[string]string{
+		"check suite":     client.checkSuiteURL("nbedos", "termtosvg", sha, 42),
+		"commit statuses": client.commitStatusesURL("nbedos", "termtosvg", sha),
+	}
+	for name, u := range urls {
+		t.Run(name, func(t *testing.T) {
+			pipeline, version, err := client.BuildFromURLSince(context.Background(), u, SourceVersion{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if version.ETag == "" {
+				t.Fatal("expected the version to hold the ETags of the responses")
+			}
+
+			// The second poll sends the ETags of the first one
+			if _, _, err := client.BuildFromURLSince(context.Background(), u, version); err != ErrNotModified {
+				t.Fatalf("expected %v but got %v", ErrNotModified, err)
+			}
+
+			stale := SourceVersion{ETag: strings.Replace(version.ETag, "json", "stale", -1)}
+			again, _, err := client.BuildFromURLSince(context.Background(), u, stale)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := pipeline.Diff(again); len(diff) > 0 {
+				t.Fatal(diff)
+			}
+		})
+	}
+
+	t.Run("a single changed resource of a check suite is fetched again", func(t *testing.T) {
+		u := urls["check suite"]
+		pipeline, version, err := client.BuildFromURLSince(context.Background(), u, SourceVersion{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		etags := splitETags(version.ETag)
+		if len(etags) != 2 {
+			t.Fatalf("expected 2 ETags but got %v", etags)
+		}
+		for i := range etags {
+			changed := append([]string(nil), etags...)
+			changed[i] = `"stale"`
+			again, _, err := client.BuildFromURLSince(context.Background(), u, SourceVersion{ETag: strings.Join(changed, "\n")})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := pipeline.Diff(again); len(diff) > 0 {
+				t.Fatal(diff)
+			}
+		}
+	})
+}
+
+func TestCodecovCoverage(t *testing.T) {
+	for description, expected := range map[string]utils.NullFloat64{
+		"87.50% (+0.25%) compared to 1a2b3c4": {Valid: true, Float64: 87.5},
+		"100% of diff hit (target 80.00%)":    {Valid: true, Float64: 100},
+		"Waiting for CI to finish":            {},
+		"":                                    {},
+	} {
+		if coverage := codecovCoverage(description); coverage != expected {
+			t.Fatalf("expected %v for %q but got %v", expected, description, coverage)
+		}
+	}
+}
+
+func TestMatchesAny(t *testing.T) {
+	patterns := []string{"github-actions", "ci/jenkins*"}
+	for s, expected := range map[string]bool{
+		"github-actions":    true,
+		"github-actions-2":  false,
+		"ci/jenkins":        true,
+		"ci/jenkins/branch": true,
+		"ci/travis":         false,
+	} {
+		if matches := matchesAny(patterns, s); matches != expected {
+			t.Errorf("%q: expected %v but got %v", s, expected, matches)
+		}
+	}
+	if !matchesAny([]string{"*"}, "anything") {
+		t.Error("expected \"*\" to match all strings")
+	}
+}
@@ -3,8 +3,11 @@ package providers
 import (
 	"bytes"
 	"context"
+	"errors"
 	"fmt"
+	"io"
 	"net/url"
+	"regexp"
 	"strconv"
 	"strings"
 	"sync"
@@ -19,12 +22,21 @@ type GitLabClient struct {
 	remote      *gitlab.Client
 	rateLimiter <-chan time.Time
 	sshHostname string
+	// False if the client was created without an API token
+	authenticated bool
+	// Requests sent through remote
+	requests *requestCounter
 }
 
 const gitLabCom = "https://gitlab.com"
 
+// Maximum depth of downstream pipelines followed through bridge jobs. This guards against
+// pipelines triggering each other in a loop.
+const maxGitLabPipelineDepth = 3
+
 func NewGitLabClient(id string, name string, baseURL string, token string, requestsPerSecond float64, SSHHostname string) (GitLabClient, error) {
-	remote := gitlab.NewClient(nil, token)
+	httpClient, requests := countedClient(nil)
+	remote := gitlab.NewClient(httpClient, token)
 	if baseURL == "" {
 		baseURL = gitLabCom
 	}
@@ -43,12 +55,22 @@ func NewGitLabClient(id string, name string, baseURL string, token string, reque
 			ID:   id,
 			Name: name,
 		},
-		remote:      remote,
-		rateLimiter: time.Tick(rateLimit),
-		sshHostname: SSHHostname,
+		remote:        remote,
+		rateLimiter:   time.Tick(rateLimit),
+		sshHostname:   SSHHostname,
+		authenticated: token != "",
+		requests:      requests,
 	}, nil
 }
 
+func (c GitLabClient) RequestStats() RequestStats {
+	return c.requests.snapshot()
+}
+
+func (c GitLabClient) Authenticated() bool {
+	return c.authenticated
+}
+
 func (c GitLabClient) Commit(ctx context.Context, repo string, ref string) (Commit, error) {
 	slug, err := c.parseRepositoryURL(repo)
 	if err != nil {
@@ -72,10 +94,11 @@ func (c GitLabClient) Commit(ctx context.Context, repo string, ref string) (Comm
 	}
 
 	commit := Commit{
-		Sha:     gitlabCommit.ID,
-		Author:  fmt.Sprintf("%s <%s>", gitlabCommit.AuthorName, gitlabCommit.AuthorEmail),
-		Date:    *gitlabCommit.AuthoredDate,
-		Message: gitlabCommit.Message,
+		Sha:       gitlabCommit.ID,
+		Author:    fmt.Sprintf("%s <%s>", gitlabCommit.AuthorName, gitlabCommit.AuthorEmail),
+		Committer: fmt.Sprintf("%s <%s>", gitlabCommit.CommitterName, gitlabCommit.CommitterEmail),
+		Date:      *gitlabCommit.AuthoredDate,
+		Message:   gitlabCommit.Message,
 	}
 
 	opt := gitlab.GetCommitRefsOptions{}
@@ -177,6 +200,7 @@ func (c GitLabClient) RefStatuses(ctx context.Context, url string, ref string, s
 
 	errc := make(chan error)
 	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
 	var statusURLs []string
 	go func() {
 		var err error
@@ -215,12 +239,97 @@ func (c GitLabClient) Name() string {
 }
 
 func (c GitLabClient) BuildFromURL(ctx context.Context, u string) (Pipeline, error) {
+	pipeline, _, err := c.BuildFromURLSince(ctx, u, SourceVersion{})
+	return pipeline, err
+}
+
+// Same as BuildFromURL but return ErrNotModified if the pipeline was not updated since the
+// version 'since'. The version of a pipeline is its update time according to GitLab, so that
+// GitLab can be asked with the updated_after parameter whether it changed without fetching
+// its jobs. Pipelines triggering downstream pipelines are always fetched entirely since
+// updates of downstream pipelines do not change the update time of the upstream pipeline.
+func (c GitLabClient) BuildFromURLSince(ctx context.Context, u string, since SourceVersion) (Pipeline, SourceVersion, error) {
 	slug, id, err := c.parsePipelineURL(u)
 	if err != nil {
-		return Pipeline{}, err
+		return Pipeline{}, SourceVersion{}, err
+	}
+
+	if since.LastModified != "" {
+		updated, err := c.updatedAfter(ctx, slug, id, since.LastModified)
+		if err != nil {
+			return Pipeline{}, since, err
+		}
+		if !updated {
+			return Pipeline{}, since, ErrNotModified
+		}
+	}
+
+	pipeline, err := c.fetchPipeline(ctx, slug, id, 0)
+	if err != nil {
+		return Pipeline{}, SourceVersion{}, err
+	}
+	var version SourceVersion
+	if pipeline.UpdatedAt.Valid && !hasDownstreamPipelines(pipeline) {
+		version.LastModified = pipeline.UpdatedAt.Time.UTC().Format(time.RFC3339Nano)
+	}
+
+	return pipeline, version, nil
+}
+
+// Return true if the jobs of the pipeline include downstream pipelines (see bridgeStep)
+func hasDownstreamPipelines(p Pipeline) bool {
+	for _, stage := range p.Children {
+		for _, job := range stage.Children {
+			if len(job.Children) > 0 {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+type gitlabUpdatedPipelinesOptions struct {
+	gitlab.ListOptions
+	UpdatedAfter string `url:"updated_after"`
+}
+
+// Return true if the pipeline 'pipelineID' was updated after 'updatedAfter'. If more pipelines
+// of the project were updated since then than fit in a page, the pipeline is assumed to have
+// been updated.
+func (c GitLabClient) updatedAfter(ctx context.Context, slug string, pipelineID int, updatedAfter string) (bool, error) {
+	select {
+	case <-c.rateLimiter:
+	case <-ctx.Done():
+		return false, ctx.Err()
+	}
+
+	u := fmt.Sprintf("projects/%s/pipelines", url.PathEscape(slug))
+	options := gitlabUpdatedPipelinesOptions{
+		ListOptions:  gitlab.ListOptions{PerPage: 100},
+		UpdatedAfter: updatedAfter,
+	}
+	req, err := c.remote.NewRequest("GET", u, &options, []gitlab.OptionFunc{gitlab.WithContext(ctx)})
+	if err != nil {
+		return false, err
 	}
 
-	return c.fetchPipeline(ctx, slug, id)
+	pipelines := make([]gitlab.PipelineInfo, 0)
+	resp, err := c.remote.Do(req, &pipelines)
+	if err != nil {
+		return false, err
+	}
+	for _, p := range pipelines {
+		if p.ID == pipelineID {
+			return true, nil
+		}
+	}
+
+	return resp.NextPage != 0, nil
+}
+
+func (c GitLabClient) HostsRepository(u string) bool {
+	_, err := c.parseRepositoryURL(u)
+	return err == nil
 }
 
 func (c GitLabClient) parseRepositoryURL(u string) (string, error) {
@@ -250,7 +359,12 @@ func (c GitLabClient) parsePipelineURL(u string) (string, int, error) {
 		return "", 0, ErrUnknownPipelineURL
 	}
 
-	slug := strings.Join(pathComponents[:len(pathComponents)-2], "/")
+	// Recent versions of GitLab insert "/-/" before "pipelines"
+	slugComponents := pathComponents[:len(pathComponents)-2]
+	if len(slugComponents) > 2 && slugComponents[len(slugComponents)-1] == "-" {
+		slugComponents = slugComponents[:len(slugComponents)-1]
+	}
+	slug := strings.Join(slugComponents, "/")
 	id, err := strconv.Atoi(pathComponents[len(pathComponents)-1])
 	if err != nil {
 		return "", 0, err
@@ -306,12 +420,176 @@ func (c GitLabClient) Log(ctx context.Context, step Step) (string, error) {
 
 	buf, err := c.getTraceFile(ctx, step.Log.Key, id)
 	if err != nil {
+		if err, ok := err.(*gitlab.ErrorResponse); ok && !c.authenticated {
+			switch err.Response.StatusCode {
+			case 401, 403:
+				return "", errors.New("GitLab only gives access to job logs to authenticated users, set \"token\" in the [[providers.gitlab]] section of the configuration file")
+			}
+		}
 		return "", err
 	}
 
 	return buf.String(), nil
 }
 
+// GitLab keeps the artifacts of a job in a single archive
+func (c GitLabClient) Artifacts(ctx context.Context, step Step) ([]Artifact, error) {
+	// Bridge jobs have neither log nor artifacts
+	if step.Log.Key == "" {
+		return nil, ErrNoArtifacts
+	}
+	id, err := strconv.Atoi(step.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	select {
+	case <-c.rateLimiter:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	job, _, err := c.remote.Jobs.GetJob(step.Log.Key, id, gitlab.WithContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+
+	artifacts := make([]Artifact, 0, 1)
+	if job.ArtifactsFile.Filename != "" {
+		artifacts = append(artifacts, Artifact{
+			Path: job.ArtifactsFile.Filename,
+			Size: int64(job.ArtifactsFile.Size),
+			URL:  job.WebURL + "/artifacts/download",
+		})
+	}
+
+	return artifacts, nil
+}
+
+func (c GitLabClient) DownloadArtifact(ctx context.Context, step Step, a Artifact, w io.Writer) error {
+	id, err := strconv.Atoi(step.ID)
+	if err != nil {
+		return err
+	}
+
+	select {
+	case <-c.rateLimiter:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	archive, _, err := c.remote.Jobs.GetJobArtifacts(step.Log.Key, id, gitlab.WithContext(ctx))
+	if err != nil {
+		return err
+	}
+
+	_, err = io.Copy(w, archive)
+	return err
+}
+
+type gitlabTestReport struct {
+	TestSuites []struct {
+		Name      string  `json:"name"`
+		TotalTime float64 `json:"total_time"`
+		TestCases []struct {
+			Status        string  `json:"status"`
+			Name          string  `json:"name"`
+			Classname     string  `json:"classname"`
+			ExecutionTime float64 `json:"execution_time"`
+			SystemOutput  string  `json:"system_output"`
+		} `json:"test_cases"`
+	} `json:"test_suites"`
+}
+
+// Suffix of the names of parallel jobs, e.g. " 1/3" in "rspec 1/3"
+var gitlabParallelSuffix = regexp.MustCompile(`^ \d+/\d+$`)
+
+func fromGitLabTestStatus(s string) TestStatus {
+	switch s {
+	case "success":
+		return TestPassed
+	case "failed":
+		return TestFailed
+	case "error":
+		return TestErrored
+	default:
+		return TestSkipped
+	}
+}
+
+// GitLab builds the test report of a whole pipeline from the JUnit reports of its jobs and
+// names each test suite after the job it comes from. Parallel jobs ("rspec 1/3") share the
+// suite of their base name.
+func (c GitLabClient) TestReport(ctx context.Context, step Step) (TestReport, error) {
+	if step.Log.Key == "" {
+		return TestReport{}, ErrNoTestReport
+	}
+	id, err := strconv.Atoi(step.ID)
+	if err != nil {
+		return TestReport{}, err
+	}
+
+	select {
+	case <-c.rateLimiter:
+	case <-ctx.Done():
+		return TestReport{}, ctx.Err()
+	}
+	job, _, err := c.remote.Jobs.GetJob(step.Log.Key, id, gitlab.WithContext(ctx))
+	if err != nil {
+		return TestReport{}, err
+	}
+
+	select {
+	case <-c.rateLimiter:
+	case <-ctx.Done():
+		return TestReport{}, ctx.Err()
+	}
+	u := fmt.Sprintf("projects/%s/pipelines/%d/test_report", url.PathEscape(step.Log.Key), job.Pipeline.ID)
+	req, err := c.remote.NewRequest("GET", u, nil, []gitlab.OptionFunc{gitlab.WithContext(ctx)})
+	if err != nil {
+		return TestReport{}, err
+	}
+	var pipelineReport gitlabTestReport
+	resp, err := c.remote.Do(req, &pipelineReport)
+	if err != nil {
+		if resp != nil && resp.StatusCode == 404 {
+			return TestReport{}, ErrNoTestReport
+		}
+		return TestReport{}, err
+	}
+
+	report := TestReport{}
+	for _, suite := range pipelineReport.TestSuites {
+		if suite.Name != job.Name && !gitlabParallelSuffix.MatchString(strings.TrimPrefix(job.Name, suite.Name)) {
+			continue
+		}
+		testSuite := TestSuite{
+			Name: suite.Name,
+			Duration: utils.NullDuration{
+				Valid:    true,
+				Duration: time.Duration(suite.TotalTime * float64(time.Second)),
+			},
+			Cases: make([]TestCase, 0, len(suite.TestCases)),
+		}
+		for _, testCase := range suite.TestCases {
+			testSuite.Cases = append(testSuite.Cases, TestCase{
+				Name:      testCase.Name,
+				ClassName: testCase.Classname,
+				Status:    fromGitLabTestStatus(testCase.Status),
+				Duration: utils.NullDuration{
+					Valid:    true,
+					Duration: time.Duration(testCase.ExecutionTime * float64(time.Second)),
+				},
+				Message: testCase.SystemOutput,
+			})
+		}
+		report.Suites = append(report.Suites, testSuite)
+	}
+	if len(report.Suites) == 0 {
+		return TestReport{}, ErrNoTestReport
+	}
+
+	return report, nil
+}
+
 func (c GitLabClient) fetchJobs(ctx context.Context, slug string, pipelineID int) ([]*gitlab.Job, error) {
 	select {
 	case <-c.rateLimiter:
@@ -326,6 +604,7 @@ func (c GitLabClient) fetchJobs(ctx context.Context, slug string, pipelineID int
 	wg := sync.WaitGroup{}
 	errc := make(chan error)
 	pageCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
 	allPages := make([][]*gitlab.Job, resp.TotalPages)
 	if len(allPages) > 0 {
 		allPages[0] = jobs
@@ -336,8 +615,8 @@ func (c GitLabClient) fetchJobs(ctx context.Context, slug string, pipelineID int
 			defer wg.Done()
 			select {
 			case <-c.rateLimiter:
-			case <-ctx.Done():
-				errc <- ctx.Err()
+			case <-pageCtx.Done():
+				errc <- pageCtx.Err()
 				return
 			}
 			options := gitlab.ListJobsOptions{
@@ -374,7 +653,152 @@ func (c GitLabClient) fetchJobs(ctx context.Context, slug string, pipelineID int
 	return allJobs, err
 }
 
-func (c GitLabClient) fetchPipeline(ctx context.Context, slug string, pipelineID int) (pipeline Pipeline, err error) {
+// Bridge job triggering a downstream pipeline. go-gitlab does not implement the bridges API.
+type gitlabBridge struct {
+	ID                 int                  `json:"id"`
+	Name               string               `json:"name"`
+	Stage              string               `json:"stage"`
+	Status             string               `json:"status"`
+	AllowFailure       bool                 `json:"allow_failure"`
+	CreatedAt          *time.Time           `json:"created_at"`
+	StartedAt          *time.Time           `json:"started_at"`
+	FinishedAt         *time.Time           `json:"finished_at"`
+	Duration           float64              `json:"duration"`
+	WebURL             string               `json:"web_url"`
+	DownstreamPipeline *gitlabDownstreamRef `json:"downstream_pipeline"`
+}
+
+type gitlabDownstreamRef struct {
+	ID        int    `json:"id"`
+	ProjectID int    `json:"project_id"`
+	WebURL    string `json:"web_url"`
+}
+
+// Return the bridge jobs of a pipeline. Versions of GitLab without support for the bridges
+// API result in an empty list.
+func (c GitLabClient) fetchBridges(ctx context.Context, slug string, pipelineID int) ([]gitlabBridge, error) {
+	select {
+	case <-c.rateLimiter:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+
+	u := fmt.Sprintf("projects/%s/pipelines/%d/bridges", url.PathEscape(slug), pipelineID)
+	options := gitlab.ListOptions{PerPage: 100}
+	req, err := c.remote.NewRequest("GET", u, &options, []gitlab.OptionFunc{gitlab.WithContext(ctx)})
+	if err != nil {
+		return nil, err
+	}
+
+	bridges := make([]gitlabBridge, 0)
+	resp, err := c.remote.Do(req, &bridges)
+	if err != nil {
+		if resp != nil && resp.StatusCode == 404 {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return bridges, nil
+}
+
+// Return the slug of the project and the ID of a downstream pipeline
+func (c GitLabClient) downstreamPipeline(ref gitlabDownstreamRef) (string, int, error) {
+	if ref.ProjectID > 0 {
+		return strconv.Itoa(ref.ProjectID), ref.ID, nil
+	}
+	return c.parsePipelineURL(ref.WebURL)
+}
+
+// Build the step of a bridge job. The stages of the downstream pipeline, if any, are attached
+// as children of the step.
+func (c GitLabClient) bridgeStep(ctx context.Context, bridge gitlabBridge, depth int) (Step, error) {
+	step := Step{
+		ID:         strconv.Itoa(bridge.ID),
+		Type:       StepJob,
+		State:      fromGitLabState(bridge.Status),
+		Name:       bridge.Name,
+		CreatedAt:  utils.NullTimeFromTime(bridge.CreatedAt),
+		StartedAt:  utils.NullTimeFromTime(bridge.StartedAt),
+		FinishedAt: utils.NullTimeFromTime(bridge.FinishedAt),
+		Duration: utils.NullDuration{
+			Duration: time.Duration(bridge.Duration * float64(time.Second)),
+			Valid:    bridge.Duration > 0,
+		},
+		WebURL: utils.NullString{
+			String: bridge.WebURL,
+			Valid:  bridge.WebURL != "",
+		},
+		AllowFailure: bridge.AllowFailure,
+	}
+
+	if bridge.DownstreamPipeline == nil || depth >= maxGitLabPipelineDepth {
+		return step, nil
+	}
+	slug, id, err := c.downstreamPipeline(*bridge.DownstreamPipeline)
+	if err != nil {
+		// The downstream pipeline may be hosted on another instance
+		return step, nil
+	}
+	downstream, err := c.fetchPipeline(ctx, slug, id, depth+1)
+	if err != nil {
+		return Step{}, err
+	}
+	step.Children = downstream.Children
+	if !step.WebURL.Valid {
+		step.WebURL = downstream.WebURL
+	}
+
+	return step, nil
+}
+
+// Fetch the variables the pipeline was run with. Reading them requires the maintainer role so
+// pipelines whose variables cannot be read are shown without variables.
+func (c GitLabClient) fetchVariables(ctx context.Context, slug string, pipelineID int) ([]Variable, error) {
+	select {
+	case <-c.rateLimiter:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	gitlabVariables, _, err := c.remote.Pipelines.GetPipelineVariables(slug, pipelineID, gitlab.WithContext(ctx))
+	if err != nil {
+		if e, ok := err.(*gitlab.ErrorResponse); ok && e.Response != nil {
+			switch e.Response.StatusCode {
+			case 401, 403, 404:
+				return nil, nil
+			}
+		}
+		return nil, err
+	}
+
+	var variables []Variable
+	for _, v := range gitlabVariables {
+		variables = append(variables, Variable{
+			Name:  v.Key,
+			Value: v.Value,
+		})
+	}
+
+	return variables, nil
+}
+
+// Return the merge request of a pipeline from its reference. Merge request pipelines run on
+// refs/merge-requests/<iid>/head (or /merge for merged results pipelines).
+func gitLabMergeRequest(ref string) PullRequest {
+	if !strings.HasPrefix(ref, "refs/merge-requests/") {
+		return PullRequest{}
+	}
+	iid := strings.SplitN(strings.TrimPrefix(ref, "refs/merge-requests/"), "/", 2)[0]
+	if _, err := strconv.Atoi(iid); err != nil {
+		return PullRequest{}
+	}
+
+	return PullRequest{Number: iid}
+}
+
+// Fetch a pipeline and its jobs. Downstream pipelines triggered by bridge jobs are followed
+// up to maxGitLabPipelineDepth levels, depth being the level of the pipeline requested.
+func (c GitLabClient) fetchPipeline(ctx context.Context, slug string, pipelineID int, depth int) (pipeline Pipeline, err error) {
 	select {
 	case <-c.rateLimiter:
 	case <-ctx.Done():
@@ -386,8 +810,9 @@ func (c GitLabClient) fetchPipeline(ctx context.Context, slug string, pipelineID
 	}
 
 	pipeline = Pipeline{
-		Ref:   gitlabPipeline.Ref,
-		IsTag: gitlabPipeline.Tag,
+		Ref:         gitlabPipeline.Ref,
+		IsTag:       gitlabPipeline.Tag,
+		PullRequest: gitLabMergeRequest(gitlabPipeline.Ref),
 		Step: Step{
 			ID:         strconv.Itoa(gitlabPipeline.ID),
 			Type:       StepPipeline,
@@ -406,21 +831,43 @@ func (c GitLabClient) fetchPipeline(ctx context.Context, slug string, pipelineID
 			},
 		},
 	}
+	if gitlabPipeline.User != nil {
+		pipeline.Author = gitlabPipeline.User.Username
+	}
+	if coverage, err := strconv.ParseFloat(gitlabPipeline.Coverage, 64); err == nil {
+		pipeline.Coverage = utils.NullFloat64{Valid: true, Float64: coverage}
+	}
+	if pipeline.Variables, err = c.fetchVariables(ctx, slug, gitlabPipeline.ID); err != nil {
+		return Pipeline{}, err
+	}
 
 	jobs, err := c.fetchJobs(ctx, slug, gitlabPipeline.ID)
 	if err != nil {
 		return Pipeline{}, err
 	}
 
-	stagesIndexByName := make(map[string]int)
+	bridges, err := c.fetchBridges(ctx, slug, gitlabPipeline.ID)
+	if err != nil {
+		return Pipeline{}, err
+	}
+
+	stageNames := make([]string, 0, len(jobs)+len(bridges))
 	for _, job := range jobs {
-		if _, exists := stagesIndexByName[job.Stage]; !exists {
+		stageNames = append(stageNames, job.Stage)
+	}
+	for _, bridge := range bridges {
+		stageNames = append(stageNames, bridge.Stage)
+	}
+
+	stagesIndexByName := make(map[string]int)
+	for _, stageName := range stageNames {
+		if _, exists := stagesIndexByName[stageName]; !exists {
 			stage := Step{
 				ID:   strconv.Itoa(len(stagesIndexByName) + 1),
 				Type: StepStage,
-				Name: job.Stage,
+				Name: stageName,
 			}
-			stagesIndexByName[job.Stage] = len(pipeline.Children)
+			stagesIndexByName[stageName] = len(pipeline.Children)
 			pipeline.Children = append(pipeline.Children, stage)
 		}
 	}
@@ -446,12 +893,31 @@ func (c GitLabClient) fetchPipeline(ctx context.Context, slug string, pipelineID
 				Valid:  true,
 			},
 			AllowFailure: gitlabJob.AllowFailure,
+			// go-gitlab reads the coverage of jobs without coverage as zero
+			Coverage: utils.NullFloat64{
+				Valid:   gitlabJob.Coverage > 0,
+				Float64: gitlabJob.Coverage,
+			},
+			Runner: gitlabJob.Runner.Description,
+		}
+		if job.Runner == "" {
+			job.Runner = gitlabJob.Runner.Name
 		}
 
 		index := stagesIndexByName[gitlabJob.Stage]
 		pipeline.Children[index].Children = append(pipeline.Children[index].Children, job)
 	}
 
+	for _, bridge := range bridges {
+		job, err := c.bridgeStep(ctx, bridge, depth)
+		if err != nil {
+			return Pipeline{}, err
+		}
+
+		index := stagesIndexByName[bridge.Stage]
+		pipeline.Children[index].Children = append(pipeline.Children[index].Children, job)
+	}
+
 	// Compute stage state
 	for i, stage := range pipeline.Children {
 		// Each stage contains all job runs. Select only the last run of each job
@@ -481,3 +947,121 @@ func (c GitLabClient) fetchPipeline(ctx context.Context, slug string, pipelineID
 
 	return pipeline, nil
 }
+
+func (c GitLabClient) ConfigurationFiles() []string {
+	return []string{".gitlab-ci.yml"}
+}
+
+// Validate the content of a .gitlab-ci.yml file with the CI Lint API of the GitLab instance
+func (c GitLabClient) Lint(ctx context.Context, content string) ([]string, error) {
+	select {
+	case <-c.rateLimiter:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	result, _, err := c.remote.Validate.Lint(content, gitlab.WithContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+	if result.Status == "valid" {
+		return nil, nil
+	}
+	if len(result.Errors) == 0 {
+		return []string{fmt.Sprintf("configuration is %s", result.Status)}, nil
+	}
+
+	return result.Errors, nil
+}
+
+type gitlabMergedConfiguration struct {
+	Valid      bool     `json:"valid"`
+	MergedYaml string   `json:"merged_yaml"`
+	Errors     []string `json:"errors"`
+}
+
+type gitlabMergedConfigurationOptions struct {
+	ContentRef string `url:"content_ref"`
+	// Name of the parameter before GitLab 13.10
+	Sha string `url:"sha"`
+}
+
+// Return the content of .gitlab-ci.yml at the commit of the pipeline 'p' after includes have
+// been merged. Versions of GitLab without support for project linting result in
+// ErrNoConfiguration.
+func (c GitLabClient) Configuration(ctx context.Context, p Pipeline) (string, error) {
+	if !p.WebURL.Valid {
+		return "", ErrUnknownPipelineURL
+	}
+	slug, _, err := c.parsePipelineURL(p.WebURL.String)
+	if err != nil {
+		return "", err
+	}
+	ref := p.Sha
+	if ref == "" {
+		ref = p.Ref
+	}
+
+	select {
+	case <-c.rateLimiter:
+	case <-ctx.Done():
+		return "", ctx.Err()
+	}
+
+	u := fmt.Sprintf("projects/%s/ci/lint", url.PathEscape(slug))
+	options := gitlabMergedConfigurationOptions{ContentRef: ref, Sha: ref}
+	req, err := c.remote.NewRequest("GET", u, &options, []gitlab.OptionFunc{gitlab.WithContext(ctx)})
+	if err != nil {
+		return "", err
+	}
+
+	var configuration gitlabMergedConfiguration
+	resp, err := c.remote.Do(req, &configuration)
+	if err != nil {
+		if resp != nil && resp.StatusCode == 404 {
+			return "", ErrNoConfiguration
+		}
+		return "", err
+	}
+	if configuration.MergedYaml == "" {
+		if len(configuration.Errors) > 0 {
+			return "", fmt.Errorf("invalid configuration: %s", strings.Join(configuration.Errors, ", "))
+		}
+		return "", ErrNoConfiguration
+	}
+
+	return configuration.MergedYaml, nil
+}
+
+// Create a new pipeline for the git reference of 'p' with 'variables'
+func (c GitLabClient) Rerun(ctx context.Context, p Pipeline, variables []Variable) (string, error) {
+	if !p.WebURL.Valid {
+		return "", ErrUnknownPipelineURL
+	}
+	slug, _, err := c.parsePipelineURL(p.WebURL.String)
+	if err != nil {
+		return "", err
+	}
+
+	opt := gitlab.CreatePipelineOptions{
+		Ref: gitlab.String(p.Ref),
+	}
+	for _, v := range variables {
+		opt.Variables = append(opt.Variables, &gitlab.PipelineVariable{
+			Key:          v.Name,
+			Value:        v.Value,
+			VariableType: "env_var",
+		})
+	}
+
+	select {
+	case <-c.rateLimiter:
+	case <-ctx.Done():
+		return "", ctx.Err()
+	}
+	pipeline, _, err := c.remote.Pipelines.CreatePipeline(slug, &opt, gitlab.WithContext(ctx))
+	if err != nil {
+		return "", err
+	}
+
+	return pipeline.WebURL, nil
+}
@@ -8,6 +8,7 @@ import (
 	"net/http/httptest"
 	"path"
 	"sort"
+	"strings"
 	"testing"
 	"time"
 
@@ -41,6 +42,12 @@ func TestParsePipelineURL(t *testing.T) {
 			expectedSlug: "long/namespace/nbedos/cistern",
 			expectedID:   97604657,
 		},
+		{
+			name:         "pipeline path with dash separator",
+			url:          "https://gitlab.com/namespace/nbedos/cistern/-/pipelines/97604657",
+			expectedSlug: "namespace/nbedos/cistern",
+			expectedID:   97604657,
+		},
 	}
 
 	for _, testCase := range testCases {
@@ -64,6 +71,60 @@ func TestParsePipelineURL(t *testing.T) {
 	}
 }
 
+func TestGitLabClient_HostsRepository(t *testing.T) {
+	gitlabCom, err := NewGitLabClient("gitlab-0", "gitlab", "", "", 1000, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	selfHosted, err := NewGitLabClient("gitlab-1", "gitlab", "https://gitlab.example.com", "", 1000, "ssh.example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testCases := []struct {
+		url        string
+		gitlabCom  bool
+		selfHosted bool
+	}{
+		{
+			url:        "https://gitlab.com/nbedos/cistern",
+			gitlabCom:  true,
+			selfHosted: false,
+		},
+		{
+			url:        "git@gitlab.com:nbedos/cistern.git",
+			gitlabCom:  true,
+			selfHosted: false,
+		},
+		{
+			url:        "https://gitlab.example.com/nbedos/cistern",
+			gitlabCom:  false,
+			selfHosted: true,
+		},
+		{
+			url:        "git@ssh.example.com:nbedos/cistern.git",
+			gitlabCom:  false,
+			selfHosted: true,
+		},
+		{
+			url:        "https://github.com/nbedos/cistern",
+			gitlabCom:  false,
+			selfHosted: false,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.url, func(t *testing.T) {
+			if hosted := gitlabCom.HostsRepository(testCase.url); hosted != testCase.gitlabCom {
+				t.Fatalf("expected %v for gitlab.com but got %v", testCase.gitlabCom, hosted)
+			}
+			if hosted := selfHosted.HostsRepository(testCase.url); hosted != testCase.selfHosted {
+				t.Fatalf("expected %v for self-hosted instance but got %v", testCase.selfHosted, hosted)
+			}
+		})
+	}
+}
+
 func setupGitLabTestServer() (GitLabClient, string, func(), error) {
 	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		filename := ""
@@ -74,16 +135,69 @@ func setupGitLabTestServer() (GitLabClient, string, func(), error) {
 		case "/api/v4/projects/long/namespace/nbedos/cistern/pipelines/103230300/jobs":
 			w.Header().Add("X-Total-Pages", "1")
 			filename = "gitlab_jobs.json"
+		case "/api/v4/projects/long/namespace/nbedos/cistern/pipelines/103230400":
+			filename = "gitlab_upstream_pipeline.json"
+		case "/api/v4/projects/long/namespace/nbedos/cistern/pipelines/103230400/jobs",
+			"/api/v4/projects/42/pipelines/103230500/jobs":
+			w.Header().Add("X-Total-Pages", "1")
+			filename = "gitlab_jobs.json"
+		case "/api/v4/projects/long/namespace/nbedos/cistern/pipelines/103230400/bridges":
+			filename = "gitlab_bridges.json"
+		case "/api/v4/projects/42/pipelines/103230500":
+			filename = "gitlab_downstream_pipeline.json"
 		case "/api/v4/projects/long/namespace/nbedos/cistern/jobs/42/trace":
 			filename = "gitlab_log"
+		case "/api/v4/projects/long/namespace/nbedos/cistern/jobs/42":
+			fmt.Fprint(w, `{"id": 42, "name": "go-test 2/4", "pipeline": {"id": 103230300}, "web_url": "https://gitlab.com/nbedos/cistern/-/jobs/42", "artifacts_file": {"filename": "artifacts.zip", "size": 1024}}`)
+			return
+		case "/api/v4/projects/long/namespace/nbedos/cistern/pipelines/103230300/test_report":
+			filename = "gitlab_test_report.json"
+		case "/api/v4/projects/long/namespace/nbedos/cistern/jobs/42/artifacts":
+			fmt.Fprint(w, "archive")
+			return
 		case "/api/v4/projects/long/namespace/owner/repo/repository/commits/master":
 			filename = "gitlab_commit.json"
 		case "/api/v4/projects/long/namespace/owner/repo/repository/commits/a24840cf94b395af69da4a1001d32e3694637e20/refs":
 			filename = "gitlab_refs.json"
 		case "/api/v4/projects/long/namespace/nbedos/cistern/pipelines":
+			if after := r.URL.Query().Get("updated_after"); after != "" {
+				// Pipeline 103230300 was last updated at 2019-12-15T21:48:13.077Z
+				t, err := time.Parse(time.RFC3339Nano, after)
+				if err != nil {
+					w.WriteHeader(400)
+					return
+				}
+				if t.Before(time.Date(2019, 12, 15, 21, 48, 13, 77000000, time.UTC)) {
+					fmt.Fprint(w, `[{"id": 103230300}]`)
+				} else {
+					fmt.Fprint(w, `[]`)
+				}
+				return
+			}
 			filename = "gitlab_pipelines.json"
 		case "/api/v4/projects/long/namespace/nbedos/cistern/repository/commits/a24840cf94b395af69da4a1001d32e3694637e20/statuses":
 			filename = "gitlab_statuses.json"
+		case "/api/v4/projects/long/namespace/nbedos/cistern/pipelines/103230300/variables":
+			filename = "gitlab_pipeline_variables.json"
+		case "/api/v4/projects/long/namespace/nbedos/cistern/pipeline":
+			bs, err := ioutil.ReadAll(r.Body)
+			if err != nil || r.Method != http.MethodPost || !strings.Contains(string(bs), `"ref":"master"`) {
+				w.WriteHeader(400)
+				return
+			}
+			w.WriteHeader(201)
+			filename = "gitlab_pipeline.json"
+		case "/api/v4/projects/long/namespace/nbedos/cistern/ci/lint":
+			if r.URL.Query().Get("content_ref") != "a24840cf94b395af69da4a1001d32e3694637e20" {
+				w.WriteHeader(400)
+				return
+			}
+			filename = "gitlab_merged_yaml.json"
+		case "/api/v4/ci/lint":
+			filename = "gitlab_lint_valid.json"
+			if bs, err := ioutil.ReadAll(r.Body); err != nil || strings.Contains(string(bs), "invalid") {
+				filename = "gitlab_lint_invalid.json"
+			}
 		default:
 			w.WriteHeader(404)
 			return
@@ -130,7 +244,8 @@ func TestGitLabClient_BuildFromURL(t *testing.T) {
 		t.Fatal(err)
 	}
 	expectedPipeline := Pipeline{
-		Ref: "master",
+		Ref:    "master",
+		Author: "nbedos",
 		Step: Step{
 			ID:           "103230300",
 			Name:         "",
@@ -161,6 +276,14 @@ func TestGitLabClient_BuildFromURL(t *testing.T) {
 				Valid:  true,
 				String: "https://gitlab.com/long/namespace/nbedos/cistern/pipelines/103230300",
 			},
+			Variables: []Variable{
+				{Name: "RUN_NIGHTLY_BUILD", Value: "true"},
+				{Name: "DEPLOY_TOKEN", Value: "abcd"},
+			},
+			Coverage: utils.NullFloat64{
+				Valid:   true,
+				Float64: 87.5,
+			},
 			Children: []Step{
 				{
 					ID:    "1",
@@ -211,6 +334,7 @@ func TestGitLabClient_BuildFromURL(t *testing.T) {
 							},
 							WebURL: utils.NullString{Valid: true, String: "https://gitlab.com/long/namespace/nbedos/cistern/-/jobs/379869167"},
 							Log:    Log{Key: "long/namespace/nbedos/cistern"},
+							Runner: "shared-runners-manager-4.gitlab.com",
 						},
 					},
 				},
@@ -222,6 +346,113 @@ func TestGitLabClient_BuildFromURL(t *testing.T) {
 	}
 }
 
+func TestGitLabClient_BuildFromURLSince(t *testing.T) {
+	client, testURL, teardown, err := setupGitLabTestServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer teardown()
+
+	pipelineURL := testURL + "/long/namespace/nbedos/cistern/pipelines/103230300"
+	pipeline, version, err := client.BuildFromURLSince(context.Background(), pipelineURL, SourceVersion{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pipeline.ID != "103230300" {
+		t.Fatalf("expected pipeline 103230300 but got %q", pipeline.ID)
+	}
+	if expected := "2019-12-15T21:48:13.077Z"; version.LastModified != expected {
+		t.Fatalf("expected version %q but got %q", expected, version.LastModified)
+	}
+
+	// The second poll sends the update time of the pipeline as updated_after
+	if _, _, err := client.BuildFromURLSince(context.Background(), pipelineURL, version); err != ErrNotModified {
+		t.Fatalf("expected %v but got %v", ErrNotModified, err)
+	}
+
+	older := SourceVersion{LastModified: "2019-12-15T21:00:00Z"}
+	if pipeline, _, err = client.BuildFromURLSince(context.Background(), pipelineURL, older); err != nil {
+		t.Fatal(err)
+	}
+	if pipeline.ID != "103230300" {
+		t.Fatalf("expected pipeline 103230300 but got %q", pipeline.ID)
+	}
+
+	t.Run("pipelines with downstream pipelines have no version", func(t *testing.T) {
+		u := testURL + "/long/namespace/nbedos/cistern/pipelines/103230400"
+		_, version, err := client.BuildFromURLSince(context.Background(), u, SourceVersion{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !version.IsZero() {
+			t.Fatalf("expected no version but got %+v", version)
+		}
+	})
+}
+
+func TestGitLabClient_BuildFromURLDownstream(t *testing.T) {
+	client, testURL, teardown, err := setupGitLabTestServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer teardown()
+
+	pipelineURL := testURL + "/long/namespace/nbedos/cistern/-/pipelines/103230400"
+	pipeline, err := client.BuildFromURL(context.Background(), pipelineURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(pipeline.Children) != 2 || pipeline.Children[1].Name != "deploy" {
+		t.Fatalf("expected stages 'test' and 'deploy' but got %+v", pipeline.Children)
+	}
+	deploy := pipeline.Children[1]
+	if len(deploy.Children) != 1 {
+		t.Fatalf("expected a single bridge job but got %+v", deploy.Children)
+	}
+
+	bridge := deploy.Children[0]
+	expectedBridge := Step{
+		ID:    "379869200",
+		Name:  "trigger child",
+		Type:  StepJob,
+		State: Passed,
+		CreatedAt: utils.NullTime{
+			Valid: true,
+			Time:  time.Date(2019, 12, 15, 21, 48, 13, 100000000, time.UTC),
+		},
+		StartedAt: utils.NullTime{
+			Valid: true,
+			Time:  time.Date(2019, 12, 15, 21, 48, 13, 200000000, time.UTC),
+		},
+		FinishedAt: utils.NullTime{
+			Valid: true,
+			Time:  time.Date(2019, 12, 15, 21, 50, 1, 200000000, time.UTC),
+		},
+		Duration: utils.NullDuration{
+			Valid:    true,
+			Duration: time.Minute + 48*time.Second,
+		},
+		WebURL: utils.NullString{
+			Valid:  true,
+			String: "https://gitlab.com/long/namespace/nbedos/cistern/-/jobs/379869200",
+		},
+	}
+	children := bridge.Children
+	bridge.Children = nil
+	if diff := expectedBridge.Diff(bridge); len(diff) > 0 {
+		t.Fatal(diff)
+	}
+
+	// Stages of the downstream pipeline are attached to the bridge job
+	if len(children) != 1 || children[0].Type != StepStage || children[0].Name != "test" {
+		t.Fatalf("expected downstream stage 'test' but got %+v", children)
+	}
+	if jobs := children[0].Children; len(jobs) != 1 || jobs[0].ID != "379869167" || jobs[0].Log.Key != "42" {
+		t.Fatalf("unexpected downstream jobs: %+v", jobs)
+	}
+}
+
 func TestGitLabClient_Log(t *testing.T) {
 	client, _, teardown, err := setupGitLabTestServer()
 	if err != nil {
@@ -245,6 +476,90 @@ func TestGitLabClient_Log(t *testing.T) {
 	}
 }
 
+func TestGitLabClient_Artifacts(t *testing.T) {
+	client, _, teardown, err := setupGitLabTestServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer teardown()
+
+	step := Step{
+		ID:   "42",
+		Type: StepJob,
+		Log: Log{
+			Key: "long/namespace/nbedos/cistern",
+		},
+	}
+	artifacts, err := client.Artifacts(context.Background(), step)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []Artifact{
+		{
+			Path: "artifacts.zip",
+			Size: 1024,
+			URL:  "https://gitlab.com/nbedos/cistern/-/jobs/42/artifacts/download",
+		},
+	}
+	if diff := cmp.Diff(expected, artifacts); len(diff) > 0 {
+		t.Fatal(diff)
+	}
+
+	content := new(strings.Builder)
+	if err := client.DownloadArtifact(context.Background(), step, artifacts[0], content); err != nil {
+		t.Fatal(err)
+	}
+	if content.String() != "archive" {
+		t.Fatalf("expected %q but got %q", "archive", content.String())
+	}
+}
+
+func TestGitLabClient_TestReport(t *testing.T) {
+	client, _, teardown, err := setupGitLabTestServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer teardown()
+
+	step := Step{
+		ID:   "42",
+		Type: StepJob,
+		Log: Log{
+			Key: "long/namespace/nbedos/cistern",
+		},
+	}
+	report, err := client.TestReport(context.Background(), step)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := TestReport{
+		Suites: []TestSuite{
+			{
+				Name:     "go-test",
+				Duration: utils.NullDuration{Valid: true, Duration: 1500 * time.Millisecond},
+				Cases: []TestCase{
+					{
+						Name:      "TestCache",
+						ClassName: "providers",
+						Status:    TestPassed,
+						Duration:  utils.NullDuration{Valid: true, Duration: time.Second},
+					},
+					{
+						Name:      "TestLog",
+						ClassName: "providers",
+						Status:    TestFailed,
+						Duration:  utils.NullDuration{Valid: true, Duration: 500 * time.Millisecond},
+						Message:   "cache_test.go:42: expected 1 but got 2",
+					},
+				},
+			},
+		},
+	}
+	if diff := cmp.Diff(expected, report); len(diff) > 0 {
+		t.Fatal(diff)
+	}
+}
+
 func TestGitLabClient_Commit(t *testing.T) {
 	t.Run("existing reference", func(t *testing.T) {
 		client, testURL, teardown, err := setupGitLabTestServer()
@@ -254,14 +569,15 @@ func TestGitLabClient_Commit(t *testing.T) {
 		defer teardown()
 
 		expectedCommit := Commit{
-			Sha:      "a24840cf94b395af69da4a1001d32e3694637e20",
-			Author:   "nbedos <nicolas.bedos@gmail.com>",
-			Date:     time.Date(2019, 12, 16, 18, 6, 43, 0, time.UTC),
-			Message:  "Fix typos\n",
-			Branches: []string{"master"},
-			Tags:     nil,
-			Head:     "",
-			Statuses: nil,
+			Sha:       "a24840cf94b395af69da4a1001d32e3694637e20",
+			Author:    "nbedos <nicolas.bedos@gmail.com>",
+			Committer: "nbedos <nicolas.bedos@gmail.com>",
+			Date:      time.Date(2019, 12, 16, 18, 6, 43, 0, time.UTC),
+			Message:   "Fix typos\n",
+			Branches:  []string{"master"},
+			Tags:      nil,
+			Head:      "",
+			Statuses:  nil,
 		}
 
 		for _, repoURL := range []string{testURL, client.sshHostname} {
@@ -313,3 +629,102 @@ func TestGitLabClient_RefStatuses(t *testing.T) {
 		t.Fatal(diff)
 	}
 }
+
+func TestGitLabClient_Lint(t *testing.T) {
+	client, _, teardown, err := setupGitLabTestServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer teardown()
+
+	t.Run("valid configuration", func(t *testing.T) {
+		errs, err := client.Lint(context.Background(), "test:\n  script: make test\n")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(errs) > 0 {
+			t.Fatalf("expected no error but got %v", errs)
+		}
+	})
+
+	t.Run("invalid configuration", func(t *testing.T) {
+		errs, err := client.Lint(context.Background(), "test:\n  invalid: true\n")
+		if err != nil {
+			t.Fatal(err)
+		}
+		expected := []string{"jobs:test config contains unknown keys: invalid"}
+		if diff := cmp.Diff(expected, errs); len(diff) > 0 {
+			t.Fatal(diff)
+		}
+	})
+}
+
+func TestGitLabClient_Rerun(t *testing.T) {
+	client, testURL, teardown, err := setupGitLabTestServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer teardown()
+
+	p := Pipeline{
+		Ref: "master",
+		Step: Step{
+			WebURL: utils.NullString{
+				Valid:  true,
+				String: testURL + "/long/namespace/nbedos/cistern/pipelines/103230300",
+			},
+		},
+	}
+	variables := []Variable{{Name: "DEPLOY", Value: "false"}}
+
+	u, err := client.Rerun(context.Background(), p, variables)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := "https://gitlab.com/long/namespace/nbedos/cistern/pipelines/103230300"; u != expected {
+		t.Fatalf("expected %q but got %q", expected, u)
+	}
+}
+
+func TestGitLabClient_Configuration(t *testing.T) {
+	client, testURL, teardown, err := setupGitLabTestServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer teardown()
+
+	p := Pipeline{
+		Ref: "master",
+		Sha: "a24840cf94b395af69da4a1001d32e3694637e20",
+		Step: Step{
+			WebURL: utils.NullString{
+				Valid:  true,
+				String: testURL + "/long/namespace/nbedos/cistern/pipelines/103230300",
+			},
+		},
+	}
+
+	configuration, err := client.Configuration(context.Background(), p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "---\ntest:\n  stage: test\n  script:\n  - go test ./...\n"
+	if configuration != expected {
+		t.Fatalf("expected %q but got %q", expected, configuration)
+	}
+}
+
+func TestGitLabMergeRequest(t *testing.T) {
+	testCases := map[string]PullRequest{
+		"master":                          {},
+		"refs/merge-requests/42/head":     {Number: "42"},
+		"refs/merge-requests/42/merge":    {Number: "42"},
+		"refs/merge-requests/feature/abc": {},
+	}
+
+	for ref, expected := range testCases {
+		if pr := gitLabMergeRequest(ref); pr != expected {
+			t.Errorf("%s: expected %+v but got %+v", ref, expected, pr)
+		}
+	}
+}
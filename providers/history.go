@@ -0,0 +1,142 @@
+package providers
+
+import (
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/nbedos/cistern/utils"
+)
+
+// Number of runs of each job kept in the history of the cache
+const maxJobRuns = 20
+
+// Number of changes between passing and failing runs of a job from which the job is
+// considered flaky
+const flakyTransitions = 3
+
+// Identifies a job across the pipelines of a provider
+type jobKey struct {
+	providerHost string
+	// Names of the pipeline, stages and job separated by "/"
+	path string
+}
+
+// Outcome of one run of a job
+type jobRun struct {
+	pipeline PipelineKey
+	sha      string
+	state    State
+	at       time.Time
+}
+
+// Call f for each job of the step with the names of the steps leading to the job
+func walkJobs(s Step, path []string, f func(path []string, job Step)) {
+	path = append(path[:len(path):len(path)], s.Name)
+	if s.Type == StepJob {
+		f(path, s)
+	}
+	for _, child := range s.Children {
+		walkJobs(child, path, f)
+	}
+}
+
+// Add the passing and failing jobs of the pipeline to the history of the cache. The history
+// is kept when the pipeline is evicted from the cache. The caller must hold c.mutex.
+func (c *Cache) recordJobRuns(sha string, p Pipeline) {
+	walkJobs(p.Step, nil, func(path []string, job Step) {
+		if job.State != Passed && job.State != Failed {
+			return
+		}
+		key := jobKey{providerHost: p.ProviderHost, path: strings.Join(path, "/")}
+		run := jobRun{
+			pipeline: p.Key(),
+			sha:      sha,
+			state:    job.State,
+			at:       utils.MinNullTime(job.StartedAt, job.CreatedAt, p.CreatedAt).Time,
+		}
+
+		runs := c.jobRuns[key][:0:0]
+		for _, r := range c.jobRuns[key] {
+			if r.pipeline != run.pipeline {
+				runs = append(runs, r)
+			}
+		}
+		runs = append(runs, run)
+		sort.SliceStable(runs, func(i, j int) bool {
+			return runs[i].at.Before(runs[j].at)
+		})
+		if len(runs) > maxJobRuns {
+			runs = runs[len(runs)-maxJobRuns:]
+		}
+		c.jobRuns[key] = runs
+	})
+}
+
+// A job is flaky if it both failed and passed on the same commit or if its outcome keeps
+// changing from one run to the next. Runs must be sorted by date.
+func isFlaky(runs []jobRun) bool {
+	stateBySha := make(map[string]State)
+	transitions := 0
+	for i, run := range runs {
+		if run.sha != "" {
+			if state, exists := stateBySha[run.sha]; exists && state != run.state {
+				return true
+			}
+			stateBySha[run.sha] = run.state
+		}
+		if i > 0 && runs[i-1].state != run.state {
+			transitions++
+		}
+	}
+
+	return transitions >= flakyTransitions
+}
+
+// Return a copy of the step where flaky jobs are marked as such. The caller must hold c.mutex.
+func (c *Cache) withFlakyJobs(providerHost string, s Step, path []string) Step {
+	path = append(path[:len(path):len(path)], s.Name)
+	if s.Type == StepJob {
+		s.Flaky = isFlaky(c.jobRuns[jobKey{providerHost: providerHost, path: strings.Join(path, "/")}])
+	}
+	if len(s.Children) > 0 {
+		children := make([]Step, 0, len(s.Children))
+		for _, child := range s.Children {
+			children = append(children, c.withFlakyJobs(providerHost, child, path))
+		}
+		s.Children = children
+	}
+
+	return s
+}
+
+// Return the coverage of the pipeline minus the coverage of the most recent pipeline of the
+// same branch and provider created before it. Pipelines of tags are compared with the most
+// recent pipeline of another tag instead, i.e. the previous release. The result is not valid
+// if either coverage is unknown. The caller must hold c.mutex.
+func (c *Cache) coverageDelta(p Pipeline) utils.NullFloat64 {
+	if !p.Coverage.Valid || !p.CreatedAt.Valid {
+		return utils.NullFloat64{}
+	}
+
+	var previous *Pipeline
+	for _, q := range c.pipelineByKey {
+		if q == nil || q.providerID != p.providerID || q.IsTag != p.IsTag || (!p.IsTag && q.Ref != p.Ref) {
+			continue
+		}
+		if !q.Coverage.Valid || !q.CreatedAt.Valid || !q.CreatedAt.Time.Before(p.CreatedAt.Time) {
+			continue
+		}
+		if previous == nil || q.CreatedAt.Time.After(previous.CreatedAt.Time) {
+			previous = q
+		}
+	}
+	if previous == nil {
+		return utils.NullFloat64{}
+	}
+
+	return utils.NullFloat64{
+		Valid:   true,
+		Float64: p.Coverage.Float64 - previous.Coverage.Float64,
+	}
+}
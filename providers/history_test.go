@@ -0,0 +1,164 @@
+package providers
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nbedos/cistern/utils"
+)
+
+func TestIsFlaky(t *testing.T) {
+	runs := func(shas string, states ...State) []jobRun {
+		rs := make([]jobRun, 0, len(states))
+		for i, state := range states {
+			rs = append(rs, jobRun{
+				sha:   string(shas[i]),
+				state: state,
+			})
+		}
+		return rs
+	}
+
+	testCases := []struct {
+		name  string
+		runs  []jobRun
+		flaky bool
+	}{
+		{
+			name:  "no run",
+			runs:  nil,
+			flaky: false,
+		},
+		{
+			name:  "always passing",
+			runs:  runs("abc", Passed, Passed, Passed),
+			flaky: false,
+		},
+		{
+			name:  "fixed on the next commit",
+			runs:  runs("abc", Passed, Failed, Passed),
+			flaky: false,
+		},
+		{
+			name:  "failed then passed on the same commit",
+			runs:  runs("abb", Passed, Failed, Passed),
+			flaky: true,
+		},
+		{
+			name:  "alternating results",
+			runs:  runs("abcd", Passed, Failed, Passed, Failed),
+			flaky: true,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			if flaky := isFlaky(testCase.runs); flaky != testCase.flaky {
+				t.Fatalf("expected %v but got %v", testCase.flaky, flaky)
+			}
+		})
+	}
+}
+
+func TestCache_FlakyJobs(t *testing.T) {
+	c := NewCache(nil, nil, utils.PollingStrategy{})
+	c.SaveCommit("master", Commit{Sha: "sha"})
+
+	at := time.Date(2020, 1, 1, 12, 0, 0, 0, time.UTC)
+	for i, state := range []State{Failed, Passed} {
+		p := Pipeline{
+			ProviderHost: "host",
+			Step: Step{
+				ID:        strconv.Itoa(i),
+				Type:      StepPipeline,
+				State:     state,
+				CreatedAt: utils.NullTime{Valid: true, Time: at.Add(time.Duration(i) * time.Hour)},
+				Children: []Step{
+					{ID: "1", Name: "test", Type: StepJob, State: state},
+					{ID: "2", Name: "lint", Type: StepJob, State: Passed},
+				},
+			},
+		}
+		if _, err := c.SavePipeline("sha", p); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	pipelines := c.Pipelines("master")
+	if len(pipelines) != 2 {
+		t.Fatalf("expected 2 pipelines but got %d", len(pipelines))
+	}
+	for _, p := range pipelines {
+		if !p.Children[0].Flaky {
+			t.Fatalf("job %q of pipeline %s should be flaky", p.Children[0].Name, p.ID)
+		}
+		if p.Children[1].Flaky {
+			t.Fatalf("job %q of pipeline %s should not be flaky", p.Children[1].Name, p.ID)
+		}
+	}
+
+	saved, _ := c.Pipeline(pipelines[0].Key())
+	if saved.Children[0].Flaky {
+		t.Fatal("pipelines stored in cache must not be modified")
+	}
+}
+
+func TestCache_CoverageDelta(t *testing.T) {
+	c := NewCache(nil, nil, utils.PollingStrategy{})
+	c.SaveCommit("master", Commit{Sha: "sha3"})
+
+	at := time.Date(2020, 1, 1, 12, 0, 0, 0, time.UTC)
+	pipeline := func(id int, sha string, ref string, coverage float64) {
+		p := Pipeline{
+			ProviderHost: "host",
+			Ref:          ref,
+			IsTag:        strings.HasPrefix(ref, "v"),
+			Step: Step{
+				ID:        strconv.Itoa(id),
+				Type:      StepPipeline,
+				State:     Passed,
+				CreatedAt: utils.NullTime{Valid: true, Time: at.Add(time.Duration(id) * time.Hour)},
+				Coverage:  utils.NullFloat64{Valid: true, Float64: coverage},
+			},
+		}
+		if _, err := c.SavePipeline(sha, p); err != nil {
+			t.Fatal(err)
+		}
+	}
+	pipeline(1, "sha1", "master", 80)
+	pipeline(2, "sha2", "master", 82.5)
+	// Pipelines of other branches are not compared
+	pipeline(3, "sha2", "feature", 50)
+	pipeline(4, "sha3", "master", 81.5)
+	// Pipelines of tags are compared with the pipeline of the previous tag
+	pipeline(5, "sha4", "v1.0", 70)
+	pipeline(6, "sha5", "v1.1", 72.5)
+
+	pipelines := c.Pipelines("master")
+	if len(pipelines) != 1 {
+		t.Fatalf("expected 1 pipeline but got %d", len(pipelines))
+	}
+	expected := utils.NullFloat64{Valid: true, Float64: -1}
+	if pipelines[0].CoverageDelta != expected {
+		t.Fatalf("expected %v but got %v", expected, pipelines[0].CoverageDelta)
+	}
+
+	tags := c.TagPipelines()
+	if len(tags) != 2 {
+		t.Fatalf("expected 2 tag pipelines but got %d", len(tags))
+	}
+	if tags[0].CoverageDelta.Valid {
+		t.Fatalf("expected no coverage delta for the first tag but got %v", tags[0].CoverageDelta)
+	}
+	expected = utils.NullFloat64{Valid: true, Float64: 2.5}
+	if tags[1].CoverageDelta != expected {
+		t.Fatalf("expected %v but got %v", expected, tags[1].CoverageDelta)
+	}
+
+	first, _ := c.Pipeline(PipelineKey{ProviderHost: "host", ID: "1"})
+	if first.CoverageDelta.Valid {
+		t.Fatalf("expected no coverage delta for the first pipeline but got %v", first.CoverageDelta)
+	}
+}
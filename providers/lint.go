@@ -0,0 +1,69 @@
+package providers
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// CI providers implementing Linter can validate their configuration files before they are
+// pushed
+type Linter interface {
+	// Paths of the configuration files of the provider relative to the root of the repository
+	ConfigurationFiles() []string
+	// Return the errors found in the configuration file whose content is 'content'. The list is
+	// empty if the configuration is valid.
+	Lint(ctx context.Context, content string) ([]string, error)
+}
+
+type LintResult struct {
+	// Path of the configuration file relative to the root of the repository
+	Path       string
+	ProviderID string
+	Errors     []string
+}
+
+func (r LintResult) Valid() bool {
+	return len(r.Errors) == 0
+}
+
+// Validate the configuration files found in the directory 'dir' with every CI provider able to
+// do so. Files missing from the directory are ignored. Results are sorted by path and provider.
+func (c Cache) LintConfiguration(ctx context.Context, dir string) ([]LintResult, error) {
+	results := make([]LintResult, 0)
+	for id, provider := range c.ciProvidersByID {
+		linter, ok := provider.(Linter)
+		if !ok {
+			continue
+		}
+		for _, path := range linter.ConfigurationFiles() {
+			bs, err := ioutil.ReadFile(filepath.Join(dir, filepath.FromSlash(path)))
+			if err != nil {
+				if os.IsNotExist(err) {
+					continue
+				}
+				return nil, err
+			}
+			reqCtx, cancel := requestContext(ctx, c.timeout())
+			errs, err := linter.Lint(reqCtx, string(bs))
+			cancel()
+			if err != nil {
+				return nil, ProviderError{ProviderID: id, Operation: "lint " + path, Err: err}
+			}
+			results = append(results, LintResult{
+				Path:       path,
+				ProviderID: id,
+				Errors:     errs,
+			})
+		}
+	}
+
+	sort.Slice(results, func(i, j int) bool {
+		ri, rj := results[i], results[j]
+		return ri.Path < rj.Path || (ri.Path == rj.Path && ri.ProviderID < rj.ProviderID)
+	})
+
+	return results, nil
+}
@@ -0,0 +1,63 @@
+package providers
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/nbedos/cistern/utils"
+)
+
+func TestCache_LintConfiguration(t *testing.T) {
+	client, _, teardown, err := setupGitLabTestServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer teardown()
+	client.provider = Provider{ID: "gitlab", Name: "gitlab"}
+
+	dir, err := ioutil.TempDir("", "cistern")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	c := NewCache([]CIProvider{client}, nil, utils.PollingStrategy{})
+
+	t.Run("missing configuration file", func(t *testing.T) {
+		results, err := c.LintConfiguration(context.Background(), dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(results) > 0 {
+			t.Fatalf("expected no result but got %v", results)
+		}
+	})
+
+	t.Run("invalid configuration file", func(t *testing.T) {
+		content := []byte("test:\n  invalid: true\n")
+		if err := ioutil.WriteFile(filepath.Join(dir, ".gitlab-ci.yml"), content, 0600); err != nil {
+			t.Fatal(err)
+		}
+		results, err := c.LintConfiguration(context.Background(), dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		expected := []LintResult{
+			{
+				Path:       ".gitlab-ci.yml",
+				ProviderID: "gitlab",
+				Errors:     []string{"jobs:test config contains unknown keys: invalid"},
+			},
+		}
+		if diff := cmp.Diff(expected, results); len(diff) > 0 {
+			t.Fatal(diff)
+		}
+		if results[0].Valid() {
+			t.Fatal("expected invalid result")
+		}
+	})
+}
@@ -0,0 +1,292 @@
+package providers
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nbedos/cistern/utils"
+	"github.com/pelletier/go-toml"
+)
+
+// Scheme of the URLs of pipelines run by a LocalClient
+const localScheme = "local"
+
+// LocalClient runs a list of commands on the local machine for the commit checked out in the
+// repository and shows them as a pipeline whose jobs are the commands. This allows watching
+// local test runs, for example before pushing a commit, next to remote pipelines.
+type LocalClient struct {
+	ctx      context.Context
+	dir      string
+	jobs     []LocalJob
+	mux      *sync.Mutex
+	runs     map[string]*localRun
+	provider Provider
+}
+
+// Command run by a LocalClient. Commands are run one after the other in the directory of the
+// file describing them.
+type LocalJob struct {
+	Name    string   `toml:"name"`
+	Command []string `toml:"command"`
+}
+
+type localJobRun struct {
+	LocalJob
+	state      State
+	startedAt  utils.NullTime
+	finishedAt utils.NullTime
+	output     bytes.Buffer
+}
+
+type localRun struct {
+	sha       string
+	ref       string
+	createdAt time.Time
+	updatedAt time.Time
+	jobs      []*localJobRun
+}
+
+// Create a client running the jobs listed in the TOML file designated by path. Runs are
+// attached to ctx and are stopped when ctx is canceled.
+func NewLocalClient(ctx context.Context, id string, name string, path string) (LocalClient, error) {
+	if path == "" {
+		return LocalClient{}, errors.New("the path of the file listing local jobs must not be empty")
+	}
+
+	bs, err := ioutil.ReadFile(path)
+	if err != nil {
+		return LocalClient{}, err
+	}
+	var file struct {
+		Jobs []LocalJob `toml:"jobs"`
+	}
+	if err := toml.Unmarshal(bs, &file); err != nil {
+		return LocalClient{}, fmt.Errorf("%s: %w", path, err)
+	}
+
+	dir, err := filepath.Abs(filepath.Dir(path))
+	if err != nil {
+		return LocalClient{}, err
+	}
+
+	return newLocalClient(ctx, id, name, dir, file.Jobs)
+}
+
+func newLocalClient(ctx context.Context, id string, name string, dir string, jobs []LocalJob) (LocalClient, error) {
+	if len(jobs) == 0 {
+		return LocalClient{}, errors.New("the list of local jobs must not be empty")
+	}
+	for i, job := range jobs {
+		if len(job.Command) == 0 {
+			return LocalClient{}, fmt.Errorf("the command of local job #%d must not be empty", i)
+		}
+		if job.Name == "" {
+			jobs[i].Name = strings.Join(job.Command, " ")
+		}
+	}
+
+	return LocalClient{
+		ctx:  ctx,
+		dir:  dir,
+		jobs: jobs,
+		mux:  &sync.Mutex{},
+		runs: make(map[string]*localRun),
+		provider: Provider{
+			ID:   id,
+			Name: name,
+		},
+	}, nil
+}
+
+func (c LocalClient) ID() string {
+	return c.provider.ID
+}
+
+func (c LocalClient) Host() string {
+	return "localhost"
+}
+
+func (c LocalClient) Name() string {
+	return c.provider.Name
+}
+
+func (c LocalClient) runURL(sha string) string {
+	return fmt.Sprintf("%s://%s/%s", localScheme, c.provider.ID, sha)
+}
+
+// Jobs are only run for the commit checked out in the repository since they operate on the
+// working tree. Each commit is run at most once.
+func (c LocalClient) PipelineURLs(ctx context.Context, sha string) ([]string, error) {
+	commit, err := ResolveCommit(c.dir, "HEAD")
+	if err != nil {
+		return nil, err
+	}
+	if commit.Sha != sha {
+		return nil, nil
+	}
+
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	if _, exists := c.runs[sha]; !exists {
+		run := &localRun{
+			sha:       sha,
+			ref:       commit.Head,
+			createdAt: time.Now(),
+		}
+		run.updatedAt = run.createdAt
+		for _, job := range c.jobs {
+			run.jobs = append(run.jobs, &localJobRun{
+				LocalJob: job,
+				state:    Pending,
+			})
+		}
+		c.runs[sha] = run
+		go c.run(run)
+	}
+
+	return []string{c.runURL(sha)}, nil
+}
+
+// Writer appending the output of a job to its log while holding the lock of the client
+type localWriter struct {
+	mux *sync.Mutex
+	buf *bytes.Buffer
+}
+
+func (w localWriter) Write(p []byte) (int, error) {
+	w.mux.Lock()
+	defer w.mux.Unlock()
+	return w.buf.Write(p)
+}
+
+// Run jobs one after the other. Jobs following a failed job are skipped.
+func (c LocalClient) run(r *localRun) {
+	failed := false
+	for _, job := range r.jobs {
+		c.mux.Lock()
+		now := time.Now()
+		r.updatedAt = now
+		if failed || c.ctx.Err() != nil {
+			job.state = Skipped
+			c.mux.Unlock()
+			continue
+		}
+		job.state = Running
+		job.startedAt = utils.NullTime{Valid: true, Time: now}
+		c.mux.Unlock()
+
+		cmd := exec.CommandContext(c.ctx, job.Command[0], job.Command[1:]...)
+		cmd.Dir = c.dir
+		w := localWriter{mux: c.mux, buf: &job.output}
+		cmd.Stdout = w
+		cmd.Stderr = w
+		err := cmd.Run()
+
+		c.mux.Lock()
+		now = time.Now()
+		r.updatedAt = now
+		job.finishedAt = utils.NullTime{Valid: true, Time: now}
+		switch {
+		case c.ctx.Err() != nil:
+			job.state = Canceled
+			failed = true
+		case err != nil:
+			job.state = Failed
+			failed = true
+			fmt.Fprintf(&job.output, "\n%s: %v\n", strings.Join(job.Command, " "), err)
+		default:
+			job.state = Passed
+		}
+		c.mux.Unlock()
+	}
+}
+
+func (c LocalClient) BuildFromURL(ctx context.Context, u string) (Pipeline, error) {
+	v, err := url.Parse(u)
+	if err != nil {
+		return Pipeline{}, err
+	}
+	if v.Scheme != localScheme || v.Host != c.provider.ID {
+		return Pipeline{}, ErrUnknownPipelineURL
+	}
+	sha := strings.TrimPrefix(v.Path, "/")
+
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	run, exists := c.runs[sha]
+	if !exists {
+		return Pipeline{}, ErrUnknownPipelineURL
+	}
+
+	number := sha
+	if len(number) > 7 {
+		number = number[:7]
+	}
+	pipeline := Pipeline{
+		Number: number,
+		Ref:    run.ref,
+		Step: Step{
+			ID:        sha,
+			Type:      StepPipeline,
+			CreatedAt: utils.NullTime{Valid: true, Time: run.createdAt},
+			UpdatedAt: utils.NullTime{Valid: true, Time: run.updatedAt},
+		},
+	}
+
+	for i, job := range run.jobs {
+		pipeline.Children = append(pipeline.Children, Step{
+			ID:         strconv.Itoa(i),
+			Name:       job.Name,
+			Type:       StepJob,
+			State:      job.state,
+			StartedAt:  job.startedAt,
+			FinishedAt: job.finishedAt,
+			Duration:   utils.NullSub(job.finishedAt, job.startedAt),
+			Log: Log{
+				Key: fmt.Sprintf("%s/%d", sha, i),
+			},
+		})
+	}
+
+	aggregate := Aggregate(pipeline.Children)
+	pipeline.State = aggregate.State
+	pipeline.StartedAt = aggregate.StartedAt
+	if !pipeline.State.IsActive() {
+		pipeline.FinishedAt = aggregate.FinishedAt
+	}
+	pipeline.Duration = utils.NullSub(pipeline.FinishedAt, pipeline.StartedAt)
+
+	return pipeline, nil
+}
+
+// Return the output of the job written so far, which allows following jobs still running
+func (c LocalClient) Log(ctx context.Context, step Step) (string, error) {
+	i := strings.LastIndex(step.Log.Key, "/")
+	if i < 0 {
+		return "", ErrNoLogHere
+	}
+	sha := step.Log.Key[:i]
+	n, err := strconv.Atoi(step.Log.Key[i+1:])
+	if err != nil {
+		return "", ErrNoLogHere
+	}
+
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	run, exists := c.runs[sha]
+	if !exists || n < 0 || n >= len(run.jobs) {
+		return "", ErrNoLogHere
+	}
+
+	return run.jobs[n].output.String(), nil
+}
@@ -0,0 +1,254 @@
+package providers
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/nbedos/cistern/utils"
+)
+
+// Runners executing the jobs of a CI configuration file on the local machine
+const (
+	// https://github.com/nektos/act runs GitHub Actions workflows in containers
+	LocalRunnerAct = "act"
+	// "gitlab-runner exec docker JOB" runs a job of .gitlab-ci.yml in a container
+	LocalRunnerGitLab = "gitlab-runner"
+)
+
+// Create a client running the jobs of the CI configuration file designated by path with
+// the local runner 'runner'. Jobs are run from the root of the repository containing the file.
+// This is experimental: jobs are listed without fully parsing the configuration file.
+func NewLocalCIClient(ctx context.Context, id string, name string, path string, runner string) (LocalClient, error) {
+	if path == "" {
+		return LocalClient{}, errors.New("the path of the CI configuration file must not be empty")
+	}
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return LocalClient{}, err
+	}
+	dir, err := RepositoryRoot(filepath.Dir(path))
+	if err != nil {
+		return LocalClient{}, err
+	}
+
+	var jobs []LocalJob
+	switch runner {
+	case LocalRunnerAct:
+		cmd := exec.CommandContext(ctx, "act", "--list", "-W", path)
+		cmd.Dir = dir
+		bs, err := cmd.Output()
+		if err != nil {
+			return LocalClient{}, fmt.Errorf("failed to list jobs with %q: %w", "act --list", err)
+		}
+		jobs = actJobs(string(bs), path)
+	case LocalRunnerGitLab:
+		bs, err := ioutil.ReadFile(path)
+		if err != nil {
+			return LocalClient{}, err
+		}
+		jobs = gitLabCIJobs(string(bs))
+	default:
+		return LocalClient{}, fmt.Errorf("invalid local runner: %q (expected %q or %q)", runner, LocalRunnerAct, LocalRunnerGitLab)
+	}
+
+	if len(jobs) == 0 {
+		return LocalClient{}, fmt.Errorf("%s: no job found", path)
+	}
+
+	return newLocalClient(ctx, id, name, dir, jobs)
+}
+
+// Return the root of the git repository containing the directory dir, that is the closest
+// directory containing a ".git" entry
+func RepositoryRoot(dir string) (string, error) {
+	for d := dir; ; {
+		if _, err := os.Stat(filepath.Join(d, ".git")); err == nil {
+			return d, nil
+		}
+		parent := filepath.Dir(d)
+		if parent == d {
+			return "", fmt.Errorf("%s is not part of a git repository", dir)
+		}
+		d = parent
+	}
+}
+
+// Return the jobs listed by "act --list" in the order of their stage. The output of act is a
+// table whose columns are separated by spaces:
+//
+//	Stage  Job ID  Job name  Workflow name  Workflow file  Events
+//	0      build   Build     CI             ci.yml         push
+func actJobs(output string, workflow string) []LocalJob {
+	type stagedJob struct {
+		stage int
+		job   LocalJob
+	}
+	jobs := make([]stagedJob, 0)
+
+	var idStart, nameStart, nameEnd int
+	header := false
+	scanner := bufio.NewScanner(strings.NewReader(output))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if !header {
+			idStart = strings.Index(line, "Job ID")
+			nameStart = strings.Index(line, "Job name")
+			nameEnd = strings.Index(line, "Workflow name")
+			header = strings.HasPrefix(line, "Stage") && idStart > 0 && nameStart > idStart
+			continue
+		}
+		if len(line) <= idStart || strings.TrimSpace(line) == "" {
+			continue
+		}
+		stage, err := strconv.Atoi(strings.TrimSpace(line[:idStart]))
+		if err != nil {
+			continue
+		}
+		id := strings.TrimSpace(line[idStart:utils.MinInt(nameStart, len(line))])
+		name := id
+		if len(line) > nameStart {
+			end := len(line)
+			if nameEnd > nameStart && nameEnd < end {
+				end = nameEnd
+			}
+			name = strings.TrimSpace(line[nameStart:end])
+		}
+		jobs = append(jobs, stagedJob{
+			stage: stage,
+			job: LocalJob{
+				Name:    name,
+				Command: []string{"act", "-j", id, "-W", workflow},
+			},
+		})
+	}
+
+	sort.SliceStable(jobs, func(i, j int) bool {
+		return jobs[i].stage < jobs[j].stage
+	})
+	result := make([]LocalJob, 0, len(jobs))
+	for _, j := range jobs {
+		result = append(result, j.job)
+	}
+
+	return result
+}
+
+// Top-level keys of .gitlab-ci.yml that do not define jobs
+var gitLabCIKeywords = map[string]struct{}{
+	"after_script":  {},
+	"before_script": {},
+	"cache":         {},
+	"default":       {},
+	"image":         {},
+	"include":       {},
+	"services":      {},
+	"stages":        {},
+	"types":         {},
+	"variables":     {},
+	"workflow":      {},
+}
+
+var gitLabCITopLevelKey = regexp.MustCompile(`^["']?([^\s"'#:][^"':]*)["']?:\s*(#.*)?$`)
+var gitLabCIStage = regexp.MustCompile(`^\s+stage:\s*["']?([^"'\s#]+)`)
+var gitLabCIListItem = regexp.MustCompile(`^\s+-\s*["']?([^"'\s#]+)`)
+var gitLabCIInlineList = regexp.MustCompile(`^stages:\s*\[(.*)\]`)
+
+// Return the jobs of a .gitlab-ci.yml file in the order of their stage. Hidden jobs (starting
+// with a dot) and templates are ignored. Only the usual layout of the file is supported: jobs
+// are top-level keys and their stage is set by a "stage" key one level below.
+func gitLabCIJobs(content string) []LocalJob {
+	stages := make([]string, 0)
+	names := make([]string, 0)
+	stageByJob := make(map[string]string)
+
+	var current string
+	inStages := false
+	scanner := bufio.NewScanner(strings.NewReader(content))
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), " \t\r")
+		if strings.TrimSpace(line) == "" || strings.HasPrefix(strings.TrimSpace(line), "#") {
+			continue
+		}
+
+		if line[0] != ' ' && line[0] != '\t' && line[0] != '-' {
+			current, inStages = "", false
+			if m := gitLabCIInlineList.FindStringSubmatch(line); m != nil {
+				for _, s := range strings.Split(m[1], ",") {
+					if s = strings.Trim(strings.TrimSpace(s), `"'`); s != "" {
+						stages = append(stages, s)
+					}
+				}
+				continue
+			}
+			m := gitLabCITopLevelKey.FindStringSubmatch(line)
+			if m == nil {
+				continue
+			}
+			key := strings.TrimSpace(m[1])
+			if key == "stages" {
+				inStages = true
+				continue
+			}
+			if _, reserved := gitLabCIKeywords[key]; reserved || strings.HasPrefix(key, ".") {
+				continue
+			}
+			current = key
+			names = append(names, key)
+			continue
+		}
+
+		switch {
+		case inStages:
+			if m := gitLabCIListItem.FindStringSubmatch(line); m != nil {
+				stages = append(stages, m[1])
+			}
+		case current != "":
+			if m := gitLabCIStage.FindStringSubmatch(line); m != nil {
+				if _, exists := stageByJob[current]; !exists {
+					stageByJob[current] = m[1]
+				}
+			}
+		}
+	}
+
+	if len(stages) == 0 {
+		stages = []string{"build", "test", "deploy"}
+	}
+	stages = append(append([]string{".pre"}, stages...), ".post")
+	position := make(map[string]int, len(stages))
+	for i, stage := range stages {
+		if _, exists := position[stage]; !exists {
+			position[stage] = i
+		}
+	}
+	stageOf := func(job string) int {
+		stage, exists := stageByJob[job]
+		if !exists {
+			stage = "test"
+		}
+		return position[stage]
+	}
+	sort.SliceStable(names, func(i, j int) bool {
+		return stageOf(names[i]) < stageOf(names[j])
+	})
+
+	jobs := make([]LocalJob, 0, len(names))
+	for _, name := range names {
+		jobs = append(jobs, LocalJob{
+			Name:    name,
+			Command: []string{"gitlab-runner", "exec", "docker", name},
+		})
+	}
+
+	return jobs
+}
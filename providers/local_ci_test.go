@@ -0,0 +1,70 @@
+package providers
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestActJobs(t *testing.T) {
+	output := `Stage  Job ID  Job name     Workflow name  Workflow file  Events
+1      test    Unit tests   CI             ci.yml         push
+0      build   Build        CI             ci.yml         push
+1      lint    lint         CI             ci.yml         push
+`
+	expected := []LocalJob{
+		{Name: "Build", Command: []string{"act", "-j", "build", "-W", "ci.yml"}},
+		{Name: "Unit tests", Command: []string{"act", "-j", "test", "-W", "ci.yml"}},
+		{Name: "lint", Command: []string{"act", "-j", "lint", "-W", "ci.yml"}},
+	}
+	if diff := cmp.Diff(expected, actJobs(output, "ci.yml")); len(diff) > 0 {
+		t.Fatal(diff)
+	}
+}
+
+func TestGitLabCIJobs(t *testing.T) {
+	content := `image: golang:1.13
+
+stages:
+  - build
+  - test
+  - deploy
+
+variables:
+  GOFLAGS: "-mod=readonly"
+
+.template: &template
+  stage: test
+
+publish:
+  stage: deploy
+  script:
+    - make publish
+
+"unit tests":
+  <<: *template
+  script:
+    - go test ./...
+
+compile:
+  stage: build
+  script: go build ./...
+`
+	names := make([]string, 0)
+	for _, job := range gitLabCIJobs(content) {
+		names = append(names, job.Name)
+	}
+	expected := []string{"compile", "unit tests", "publish"}
+	if diff := cmp.Diff(expected, names); len(diff) > 0 {
+		t.Fatal(diff)
+	}
+
+	inline := "stages: [lint, build]\nbuild:\n  stage: build\nlint:\n  stage: lint\n"
+	jobs := gitLabCIJobs(inline)
+	if len(jobs) != 2 || jobs[0].Name != "lint" {
+		t.Fatalf("unexpected jobs: %+v", jobs)
+	}
+	if diff := cmp.Diff([]string{"gitlab-runner", "exec", "docker", "lint"}, jobs[0].Command); len(diff) > 0 {
+		t.Fatal(diff)
+	}
+}
@@ -0,0 +1,192 @@
+package providers
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Not an actual test: this function is started as a local job by the tests below with the
+// behavior of the job following "--" on the command line
+func TestLocalHelperProcess(t *testing.T) {
+	mode := ""
+	for i, arg := range os.Args {
+		if arg == "--" && i+1 < len(os.Args) {
+			mode = os.Args[i+1]
+		}
+	}
+	if mode == "" {
+		return
+	}
+
+	fmt.Printf("running %s\n", mode)
+	if mode == "fail" {
+		os.Exit(1)
+	}
+	os.Exit(0)
+}
+
+func setupLocalTestClient(t *testing.T, modes ...string) (LocalClient, func()) {
+	jobs := make([]LocalJob, 0, len(modes))
+	for _, mode := range modes {
+		jobs = append(jobs, LocalJob{
+			Name:    mode,
+			Command: []string{os.Args[0], "-test.run=TestLocalHelperProcess", "--", mode},
+		})
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	client, err := newLocalClient(ctx, "local-0", "local", ".", jobs)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return client, cancel
+}
+
+// Return the pipeline run by client for the commit checked out once all its jobs are done
+func waitForLocalPipeline(t *testing.T, client LocalClient) Pipeline {
+	commit, err := ResolveCommit(".", "HEAD")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	urls, err := client.PipelineURLs(context.Background(), commit.Sha)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(urls) != 1 {
+		t.Fatalf("expected one url but got %v", urls)
+	}
+
+	for timeout := time.After(10 * time.Second); ; {
+		pipeline, err := client.BuildFromURL(context.Background(), urls[0])
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !pipeline.State.IsActive() {
+			return pipeline
+		}
+		select {
+		case <-timeout:
+			t.Fatal("timeout waiting for local jobs")
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+}
+
+func TestLocalClient_BuildFromURL(t *testing.T) {
+	t.Run("passed", func(t *testing.T) {
+		client, teardown := setupLocalTestClient(t, "build", "test")
+		defer teardown()
+
+		pipeline := waitForLocalPipeline(t, client)
+		if pipeline.State != Passed {
+			t.Fatalf("expected state %q but got %q", Passed, pipeline.State)
+		}
+		if len(pipeline.Children) != 2 || pipeline.Children[1].Name != "test" {
+			t.Fatalf("unexpected jobs: %+v", pipeline.Children)
+		}
+		if !pipeline.FinishedAt.Valid || !pipeline.Duration.Valid {
+			t.Fatalf("expected pipeline to be finished: %+v", pipeline)
+		}
+
+		log, err := client.Log(context.Background(), pipeline.Children[1])
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.HasPrefix(log, "running test\n") {
+			t.Fatalf("unexpected log: %q", log)
+		}
+	})
+
+	t.Run("jobs following a failure are skipped", func(t *testing.T) {
+		client, teardown := setupLocalTestClient(t, "build", "fail", "test")
+		defer teardown()
+
+		pipeline := waitForLocalPipeline(t, client)
+		states := make([]State, 0)
+		for _, job := range pipeline.Children {
+			states = append(states, job.State)
+		}
+		if pipeline.State != Failed || states[0] != Passed || states[1] != Failed || states[2] != Skipped {
+			t.Fatalf("unexpected states: pipeline %q, jobs %v", pipeline.State, states)
+		}
+	})
+
+	t.Run("unknown url", func(t *testing.T) {
+		client, teardown := setupLocalTestClient(t, "build")
+		defer teardown()
+
+		for _, u := range []string{"local://local-0/a24840cf", "local://local-1/a24840cf", "https://example.com/local-0"} {
+			if _, err := client.BuildFromURL(context.Background(), u); err != ErrUnknownPipelineURL {
+				t.Fatalf("%s: expected %v but got %v", u, ErrUnknownPipelineURL, err)
+			}
+		}
+	})
+}
+
+func TestLocalClient_PipelineURLs(t *testing.T) {
+	client, teardown := setupLocalTestClient(t, "build")
+	defer teardown()
+
+	// Only the commit checked out is run
+	urls, err := client.PipelineURLs(context.Background(), "a24840cfe1f6d9b4e1a8c5b2f0d3e7a6c9b8d4f1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(urls) > 0 {
+		t.Fatalf("expected no url but got %v", urls)
+	}
+}
+
+func TestNewLocalClient(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cistern")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	testCases := map[string]struct {
+		content string
+		valid   bool
+	}{
+		"valid file": {
+			content: "[[jobs]]\nname = \"test\"\ncommand = [\"go\", \"test\", \"./...\"]\n",
+			valid:   true,
+		},
+		"no job": {
+			content: "",
+			valid:   false,
+		},
+		"empty command": {
+			content: "[[jobs]]\nname = \"test\"\n",
+			valid:   false,
+		},
+	}
+
+	for name, testCase := range testCases {
+		t.Run(name, func(t *testing.T) {
+			filePath := path.Join(dir, "jobs.toml")
+			if err := ioutil.WriteFile(filePath, []byte(testCase.content), 0600); err != nil {
+				t.Fatal(err)
+			}
+			client, err := NewLocalClient(context.Background(), "local-0", "local", filePath)
+			if testCase.valid {
+				if err != nil {
+					t.Fatal(err)
+				}
+				if client.dir != dir {
+					t.Fatalf("expected jobs to run in %q but got %q", dir, client.dir)
+				}
+			} else if err == nil {
+				t.Fatal("expected error but got nil")
+			}
+		})
+	}
+}
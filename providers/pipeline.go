@@ -2,6 +2,7 @@ package providers
 
 import (
 	"fmt"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
@@ -86,14 +87,15 @@ type Ref struct {
 }
 
 type Commit struct {
-	Sha      string
-	Author   string
-	Date     time.Time
-	Message  string
-	Branches []string
-	Tags     []string
-	Head     string
-	Statuses []string
+	Sha       string
+	Author    string
+	Committer string
+	Date      time.Time
+	Message   string
+	Branches  []string
+	Tags      []string
+	Head      string
+	Statuses  []string
 }
 
 type GitStyle struct {
@@ -162,6 +164,8 @@ type Log struct {
 }
 
 type Step struct {
+	// Identifier of the step among its siblings, as given by the provider. Its content is
+	// opaque: a number, a UUID or a slug.
 	ID           string
 	Name         string
 	Type         StepType
@@ -174,18 +178,121 @@ type Step struct {
 	Duration     utils.NullDuration
 	WebURL       utils.NullString
 	Log          Log
-	Children     []Step
+	// Parameters of the pipeline or variables of the job, where providers expose them
+	Variables []Variable
+	// True if the job both passed and failed on the same commit or if its outcome keeps
+	// changing between runs. Only set for pipelines returned by the cache.
+	Flaky bool
+	// Percentage of the code covered by tests, where providers expose it
+	Coverage utils.NullFloat64
+	// Runner, agent or pool that ran the step, where providers expose it
+	Runner   string
+	Children []Step
+}
+
+// Variable or parameter passed to a pipeline or a job
+type Variable struct {
+	Name  string
+	Value string
+	// The value of secret variables is never shown
+	Secret bool
+}
+
+// Value shown in place of the value of secret variables
+const MaskedValue = "****"
+
+// Parts of the names of variables that usually hold secrets
+var secretNameParts = []string{"TOKEN", "SECRET", "PASSWORD", "PASSWD", "KEY", "CREDENTIAL", "AUTH"}
+
+// Return true if the value of the variable must be masked, either because the provider says
+// so or because the name of the variable suggests that its value is a secret
+func (v Variable) IsSecret() bool {
+	if v.Secret {
+		return true
+	}
+	name := strings.ToUpper(v.Name)
+	for _, part := range secretNameParts {
+		if strings.Contains(name, part) {
+			return true
+		}
+	}
+	return false
+}
+
+func (v Variable) String() string {
+	if v.IsSecret() {
+		return fmt.Sprintf("%s=%s", v.Name, MaskedValue)
+	}
+	return fmt.Sprintf("%s=%s", v.Name, v.Value)
 }
 
 func (s Step) Diff(other Step) string {
 	return cmp.Diff(s, other)
 }
 
+// Return a copy of the step where steps without a state, typically stages of providers that
+// do not report the state of stages, take the state aggregated from their children. Failures of
+// children allowed to fail are ignored.
+func (s Step) withChildrenStates() Step {
+	if len(s.Children) == 0 {
+		return s
+	}
+
+	children := make([]Step, 0, len(s.Children))
+	for _, child := range s.Children {
+		children = append(children, child.withChildrenStates())
+	}
+	s.Children = children
+
+	if s.State == Unknown {
+		s.State = Aggregate(children).State
+		// Aggregate returns single steps as is
+		if len(children) == 1 && children[0].AllowFailure && (s.State == Failed || s.State == Canceled) {
+			s.State = Passed
+		}
+	}
+
+	return s
+}
+
+// Return a copy of the step where steps missing a duration, typically stages of providers that
+// do not time stages, take the duration spanned by their children
+func (s Step) withChildrenDurations() Step {
+	if len(s.Children) == 0 {
+		return s
+	}
+
+	children := make([]Step, 0, len(s.Children))
+	for _, child := range s.Children {
+		children = append(children, child.withChildrenDurations())
+	}
+	s.Children = children
+
+	if !s.Duration.Valid {
+		aggregate := Aggregate(children)
+		if !s.StartedAt.Valid {
+			s.StartedAt = aggregate.StartedAt
+		}
+		if !s.FinishedAt.Valid && !s.State.IsActive() {
+			s.FinishedAt = aggregate.FinishedAt
+		}
+		s.Duration = utils.NullSub(s.FinishedAt, s.StartedAt)
+	}
+
+	return s
+}
+
+// Return a copy of the step where 'f' was applied to the step and each of its descendants.
+// The children of 's' are left untouched since they may be shared with other copies of the step.
 func (s Step) Map(f func(Step) Step) Step {
 	s = f(s)
 
-	for i, child := range s.Children {
-		s.Children[i] = child.Map(f)
+	if len(s.Children) > 0 {
+		children := make([]Step, 0, len(s.Children))
+		for _, child := range s.Children {
+			children = append(children, child.Map(f))
+		}
+		s.Children = children
 	}
 
 	return s
@@ -211,10 +318,33 @@ func (s Step) getStep(stepIDs []string) (Step, bool) {
 	return step, true
 }
 
+// Return a copy of s where the content of the log of the step designated by stepIDs is set
+// to log
+func (s Step) setLog(stepIDs []string, log string) Step {
+	if len(stepIDs) == 0 {
+		s.Log.Content = utils.NullString{Valid: true, String: log}
+		return s
+	}
+
+	children := make([]Step, len(s.Children))
+	copy(children, s.Children)
+	for i, child := range children {
+		if child.ID == stepIDs[0] {
+			children[i] = child.setLog(stepIDs[1:], log)
+		}
+	}
+	s.Children = children
+
+	return s
+}
+
 type StepStatusChanges struct {
 	Started [][]string
 	Passed  [][]string
 	Failed  [][]string
+	// Steps that reached a final state other than a failure (passed, skipped, waiting for a
+	// manual action or failed while being allowed to fail)
+	Finished [][]string
 }
 
 func (s Step) statusDiff(before Step, prefix []string) map[StepType]*StepStatusChanges {
@@ -231,12 +361,16 @@ func (s Step) statusDiff(before Step, prefix []string) map[StepType]*StepStatusC
 			c[s.Type].Started = append(c[s.Type].Started, prefix)
 		case Passed:
 			c[s.Type].Passed = append(c[s.Type].Passed, prefix)
+			c[s.Type].Finished = append(c[s.Type].Finished, prefix)
 		case Failed, Canceled:
 			if s.AllowFailure {
 				c[s.Type].Passed = append(c[s.Type].Passed, prefix)
+				c[s.Type].Finished = append(c[s.Type].Finished, prefix)
 			} else {
 				c[s.Type].Failed = append(c[s.Type].Failed, prefix)
 			}
+		case Skipped, Manual:
+			c[s.Type].Finished = append(c[s.Type].Finished, prefix)
 		}
 	}
 
@@ -249,6 +383,7 @@ func (s Step) statusDiff(before Step, prefix []string) map[StepType]*StepStatusC
 			c[stepType].Started = append(c[stepType].Started, childChanges.Started...)
 			c[stepType].Passed = append(c[stepType].Passed, childChanges.Passed...)
 			c[stepType].Failed = append(c[stepType].Failed, childChanges.Failed...)
+			c[stepType].Finished = append(c[stepType].Finished, childChanges.Finished...)
 		}
 	}
 
@@ -267,12 +402,27 @@ const (
 	ColumnName
 	ColumnWebURL
 	ColumnAllowedFailure
+	ColumnCommit
+	ColumnCost
+	ColumnVariables
+	ColumnFlaky
+	ColumnCoverage
+	ColumnRunner
 )
 
 func (s Step) NodeID() interface{} {
 	return s.ID
 }
 
+// Steps are identified by their name when it is set since the IDs of stages and jobs may
+// change between two updates of a pipeline, for example when a job is restarted
+func (s Step) StableID() interface{} {
+	if s.Name != "" {
+		return s.Name
+	}
+	return s.ID
+}
+
 func (s Step) NodeChildren() []tui.TableNode {
 	children := make([]tui.TableNode, 0)
 	for _, child := range s.Children {
@@ -283,7 +433,7 @@ func (s Step) NodeChildren() []tui.TableNode {
 }
 
 func (s Step) InheritedValues() []tui.ColumnID {
-	return []tui.ColumnID{ColumnRef, ColumnPipeline}
+	return []tui.ColumnID{ColumnRef, ColumnPipeline, ColumnCommit}
 }
 
 type StepStyle struct {
@@ -298,10 +448,100 @@ type StepStyle struct {
 		Skipped  tui.StyleTransform
 		Manual   tui.StyleTransform
 	}
+	// Symbols shown instead of the name of states. States missing from the map are shown
+	// as text.
+	StateIcons map[State]string
+	// Symbols shown before the name of providers, indexed by type of provider (e.g. "gitlab")
+	ProviderIcons map[string]string
+	// Estimation of the cost of pipelines
+	Costs CostModel
+	// Color all the cells of a row with the style of the state of the step instead of the
+	// state cell only
+	ColorRows bool
+	// Source of the current time used to show the time elapsed since running steps started
+	// in place of their duration. Running steps have no duration if nil.
+	Now func() time.Time
+}
+
+// Return the style transformation applied to steps in the state designated by state. The
+// result is nil if the state has no particular style.
+func (s StepStyle) StateStyle(state State) tui.StyleTransform {
+	switch state {
+	case Failed:
+		return s.Status.Failed
+	case Canceled:
+		return s.Status.Canceled
+	case Passed:
+		return s.Status.Passed
+	case Running:
+		return s.Status.Running
+	case Pending:
+		return s.Status.Pending
+	case Skipped:
+		return s.Status.Skipped
+	case Manual:
+		return s.Status.Manual
+	default:
+		return nil
+	}
+}
+
+var UnicodeStateIcons = map[State]string{
+	Unknown:  "?",
+	Pending:  "◌",
+	Running:  "●",
+	Passed:   "✓",
+	Failed:   "✗",
+	Canceled: "⊘",
+	Manual:   "▷",
+	Skipped:  "»",
+}
+
+// Icons of Nerd Fonts (https://www.nerdfonts.com), only displayed correctly by terminals using
+// one of these fonts
+var NerdFontStateIcons = map[State]string{
+	Unknown:  "\uf128", // nf-fa-question
+	Pending:  "\uf017", // nf-fa-clock_o
+	Running:  "\uf110", // nf-fa-spinner
+	Passed:   "\uf00c", // nf-fa-check
+	Failed:   "\uf00d", // nf-fa-times
+	Canceled: "\uf05e", // nf-fa-ban
+	Manual:   "\uf256", // nf-fa-hand_paper_o
+	Skipped:  "\uf050", // nf-fa-fast_forward
+}
+
+var NerdFontProviderIcons = map[string]string{
+	"gitlab": "\uf296", // nf-fa-gitlab
+	"travis": "\ue77e", // nf-dev-travis
+	"azure":  "\uebd8", // nf-cod-azure
+	"local":  "\uf108", // nf-fa-desktop
+}
+
+// Return the type of the provider identified by id, e.g. "gitlab" for "gitlab-0"
+func providerType(id string) string {
+	return strings.SplitN(id, "-", 2)[0]
+}
+
+// Apply the style of 'state' to all the values of a row if rows are colored by state. Styles
+// of individual cells, such as the color of git references, take precedence.
+func (s StepStyle) colorRow(state State, values map[tui.ColumnID]tui.StyledString) map[tui.ColumnID]tui.StyledString {
+	transform := s.StateStyle(state)
+	if !s.ColorRows || transform == nil {
+		return values
+	}
+	for id, value := range values {
+		value.ApplyUnder(transform)
+		values[id] = value
+	}
+	return values
 }
 
 func (s Step) Values(v interface{}) map[tui.ColumnID]tui.StyledString {
-	conf := v.(StepStyle)
+	return v.(StepStyle).colorRow(s.State, s.values(v.(StepStyle)))
+}
+
+// Return the values of the row of the step, whatever the setting of StepStyle.ColorRows
+func (s Step) values(conf StepStyle) map[tui.ColumnID]tui.StyledString {
 
 	nullTimeToString := func(t utils.NullTime) tui.StyledString {
 		s := "-"
@@ -323,22 +563,13 @@ func (s Step) Values(v interface{}) map[tui.ColumnID]tui.StyledString {
 		typeChar = "T"
 	}
 
-	state := tui.NewStyledString(string(s.State))
-	switch s.State {
-	case Failed:
-		state.Apply(conf.Status.Failed)
-	case Canceled:
-		state.Apply(conf.Status.Canceled)
-	case Passed:
-		state.Apply(conf.Status.Passed)
-	case Running:
-		state.Apply(conf.Status.Running)
-	case Pending:
-		state.Apply(conf.Status.Pending)
-	case Skipped:
-		state.Apply(conf.Status.Skipped)
-	case Manual:
-		state.Apply(conf.Status.Manual)
+	stateName := string(s.State)
+	if icon, exists := conf.StateIcons[s.State]; exists {
+		stateName = icon
+	}
+	state := tui.NewStyledString(stateName)
+	if transform := conf.StateStyle(s.State); transform != nil {
+		state.Apply(transform)
 	}
 
 	webURL := "-"
@@ -350,6 +581,33 @@ func (s Step) Values(v interface{}) map[tui.ColumnID]tui.StyledString {
 	if s.AllowFailure {
 		allowedFailure = "yes"
 	}
+
+	var flaky tui.StyledString
+	if s.Type == StepJob {
+		flaky = tui.NewStyledString("no")
+		if s.Flaky {
+			flaky = tui.NewStyledString("yes")
+			if transform := conf.StateStyle(Failed); transform != nil {
+				flaky.Apply(transform)
+			}
+		}
+	}
+
+	variables := make([]string, 0, len(s.Variables))
+	for _, variable := range s.Variables {
+		variables = append(variables, variable.String())
+	}
+
+	duration := s.Duration
+	if !duration.Valid && s.State.IsActive() && s.StartedAt.Valid && conf.Now != nil {
+		duration = utils.NullSub(utils.NullTime{Valid: true, Time: conf.Now()}, s.StartedAt)
+	}
+
+	coverage := "-"
+	if s.Coverage.Valid {
+		coverage = fmt.Sprintf("%.1f%%", s.Coverage.Float64)
+	}
+
 	return map[tui.ColumnID]tui.StyledString{
 		ColumnType:           tui.NewStyledString(typeChar),
 		ColumnState:          state,
@@ -357,16 +615,39 @@ func (s Step) Values(v interface{}) map[tui.ColumnID]tui.StyledString {
 		ColumnCreated:        nullTimeToString(s.CreatedAt),
 		ColumnStarted:        nullTimeToString(s.StartedAt),
 		ColumnFinished:       nullTimeToString(s.FinishedAt),
-		ColumnDuration:       tui.NewStyledString(s.Duration.String()),
+		ColumnDuration:       tui.NewStyledString(duration.String()),
 		ColumnName:           tui.NewStyledString(s.Name),
 		ColumnWebURL:         tui.NewStyledString(webURL),
+		ColumnCost:           tui.NewStyledString("-"),
+		ColumnVariables:      tui.NewStyledString(strings.Join(variables, " ")),
+		ColumnFlaky:          flaky,
+		ColumnCoverage:       tui.NewStyledString(coverage),
+		ColumnRunner:         tui.NewStyledString(s.Runner),
+	}
+}
+
+// Compare two coverages, unknown coverages coming first
+func compareCoverages(lhs utils.NullFloat64, rhs utils.NullFloat64) int {
+	switch {
+	case lhs.Valid && rhs.Valid:
+		return compareFloats(lhs.Float64, rhs.Float64)
+	case lhs.Valid:
+		return 1
+	case rhs.Valid:
+		return -1
+	default:
+		return 0
 	}
 }
 
 func (s Step) Compare(t tui.TableNode, id tui.ColumnID, i interface{}) int {
 	other := t.(Step)
 	switch id {
-	case ColumnType, ColumnState, ColumnAllowedFailure, ColumnName, ColumnWebURL:
+	case ColumnState:
+		// Sort states by precedence so that the most significant states are grouped together
+		return statePrecedence[s.State] - statePrecedence[other.State]
+
+	case ColumnType, ColumnAllowedFailure, ColumnName, ColumnWebURL, ColumnFlaky, ColumnRunner:
 		lhs, rhs := s.Values(i)[id].String(), other.Values(i)[id].String()
 		if lhs < rhs {
 			return -1
@@ -408,6 +689,9 @@ func (s Step) Compare(t tui.TableNode, id tui.ColumnID, i interface{}) int {
 			return 1
 		}
 
+	case ColumnCoverage:
+		return compareCoverages(s.Coverage, other.Coverage)
+
 	case ColumnDuration:
 		v := s.Duration
 		vOther := other.Duration
@@ -439,9 +723,32 @@ type Pipeline struct {
 	ProviderName string
 	Ref          string
 	IsTag        bool
+	// Username of the user who triggered the pipeline, empty if the provider does not tell
+	Author string
+	// SHA of the commit the pipeline was run for. Only set for pipelines returned by the cache.
+	Sha string
+	// Pull request the pipeline was run for, if the provider tells
+	PullRequest PullRequest
+	// Only set for pipelines returned by the cache: time of the next request for the pipeline
+	// if the last one failed with a transient error. The pipeline may be out of date until then.
+	RetryAt utils.NullTime `json:"-"`
+	// Only set for pipelines returned by the cache: coverage of the pipeline minus the
+	// coverage of the previous pipeline of the same branch and provider
+	CoverageDelta utils.NullFloat64 `json:"-"`
 	Step
 }
 
+// Pull request (or merge request) of a pipeline. The number is empty if the pipeline was not
+// run for a pull request and the title is empty if the provider does not tell.
+type PullRequest struct {
+	Number string
+	Title  string
+}
+
+func (r PullRequest) Valid() bool {
+	return r.Number != ""
+}
+
 func (p Pipeline) Diff(other Pipeline) string {
 	return cmp.Diff(p, other, cmp.AllowUnexported(Pipeline{}, Step{}))
 }
@@ -452,6 +759,34 @@ func (ps Pipelines) Diff(others Pipelines) string {
 	return cmp.Diff(ps, others, cmp.AllowUnexported(Pipeline{}, Step{}))
 }
 
+// Return the 'n' most recently created pipelines of ps, or all pipelines if n is zero.
+// Pipelines are returned in the same order as in ps.
+func (ps Pipelines) MostRecent(n int) Pipelines {
+	if n <= 0 || len(ps) <= n {
+		return ps
+	}
+
+	indexes := make([]int, len(ps))
+	for i := range indexes {
+		indexes[i] = i
+	}
+	sort.SliceStable(indexes, func(i, j int) bool {
+		pi, pj := ps[indexes[i]], ps[indexes[j]]
+		ti := utils.MinNullTime(pi.CreatedAt, pi.StartedAt)
+		tj := utils.MinNullTime(pj.CreatedAt, pj.StartedAt)
+		return ti.Valid && (!tj.Valid || ti.Time.After(tj.Time))
+	})
+	indexes = indexes[:n]
+	sort.Ints(indexes)
+
+	recent := make(Pipelines, 0, n)
+	for _, i := range indexes {
+		recent = append(recent, ps[i])
+	}
+
+	return recent
+}
+
 type PipelineKey struct {
 	ProviderHost string
 	ID           string
@@ -468,6 +803,12 @@ func (p Pipeline) NodeID() interface{} {
 	return p.Key()
 }
 
+// Pipelines anchor the traversable state of their steps so that it is preserved when they
+// are gathered under a group and can be saved along with the pipeline
+func (p Pipeline) Anchor() interface{} {
+	return p.Key()
+}
+
 func (p Pipeline) InheritedValues() []tui.ColumnID {
 	return nil
 }
@@ -475,7 +816,7 @@ func (p Pipeline) InheritedValues() []tui.ColumnID {
 func (p Pipeline) Values(v interface{}) map[tui.ColumnID]tui.StyledString {
 	conf := v.(StepStyle)
 
-	values := p.Step.Values(conf)
+	values := p.Step.values(conf)
 
 	number := p.Number
 	if number == "" {
@@ -486,7 +827,11 @@ func (p Pipeline) Values(v interface{}) map[tui.ColumnID]tui.StyledString {
 	}
 	values[ColumnPipeline] = tui.NewStyledString(number)
 
-	name := tui.NewStyledString(p.ProviderName, conf.Provider)
+	providerName := p.ProviderName
+	if icon, exists := conf.ProviderIcons[providerType(p.providerID)]; exists {
+		providerName = fmt.Sprintf("%s %s", icon, providerName)
+	}
+	name := tui.NewStyledString(providerName, conf.Provider)
 	if p.Name != "" {
 		name.Append(fmt.Sprintf(": %s", p.Name))
 	}
@@ -498,12 +843,73 @@ func (p Pipeline) Values(v interface{}) map[tui.ColumnID]tui.StyledString {
 		values[ColumnRef] = tui.NewStyledString(p.Ref, conf.Branch)
 	}
 
-	return values
+	if p.RetryAt.Valid {
+		retryAt := p.RetryAt.Time.In(conf.Location).Truncate(time.Second).Format("15:04:05")
+		state := values[ColumnState]
+		state.Append(fmt.Sprintf(" (stale, retrying at %s)", retryAt))
+		values[ColumnState] = state
+	}
+
+	if p.CoverageDelta.Valid {
+		coverage := values[ColumnCoverage]
+		delta := fmt.Sprintf(" (%+.1f)", p.CoverageDelta.Float64)
+		switch {
+		case p.CoverageDelta.Float64 >= 0.05:
+			coverage.Append(delta, conf.StateStyle(Passed))
+		case p.CoverageDelta.Float64 <= -0.05:
+			coverage.Append(delta, conf.StateStyle(Failed))
+		default:
+			coverage.Append(delta)
+		}
+		values[ColumnCoverage] = coverage
+	}
+
+	sha := p.Sha
+	if len(sha) > 7 {
+		sha = sha[:7]
+	}
+	values[ColumnCommit] = tui.NewStyledString(sha, conf.SHA)
+
+	if cost, ok := conf.Costs.PipelineCost(p); ok {
+		values[ColumnCost] = tui.NewStyledString(conf.Costs.Format(cost))
+	}
+
+	return conf.colorRow(p.State, values)
 }
 
 func (p Pipeline) Compare(other tui.TableNode, id tui.ColumnID, i interface{}) int {
-	switch q := other.(Pipeline); id {
-	case ColumnRef, ColumnPipeline, ColumnName:
+	switch id {
+	case ColumnCost:
+		costs := i.(StepStyle).Costs
+		lhs, _ := costs.PipelineCost(p)
+		var rhs float64
+		switch q := other.(type) {
+		case Pipeline:
+			rhs, _ = costs.PipelineCost(q)
+		case PipelineGroup:
+			rhs, _ = q.cost(costs)
+		}
+		return compareFloats(lhs, rhs)
+	case ColumnPipeline:
+		// Pipelines of the same provider are sorted by number, numerically
+		if q, ok := other.(Pipeline); ok && p.ProviderName == q.ProviderName {
+			n, errN := strconv.Atoi(p.Number)
+			m, errM := strconv.Atoi(q.Number)
+			if errN == nil && errM == nil {
+				return n - m
+			}
+		}
+		lhs, rhs := p.Values(i)[id].String(), other.Values(i)[id].String()
+		if lhs < rhs {
+			return -1
+		} else if lhs == rhs {
+			return 0
+		} else {
+			return 1
+		}
+	case ColumnRef:
+		return compareRefs(p.Values(i)[id].String(), other.Values(i)[id].String())
+	case ColumnName:
 		lhs, rhs := p.Values(i)[id].String(), other.Values(i)[id].String()
 		if lhs < rhs {
 			return -1
@@ -513,7 +919,142 @@ func (p Pipeline) Compare(other tui.TableNode, id tui.ColumnID, i interface{}) i
 			return 1
 		}
 	default:
-		return p.Step.Compare(q.Step, id, i)
+		switch q := other.(type) {
+		case Pipeline:
+			return p.Step.Compare(q.Step, id, i)
+		case PipelineGroup:
+			return p.Step.Compare(q.step(), id, i)
+		default:
+			return 0
+		}
+	}
+}
+
+// Split 's' into runs of digits and runs of other characters
+func splitDigits(s string) []string {
+	parts := make([]string, 0)
+	for i := 0; i < len(s); {
+		j := i + 1
+		isDigit := s[i] >= '0' && s[i] <= '9'
+		for j < len(s) && (s[j] >= '0' && s[j] <= '9') == isDigit {
+			j++
+		}
+		parts = append(parts, s[i:j])
+		i = j
+	}
+	return parts
+}
+
+// Compare two git references, numbers being compared numerically so that tags such as
+// "v1.10.0" come after "v1.9.0"
+func compareRefs(lhs string, rhs string) int {
+	lparts, rparts := splitDigits(lhs), splitDigits(rhs)
+	for k := 0; k < len(lparts) && k < len(rparts); k++ {
+		l, r := lparts[k], rparts[k]
+		if l[0] >= '0' && l[0] <= '9' && r[0] >= '0' && r[0] <= '9' {
+			l, r = strings.TrimLeft(l, "0"), strings.TrimLeft(r, "0")
+			if len(l) != len(r) {
+				return len(l) - len(r)
+			}
+		}
+		if c := strings.Compare(l, r); c != 0 {
+			return c
+		}
+	}
+	return len(lparts) - len(rparts)
+}
+
+// Identifier of a PipelineGroup among the rows of the table
+type PipelineGroupID string
+
+// PipelineGroup gathers several pipelines under a single row of the table. The state and
+// dates of the group are those of the aggregation of its pipelines.
+type PipelineGroup struct {
+	Name string
+	// Optional description shown after the name
+	Title     string
+	Pipelines Pipelines
+}
+
+func (g PipelineGroup) step() Step {
+	steps := make([]Step, 0, len(g.Pipelines))
+	for _, p := range g.Pipelines {
+		steps = append(steps, p.Step)
+	}
+	s := Aggregate(steps)
+	s.ID = g.Name
+	s.Name = g.Name
+	s.WebURL = utils.NullString{}
+	s.AllowFailure = false
+	s.Children = nil
+
+	return s
+}
+
+func (g PipelineGroup) NodeID() interface{} {
+	return PipelineGroupID(g.Name)
+}
+
+func (g PipelineGroup) NodeChildren() []tui.TableNode {
+	children := make([]tui.TableNode, 0, len(g.Pipelines))
+	for _, p := range g.Pipelines {
+		children = append(children, p)
+	}
+
+	return children
+}
+
+func (g PipelineGroup) InheritedValues() []tui.ColumnID {
+	return nil
+}
+
+func (g PipelineGroup) Values(v interface{}) map[tui.ColumnID]tui.StyledString {
+	step := g.step()
+	values := step.values(v.(StepStyle))
+	values[ColumnType] = tui.NewStyledString("")
+	name := g.Name
+	if g.Title != "" {
+		name = fmt.Sprintf("%s: %s", g.Name, g.Title)
+	}
+	values[ColumnName] = tui.NewStyledString(fmt.Sprintf("%s (%d)", name, len(g.Pipelines)))
+	if cost, ok := g.cost(v.(StepStyle).Costs); ok {
+		values[ColumnCost] = tui.NewStyledString(v.(StepStyle).Costs.Format(cost))
+	}
+
+	return v.(StepStyle).colorRow(step.State, values)
+}
+
+func (g PipelineGroup) Compare(other tui.TableNode, id tui.ColumnID, i interface{}) int {
+	switch id {
+	case ColumnCost:
+		costs := i.(StepStyle).Costs
+		lhs, _ := g.cost(costs)
+		var rhs float64
+		switch q := other.(type) {
+		case Pipeline:
+			rhs, _ = costs.PipelineCost(q)
+		case PipelineGroup:
+			rhs, _ = q.cost(costs)
+		}
+		return compareFloats(lhs, rhs)
+	case ColumnRef, ColumnPipeline, ColumnName:
+		lhs, rhs := g.Values(i)[id].String(), other.Values(i)[id].String()
+		if lhs < rhs {
+			return -1
+		} else if lhs == rhs {
+			return 0
+		} else {
+			return 1
+		}
+	default:
+		switch q := other.(type) {
+		case Pipeline:
+			return g.step().Compare(q.Step, id, i)
+		case PipelineGroup:
+			return g.step().Compare(q.step(), id, i)
+		default:
+			return 0
+		}
 	}
 }
 
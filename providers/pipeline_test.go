@@ -2,8 +2,11 @@ package providers
 
 import (
 	"testing"
+	"time"
 
 	"github.com/google/go-cmp/cmp"
+	"github.com/nbedos/cistern/tui"
+	"github.com/nbedos/cistern/utils"
 )
 
 func TestAggregateStatuses(t *testing.T) {
@@ -189,6 +192,32 @@ func TestStep_StatusDiff(t *testing.T) {
 				Passed: [][]string{
 					{"0"},
 				},
+				Finished: [][]string{
+					{"0"},
+				},
+			},
+			StepStage: {},
+			StepJob:   {},
+			StepTask:  {},
+		}
+
+		if diff := cmp.Diff(after.statusDiff(before, nil), expected); diff != "" {
+			t.Fatal(diff)
+		}
+	})
+
+	t.Run("skipped pipeline", func(t *testing.T) {
+		after := Step{
+			ID:    "0",
+			Type:  StepPipeline,
+			State: Skipped,
+		}
+
+		expected := map[StepType]*StepStatusChanges{
+			StepPipeline: {
+				Finished: [][]string{
+					{"0"},
+				},
 			},
 			StepStage: {},
 			StepJob:   {},
@@ -230,3 +259,435 @@ func TestStep_StatusDiff(t *testing.T) {
 		}
 	})
 }
+
+func TestPipelines_MostRecent(t *testing.T) {
+	pipeline := func(id string, day int) Pipeline {
+		return Pipeline{
+			Step: Step{
+				ID: id,
+				CreatedAt: utils.NullTime{
+					Valid: true,
+					Time:  time.Date(2019, 12, day, 0, 0, 0, 0, time.UTC),
+				},
+			},
+		}
+	}
+	pipelines := Pipelines{
+		pipeline("1", 3),
+		pipeline("2", 1),
+		pipeline("3", 4),
+		pipeline("4", 2),
+		{Step: Step{ID: "5"}},
+	}
+
+	testCases := []struct {
+		n        int
+		expected Pipelines
+	}{
+		{
+			n:        0,
+			expected: pipelines,
+		},
+		{
+			n:        1,
+			expected: Pipelines{pipelines[2]},
+		},
+		{
+			n:        2,
+			expected: Pipelines{pipelines[0], pipelines[2]},
+		},
+		{
+			n:        4,
+			expected: Pipelines{pipelines[0], pipelines[1], pipelines[2], pipelines[3]},
+		},
+		{
+			n:        10,
+			expected: pipelines,
+		},
+	}
+
+	for _, testCase := range testCases {
+		if diff := testCase.expected.Diff(pipelines.MostRecent(testCase.n)); len(diff) > 0 {
+			t.Fatalf("n=%d: %s", testCase.n, diff)
+		}
+	}
+}
+
+func TestPipelineGroup_Values(t *testing.T) {
+	at := func(minutes int) utils.NullTime {
+		return utils.NullTime{
+			Valid: true,
+			Time:  time.Date(2020, 1, 30, 12, minutes, 0, 0, time.UTC),
+		}
+	}
+	group := PipelineGroup{
+		Name: "bot updates",
+		Pipelines: Pipelines{
+			{Ref: "dependabot/a", Step: Step{ID: "1", State: Passed, StartedAt: at(0), FinishedAt: at(5)}},
+			{Ref: "dependabot/b", Step: Step{ID: "2", State: Running, StartedAt: at(2)}},
+		},
+	}
+	style := StepStyle{GitStyle: GitStyle{Location: time.UTC}}
+
+	values := group.Values(style)
+	expected := map[tui.ColumnID]string{
+		ColumnName:    "bot updates (2)",
+		ColumnState:   string(Running),
+		ColumnStarted: "Jan 30 12:00",
+		ColumnType:    "",
+	}
+	for id, value := range expected {
+		if s := values[id].String(); s != value {
+			t.Errorf("column %d: expected %q but got %q", id, value, s)
+		}
+	}
+
+	if n := len(group.NodeChildren()); n != 2 {
+		t.Fatalf("expected 2 children but got %d", n)
+	}
+
+	// Groups and pipelines must be comparable since they are siblings in the table
+	if group.Compare(group.Pipelines[1], ColumnStarted, style) >= 0 {
+		t.Fatal("expected group to start before its second pipeline")
+	}
+	if group.Pipelines[1].Compare(group, ColumnStarted, style) <= 0 {
+		t.Fatal("expected second pipeline to start after the group")
+	}
+}
+
+func TestPipeline_ValuesIcons(t *testing.T) {
+	pipeline := Pipeline{
+		providerID:   "gitlab-0",
+		ProviderName: "gitlab",
+		Step: Step{
+			ID:    "1",
+			State: Passed,
+		},
+	}
+
+	testCases := []struct {
+		name     string
+		style    StepStyle
+		state    string
+		provider string
+	}{
+		{
+			name:     "text",
+			style:    StepStyle{},
+			state:    "passed",
+			provider: "gitlab",
+		},
+		{
+			name:     "unicode",
+			style:    StepStyle{StateIcons: UnicodeStateIcons},
+			state:    "✓",
+			provider: "gitlab",
+		},
+		{
+			name:     "nerd font",
+			style:    StepStyle{StateIcons: NerdFontStateIcons, ProviderIcons: NerdFontProviderIcons},
+			state:    "\uf00c",
+			provider: "\uf296 gitlab",
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			values := pipeline.Values(testCase.style)
+			if s := values[ColumnState].String(); s != testCase.state {
+				t.Fatalf("expected state %q but got %q", testCase.state, s)
+			}
+			if s := values[ColumnName].String(); s != testCase.provider {
+				t.Fatalf("expected name %q but got %q", testCase.provider, s)
+			}
+		})
+	}
+}
+
+func TestPipeline_Compare(t *testing.T) {
+	style := StepStyle{}
+	testCases := []struct {
+		name   string
+		id     tui.ColumnID
+		lhs    Pipeline
+		rhs    Pipeline
+		result int
+	}{
+		{
+			name:   "numbers are compared numerically",
+			id:     ColumnPipeline,
+			lhs:    Pipeline{Number: "9", ProviderName: "gitlab"},
+			rhs:    Pipeline{Number: "10", ProviderName: "gitlab"},
+			result: -1,
+		},
+		{
+			name:   "states are compared by precedence",
+			id:     ColumnState,
+			lhs:    Pipeline{Step: Step{State: Running}},
+			rhs:    Pipeline{Step: Step{State: Passed}},
+			result: 1,
+		},
+		{
+			name:   "equal states",
+			id:     ColumnState,
+			lhs:    Pipeline{Step: Step{State: Failed}},
+			rhs:    Pipeline{Step: Step{State: Failed}},
+			result: 0,
+		},
+		{
+			name:   "version numbers of tags are compared numerically",
+			id:     ColumnRef,
+			lhs:    Pipeline{Ref: "v1.10.0", IsTag: true},
+			rhs:    Pipeline{Ref: "v1.9.2", IsTag: true},
+			result: 1,
+		},
+		{
+			name:   "a tag comes before longer tags it is a prefix of",
+			id:     ColumnRef,
+			lhs:    Pipeline{Ref: "v2.0.0", IsTag: true},
+			rhs:    Pipeline{Ref: "v2.0.0-rc1", IsTag: true},
+			result: -1,
+		},
+		{
+			name:   "branches are compared alphabetically",
+			id:     ColumnRef,
+			lhs:    Pipeline{Ref: "feature"},
+			rhs:    Pipeline{Ref: "master"},
+			result: -1,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			c := testCase.lhs.Compare(testCase.rhs, testCase.id, style)
+			if sign := utils.MinInt(utils.MaxInt(c, -1), 1); sign != testCase.result {
+				t.Fatalf("expected %d but got %d", testCase.result, c)
+			}
+		})
+	}
+}
+
+func TestStep_ValuesVariables(t *testing.T) {
+	step := Step{
+		Variables: []Variable{
+			{Name: "RUN_NIGHTLY_BUILD", Value: "true"},
+			{Name: "deploy_token", Value: "abcd"},
+			{Name: "TARGET", Value: "production", Secret: true},
+		},
+	}
+
+	values := step.Values(StepStyle{GitStyle: GitStyle{Location: time.UTC}})
+	expected := "RUN_NIGHTLY_BUILD=true deploy_token=**** TARGET=****"
+	if s := values[ColumnVariables].String(); s != expected {
+		t.Fatalf("expected %q but got %q", expected, s)
+	}
+}
+
+func TestStep_ValuesRunningDuration(t *testing.T) {
+	startedAt := time.Date(2020, 1, 30, 12, 0, 0, 0, time.UTC)
+	now := startedAt.Add(2*time.Minute + 5*time.Second)
+	style := StepStyle{
+		GitStyle: GitStyle{Location: time.UTC},
+		Now:      func() time.Time { return now },
+	}
+
+	testCases := []struct {
+		name     string
+		step     Step
+		style    StepStyle
+		expected string
+	}{
+		{
+			name:     "running step",
+			step:     Step{State: Running, StartedAt: utils.NullTime{Valid: true, Time: startedAt}},
+			style:    style,
+			expected: "2m05s",
+		},
+		{
+			name:     "running step without clock",
+			step:     Step{State: Running, StartedAt: utils.NullTime{Valid: true, Time: startedAt}},
+			style:    StepStyle{GitStyle: GitStyle{Location: time.UTC}},
+			expected: "-",
+		},
+		{
+			name:     "pending step",
+			step:     Step{State: Pending},
+			style:    style,
+			expected: "-",
+		},
+		{
+			name: "finished step",
+			step: Step{
+				State:     Passed,
+				StartedAt: utils.NullTime{Valid: true, Time: startedAt},
+				Duration:  utils.NullDuration{Valid: true, Duration: 42 * time.Second},
+			},
+			style:    style,
+			expected: "42s",
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			values := testCase.step.Values(testCase.style)
+			if s := values[ColumnDuration].String(); s != testCase.expected {
+				t.Fatalf("expected %q but got %q", testCase.expected, s)
+			}
+		})
+	}
+}
+
+func TestPipeline_ValuesRetryAt(t *testing.T) {
+	pipeline := Pipeline{
+		RetryAt: utils.NullTime{Valid: true, Time: time.Date(2020, 1, 30, 12, 3, 11, 0, time.UTC)},
+		Step:    Step{State: Running},
+	}
+
+	values := pipeline.Values(StepStyle{GitStyle: GitStyle{Location: time.UTC}})
+	expected := "running (stale, retrying at 12:03:11)"
+	if s := values[ColumnState].String(); s != expected {
+		t.Fatalf("expected %q but got %q", expected, s)
+	}
+}
+
+func TestPipeline_ValuesCoverage(t *testing.T) {
+	testCases := []struct {
+		coverage utils.NullFloat64
+		delta    utils.NullFloat64
+		expected string
+	}{
+		{
+			expected: "-",
+		},
+		{
+			coverage: utils.NullFloat64{Valid: true, Float64: 87.54},
+			expected: "87.5%",
+		},
+		{
+			coverage: utils.NullFloat64{Valid: true, Float64: 87.54},
+			delta:    utils.NullFloat64{Valid: true, Float64: 1.25},
+			expected: "87.5% (+1.2)",
+		},
+		{
+			coverage: utils.NullFloat64{Valid: true, Float64: 80},
+			delta:    utils.NullFloat64{Valid: true, Float64: -7.5},
+			expected: "80.0% (-7.5)",
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.expected, func(t *testing.T) {
+			pipeline := Pipeline{
+				CoverageDelta: testCase.delta,
+				Step:          Step{Coverage: testCase.coverage},
+			}
+			values := pipeline.Values(StepStyle{GitStyle: GitStyle{Location: time.UTC}})
+			if s := values[ColumnCoverage].String(); s != testCase.expected {
+				t.Fatalf("expected %q but got %q", testCase.expected, s)
+			}
+		})
+	}
+}
+
+func TestStep_withChildrenDurations(t *testing.T) {
+	at := func(minutes int) utils.NullTime {
+		return utils.NullTime{Valid: true, Time: time.Date(2020, 1, 1, 12, minutes, 0, 0, time.UTC)}
+	}
+	job := func(id string, start int, end int) Step {
+		return Step{
+			ID:         id,
+			Type:       StepJob,
+			State:      Passed,
+			StartedAt:  at(start),
+			FinishedAt: at(end),
+			Duration:   utils.NullSub(at(end), at(start)),
+		}
+	}
+
+	step := Step{
+		ID:    "1",
+		Type:  StepPipeline,
+		State: Running,
+		Children: []Step{
+			{
+				ID:       "1",
+				Type:     StepStage,
+				State:    Passed,
+				Children: []Step{job("1", 0, 2), job("2", 1, 5)},
+			},
+			{
+				ID:       "2",
+				Type:     StepStage,
+				State:    Running,
+				Children: []Step{job("3", 6, 7), {ID: "4", Type: StepJob, State: Running, StartedAt: at(6)}},
+			},
+		},
+	}
+
+	result := step.withChildrenDurations()
+	if d := result.Children[0].Duration; !d.Valid || d.Duration != 5*time.Minute {
+		t.Fatalf("expected a duration of 5 minutes for the first stage but got %v", d)
+	}
+	if d := result.Children[1]; d.Duration.Valid || !d.StartedAt.Valid || d.FinishedAt.Valid {
+		t.Fatalf("expected running stage to be started but not finished: %+v", d)
+	}
+	if step.Children[0].Duration.Valid {
+		t.Fatal("the original step must not be modified")
+	}
+}
+
+func TestStep_withChildrenStates(t *testing.T) {
+	job := func(id string, state State, allowFailure bool) Step {
+		return Step{
+			ID:           id,
+			Type:         StepJob,
+			State:        state,
+			AllowFailure: allowFailure,
+		}
+	}
+
+	step := Step{
+		ID:    "1",
+		Type:  StepPipeline,
+		State: Failed,
+		Children: []Step{
+			{
+				ID:       "1",
+				Type:     StepStage,
+				Children: []Step{job("1", Passed, false), job("2", Failed, true)},
+			},
+			{
+				ID:       "2",
+				Type:     StepStage,
+				Children: []Step{job("3", Failed, true)},
+			},
+			{
+				ID:       "3",
+				Type:     StepStage,
+				Children: []Step{job("4", Passed, false), job("5", Failed, false)},
+			},
+			{
+				ID:       "4",
+				Type:     StepStage,
+				State:    Canceled,
+				Children: []Step{job("6", Passed, false)},
+			},
+		},
+	}
+
+	result := step.withChildrenStates()
+	states := make([]State, 0)
+	for _, stage := range result.Children {
+		states = append(states, stage.State)
+	}
+	expected := []State{Passed, Passed, Failed, Canceled}
+	if diff := cmp.Diff(expected, states); len(diff) > 0 {
+		t.Fatal(diff)
+	}
+	if result.State != Failed {
+		t.Fatalf("expected the state of the pipeline to be left unchanged but got %q", result.State)
+	}
+	if step.Children[0].State != Unknown {
+		t.Fatal("the original step must not be modified")
+	}
+}
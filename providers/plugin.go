@@ -0,0 +1,182 @@
+package providers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"os/exec"
+	"strings"
+)
+
+// Version of the protocol spoken between cistern and plugins. It must be increased every time
+// a change breaks compatibility with existing plugins.
+const PluginProtocolVersion = 1
+
+// Methods a plugin may implement. Only pluginMethodPipeline is mandatory.
+const (
+	pluginMethodHandshake = "handshake"
+	pluginMethodPipeline  = "pipeline"
+	pluginMethodPipelines = "pipelines"
+	pluginMethodLog       = "log"
+)
+
+// PluginClient delegates the work of a CI provider to an external executable. For each
+// request, the executable is started, reads a JSON request on its standard input and writes a
+// JSON response on its standard output. Pipelines are described in the same format as for the
+// generic provider.
+type PluginClient struct {
+	command  []string
+	host     string
+	methods  map[string]struct{}
+	provider Provider
+}
+
+type pluginRequest struct {
+	Protocol int    `json:"protocol"`
+	Method   string `json:"method"`
+	URL      string `json:"url,omitempty"`
+	Sha      string `json:"sha,omitempty"`
+	Key      string `json:"key,omitempty"`
+}
+
+type pluginResponse struct {
+	Protocol int              `json:"protocol"`
+	Error    string           `json:"error"`
+	Host     string           `json:"host"`
+	Methods  []string         `json:"methods"`
+	Pipeline *genericPipeline `json:"pipeline"`
+	URLs     []string         `json:"urls"`
+	Log      string           `json:"log"`
+}
+
+// Create a client for the plugin started by command. The plugin is asked for the host of the
+// CI provider and for the methods it implements, which also makes sure that cistern and the
+// plugin speak the same version of the protocol.
+func NewPluginClient(ctx context.Context, id string, name string, command []string) (PluginClient, error) {
+	if len(command) == 0 {
+		return PluginClient{}, errors.New("the command of a plugin must not be empty")
+	}
+
+	c := PluginClient{
+		command: command,
+		methods: make(map[string]struct{}),
+		provider: Provider{
+			ID:   id,
+			Name: name,
+		},
+	}
+
+	resp, err := c.call(ctx, pluginRequest{Method: pluginMethodHandshake})
+	if err != nil {
+		return PluginClient{}, err
+	}
+	if resp.Host == "" {
+		return PluginClient{}, fmt.Errorf("plugin %q: handshake response must include the host of the provider", c.command[0])
+	}
+	c.host = resp.Host
+	for _, method := range resp.Methods {
+		c.methods[method] = struct{}{}
+	}
+	if _, exists := c.methods[pluginMethodPipeline]; !exists {
+		return PluginClient{}, fmt.Errorf("plugin %q: method %q must be implemented", c.command[0], pluginMethodPipeline)
+	}
+
+	return c, nil
+}
+
+func (c PluginClient) ID() string {
+	return c.provider.ID
+}
+
+func (c PluginClient) Host() string {
+	return c.host
+}
+
+func (c PluginClient) Name() string {
+	return c.provider.Name
+}
+
+func (c PluginClient) BuildFromURL(ctx context.Context, u string) (Pipeline, error) {
+	// Avoid starting the plugin for urls it cannot possibly handle
+	v, err := url.Parse(u)
+	if err != nil {
+		return Pipeline{}, err
+	}
+	if v.Host != c.host && v.Hostname() != c.host {
+		return Pipeline{}, ErrUnknownPipelineURL
+	}
+
+	resp, err := c.call(ctx, pluginRequest{Method: pluginMethodPipeline, URL: u})
+	if err != nil {
+		return Pipeline{}, err
+	}
+	// A response without pipeline means the url is not handled by the plugin
+	if resp.Pipeline == nil {
+		return Pipeline{}, ErrUnknownPipelineURL
+	}
+
+	return resp.Pipeline.toPipeline()
+}
+
+func (c PluginClient) PipelineURLs(ctx context.Context, sha string) ([]string, error) {
+	if _, exists := c.methods[pluginMethodPipelines]; !exists {
+		return nil, nil
+	}
+
+	resp, err := c.call(ctx, pluginRequest{Method: pluginMethodPipelines, Sha: sha})
+	if err != nil {
+		return nil, err
+	}
+
+	return resp.URLs, nil
+}
+
+func (c PluginClient) Log(ctx context.Context, step Step) (string, error) {
+	if _, exists := c.methods[pluginMethodLog]; !exists || step.Log.Key == "" {
+		return "", ErrNoLogHere
+	}
+
+	resp, err := c.call(ctx, pluginRequest{Method: pluginMethodLog, Key: step.Log.Key})
+	if err != nil {
+		return "", err
+	}
+
+	return resp.Log, nil
+}
+
+func (c PluginClient) call(ctx context.Context, req pluginRequest) (pluginResponse, error) {
+	var resp pluginResponse
+	req.Protocol = PluginProtocolVersion
+
+	stdin, err := json.Marshal(req)
+	if err != nil {
+		return resp, err
+	}
+
+	cmd := exec.CommandContext(ctx, c.command[0], c.command[1:]...)
+	cmd.Stdin = bytes.NewReader(stdin)
+	stderr := new(bytes.Buffer)
+	cmd.Stderr = stderr
+	stdout, err := cmd.Output()
+	if err != nil {
+		if ctx.Err() != nil {
+			return resp, ctx.Err()
+		}
+		return resp, fmt.Errorf("plugin %q: %s request failed: %w (%s)", c.command[0], req.Method, err, strings.TrimSpace(stderr.String()))
+	}
+
+	if err := json.Unmarshal(stdout, &resp); err != nil {
+		return resp, fmt.Errorf("plugin %q: invalid response to %s request: %w", c.command[0], req.Method, err)
+	}
+	if resp.Protocol != PluginProtocolVersion {
+		return resp, fmt.Errorf("plugin %q: unsupported protocol version %d (expected %d)", c.command[0], resp.Protocol, PluginProtocolVersion)
+	}
+	if resp.Error != "" {
+		return resp, fmt.Errorf("plugin %q: %s", c.command[0], resp.Error)
+	}
+
+	return resp, nil
+}
@@ -0,0 +1,168 @@
+package providers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+// Not an actual test: this function turns the test executable into a plugin when started by
+// the tests below
+func TestPluginHelperProcess(t *testing.T) {
+	if os.Getenv("CISTERN_TEST_PLUGIN") == "" {
+		return
+	}
+	defer os.Exit(0)
+
+	var req pluginRequest
+	if err := json.NewDecoder(os.Stdin).Decode(&req); err != nil {
+		fmt.Fprint(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	resp := map[string]interface{}{
+		"protocol": PluginProtocolVersion,
+	}
+	switch os.Getenv("CISTERN_TEST_PLUGIN") {
+	case "outdated":
+		resp["protocol"] = PluginProtocolVersion + 1
+	case "crash":
+		fmt.Fprint(os.Stderr, "crash")
+		os.Exit(1)
+	}
+
+	bs, err := ioutil.ReadFile(genericTestFile)
+	if err != nil {
+		fmt.Fprint(os.Stderr, err)
+		os.Exit(1)
+	}
+	var feed struct {
+		Pipelines []map[string]interface{} `json:"pipelines"`
+	}
+	if err := json.Unmarshal(bs, &feed); err != nil {
+		fmt.Fprint(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	switch req.Method {
+	case pluginMethodHandshake:
+		resp["host"] = "ci.example.com"
+		resp["methods"] = []string{pluginMethodPipeline, pluginMethodLog}
+	case pluginMethodPipeline:
+		for _, p := range feed.Pipelines {
+			if p["url"] == req.URL {
+				resp["pipeline"] = p
+			}
+		}
+	case pluginMethodLog:
+		resp["log"] = "log of " + req.Key
+	default:
+		resp["error"] = fmt.Sprintf("unknown method %q", req.Method)
+	}
+
+	if err := json.NewEncoder(os.Stdout).Encode(resp); err != nil {
+		fmt.Fprint(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+func setupPluginTestClient(t *testing.T, mode string) (PluginClient, func(), error) {
+	if err := os.Setenv("CISTERN_TEST_PLUGIN", mode); err != nil {
+		t.Fatal(err)
+	}
+	command := []string{os.Args[0], "-test.run=TestPluginHelperProcess"}
+	client, err := NewPluginClient(context.Background(), "plugin-0", "plugin", command)
+
+	return client, func() { os.Unsetenv("CISTERN_TEST_PLUGIN") }, err
+}
+
+func TestNewPluginClient(t *testing.T) {
+	t.Run("handshake", func(t *testing.T) {
+		client, teardown, err := setupPluginTestClient(t, "ok")
+		defer teardown()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if client.Host() != "ci.example.com" {
+			t.Fatalf("expected host %q but got %q", "ci.example.com", client.Host())
+		}
+	})
+
+	t.Run("protocol version mismatch", func(t *testing.T) {
+		_, teardown, err := setupPluginTestClient(t, "outdated")
+		defer teardown()
+		if err == nil {
+			t.Fatal("expected error but got nil")
+		}
+	})
+
+	t.Run("plugin failure", func(t *testing.T) {
+		_, teardown, err := setupPluginTestClient(t, "crash")
+		defer teardown()
+		if err == nil {
+			t.Fatal("expected error but got nil")
+		}
+	})
+}
+
+func TestPluginClient_BuildFromURL(t *testing.T) {
+	client, teardown, err := setupPluginTestClient(t, "ok")
+	defer teardown()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("known url", func(t *testing.T) {
+		pipeline, err := client.BuildFromURL(context.Background(), "https://ci.example.com/builds/43")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if pipeline.Number != "43" || pipeline.Ref != "0.1.0" || !pipeline.IsTag || pipeline.State != Running {
+			t.Fatalf("unexpected pipeline: %+v", pipeline)
+		}
+	})
+
+	t.Run("unknown url", func(t *testing.T) {
+		_, err := client.BuildFromURL(context.Background(), "https://ci.example.com/builds/44")
+		if err != ErrUnknownPipelineURL {
+			t.Fatalf("expected %v but got %v", ErrUnknownPipelineURL, err)
+		}
+	})
+
+	t.Run("url of another host", func(t *testing.T) {
+		_, err := client.BuildFromURL(context.Background(), "https://example.com/builds/42")
+		if err != ErrUnknownPipelineURL {
+			t.Fatalf("expected %v but got %v", ErrUnknownPipelineURL, err)
+		}
+	})
+}
+
+func TestPluginClient_Log(t *testing.T) {
+	client, teardown, err := setupPluginTestClient(t, "ok")
+	defer teardown()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	log, err := client.Log(context.Background(), Step{Log: Log{Key: "42"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff("log of 42", log); len(diff) > 0 {
+		t.Fatal(diff)
+	}
+
+	// The plugin does not implement the "pipelines" method
+	urls, err := client.PipelineURLs(context.Background(), "a24840cf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(urls) > 0 {
+		t.Fatalf("expected no url but got %v", urls)
+	}
+}
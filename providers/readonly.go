@@ -0,0 +1,44 @@
+package providers
+
+import (
+	"errors"
+	"sort"
+)
+
+var ErrReadOnly = errors.New("this action requires an API token for the provider of the pipeline")
+
+// Providers implementing Authenticator tell whether they were configured with credentials.
+// Providers without credentials only see public repositories, are subject to lower rate
+// limits and cannot take actions such as running a pipeline again.
+type Authenticator interface {
+	Authenticated() bool
+}
+
+// Return the IDs of the providers used without credentials
+func (c *Cache) AnonymousProviders() []string {
+	anonymous := make(map[string]struct{})
+	for id, provider := range c.ciProvidersByID {
+		if !authenticated(provider) {
+			anonymous[id] = struct{}{}
+		}
+	}
+	for _, provider := range c.sourceProviders {
+		if !authenticated(provider) {
+			anonymous[provider.ID()] = struct{}{}
+		}
+	}
+
+	ids := make([]string, 0, len(anonymous))
+	for id := range anonymous {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	return ids
+}
+
+// Return false if the provider was configured without credentials
+func authenticated(provider interface{}) bool {
+	a, ok := provider.(Authenticator)
+	return !ok || a.Authenticated()
+}
@@ -0,0 +1,97 @@
+package providers
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-github/v29/github"
+	"github.com/nbedos/cistern/utils"
+)
+
+func TestCache_AnonymousProviders(t *testing.T) {
+	ctx := context.Background()
+	token := "token"
+	anonymousGitLab, err := NewGitLabClient("gitlab-0", "gitlab", "", "", 0, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	authenticatedGitLab, err := NewGitLabClient("gitlab-1", "gitlab", "", token, 0, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	anonymousGitHub := NewGitHubClient(ctx, "github-0", nil, nil, nil)
+	authenticatedGitHub := NewGitHubClient(ctx, "github-1", &token, nil, nil)
+
+	c := NewCache(
+		[]CIProvider{anonymousGitLab, authenticatedGitLab, &testProvider{"ci", "ci.example.com", 0}},
+		[]SourceProvider{anonymousGitLab, authenticatedGitLab, anonymousGitHub, authenticatedGitHub},
+		utils.PollingStrategy{},
+	)
+
+	expected := []string{"github-0", "gitlab-0"}
+	if diff := cmp.Diff(expected, c.AnonymousProviders()); len(diff) > 0 {
+		t.Fatal(diff)
+	}
+}
+
+func TestGitHubClient_explain(t *testing.T) {
+	token := "token"
+	rateLimitErr := &github.RateLimitError{
+		Response: &http.Response{
+			Request:    &http.Request{Method: "GET", URL: &url.URL{Scheme: "https", Host: "api.github.com"}},
+			StatusCode: 403,
+		},
+		Message: "API rate limit exceeded",
+	}
+
+	t.Run("unauthenticated client", func(t *testing.T) {
+		c := NewGitHubClient(context.Background(), "github", nil, nil, nil)
+		if err := c.explain(rateLimitErr); !strings.Contains(err.Error(), "[[providers.github]]") {
+			t.Fatalf("expected the error to suggest setting a token but got %q", err)
+		}
+		if err := c.explain(ErrUnknownRepositoryURL); err != ErrUnknownRepositoryURL {
+			t.Fatalf("expected %v but got %v", ErrUnknownRepositoryURL, err)
+		}
+	})
+
+	t.Run("authenticated client", func(t *testing.T) {
+		c := NewGitHubClient(context.Background(), "github", &token, nil, nil)
+		if err := c.explain(rateLimitErr); err != rateLimitErr {
+			t.Fatalf("expected %v but got %v", rateLimitErr, err)
+		}
+	})
+}
+
+func TestCache_RerunReadOnly(t *testing.T) {
+	client, testURL, teardown, err := setupGitLabTestServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer teardown()
+	client.provider = Provider{ID: "gitlab", Name: "gitlab"}
+
+	c := NewCache([]CIProvider{client}, nil, utils.PollingStrategy{})
+	p := Pipeline{
+		providerID:   "gitlab",
+		ProviderHost: "gitlab.com",
+		Ref:          "master",
+		Step: Step{
+			ID: "103230300",
+			WebURL: utils.NullString{
+				Valid:  true,
+				String: testURL + "/long/namespace/nbedos/cistern/pipelines/103230300",
+			},
+		},
+	}
+	if _, err := c.SavePipeline("sha", p); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := c.Rerun(context.Background(), p.Key(), nil); err != ErrReadOnly {
+		t.Fatalf("expected %v but got %v", ErrReadOnly, err)
+	}
+}
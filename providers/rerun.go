@@ -0,0 +1,62 @@
+package providers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+var ErrRerunNotSupported = errors.New("the provider of this pipeline cannot run it again with different variables")
+
+// CI providers implementing Rerunner can run a pipeline again with different variables
+type Rerunner interface {
+	// Run the pipeline 'p' again with 'variables' and return the URL of the new pipeline
+	Rerun(ctx context.Context, p Pipeline, variables []Variable) (string, error)
+}
+
+// Run the pipeline identified by 'key' again with 'variables'. Variables whose value is
+// "****", the masked value of secrets, keep the value they had in the pipeline. The result is
+// the URL of the new pipeline.
+func (c *Cache) Rerun(ctx context.Context, key PipelineKey, variables []Variable) (string, error) {
+	pipeline, exists := c.Pipeline(key)
+	if !exists {
+		return "", fmt.Errorf("no matching pipeline for %v", key)
+	}
+	provider, exists := c.ciProvidersByID[pipeline.providerID]
+	if !exists {
+		return "", fmt.Errorf("no matching Provider found in cache for account ID %q", pipeline.providerID)
+	}
+	rerunner, ok := provider.(Rerunner)
+	if !ok {
+		return "", ErrRerunNotSupported
+	}
+	if !authenticated(provider) {
+		return "", ErrReadOnly
+	}
+
+	previous := make(map[string]string, len(pipeline.Variables))
+	for _, v := range pipeline.Variables {
+		previous[v.Name] = v.Value
+	}
+	unmasked := make([]Variable, 0, len(variables))
+	for _, v := range variables {
+		if value, exists := previous[v.Name]; exists && v.Value == MaskedValue {
+			v.Value = value
+		}
+		unmasked = append(unmasked, v)
+	}
+
+	ctx, cancel := requestContext(ctx, c.timeout())
+	defer cancel()
+	u, err := rerunner.Rerun(ctx, pipeline, unmasked)
+	if err != nil {
+		return "", ProviderError{
+			ProviderID: provider.ID(),
+			Operation:  "rerun " + pipelineName(pipeline),
+			URL:        pipeline.WebURL.String,
+			Err:        err,
+		}
+	}
+
+	return u, nil
+}
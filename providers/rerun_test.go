@@ -0,0 +1,76 @@
+package providers
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/nbedos/cistern/utils"
+)
+
+type rerunProvider struct {
+	testProvider
+	variables []Variable
+}
+
+func (p *rerunProvider) Rerun(ctx context.Context, pipeline Pipeline, variables []Variable) (string, error) {
+	p.variables = variables
+	return "https://ci.example.com/pipelines/43", nil
+}
+
+func TestCache_Rerun(t *testing.T) {
+	pipeline := Pipeline{
+		providerID:   "ci",
+		ProviderHost: "ci.example.com",
+		Step: Step{
+			ID: "42",
+			Variables: []Variable{
+				{Name: "API_TOKEN", Value: "secret"},
+				{Name: "DEPLOY", Value: "true"},
+			},
+		},
+	}
+
+	t.Run("secret values are not replaced by their masked value", func(t *testing.T) {
+		provider := &rerunProvider{testProvider: testProvider{id: "ci", url: "ci.example.com"}}
+		c := NewCache([]CIProvider{provider}, nil, utils.PollingStrategy{})
+		if _, err := c.SavePipeline("sha", pipeline); err != nil {
+			t.Fatal(err)
+		}
+
+		variables := []Variable{
+			{Name: "API_TOKEN", Value: MaskedValue},
+			{Name: "DEPLOY", Value: "false"},
+			{Name: "PASSWORD", Value: MaskedValue},
+		}
+		u, err := c.Rerun(context.Background(), pipeline.Key(), variables)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if expected := "https://ci.example.com/pipelines/43"; u != expected {
+			t.Fatalf("expected %q but got %q", expected, u)
+		}
+
+		expected := []Variable{
+			{Name: "API_TOKEN", Value: "secret"},
+			{Name: "DEPLOY", Value: "false"},
+			{Name: "PASSWORD", Value: MaskedValue},
+		}
+		if diff := cmp.Diff(expected, provider.variables); len(diff) > 0 {
+			t.Fatal(diff)
+		}
+		if variables[0].Value != MaskedValue {
+			t.Fatal("the variables of the caller must not be modified")
+		}
+	})
+
+	t.Run("provider without rerun support", func(t *testing.T) {
+		c := NewCache([]CIProvider{&testProvider{"ci", "ci.example.com", 0}}, nil, utils.PollingStrategy{})
+		if _, err := c.SavePipeline("sha", pipeline); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := c.Rerun(context.Background(), pipeline.Key(), nil); err != ErrRerunNotSupported {
+			t.Fatalf("expected %v but got %v", ErrRerunNotSupported, err)
+		}
+	})
+}
@@ -0,0 +1,93 @@
+package providers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/go-github/v29/github"
+	"github.com/nbedos/cistern/utils"
+	"github.com/xanzy/go-gitlab"
+)
+
+// Maximum number of consecutive requests rescheduled after a transient error before the
+// error is reported
+const maxRetries = 5
+
+// Return the time at which the request that failed with 'err' can be sent again. Only the
+// errors likely to be transient (status 429 or 5xx) for which the provider set the
+// Retry-After header can be retried.
+func retryTime(err error, now time.Time) (time.Time, bool) {
+	var status int
+	var header string
+
+	var httpErr HTTPError
+	var abuseErr *github.AbuseRateLimitError
+	var githubErr *github.ErrorResponse
+	var gitlabErr *gitlab.ErrorResponse
+	switch {
+	case errors.As(err, &httpErr):
+		status, header = httpErr.Status, httpErr.RetryAfter
+	case errors.As(err, &abuseErr):
+		// go-github has already parsed the Retry-After header
+		if abuseErr.RetryAfter == nil {
+			return time.Time{}, false
+		}
+		return now.Add(*abuseErr.RetryAfter), true
+	case errors.As(err, &githubErr) && githubErr.Response != nil:
+		status, header = githubErr.Response.StatusCode, githubErr.Response.Header.Get("Retry-After")
+	case errors.As(err, &gitlabErr) && gitlabErr.Response != nil:
+		status, header = gitlabErr.Response.StatusCode, gitlabErr.Response.Header.Get("Retry-After")
+	default:
+		return time.Time{}, false
+	}
+
+	if status != http.StatusTooManyRequests && (status < 500 || status > 599) {
+		return time.Time{}, false
+	}
+
+	return parseRetryAfter(header, now)
+}
+
+// Parse the value of a Retry-After header, either a number of seconds or an HTTP date.
+func parseRetryAfter(header string, now time.Time) (time.Time, bool) {
+	header = strings.TrimSpace(header)
+	if header == "" {
+		return time.Time{}, false
+	}
+	if seconds, err := strconv.Atoi(header); err == nil {
+		if seconds < 0 {
+			return time.Time{}, false
+		}
+		return now.Add(time.Duration(seconds) * time.Second), true
+	}
+	t, err := http.ParseTime(header)
+	if err != nil {
+		return time.Time{}, false
+	}
+	if t.Before(now) {
+		return now, true
+	}
+	return t, true
+}
+
+// Call f until it succeeds, fails with an error that cannot be retried or fails more than
+// maxRetries times in a row. Between two calls, wait for as long as the provider asked.
+func withRetries(ctx context.Context, clock utils.Clock, f func() error) error {
+	for retries := 0; ; retries++ {
+		err := f()
+		at, ok := retryTime(err, clock.Now())
+		if !ok || retries >= maxRetries {
+			return err
+		}
+		select {
+		case <-clock.After(at.Sub(clock.Now())):
+			// Do nothing
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
@@ -0,0 +1,186 @@
+package providers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/google/go-github/v29/github"
+	"github.com/nbedos/cistern/utils"
+	"github.com/xanzy/go-gitlab"
+)
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2020, 1, 30, 12, 0, 0, 0, time.UTC)
+	testCases := []struct {
+		name   string
+		header string
+		at     time.Time
+		ok     bool
+	}{
+		{
+			name:   "delay in seconds",
+			header: "120",
+			at:     now.Add(2 * time.Minute),
+			ok:     true,
+		},
+		{
+			name:   "HTTP date",
+			header: "Thu, 30 Jan 2020 12:05:00 GMT",
+			at:     now.Add(5 * time.Minute),
+			ok:     true,
+		},
+		{
+			name:   "HTTP date in the past",
+			header: "Thu, 30 Jan 2020 11:00:00 GMT",
+			at:     now,
+			ok:     true,
+		},
+		{
+			name:   "missing header",
+			header: "",
+		},
+		{
+			name:   "negative delay",
+			header: "-1",
+		},
+		{
+			name:   "invalid value",
+			header: "tomorrow",
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			at, ok := parseRetryAfter(testCase.header, now)
+			if ok != testCase.ok || !at.Equal(testCase.at) {
+				t.Fatalf("expected (%v, %v) but got (%v, %v)", testCase.at, testCase.ok, at, ok)
+			}
+		})
+	}
+}
+
+func TestRetryTime(t *testing.T) {
+	now := time.Date(2020, 1, 30, 12, 0, 0, 0, time.UTC)
+	response := func(status int, retryAfter string) *http.Response {
+		header := make(http.Header)
+		if retryAfter != "" {
+			header.Set("Retry-After", retryAfter)
+		}
+		return &http.Response{StatusCode: status, Header: header}
+	}
+	delay := 30 * time.Second
+
+	testCases := []struct {
+		name string
+		err  error
+		ok   bool
+	}{
+		{
+			name: "too many requests",
+			err:  HTTPError{Status: 429, RetryAfter: "30"},
+			ok:   true,
+		},
+		{
+			name: "service unavailable",
+			err:  HTTPError{Status: 503, RetryAfter: "30"},
+			ok:   true,
+		},
+		{
+			name: "wrapped error",
+			err:  ProviderError{ProviderID: "travis", Err: fmt.Errorf("fetch: %w", HTTPError{Status: 502, RetryAfter: "30"})},
+			ok:   true,
+		},
+		{
+			name: "GitLab",
+			err:  &gitlab.ErrorResponse{Response: response(503, "30")},
+			ok:   true,
+		},
+		{
+			name: "GitHub",
+			err:  &github.ErrorResponse{Response: response(429, "30")},
+			ok:   true,
+		},
+		{
+			name: "GitHub abuse detection",
+			err:  &github.AbuseRateLimitError{Response: response(403, ""), RetryAfter: &delay},
+			ok:   true,
+		},
+		{
+			name: "server error without Retry-After",
+			err:  HTTPError{Status: 500},
+		},
+		{
+			name: "client error",
+			err:  HTTPError{Status: 404, RetryAfter: "30"},
+		},
+		{
+			name: "other error",
+			err:  errors.New("connection refused"),
+		},
+		{
+			name: "no error",
+			err:  nil,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			at, ok := retryTime(testCase.err, now)
+			if ok != testCase.ok {
+				t.Fatalf("expected %v but got %v", testCase.ok, ok)
+			}
+			if ok && !at.Equal(now.Add(delay)) {
+				t.Fatalf("expected %v but got %v", now.Add(delay), at)
+			}
+		})
+	}
+}
+
+func TestWithRetries(t *testing.T) {
+	overloaded := HTTPError{Status: 503, RetryAfter: "120"}
+
+	t.Run("transient errors are retried when the provider says so", func(t *testing.T) {
+		start := time.Date(2020, 1, 30, 12, 0, 0, 0, time.UTC)
+		clock := utils.NewVirtualClock(start)
+		calls := 0
+		err := withRetries(context.Background(), clock, func() error {
+			if calls++; calls < 3 {
+				return overloaded
+			}
+			return nil
+		})
+		if err != nil || calls != 3 {
+			t.Fatalf("expected success after 3 calls but got %v after %d calls", err, calls)
+		}
+		if elapsed := clock.Now().Sub(start); elapsed != 4*time.Minute {
+			t.Fatalf("expected to wait for 4 minutes but waited for %v", elapsed)
+		}
+	})
+
+	t.Run("the error is returned after too many retries", func(t *testing.T) {
+		calls := 0
+		err := withRetries(context.Background(), utils.NewVirtualClock(time.Time{}), func() error {
+			calls++
+			return overloaded
+		})
+		if err != overloaded || calls != maxRetries+1 {
+			t.Fatalf("expected %v after %d calls but got %v after %d calls", overloaded, maxRetries+1, err, calls)
+		}
+	})
+
+	t.Run("other errors are not retried", func(t *testing.T) {
+		calls := 0
+		expected := HTTPError{Status: 404}
+		err := withRetries(context.Background(), utils.NewVirtualClock(time.Time{}), func() error {
+			calls++
+			return expected
+		})
+		if err != expected || calls != 1 {
+			t.Fatalf("expected %v after 1 call but got %v after %d calls", expected, err, calls)
+		}
+	})
+}
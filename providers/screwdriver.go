@@ -0,0 +1,491 @@
+package providers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nbedos/cistern/utils"
+)
+
+type ScrewdriverClient struct {
+	baseURL     url.URL
+	apiURL      url.URL
+	httpClient  *http.Client
+	rateLimiter <-chan time.Time
+	token       string
+	jwt         *screwdriverJWT
+	provider    Provider
+	// Requests sent through httpClient
+	requests *requestCounter
+}
+
+// Screwdriver API tokens must be exchanged for a JSON Web Token which is then used to
+// authenticate requests. The JWT is shared by all copies of the client.
+type screwdriverJWT struct {
+	mux   *sync.Mutex
+	value string
+}
+
+var ScrewdriverURL = url.URL{Scheme: "https", Host: "cd.screwdriver.cd"}
+var ScrewdriverAPIURL = url.URL{Scheme: "https", Host: "api.screwdriver.cd"}
+
+func NewScrewdriverClient(id string, name string, token string, URL string, apiURL string, requestsPerSecond float64) (ScrewdriverClient, error) {
+	rateLimit := time.Second / 10
+	if requestsPerSecond > 0 {
+		rateLimit = time.Second / time.Duration(requestsPerSecond)
+	}
+
+	u := ScrewdriverURL
+	if URL != "" {
+		v, err := url.Parse(URL)
+		if err != nil {
+			return ScrewdriverClient{}, err
+		}
+		u = *v
+	}
+	u.Path = strings.TrimSuffix(u.Path, "/")
+
+	a := ScrewdriverAPIURL
+	if apiURL != "" {
+		v, err := url.Parse(apiURL)
+		if err != nil {
+			return ScrewdriverClient{}, err
+		}
+		a = *v
+	}
+	a.Path = strings.TrimSuffix(a.Path, "/")
+
+	httpClient, requests := countedClient(nil)
+
+	return ScrewdriverClient{
+		baseURL:     u,
+		apiURL:      a,
+		httpClient:  httpClient,
+		requests:    requests,
+		rateLimiter: time.Tick(rateLimit),
+		token:       token,
+		jwt: &screwdriverJWT{
+			mux: &sync.Mutex{},
+		},
+		provider: Provider{
+			ID:   id,
+			Name: name,
+		},
+	}, nil
+}
+
+func (c ScrewdriverClient) RequestStats() RequestStats {
+	return c.requests.snapshot()
+}
+
+func (c ScrewdriverClient) ID() string {
+	return c.provider.ID
+}
+
+func (c ScrewdriverClient) Host() string {
+	return c.baseURL.Host
+}
+
+func (c ScrewdriverClient) Name() string {
+	return c.provider.Name
+}
+
+func (c ScrewdriverClient) BuildFromURL(ctx context.Context, u string) (Pipeline, error) {
+	pipelineID, resource, id, err := parseScrewdriverWebURL(&c.baseURL, u)
+	if err != nil {
+		return Pipeline{}, err
+	}
+
+	eventID := id
+	if resource == "builds" {
+		var build screwdriverBuild
+		if _, err := c.getJSON(ctx, c.endpoint("/v4/builds/%d", id), &build); err != nil {
+			return Pipeline{}, err
+		}
+		eventID = build.EventID
+	}
+
+	return c.fetchPipeline(ctx, pipelineID, eventID)
+}
+
+// Extract pipeline ID, resource type ("builds" or "events") and resource ID from web url
+func parseScrewdriverWebURL(baseURL *url.URL, u string) (int, string, int, error) {
+	v, err := url.Parse(u)
+	if err != nil {
+		return 0, "", 0, err
+	}
+
+	if v.Hostname() != baseURL.Hostname() {
+		return 0, "", 0, ErrUnknownPipelineURL
+	}
+
+	// url format: https://cd.screwdriver.cd/pipelines/1234/builds/5678
+	//         OR  https://cd.screwdriver.cd/pipelines/1234/events/9012
+	cs := strings.Split(strings.TrimPrefix(v.Path, baseURL.Path), "/")
+	if len(cs) < 5 || cs[1] != "pipelines" || (cs[3] != "builds" && cs[3] != "events") {
+		return 0, "", 0, ErrUnknownPipelineURL
+	}
+
+	pipelineID, err := strconv.Atoi(cs[2])
+	if err != nil {
+		return 0, "", 0, err
+	}
+	id, err := strconv.Atoi(cs[4])
+	if err != nil {
+		return 0, "", 0, err
+	}
+
+	return pipelineID, cs[3], id, nil
+}
+
+func (c ScrewdriverClient) endpoint(format string, a ...interface{}) url.URL {
+	endpoint := c.apiURL
+	endpoint.Path += fmt.Sprintf(format, a...)
+
+	return endpoint
+}
+
+func (c ScrewdriverClient) webURL(format string, a ...interface{}) string {
+	webURL := c.baseURL
+	webURL.Path += fmt.Sprintf(format, a...)
+
+	return webURL.String()
+}
+
+func (c ScrewdriverClient) fetchPipeline(ctx context.Context, pipelineID int, eventID int) (Pipeline, error) {
+	var event screwdriverEvent
+	if _, err := c.getJSON(ctx, c.endpoint("/v4/events/%d", eventID), &event); err != nil {
+		return Pipeline{}, err
+	}
+
+	var builds []screwdriverBuild
+	if _, err := c.getJSON(ctx, c.endpoint("/v4/events/%d/builds", eventID), &builds); err != nil {
+		return Pipeline{}, err
+	}
+
+	var jobs []screwdriverJob
+	if _, err := c.getJSON(ctx, c.endpoint("/v4/pipelines/%d/jobs", pipelineID), &jobs); err != nil {
+		return Pipeline{}, err
+	}
+	jobNameByID := make(map[int]string, len(jobs))
+	for _, job := range jobs {
+		jobNameByID[job.ID] = job.Name
+	}
+
+	pipeline, err := event.toPipeline(c.webURL("/pipelines/%d/events/%d", pipelineID, eventID))
+	if err != nil {
+		return Pipeline{}, err
+	}
+
+	sort.Slice(builds, func(i, j int) bool {
+		return builds[i].ID < builds[j].ID
+	})
+	for _, build := range builds {
+		name := jobNameByID[build.JobID]
+		webURL := c.webURL("/pipelines/%d/builds/%d", pipelineID, build.ID)
+		logURL := c.endpoint("/v4/builds/%d/steps", build.ID)
+		job, err := build.toStep(name, webURL, logURL)
+		if err != nil {
+			return Pipeline{}, err
+		}
+		pipeline.Children = append(pipeline.Children, job)
+	}
+
+	// Events have no status of their own
+	aggregate := Aggregate(pipeline.Children)
+	pipeline.State = aggregate.State
+	pipeline.StartedAt = aggregate.StartedAt
+	pipeline.FinishedAt = aggregate.FinishedAt
+	if !pipeline.State.IsActive() {
+		pipeline.Duration = utils.NullSub(pipeline.FinishedAt, pipeline.StartedAt)
+	} else {
+		pipeline.FinishedAt = utils.NullTime{}
+	}
+	pipeline.UpdatedAt = utils.MaxNullTime(pipeline.CreatedAt, pipeline.StartedAt, pipeline.FinishedAt)
+
+	return pipeline, nil
+}
+
+// Step logs are stored by the Screwdriver store and served by the API in pages of lines. The
+// header "X-More-Data" tells if more pages are available.
+func (c ScrewdriverClient) Log(ctx context.Context, step Step) (string, error) {
+	if step.Log.Key == "" {
+		return "", ErrNoLogHere
+	}
+
+	u, err := url.Parse(step.Log.Key)
+	if err != nil {
+		return "", err
+	}
+
+	builder := strings.Builder{}
+	for from, more := 0, true; more; {
+		params := u.Query()
+		params.Set("from", strconv.Itoa(from))
+		u.RawQuery = params.Encode()
+
+		var lines []struct {
+			N int    `json:"n"`
+			M string `json:"m"`
+		}
+		header, err := c.getJSON(ctx, *u, &lines)
+		if err != nil {
+			return "", err
+		}
+
+		for _, line := range lines {
+			builder.WriteString(line.M)
+			builder.WriteString("\n")
+			from = line.N + 1
+		}
+		more = len(lines) > 0 && header.Get("X-More-Data") == "true"
+	}
+
+	return builder.String(), nil
+}
+
+// Return a JWT for authenticating requests, requesting a new one if needed
+func (c ScrewdriverClient) authenticate(ctx context.Context, renew bool) (string, error) {
+	if c.token == "" {
+		return "", nil
+	}
+
+	c.jwt.mux.Lock()
+	defer c.jwt.mux.Unlock()
+	if c.jwt.value != "" && !renew {
+		return c.jwt.value, nil
+	}
+
+	u := c.endpoint("/v4/auth/token")
+	params := u.Query()
+	params.Set("api_token", c.token)
+	u.RawQuery = params.Encode()
+
+	var response struct {
+		Token string `json:"token"`
+	}
+	if _, err := c.do(ctx, u, "", &response); err != nil {
+		return "", err
+	}
+	c.jwt.value = response.Token
+
+	return c.jwt.value, nil
+}
+
+func (c ScrewdriverClient) getJSON(ctx context.Context, u url.URL, v interface{}) (http.Header, error) {
+	jwt, err := c.authenticate(ctx, false)
+	if err != nil {
+		return nil, err
+	}
+
+	header, err := c.do(ctx, u, jwt, v)
+	if e, ok := err.(HTTPError); ok && e.Status == 401 && jwt != "" {
+		// The JWT may have expired
+		if jwt, err = c.authenticate(ctx, true); err != nil {
+			return nil, err
+		}
+		return c.do(ctx, u, jwt, v)
+	}
+
+	return header, err
+}
+
+func (c ScrewdriverClient) do(ctx context.Context, u url.URL, jwt string, v interface{}) (http.Header, error) {
+	req, err := http.NewRequest("GET", u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Add("Accept", "application/json")
+	if jwt != "" {
+		req.Header.Add("Authorization", fmt.Sprintf("Bearer %s", jwt))
+	}
+	req = req.WithContext(ctx)
+
+	select {
+	case <-c.rateLimiter:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		message, err := ioutil.ReadAll(resp.Body)
+		if err != nil {
+			message = nil
+		}
+		// Do not leak the API token in error messages
+		u.RawQuery = ""
+		return nil, HTTPError{
+			Method:     req.Method,
+			URL:        u.String(),
+			Status:     resp.StatusCode,
+			Message:    string(message),
+			RetryAfter: resp.Header.Get("Retry-After"),
+		}
+	}
+
+	return resp.Header, json.NewDecoder(resp.Body).Decode(v)
+}
+
+func fromScrewdriverState(s string) State {
+	switch strings.ToUpper(s) {
+	case "CREATED", "QUEUED", "BLOCKED", "FROZEN":
+		return Pending
+	case "RUNNING":
+		return Running
+	case "SUCCESS":
+		return Passed
+	case "FAILURE", "UNSTABLE":
+		return Failed
+	case "ABORTED":
+		return Canceled
+	case "COLLAPSED":
+		return Skipped
+	default:
+		return Unknown
+	}
+}
+
+type screwdriverEvent struct {
+	ID         int    `json:"id"`
+	PipelineID int    `json:"pipelineId"`
+	Sha        string `json:"sha"`
+	Type       string `json:"type"`
+	BaseBranch string `json:"baseBranch"`
+	CreateTime string `json:"createTime"`
+}
+
+func (e screwdriverEvent) toPipeline(webURL string) (Pipeline, error) {
+	createdAt, err := utils.NullTimeFromString(e.CreateTime)
+	if err != nil {
+		return Pipeline{}, err
+	}
+
+	return Pipeline{
+		Number: strconv.Itoa(e.ID),
+		Ref:    e.BaseBranch,
+		Step: Step{
+			ID:        strconv.Itoa(e.ID),
+			Type:      StepPipeline,
+			CreatedAt: createdAt,
+			WebURL: utils.NullString{
+				Valid:  true,
+				String: webURL,
+			},
+		},
+	}, nil
+}
+
+type screwdriverJob struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+}
+
+type screwdriverBuild struct {
+	ID         int               `json:"id"`
+	JobID      int               `json:"jobId"`
+	EventID    int               `json:"eventId"`
+	Status     string            `json:"status"`
+	CreateTime string            `json:"createTime"`
+	StartTime  string            `json:"startTime"`
+	EndTime    string            `json:"endTime"`
+	Steps      []screwdriverStep `json:"steps"`
+}
+
+func (b screwdriverBuild) toStep(name string, webURL string, logURL url.URL) (Step, error) {
+	job := Step{
+		ID:    strconv.Itoa(b.ID),
+		Name:  name,
+		Type:  StepJob,
+		State: fromScrewdriverState(b.Status),
+		WebURL: utils.NullString{
+			Valid:  true,
+			String: webURL,
+		},
+	}
+
+	var err error
+	if job.CreatedAt, err = utils.NullTimeFromString(b.CreateTime); err != nil {
+		return Step{}, err
+	}
+	if job.StartedAt, err = utils.NullTimeFromString(b.StartTime); err != nil {
+		return Step{}, err
+	}
+	if job.FinishedAt, err = utils.NullTimeFromString(b.EndTime); err != nil {
+		return Step{}, err
+	}
+	job.Duration = utils.NullSub(job.FinishedAt, job.StartedAt)
+
+	for _, s := range b.Steps {
+		task, err := s.toStep(job, logURL)
+		if err != nil {
+			return Step{}, err
+		}
+		job.Children = append(job.Children, task)
+	}
+
+	return job, nil
+}
+
+type screwdriverStep struct {
+	Name      string `json:"name"`
+	Code      *int   `json:"code"`
+	StartTime string `json:"startTime"`
+	EndTime   string `json:"endTime"`
+}
+
+func (s screwdriverStep) toStep(job Step, logURL url.URL) (Step, error) {
+	// Set both the decoded and the encoded path since step names may contain slashes
+	logURL.RawPath = logURL.EscapedPath() + fmt.Sprintf("/%s/logs", url.PathEscape(s.Name))
+	logURL.Path += fmt.Sprintf("/%s/logs", s.Name)
+	task := Step{
+		ID:        s.Name,
+		Name:      s.Name,
+		Type:      StepTask,
+		CreatedAt: job.CreatedAt,
+		WebURL:    job.WebURL,
+		Log: Log{
+			Key: logURL.String(),
+		},
+	}
+
+	var err error
+	if task.StartedAt, err = utils.NullTimeFromString(s.StartTime); err != nil {
+		return Step{}, err
+	}
+	if task.FinishedAt, err = utils.NullTimeFromString(s.EndTime); err != nil {
+		return Step{}, err
+	}
+	task.Duration = utils.NullSub(task.FinishedAt, task.StartedAt)
+
+	// Steps have no status, only an exit code set once they are over
+	switch {
+	case s.Code != nil && *s.Code == 0:
+		task.State = Passed
+	case s.Code != nil:
+		task.State = Failed
+	case task.StartedAt.Valid && job.State == Canceled:
+		task.State = Canceled
+	case task.StartedAt.Valid:
+		task.State = Running
+	case job.State.IsActive():
+		task.State = Pending
+	default:
+		task.State = Skipped
+	}
+
+	return task, nil
+}
@@ -0,0 +1,298 @@
+package providers
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/nbedos/cistern/utils"
+)
+
+func setupScrewdriverTestServer(t *testing.T) (ScrewdriverClient, *url.URL, func()) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/v4/auth/token" {
+			if r.URL.Query().Get("api_token") != "token" {
+				w.WriteHeader(401)
+				return
+			}
+			fmt.Fprint(w, `{"token": "jwt"}`)
+			return
+		}
+
+		if r.Header.Get("Authorization") != "Bearer jwt" {
+			w.WriteHeader(401)
+			return
+		}
+
+		filename := ""
+		switch r.URL.Path {
+		case "/v4/builds/5678":
+			filename = "screwdriver_build.json"
+		case "/v4/events/9012":
+			filename = "screwdriver_event.json"
+		case "/v4/events/9012/builds":
+			filename = "screwdriver_event_builds.json"
+		case "/v4/pipelines/1234/jobs":
+			filename = "screwdriver_jobs.json"
+		case "/v4/builds/5678/steps/test/logs":
+			from := r.URL.Query().Get("from")
+			filename = fmt.Sprintf("screwdriver_log_%s.json", from)
+			w.Header().Set("X-More-Data", fmt.Sprint(from == "0"))
+		default:
+			w.WriteHeader(404)
+			return
+		}
+
+		bs, err := ioutil.ReadFile(path.Join("test_data", "screwdriver", filename))
+		if err != nil {
+			w.WriteHeader(500)
+			fmt.Fprint(w, err.Error())
+			return
+		}
+		if _, err := fmt.Fprint(w, string(bs)); err != nil {
+			w.WriteHeader(500)
+			fmt.Fprint(w, err.Error())
+			return
+		}
+	}))
+
+	testURL, err := url.Parse(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	client := ScrewdriverClient{
+		baseURL:     *testURL,
+		apiURL:      *testURL,
+		httpClient:  ts.Client(),
+		rateLimiter: time.Tick(time.Millisecond),
+		token:       "token",
+		jwt: &screwdriverJWT{
+			mux: &sync.Mutex{},
+		},
+	}
+
+	return client, testURL, func() { ts.Close() }
+}
+
+func TestParseScrewdriverWebURL(t *testing.T) {
+	baseURL := ScrewdriverURL
+
+	testCases := []struct {
+		url        string
+		pipelineID int
+		resource   string
+		id         int
+	}{
+		{
+			url:        "https://cd.screwdriver.cd/pipelines/1234/builds/5678",
+			pipelineID: 1234,
+			resource:   "builds",
+			id:         5678,
+		},
+		{
+			url:        "https://cd.screwdriver.cd/pipelines/1234/events/9012",
+			pipelineID: 1234,
+			resource:   "events",
+			id:         9012,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.url, func(t *testing.T) {
+			pipelineID, resource, id, err := parseScrewdriverWebURL(&baseURL, testCase.url)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if pipelineID != testCase.pipelineID || resource != testCase.resource || id != testCase.id {
+				t.Fail()
+			}
+		})
+	}
+
+	t.Run("url of another host", func(t *testing.T) {
+		_, _, _, err := parseScrewdriverWebURL(&baseURL, "https://example.com/pipelines/1234/builds/5678")
+		if err != ErrUnknownPipelineURL {
+			t.Fatalf("expected %v but got %v", ErrUnknownPipelineURL, err)
+		}
+	})
+}
+
+func TestScrewdriverClient_BuildFromURL(t *testing.T) {
+	client, testURL, teardown := setupScrewdriverTestServer(t)
+	defer teardown()
+
+	pipeline, err := client.BuildFromURL(context.Background(), testURL.String()+"/pipelines/1234/builds/5678")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	at := func(minutes int, seconds int) utils.NullTime {
+		return utils.NullTime{
+			Valid: true,
+			Time:  time.Date(2020, 1, 30, 13, minutes, seconds, 0, time.UTC),
+		}
+	}
+	duration := func(d time.Duration) utils.NullDuration {
+		return utils.NullDuration{
+			Valid:    true,
+			Duration: d,
+		}
+	}
+	webURL := func(p string) utils.NullString {
+		return utils.NullString{
+			Valid:  true,
+			String: testURL.String() + p,
+		}
+	}
+	logKey := func(build int, step string) string {
+		return fmt.Sprintf("%s/v4/builds/%d/steps/%s/logs", testURL.String(), build, step)
+	}
+
+	expectedPipeline := Pipeline{
+		Number: "9012",
+		Ref:    "master",
+		Step: Step{
+			ID:         "9012",
+			Type:       StepPipeline,
+			State:      Failed,
+			CreatedAt:  at(0, 0),
+			StartedAt:  at(0, 4),
+			FinishedAt: at(1, 30),
+			UpdatedAt:  at(1, 30),
+			Duration:   duration(86 * time.Second),
+			WebURL:     webURL("/pipelines/1234/events/9012"),
+			Children: []Step{
+				{
+					ID:         "5678",
+					Name:       "main",
+					Type:       StepJob,
+					State:      Failed,
+					CreatedAt:  at(0, 1),
+					StartedAt:  at(0, 5),
+					FinishedAt: at(1, 30),
+					Duration:   duration(85 * time.Second),
+					WebURL:     webURL("/pipelines/1234/builds/5678"),
+					Children: []Step{
+						{
+							ID:         "install",
+							Name:       "install",
+							Type:       StepTask,
+							State:      Passed,
+							CreatedAt:  at(0, 1),
+							StartedAt:  at(0, 5),
+							FinishedAt: at(0, 30),
+							Duration:   duration(25 * time.Second),
+							WebURL:     webURL("/pipelines/1234/builds/5678"),
+							Log:        Log{Key: logKey(5678, "install")},
+						},
+						{
+							ID:         "test",
+							Name:       "test",
+							Type:       StepTask,
+							State:      Failed,
+							CreatedAt:  at(0, 1),
+							StartedAt:  at(0, 30),
+							FinishedAt: at(1, 30),
+							Duration:   duration(time.Minute),
+							WebURL:     webURL("/pipelines/1234/builds/5678"),
+							Log:        Log{Key: logKey(5678, "test")},
+						},
+						{
+							ID:        "publish",
+							Name:      "publish",
+							Type:      StepTask,
+							State:     Skipped,
+							CreatedAt: at(0, 1),
+							WebURL:    webURL("/pipelines/1234/builds/5678"),
+							Log:       Log{Key: logKey(5678, "publish")},
+						},
+					},
+				},
+				{
+					ID:         "5679",
+					Name:       "lint",
+					Type:       StepJob,
+					State:      Passed,
+					CreatedAt:  at(0, 1),
+					StartedAt:  at(0, 4),
+					FinishedAt: at(0, 20),
+					Duration:   duration(16 * time.Second),
+					WebURL:     webURL("/pipelines/1234/builds/5679"),
+					Children: []Step{
+						{
+							ID:         "sd-setup-init",
+							Name:       "sd-setup-init",
+							Type:       StepTask,
+							State:      Passed,
+							CreatedAt:  at(0, 1),
+							StartedAt:  at(0, 4),
+							FinishedAt: at(0, 20),
+							Duration:   duration(16 * time.Second),
+							WebURL:     webURL("/pipelines/1234/builds/5679"),
+							Log:        Log{Key: logKey(5679, "sd-setup-init")},
+						},
+					},
+				},
+			},
+		},
+	}
+	if diff := expectedPipeline.Diff(pipeline); len(diff) > 0 {
+		t.Fatal(diff)
+	}
+}
+
+func TestScrewdriverClient_Log(t *testing.T) {
+	client, testURL, teardown := setupScrewdriverTestServer(t)
+	defer teardown()
+
+	step := Step{
+		Log: Log{
+			Key: testURL.String() + "/v4/builds/5678/steps/test/logs",
+		},
+	}
+
+	log, err := client.Log(context.Background(), step)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "$ go test ./...\n--- FAIL: TestScrewdriver (0.00s)\nFAIL\n"
+	if diff := cmp.Diff(expected, log); len(diff) > 0 {
+		t.Fatal(diff)
+	}
+}
+
+func TestScrewdriverStep_toStep(t *testing.T) {
+	logURL, err := url.Parse("https://api.screwdriver.cd/v4/builds/5678/steps")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testCases := map[string]string{
+		"test":        "https://api.screwdriver.cd/v4/builds/5678/steps/test/logs",
+		"run tests":   "https://api.screwdriver.cd/v4/builds/5678/steps/run%20tests/logs",
+		"build/linux": "https://api.screwdriver.cd/v4/builds/5678/steps/build%2Flinux/logs",
+		"100%":        "https://api.screwdriver.cd/v4/builds/5678/steps/100%25/logs",
+	}
+	for name, expected := range testCases {
+		t.Run(name, func(t *testing.T) {
+			step, err := screwdriverStep{Name: name}.toStep(Step{}, *logURL)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if step.Log.Key != expected {
+				t.Fatalf("expected %q but got %q", expected, step.Log.Key)
+			}
+		})
+	}
+}
@@ -0,0 +1,190 @@
+package providers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"time"
+
+	"github.com/nbedos/cistern/utils"
+)
+
+// Version of the format of the snapshots written by Cache.Export
+const snapshotVersion = 1
+
+// Copy of the commits and pipelines of the cache at a given time
+type Snapshot struct {
+	Version   int       `json:"version"`
+	CreatedAt time.Time `json:"created_at"`
+	// Commits by git reference
+	Commits   map[string]Commit `json:"commits"`
+	Pipelines []storedPipeline  `json:"pipelines"`
+}
+
+// Return a copy of the step where the values of secret variables are masked and logs are
+// removed unless 'logs' is true
+func (s Step) exported(logs bool) Step {
+	if !logs {
+		s.Log.Content = utils.NullString{}
+	}
+	if len(s.Variables) > 0 {
+		variables := make([]Variable, 0, len(s.Variables))
+		for _, v := range s.Variables {
+			if v.IsSecret() {
+				v.Value = MaskedValue
+				v.Secret = true
+			}
+			variables = append(variables, v)
+		}
+		s.Variables = variables
+	}
+	if len(s.Children) > 0 {
+		children := make([]Step, 0, len(s.Children))
+		for _, child := range s.Children {
+			children = append(children, child.exported(logs))
+		}
+		s.Children = children
+	}
+	return s
+}
+
+// Fetch the logs of all the jobs of the step identified by key and stepIDs that are not in
+// cache yet. Jobs without a log are left as they are.
+func (c *Cache) withLogs(ctx context.Context, key PipelineKey, stepIDs []string, s Step) (Step, error) {
+	if s.Type == StepJob && !s.Log.Content.Valid {
+		log, err := c.Log(ctx, key, stepIDs)
+		switch err {
+		case nil:
+			s.Log.Content = utils.NullString{Valid: true, String: log}
+		case ErrNoLogHere:
+			// Do nothing
+		default:
+			return s, err
+		}
+	}
+	if len(s.Children) > 0 {
+		children := make([]Step, 0, len(s.Children))
+		for _, child := range s.Children {
+			childIDs := append(stepIDs[:len(stepIDs):len(stepIDs)], child.ID)
+			child, err := c.withLogs(ctx, key, childIDs, child)
+			if err != nil {
+				return s, err
+			}
+			children = append(children, child)
+		}
+		s.Children = children
+	}
+	return s, nil
+}
+
+// Write a JSON snapshot of the commits and pipelines of the cache to w. If 'logs' is true,
+// the logs of all jobs are fetched from the providers and included in the snapshot. The
+// values of secret variables are masked so that snapshots can be attached to bug reports.
+func (c *Cache) Export(ctx context.Context, w io.Writer, logs bool) error {
+	c.mutex.RLock()
+	snapshot := Snapshot{
+		Version:   snapshotVersion,
+		CreatedAt: c.clock.Now(),
+		Commits:   make(map[string]Commit, len(c.commitsByRef)),
+		Pipelines: make([]storedPipeline, 0, len(c.pipelineByKey)),
+	}
+	for ref, commit := range c.commitsByRef {
+		snapshot.Commits[ref] = commit
+	}
+	for sha, pipelineByKey := range c.pipelineBySha {
+		for _, p := range pipelineByKey {
+			snapshot.Pipelines = append(snapshot.Pipelines, storedPipeline{
+				Sha:        sha,
+				ProviderID: p.providerID,
+				Pipeline:   *p,
+				SavedAt:    c.usedAt.get(p.Key()),
+			})
+		}
+	}
+	c.mutex.RUnlock()
+
+	sort.Slice(snapshot.Pipelines, func(i, j int) bool {
+		pi, pj := snapshot.Pipelines[i], snapshot.Pipelines[j]
+		if pi.Sha != pj.Sha {
+			return pi.Sha < pj.Sha
+		}
+		return pi.Pipeline.ProviderHost < pj.Pipeline.ProviderHost || (pi.Pipeline.ProviderHost == pj.Pipeline.ProviderHost && pi.Pipeline.ID < pj.Pipeline.ID)
+	})
+
+	for i, p := range snapshot.Pipelines {
+		if logs {
+			step, err := c.withLogs(ctx, p.Pipeline.Key(), nil, p.Pipeline.Step)
+			if err != nil {
+				return err
+			}
+			p.Pipeline.Step = step
+		}
+		p.Pipeline.Step = p.Pipeline.Step.exported(logs)
+		snapshot.Pipelines[i] = p
+	}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(snapshot)
+}
+
+// Read a snapshot written by Cache.Export
+func ReadSnapshot(r io.Reader) (Snapshot, error) {
+	var snapshot Snapshot
+	if err := json.NewDecoder(r).Decode(&snapshot); err != nil {
+		return Snapshot{}, err
+	}
+	if snapshot.Version != snapshotVersion {
+		return Snapshot{}, fmt.Errorf("unsupported snapshot version %d (expected %d)", snapshot.Version, snapshotVersion)
+	}
+	return snapshot, nil
+}
+
+// Return the git reference of the most recent commit of the snapshot, or an empty string if
+// the snapshot has no commit
+func (s Snapshot) LatestRef() string {
+	refs := make([]string, 0, len(s.Commits))
+	for ref := range s.Commits {
+		refs = append(refs, ref)
+	}
+	sort.Slice(refs, func(i, j int) bool {
+		ci, cj := s.Commits[refs[i]], s.Commits[refs[j]]
+		if !ci.Date.Equal(cj.Date) {
+			return ci.Date.After(cj.Date)
+		}
+		return refs[i] < refs[j]
+	})
+	if len(refs) == 0 {
+		return ""
+	}
+	return refs[0]
+}
+
+// Return a cache holding the commits and pipelines of the snapshot. The cache has no
+// provider so its pipelines are never polled, and its clock is stopped at the time the
+// snapshot was taken so that durations of running jobs are shown as they were then.
+func NewCacheFromSnapshot(s Snapshot) Cache {
+	c := NewCache(nil, nil, utils.PollingStrategy{})
+	c.SetClock(utils.NewVirtualClock(s.CreatedAt))
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	for ref, commit := range s.Commits {
+		c.commitsByRef[ref] = commit
+	}
+	for _, stored := range s.Pipelines {
+		p := stored.Pipeline
+		p.providerID = stored.ProviderID
+		c.pipelineByKey[p.Key()] = &p
+		if _, exists := c.pipelineBySha[stored.Sha]; !exists {
+			c.pipelineBySha[stored.Sha] = make(map[PipelineKey]*Pipeline)
+		}
+		c.pipelineBySha[stored.Sha][p.Key()] = &p
+		c.usedAt.touch(stored.SavedAt, p.Key())
+		c.recordJobRuns(stored.Sha, p)
+	}
+
+	return c
+}
@@ -0,0 +1,143 @@
+package providers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/nbedos/cistern/utils"
+)
+
+func TestCache_Export(t *testing.T) {
+	now := time.Date(2020, 1, 30, 12, 0, 0, 0, time.UTC)
+	newCache := func() Cache {
+		c := NewCache([]CIProvider{&testProvider{"ci", "ci.example.com", 0}}, nil, utils.PollingStrategy{})
+		c.SetClock(utils.NewVirtualClock(now))
+		c.SaveCommit("master", Commit{Sha: "sha", Branches: []string{"master"}})
+		pipeline := Pipeline{
+			providerID:   "ci",
+			ProviderHost: "ci.example.com",
+			Ref:          "master",
+			Step: Step{
+				ID:        "1",
+				Type:      StepPipeline,
+				State:     Passed,
+				Variables: []Variable{{Name: "API_TOKEN", Value: "hunter2"}, {Name: "GOOS", Value: "linux"}},
+				Children: []Step{
+					{
+						ID:    "job",
+						Type:  StepJob,
+						State: Passed,
+						Log:   Log{Content: utils.NullString{Valid: true, String: "ok\n"}},
+					},
+					{ID: "other", Type: StepJob, State: Passed},
+				},
+			},
+		}
+		if _, err := c.SavePipeline("sha", pipeline); err != nil {
+			t.Fatal(err)
+		}
+		return c
+	}
+
+	export := func(c Cache, logs bool) Snapshot {
+		buf := bytes.Buffer{}
+		if err := c.Export(context.Background(), &buf, logs); err != nil {
+			t.Fatal(err)
+		}
+		var snapshot Snapshot
+		if err := json.Unmarshal(buf.Bytes(), &snapshot); err != nil {
+			t.Fatal(err)
+		}
+		return snapshot
+	}
+
+	t.Run("secrets are masked and logs are left out", func(t *testing.T) {
+		snapshot := export(newCache(), false)
+		if snapshot.Version != snapshotVersion || !snapshot.CreatedAt.Equal(now) {
+			t.Fatalf("unexpected header: version %d, created at %v", snapshot.Version, snapshot.CreatedAt)
+		}
+		if diff := cmp.Diff([]string{"master"}, snapshot.Commits["master"].Branches); diff != "" {
+			t.Fatal(diff)
+		}
+		if len(snapshot.Pipelines) != 1 {
+			t.Fatalf("expected 1 pipeline but got %d", len(snapshot.Pipelines))
+		}
+		p := snapshot.Pipelines[0]
+		if p.Sha != "sha" || p.ProviderID != "ci" {
+			t.Fatalf("expected pipeline of commit %q and provider %q but got %q and %q", "sha", "ci", p.Sha, p.ProviderID)
+		}
+		expected := []Variable{{Name: "API_TOKEN", Value: MaskedValue, Secret: true}, {Name: "GOOS", Value: "linux"}}
+		if diff := cmp.Diff(expected, p.Pipeline.Variables); diff != "" {
+			t.Fatal(diff)
+		}
+		for _, child := range p.Pipeline.Children {
+			if child.Log.Content.Valid {
+				t.Fatalf("expected no log for job %q", child.ID)
+			}
+		}
+	})
+
+	t.Run("missing logs are fetched", func(t *testing.T) {
+		snapshot := export(newCache(), true)
+		logs := make([]string, 0)
+		for _, child := range snapshot.Pipelines[0].Pipeline.Children {
+			logs = append(logs, child.Log.Content.String)
+		}
+		if diff := cmp.Diff([]string{"ok\n", "\n"}, logs); diff != "" {
+			t.Fatal(diff)
+		}
+	})
+}
+
+func TestNewCacheFromSnapshot(t *testing.T) {
+	now := time.Date(2020, 1, 30, 12, 0, 0, 0, time.UTC)
+	c := NewCache([]CIProvider{&testProvider{"ci", "ci.example.com", 0}}, nil, utils.PollingStrategy{})
+	c.SetClock(utils.NewVirtualClock(now))
+	c.SaveCommit("master", Commit{Sha: "sha1", Date: now.Add(-time.Hour)})
+	c.SaveCommit("feature", Commit{Sha: "sha2", Date: now.Add(-time.Minute)})
+	pipeline := Pipeline{
+		providerID:   "ci",
+		ProviderHost: "ci.example.com",
+		ProviderName: "ci",
+		Ref:          "master",
+		Step: Step{
+			ID:       "1",
+			Type:     StepPipeline,
+			State:    Running,
+			Children: []Step{{ID: "job", Type: StepJob, State: Running}},
+		},
+	}
+	if _, err := c.SavePipeline("sha1", pipeline); err != nil {
+		t.Fatal(err)
+	}
+
+	buf := bytes.Buffer{}
+	if err := c.Export(context.Background(), &buf, false); err != nil {
+		t.Fatal(err)
+	}
+	snapshot, err := ReadSnapshot(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ref := snapshot.LatestRef(); ref != "feature" {
+		t.Fatalf("expected %q but got %q", "feature", ref)
+	}
+
+	replay := NewCacheFromSnapshot(snapshot)
+	if !replay.Clock().Now().Equal(now) {
+		t.Fatalf("expected clock at %v but got %v", now, replay.Clock().Now())
+	}
+	if diff := cmp.Diff(c.Pipelines("master"), replay.Pipelines("master"), cmp.AllowUnexported(Pipeline{})); diff != "" {
+		t.Fatal(diff)
+	}
+
+	t.Run("snapshots of another version are rejected", func(t *testing.T) {
+		if _, err := ReadSnapshot(bytes.NewBufferString(`{"version": 0}`)); err == nil {
+			t.Fatal("expected an error")
+		}
+	})
+}
@@ -0,0 +1,274 @@
+package providers
+
+import (
+	"context"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/nbedos/cistern/utils"
+)
+
+// Rate limit of the API of a provider as advertised by its last response
+type RateLimit struct {
+	// False if no response specified a rate limit
+	Valid bool
+	// Maximum number of requests per period
+	Limit int
+	// Number of requests left until the end of the period
+	Remaining int
+	// Start of the next period
+	Reset utils.NullTime
+}
+
+// Counters of the HTTP requests sent by a provider to its API
+type RequestStats struct {
+	Requests int
+	// Number of requests that failed or were answered with a status code above 399
+	Errors int
+	// Total duration of the requests
+	Duration  time.Duration
+	RateLimit RateLimit
+}
+
+// Providers implementing Instrumented count the requests they send to their API
+type Instrumented interface {
+	RequestStats() RequestStats
+}
+
+// Counter of the requests sent through an HTTP client, shared by all copies of a provider
+type requestCounter struct {
+	mutex *sync.Mutex
+	stats RequestStats
+}
+
+func (c *requestCounter) record(resp *http.Response, err error, duration time.Duration, now time.Time) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.stats.Requests++
+	c.stats.Duration += duration
+	if err != nil || resp.StatusCode >= 400 {
+		c.stats.Errors++
+	}
+	if resp != nil {
+		if limit, ok := parseRateLimit(resp.Header, now); ok {
+			c.stats.RateLimit = limit
+		}
+	}
+}
+
+// Return the counters of the requests, zero for a nil counter
+func (c *requestCounter) snapshot() RequestStats {
+	if c == nil {
+		return RequestStats{}
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.stats
+}
+
+// http.RoundTripper recording each request sent through 'base'
+type countingTransport struct {
+	base    http.RoundTripper
+	counter *requestCounter
+}
+
+func (t countingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	start := time.Now()
+	resp, err := t.base.RoundTrip(req)
+	now := time.Now()
+	t.counter.record(resp, err, now.Sub(start), now)
+	return resp, err
+}
+
+// Return an HTTP client sending its requests through 'base', or http.DefaultTransport if
+// base is nil, and the counter of these requests
+func countedClient(base http.RoundTripper) (*http.Client, *requestCounter) {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	counter := &requestCounter{mutex: &sync.Mutex{}}
+	return &http.Client{Transport: countingTransport{base: base, counter: counter}}, counter
+}
+
+// Parse the rate limit headers of a response. GitHub prefixes them with "X-" and gives the
+// reset time as a UNIX timestamp, other providers may give the number of seconds left
+// before the reset instead.
+func parseRateLimit(header http.Header, now time.Time) (RateLimit, bool) {
+	get := func(name string) (int, bool) {
+		value := header.Get("X-" + name)
+		if value == "" {
+			value = header.Get(name)
+		}
+		n, err := strconv.Atoi(value)
+		return n, err == nil && n >= 0
+	}
+
+	remaining, ok := get("RateLimit-Remaining")
+	if !ok {
+		return RateLimit{}, false
+	}
+	limit := RateLimit{
+		Valid:     true,
+		Remaining: remaining,
+	}
+	limit.Limit, _ = get("RateLimit-Limit")
+	if reset, ok := get("RateLimit-Reset"); ok {
+		limit.Reset.Valid = true
+		// Any value this large is a timestamp rather than a delay (a delay of more than
+		// 30 years)
+		if reset > 1000000000 {
+			limit.Reset.Time = time.Unix(int64(reset), 0)
+		} else {
+			limit.Reset.Time = now.Add(time.Duration(reset) * time.Second)
+		}
+	}
+	return limit, true
+}
+
+// Polls of the pipelines of a provider
+type pollStats struct {
+	count    int
+	errors   int
+	last     time.Time
+	duration time.Duration
+	// Start of the last poll that succeeded
+	lastSuccess time.Time
+	// Number of polls in progress
+	pending int
+}
+
+// Activity of a single provider
+type ProviderStats struct {
+	ID   string
+	Name string
+	// False if the provider does not count its requests, in which case Requests is empty
+	Instrumented bool
+	Requests     RequestStats
+	// Number of polls of pipelines and number of polls that failed
+	Polls      int
+	PollErrors int
+	// Start and duration of the last poll
+	LastPoll         utils.NullTime
+	LastPollDuration utils.NullDuration
+	// Start of the last poll that succeeded
+	LastSuccess utils.NullTime
+	// True while a poll of a pipeline of the provider is in progress
+	Polling bool
+	// Number of pipelines of the provider stored in cache
+	Pipelines int
+	// Size in bytes of the logs of these pipelines kept in memory
+	LogBytes int
+}
+
+// Statistics of the cache and its providers
+type CacheStats struct {
+	// Providers sorted by ID
+	Providers []ProviderStats
+	Commits   int
+	Pipelines int
+	LogBytes  int
+}
+
+// Return the size in bytes of the logs of step and its children
+func (s Step) logBytes() int {
+	n := len(s.Log.Content.String)
+	for _, child := range s.Children {
+		n += child.logBytes()
+	}
+	return n
+}
+
+// Record the start of a poll of a pipeline of the provider identified by providerID. Each
+// call must be followed by a call to recordPoll once the poll is over.
+func (c *Cache) startPoll(providerID string) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	stats := c.polls[providerID]
+	stats.pending++
+	c.polls[providerID] = stats
+}
+
+// Record a poll of a pipeline of the provider identified by providerID
+func (c *Cache) recordPoll(providerID string, start time.Time, duration time.Duration, err error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	stats := c.polls[providerID]
+	stats.count++
+	switch err {
+	case nil, ErrNotModified:
+		stats.lastSuccess = start
+	case context.Canceled:
+		// Do nothing
+	default:
+		stats.errors++
+	}
+	if stats.pending > 0 {
+		stats.pending--
+	}
+	stats.last = start
+	stats.duration = duration
+	c.polls[providerID] = stats
+}
+
+// Return the statistics of the cache and of each of its providers
+func (c *Cache) Stats() CacheStats {
+	byID := make(map[string]*ProviderStats)
+	add := func(id string, name string, p interface{}) {
+		stats, exists := byID[id]
+		if !exists {
+			stats = &ProviderStats{ID: id, Name: name}
+			byID[id] = stats
+		}
+		if instrumented, ok := p.(Instrumented); ok && !stats.Instrumented {
+			stats.Instrumented = true
+			stats.Requests = instrumented.RequestStats()
+		}
+	}
+	for id, p := range c.ciProvidersByID {
+		add(id, p.Name(), p)
+	}
+	for _, p := range c.sourceProviders {
+		add(p.ID(), "", p)
+	}
+
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+
+	stats := CacheStats{
+		Commits: len(c.commitsByRef),
+	}
+	for id, polls := range c.polls {
+		if p, exists := byID[id]; exists {
+			p.Polls = polls.count
+			p.PollErrors = polls.errors
+			p.LastPoll = utils.NullTime{Valid: true, Time: polls.last}
+			p.LastPollDuration = utils.NullDuration{Valid: true, Duration: polls.duration}
+			p.LastSuccess = utils.NullTime{Valid: !polls.lastSuccess.IsZero(), Time: polls.lastSuccess}
+			p.Polling = polls.pending > 0
+		}
+	}
+	for _, pipeline := range c.pipelineByKey {
+		n := pipeline.Step.logBytes()
+		stats.Pipelines++
+		stats.LogBytes += n
+		if p, exists := byID[pipeline.providerID]; exists {
+			p.Pipelines++
+			p.LogBytes += n
+		}
+	}
+
+	for _, p := range byID {
+		if p.Name == "" {
+			p.Name = p.ID
+		}
+		stats.Providers = append(stats.Providers, *p)
+	}
+	sort.Slice(stats.Providers, func(i, j int) bool {
+		return stats.Providers[i].ID < stats.Providers[j].ID
+	})
+
+	return stats
+}
@@ -0,0 +1,182 @@
+package providers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/nbedos/cistern/utils"
+)
+
+func TestParseRateLimit(t *testing.T) {
+	now := time.Date(2020, 1, 30, 12, 0, 0, 0, time.UTC)
+	testCases := []struct {
+		name   string
+		header map[string]string
+		limit  RateLimit
+		ok     bool
+	}{
+		{
+			name: "GitHub",
+			header: map[string]string{
+				"X-RateLimit-Limit":     "5000",
+				"X-RateLimit-Remaining": "4990",
+				"X-RateLimit-Reset":     "1580389200",
+			},
+			limit: RateLimit{
+				Valid:     true,
+				Limit:     5000,
+				Remaining: 4990,
+				Reset:     utils.NullTime{Valid: true, Time: time.Unix(1580389200, 0)},
+			},
+			ok: true,
+		},
+		{
+			name: "delay before reset",
+			header: map[string]string{
+				"RateLimit-Limit":     "600",
+				"RateLimit-Remaining": "0",
+				"RateLimit-Reset":     "60",
+			},
+			limit: RateLimit{
+				Valid:     true,
+				Limit:     600,
+				Remaining: 0,
+				Reset:     utils.NullTime{Valid: true, Time: now.Add(time.Minute)},
+			},
+			ok: true,
+		},
+		{
+			name: "no reset time",
+			header: map[string]string{
+				"RateLimit-Remaining": "10",
+			},
+			limit: RateLimit{
+				Valid:     true,
+				Remaining: 10,
+			},
+			ok: true,
+		},
+		{
+			name:   "no rate limit",
+			header: map[string]string{},
+		},
+		{
+			name: "invalid value",
+			header: map[string]string{
+				"RateLimit-Remaining": "many",
+			},
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			header := make(http.Header)
+			for name, value := range testCase.header {
+				header.Set(name, value)
+			}
+			limit, ok := parseRateLimit(header, now)
+			if ok != testCase.ok {
+				t.Fatalf("expected %v but got %v", testCase.ok, ok)
+			}
+			if diff := cmp.Diff(testCase.limit, limit); diff != "" {
+				t.Fatal(diff)
+			}
+		})
+	}
+}
+
+func TestCountedClient(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("RateLimit-Remaining", "41")
+		if r.URL.Path == "/missing" {
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer ts.Close()
+
+	client, counter := countedClient(nil)
+	for _, path := range []string{"/", "/", "/missing"} {
+		resp, err := client.Get(ts.URL + path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+	}
+
+	stats := counter.snapshot()
+	if stats.Requests != 3 || stats.Errors != 1 {
+		t.Fatalf("expected 3 requests and 1 error but got %d requests and %d errors", stats.Requests, stats.Errors)
+	}
+	if !stats.RateLimit.Valid || stats.RateLimit.Remaining != 41 {
+		t.Fatalf("expected 41 remaining requests but got %+v", stats.RateLimit)
+	}
+}
+
+func TestCache_Stats(t *testing.T) {
+	c := NewCache([]CIProvider{
+		&testProvider{"ci1", "ci1.example.com", 0},
+		&testProvider{"ci2", "ci2.example.com", 0},
+	}, nil, utils.PollingStrategy{})
+	c.SetClock(utils.NewVirtualClock(time.Date(2020, 1, 30, 12, 0, 0, 0, time.UTC)))
+
+	pipelines := []Pipeline{
+		{
+			providerID: "ci1",
+			Step: Step{
+				ID:   "1",
+				Type: StepPipeline,
+				Children: []Step{
+					{
+						ID:   "job",
+						Type: StepJob,
+						Log:  Log{Content: utils.NullString{Valid: true, String: "12345"}},
+					},
+				},
+			},
+		},
+		{
+			providerID: "ci1",
+			Step:       Step{ID: "2", Type: StepPipeline},
+		},
+	}
+	for _, p := range pipelines {
+		if _, err := c.SavePipeline("sha", p); err != nil {
+			t.Fatal(err)
+		}
+	}
+	start := time.Date(2020, 1, 30, 12, 0, 0, 0, time.UTC)
+	c.startPoll("ci1")
+	c.recordPoll("ci1", start, time.Second, nil)
+	c.startPoll("ci1")
+	c.startPoll("ci1")
+	c.recordPoll("ci1", start.Add(time.Minute), 2*time.Second, ErrUnknownPipelineURL)
+
+	expected := CacheStats{
+		Providers: []ProviderStats{
+			{
+				ID:               "ci1",
+				Name:             "ci1",
+				Polls:            2,
+				PollErrors:       1,
+				LastPoll:         utils.NullTime{Valid: true, Time: start.Add(time.Minute)},
+				LastPollDuration: utils.NullDuration{Valid: true, Duration: 2 * time.Second},
+				LastSuccess:      utils.NullTime{Valid: true, Time: start},
+				Polling:          true,
+				Pipelines:        2,
+				LogBytes:         5,
+			},
+			{
+				ID:   "ci2",
+				Name: "ci2",
+			},
+		},
+		Pipelines: 2,
+		LogBytes:  5,
+	}
+	if diff := cmp.Diff(expected, c.Stats()); diff != "" {
+		t.Fatal(diff)
+	}
+}
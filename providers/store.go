@@ -0,0 +1,172 @@
+package providers
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Name of the file listing commits in the directory of a diskStore
+const commitsFilename = "commits.json"
+
+// Prefix of the names of pipeline files so that they can be told apart from other files of
+// the directory of a diskStore
+const pipelineFilenamePrefix = "cistern-pipeline-"
+
+// A diskStore keeps a copy of the pipelines and commits of the cache in a directory so that
+// they survive restarts of cistern. Each pipeline is stored in its own JSON file.
+type diskStore struct {
+	dir string
+}
+
+type storedPipeline struct {
+	Sha        string    `json:"sha"`
+	ProviderID string    `json:"provider_id"`
+	Pipeline   Pipeline  `json:"pipeline"`
+	SavedAt    time.Time `json:"saved_at"`
+	// Traversable state of the steps of the pipeline in the user interface
+	Folds map[string]bool `json:"folds,omitempty"`
+	// Path of the file the pipeline was read from
+	path string
+}
+
+func (s diskStore) pipelinePath(key PipelineKey) string {
+	return filepath.Join(s.dir, pipelineFilenamePrefix+stepFilename(key, nil)+".json")
+}
+
+// Write v as JSON to path. The file is written under a temporary name and renamed afterwards
+// so that readers never see a partially written file.
+func writeJSONFile(path string, v interface{}) error {
+	bs, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	f, err := ioutil.TempFile(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(bs); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+
+	return os.Rename(f.Name(), path)
+}
+
+func (s diskStore) savePipeline(sha string, p Pipeline, folds map[string]bool, now time.Time) error {
+	return writeJSONFile(s.pipelinePath(p.Key()), storedPipeline{
+		Sha:        sha,
+		ProviderID: p.providerID,
+		Pipeline:   p,
+		SavedAt:    now,
+		Folds:      folds,
+	})
+}
+
+func (s diskStore) saveCommits(commitsByRef map[string]Commit) error {
+	return writeJSONFile(filepath.Join(s.dir, commitsFilename), commitsByRef)
+}
+
+// Return the pipelines and commits of the store. Pipelines saved more than maxAge ago are
+// removed from the store. A missing directory results in an empty store.
+func (s diskStore) load(maxAge time.Duration, now time.Time) ([]storedPipeline, map[string]Commit, error) {
+	commitsByRef := make(map[string]Commit)
+	bs, err := ioutil.ReadFile(filepath.Join(s.dir, commitsFilename))
+	switch {
+	case os.IsNotExist(err):
+		// Do nothing
+	case err != nil:
+		return nil, nil, err
+	default:
+		if err := json.Unmarshal(bs, &commitsByRef); err != nil {
+			return nil, nil, fmt.Errorf("%s: %w", filepath.Join(s.dir, commitsFilename), err)
+		}
+	}
+
+	stored, invalid, err := s.pipelines()
+	if err != nil {
+		return nil, nil, err
+	}
+	// Files that cannot be decoded, for example because they were written by another
+	// version of cistern, are discarded along with expired ones
+	for _, path := range invalid {
+		if err := os.Remove(path); err != nil {
+			return nil, nil, err
+		}
+	}
+	pipelines := make([]storedPipeline, 0, len(stored))
+	for _, p := range stored {
+		if maxAge > 0 && now.Sub(p.SavedAt) > maxAge {
+			if err := os.Remove(p.path); err != nil {
+				return nil, nil, err
+			}
+			continue
+		}
+		pipelines = append(pipelines, p)
+	}
+
+	return pipelines, commitsByRef, nil
+}
+
+// Return true if p has the fields every pipeline written by savePipeline has
+func (p storedPipeline) valid() bool {
+	return p.ProviderID != "" && p.Pipeline.ID != "" && !p.SavedAt.IsZero()
+}
+
+// Return the pipelines of the store and the paths of the files that could not be decoded.
+// Only files named by savePipeline are read. Records lacking the fields written by
+// savePipeline are skipped but left in place.
+func (s diskStore) pipelines() ([]storedPipeline, []string, error) {
+	paths, err := filepath.Glob(filepath.Join(s.dir, pipelineFilenamePrefix+"*.json"))
+	if err != nil {
+		return nil, nil, err
+	}
+	pipelines := make([]storedPipeline, 0, len(paths))
+	invalid := make([]string, 0)
+	for _, path := range paths {
+		bs, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, nil, err
+		}
+		var p storedPipeline
+		if err := json.Unmarshal(bs, &p); err != nil {
+			invalid = append(invalid, path)
+			continue
+		}
+		if !p.valid() {
+			continue
+		}
+		p.path = path
+		p.Pipeline.providerID = p.ProviderID
+		p.Pipeline.Sha = p.Sha
+		pipelines = append(pipelines, p)
+	}
+
+	return pipelines, invalid, nil
+}
+
+// Return the pipelines saved in the directory 'dir' by a persisted cache (see Cache.Persist).
+// The directory is left untouched. A missing directory results in an empty list.
+func StoredPipelines(dir string) ([]Pipeline, error) {
+	stored, _, err := diskStore{dir: dir}.pipelines()
+	if err != nil {
+		return nil, err
+	}
+	pipelines := make([]Pipeline, 0, len(stored))
+	for _, p := range stored {
+		pipelines = append(pipelines, p.Pipeline)
+	}
+
+	return pipelines, nil
+}
@@ -0,0 +1,189 @@
+package providers
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/nbedos/cistern/utils"
+)
+
+func TestCache_Persist(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cistern")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	newCache := func() Cache {
+		return NewCache([]CIProvider{&testProvider{"provider", "provider.example.com", 0}}, nil, utils.PollingStrategy{})
+	}
+
+	pipeline := Pipeline{
+		providerID:   "provider",
+		ProviderHost: "provider.example.com",
+		Ref:          "master",
+		Step: Step{
+			ID:    "1",
+			State: Passed,
+			Children: []Step{
+				{ID: "2", Type: StepJob, State: Passed},
+			},
+		},
+	}
+
+	t.Run("pipelines, commits and logs are restored", func(t *testing.T) {
+		c := newCache()
+		if err := c.Persist(dir, 0); err != nil {
+			t.Fatal(err)
+		}
+		c.SaveCommit("master", Commit{Sha: "sha"})
+		if _, err := c.SavePipeline("sha", pipeline); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := c.Log(context.Background(), pipeline.Key(), []string{"2"}); err != nil {
+			t.Fatal(err)
+		}
+
+		restored := newCache()
+		if err := restored.Persist(dir, 0); err != nil {
+			t.Fatal(err)
+		}
+		pipelines := restored.Pipelines("master")
+		if len(pipelines) != 1 || pipelines[0].ID != "1" || pipelines[0].providerID != "provider" {
+			t.Fatalf("unexpected pipelines: %+v", pipelines)
+		}
+		step, exists := restored.Step(pipeline.Key(), []string{"2"})
+		if !exists || !step.Log.Content.Valid || step.Log.Content.String != "" {
+			t.Fatalf("expected log to be restored: %+v", step)
+		}
+	})
+
+	t.Run("fold state of pipelines is restored", func(t *testing.T) {
+		c := newCache()
+		if err := c.Persist(dir, 0); err != nil {
+			t.Fatal(err)
+		}
+		folds := map[string]bool{"": true, "/string(\"tests\")": false}
+		c.SaveFoldState(pipeline.Key(), folds)
+		c.SaveFoldState(PipelineKey{ProviderHost: "provider.example.com", ID: "404"}, folds)
+		if err := c.Flush(); err != nil {
+			t.Fatal(err)
+		}
+
+		restored := newCache()
+		if err := restored.Persist(dir, 0); err != nil {
+			t.Fatal(err)
+		}
+		expected := map[PipelineKey]map[string]bool{pipeline.Key(): folds}
+		if diff := cmp.Diff(expected, restored.FoldState()); diff != "" {
+			t.Fatal(diff)
+		}
+	})
+
+	t.Run("pipelines of providers no longer configured are ignored", func(t *testing.T) {
+		c := NewCache([]CIProvider{&testProvider{"other", "other.example.com", 0}}, nil, utils.PollingStrategy{})
+		if err := c.Persist(dir, 0); err != nil {
+			t.Fatal(err)
+		}
+		if pipelines := c.Pipelines("master"); len(pipelines) != 0 {
+			t.Fatalf("expected no pipeline but got %+v", pipelines)
+		}
+	})
+
+	t.Run("expired pipelines are removed", func(t *testing.T) {
+		store := diskStore{dir: dir}
+		if err := store.savePipeline("sha", pipeline, nil, time.Now().Add(-48*time.Hour)); err != nil {
+			t.Fatal(err)
+		}
+
+		c := newCache()
+		if err := c.Persist(dir, 24*time.Hour); err != nil {
+			t.Fatal(err)
+		}
+		if pipelines := c.Pipelines("master"); len(pipelines) != 0 {
+			t.Fatalf("expected no pipeline but got %+v", pipelines)
+		}
+		if _, err := os.Stat(filepath.Join(dir, "cistern-pipeline-provider.example.com-1.json")); !os.IsNotExist(err) {
+			t.Fatalf("expected file to be removed but got %v", err)
+		}
+	})
+
+	t.Run("files not written by cistern are left untouched", func(t *testing.T) {
+		files := map[string]string{
+			"snapshot-20200101.json": `{"pipelines": []}`,
+			"notes.json":             `not json`,
+			"cistern-pipeline-provider.example.com-2.json": `{"provider_id": "provider"}`,
+			"cistern-pipeline-provider.example.com-3.json": `{}`,
+		}
+		for name, content := range files {
+			if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0600); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		for _, maxAge := range []time.Duration{0, 24 * time.Hour} {
+			pipelines, _, err := diskStore{dir: dir}.load(maxAge, time.Now())
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, p := range pipelines {
+				if p.Pipeline.ID != "1" {
+					t.Fatalf("unexpected pipeline: %+v", p)
+				}
+			}
+			for name := range files {
+				if _, err := os.Stat(filepath.Join(dir, name)); err != nil {
+					t.Fatalf("expected file %q to be left in place but got %v", name, err)
+				}
+			}
+		}
+	})
+}
+
+func TestCache_Flush(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cistern")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	newCache := func() Cache {
+		return NewCache([]CIProvider{&testProvider{"provider", "provider.example.com", 0}}, nil, utils.PollingStrategy{})
+	}
+
+	c := newCache()
+	if err := c.Persist(dir, 0); err != nil {
+		t.Fatal(err)
+	}
+	c.SaveCommit("master", Commit{Sha: "sha"})
+	pipeline := Pipeline{
+		providerID:   "provider",
+		ProviderHost: "provider.example.com",
+		Ref:          "master",
+		Step:         Step{ID: "1", State: Passed},
+	}
+	if _, err := c.SavePipeline("sha", pipeline); err != nil {
+		t.Fatal(err)
+	}
+
+	// Simulate writes lost while cistern was running
+	if err := os.RemoveAll(dir); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	restored := newCache()
+	if err := restored.Persist(dir, 0); err != nil {
+		t.Fatal(err)
+	}
+	if pipelines := restored.Pipelines("master"); len(pipelines) != 1 || pipelines[0].ID != "1" {
+		t.Fatalf("unexpected pipelines: %+v", pipelines)
+	}
+}
@@ -0,0 +1,305 @@
+package providers
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/nbedos/cistern/utils"
+)
+
+var ErrNoTestReport = errors.New("no test report found for this job")
+
+// Error returned by parseJUnit when the document is not a JUnit report
+var errNotJUnit = errors.New("not a JUnit report")
+
+type TestStatus string
+
+const (
+	TestPassed  TestStatus = "passed"
+	TestFailed  TestStatus = "failed"
+	TestErrored TestStatus = "error"
+	TestSkipped TestStatus = "skipped"
+)
+
+// Outcome of a test case
+type TestCase struct {
+	Name string
+	// Class, module or file of the test case, empty if the report does not tell
+	ClassName string
+	Status    TestStatus
+	Duration  utils.NullDuration
+	// Failure message and output of the test case, if any
+	Message string
+}
+
+type TestSuite struct {
+	Name     string
+	Duration utils.NullDuration
+	Cases    []TestCase
+}
+
+// Results of the tests run by a job
+type TestReport struct {
+	Suites []TestSuite
+}
+
+// Return the number of test cases with status 's'
+func (r TestReport) Count(s TestStatus) int {
+	n := 0
+	for _, suite := range r.Suites {
+		for _, testCase := range suite.Cases {
+			if testCase.Status == s {
+				n++
+			}
+		}
+	}
+	return n
+}
+
+// Return the test suites of the report keeping only the test cases that failed or errored.
+// Suites without any such test case are left out.
+func (r TestReport) Failures() []TestSuite {
+	suites := make([]TestSuite, 0)
+	for _, suite := range r.Suites {
+		cases := make([]TestCase, 0)
+		for _, testCase := range suite.Cases {
+			if testCase.Status == TestFailed || testCase.Status == TestErrored {
+				cases = append(cases, testCase)
+			}
+		}
+		if len(cases) > 0 {
+			suite.Cases = cases
+			suites = append(suites, suite)
+		}
+	}
+	return suites
+}
+
+// CI providers implementing TestReportProvider expose the results of the tests run by jobs.
+// The test reports of jobs of other providers implementing ArtifactProvider are built from
+// the JUnit files found in the artifacts of the job.
+type TestReportProvider interface {
+	// Return the test report of the job 'step' or ErrNoTestReport if the job did not
+	// publish one
+	TestReport(ctx context.Context, step Step) (TestReport, error)
+}
+
+// Return the test report of the job identified by key and stepIDs
+func (c *Cache) TestReport(ctx context.Context, key PipelineKey, stepIDs []string) (TestReport, error) {
+	pipeline, exists := c.Pipeline(key)
+	if !exists {
+		return TestReport{}, fmt.Errorf("no matching pipeline for %v", key)
+	}
+	step, exists := pipeline.getStep(stepIDs)
+	if !exists {
+		return TestReport{}, fmt.Errorf("no matching step for %v %v", key, stepIDs)
+	}
+	provider, exists := c.ciProvidersByID[pipeline.providerID]
+	if !exists {
+		return TestReport{}, fmt.Errorf("no matching Provider found in cache for account ID %q", pipeline.providerID)
+	}
+	if step.Type != StepJob {
+		return TestReport{}, ErrNoTestReport
+	}
+
+	switch p := provider.(type) {
+	case TestReportProvider:
+		ctx, cancel := requestContext(ctx, c.timeout())
+		defer cancel()
+		report, err := p.TestReport(ctx, step)
+		if err != nil && err != ErrNoTestReport {
+			err = ProviderError{
+				ProviderID: provider.ID(),
+				Operation:  fmt.Sprintf("fetch test report of job '%s' of %s", step.Name, pipelineName(pipeline)),
+				URL:        step.WebURL.String,
+				Err:        err,
+			}
+		}
+		return report, err
+	case ArtifactProvider:
+		return c.junitReport(ctx, key, stepIDs)
+	default:
+		return TestReport{}, ErrNoTestReport
+	}
+}
+
+// Build the test report of a job from the JUnit files found in its artifacts
+func (c *Cache) junitReport(ctx context.Context, key PipelineKey, stepIDs []string) (TestReport, error) {
+	artifacts, err := c.Artifacts(ctx, key, stepIDs)
+	if errors.Is(err, ErrNoArtifacts) {
+		return TestReport{}, ErrNoTestReport
+	}
+	if err != nil {
+		return TestReport{}, err
+	}
+	provider, providerID, pipeline, step, err := c.artifactProvider(key, stepIDs)
+	if err != nil {
+		return TestReport{}, err
+	}
+
+	report := TestReport{}
+	found := false
+	for _, artifact := range artifacts {
+		if !strings.HasSuffix(strings.ToLower(artifact.Path), ".xml") {
+			continue
+		}
+		buf := bytes.Buffer{}
+		err := func() error {
+			ctx, cancel := requestContext(ctx, c.timeout())
+			defer cancel()
+			return provider.DownloadArtifact(ctx, step, artifact, &buf)
+		}()
+		if err != nil {
+			return TestReport{}, ProviderError{
+				ProviderID: providerID,
+				Operation:  fmt.Sprintf("download artifact %q of job '%s' of %s", artifact.Path, step.Name, pipelineName(pipeline)),
+				URL:        artifact.URL,
+				Err:        err,
+			}
+		}
+		fileReport, err := parseJUnit(&buf)
+		if err != nil {
+			// Not every XML file is a test report
+			continue
+		}
+		found = true
+		report.Suites = append(report.Suites, fileReport.Suites...)
+	}
+
+	if !found {
+		return TestReport{}, ErrNoTestReport
+	}
+	return report, nil
+}
+
+type junitTestSuites struct {
+	Suites []junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name  string          `xml:"name,attr"`
+	Time  string          `xml:"time,attr"`
+	Cases []junitTestCase `xml:"testcase"`
+	// Some tools nest test suites
+	Suites []junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestCase struct {
+	Name      string       `xml:"name,attr"`
+	ClassName string       `xml:"classname,attr"`
+	Time      string       `xml:"time,attr"`
+	Failure   *junitResult `xml:"failure"`
+	Error     *junitResult `xml:"error"`
+	Skipped   *junitResult `xml:"skipped"`
+}
+
+type junitResult struct {
+	Message string `xml:"message,attr"`
+	Text    string `xml:",chardata"`
+}
+
+func (r junitResult) String() string {
+	return strings.TrimSpace(strings.Join([]string{r.Message, strings.TrimSpace(r.Text)}, "\n"))
+}
+
+// Parse a duration in seconds such as "1.5" or "1,234.5"
+func junitDuration(s string) utils.NullDuration {
+	seconds, err := strconv.ParseFloat(strings.Replace(s, ",", "", -1), 64)
+	if err != nil || seconds < 0 {
+		return utils.NullDuration{}
+	}
+	return utils.NullDuration{
+		Valid:    true,
+		Duration: time.Duration(seconds * float64(time.Second)),
+	}
+}
+
+func (s junitTestSuite) toTestSuites() []TestSuite {
+	suite := TestSuite{
+		Name:     s.Name,
+		Duration: junitDuration(s.Time),
+		Cases:    make([]TestCase, 0, len(s.Cases)),
+	}
+	for _, c := range s.Cases {
+		testCase := TestCase{
+			Name:      c.Name,
+			ClassName: c.ClassName,
+			Status:    TestPassed,
+			Duration:  junitDuration(c.Time),
+		}
+		switch {
+		case c.Failure != nil:
+			testCase.Status = TestFailed
+			testCase.Message = c.Failure.String()
+		case c.Error != nil:
+			testCase.Status = TestErrored
+			testCase.Message = c.Error.String()
+		case c.Skipped != nil:
+			testCase.Status = TestSkipped
+			testCase.Message = c.Skipped.String()
+		}
+		suite.Cases = append(suite.Cases, testCase)
+	}
+
+	suites := make([]TestSuite, 0, 1)
+	if len(suite.Cases) > 0 || len(s.Suites) == 0 {
+		suites = append(suites, suite)
+	}
+	for _, child := range s.Suites {
+		suites = append(suites, child.toTestSuites()...)
+	}
+	return suites
+}
+
+// Parse a test report in the JUnit XML format. The root element is either <testsuites> or
+// a single <testsuite>.
+func parseJUnit(r io.Reader) (TestReport, error) {
+	decoder := xml.NewDecoder(r)
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			return TestReport{}, errNotJUnit
+		}
+		if err != nil {
+			return TestReport{}, err
+		}
+		start, ok := token.(xml.StartElement)
+		if !ok {
+			// Skip the prolog, comments and processing instructions
+			continue
+		}
+
+		var suites []junitTestSuite
+		switch start.Name.Local {
+		case "testsuites":
+			var root junitTestSuites
+			if err := decoder.DecodeElement(&root, &start); err != nil {
+				return TestReport{}, err
+			}
+			suites = root.Suites
+		case "testsuite":
+			var root junitTestSuite
+			if err := decoder.DecodeElement(&root, &start); err != nil {
+				return TestReport{}, err
+			}
+			suites = []junitTestSuite{root}
+		default:
+			return TestReport{}, errNotJUnit
+		}
+
+		report := TestReport{
+			Suites: make([]TestSuite, 0, len(suites)),
+		}
+		for _, suite := range suites {
+			report.Suites = append(report.Suites, suite.toTestSuites()...)
+		}
+		return report, nil
+	}
+}
@@ -0,0 +1,283 @@
+package providers
+
+import (
+	"context"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/nbedos/cistern/utils"
+)
+
+func TestParseJUnit(t *testing.T) {
+	seconds := func(s float64) utils.NullDuration {
+		return utils.NullDuration{Valid: true, Duration: time.Duration(s * float64(time.Second))}
+	}
+
+	t.Run("test suites", func(t *testing.T) {
+		document := `<?xml version="1.0" encoding="UTF-8"?>
+<!-- generated by go-junit-report -->
+<testsuites>
+	<testsuite name="github.com/nbedos/cistern/providers" time="1,234.5">
+		<testcase classname="providers" name="TestCache" time="0.5"></testcase>
+		<testcase classname="providers" name="TestLog" time="0.25">
+			<failure message="Failed">cache_test.go:42: expected 1 but got 2</failure>
+		</testcase>
+		<testcase classname="providers" name="TestPanic">
+			<error>panic: runtime error</error>
+		</testcase>
+		<testcase classname="providers" name="TestSlow">
+			<skipped message="skipped in short mode"/>
+		</testcase>
+	</testsuite>
+	<testsuite name="parent">
+		<testsuite name="child">
+			<testcase name="nested"/>
+		</testsuite>
+	</testsuite>
+</testsuites>`
+
+		report, err := parseJUnit(strings.NewReader(document))
+		if err != nil {
+			t.Fatal(err)
+		}
+		expected := TestReport{
+			Suites: []TestSuite{
+				{
+					Name:     "github.com/nbedos/cistern/providers",
+					Duration: seconds(1234.5),
+					Cases: []TestCase{
+						{
+							Name:      "TestCache",
+							ClassName: "providers",
+							Status:    TestPassed,
+							Duration:  seconds(0.5),
+						},
+						{
+							Name:      "TestLog",
+							ClassName: "providers",
+							Status:    TestFailed,
+							Duration:  seconds(0.25),
+							Message:   "Failed\ncache_test.go:42: expected 1 but got 2",
+						},
+						{
+							Name:      "TestPanic",
+							ClassName: "providers",
+							Status:    TestErrored,
+							Message:   "panic: runtime error",
+						},
+						{
+							Name:      "TestSlow",
+							ClassName: "providers",
+							Status:    TestSkipped,
+							Message:   "skipped in short mode",
+						},
+					},
+				},
+				{
+					Name: "child",
+					Cases: []TestCase{
+						{
+							Name:   "nested",
+							Status: TestPassed,
+						},
+					},
+				},
+			},
+		}
+		if diff := cmp.Diff(expected, report); len(diff) > 0 {
+			t.Fatal(diff)
+		}
+	})
+
+	t.Run("single test suite", func(t *testing.T) {
+		document := `<testsuite name="pytest"><testcase name="test_a" time="1"/></testsuite>`
+		report, err := parseJUnit(strings.NewReader(document))
+		if err != nil {
+			t.Fatal(err)
+		}
+		expected := TestReport{
+			Suites: []TestSuite{
+				{
+					Name: "pytest",
+					Cases: []TestCase{
+						{
+							Name:     "test_a",
+							Status:   TestPassed,
+							Duration: seconds(1),
+						},
+					},
+				},
+			},
+		}
+		if diff := cmp.Diff(expected, report); len(diff) > 0 {
+			t.Fatal(diff)
+		}
+	})
+
+	t.Run("other XML documents are rejected", func(t *testing.T) {
+		for _, document := range []string{`<coverage line-rate="0.9"></coverage>`, ``} {
+			if _, err := parseJUnit(strings.NewReader(document)); err != errNotJUnit {
+				t.Fatalf("expected %v but got %v", errNotJUnit, err)
+			}
+		}
+	})
+}
+
+func TestTestReport_Failures(t *testing.T) {
+	report := TestReport{
+		Suites: []TestSuite{
+			{
+				Name: "a",
+				Cases: []TestCase{
+					{Name: "1", Status: TestPassed},
+					{Name: "2", Status: TestFailed},
+					{Name: "3", Status: TestErrored},
+				},
+			},
+			{
+				Name: "b",
+				Cases: []TestCase{
+					{Name: "4", Status: TestSkipped},
+				},
+			},
+		},
+	}
+
+	expected := []TestSuite{
+		{
+			Name: "a",
+			Cases: []TestCase{
+				{Name: "2", Status: TestFailed},
+				{Name: "3", Status: TestErrored},
+			},
+		},
+	}
+	if diff := cmp.Diff(expected, report.Failures()); len(diff) > 0 {
+		t.Fatal(diff)
+	}
+	if n := report.Count(TestPassed); n != 1 {
+		t.Fatalf("expected 1 passed test but got %d", n)
+	}
+}
+
+type testReportProvider struct {
+	testProvider
+	report TestReport
+}
+
+func (p *testReportProvider) TestReport(ctx context.Context, step Step) (TestReport, error) {
+	return p.report, nil
+}
+
+func TestCache_TestReport(t *testing.T) {
+	pipeline := Pipeline{
+		providerID:   "ci",
+		ProviderHost: "ci.example.com",
+		Step: Step{
+			ID:   "42",
+			Type: StepPipeline,
+			Children: []Step{
+				{ID: "1", Name: "test", Type: StepJob},
+				{ID: "2", Name: "build", Type: StepStage},
+			},
+		},
+	}
+	newCache := func(provider CIProvider) Cache {
+		c := NewCache([]CIProvider{provider}, nil, utils.PollingStrategy{})
+		if _, err := c.SavePipeline("sha", pipeline); err != nil {
+			t.Fatal(err)
+		}
+		return c
+	}
+
+	t.Run("the report of the provider is preferred", func(t *testing.T) {
+		provider := &testReportProvider{
+			testProvider: testProvider{id: "ci", url: "ci.example.com"},
+			report:       TestReport{Suites: []TestSuite{{Name: "suite"}}},
+		}
+		c := newCache(provider)
+		report, err := c.TestReport(context.Background(), pipeline.Key(), []string{"1"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if diff := cmp.Diff(provider.report, report); len(diff) > 0 {
+			t.Fatal(diff)
+		}
+	})
+
+	t.Run("JUnit files are read from artifacts", func(t *testing.T) {
+		provider := &junitArtifactProvider{
+			artifactProvider: artifactProvider{
+				testProvider: testProvider{id: "ci", url: "ci.example.com"},
+				artifacts: []Artifact{
+					{Path: "coverage.xml"},
+					{Path: "binary"},
+					{Path: "reports/junit.xml"},
+				},
+			},
+			content: map[string]string{
+				"coverage.xml":      `<coverage/>`,
+				"reports/junit.xml": `<testsuite name="unit"><testcase name="TestA"/></testsuite>`,
+			},
+		}
+		c := newCache(provider)
+		report, err := c.TestReport(context.Background(), pipeline.Key(), []string{"1"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		expected := TestReport{
+			Suites: []TestSuite{
+				{
+					Name:  "unit",
+					Cases: []TestCase{{Name: "TestA", Status: TestPassed}},
+				},
+			},
+		}
+		if diff := cmp.Diff(expected, report); len(diff) > 0 {
+			t.Fatal(diff)
+		}
+	})
+
+	t.Run("artifacts without JUnit file", func(t *testing.T) {
+		provider := &artifactProvider{
+			testProvider: testProvider{id: "ci", url: "ci.example.com"},
+			artifacts:    []Artifact{{Path: "binary"}},
+		}
+		c := newCache(provider)
+		_, err := c.TestReport(context.Background(), pipeline.Key(), []string{"1"})
+		if err != ErrNoTestReport {
+			t.Fatalf("expected %v but got %v", ErrNoTestReport, err)
+		}
+	})
+
+	t.Run("only jobs have test reports", func(t *testing.T) {
+		provider := &testReportProvider{testProvider: testProvider{id: "ci", url: "ci.example.com"}}
+		c := newCache(provider)
+		_, err := c.TestReport(context.Background(), pipeline.Key(), []string{"2"})
+		if err != ErrNoTestReport {
+			t.Fatalf("expected %v but got %v", ErrNoTestReport, err)
+		}
+	})
+
+	t.Run("providers without test reports nor artifacts", func(t *testing.T) {
+		c := newCache(&testProvider{id: "ci", url: "ci.example.com"})
+		_, err := c.TestReport(context.Background(), pipeline.Key(), []string{"1"})
+		if err != ErrNoTestReport {
+			t.Fatalf("expected %v but got %v", ErrNoTestReport, err)
+		}
+	})
+}
+
+// Provider whose artifacts have a content specific to each artifact
+type junitArtifactProvider struct {
+	artifactProvider
+	content map[string]string
+}
+
+func (p *junitArtifactProvider) DownloadArtifact(ctx context.Context, step Step, a Artifact, w io.Writer) error {
+	_, err := io.WriteString(w, p.content[a.Path])
+	return err
+}
@@ -56,7 +56,9 @@ type travisBuild struct {
 	CreatedBy struct {
 		Login string
 	} `json:"created_by"`
-	Jobs []travisJob
+	PullRequestNumber int    `json:"pull_request_number"`
+	PullRequestTitle  string `json:"pull_request_title"`
+	Jobs              []travisJob
 }
 
 func (b travisBuild) toPipeline(webURL string) (pipeline Pipeline, err error) {
@@ -64,6 +66,7 @@ func (b travisBuild) toPipeline(webURL string) (pipeline Pipeline, err error) {
 		Number: b.Number,
 		Ref:    "",
 		IsTag:  b.Tag.Name != "",
+		Author: b.CreatedBy.Login,
 		Step: Step{
 			ID:    strconv.Itoa(b.ID),
 			State: fromTravisState(b.State),
@@ -89,6 +92,12 @@ func (b travisBuild) toPipeline(webURL string) (pipeline Pipeline, err error) {
 	} else {
 		pipeline.Ref = b.Tag.Name
 	}
+	if b.EventType == "pull_request" && b.PullRequestNumber > 0 {
+		pipeline.PullRequest = PullRequest{
+			Number: strconv.Itoa(b.PullRequestNumber),
+			Title:  b.PullRequestTitle,
+		}
+	}
 
 	pipeline.WebURL = utils.NullString{
 		String: fmt.Sprintf("%s/builds/%d", webURL, b.ID),
@@ -98,11 +107,13 @@ func (b travisBuild) toPipeline(webURL string) (pipeline Pipeline, err error) {
 	sort.Slice(b.Jobs, func(i, j int) bool {
 		return b.Jobs[i].Stage.ID < b.Jobs[j].Stage.ID || (b.Jobs[i].Stage.ID == b.Jobs[j].Stage.ID && b.Jobs[i].ID < b.Jobs[j].ID)
 	})
+	numbers := make(map[string]string, len(b.Jobs))
 	for _, travisJob := range b.Jobs {
 		job, err := travisJob.toStep(webURL)
 		if err != nil {
 			return pipeline, err
 		}
+		numbers[job.ID] = travisJob.Number
 
 		pipeline.CreatedAt = utils.MinNullTime(job.CreatedAt, pipeline.CreatedAt)
 
@@ -124,9 +135,31 @@ func (b travisBuild) toPipeline(webURL string) (pipeline Pipeline, err error) {
 		}
 	}
 
+	disambiguateTravisJobs(pipeline.Children, numbers)
+	for i := range pipeline.Children {
+		disambiguateTravisJobs(pipeline.Children[i].Children, numbers)
+	}
+
 	return pipeline, nil
 }
 
+// Jobs of a build matrix that only differ by settings not shown in their name would all be
+// named identically. Append the number of the job (e.g. "72.3") to the name of these jobs
+// so that they can be told apart.
+func disambiguateTravisJobs(steps []Step, numbers map[string]string) {
+	counts := make(map[string]int)
+	for _, step := range steps {
+		if step.Type == StepJob {
+			counts[step.Name]++
+		}
+	}
+	for i, step := range steps {
+		if number := numbers[step.ID]; step.Type == StepJob && counts[step.Name] > 1 && number != "" {
+			steps[i].Name = strings.TrimSpace(fmt.Sprintf("%s #%s", step.Name, number))
+		}
+	}
+}
+
 type travisJob struct {
 	ID           int
 	State        string
@@ -164,8 +197,16 @@ func (c travisJobConfig) String() string {
 	compiler, _ = c["compiler"].(string)
 	os, _ = c["os"].(string)
 	dist, _ = c["dist"].(string)
+	osxImage, _ := c["osx_image"].(string)
+	jdk, _ := c["jdk"].(string)
+
+	// amd64 is the default architecture, only mention the others
+	arch, _ := c["arch"].(string)
+	if arch == "amd64" {
+		arch = ""
+	}
 
-	values := []string{os, dist, language, compiler}
+	values := []string{os, dist, osxImage, arch, language, compiler, jdk, c.env()}
 
 	nonEmptyValues := make([]string, 0, len(values))
 	for _, value := range values {
@@ -176,6 +217,39 @@ func (c travisJobConfig) String() string {
 	return strings.Join(nonEmptyValues, ", ")
 }
 
+// Return the environment variables set for the job, e.g. "DB=postgres TEST_SUITE=unit". The
+// configuration may list them as a string, a list of strings or a list of maps.
+func (c travisJobConfig) env() string {
+	var values []string
+	var collect func(v interface{})
+	collect = func(v interface{}) {
+		switch env := v.(type) {
+		case string:
+			if env != "" {
+				values = append(values, env)
+			}
+		case []interface{}:
+			for _, e := range env {
+				collect(e)
+			}
+		case map[string]interface{}:
+			keys := make([]string, 0, len(env))
+			for key := range env {
+				keys = append(keys, key)
+			}
+			sort.Strings(keys)
+			for _, key := range keys {
+				if value, ok := env[key].(string); ok {
+					values = append(values, fmt.Sprintf("%s=%s", key, value))
+				}
+			}
+		}
+	}
+	collect(c["env"])
+
+	return strings.Join(values, " ")
+}
+
 func (j travisJob) toStep(webURL string) (Step, error) {
 	var err error
 
@@ -254,18 +328,21 @@ func (s travisStage) toStep(webURL string) (Step, error) {
 
 type TravisClient struct {
 	baseURL            url.URL
+	webBaseURL         url.URL
 	httpClient         *http.Client
 	rateLimiter        <-chan time.Time
 	logBackoffInterval time.Duration
 	buildsPageSize     int
 	token              string
 	provider           Provider
+	// Requests sent through httpClient
+	requests *requestCounter
 }
 
 var TravisOrgURL = url.URL{Scheme: "https", Host: "api.travis-ci.org"}
 var TravisComURL = url.URL{Scheme: "https", Host: "api.travis-ci.com"}
 
-func NewTravisClient(id string, name string, token string, URL string, requestsPerSecond float64) (TravisClient, error) {
+func NewTravisClient(id string, name string, token string, URL string, webURL string, requestsPerSecond float64) (TravisClient, error) {
 	rateLimit := time.Second / 20
 	if requestsPerSecond > 0 {
 		rateLimit = time.Second / time.Duration(requestsPerSecond)
@@ -283,10 +360,26 @@ func NewTravisClient(id string, name string, token string, URL string, requestsP
 			return TravisClient{}, err
 		}
 	}
+	apiURL := *u
+	apiURL.Path = strings.TrimSuffix(apiURL.Path, "/")
+
+	w := travisWebURL(apiURL)
+	if webURL != "" {
+		v, err := url.Parse(webURL)
+		if err != nil {
+			return TravisClient{}, err
+		}
+		w = *v
+		w.Path = strings.TrimSuffix(w.Path, "/")
+	}
+
+	httpClient, requests := countedClient(nil)
 
 	return TravisClient{
-		baseURL:            *u,
-		httpClient:         &http.Client{Timeout: 10 * time.Second},
+		baseURL:            apiURL,
+		webBaseURL:         w,
+		httpClient:         httpClient,
+		requests:           requests,
 		rateLimiter:        time.Tick(rateLimit),
 		logBackoffInterval: 10 * time.Second,
 		token:              token,
@@ -298,6 +391,10 @@ func NewTravisClient(id string, name string, token string, URL string, requestsP
 	}, nil
 }
 
+func (c TravisClient) RequestStats() RequestStats {
+	return c.requests.snapshot()
+}
+
 func (c TravisClient) ID() string {
 	return c.provider.ID
 }
@@ -310,8 +407,20 @@ func (c TravisClient) Name() string {
 	return c.provider.Name
 }
 
+// Return the URL of the website of the Travis instance based on the URL of its API.
+// The API of travis-ci.org and travis-ci.com is served by a subdomain ("api.travis-ci.org")
+// whereas the API of Travis CI Enterprise is served under the "/api" path of the instance
+// ("https://travis.example.com/api").
+func travisWebURL(apiURL url.URL) url.URL {
+	webURL := apiURL
+	webURL.Host = strings.TrimPrefix(webURL.Host, "api.")
+	webURL.Path = strings.TrimSuffix(webURL.Path, "/api")
+
+	return webURL
+}
+
 func (c TravisClient) BuildFromURL(ctx context.Context, u string) (Pipeline, error) {
-	owner, repo, id, err := parseTravisWebURL(&c.baseURL, u)
+	owner, repo, id, err := parseTravisWebURL(&c.webBaseURL, u)
 	if err != nil {
 		return Pipeline{}, err
 	}
@@ -321,18 +430,18 @@ func (c TravisClient) BuildFromURL(ctx context.Context, u string) (Pipeline, err
 }
 
 // Extract owner, repository and build ID from web url of build
-func parseTravisWebURL(baseURL *url.URL, u string) (string, string, string, error) {
+func parseTravisWebURL(webBaseURL *url.URL, u string) (string, string, string, error) {
 	v, err := url.Parse(u)
 	if err != nil {
 		return "", "", "", err
 	}
 
-	if v.Hostname() != strings.TrimPrefix(baseURL.Hostname(), "api.") {
+	if v.Hostname() != webBaseURL.Hostname() {
 		return "", "", "", ErrUnknownPipelineURL
 	}
 
 	// url format: https://travis-ci.org/nbedos/termtosvg/builds/612815758
-	cs := strings.Split(v.EscapedPath(), "/")
+	cs := strings.Split(strings.TrimPrefix(v.EscapedPath(), webBaseURL.Path), "/")
 	if len(cs) < 5 || cs[3] != "builds" {
 		return "", "", "", ErrUnknownPipelineURL
 	}
@@ -343,8 +452,7 @@ func parseTravisWebURL(baseURL *url.URL, u string) (string, string, string, erro
 
 func (c TravisClient) webURL(slug string) (url.URL, error) {
 	var err error
-	webURL := c.baseURL
-	webURL.Host = strings.TrimPrefix(webURL.Host, "api.")
+	webURL := c.webBaseURL
 	webURL.Path += fmt.Sprintf("/%s", slug)
 
 	return webURL, err
@@ -380,10 +488,11 @@ func (c TravisClient) get(ctx context.Context, method string, resourceURL url.UR
 
 	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
 		err = HTTPError{
-			Method:  req.Method,
-			URL:     req.URL.String(),
-			Status:  resp.StatusCode,
-			Message: body.String(),
+			Method:     req.Method,
+			URL:        req.URL.String(),
+			Status:     resp.StatusCode,
+			Message:    body.String(),
+			RetryAfter: resp.Header.Get("Retry-After"),
 		}
 	}
 
@@ -395,6 +504,8 @@ type HTTPError struct {
 	URL     string
 	Status  int
 	Message string
+	// Value of the Retry-After header of the response, if any
+	RetryAfter string
 }
 
 func (err HTTPError) Error() string {
@@ -10,6 +10,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/google/go-cmp/cmp"
 	"github.com/nbedos/cistern/utils"
 )
 
@@ -34,6 +35,7 @@ func TestTravisClientfetchPipeline(t *testing.T) {
 
 	client := TravisClient{
 		baseURL:     *URL,
+		webBaseURL:  *URL,
 		httpClient:  ts.Client(),
 		rateLimiter: time.Tick(time.Millisecond),
 		token:       "token",
@@ -48,6 +50,7 @@ func TestTravisClientfetchPipeline(t *testing.T) {
 		Number: "72",
 		Ref:    "feature/travis_improvements",
 		IsTag:  false,
+		Author: "nbedos",
 		Step: Step{
 			ID:    "609256446",
 			Type:  StepPipeline,
@@ -234,14 +237,149 @@ func TestTravisClientfetchPipeline(t *testing.T) {
 }
 
 func TestParseTravisWebURL(t *testing.T) {
-	u := "https://travis-ci.org/nbedos/termtosvg/builds/612815758"
+	testCases := []struct {
+		apiURL string
+		url    string
+	}{
+		{
+			apiURL: "https://api.travis-ci.org",
+			url:    "https://travis-ci.org/nbedos/termtosvg/builds/612815758",
+		},
+		{
+			apiURL: "https://travis.example.com/api",
+			url:    "https://travis.example.com/nbedos/termtosvg/builds/612815758",
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.url, func(t *testing.T) {
+			client, err := NewTravisClient("travis", "travis", "", testCase.apiURL, "", 0)
+			if err != nil {
+				t.Fatal(err)
+			}
 
-	owner, repo, id, err := parseTravisWebURL(&TravisOrgURL, u)
+			owner, repo, id, err := parseTravisWebURL(&client.webBaseURL, testCase.url)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if owner != "nbedos" || repo != "termtosvg" || id != "612815758" {
+				t.Fail()
+			}
+		})
+	}
+
+	t.Run("url of another host", func(t *testing.T) {
+		webURL := travisWebURL(TravisOrgURL)
+		_, _, _, err := parseTravisWebURL(&webURL, "https://example.com/nbedos/termtosvg/builds/612815758")
+		if err != ErrUnknownPipelineURL {
+			t.Fatalf("expected %v but got %v", ErrUnknownPipelineURL, err)
+		}
+	})
+}
+
+func TestTravisJobConfig_String(t *testing.T) {
+	testCases := []struct {
+		name   string
+		config travisJobConfig
+		output string
+	}{
+		{
+			name: "language version and os",
+			config: travisJobConfig{
+				"language": "go",
+				"go":       "1.13.x",
+				"os":       "linux",
+				"dist":     "bionic",
+			},
+			output: "linux, bionic, go 1.13.x",
+		},
+		{
+			name: "macOS image and architecture",
+			config: travisJobConfig{
+				"language":  "python",
+				"python":    3.8,
+				"os":        "osx",
+				"osx_image": "xcode11.2",
+				"arch":      "arm64",
+			},
+			output: "osx, xcode11.2, arm64, python 3.8",
+		},
+		{
+			name: "environment variables as a string",
+			config: travisJobConfig{
+				"language": "ruby",
+				"env":      "DB=postgres TEST_SUITE=unit",
+			},
+			output: "ruby, DB=postgres TEST_SUITE=unit",
+		},
+		{
+			name: "environment variables as a list",
+			config: travisJobConfig{
+				"language": "node_js",
+				"env":      []interface{}{"A=1", map[string]interface{}{"C": "3", "B": "2"}},
+			},
+			output: "node_js, A=1 B=2 C=3",
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			if s := testCase.config.String(); s != testCase.output {
+				t.Fatalf("expected %q but got %q", testCase.output, s)
+			}
+		})
+	}
+}
+
+func TestTravisBuild_toPipelineMatrix(t *testing.T) {
+	config := travisJobConfig{"language": "go", "go": "1.13.x"}
+	build := travisBuild{
+		ID: 1,
+		Jobs: []travisJob{
+			{ID: 1, Number: "72.1", Config: config},
+			{ID: 2, Number: "72.2", Config: config},
+			{ID: 3, Number: "72.3", Config: travisJobConfig{"language": "go", "go": "1.12.x"}},
+		},
+	}
+
+	pipeline, err := build.toPipeline("https://travis-ci.org/owner/repo")
 	if err != nil {
 		t.Fatal(err)
 	}
 
-	if owner != "nbedos" || repo != "termtosvg" || id != "612815758" {
-		t.Fail()
+	names := make([]string, 0)
+	for _, job := range pipeline.Children {
+		names = append(names, job.Name)
+	}
+	expected := []string{"go 1.13.x #72.1", "go 1.13.x #72.2", "go 1.12.x"}
+	if diff := cmp.Diff(expected, names); len(diff) > 0 {
+		t.Fatal(diff)
+	}
+}
+
+func TestTravisBuild_toPipelinePullRequest(t *testing.T) {
+	build := travisBuild{
+		ID:                1,
+		EventType:         "pull_request",
+		PullRequestNumber: 12,
+		PullRequestTitle:  "Fix typo",
+	}
+
+	pipeline, err := build.toPipeline("https://travis-ci.org/owner/repo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := PullRequest{Number: "12", Title: "Fix typo"}
+	if diff := cmp.Diff(expected, pipeline.PullRequest); len(diff) > 0 {
+		t.Fatal(diff)
+	}
+
+	build.EventType = "push"
+	if pipeline, err = build.toPipeline("https://travis-ci.org/owner/repo"); err != nil {
+		t.Fatal(err)
+	}
+	if pipeline.PullRequest.Valid() {
+		t.Fatalf("expected no pull request but got %+v", pipeline.PullRequest)
 	}
 }
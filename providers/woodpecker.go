@@ -0,0 +1,341 @@
+package providers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/nbedos/cistern/utils"
+)
+
+type WoodpeckerClient struct {
+	baseURL     url.URL
+	httpClient  *http.Client
+	rateLimiter <-chan time.Time
+	token       string
+	provider    Provider
+	// Requests sent through httpClient
+	requests *requestCounter
+}
+
+func NewWoodpeckerClient(id string, name string, token string, URL string, requestsPerSecond float64) (WoodpeckerClient, error) {
+	if URL == "" {
+		return WoodpeckerClient{}, errors.New("the url of a woodpecker instance must not be empty")
+	}
+	u, err := url.Parse(URL)
+	if err != nil {
+		return WoodpeckerClient{}, err
+	}
+	u.Path = strings.TrimSuffix(u.Path, "/")
+
+	rateLimit := time.Second / 10
+	if requestsPerSecond > 0 {
+		rateLimit = time.Second / time.Duration(requestsPerSecond)
+	}
+
+	httpClient, requests := countedClient(nil)
+
+	return WoodpeckerClient{
+		baseURL:     *u,
+		httpClient:  httpClient,
+		requests:    requests,
+		rateLimiter: time.Tick(rateLimit),
+		token:       token,
+		provider: Provider{
+			ID:   id,
+			Name: name,
+		},
+	}, nil
+}
+
+func (c WoodpeckerClient) RequestStats() RequestStats {
+	return c.requests.snapshot()
+}
+
+func (c WoodpeckerClient) ID() string {
+	return c.provider.ID
+}
+
+func (c WoodpeckerClient) Host() string {
+	return c.baseURL.Host
+}
+
+func (c WoodpeckerClient) Name() string {
+	return c.provider.Name
+}
+
+func (c WoodpeckerClient) BuildFromURL(ctx context.Context, u string) (Pipeline, error) {
+	owner, repo, number, err := parseWoodpeckerWebURL(&c.baseURL, u)
+	if err != nil {
+		return Pipeline{}, err
+	}
+
+	return c.fetchPipeline(ctx, owner, repo, number)
+}
+
+// Extract owner, repository and build number from web url of build
+func parseWoodpeckerWebURL(baseURL *url.URL, u string) (string, string, int, error) {
+	v, err := url.Parse(u)
+	if err != nil {
+		return "", "", 0, err
+	}
+
+	if v.Hostname() != baseURL.Hostname() {
+		return "", "", 0, ErrUnknownPipelineURL
+	}
+
+	// url format: https://ci.example.com/nbedos/cistern/build/42
+	//         OR  https://ci.example.com/nbedos/cistern/pipeline/42
+	cs := strings.Split(strings.TrimPrefix(v.Path, baseURL.Path), "/")
+	if len(cs) < 5 || (cs[3] != "build" && cs[3] != "pipeline") {
+		return "", "", 0, ErrUnknownPipelineURL
+	}
+
+	owner, repo := cs[1], cs[2]
+	number, err := strconv.Atoi(cs[4])
+	if err != nil {
+		return "", "", 0, err
+	}
+
+	return owner, repo, number, nil
+}
+
+func (c WoodpeckerClient) endpoint(format string, a ...interface{}) url.URL {
+	endpoint := c.baseURL
+	endpoint.Path += fmt.Sprintf(format, a...)
+
+	return endpoint
+}
+
+func (c WoodpeckerClient) fetchPipeline(ctx context.Context, owner string, repo string, number int) (Pipeline, error) {
+	var build woodpeckerBuild
+	endpoint := c.endpoint("/api/repos/%s/%s/builds/%d", owner, repo, number)
+	if err := c.getJSON(ctx, endpoint, &build); err != nil {
+		return Pipeline{}, err
+	}
+
+	webURL := c.endpoint("/%s/%s/build/%d", owner, repo, number)
+	logURL := c.endpoint("/api/repos/%s/%s/logs/%d", owner, repo, number)
+
+	return build.toPipeline(webURL, logURL), nil
+}
+
+func (c WoodpeckerClient) Log(ctx context.Context, step Step) (string, error) {
+	if step.Log.Key == "" {
+		return "", ErrNoLogHere
+	}
+
+	u, err := url.Parse(step.Log.Key)
+	if err != nil {
+		return "", err
+	}
+
+	var lines []struct {
+		Out string `json:"out"`
+	}
+	if err := c.getJSON(ctx, *u, &lines); err != nil {
+		return "", err
+	}
+
+	builder := strings.Builder{}
+	for _, line := range lines {
+		builder.WriteString(line.Out)
+		if !strings.HasSuffix(line.Out, "\n") {
+			builder.WriteString("\n")
+		}
+	}
+
+	return builder.String(), nil
+}
+
+func (c WoodpeckerClient) getJSON(ctx context.Context, u url.URL, v interface{}) error {
+	r, err := c.get(ctx, u)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if errClose := r.Close(); err == nil {
+			err = errClose
+		}
+	}()
+
+	err = json.NewDecoder(r).Decode(v)
+	return err
+}
+
+func (c WoodpeckerClient) get(ctx context.Context, u url.URL) (io.ReadCloser, error) {
+	req, err := http.NewRequest("GET", u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Add("Accept", "application/json")
+	if c.token != "" {
+		req.Header.Add("Authorization", fmt.Sprintf("Bearer %s", c.token))
+	}
+	req = req.WithContext(ctx)
+
+	select {
+	case <-c.rateLimiter:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		message, err := ioutil.ReadAll(resp.Body)
+		if err != nil {
+			message = nil
+		}
+		resp.Body.Close()
+		return nil, HTTPError{
+			Method:     req.Method,
+			URL:        u.String(),
+			Status:     resp.StatusCode,
+			Message:    string(message),
+			RetryAfter: resp.Header.Get("Retry-After"),
+		}
+	}
+
+	return resp.Body, nil
+}
+
+func fromWoodpeckerState(s string) State {
+	switch strings.ToLower(s) {
+	case "pending", "blocked":
+		return Pending
+	case "running":
+		return Running
+	case "success":
+		return Passed
+	case "failure", "error":
+		return Failed
+	case "killed", "declined":
+		return Canceled
+	case "skipped":
+		return Skipped
+	default:
+		return Unknown
+	}
+}
+
+// Woodpecker dates are UNIX timestamps where 0 stands for a missing value
+func woodpeckerTime(timestamp int64) utils.NullTime {
+	if timestamp <= 0 {
+		return utils.NullTime{}
+	}
+	return utils.NullTime{
+		Valid: true,
+		Time:  time.Unix(timestamp, 0).UTC(),
+	}
+}
+
+type woodpeckerBuild struct {
+	ID         int              `json:"id"`
+	Number     int              `json:"number"`
+	Event      string           `json:"event"`
+	Status     string           `json:"status"`
+	CreatedAt  int64            `json:"created_at"`
+	UpdatedAt  int64            `json:"updated_at"`
+	StartedAt  int64            `json:"started_at"`
+	FinishedAt int64            `json:"finished_at"`
+	Commit     string           `json:"commit"`
+	Branch     string           `json:"branch"`
+	Ref        string           `json:"ref"`
+	Procs      []woodpeckerProc `json:"procs"`
+}
+
+func (b woodpeckerBuild) toPipeline(webURL url.URL, logURL url.URL) Pipeline {
+	pipeline := Pipeline{
+		Number: strconv.Itoa(b.Number),
+		Ref:    b.Branch,
+		IsTag:  b.Event == "tag",
+		Step: Step{
+			ID:         strconv.Itoa(b.ID),
+			Type:       StepPipeline,
+			State:      fromWoodpeckerState(b.Status),
+			CreatedAt:  woodpeckerTime(b.CreatedAt),
+			StartedAt:  woodpeckerTime(b.StartedAt),
+			FinishedAt: woodpeckerTime(b.FinishedAt),
+			UpdatedAt:  woodpeckerTime(b.UpdatedAt),
+			WebURL: utils.NullString{
+				Valid:  true,
+				String: webURL.String(),
+			},
+		},
+	}
+	if pipeline.IsTag {
+		pipeline.Ref = strings.TrimPrefix(b.Ref, "refs/tags/")
+	}
+	if !pipeline.UpdatedAt.Valid {
+		pipeline.UpdatedAt = utils.MaxNullTime(
+			pipeline.CreatedAt,
+			pipeline.StartedAt,
+			pipeline.FinishedAt)
+	}
+	pipeline.Duration = utils.NullSub(pipeline.FinishedAt, pipeline.StartedAt)
+
+	// Woodpecker returns a flat list of procs linked to their parent by the "ppid" attribute.
+	// Procs without a parent are workflows, which are shown as jobs, and the other procs are
+	// the steps of the workflow, shown as tasks.
+	sort.Slice(b.Procs, func(i, j int) bool {
+		return b.Procs[i].PID < b.Procs[j].PID
+	})
+	jobIndexByPID := make(map[int]int)
+	for _, proc := range b.Procs {
+		if proc.PPID == 0 {
+			job := proc.toStep(StepJob, pipeline)
+			jobIndexByPID[proc.PID] = len(pipeline.Children)
+			pipeline.Children = append(pipeline.Children, job)
+		}
+	}
+	for _, proc := range b.Procs {
+		if index, exists := jobIndexByPID[proc.PPID]; exists && proc.PPID != 0 {
+			task := proc.toStep(StepTask, pipeline)
+			u := logURL
+			u.Path += fmt.Sprintf("/%d", proc.PID)
+			task.Log.Key = u.String()
+			pipeline.Children[index].Children = append(pipeline.Children[index].Children, task)
+		}
+	}
+
+	return pipeline
+}
+
+type woodpeckerProc struct {
+	ID        int    `json:"id"`
+	PID       int    `json:"pid"`
+	PPID      int    `json:"ppid"`
+	Name      string `json:"name"`
+	State     string `json:"state"`
+	ExitCode  int    `json:"exit_code"`
+	StartTime int64  `json:"start_time"`
+	EndTime   int64  `json:"end_time"`
+}
+
+func (p woodpeckerProc) toStep(stepType StepType, pipeline Pipeline) Step {
+	step := Step{
+		ID:         strconv.Itoa(p.PID),
+		Name:       p.Name,
+		Type:       stepType,
+		State:      fromWoodpeckerState(p.State),
+		CreatedAt:  pipeline.CreatedAt,
+		StartedAt:  woodpeckerTime(p.StartTime),
+		FinishedAt: woodpeckerTime(p.EndTime),
+		WebURL:     pipeline.WebURL,
+	}
+	step.Duration = utils.NullSub(step.FinishedAt, step.StartedAt)
+
+	return step
+}
@@ -0,0 +1,209 @@
+package providers
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/nbedos/cistern/utils"
+)
+
+func setupWoodpeckerTestServer(t *testing.T) (*http.Client, *url.URL, func()) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		filename := ""
+		switch r.URL.Path {
+		case "/api/repos/nbedos/cistern/builds/42":
+			filename = "woodpecker_build.json"
+		case "/api/repos/nbedos/cistern/logs/42/3":
+			filename = "woodpecker_log.json"
+		default:
+			w.WriteHeader(404)
+			return
+		}
+
+		bs, err := ioutil.ReadFile(path.Join("test_data", "woodpecker", filename))
+		if err != nil {
+			w.WriteHeader(500)
+			fmt.Fprint(w, err.Error())
+			return
+		}
+		if _, err := fmt.Fprint(w, string(bs)); err != nil {
+			w.WriteHeader(500)
+			fmt.Fprint(w, err.Error())
+			return
+		}
+	}))
+
+	testURL, err := url.Parse(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return ts.Client(), testURL, func() { ts.Close() }
+}
+
+func TestParseWoodpeckerWebURL(t *testing.T) {
+	baseURL := url.URL{
+		Scheme: "https",
+		Host:   "ci.example.com",
+	}
+
+	for _, u := range []string{
+		"https://ci.example.com/nbedos/cistern/build/42",
+		"https://ci.example.com/nbedos/cistern/pipeline/42",
+	} {
+		t.Run(u, func(t *testing.T) {
+			owner, repo, number, err := parseWoodpeckerWebURL(&baseURL, u)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if owner != "nbedos" || repo != "cistern" || number != 42 {
+				t.Fail()
+			}
+		})
+	}
+
+	t.Run("url of another host", func(t *testing.T) {
+		_, _, _, err := parseWoodpeckerWebURL(&baseURL, "https://example.com/nbedos/cistern/build/42")
+		if err != ErrUnknownPipelineURL {
+			t.Fatalf("expected %v but got %v", ErrUnknownPipelineURL, err)
+		}
+	})
+}
+
+func TestWoodpeckerClient_BuildFromURL(t *testing.T) {
+	httpClient, testURL, teardown := setupWoodpeckerTestServer(t)
+	defer teardown()
+
+	client := WoodpeckerClient{
+		baseURL:     *testURL,
+		httpClient:  httpClient,
+		rateLimiter: time.Tick(time.Millisecond),
+	}
+
+	pipelineURL := testURL.String() + "/nbedos/cistern/build/42"
+	pipeline, err := client.BuildFromURL(context.Background(), pipelineURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	at := func(minutes int, seconds int) utils.NullTime {
+		return utils.NullTime{
+			Valid: true,
+			Time:  time.Date(2020, 1, 30, 13, minutes, seconds, 0, time.UTC),
+		}
+	}
+	webURL := utils.NullString{
+		Valid:  true,
+		String: pipelineURL,
+	}
+
+	expectedPipeline := Pipeline{
+		Number: "42",
+		Ref:    "master",
+		IsTag:  false,
+		Step: Step{
+			ID:         "1042",
+			Type:       StepPipeline,
+			State:      Failed,
+			CreatedAt:  at(0, 0),
+			StartedAt:  at(0, 5),
+			FinishedAt: at(1, 30),
+			UpdatedAt:  at(1, 30),
+			Duration: utils.NullDuration{
+				Valid:    true,
+				Duration: 85 * time.Second,
+			},
+			WebURL: webURL,
+			Children: []Step{
+				{
+					ID:         "1",
+					Name:       "pipeline",
+					Type:       StepJob,
+					State:      Failed,
+					CreatedAt:  at(0, 0),
+					StartedAt:  at(0, 5),
+					FinishedAt: at(1, 30),
+					Duration: utils.NullDuration{
+						Valid:    true,
+						Duration: 85 * time.Second,
+					},
+					WebURL: webURL,
+					Children: []Step{
+						{
+							ID:         "2",
+							Name:       "clone",
+							Type:       StepTask,
+							State:      Passed,
+							CreatedAt:  at(0, 0),
+							StartedAt:  at(0, 5),
+							FinishedAt: at(0, 30),
+							Duration: utils.NullDuration{
+								Valid:    true,
+								Duration: 25 * time.Second,
+							},
+							WebURL: webURL,
+							Log: Log{
+								Key: testURL.String() + "/api/repos/nbedos/cistern/logs/42/2",
+							},
+						},
+						{
+							ID:         "3",
+							Name:       "test",
+							Type:       StepTask,
+							State:      Failed,
+							CreatedAt:  at(0, 0),
+							StartedAt:  at(0, 30),
+							FinishedAt: at(1, 30),
+							Duration: utils.NullDuration{
+								Valid:    true,
+								Duration: time.Minute,
+							},
+							WebURL: webURL,
+							Log: Log{
+								Key: testURL.String() + "/api/repos/nbedos/cistern/logs/42/3",
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+	if diff := expectedPipeline.Diff(pipeline); len(diff) > 0 {
+		t.Fatal(diff)
+	}
+}
+
+func TestWoodpeckerClient_Log(t *testing.T) {
+	httpClient, testURL, teardown := setupWoodpeckerTestServer(t)
+	defer teardown()
+
+	client := WoodpeckerClient{
+		baseURL:     *testURL,
+		httpClient:  httpClient,
+		rateLimiter: time.Tick(time.Millisecond),
+	}
+
+	step := Step{
+		Log: Log{
+			Key: testURL.String() + "/api/repos/nbedos/cistern/logs/42/3",
+		},
+	}
+
+	log, err := client.Log(context.Background(), step)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if diff := cmp.Diff("+ go test ./...\nFAIL\n", log); len(diff) > 0 {
+		t.Fatal(diff)
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"sort"
 	"strings"
 	"sync"
+	"unicode"
 
 	"github.com/gdamore/tcell"
 	"github.com/mattn/go-runewidth"
@@ -11,9 +12,11 @@ import (
 )
 
 type Command struct {
-	width   int
-	height  int
-	input   string
+	width  int
+	height int
+	input  string
+	// Position of the cursor in the input, counted in runes
+	cursor  int
 	focused bool
 	prefix  string
 	tooltip *completion
@@ -48,11 +51,21 @@ func (c Command) Draw(w Window) {
 
 	inputLine := StyledString{}
 	if c.focused {
-		inputLine.Append(c.prefix + c.input)
-		// Append cursor
-		inputLine.Append(" ", func(s tcell.Style) tcell.Style {
+		runes := []rune(c.input)
+		// Drop the beginning of the line if needed to keep the cursor on screen
+		before := []rune(c.prefix + string(runes[:c.cursor]))
+		for len(before) > 0 && runewidth.StringWidth(string(before))+1 > c.width {
+			before = before[1:]
+		}
+		inputLine.Append(string(before))
+		under, after := " ", ""
+		if c.cursor < len(runes) {
+			under, after = string(runes[c.cursor]), string(runes[c.cursor+1:])
+		}
+		inputLine.Append(under, func(s tcell.Style) tcell.Style {
 			return s.Reverse(true)
 		})
+		inputLine.Append(after)
 	}
 	w.Draw(0, c.height-1, inputLine)
 }
@@ -63,15 +76,29 @@ func (c *Command) Process(ev *tcell.EventKey) {
 		c.setInput("")
 		c.focused = false
 	case tcell.KeyCtrlU:
-		c.setInput("")
+		c.deleteRange(0, c.cursor)
+	case tcell.KeyCtrlK:
+		c.deleteRange(c.cursor, len([]rune(c.input)))
+	case tcell.KeyCtrlW:
+		c.deleteRange(c.previousWord(), c.cursor)
 	case tcell.KeyBackspace, tcell.KeyBackspace2:
-		c.deleteLastRune()
+		c.deleteRange(c.cursor-1, c.cursor)
+	case tcell.KeyDelete, tcell.KeyCtrlD:
+		c.deleteRange(c.cursor, c.cursor+1)
+	case tcell.KeyLeft:
+		c.moveCursor(c.cursor - 1)
+	case tcell.KeyRight:
+		c.moveCursor(c.cursor + 1)
+	case tcell.KeyHome, tcell.KeyCtrlA:
+		c.moveCursor(0)
+	case tcell.KeyEnd, tcell.KeyCtrlE:
+		c.moveCursor(len([]rune(c.input)))
 	case tcell.KeyTab:
 		c.Complete(false)
 	case tcell.KeyBacktab:
 		c.Complete(true)
 	case tcell.KeyRune:
-		c.setInput(string(append([]rune(c.input), ev.Rune())))
+		c.insert(ev.Rune())
 	case tcell.KeyDown, tcell.KeyCtrlN:
 		c.scrollBy(1)
 	case tcell.KeyUp, tcell.KeyCtrlP:
@@ -89,19 +116,59 @@ func (c *Command) SetCompletions(suggestions Suggestions) {
 	c.tooltip.setSuggestions(suggestions)
 }
 
-func (c *Command) deleteLastRune() {
-	if len(c.input) > 0 {
-		runes := []rune(c.input)
-		c.setInput(string(runes[:len(runes)-1]))
+// Insert r at the position of the cursor. Text pasted in the terminal is received one rune
+// at a time so it is inserted the same way.
+func (c *Command) insert(r rune) {
+	runes := []rune(c.input)
+	runes = append(runes[:c.cursor], append([]rune{r}, runes[c.cursor:]...)...)
+	cursor := c.cursor + 1
+	c.setInput(string(runes))
+	c.cursor = cursor
+}
+
+// Delete runes of the input from index 'from' (included) to index 'to' (excluded) and move
+// the cursor to 'from'
+func (c *Command) deleteRange(from int, to int) {
+	runes := []rune(c.input)
+	from = utils.Bounded(from, 0, len(runes))
+	to = utils.Bounded(to, from, len(runes))
+	if from == to {
+		return
 	}
+	c.setInput(string(append(runes[:from], runes[to:]...)))
+	c.cursor = from
+}
+
+func (c *Command) moveCursor(index int) {
+	c.cursor = utils.Bounded(index, 0, len([]rune(c.input)))
+}
+
+// Return the index of the beginning of the word preceding the cursor. Words are separated by
+// white space.
+func (c Command) previousWord() int {
+	runes := []rune(c.input)
+	i := c.cursor
+	for i > 0 && unicode.IsSpace(runes[i-1]) {
+		i--
+	}
+	for i > 0 && !unicode.IsSpace(runes[i-1]) {
+		i--
+	}
+	return i
 }
 
 func (c Command) Input() string {
 	return c.input
 }
 
+// Replace the content of the prompt
+func (c *Command) SetInput(s string) {
+	c.setInput(s)
+}
+
 func (c *Command) setInput(s string) {
 	c.input = s
+	c.cursor = len([]rune(s))
 	c.tooltip.scrollTo(c.input)
 }
 
@@ -117,6 +184,7 @@ func (c *Command) Complete(reverse bool) {
 			candidate = c.tooltip.suggestions[c.tooltip.cursorIndex.Int]
 		}
 		c.input = candidate.Value
+		c.cursor = len([]rune(c.input))
 	}
 }
 
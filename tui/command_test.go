@@ -0,0 +1,90 @@
+package tui
+
+import (
+	"testing"
+
+	"github.com/gdamore/tcell"
+)
+
+func TestCommand_Process(t *testing.T) {
+	runes := func(s string) []*tcell.EventKey {
+		events := make([]*tcell.EventKey, 0)
+		for _, r := range s {
+			events = append(events, tcell.NewEventKey(tcell.KeyRune, r, tcell.ModNone))
+		}
+		return events
+	}
+	key := func(k tcell.Key) []*tcell.EventKey {
+		return []*tcell.EventKey{tcell.NewEventKey(k, 0, tcell.ModNone)}
+	}
+
+	testCases := []struct {
+		name   string
+		input  string
+		events [][]*tcell.EventKey
+		output string
+		cursor int
+	}{
+		{
+			name:   "multi-byte runes are inserted at the cursor",
+			input:  "cafe!",
+			events: [][]*tcell.EventKey{key(tcell.KeyLeft), key(tcell.KeyBackspace2), runes("é"), key(tcell.KeyHome), runes("日本 ")},
+			output: "日本 café!",
+			cursor: 3,
+		},
+		{
+			name:   "Ctrl-W deletes the word before the cursor",
+			input:  "feature/login fix  ",
+			events: [][]*tcell.EventKey{key(tcell.KeyCtrlW)},
+			output: "feature/login ",
+			cursor: 14,
+		},
+		{
+			name:   "Ctrl-U deletes up to the cursor",
+			input:  "feature/login",
+			events: [][]*tcell.EventKey{key(tcell.KeyLeft), key(tcell.KeyLeft), key(tcell.KeyCtrlU)},
+			output: "in",
+			cursor: 0,
+		},
+		{
+			name:   "Ctrl-K deletes from the cursor",
+			input:  "feature/login",
+			events: [][]*tcell.EventKey{key(tcell.KeyCtrlA), key(tcell.KeyRight), key(tcell.KeyCtrlK)},
+			output: "f",
+			cursor: 1,
+		},
+		{
+			name:   "Delete removes the rune under the cursor",
+			input:  "ñandú",
+			events: [][]*tcell.EventKey{key(tcell.KeyHome), key(tcell.KeyDelete), key(tcell.KeyEnd), key(tcell.KeyCtrlD)},
+			output: "andú",
+			cursor: 4,
+		},
+		{
+			name:   "cursor stays within bounds",
+			input:  "ab",
+			events: [][]*tcell.EventKey{key(tcell.KeyRight), key(tcell.KeyHome), key(tcell.KeyLeft), key(tcell.KeyBackspace)},
+			output: "ab",
+			cursor: 0,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			c := NewCommand(20, 1, "/")
+			c.Focus()
+			c.SetInput(testCase.input)
+			for _, events := range testCase.events {
+				for _, ev := range events {
+					c.Process(ev)
+				}
+			}
+			if c.Input() != testCase.output {
+				t.Fatalf("expected input %q but got %q", testCase.output, c.Input())
+			}
+			if c.cursor != testCase.cursor {
+				t.Fatalf("expected cursor at %d but got %d", testCase.cursor, c.cursor)
+			}
+		})
+	}
+}
@@ -0,0 +1,206 @@
+package tui
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/gdamore/tcell"
+	"github.com/nbedos/cistern/utils"
+)
+
+// Scrollable view of the log of a job. The view can follow the end of the log as it grows
+// and move to the lines matching a search.
+type LogView struct {
+	width   int
+	height  int
+	lines   []string
+	yOffset int
+	// True if the view stays at the end of the log when the log grows
+	follow bool
+	// Pattern searched in the log, occurrences are emphasized
+	search Pattern
+	// Index of the line of the last match, -1 if there is none
+	match    int
+	emphasis StyleTransform
+}
+
+func NewLogView(width, height int, emphasis StyleTransform) (LogView, error) {
+	if width < 0 || height < 0 {
+		return LogView{}, errors.New("width and height must be >= 0")
+	}
+
+	return LogView{
+		width:    width,
+		height:   height,
+		match:    -1,
+		emphasis: emphasis,
+	}, nil
+}
+
+// Replace the log shown by the view. The first line of the view stays the same unless the
+// view follows the end of the log.
+func (v *LogView) SetLog(log string) {
+	log = strings.TrimSuffix(log, "\n")
+	log = strings.Replace(log, "\t", "    ", -1)
+	v.lines = strings.Split(log, "\n")
+	if v.match >= len(v.lines) {
+		v.match = -1
+	}
+	if v.follow {
+		v.verticalScroll(len(v.lines))
+	} else {
+		v.verticalScroll(0)
+	}
+}
+
+// Remove the log, the search and the position of the view
+func (v *LogView) Clear() {
+	v.lines = nil
+	v.yOffset = 0
+	v.follow = false
+	v.search = Pattern{}
+	v.match = -1
+}
+
+// Start or stop following the end of the log
+func (v *LogView) Follow(follow bool) {
+	v.follow = follow
+	if follow {
+		v.verticalScroll(len(v.lines))
+	}
+}
+
+func (v LogView) Following() bool {
+	return v.follow
+}
+
+// Return the index of the first line shown and the number of lines of the log
+func (v LogView) Position() (int, int) {
+	return v.yOffset, len(v.lines)
+}
+
+// Move to the next line matching 'p', or to the previous one if 'ascending' is false,
+// wrapping around the log. Return false if no line matches. Finding a match stops
+// following the end of the log.
+func (v *LogView) Search(p Pattern, ascending bool) bool {
+	v.search = p
+	if p.Empty() || len(v.lines) == 0 {
+		return false
+	}
+
+	step := 1
+	if !ascending {
+		step = -1
+	}
+	current := v.match
+	if current < v.yOffset || current >= v.yOffset+v.height {
+		// Start from the top of the screen if the last match is not visible anymore
+		current = v.yOffset - step
+	}
+	for n, i := 0, current; n < len(v.lines); n++ {
+		i = utils.Modulo(i+step, len(v.lines))
+		if p.Matches(v.lines[i]) {
+			v.match = i
+			v.follow = false
+			v.verticalScroll(i - v.yOffset)
+			return true
+		}
+	}
+
+	return false
+}
+
+func (v *LogView) verticalScroll(amount int) {
+	switch offset := v.yOffset + amount; {
+	case offset < 0:
+		v.yOffset = 0
+	case offset > len(v.lines)-v.height:
+		v.yOffset = utils.MaxInt(0, len(v.lines)-v.height)
+	default:
+		v.yOffset = offset
+	}
+}
+
+func (v *LogView) Resize(width int, height int) {
+	v.width = utils.MaxInt(0, width)
+	v.height = utils.MaxInt(0, height)
+	if v.follow {
+		v.verticalScroll(len(v.lines))
+	} else {
+		v.verticalScroll(0)
+	}
+}
+
+// Return the line at index i with the occurrences of the pattern searched emphasized
+func (v LogView) line(i int) StyledString {
+	line := v.lines[i]
+	s := StyledString{}
+	start := 0
+	for _, match := range v.search.FindAll(line) {
+		s.Append(line[start:match[0]])
+		s.Append(line[match[0]:match[1]], v.emphasis)
+		start = match[1]
+	}
+	s.Append(line[start:])
+
+	return s
+}
+
+func (v LogView) Draw(w Window) {
+	for i := v.yOffset; i < len(v.lines) && i < v.yOffset+v.height; i++ {
+		w.Draw(0, i-v.yOffset, v.line(i))
+	}
+}
+
+func (v *LogView) Process(ev *tcell.EventKey) {
+	switch ev.Key() {
+	case tcell.KeyDown, tcell.KeyCtrlN:
+		v.Do(ActionCursorDown)
+	case tcell.KeyUp, tcell.KeyCtrlP:
+		v.Do(ActionCursorUp)
+	case tcell.KeyCtrlD:
+		v.Do(ActionHalfPageDown)
+	case tcell.KeyPgDn, tcell.KeyCtrlF:
+		v.Do(ActionPageDown)
+	case tcell.KeyCtrlU:
+		v.Do(ActionHalfPageUp)
+	case tcell.KeyPgUp, tcell.KeyCtrlB:
+		v.Do(ActionPageUp)
+	case tcell.KeyRune:
+		switch ev.Rune() {
+		case ' ':
+			v.Do(ActionPageDown)
+		case 'k':
+			v.Do(ActionCursorUp)
+		case 'j':
+			v.Do(ActionCursorDown)
+		}
+	}
+}
+
+// Scroll the log. Scrolling up stops following the end of the log. Actions other than
+// vertical scrolling are ignored.
+func (v *LogView) Do(action Action) {
+	switch action {
+	case ActionCursorDown:
+		v.verticalScroll(+1)
+	case ActionCursorUp:
+		v.follow = false
+		v.verticalScroll(-1)
+	case ActionHalfPageDown:
+		v.verticalScroll(v.height / 2)
+	case ActionPageDown:
+		v.verticalScroll(v.height)
+	case ActionHalfPageUp:
+		v.follow = false
+		v.verticalScroll(-v.height / 2)
+	case ActionPageUp:
+		v.follow = false
+		v.verticalScroll(-v.height)
+	case ActionTop:
+		v.follow = false
+		v.verticalScroll(-len(v.lines))
+	case ActionBottom:
+		v.verticalScroll(len(v.lines))
+	}
+}
@@ -0,0 +1,121 @@
+package tui
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func logOfLength(n int) string {
+	lines := make([]string, 0, n)
+	for i := 0; i < n; i++ {
+		lines = append(lines, fmt.Sprintf("line %d", i))
+	}
+	return strings.Join(lines, "\n") + "\n"
+}
+
+func TestLogView_SetLog(t *testing.T) {
+	t.Run("position is kept when the log grows", func(t *testing.T) {
+		v, err := NewLogView(20, 10, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		v.SetLog(logOfLength(50))
+		v.Do(ActionPageDown)
+		v.SetLog(logOfLength(100))
+		if first, total := v.Position(); first != 10 || total != 100 {
+			t.Fatalf("expected position (10, 100) but got (%d, %d)", first, total)
+		}
+	})
+
+	t.Run("view follows the end of the log", func(t *testing.T) {
+		v, err := NewLogView(20, 10, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		v.SetLog(logOfLength(50))
+		v.Follow(true)
+		if first, _ := v.Position(); first != 40 {
+			t.Fatalf("expected first line 40 but got %d", first)
+		}
+		v.SetLog(logOfLength(100))
+		if first, _ := v.Position(); first != 90 {
+			t.Fatalf("expected first line 90 but got %d", first)
+		}
+	})
+
+	t.Run("scrolling up stops following the log", func(t *testing.T) {
+		v, err := NewLogView(20, 10, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		v.SetLog(logOfLength(50))
+		v.Follow(true)
+		v.Do(ActionCursorDown)
+		if !v.Following() {
+			t.Fatal("expected view to follow the log")
+		}
+		v.Do(ActionCursorUp)
+		if v.Following() {
+			t.Fatal("expected view to stop following the log")
+		}
+		v.SetLog(logOfLength(100))
+		if first, _ := v.Position(); first != 39 {
+			t.Fatalf("expected first line 39 but got %d", first)
+		}
+	})
+}
+
+func TestLogView_Search(t *testing.T) {
+	v, err := NewLogView(20, 10, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	v.SetLog(logOfLength(50))
+	v.Follow(true)
+
+	for _, step := range []struct {
+		ascending bool
+		first     int
+	}{
+		// Search starts from the top of the screen and wraps around the log
+		{true, 40},
+		{true, 2},
+		{true, 12},
+		{true, 20},
+		{false, 12},
+		{false, 2},
+		{false, 40},
+	} {
+		if !v.Search(mustPattern("2"), step.ascending) {
+			t.Fatal("expected a match")
+		}
+		if first, _ := v.Position(); first != step.first {
+			t.Fatalf("expected first line %d but got %d", step.first, first)
+		}
+		if v.Following() {
+			t.Fatal("expected view to stop following the log")
+		}
+	}
+
+	if v.Search(mustPattern("error"), true) {
+		t.Fatal("expected no match")
+	}
+}
+
+func TestLogView_line(t *testing.T) {
+	v, err := NewLogView(20, 10, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	v.SetLog("go test ./...\n\tok tui\n")
+	v.Search(mustPattern("t"), true)
+
+	line := v.line(1)
+	if s := line.String(); s != "    ok tui" {
+		t.Fatalf("expected %q but got %q", "    ok tui", s)
+	}
+	if n := len(line.components); n != 3 {
+		t.Fatalf("expected 3 components but got %d", n)
+	}
+}
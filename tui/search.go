@@ -0,0 +1,64 @@
+package tui
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Prefix of the patterns interpreted as regular expressions
+const RegexpPrefix = "re:"
+
+// Text searched by the user. Patterns starting with RegexpPrefix are regular expressions
+// using the syntax of the regexp package, other patterns are literal strings. The zero value
+// matches nothing.
+type Pattern struct {
+	text string
+	re   *regexp.Regexp
+}
+
+// Parse the pattern 's' as typed by the user
+func NewPattern(s string) (Pattern, error) {
+	var expr string
+	if strings.HasPrefix(s, RegexpPrefix) {
+		expr = strings.TrimPrefix(s, RegexpPrefix)
+	} else {
+		expr = regexp.QuoteMeta(s)
+	}
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		return Pattern{}, fmt.Errorf("invalid regular expression %q: %w", expr, err)
+	}
+
+	return Pattern{text: s, re: re}, nil
+}
+
+// Return the pattern as typed by the user
+func (p Pattern) String() string {
+	return p.text
+}
+
+// Return true if no text is searched
+func (p Pattern) Empty() bool {
+	return p.re == nil || p.re.String() == ""
+}
+
+// Return true if 's' contains a non-empty match of the pattern
+func (p Pattern) Matches(s string) bool {
+	return len(p.FindAll(s)) > 0
+}
+
+// Return the start and end indexes of each match of the pattern in 's', empty matches excluded
+func (p Pattern) FindAll(s string) [][]int {
+	if p.Empty() {
+		return nil
+	}
+	matches := make([][]int, 0)
+	for _, match := range p.re.FindAllStringIndex(s, -1) {
+		if match[1] > match[0] {
+			matches = append(matches, match)
+		}
+	}
+
+	return matches
+}
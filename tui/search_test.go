@@ -0,0 +1,50 @@
+package tui
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func mustPattern(s string) Pattern {
+	p, err := NewPattern(s)
+	if err != nil {
+		panic(err)
+	}
+	return p
+}
+
+func TestNewPattern(t *testing.T) {
+	testCases := []struct {
+		pattern string
+		value   string
+		matches [][]int
+	}{
+		{pattern: "", value: "test", matches: nil},
+		{pattern: "test-(unit|integration)", value: "test-unit", matches: [][]int{}},
+		{pattern: "test-(unit|integration)", value: "test-(unit|integration)", matches: [][]int{{0, 23}}},
+		{pattern: "re:test-(unit|integration)", value: "test-unit, test-integration", matches: [][]int{{0, 9}, {11, 27}}},
+		{pattern: "re:x*", value: "abc", matches: [][]int{}},
+		{pattern: "re:", value: "abc", matches: nil},
+	}
+
+	for _, testCase := range testCases {
+		p, err := NewPattern(testCase.pattern)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if p.String() != testCase.pattern {
+			t.Errorf("expected %q but got %q", testCase.pattern, p.String())
+		}
+		if diff := cmp.Diff(testCase.matches, p.FindAll(testCase.value)); diff != "" {
+			t.Errorf("pattern %q: %s", testCase.pattern, diff)
+		}
+		if expected := len(testCase.matches) > 0; p.Matches(testCase.value) != expected {
+			t.Errorf("pattern %q: expected Matches() to return %v", testCase.pattern, expected)
+		}
+	}
+
+	if _, err := NewPattern("re:test-(unit"); err == nil {
+		t.Fatal("expected an error")
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"errors"
 	"fmt"
 	"sort"
+	"strings"
 
 	"github.com/gdamore/tcell"
 	"github.com/google/go-cmp/cmp"
@@ -22,6 +23,23 @@ type TableNode interface {
 	Compare(other TableNode, id ColumnID, v interface{}) int
 }
 
+// Optional interface of TableNode for nodes whose ID changes over time, for example when a
+// job is restarted. The traversable state of these nodes is saved by StableID instead of
+// NodeID so that it is preserved across calls to Replace.
+type StableNode interface {
+	// Identifier of this node among its siblings that does not change over time
+	StableID() interface{}
+}
+
+// Optional interface of TableNode. The traversable state of an anchor node and of its
+// descendants is saved relative to the anchor instead of relative to the top of the table,
+// so that it is preserved when the anchor moves under another parent. It can also be read
+// with FoldState and restored with RestoreFoldState.
+type AnchorNode interface {
+	// Identifier of this node among all the nodes of the table
+	Anchor() interface{}
+}
+
 func (n *innerTableNode) setPrefix(parent string, isLastChild bool) {
 	if parent == "" {
 		switch {
@@ -93,15 +111,23 @@ func (i nullInt) Diff(other nullInt) string {
 	return cmp.Diff(i, other, cmp.AllowUnexported(nullInt{}))
 }
 
-const maxTreeDepth = 10
-
+// Path of a node from the top level of the table. Trees may have any depth so paths are
+// compared and used as map keys through 'key', a representation of all the IDs of the path.
 type nodePath struct {
-	ids [maxTreeDepth]nodeID
-	len int
+	ids []nodeID
+	key string
+}
+
+func (p nodePath) len() int {
+	return len(p.ids)
 }
 
+// Return true if 'p' is 'other' or one of its ancestors
 func (p nodePath) isParentOf(other nodePath) bool {
-	for i := 0; i < p.len; i++ {
+	if p.len() > other.len() {
+		return false
+	}
+	for i := range p.ids {
 		if p.ids[i] != other.ids[i] {
 			return false
 		}
@@ -109,24 +135,36 @@ func (p nodePath) isParentOf(other nodePath) bool {
 	return true
 }
 
+func (p nodePath) equals(other nodePath) bool {
+	return p.key == other.key
+}
+
 func nodePathFromIDs(ids ...nodeID) nodePath {
 	return nodePath{}.append(ids...)
 }
 
 func (p nodePath) append(ids ...nodeID) nodePath {
+	// Never share the array of 'p' since paths are appended to from the same parent
+	path := nodePath{
+		ids: make([]nodeID, 0, len(p.ids)+len(ids)),
+		key: p.key,
+	}
+	path.ids = append(path.ids, p.ids...)
 	for _, id := range ids {
-		if p.len >= len(p.ids) {
-			panic(fmt.Sprintf("path length cannot exceed %d", len(p.ids)))
-		}
-
-		p.ids[p.len] = id
-		p.len++
+		path.ids = append(path.ids, id)
+		// Include the type since IDs of different types never compare equal
+		path.key += fmt.Sprintf("/%T(%#v)", id, id)
 	}
-	return p
+	return path
 }
 
 type innerTableNode struct {
-	path        nodePath
+	path nodePath
+	// Path of the closest anchor node (see AnchorNode), empty if the node has no anchor
+	anchor nodePath
+	// Path of the node from its anchor, or from the top of the table if it has no anchor,
+	// built from stable IDs
+	foldPath    nodePath
 	prefix      string
 	traversable bool
 	values      map[ColumnID]StyledString
@@ -152,13 +190,22 @@ func (n innerTableNode) depthFirstTraversal(traverseAll bool) []*innerTableNode
 }
 
 func (t HierarchicalTable) toInnerTableNode(n TableNode, parent innerTableNode, depth int) innerTableNode {
-	path := parent.path.append(n.NodeID())
 	s := innerTableNode{
-		path:   path,
-		values: n.Values(t.conf.NodeStyle),
+		path:     parent.path.append(n.NodeID()),
+		anchor:   parent.anchor,
+		foldPath: parent.foldPath,
+		values:   n.Values(t.conf.NodeStyle),
+	}
+	if anchor, ok := n.(AnchorNode); ok {
+		s.anchor = nodePathFromIDs(anchor.Anchor())
+		s.foldPath = nodePath{}
+	} else if stable, ok := n.(StableNode); ok {
+		s.foldPath = s.foldPath.append(stable.StableID())
+	} else {
+		s.foldPath = s.foldPath.append(n.NodeID())
 	}
 
-	if isTraversable, exists := t.traversable[path]; exists {
+	if isTraversable, exists := t.traversable[s.anchor.key].nodes[s.foldPath.key]; exists {
 		s.traversable = isTraversable
 	} else if depth > 0 {
 		s.traversable = true
@@ -203,10 +250,10 @@ func (t *HierarchicalTable) lookup(path nodePath) *innerTableNode {
 	}
 
 pathLoop:
-	for i := 0; i < path.len; i++ {
+	for i := 0; i < path.len(); i++ {
 		for _, c := range children {
 			if c.path.ids[i] == path.ids[i] {
-				if c.path == path {
+				if c.path.equals(path) {
 					return c
 				}
 				children = c.children
@@ -235,13 +282,29 @@ type TableConfiguration struct {
 	DefaultDepth           int
 	NodeStyle              interface{}
 	Order
+	// Columns shown on the second line of each row when details are enabled. The header of
+	// each column is used as a label for the value.
+	Details ColumnConfiguration
+	// String shown in place of the end of values longer than the width of their column
+	Ellipsis string
+	// Style of the occurrences of the pattern searched
+	Match StyleTransform
+}
+
+// Traversable state of the nodes sharing the same anchor
+type anchoredState struct {
+	// Identifier of the anchor, nil for nodes without anchor
+	anchor nodeID
+	// Traversable state of each node by key of its path from the anchor
+	nodes map[string]bool
 }
 
 type HierarchicalTable struct {
 	outerNodes []TableNode
 	// List of the top-level innerNodes
-	innerNodes  []innerTableNode
-	traversable map[nodePath]bool
+	innerNodes []innerTableNode
+	// Traversable state of each node by key of the path of its anchor
+	traversable map[string]anchoredState
 	// Depth first traversal of all the top-level innerNodes. Needs updating if `innerNodes` or `traversable` changes
 	rows []*innerTableNode
 	// Index in `rows` of the first node of the current page
@@ -255,6 +318,15 @@ type HierarchicalTable struct {
 	order        Order
 	scrolled     bool
 	columnOffset int
+	// Number of cells of the first column shown that are scrolled past. Only non-zero if
+	// the column is wider than the table.
+	textOffset int
+	// Show a second line with additional details below each row
+	details bool
+	// Pattern searched in the table, occurrences are emphasized
+	search Pattern
+	// Show only the rows matching the pattern searched and their ancestors
+	filter bool
 }
 
 func NewHierarchicalTable(conf TableConfiguration, nodes []TableNode, width int, height int) (HierarchicalTable, error) {
@@ -267,7 +339,7 @@ func NewHierarchicalTable(conf TableConfiguration, nodes []TableNode, width int,
 		width:       width,
 		conf:        conf,
 		columnWidth: make(map[ColumnID]int),
-		traversable: make(map[nodePath]bool),
+		traversable: make(map[string]anchoredState),
 	}
 
 	table.Replace(nodes)
@@ -287,9 +359,23 @@ func (t HierarchicalTable) depthFirstTraversal(traverseAll bool) []*innerTableNo
 	return explored
 }
 
+// Number of lines used to display a row
+func (t HierarchicalTable) rowHeight() int {
+	if t.details && len(t.conf.Details) > 0 {
+		return 2
+	}
+	return 1
+}
+
 // Number of rows visible on screen
 func (t HierarchicalTable) pageSize() int {
-	return utils.MaxInt(0, t.height-1)
+	return utils.MaxInt(0, (t.height-1)/t.rowHeight())
+}
+
+// Show or hide the detail line of each row
+func (t *HierarchicalTable) toggleDetails() {
+	t.details = !t.details
+	t.Resize(t.width, t.height)
 }
 
 func (t *HierarchicalTable) computeTraversal() {
@@ -314,11 +400,15 @@ func (t *HierarchicalTable) computeTraversal() {
 	t.pageIndex = nullInt{}
 	t.cursorIndex = nullInt{}
 
-	t.rows = t.depthFirstTraversal(false)
+	if t.filter && !t.search.Empty() {
+		t.rows = t.filteredTraversal()
+	} else {
+		t.rows = t.depthFirstTraversal(false)
+	}
 
 	// Adjust value of pageIndex and cursorIndex
 	for i, row := range t.rows {
-		if row.path.isParentOf(pageNodePath) || row.path == pageNodePath {
+		if row.path.isParentOf(pageNodePath) {
 			t.pageIndex = nullInt{
 				Valid: true,
 				Int:   i,
@@ -329,7 +419,7 @@ func (t *HierarchicalTable) computeTraversal() {
 		// scrolled. This prevents the cursor from moving around when increasingly more rows are
 		// loaded into the table but the user has not interacted with the table yet.
 		if t.scrolled || (cursorIndex.Valid && cursorIndex.Int > 0) {
-			if row.path.isParentOf(cursorNodePath) || row.path == cursorNodePath {
+			if row.path.isParentOf(cursorNodePath) {
 				t.cursorIndex = nullInt{
 					Valid: true,
 					Int:   i,
@@ -363,38 +453,79 @@ func (t *HierarchicalTable) computeTraversal() {
 		t.pageIndex.Int = utils.Bounded(t.pageIndex.Int, lowerBound, t.cursorIndex.Int)
 	}
 
+	t.computeColumnWidths()
+}
+
+// Return the depth first traversal of the rows matching the pattern searched and of their
+// ancestors, including rows hidden in closed folds
+func (t HierarchicalTable) filteredTraversal() []*innerTableNode {
+	rows := make([]*innerTableNode, 0)
+	for _, n := range t.innerNodes {
+		if filtered, ok := t.filterNode(n); ok {
+			filtered.setPrefix("", false)
+			rows = append(rows, filtered.depthFirstTraversal(false)...)
+		}
+	}
+
+	return rows
+}
+
+// Return a copy of 'n' where all folds are open and descendants without a match in their
+// own subtree are removed, and false if neither 'n' nor its descendants match the pattern
+// searched. The traversable state of 'n' and its descendants is left untouched.
+func (t HierarchicalTable) filterNode(n innerTableNode) (innerTableNode, bool) {
+	children := make([]*innerTableNode, 0, len(n.children))
+	for _, c := range n.children {
+		if child, ok := t.filterNode(*c); ok {
+			children = append(children, &child)
+		}
+	}
+	n.children = children
+	n.traversable = true
+
+	return n, len(children) > 0 || t.rowMatches(&n)
+}
+
+// Number of cells a column may shrink by without its width being updated. This prevents columns
+// from changing width every time the user scrolls by one row.
+const columnWidthHysteresis = 4
+
+// Compute the width of each column based on the headers and on the rows visible on screen. A
+// column widens as soon as a visible value requires it but only narrows once the values visible
+// on screen are significantly shorter than the current width.
+func (t *HierarchicalTable) computeColumnWidths() {
+	widths := make(map[ColumnID]int)
 	for id, value := range t.headers() {
-		t.columnWidth[id] = utils.MaxInt(t.columnWidth[id], value.Length())
+		widths[id] = value.Length()
 	}
 
-	for _, row := range t.rows {
-		for _, id := range t.conf.Columns.IDs() {
-			w := row.values[id].Length()
-			if t.conf.Columns[id].TreePrefix {
-				w += runewidth.StringWidth(row.prefix)
+	if t.pageIndex.Valid {
+		for _, row := range t.rows[t.pageIndex.Int:utils.MinInt(t.pageIndex.Int+t.pageSize(), len(t.rows))] {
+			for _, id := range t.conf.Columns.IDs() {
+				w := row.values[id].Length()
+				if t.conf.Columns[id].TreePrefix {
+					w += runewidth.StringWidth(row.prefix)
+				}
+				widths[id] = utils.MaxInt(widths[id], w)
 			}
-			t.columnWidth[id] = utils.MaxInt(t.columnWidth[id], w)
+		}
+	}
+
+	for id, w := range widths {
+		if w > t.columnWidth[id] || w < t.columnWidth[id]-columnWidthHysteresis {
+			t.columnWidth[id] = w
 		}
 	}
 }
 
+// Sort nodes according to the order of the table. Nodes that compare equal keep their original
+// order whatever the direction of the sort so that, for example, jobs not started yet remain in
+// the order defined by the CI configuration.
 func (t *HierarchicalTable) sortSlice(nodes []TableNode) {
 	if t.order.Valid {
-		indexById := make(map[interface{}]int)
-		for i, child := range nodes {
-			indexById[child.NodeID()] = i
-		}
-		sort.Slice(nodes, func(i, j int) bool {
+		sort.SliceStable(nodes, func(i, j int) bool {
 			c := nodes[i].Compare(nodes[j], t.order.ID, t.conf.NodeStyle)
-			if c == 0 {
-				if t.order.Ascending {
-					return indexById[nodes[i].NodeID()] < indexById[nodes[j].NodeID()]
-				} else {
-					return indexById[nodes[i].NodeID()] > indexById[nodes[j].NodeID()]
-				}
-			} else {
-				return (c < 0 && t.order.Ascending) || (c > 0 && !t.order.Ascending)
-			}
+			return (c < 0 && t.order.Ascending) || (c > 0 && !t.order.Ascending)
 		})
 	}
 }
@@ -404,11 +535,34 @@ func (t *HierarchicalTable) Replace(nodes []TableNode) {
 	t.outerNodes = make([]TableNode, len(nodes))
 	copy(t.outerNodes, nodes)
 
-	// Save traversable state
+	t.saveTraversable()
+	t.build()
+}
+
+// Compute the values of the rows again, for example because they depend on the current time
+func (t *HierarchicalTable) Refresh() {
+	t.Replace(t.outerNodes)
+}
+
+// Save the traversable state of all the nodes of the table
+func (t *HierarchicalTable) saveTraversable() {
 	for _, node := range t.depthFirstTraversal(true) {
-		t.traversable[node.path] = node.traversable
+		state, exists := t.traversable[node.anchor.key]
+		if !exists {
+			state = anchoredState{nodes: make(map[string]bool)}
+			if node.anchor.len() > 0 {
+				state.anchor = node.anchor.ids[0]
+			}
+			t.traversable[node.anchor.key] = state
+		}
+		state.nodes[node.foldPath.key] = node.traversable
 	}
+}
 
+// Build the hierarchy of inner nodes from the outer nodes and the saved traversable state
+func (t *HierarchicalTable) build() {
+	nodes := make([]TableNode, len(t.outerNodes))
+	copy(nodes, t.outerNodes)
 	t.sortSlice(nodes)
 
 	// Copy node hierarchy and compute the path of each node along the way
@@ -421,6 +575,41 @@ func (t *HierarchicalTable) Replace(nodes []TableNode) {
 	t.computeTraversal()
 }
 
+// Return the traversable state of the nodes of each anchor (see AnchorNode) by anchor. The
+// state of each node is indexed by an opaque key derived from its path from the anchor.
+func (t *HierarchicalTable) FoldState() map[interface{}]map[string]bool {
+	t.saveTraversable()
+	states := make(map[interface{}]map[string]bool)
+	for _, state := range t.traversable {
+		if state.anchor == nil {
+			continue
+		}
+		nodes := make(map[string]bool, len(state.nodes))
+		for key, traversable := range state.nodes {
+			nodes[key] = traversable
+		}
+		states[state.anchor] = nodes
+	}
+
+	return states
+}
+
+// Restore the traversable state of the nodes of 'anchor' returned by FoldState, for example
+// by a previous execution of the program
+func (t *HierarchicalTable) RestoreFoldState(anchor interface{}, nodes map[string]bool) {
+	t.saveTraversable()
+	path := nodePathFromIDs(anchor)
+	state, exists := t.traversable[path.key]
+	if !exists {
+		state = anchoredState{anchor: anchor, nodes: make(map[string]bool)}
+		t.traversable[path.key] = state
+	}
+	for key, traversable := range nodes {
+		state.nodes[key] = traversable
+	}
+	t.build()
+}
+
 func (t *HierarchicalTable) setTraversable(n *innerTableNode, traversable bool, recursive bool) {
 	if n == nil {
 		return
@@ -451,8 +640,49 @@ func (t *HierarchicalTable) setTraversableAtCursor(traversable bool, recursive b
 	}
 }
 
+// Open or close all the folds of the table, whatever the position of the cursor
+func (t *HierarchicalTable) setEveryTraversable(traversable bool) {
+	for i := range t.innerNodes {
+		t.innerNodes[i].Map(func(node *innerTableNode) {
+			node.traversable = traversable
+		})
+	}
+
+	t.computeTraversal()
+}
+
+// Width of the column 'id' on screen
+func (t HierarchicalTable) displayWidth(id ColumnID) int {
+	return utils.MinInt(t.columnWidth[id], t.conf.Columns[id].MaxWidth)
+}
+
+// Number of cells by which the table scrolls horizontally within a column wider than the table
+const horizontalScrollStep = 8
+
+// Scroll the table by 'amount' columns to the right, or to the left if 'amount' is negative.
+// A column too wide to fit on screen, such as a long commit message, is scrolled through
+// by steps of a few cells before moving to the next column so that it can be read in full.
 func (t *HierarchicalTable) horizontalScroll(amount int) {
-	t.columnOffset = utils.Bounded(t.columnOffset+amount, 0, len(t.conf.Columns.IDs())-1)
+	ids := t.conf.Columns.IDs()
+	for ; amount > 0; amount-- {
+		if t.columnOffset >= 0 && t.columnOffset < len(ids) {
+			if overflow := t.displayWidth(ids[t.columnOffset]) - t.textOffset - t.width; overflow > 0 {
+				t.textOffset += utils.MinInt(overflow, horizontalScrollStep)
+				continue
+			}
+		}
+		if t.columnOffset < len(ids)-1 {
+			t.columnOffset++
+			t.textOffset = 0
+		}
+	}
+	for ; amount < 0; amount++ {
+		if t.textOffset > 0 {
+			t.textOffset = utils.MaxInt(0, t.textOffset-horizontalScrollStep)
+		} else if t.columnOffset > 0 {
+			t.columnOffset--
+		}
+	}
 }
 
 func (t *HierarchicalTable) verticalScroll(amount int) {
@@ -475,9 +705,147 @@ func (t *HierarchicalTable) verticalScroll(amount int) {
 		t.pageIndex.Int = utils.Bounded(t.pageIndex.Int+scrollAmount, 0, len(t.rows)-1)
 		t.cursorIndex.Int = t.pageIndex.Int + t.pageSize() - 1
 	}
+
+	t.computeColumnWidths()
+}
+
+// Move the page and the cursor by 'amount' rows so that the cursor stays at the same place on
+// screen, unless the page reaches the beginning or the end of the table in which case only
+// the cursor keeps moving
+func (t *HierarchicalTable) pageScroll(amount int) {
+	if !t.cursorIndex.Valid || !t.pageIndex.Valid {
+		return
+	}
+	lastPage := utils.MaxInt(0, len(t.rows)-t.pageSize())
+	t.pageIndex.Int = utils.Bounded(t.pageIndex.Int+amount, 0, lastPage)
+	t.verticalScroll(amount)
+}
+
+// Move the cursor to the row at index 'i', or to the last row if there are fewer rows
+func (t *HierarchicalTable) ScrollToRow(i int) {
+	if !t.cursorIndex.Valid {
+		return
+	}
+	t.verticalScroll(utils.Bounded(i, 0, len(t.rows)-1) - t.cursorIndex.Int)
+}
+
+// Move the cursor to the row of the node whose NodeID() is 'id', opening the folds of its
+// ancestors if needed. Return false if no row of the table shows this node.
+func (t *HierarchicalTable) ScrollToNode(id interface{}) bool {
+	for _, n := range t.depthFirstTraversal(true) {
+		if n.path.ids[n.path.len()-1] == id {
+			return t.scrollToPath(n.path)
+		}
+	}
+
+	return false
+}
+
+// Move the cursor to the row of the node at 'path', a path as returned by ActiveNodePath,
+// opening the folds of its ancestors if needed. Return false if no row of the table shows
+// this node.
+func (t *HierarchicalTable) ScrollToPath(path []interface{}) bool {
+	if len(path) == 0 {
+		return false
+	}
+	ids := make([]nodeID, 0, len(path))
+	for _, id := range path {
+		ids = append(ids, id)
+	}
+
+	return t.scrollToPath(nodePathFromIDs(ids...))
+}
+
+func (t *HierarchicalTable) scrollToPath(target nodePath) bool {
+	if t.lookup(target) == nil {
+		return false
+	}
+	for i := 1; i < target.len(); i++ {
+		if ancestor := t.lookup(nodePathFromIDs(target.ids[:i]...)); ancestor != nil {
+			ancestor.traversable = true
+		}
+	}
+	t.computeTraversal()
+	for i, row := range t.rows {
+		if row.path.equals(target) && t.cursorIndex.Valid {
+			t.verticalScroll(i - t.cursorIndex.Int)
+			return true
+		}
+	}
+
+	return false
+}
+
+// Move the cursor to the parent of the row at the cursor
+func (t *HierarchicalTable) scrollToParent() {
+	if !t.cursorIndex.Valid {
+		return
+	}
+
+	depth := t.rows[t.cursorIndex.Int].path.len()
+	// Rows are sorted in depth-first order so the parent is the closest preceding row that is
+	// one level up
+	for i := t.cursorIndex.Int - 1; i >= 0; i-- {
+		if t.rows[i].path.len() == depth-1 {
+			t.verticalScroll(i - t.cursorIndex.Int)
+			return
+		}
+	}
+}
+
+// Move the cursor to the next (or previous) row sharing the parent of the row at the cursor
+func (t *HierarchicalTable) scrollToSibling(next bool) {
+	if !t.cursorIndex.Valid {
+		return
+	}
+
+	step := 1
+	if !next {
+		step = -1
+	}
+
+	depth := t.rows[t.cursorIndex.Int].path.len()
+	for i := t.cursorIndex.Int + step; i >= 0 && i < len(t.rows); i += step {
+		switch d := t.rows[i].path.len(); {
+		case d < depth:
+			// Reaching a row higher in the tree means there is no sibling in this direction
+			return
+		case d == depth:
+			t.verticalScroll(i - t.cursorIndex.Int)
+			return
+		}
+	}
+}
+
+// Move the cursor to the next (or previous) top-level row after (or before) the top-level row
+// enclosing the row at the cursor
+func (t *HierarchicalTable) scrollToTopLevel(next bool) {
+	if !t.cursorIndex.Valid {
+		return
+	}
+
+	// Rows are sorted in depth-first order so the enclosing top-level row is the closest
+	// preceding row of depth 1
+	from := t.cursorIndex.Int
+	for from > 0 && t.rows[from].path.len() > 1 {
+		from--
+	}
+
+	step := 1
+	if !next {
+		step = -1
+	}
+	for i := from + step; i >= 0 && i < len(t.rows); i += step {
+		if t.rows[i].path.len() == 1 {
+			t.verticalScroll(i - t.cursorIndex.Int)
+			return
+		}
+	}
 }
 
-func (t *HierarchicalTable) ScrollToNextMatch(s string, ascending bool) bool {
+// Move the cursor to the next row with a value matching 'p', or to the previous one if
+// 'ascending' is false, wrapping around the table. Return false if no other row matches.
+func (t *HierarchicalTable) ScrollToNextMatch(p Pattern, ascending bool) bool {
 	if !t.cursorIndex.Valid {
 		return false
 	}
@@ -493,7 +861,7 @@ func (t *HierarchicalTable) ScrollToNextMatch(s string, ascending bool) bool {
 	}
 	for i := start; i != t.cursorIndex.Int; i = next(i) {
 		for id := range t.conf.Columns {
-			if t.rows[i].values[id].Contains(s) {
+			if p.Matches(t.rows[i].values[id].String()) {
 				t.verticalScroll(i - t.cursorIndex.Int)
 				return true
 			}
@@ -503,6 +871,72 @@ func (t *HierarchicalTable) ScrollToNextMatch(s string, ascending bool) bool {
 	return false
 }
 
+// Emphasize the occurrences of the pattern searched in the values of the table from now on.
+// The zero value of Pattern removes the emphasis.
+func (t *HierarchicalTable) SetSearch(p Pattern) {
+	t.search = p
+	if t.filter {
+		t.computeTraversal()
+	}
+}
+
+// Show only the rows matching the pattern searched and their ancestors, whether they are in
+// closed folds or not, or show all rows again if 'filter' is false. All rows are shown while
+// the pattern searched is empty.
+func (t *HierarchicalTable) SetFilter(filter bool) {
+	t.filter = filter
+	t.computeTraversal()
+}
+
+// Return true if the table only shows the rows matching the pattern searched
+func (t HierarchicalTable) Filtered() bool {
+	return t.filter
+}
+
+// Return true if a value of the row shown in the table matches the pattern searched
+func (t HierarchicalTable) rowMatches(row *innerTableNode) bool {
+	for id := range t.conf.Columns {
+		if t.search.Matches(row.values[id].String()) {
+			return true
+		}
+	}
+	return false
+}
+
+// Return the position of the cursor among the rows matching the pattern searched, starting
+// at 1, or 0 if the row at the cursor does not match, and the number of matching rows. Rows
+// hidden in closed folds are not counted.
+func (t HierarchicalTable) MatchPosition() (int, int) {
+	index, total := 0, 0
+	if t.search.Empty() {
+		return index, total
+	}
+	for i, row := range t.rows {
+		if t.rowMatches(row) {
+			total++
+			if t.cursorIndex.Valid && t.cursorIndex.Int == i {
+				index = total
+			}
+		}
+	}
+
+	return index, total
+}
+
+// Return a copy of 'values' where the occurrences of the pattern searched are emphasized
+func (t HierarchicalTable) highlight(values map[ColumnID]StyledString) map[ColumnID]StyledString {
+	if t.search.Empty() {
+		return values
+	}
+	highlighted := make(map[ColumnID]StyledString, len(values))
+	for id, v := range values {
+		v.ApplyRanges(t.search.FindAll(v.String()), t.conf.Match)
+		highlighted[id] = v
+	}
+
+	return highlighted
+}
+
 func (t HierarchicalTable) headers() map[ColumnID]StyledString {
 	values := make(map[ColumnID]StyledString)
 	for id, column := range t.conf.Columns {
@@ -532,8 +966,7 @@ func (t HierarchicalTable) styledString(values map[ColumnID]StyledString, prefix
 			prefixedValue.AppendString(v)
 			v = prefixedValue
 		}
-		w := utils.MinInt(t.columnWidth[id], t.conf.Columns[id].MaxWidth)
-		v.Fit(alignment, w)
+		v.FitWithEllipsis(alignment, t.displayWidth(id), t.conf.Ellipsis)
 		paddedColumns = append(paddedColumns, v)
 	}
 	if len(paddedColumns) > 0 {
@@ -544,6 +977,31 @@ func (t HierarchicalTable) styledString(values map[ColumnID]StyledString, prefix
 		}
 	}
 	line := Join(paddedColumns, NewStyledString(t.conf.Sep))
+	if t.textOffset > 0 {
+		line.TruncateLeft(utils.MaxInt(0, line.Length()-t.textOffset))
+	}
+	line.Fit(Left, t.width)
+
+	return line
+}
+
+// Return the detail line of a row: the label and value of each detail column
+func (t HierarchicalTable) detailString(values map[ColumnID]StyledString, prefix string) StyledString {
+	details := make([]StyledString, 0, len(t.conf.Details))
+	for _, id := range t.conf.Details.IDs() {
+		v := values[id]
+		if v.Length() == 0 {
+			continue
+		}
+		detail := NewStyledString(fmt.Sprintf("%s: ", t.conf.Details[id].Header))
+		detail.AppendString(v)
+		details = append(details, detail)
+	}
+
+	// Align the detail line with the content of the row by replacing the characters of the
+	// tree prefix with spaces
+	line := NewStyledString(strings.Repeat(" ", runewidth.StringWidth(prefix)+1))
+	line.AppendString(Join(details, NewStyledString(t.conf.Sep)))
 	line.Fit(Left, t.width)
 
 	return line
@@ -568,6 +1026,12 @@ func (t *HierarchicalTable) Resize(width int, height int) {
 		t.cursorIndex = nullInt{}
 		t.pageIndex = nullInt{}
 	}
+
+	t.computeColumnWidths()
+	if ids := t.conf.Columns.IDs(); t.columnOffset >= 0 && t.columnOffset < len(ids) {
+		overflow := t.displayWidth(ids[t.columnOffset]) - t.width
+		t.textOffset = utils.Bounded(t.textOffset, 0, utils.MaxInt(0, overflow))
+	}
 }
 
 func (t HierarchicalTable) Draw(w Window) {
@@ -579,15 +1043,75 @@ func (t HierarchicalTable) Draw(w Window) {
 
 	if t.pageIndex.Valid && t.cursorIndex.Valid {
 		for i, row := range t.rows[t.pageIndex.Int:utils.MinInt(t.pageIndex.Int+t.pageSize(), len(t.rows))] {
-			s := t.styledString(row.values, row.prefix, false)
-			if t.cursorIndex.Int == i+t.pageIndex.Int {
-				s.Apply(t.conf.Cursor)
+			lines := []StyledString{t.styledString(t.highlight(row.values), row.prefix, false)}
+			if t.rowHeight() > 1 {
+				lines = append(lines, t.detailString(row.values, row.prefix))
+			}
+			for j, s := range lines {
+				if t.cursorIndex.Int == i+t.pageIndex.Int {
+					s.Apply(t.conf.Cursor)
+				}
+				w.Draw(0, i*t.rowHeight()+j+1, s)
 			}
-			w.Draw(0, i+1, s)
 		}
 	}
 }
 
+// Return the column shown at x, relative to the left side of the table, and the position of x
+// in the column
+func (t HierarchicalTable) columnAt(x int) (ColumnID, int, bool) {
+	ids := t.conf.Columns.IDs()
+	if t.columnOffset >= 0 && t.columnOffset < len(ids) {
+		ids = ids[t.columnOffset:]
+	} else {
+		ids = nil
+	}
+
+	start := -t.textOffset
+	for _, id := range ids {
+		w := t.displayWidth(id)
+		if x >= start && x < start+w {
+			return id, x - start, true
+		}
+		start += w + runewidth.StringWidth(t.conf.Sep)
+	}
+
+	return 0, 0, false
+}
+
+// Handle a click at (x, y), relative to the top left corner of the table. Clicking a header
+// sorts the table by its column, or reverses the order if the table is already sorted by this
+// column. Clicking a row moves the cursor to the row and clicking the tree prefix of a row
+// opens or closes its fold.
+func (t *HierarchicalTable) Click(x int, y int) {
+	id, offset, exists := t.columnAt(x)
+	if y == 0 {
+		switch {
+		case !exists:
+			// Do nothing
+		case t.order.Valid && t.order.ID == id:
+			t.reverseSortOrder()
+		default:
+			t.sortBy(id, t.order.Ascending)
+		}
+		return
+	}
+
+	if !t.cursorIndex.Valid || !t.pageIndex.Valid || y < 1 {
+		return
+	}
+	i := (y - 1) / t.rowHeight()
+	if i >= t.pageSize() || t.pageIndex.Int+i >= len(t.rows) {
+		return
+	}
+	t.verticalScroll(t.pageIndex.Int + i - t.cursorIndex.Int)
+
+	row := t.rows[t.cursorIndex.Int]
+	if exists && t.conf.Columns[id].TreePrefix && offset < runewidth.StringWidth(row.prefix) && len(row.children) > 0 {
+		t.toggleTraversableAtCursor()
+	}
+}
+
 func (t *HierarchicalTable) ActiveNodePath() []interface{} {
 	if !t.cursorIndex.Valid {
 		return nil
@@ -595,7 +1119,7 @@ func (t *HierarchicalTable) ActiveNodePath() []interface{} {
 
 	path := t.rows[t.cursorIndex.Int].path
 	slicedPath := make([]interface{}, 0)
-	for _, id := range path.ids[:path.len] {
+	for _, id := range path.ids {
 		slicedPath = append(slicedPath, id)
 	}
 
@@ -632,54 +1156,171 @@ func (t *HierarchicalTable) reverseSortOrder() {
 	}
 }
 
+// Return the columns shown by the table
+func (t HierarchicalTable) Columns() ColumnConfiguration {
+	return t.conf.Columns
+}
+
+// Show 'columns' instead of the current columns of the table. Rows sorted by a column that
+// is not shown anymore are sorted by the first column instead.
+func (t *HierarchicalTable) SetColumns(columns ColumnConfiguration) {
+	t.conf.Columns = columns
+	t.columnOffset = utils.Bounded(t.columnOffset, 0, utils.MaxInt(0, len(columns)-1))
+	t.textOffset = 0
+	if _, exists := columns[t.order.ID]; t.order.Valid && !exists {
+		if ids := columns.IDs(); len(ids) > 0 {
+			t.order.ID = ids[0]
+		} else {
+			t.order.Valid = false
+		}
+	}
+	t.columnWidth = make(map[ColumnID]int)
+	t.Replace(t.outerNodes)
+}
+
+// Return the order of the rows of the table
+func (t HierarchicalTable) Order() Order {
+	return t.order
+}
+
+// Sort the rows of the table according to 'order'. Rows keep the order in which they were
+// given to Replace() if the order is not valid.
+func (t *HierarchicalTable) SetOrder(order Order) {
+	t.order = order
+	t.Replace(t.outerNodes)
+}
+
 func (t *HierarchicalTable) Process(ev *tcell.EventKey) {
+	t.Do(tableAction(ev))
+}
+
+// Return the action bound to the key of ev by default
+func tableAction(ev *tcell.EventKey) Action {
 	switch ev.Key() {
 	case tcell.KeyDown, tcell.KeyCtrlN:
-		t.verticalScroll(+1)
+		return ActionCursorDown
 	case tcell.KeyUp, tcell.KeyCtrlP:
-		t.verticalScroll(-1)
+		return ActionCursorUp
 	case tcell.KeyLeft:
-		t.horizontalScroll(-1)
+		return ActionScrollLeft
 	case tcell.KeyRight:
-		t.horizontalScroll(+1)
+		return ActionScrollRight
 	case tcell.KeyCtrlD:
-		t.verticalScroll(t.pageSize() / 2)
+		return ActionHalfPageDown
 	case tcell.KeyPgDn, tcell.KeyCtrlF:
-		t.verticalScroll(t.pageSize())
+		return ActionPageDown
 	case tcell.KeyCtrlU:
-		t.verticalScroll(-t.pageSize() / 2)
+		return ActionHalfPageUp
 	case tcell.KeyPgUp, tcell.KeyCtrlB:
-		t.verticalScroll(-t.pageSize())
+		return ActionPageUp
 	case tcell.KeyHome:
-		t.verticalScroll(-len(t.rows))
+		return ActionTop
 	case tcell.KeyEnd:
-		t.verticalScroll(len(t.rows))
+		return ActionBottom
 	case tcell.KeyTab:
-		t.toggleTraversableAtCursor()
+		return ActionToggleFold
 	case tcell.KeyRune:
 		switch keyRune := ev.Rune(); keyRune {
 		case 'j':
-			t.verticalScroll(+1)
+			return ActionCursorDown
 		case 'k':
-			t.verticalScroll(-1)
+			return ActionCursorUp
 		case 'h':
-			t.horizontalScroll(-1)
+			return ActionScrollLeft
 		case 'l':
-			t.horizontalScroll(+1)
+			return ActionScrollRight
 		case 'c':
-			t.setTraversableAtCursor(false, false)
+			return ActionCloseFold
 		case 'C', '-':
-			t.setTraversableAtCursor(false, true)
+			return ActionCloseAllFolds
 		case 'o':
-			t.setTraversableAtCursor(true, false)
+			return ActionOpenFold
 		case 'O', '+':
-			t.setTraversableAtCursor(true, true)
+			return ActionOpenAllFolds
+		case 'E':
+			return ActionOpenEveryFold
+		case 'Z':
+			return ActionCloseEveryFold
 		case '>':
-			t.sortByNextColumn(false)
+			return ActionSortRight
 		case '<':
-			t.sortByNextColumn(true)
+			return ActionSortLeft
 		case '!':
-			t.reverseSortOrder()
+			return ActionReverseSort
+		case 'd':
+			return ActionToggleDetails
+		case 'p':
+			return ActionParent
+		case 'J':
+			return ActionNextSibling
+		case 'K':
+			return ActionPreviousSibling
+		case '}':
+			return ActionNextTopLevel
+		case '{':
+			return ActionPreviousTopLevel
+		case 'G':
+			return ActionBottom
 		}
 	}
+
+	return ActionNone
+}
+
+// Perform 'action' on the table, usually at the cursor
+func (t *HierarchicalTable) Do(action Action) {
+	switch action {
+	case ActionCursorDown:
+		t.verticalScroll(+1)
+	case ActionCursorUp:
+		t.verticalScroll(-1)
+	case ActionScrollLeft:
+		t.horizontalScroll(-1)
+	case ActionScrollRight:
+		t.horizontalScroll(+1)
+	case ActionHalfPageDown:
+		t.pageScroll(t.pageSize() / 2)
+	case ActionPageDown:
+		t.pageScroll(t.pageSize())
+	case ActionHalfPageUp:
+		t.pageScroll(-t.pageSize() / 2)
+	case ActionPageUp:
+		t.pageScroll(-t.pageSize())
+	case ActionTop:
+		t.verticalScroll(-len(t.rows))
+	case ActionBottom:
+		t.verticalScroll(len(t.rows))
+	case ActionToggleFold:
+		t.toggleTraversableAtCursor()
+	case ActionCloseFold:
+		t.setTraversableAtCursor(false, false)
+	case ActionCloseAllFolds:
+		t.setTraversableAtCursor(false, true)
+	case ActionOpenFold:
+		t.setTraversableAtCursor(true, false)
+	case ActionOpenAllFolds:
+		t.setTraversableAtCursor(true, true)
+	case ActionOpenEveryFold:
+		t.setEveryTraversable(true)
+	case ActionCloseEveryFold:
+		t.setEveryTraversable(false)
+	case ActionSortRight:
+		t.sortByNextColumn(false)
+	case ActionSortLeft:
+		t.sortByNextColumn(true)
+	case ActionReverseSort:
+		t.reverseSortOrder()
+	case ActionToggleDetails:
+		t.toggleDetails()
+	case ActionParent:
+		t.scrollToParent()
+	case ActionNextSibling:
+		t.scrollToSibling(true)
+	case ActionPreviousSibling:
+		t.scrollToSibling(false)
+	case ActionNextTopLevel:
+		t.scrollToTopLevel(true)
+	case ActionPreviousTopLevel:
+		t.scrollToTopLevel(false)
+	}
 }
@@ -1,6 +1,7 @@
 package tui
 
 import (
+	"strconv"
 	"strings"
 	"testing"
 
@@ -16,6 +17,9 @@ type testNode struct {
 }
 
 func (n testNode) Compare(other TableNode, id ColumnID, v interface{}) int {
+	if id == column2 {
+		return strings.Compare(n.values[column2].String(), other.(testNode).values[column2].String())
+	}
 	if n.id < other.(testNode).id {
 		return -1
 	} else if n.id == other.(testNode).id {
@@ -200,12 +204,55 @@ func TestHierarchicalTable_VerticalScroll(t *testing.T) {
 	}
 }
 
+func TestHierarchicalTable_pageScroll(t *testing.T) {
+	nodes := make([]TableNode, 0)
+	for i := 0; i < 10; i++ {
+		nodes = append(nodes, testNode{id: i})
+	}
+
+	const pageSize = 4
+	table, err := NewHierarchicalTable(defaultConf, nodes, 0, pageSize+1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	table.verticalScroll(1)
+
+	for _, step := range []struct {
+		action Action
+		page   int
+		cursor int
+	}{
+		// The cursor stays on the second line of the page
+		{action: ActionPageDown, page: 4, cursor: 5},
+		{action: ActionHalfPageDown, page: 6, cursor: 7},
+		// The page cannot move past the end of the table but the cursor can
+		{action: ActionHalfPageDown, page: 6, cursor: 9},
+		{action: ActionPageDown, page: 6, cursor: 9},
+		{action: ActionHalfPageUp, page: 4, cursor: 7},
+		{action: ActionPageUp, page: 0, cursor: 3},
+		{action: ActionPageUp, page: 0, cursor: 0},
+	} {
+		table.Do(step.action)
+		if table.pageIndex.Int != step.page || table.cursorIndex.Int != step.cursor {
+			t.Fatalf("expected page at row %d and cursor at row %d but got %d and %d", step.page, step.cursor, table.pageIndex.Int, table.cursorIndex.Int)
+		}
+	}
+
+	empty, err := NewHierarchicalTable(defaultConf, nil, 0, pageSize+1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Must not panic
+	empty.Do(ActionPageDown)
+	empty.Do(ActionHalfPageUp)
+}
+
 func TestHierarchicalTable_Replace(t *testing.T) {
 	t.Run("traversable state of innerNodes must be preserved across calls to Replace()", func(t *testing.T) {
 		table := HierarchicalTable{
 			height:      10,
 			columnWidth: make(map[ColumnID]int),
-			traversable: make(map[nodePath]bool),
+			traversable: make(map[string]anchoredState),
 		}
 
 		nodes := []TableNode{
@@ -259,7 +306,7 @@ func TestHierarchicalTable_Replace(t *testing.T) {
 		table := HierarchicalTable{
 			height:      10,
 			columnWidth: make(map[ColumnID]int),
-			traversable: make(map[nodePath]bool),
+			traversable: make(map[string]anchoredState),
 		}
 
 		nodes := []TableNode{
@@ -316,7 +363,7 @@ func TestHierarchicalTable_Replace(t *testing.T) {
 		table := HierarchicalTable{
 			height:      10,
 			columnWidth: make(map[ColumnID]int),
-			traversable: make(map[nodePath]bool),
+			traversable: make(map[string]anchoredState),
 		}
 
 		nodes := []TableNode{
@@ -337,7 +384,7 @@ func TestHierarchicalTable_Replace(t *testing.T) {
 		table := HierarchicalTable{
 			height:      10,
 			columnWidth: make(map[ColumnID]int),
-			traversable: make(map[nodePath]bool),
+			traversable: make(map[string]anchoredState),
 		}
 
 		table.Replace([]TableNode{
@@ -364,7 +411,7 @@ func TestHierarchicalTable_Replace(t *testing.T) {
 		table := HierarchicalTable{
 			height:      10,
 			columnWidth: make(map[ColumnID]int),
-			traversable: make(map[nodePath]bool),
+			traversable: make(map[string]anchoredState),
 		}
 
 		table.Replace([]TableNode{
@@ -393,7 +440,7 @@ func TestHierarchicalTable_Replace(t *testing.T) {
 		table := HierarchicalTable{
 			height:      3,
 			columnWidth: make(map[ColumnID]int),
-			traversable: make(map[nodePath]bool),
+			traversable: make(map[string]anchoredState),
 		}
 
 		table.Replace([]TableNode{
@@ -432,7 +479,7 @@ func TestHierarchicalTable_Replace(t *testing.T) {
 		table := HierarchicalTable{
 			height:      4,
 			columnWidth: make(map[ColumnID]int),
-			traversable: make(map[nodePath]bool),
+			traversable: make(map[string]anchoredState),
 		}
 
 		table.Replace([]TableNode{
@@ -471,7 +518,7 @@ func TestHierarchicalTable_Replace(t *testing.T) {
 		table := HierarchicalTable{
 			height:      3,
 			columnWidth: make(map[ColumnID]int),
-			traversable: make(map[nodePath]bool),
+			traversable: make(map[string]anchoredState),
 		}
 
 		table.Replace([]TableNode{
@@ -689,6 +736,128 @@ func TestHierarchicalTable_SetTraversable(t *testing.T) {
 	})
 }
 
+func TestHierarchicalTable_deepTree(t *testing.T) {
+	// Chain of nodes much deeper than the trees of CI pipelines usually are
+	const depth = 50
+	root := &testNode{id: 0}
+	for node, i := root, 1; i < depth; i++ {
+		child := &testNode{id: i}
+		node.children = []*testNode{child}
+		node = child
+	}
+
+	table, err := NewHierarchicalTable(defaultConf, []TableNode{*root}, 0, depth)
+	if err != nil {
+		t.Fatal(err)
+	}
+	table.setTraversableAtCursor(true, true)
+	if len(table.rows) != depth {
+		t.Fatalf("expected %d rows but got %d", depth, len(table.rows))
+	}
+
+	table.verticalScroll(depth - 1)
+	path := table.ActiveNodePath()
+	if len(path) != depth || path[depth-1] != depth-1 {
+		t.Fatalf("unexpected path of the last row: %v", path)
+	}
+
+	// Collapsing a node deep in the tree must hide its descendants, even after the nodes
+	// are replaced
+	table.Collapse(path[:depth/2]...)
+	table.Replace([]TableNode{*root})
+	if len(table.rows) != depth/2 {
+		t.Fatalf("expected %d rows but got %d", depth/2, len(table.rows))
+	}
+}
+
+// Node whose ID changes over time, identified by its name
+type stableTestNode struct {
+	id       int
+	name     string
+	children []TableNode
+}
+
+func (n stableTestNode) NodeID() interface{}         { return n.id }
+func (n stableTestNode) StableID() interface{}       { return n.name }
+func (n stableTestNode) NodeChildren() []TableNode   { return n.children }
+func (n stableTestNode) InheritedValues() []ColumnID { return nil }
+func (n stableTestNode) Values(v interface{}) map[ColumnID]StyledString {
+	return make(map[ColumnID]StyledString)
+}
+func (n stableTestNode) Compare(other TableNode, id ColumnID, v interface{}) int { return 0 }
+
+type anchorTestNode struct {
+	stableTestNode
+	anchor string
+}
+
+func (n anchorTestNode) Anchor() interface{} { return n.anchor }
+
+func TestHierarchicalTable_FoldState(t *testing.T) {
+	conf := defaultConf
+	conf.DefaultDepth = 1
+	before := []TableNode{
+		anchorTestNode{
+			stableTestNode: stableTestNode{id: 1, name: "pipeline", children: []TableNode{
+				stableTestNode{id: 10, name: "stage", children: []TableNode{
+					stableTestNode{id: 11, name: "job"},
+				}},
+			}},
+			anchor: "pipeline",
+		},
+	}
+	// IDs of the stage and of the job have changed and the pipeline was moved under a group
+	after := []TableNode{
+		stableTestNode{id: 0, name: "group", children: []TableNode{
+			anchorTestNode{
+				stableTestNode: stableTestNode{id: 1, name: "pipeline", children: []TableNode{
+					stableTestNode{id: 20, name: "stage", children: []TableNode{
+						stableTestNode{id: 21, name: "job"},
+					}},
+				}},
+				anchor: "pipeline",
+			},
+		}},
+	}
+	expectedPaths := []nodePath{
+		nodePathFromIDs(0),
+		nodePathFromIDs(0, 1),
+		nodePathFromIDs(0, 1, 20),
+		nodePathFromIDs(0, 1, 20, 21),
+	}
+
+	table, err := NewHierarchicalTable(conf, before, 0, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Expand the stage
+	table.verticalScroll(1)
+	table.setTraversableAtCursor(true, false)
+
+	t.Run("traversable state can be restored in another table", func(t *testing.T) {
+		state := table.FoldState()
+		if len(state) != 1 || len(state["pipeline"]) != 3 {
+			t.Fatalf("unexpected fold state: %v", state)
+		}
+
+		other, err := NewHierarchicalTable(conf, after, 0, 10)
+		if err != nil {
+			t.Fatal(err)
+		}
+		other.RestoreFoldState("pipeline", state["pipeline"])
+		if diff := nodePaths(expectedPaths).Diff(rowPaths(other)); diff != "" {
+			t.Fatal(diff)
+		}
+	})
+
+	t.Run("traversable state is preserved when IDs change and nodes move", func(t *testing.T) {
+		table.Replace(after)
+		if diff := nodePaths(expectedPaths).Diff(rowPaths(table)); diff != "" {
+			t.Fatal(diff)
+		}
+	})
+}
+
 func TestHierarchicalTable_ScrollToMatch(t *testing.T) {
 	nodes := []TableNode{
 		testNode{
@@ -736,7 +905,7 @@ func TestHierarchicalTable_ScrollToMatch(t *testing.T) {
 		if err != nil {
 			t.Fatal(err)
 		}
-		if table.ScrollToNextMatch("1", true) != false {
+		if table.ScrollToNextMatch(mustPattern("1"), true) != false {
 			t.Fatal("expected match NOT to be found")
 		}
 	})
@@ -747,7 +916,7 @@ func TestHierarchicalTable_ScrollToMatch(t *testing.T) {
 			t.Fatal(err)
 		}
 		table.setTraversableAtCursor(true, true)
-		if table.ScrollToNextMatch("2", true) != true {
+		if table.ScrollToNextMatch(mustPattern("2"), true) != true {
 			t.Fatal("expected match to be found")
 		}
 		expectedCursorIndex := nullInt{
@@ -764,7 +933,7 @@ func TestHierarchicalTable_ScrollToMatch(t *testing.T) {
 		if err != nil {
 			t.Fatal(err)
 		}
-		if table.ScrollToNextMatch("3", false) != true {
+		if table.ScrollToNextMatch(mustPattern("3"), false) != true {
 			t.Fatal("expected match to be found")
 		}
 		expectedCursorIndex := nullInt{
@@ -783,7 +952,7 @@ func TestHierarchicalTable_ScrollToMatch(t *testing.T) {
 		}
 		table.setTraversableAtCursor(true, true)
 		table.verticalScroll(1)
-		if table.ScrollToNextMatch("1", true) != true {
+		if table.ScrollToNextMatch(mustPattern("1"), true) != true {
 			t.Fatal("expected match to be found")
 		}
 		expectedCursorIndex := nullInt{
@@ -800,10 +969,338 @@ func TestHierarchicalTable_ScrollToMatch(t *testing.T) {
 		if err != nil {
 			t.Fatal(err)
 		}
-		if table.ScrollToNextMatch("2", true) != false {
+		if table.ScrollToNextMatch(mustPattern("2"), true) != false {
 			t.Fatal("expected match NOT to be found")
 		}
 	})
+
+	t.Run("the position of the cursor among matching rows must be reported", func(t *testing.T) {
+		table, err := NewHierarchicalTable(conf, nodes, 0, 10)
+		if err != nil {
+			t.Fatal(err)
+		}
+		table.setTraversableAtCursor(true, true)
+		if index, total := table.MatchPosition(); index != 0 || total != 0 {
+			t.Fatalf("expected no match without search but got %d of %d", index, total)
+		}
+
+		table.SetSearch(mustPattern("re:[12]"))
+		for _, expected := range [][2]int{{1, 2}, {2, 2}, {0, 2}} {
+			if index, total := table.MatchPosition(); index != expected[0] || total != expected[1] {
+				t.Fatalf("expected match %d of %d but got %d of %d", expected[0], expected[1], index, total)
+			}
+			table.verticalScroll(1)
+		}
+
+		values := table.highlight(table.rows[1].values)
+		if s := values[column1].String(); s != "2" {
+			t.Fatalf("highlighting must not change values but got %q", s)
+		}
+	})
+}
+
+func TestHierarchicalTable_everyFold(t *testing.T) {
+	node := func(id int, children ...*testNode) *testNode {
+		return &testNode{
+			id: id,
+			values: map[ColumnID]StyledString{
+				column1: NewStyledString(strconv.Itoa(id)),
+			},
+			children: children,
+		}
+	}
+	nodes := []TableNode{
+		*node(1, node(2, node(3)), node(4)),
+		*node(5, node(6)),
+	}
+
+	conf := defaultConf
+	conf.DefaultDepth = 1
+	table, err := NewHierarchicalTable(conf, nodes, 20, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(table.rows) != 5 {
+		t.Fatalf("expected %d rows but got %d", 5, len(table.rows))
+	}
+
+	table.Do(ActionOpenEveryFold)
+	if len(table.rows) != 6 {
+		t.Fatalf("expected %d rows but got %d", 6, len(table.rows))
+	}
+
+	// Closing every fold moves the cursor to the top-level ancestor of its row
+	table.verticalScroll(2)
+	table.Do(ActionCloseEveryFold)
+	if len(table.rows) != 2 {
+		t.Fatalf("expected %d rows but got %d", 2, len(table.rows))
+	}
+	if table.cursorIndex.Int != 0 {
+		t.Fatalf("expected cursor on row %d but got %d", 0, table.cursorIndex.Int)
+	}
+}
+
+func TestHierarchicalTable_ScrollToNode(t *testing.T) {
+	node := func(id int, children ...*testNode) *testNode {
+		return &testNode{
+			id: id,
+			values: map[ColumnID]StyledString{
+				column1: NewStyledString(strconv.Itoa(id)),
+			},
+			children: children,
+		}
+	}
+	nodes := []TableNode{
+		*node(1, node(2, node(3)), node(4)),
+		*node(5),
+	}
+
+	table, err := NewHierarchicalTable(defaultConf, nodes, 20, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Folds leading to the node are opened
+	if !table.ScrollToNode(3) {
+		t.Fatal("expected node to be found")
+	}
+	if diff := cmp.Diff([]interface{}{1, 2, 3}, table.ActiveNodePath()); diff != "" {
+		t.Fatal(diff)
+	}
+
+	if !table.ScrollToNode(5) {
+		t.Fatal("expected node to be found")
+	}
+	if diff := cmp.Diff([]interface{}{5}, table.ActiveNodePath()); diff != "" {
+		t.Fatal(diff)
+	}
+
+	if table.ScrollToNode(6) {
+		t.Fatal("expected node NOT to be found")
+	}
+}
+
+func TestHierarchicalTable_ScrollToRow(t *testing.T) {
+	nodes := make([]TableNode, 0)
+	for i := 1; i <= 5; i++ {
+		nodes = append(nodes, testNode{
+			id: i,
+			values: map[ColumnID]StyledString{
+				column1: NewStyledString(strconv.Itoa(i)),
+			},
+		})
+	}
+
+	table, err := NewHierarchicalTable(defaultConf, nodes, 20, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, testCase := range []struct {
+		index    int
+		expected int
+	}{
+		{index: 3, expected: 4},
+		{index: 0, expected: 1},
+		{index: 42, expected: 5},
+		{index: -1, expected: 1},
+	} {
+		table.ScrollToRow(testCase.index)
+		if diff := cmp.Diff([]interface{}{testCase.expected}, table.ActiveNodePath()); diff != "" {
+			t.Fatalf("row %d: %s", testCase.index, diff)
+		}
+	}
+}
+
+func TestHierarchicalTable_SetFilter(t *testing.T) {
+	node := func(id int, children ...*testNode) *testNode {
+		return &testNode{
+			id: id,
+			values: map[ColumnID]StyledString{
+				column1: NewStyledString("row " + strconv.Itoa(id)),
+			},
+			children: children,
+		}
+	}
+	// 1
+	// ├── 2
+	// │   └── 3
+	// └── 4
+	// 5
+	nodes := []TableNode{
+		*node(1, node(2, node(3)), node(4)),
+		*node(5),
+	}
+
+	conf := defaultConf
+	conf.Columns = ColumnConfiguration{
+		column1: {
+			Header:    "column1",
+			Position:  0,
+			MaxWidth:  42,
+			Alignment: Left,
+		},
+	}
+
+	table, err := NewHierarchicalTable(conf, nodes, 20, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rows := func() []string {
+		values := make([]string, 0, len(table.rows))
+		for _, row := range table.rows {
+			values = append(values, row.prefix+row.values[column1].String())
+		}
+		return values
+	}
+
+	// Folds are closed so only top-level rows are shown
+	table.SetFilter(true)
+	if diff := cmp.Diff([]string{"+row 1", " row 5"}, rows()); diff != "" {
+		t.Fatalf("all rows must be shown while no pattern is searched: %s", diff)
+	}
+
+	// Rows matching in closed folds and their ancestors are shown
+	table.SetSearch(mustPattern("re:[35]"))
+	expected := []string{"-row 1", " └── row 2", "     └── row 3", " row 5"}
+	if diff := cmp.Diff(expected, rows()); diff != "" {
+		t.Fatal(diff)
+	}
+
+	table.SetFilter(false)
+	if diff := cmp.Diff([]string{"+row 1", " row 5"}, rows()); diff != "" {
+		t.Fatalf("disabling the filter must restore the folds: %s", diff)
+	}
+}
+
+func TestHierarchicalTable_scrollToParentAndSibling(t *testing.T) {
+	node := func(id int, children ...*testNode) *testNode {
+		return &testNode{
+			id: id,
+			values: map[ColumnID]StyledString{
+				column1: NewStyledString(strconv.Itoa(id)),
+			},
+			children: children,
+		}
+	}
+	// 1
+	// ├── 2
+	// │   └── 3
+	// └── 4
+	// 5
+	// 6
+	// └── 7
+	//     └── 8
+	nodes := []TableNode{
+		*node(1, node(2, node(3)), node(4)),
+		*node(5),
+		*node(6, node(7, node(8))),
+	}
+
+	conf := defaultConf
+	conf.Columns = ColumnConfiguration{
+		column1: {
+			Header:    "column1",
+			Position:  0,
+			MaxWidth:  42,
+			Alignment: Left,
+		},
+	}
+
+	testCases := []struct {
+		name     string
+		from     int
+		move     func(table *HierarchicalTable)
+		expected int
+	}{
+		{
+			name:     "parent of a leaf",
+			from:     2,
+			move:     func(table *HierarchicalTable) { table.scrollToParent() },
+			expected: 1,
+		},
+		{
+			name:     "parent of a top-level row",
+			from:     4,
+			move:     func(table *HierarchicalTable) { table.scrollToParent() },
+			expected: 4,
+		},
+		{
+			name:     "next sibling skips descendants",
+			from:     1,
+			move:     func(table *HierarchicalTable) { table.scrollToSibling(true) },
+			expected: 3,
+		},
+		{
+			name:     "no next sibling",
+			from:     3,
+			move:     func(table *HierarchicalTable) { table.scrollToSibling(true) },
+			expected: 3,
+		},
+		{
+			name:     "previous sibling",
+			from:     3,
+			move:     func(table *HierarchicalTable) { table.scrollToSibling(false) },
+			expected: 1,
+		},
+		{
+			name:     "no previous sibling",
+			from:     1,
+			move:     func(table *HierarchicalTable) { table.scrollToSibling(false) },
+			expected: 1,
+		},
+		{
+			name:     "next top-level row",
+			from:     0,
+			move:     func(table *HierarchicalTable) { table.scrollToSibling(true) },
+			expected: 4,
+		},
+		{
+			name:     "next top-level row from a nested row",
+			from:     2,
+			move:     func(table *HierarchicalTable) { table.scrollToTopLevel(true) },
+			expected: 4,
+		},
+		{
+			name:     "previous top-level row from a nested row",
+			from:     7,
+			move:     func(table *HierarchicalTable) { table.scrollToTopLevel(false) },
+			expected: 4,
+		},
+		{
+			name:     "no previous top-level row",
+			from:     3,
+			move:     func(table *HierarchicalTable) { table.scrollToTopLevel(false) },
+			expected: 3,
+		},
+		{
+			name:     "no next top-level row",
+			from:     6,
+			move:     func(table *HierarchicalTable) { table.scrollToTopLevel(true) },
+			expected: 6,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			table, err := NewHierarchicalTable(conf, nodes, 20, 10)
+			if err != nil {
+				t.Fatal(err)
+			}
+			table.setEveryTraversable(true)
+			table.verticalScroll(testCase.from)
+
+			testCase.move(&table)
+
+			expected := nullInt{
+				Valid: true,
+				Int:   testCase.expected,
+			}
+			if diff := expected.Diff(table.cursorIndex); diff != "" {
+				t.Fatal(diff)
+			}
+		})
+	}
 }
 
 func TestHierarchicalTable_Resize(t *testing.T) {
@@ -873,6 +1370,225 @@ func TestHierarchicalTable_Resize(t *testing.T) {
 	})
 }
 
+func TestHierarchicalTable_computeColumnWidths(t *testing.T) {
+	nodes := make([]TableNode, 0)
+	for i, n := range []int{20, 10, 10, 8, 8} {
+		nodes = append(nodes, testNode{
+			id: i + 1,
+			values: map[ColumnID]StyledString{
+				column1: NewStyledString(strings.Repeat("x", n)),
+			},
+		})
+	}
+
+	conf := defaultConf
+	conf.Columns = ColumnConfiguration{
+		column1: {
+			Header:    "c",
+			Position:  0,
+			MaxWidth:  999,
+			Alignment: Left,
+		},
+	}
+
+	// Two rows are visible on screen
+	table, err := NewHierarchicalTable(conf, nodes, 80, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	steps := []struct {
+		name   string
+		scroll int
+		width  int
+	}{
+		{
+			name:   "the width of the column must match the widest visible value",
+			scroll: 0,
+			width:  20,
+		},
+		{
+			name:   "the column must narrow once the widest value is no longer visible",
+			scroll: 2,
+			width:  10,
+		},
+		{
+			name:   "the column must not narrow if the difference is small",
+			scroll: 2,
+			width:  10,
+		},
+		{
+			name:   "the column must widen as soon as a wider value is visible",
+			scroll: -4,
+			width:  20,
+		},
+	}
+
+	for _, step := range steps {
+		table.verticalScroll(step.scroll)
+		if table.columnWidth[column1] != step.width {
+			t.Fatalf("%s: expected width %d but got %d", step.name, step.width, table.columnWidth[column1])
+		}
+	}
+}
+
+func TestHierarchicalTable_toggleDetails(t *testing.T) {
+	conf := defaultConf
+	conf.Columns = ColumnConfiguration{
+		column1: {
+			Header:     "column1",
+			Position:   0,
+			MaxWidth:   999,
+			Alignment:  Left,
+			TreePrefix: true,
+		},
+	}
+	conf.Details = ColumnConfiguration{
+		column2: {
+			Header:   "sha",
+			Position: 0,
+		},
+		column3: {
+			Header:   "duration",
+			Position: 1,
+		},
+	}
+
+	table, err := NewHierarchicalTable(conf, nil, 30, 11)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if table.pageSize() != 10 {
+		t.Fatalf("expected page size of 10 but got %d", table.pageSize())
+	}
+	table.toggleDetails()
+	if table.pageSize() != 5 {
+		t.Fatalf("expected page size of 5 but got %d", table.pageSize())
+	}
+
+	values := map[ColumnID]StyledString{
+		column1: NewStyledString("name"),
+		column2: NewStyledString("abcdef0"),
+		column3: NewStyledString("1m30s"),
+	}
+	s := table.detailString(values, "├── ").String()
+	expected := "     sha: abcdef0  duration: 1m30s"[:30]
+	if diff := cmp.Diff(expected, s); diff != "" {
+		t.Fatal(diff)
+	}
+
+	table.toggleDetails()
+	if table.pageSize() != 10 {
+		t.Fatalf("expected page size of 10 but got %d", table.pageSize())
+	}
+}
+
+func TestHierarchicalTable_Click(t *testing.T) {
+	conf := defaultConf
+	conf.Columns = ColumnConfiguration{
+		column1: {
+			Header:     "column1",
+			Position:   0,
+			MaxWidth:   999,
+			Alignment:  Left,
+			TreePrefix: true,
+		},
+		column2: {
+			Header:    "column2",
+			Position:  1,
+			MaxWidth:  999,
+			Alignment: Left,
+		},
+	}
+	node := func(id int, children ...*testNode) *testNode {
+		return &testNode{
+			id: id,
+			values: map[ColumnID]StyledString{
+				column1: NewStyledString(strconv.Itoa(id)),
+				column2: NewStyledString(strconv.Itoa(id)),
+			},
+			children: children,
+		}
+	}
+	nodes := []TableNode{*node(1, node(2), node(3)), *node(4)}
+
+	table, err := NewHierarchicalTable(conf, nodes, 30, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("clicking a row moves the cursor", func(t *testing.T) {
+		table.Click(3, 2)
+		if diff := table.cursorIndex.Diff(nullInt{Valid: true, Int: 1}); diff != "" {
+			t.Fatal(diff)
+		}
+		// Below the last row
+		table.Click(3, 5)
+		if diff := table.cursorIndex.Diff(nullInt{Valid: true, Int: 1}); diff != "" {
+			t.Fatal(diff)
+		}
+	})
+
+	t.Run("clicking the tree prefix of a row opens or closes its fold", func(t *testing.T) {
+		table.Click(0, 1)
+		expected := []nodePath{
+			nodePathFromIDs(1),
+			nodePathFromIDs(1, 2),
+			nodePathFromIDs(1, 3),
+			nodePathFromIDs(4),
+		}
+		if diff := nodePaths(expected).Diff(rowPaths(table)); diff != "" {
+			t.Fatal(diff)
+		}
+		// Clicking the value of a row does not close its fold
+		table.Click(3, 1)
+		if diff := nodePaths(expected).Diff(rowPaths(table)); diff != "" {
+			t.Fatal(diff)
+		}
+	})
+
+	t.Run("clicking a header sorts the table by its column", func(t *testing.T) {
+		table.Click(10, 0)
+		if diff := cmp.Diff(Order{Valid: true, ID: column2}, table.Order()); diff != "" {
+			t.Fatal(diff)
+		}
+		table.Click(10, 0)
+		if diff := cmp.Diff(Order{Valid: true, ID: column2, Ascending: true}, table.Order()); diff != "" {
+			t.Fatal(diff)
+		}
+	})
+}
+
+func TestHierarchicalTable_SetColumns(t *testing.T) {
+	conf := defaultConf
+	conf.Columns = ColumnConfiguration{
+		column1: {Header: "column1", Position: 0, MaxWidth: 999},
+		column2: {Header: "column2", Position: 1, MaxWidth: 999},
+	}
+	conf.Order = Order{Valid: true, ID: column2, Ascending: true}
+	nodes := []TableNode{
+		testNode{id: 1, values: map[ColumnID]StyledString{column1: NewStyledString("a"), column2: NewStyledString("b")}},
+	}
+	table, err := NewHierarchicalTable(conf, nodes, 30, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	columns := ColumnConfiguration{
+		column3: {Header: "column3", Position: 0, MaxWidth: 999},
+		column1: {Header: "column1", Position: 1, MaxWidth: 999},
+	}
+	table.SetColumns(columns)
+	if diff := cmp.Diff(columns, table.Columns()); diff != "" {
+		t.Fatal(diff)
+	}
+	// The table was sorted by a column that is not shown anymore
+	if diff := cmp.Diff(Order{Valid: true, ID: column3, Ascending: true}, table.Order()); diff != "" {
+		t.Fatal(diff)
+	}
+}
+
 func TestHierarchicalTable_headers(t *testing.T) {
 	t.Run("", func(t *testing.T) {
 		conf := defaultConf
@@ -976,6 +1692,46 @@ func TestHierarchicalTable_styledString(t *testing.T) {
 		}
 	})
 
+	t.Run("columns wider than the table are scrolled through before the next column", func(t *testing.T) {
+		conf := conf
+		conf.Columns = ColumnConfiguration{
+			column1: conf.Columns[column1],
+			column2: conf.Columns[column2],
+		}
+		table, err := NewHierarchicalTable(conf, nil, 10, 10)
+		if err != nil {
+			t.Fatal(err)
+		}
+		values := map[ColumnID]StyledString{
+			column1: NewStyledString("column1"),
+			column2: NewStyledString("a commit message longer than the table"),
+		}
+		table.columnWidth[column2] = values[column2].Length()
+
+		scroll := func(amount int) string {
+			table.horizontalScroll(amount)
+			return table.styledString(values, "", false).String()
+		}
+		lines := []string{table.styledString(values, "", false).String()}
+		for _, amount := range []int{1, 1, 1, 1, 1, 1, -1, -4} {
+			lines = append(lines, scroll(amount))
+		}
+		expected := []string{
+			"column1  a",
+			"a commit m",
+			" message l",
+			" longer th",
+			"than the t",
+			" the table",
+			" the table",
+			"ger than t",
+			"column1  a",
+		}
+		if diff := cmp.Diff(expected, lines); diff != "" {
+			t.Fatal(diff)
+		}
+	})
+
 	t.Run("no configuration: do not display anything but do not crash either", func(t *testing.T) {
 		table, err := NewHierarchicalTable(defaultConf, nil, 28, 10)
 		if err != nil {
@@ -1213,4 +1969,42 @@ func TestHierarchicalTable_SortBy(t *testing.T) {
 		}
 	})
 
+	t.Run("nodes comparing equal must keep their original order", func(t *testing.T) {
+		value := func(s string) map[ColumnID]StyledString {
+			return map[ColumnID]StyledString{column2: NewStyledString(s)}
+		}
+		nodes := []TableNode{
+			testNode{id: 3, values: value("a")},
+			testNode{id: 1, values: value("b")},
+			testNode{id: 4, values: value("a")},
+			testNode{id: 2, values: value("a")},
+		}
+		table, err := NewHierarchicalTable(conf, nodes, 10, 10)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		table.sortBy(column2, true)
+		expectedPaths := []nodePath{
+			nodePathFromIDs(3),
+			nodePathFromIDs(4),
+			nodePathFromIDs(2),
+			nodePathFromIDs(1),
+		}
+		if diff := nodePaths(expectedPaths).Diff(rowPaths(table)); diff != "" {
+			t.Fatal(diff)
+		}
+
+		table.sortBy(column2, false)
+		expectedPaths = []nodePath{
+			nodePathFromIDs(1),
+			nodePathFromIDs(3),
+			nodePathFromIDs(4),
+			nodePathFromIDs(2),
+		}
+		if diff := nodePaths(expectedPaths).Diff(rowPaths(table)); diff != "" {
+			t.Fatal(diff)
+		}
+	})
+
 }
@@ -2,6 +2,7 @@ package tui
 
 import (
 	"bytes"
+	"sort"
 	"strings"
 
 	"github.com/mattn/go-runewidth"
@@ -30,6 +31,68 @@ func (s *StyledString) Apply(t StyleTransform) {
 	}
 }
 
+// Apply t to each component of the string before the style transformation of the
+// component, which therefore takes precedence over t
+func (s *StyledString) ApplyUnder(t StyleTransform) {
+	if t == nil {
+		return
+	}
+	for i := range s.components {
+		if s.components[i].Transform == nil {
+			s.components[i].Transform = t
+		} else {
+			s.components[i].Transform = s.components[i].Transform.On(t)
+		}
+	}
+}
+
+// Apply t to the parts of the string between the start and end byte offsets of each range,
+// such as the matches returned by Pattern.FindAll(s.String())
+func (s *StyledString) ApplyRanges(ranges [][]int, t StyleTransform) {
+	if len(ranges) == 0 || t == nil {
+		return
+	}
+	inRange := func(i int) bool {
+		for _, r := range ranges {
+			if i >= r[0] && i < r[1] {
+				return true
+			}
+		}
+		return false
+	}
+
+	components := make([]elementaryString, 0, len(s.components))
+	start := 0
+	for _, c := range s.components {
+		end := start + len(c.Content)
+		// Split the component at the boundaries of the ranges
+		cuts := []int{start, end}
+		for _, r := range ranges {
+			for _, i := range r[:2] {
+				if i > start && i < end {
+					cuts = append(cuts, i)
+				}
+			}
+		}
+		sort.Ints(cuts)
+		for i := 0; i < len(cuts)-1; i++ {
+			if cuts[i] == cuts[i+1] {
+				continue
+			}
+			component := elementaryString{
+				Content:   c.Content[cuts[i]-start : cuts[i+1]-start],
+				Transform: c.Transform,
+			}
+			if inRange(cuts[i]) {
+				component.Transform = t.On(c.Transform)
+			}
+			components = append(components, component)
+		}
+		start = end
+	}
+	s.components = components
+}
+
 func (s *StyledString) Append(content string, t ...StyleTransform) {
 	s.AppendString(NewStyledString(content, t...))
 }
@@ -52,8 +115,11 @@ func (s *StyledString) Truncate(width int) {
 		for j, r := range runes {
 			width -= runewidth.RuneWidth(r)
 			if width < 0 {
-				s.components[i].Content = string(runes[:j])
-				s.components = s.components[:i+1]
+				// Copy the components since they may be shared with other strings
+				components := make([]elementaryString, i+1)
+				copy(components, s.components[:i+1])
+				components[i].Content = string(runes[:j])
+				s.components = components
 				return
 			}
 		}
@@ -68,8 +134,10 @@ func (s *StyledString) TruncateLeft(width int) {
 			r := runes[j]
 			width -= runewidth.RuneWidth(r)
 			if width < 0 {
-				s.components[i].Content = string(runes[j+1:])
-				s.components = s.components[i:]
+				components := make([]elementaryString, len(s.components)-i)
+				copy(components, s.components[i:])
+				components[0].Content = string(runes[j+1:])
+				s.components = components
 				return
 			}
 		}
@@ -123,6 +191,24 @@ func (s *StyledString) Fit(alignment Alignment, width int) {
 	}
 }
 
+// Same as Fit but a string longer than 'width' ends with 'ellipsis', or starts with it if
+// the string is aligned to the right, to show that it was truncated
+func (s *StyledString) FitWithEllipsis(alignment Alignment, width int, ellipsis string) {
+	if w := runewidth.StringWidth(ellipsis); w > 0 && w < width && s.Length() > width {
+		switch alignment {
+		case Left:
+			s.Truncate(width - w)
+			s.Append(ellipsis)
+		case Right:
+			s.TruncateLeft(width - w)
+			truncated := NewStyledString(ellipsis)
+			truncated.AppendString(*s)
+			*s = truncated
+		}
+	}
+	s.Fit(alignment, width)
+}
+
 func (s StyledString) Contains(value string) bool {
 	b := bytes.NewBufferString("")
 	for _, c := range s.components {
@@ -0,0 +1,101 @@
+package tui
+
+import (
+	"testing"
+
+	"github.com/gdamore/tcell"
+)
+
+func TestStyledString_ApplyUnder(t *testing.T) {
+	red := func(s tcell.Style) tcell.Style { return s.Foreground(tcell.ColorRed) }
+	blue := func(s tcell.Style) tcell.Style { return s.Foreground(tcell.ColorBlue) }
+	bold := func(s tcell.Style) tcell.Style { return s.Bold(true) }
+
+	s := NewStyledString("plain")
+	s.Append("blue", blue)
+	s.ApplyUnder(nil)
+	s.ApplyUnder(red)
+	s.ApplyUnder(bold)
+
+	expected := []tcell.Style{
+		tcell.StyleDefault.Foreground(tcell.ColorRed).Bold(true),
+		tcell.StyleDefault.Foreground(tcell.ColorBlue).Bold(true),
+	}
+	for i, c := range s.components {
+		if style := c.Transform(tcell.StyleDefault); style != expected[i] {
+			t.Errorf("component %d: expected %v but got %v", i, expected[i], style)
+		}
+	}
+}
+
+func TestStyledString_FitWithEllipsis(t *testing.T) {
+	testCases := []struct {
+		alignment Alignment
+		width     int
+		ellipsis  string
+		expected  string
+	}{
+		{alignment: Left, width: 10, ellipsis: "…", expected: "commit    "},
+		{alignment: Left, width: 4, ellipsis: "…", expected: "com…"},
+		{alignment: Right, width: 4, ellipsis: "…", expected: "…mit"},
+		{alignment: Left, width: 4, ellipsis: "", expected: "comm"},
+		{alignment: Left, width: 1, ellipsis: "…", expected: "c"},
+	}
+
+	for _, testCase := range testCases {
+		s := NewStyledString("commit")
+		s.FitWithEllipsis(testCase.alignment, testCase.width, testCase.ellipsis)
+		if s.String() != testCase.expected {
+			t.Errorf("expected %q but got %q", testCase.expected, s.String())
+		}
+	}
+}
+
+func TestStyledString_Truncate(t *testing.T) {
+	s := NewStyledString("abc")
+	s.Append("def")
+	truncated := s
+	truncated.Truncate(4)
+	truncated.Append("…")
+	if s.String() != "abcdef" {
+		t.Fatalf("truncating a copy must not modify the original string but got %q", s.String())
+	}
+	if truncated.String() != "abcd…" {
+		t.Fatalf("expected %q but got %q", "abcd…", truncated.String())
+	}
+}
+
+func TestStyledString_ApplyRanges(t *testing.T) {
+	blue := func(s tcell.Style) tcell.Style { return s.Foreground(tcell.ColorBlue) }
+	reverse := func(s tcell.Style) tcell.Style { return s.Reverse(true) }
+
+	s := NewStyledString("test-")
+	s.Append("unit", blue)
+	s.Append(" and test-integration")
+	s.ApplyRanges([][]int{{3, 7}, {14, 19}}, reverse)
+
+	expected := []struct {
+		content string
+		style   tcell.Style
+	}{
+		{"tes", tcell.StyleDefault},
+		{"t-", tcell.StyleDefault.Reverse(true)},
+		{"un", tcell.StyleDefault.Foreground(tcell.ColorBlue).Reverse(true)},
+		{"it", tcell.StyleDefault.Foreground(tcell.ColorBlue)},
+		{" and ", tcell.StyleDefault},
+		{"test-", tcell.StyleDefault.Reverse(true)},
+		{"integration", tcell.StyleDefault},
+	}
+	if len(s.components) != len(expected) {
+		t.Fatalf("expected %d components but got %d: %v", len(expected), len(s.components), s.components)
+	}
+	for i, c := range s.components {
+		style := tcell.StyleDefault
+		if c.Transform != nil {
+			style = c.Transform(style)
+		}
+		if c.Content != expected[i].content || style != expected[i].style {
+			t.Errorf("component %d: expected %q (%v) but got %q (%v)", i, expected[i].content, expected[i].style, c.Content, style)
+		}
+	}
+}
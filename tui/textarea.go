@@ -56,25 +56,47 @@ func (t TextArea) Draw(w Window) {
 func (t *TextArea) Process(ev *tcell.EventKey) {
 	switch ev.Key() {
 	case tcell.KeyDown, tcell.KeyCtrlN:
-		t.verticalScroll(+1)
+		t.Do(ActionCursorDown)
 	case tcell.KeyUp, tcell.KeyCtrlP:
-		t.verticalScroll(-1)
+		t.Do(ActionCursorUp)
 	case tcell.KeyCtrlD:
-		t.verticalScroll(t.height / 2)
+		t.Do(ActionHalfPageDown)
 	case tcell.KeyPgDn, tcell.KeyCtrlF:
-		t.verticalScroll(t.height)
+		t.Do(ActionPageDown)
 	case tcell.KeyCtrlU:
-		t.verticalScroll(-t.height / 2)
+		t.Do(ActionHalfPageUp)
 	case tcell.KeyPgUp, tcell.KeyCtrlB:
-		t.verticalScroll(-t.height)
+		t.Do(ActionPageUp)
 	case tcell.KeyRune:
 		switch ev.Rune() {
 		case ' ':
-			t.verticalScroll(t.height)
+			t.Do(ActionPageDown)
 		case 'k':
-			t.verticalScroll(-1)
+			t.Do(ActionCursorUp)
 		case 'j':
-			t.verticalScroll(+1)
+			t.Do(ActionCursorDown)
 		}
 	}
+}
+
+// Scroll the text area. Actions other than vertical scrolling are ignored.
+func (t *TextArea) Do(action Action) {
+	switch action {
+	case ActionCursorDown:
+		t.verticalScroll(+1)
+	case ActionCursorUp:
+		t.verticalScroll(-1)
+	case ActionHalfPageDown:
+		t.verticalScroll(t.height / 2)
+	case ActionPageDown:
+		t.verticalScroll(t.height)
+	case ActionHalfPageUp:
+		t.verticalScroll(-t.height / 2)
+	case ActionPageUp:
+		t.verticalScroll(-t.height)
+	case ActionTop:
+		t.verticalScroll(-len(t.Content))
+	case ActionBottom:
+		t.verticalScroll(len(t.Content))
+	}
 }
\ No newline at end of file
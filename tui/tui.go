@@ -10,6 +10,7 @@ import (
 	"strings"
 
 	"github.com/gdamore/tcell"
+	"github.com/gdamore/tcell/terminfo"
 )
 
 type TUI struct {
@@ -17,6 +18,10 @@ type TUI struct {
 	screen       tcell.Screen
 	defaultStyle tcell.Style
 	Eventc       chan tcell.Event
+	// True if mouse events are reported
+	mouse bool
+	// Terminal written to by WriteSequence
+	out io.Writer
 }
 
 func NewTUI(newScreen func() (tcell.Screen, error), defaultStyle tcell.Style) (TUI, error) {
@@ -24,6 +29,7 @@ func NewTUI(newScreen func() (tcell.Screen, error), defaultStyle tcell.Style) (T
 		newScreen:    newScreen,
 		defaultStyle: defaultStyle,
 		Eventc:       make(chan tcell.Event),
+		out:          os.Stdout,
 	}
 	err := ui.init()
 
@@ -41,7 +47,9 @@ func (t *TUI) init() error {
 		return err
 	}
 	t.screen.SetStyle(t.defaultStyle)
-	//screen.EnableMouse()
+	if t.mouse {
+		t.screen.EnableMouse()
+	}
 	t.screen.Clear()
 
 	go t.poll()
@@ -49,6 +57,12 @@ func (t *TUI) init() error {
 	return nil
 }
 
+// Report mouse events on Eventc, including after the screen is taken back by Exec or Suspend
+func (t *TUI) EnableMouse() {
+	t.mouse = true
+	t.screen.EnableMouse()
+}
+
 func (t TUI) Clear() {
 	t.screen.Clear()
 }
@@ -98,6 +112,32 @@ func (t *TUI) Exec(ctx context.Context, name string, args []string, stdin io.Rea
 	return err
 }
 
+// Give the terminal back, call 'stop' which is expected to return once the process is resumed
+// and take control of the terminal again
+func (t *TUI) Suspend(stop func() error) error {
+	t.Finish()
+	err := stop()
+	if e := t.init(); err == nil {
+		err = e
+	}
+
+	return err
+}
+
+// Write 'seq', a control sequence such as the OSC 52 sequence setting the clipboard, to the
+// terminal. The screen is shown first and the caller must not draw on the screen concurrently
+// so that the sequence is never written in the middle of an update of the screen.
+func (t TUI) WriteSequence(seq string) error {
+	t.screen.Show()
+	_, err := io.WriteString(t.out, seq)
+	return err
+}
+
+// Redraw the whole screen, for example after another process wrote to the terminal
+func (t TUI) Sync() {
+	t.screen.Sync()
+}
+
 func (t *TUI) Window(x, y, width, height int) Window {
 	return &subScreen{
 		style:  t.defaultStyle,
@@ -130,37 +170,63 @@ type StyleTransformDefinition struct {
 	Blink      *bool   `toml:"blink"`
 }
 
-func (s StyleTransformDefinition) Parse() (StyleTransform, error) {
-	parseColor := func(s string) (tcell.Color, error) {
-		s = strings.Trim(strings.ToLower(s), " ")
-
-		if strings.HasPrefix(s, "color") {
-			s = strings.TrimPrefix(s, "color")
-			if n, err := strconv.Atoi(s); err == nil {
-				return tcell.Color(n), nil
-			}
-		} else if c, ok := tcell.ColorNames[s]; ok {
-			return c, nil
-		} else if len(s) == 7 && s[0] == '#' {
-			if n, err := strconv.ParseInt(s[1:], 16, 32); err == nil {
-				return tcell.NewHexColor(int32(n)), nil
-			}
+// Parse a color given by name (e.g. "red"), by index in the palette of the terminal
+// ("colorX" where X is in the range 0-255) or in hexadecimal format ("#rrggbb" or "#rgb")
+func ParseColor(s string) (tcell.Color, error) {
+	s = strings.Trim(strings.ToLower(s), " ")
+
+	if strings.HasPrefix(s, "color") {
+		s = strings.TrimPrefix(s, "color")
+		if n, err := strconv.Atoi(s); err == nil {
+			return tcell.Color(n), nil
+		}
+	} else if c, ok := tcell.ColorNames[s]; ok {
+		return c, nil
+	} else if len(s) == 7 && s[0] == '#' {
+		if n, err := strconv.ParseInt(s[1:], 16, 32); err == nil {
+			return tcell.NewHexColor(int32(n)), nil
 		}
+	} else if len(s) == 4 && s[0] == '#' {
+		// Shorthand notation: "#abc" is "#aabbcc"
+		if n, err := strconv.ParseInt(s[1:], 16, 32); err == nil {
+			r, g, b := (n>>8)&0xf, (n>>4)&0xf, n&0xf
+			return tcell.NewRGBColor(int32(r*0x11), int32(g*0x11), int32(b*0x11)), nil
+		}
+	}
 
-		return tcell.Color(0), fmt.Errorf("failed to parse color from string %q", s)
+	return tcell.Color(0), fmt.Errorf("failed to parse color from string %q", s)
+}
+
+// Return the number of colors of the terminal described by the environment variable TERM,
+// 16777216 if it supports truecolor and 1 if it has no colors. Return 0 if the terminal is
+// unknown. As with tcell, setting COLORTERM to "truecolor" or "24bit" enables truecolor and
+// setting TCELL_TRUECOLOR to "disable" disables it.
+func TerminalColors() int {
+	ti, err := terminfo.LookupTerminfo(os.Getenv("TERM"))
+	if err != nil {
+		return 0
+	}
+	if os.Getenv("TCELL_TRUECOLOR") != "disable" && (ti.SetFgBgRGB != "" || ti.SetFgRGB != "" || ti.SetBgRGB != "") {
+		return 1 << 24
 	}
+	if ti.Colors < 1 {
+		return 1
+	}
+	return ti.Colors
+}
 
+func (s StyleTransformDefinition) Parse() (StyleTransform, error) {
 	var err error
 	var fg, bg tcell.Color
 
 	if s.Foreground != nil {
-		if fg, err = parseColor(*s.Foreground); err != nil {
+		if fg, err = ParseColor(*s.Foreground); err != nil {
 			return nil, err
 		}
 	}
 
 	if s.Background != nil {
-		if bg, err = parseColor(*s.Foreground); err != nil {
+		if bg, err = ParseColor(*s.Background); err != nil {
 			return nil, err
 		}
 	}
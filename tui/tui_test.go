@@ -2,6 +2,7 @@ package tui
 
 import (
 	"context"
+	"os"
 	"strconv"
 	"testing"
 	"time"
@@ -115,3 +116,92 @@ func TestTUI_Exec(t *testing.T) {
 		}
 	})
 }
+
+func TestStyleTransformDefinition_Parse(t *testing.T) {
+	str := func(s string) *string { return &s }
+
+	testCases := []struct {
+		name       string
+		definition StyleTransformDefinition
+		fg         tcell.Color
+		bg         tcell.Color
+	}{
+		{
+			name:       "color names",
+			definition: StyleTransformDefinition{Foreground: str("red"), Background: str("white")},
+			fg:         tcell.ColorRed,
+			bg:         tcell.ColorWhite,
+		},
+		{
+			name:       "256 colors",
+			definition: StyleTransformDefinition{Foreground: str("color123")},
+			fg:         tcell.Color(123),
+			bg:         tcell.ColorDefault,
+		},
+		{
+			name:       "truecolor",
+			definition: StyleTransformDefinition{Foreground: str("#ff8700"), Background: str("#abc")},
+			fg:         tcell.NewHexColor(0xff8700),
+			bg:         tcell.NewHexColor(0xaabbcc),
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			transform, err := testCase.definition.Parse()
+			if err != nil {
+				t.Fatal(err)
+			}
+			fg, bg, _ := transform(tcell.StyleDefault).Decompose()
+			if fg != testCase.fg || bg != testCase.bg {
+				t.Fatalf("expected colors (%v, %v) but got (%v, %v)", testCase.fg, testCase.bg, fg, bg)
+			}
+		})
+	}
+
+	t.Run("invalid color", func(t *testing.T) {
+		if _, err := (StyleTransformDefinition{Background: str("#12")}).Parse(); err == nil {
+			t.Fatal("expected error but got nil")
+		}
+	})
+}
+
+func TestTerminalColors(t *testing.T) {
+	for _, name := range []string{"TERM", "COLORTERM", "TCELL_TRUECOLOR"} {
+		value, exists := os.LookupEnv(name)
+		defer func(name string) {
+			if exists {
+				os.Setenv(name, value)
+			} else {
+				os.Unsetenv(name)
+			}
+		}(name)
+		os.Unsetenv(name)
+	}
+
+	testCases := []struct {
+		term      string
+		colorterm string
+		colors    int
+	}{
+		{"xterm-256color", "", 256},
+		{"xterm-256color", "truecolor", 1 << 24},
+		{"xterm", "", 8},
+		{"vt100", "", 1},
+		{"", "", 0},
+	}
+	for _, testCase := range testCases {
+		os.Setenv("TERM", testCase.term)
+		os.Setenv("COLORTERM", testCase.colorterm)
+		if colors := TerminalColors(); colors != testCase.colors {
+			t.Errorf("TERM=%q COLORTERM=%q: expected %d colors but got %d", testCase.term, testCase.colorterm, testCase.colors, colors)
+		}
+	}
+
+	os.Setenv("TERM", "xterm-256color")
+	os.Setenv("COLORTERM", "truecolor")
+	os.Setenv("TCELL_TRUECOLOR", "disable")
+	if colors := TerminalColors(); colors != 256 {
+		t.Errorf("expected 256 colors but got %d", colors)
+	}
+}
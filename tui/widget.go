@@ -6,6 +6,40 @@ import (
 	"github.com/nbedos/cistern/utils"
 )
 
+// Action of the user on a widget. Widgets map keys to actions in Process and perform actions
+// in Do so that applications can let users choose their own keys.
+type Action int
+
+const (
+	ActionNone Action = iota
+	ActionCursorUp
+	ActionCursorDown
+	ActionScrollLeft
+	ActionScrollRight
+	ActionHalfPageUp
+	ActionHalfPageDown
+	ActionPageUp
+	ActionPageDown
+	ActionTop
+	ActionBottom
+	ActionParent
+	ActionNextSibling
+	ActionPreviousSibling
+	ActionNextTopLevel
+	ActionPreviousTopLevel
+	ActionSortLeft
+	ActionSortRight
+	ActionReverseSort
+	ActionOpenFold
+	ActionOpenAllFolds
+	ActionCloseFold
+	ActionCloseAllFolds
+	ActionToggleFold
+	ActionToggleDetails
+	ActionOpenEveryFold
+	ActionCloseEveryFold
+)
+
 type Widget interface {
 	Resize(width int, height int)
 	Draw(w Window)
@@ -0,0 +1,61 @@
+package utils
+
+import (
+	"sync"
+	"time"
+)
+
+// Source of the current time. Reading the time through a Clock rather than from the time
+// package makes code depending on it testable and lets it run faster or slower than real time.
+type Clock interface {
+	Now() time.Time
+	// Return a channel receiving the current time once 'd' has elapsed
+	After(d time.Duration) <-chan time.Time
+}
+
+// Clock of the system
+type SystemClock struct{}
+
+func (SystemClock) Now() time.Time {
+	return time.Now()
+}
+
+func (SystemClock) After(d time.Duration) <-chan time.Time {
+	return time.After(d)
+}
+
+// Clock where waiting takes no time: After() moves the clock forward by the duration instead
+// of blocking. The time of a VirtualClock only changes on calls to After() and Advance().
+type VirtualClock struct {
+	mutex *sync.Mutex
+	now   *time.Time
+}
+
+func NewVirtualClock(now time.Time) VirtualClock {
+	return VirtualClock{
+		mutex: &sync.Mutex{},
+		now:   &now,
+	}
+}
+
+func (c VirtualClock) Now() time.Time {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return *c.now
+}
+
+func (c VirtualClock) After(d time.Duration) <-chan time.Time {
+	timec := make(chan time.Time, 1)
+	timec <- c.Advance(d)
+	return timec
+}
+
+// Move the clock forward by 'd' and return the new time. Negative durations are ignored.
+func (c VirtualClock) Advance(d time.Duration) time.Time {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if d > 0 {
+		*c.now = c.now.Add(d)
+	}
+	return *c.now
+}
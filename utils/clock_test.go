@@ -0,0 +1,38 @@
+package utils
+
+import (
+	"testing"
+	"time"
+)
+
+func TestVirtualClock(t *testing.T) {
+	start := time.Date(2020, 1, 30, 12, 0, 0, 0, time.UTC)
+
+	t.Run("After() returns immediately and moves the clock forward", func(t *testing.T) {
+		clock := NewVirtualClock(start)
+		select {
+		case now := <-clock.After(time.Minute):
+			if expected := start.Add(time.Minute); !now.Equal(expected) || !clock.Now().Equal(expected) {
+				t.Fatalf("expected %v but got %v (clock at %v)", expected, now, clock.Now())
+			}
+		default:
+			t.Fatal("After() should not block")
+		}
+	})
+
+	t.Run("copies share the same time", func(t *testing.T) {
+		clock := NewVirtualClock(start)
+		copied := clock
+		copied.Advance(time.Hour)
+		if expected := start.Add(time.Hour); !clock.Now().Equal(expected) {
+			t.Fatalf("expected %v but got %v", expected, clock.Now())
+		}
+	})
+
+	t.Run("the clock never goes backward", func(t *testing.T) {
+		clock := NewVirtualClock(start)
+		if now := clock.Advance(-time.Hour); !now.Equal(start) {
+			t.Fatalf("expected %v but got %v", start, now)
+		}
+	})
+}
@@ -123,6 +123,11 @@ func MaxNullTime(times ...NullTime) NullTime {
 	return result
 }
 
+type NullFloat64 struct {
+	Valid   bool
+	Float64 float64
+}
+
 type NullDuration struct {
 	Valid    bool
 	Duration time.Duration
@@ -179,6 +184,20 @@ func XDGConfigLocations(filename string) []string {
 	return locations
 }
 
+// Return location of cache files based on
+// https://specifications.freedesktop.org/basedir-spec/basedir-spec-latest.html
+func XDGCacheLocation(filename string) string {
+	cacheHome := getEnvWithDefault("XDG_CACHE_HOME", path.Join(os.Getenv("HOME"), ".cache"))
+	return path.Join(cacheHome, filename)
+}
+
+// Return location of state files based on
+// https://specifications.freedesktop.org/basedir-spec/basedir-spec-latest.html
+func XDGStateLocation(filename string) string {
+	stateHome := getEnvWithDefault("XDG_STATE_HOME", path.Join(os.Getenv("HOME"), ".local", "state"))
+	return path.Join(stateHome, filename)
+}
+
 type PollingStrategy struct {
 	InitialInterval time.Duration
 	Multiplier      float64